@@ -0,0 +1,774 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Checkpoints record the session's workspace after each chat completion so it
+// can be diffed against or rolled back later. A checkpoint is a copy of the
+// workspace part of the overlay upper directory; together with the (read-only)
+// lower directory it describes exactly what the workspace looked like. Files
+// unchanged since the previous checkpoint are hardlinked to it, so a turn only
+// costs the files it touched. Restores write through the mounted overlay and
+// never modify the upper directory directly.
+
+const (
+	// checkpointsDir holds one directory per session, each with one
+	// directory per checkpoint named by its sequence number.
+	checkpointsDir = "/.data/.checkpoints"
+
+	// checkpointScope is the part of the home directory that is checkpointed.
+	// Agent state such as transcripts lives elsewhere in the home and must
+	// not be rolled back with the files.
+	checkpointScope = "workspace"
+
+	// checkpointNotFoundExit is the exit code used when the requested
+	// checkpoint does not exist, so callers can tell it from a failure.
+	checkpointNotFoundExit = 2
+
+	overlayOpaqueXattr = "trusted.overlay.opaque"
+)
+
+var errCheckpointNotFound = errors.New("checkpoint not found")
+
+// checkpointMeta is stored as meta.json in each checkpoint directory.
+type checkpointMeta struct {
+	MessageID string    `json:"messageId"`
+	Seq       int       `json:"seq"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// checkpointChange is a path that differs between a checkpoint and the
+// current workspace.
+type checkpointChange struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // added, modified, deleted (relative to the checkpoint)
+	Dir    bool   `json:"-"`
+}
+
+// checkpointStore manages the checkpoints of one session.
+type checkpointStore struct {
+	dir    string // checkpoints of the session
+	upper  string // overlay upper directory, limited to checkpointScope
+	lower  string // overlay lower directory, limited to checkpointScope
+	merged string // mounted overlay, limited to checkpointScope
+}
+
+func newCheckpointStore(sessionID string) *checkpointStore {
+	return &checkpointStore{
+		dir:    filepath.Join(checkpointsDir, sessionID),
+		upper:  filepath.Join(overlayFSDir, sessionID, "upper", checkpointScope),
+		lower:  filepath.Join(baseHomeDir, checkpointScope),
+		merged: filepath.Join(mountHome, checkpointScope),
+	}
+}
+
+// runCheckpoint implements the checkpoint subcommand. Results are written to
+// stdout as JSON.
+func runCheckpoint(args []string) error {
+	const usage = "usage: discobot-agent checkpoint <create|list|diff|restore> [message-id] [path]"
+	if len(args) < 1 {
+		return errors.New(usage)
+	}
+
+	sessionID := os.Getenv("SESSION_ID")
+	if sessionID == "" {
+		return fmt.Errorf("SESSION_ID environment variable is required")
+	}
+	store := newCheckpointStore(sessionID)
+
+	var result any
+	var err error
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		var list []checkpointMeta
+		list, err = store.list()
+		result = map[string]any{"checkpoints": list}
+	case args[0] == "create" && len(args) == 2:
+		result, err = store.create(args[1], time.Now())
+	case args[0] == "diff" && len(args) == 2:
+		result, err = store.diff(args[1], "")
+	case args[0] == "diff" && len(args) == 3:
+		result, err = store.diff(args[1], args[2])
+	case args[0] == "restore" && len(args) == 2:
+		var changes []checkpointChange
+		changes, err = store.restore(args[1])
+		result = map[string]any{"files": fileChanges(changes)}
+	default:
+		return errors.New(usage)
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
+
+// list returns the session's checkpoints, oldest first.
+func (s *checkpointStore) list() ([]checkpointMeta, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []checkpointMeta{}, nil
+		}
+		return nil, err
+	}
+
+	list := []checkpointMeta{}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name(), "meta.json"))
+		if err != nil {
+			continue
+		}
+		var meta checkpointMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			continue
+		}
+		list = append(list, meta)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Seq < list[j].Seq })
+	return list, nil
+}
+
+// find returns the checkpoint recorded for messageID.
+func (s *checkpointStore) find(messageID string) (*checkpointMeta, error) {
+	list, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].MessageID == messageID {
+			return &list[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errCheckpointNotFound, messageID)
+}
+
+func (s *checkpointStore) path(seq int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d", seq))
+}
+
+// create snapshots the upper directory as the checkpoint for messageID.
+// An existing checkpoint for the same message is replaced.
+func (s *checkpointStore) create(messageID string, now time.Time) (*checkpointMeta, error) {
+	if messageID == "" {
+		return nil, errors.New("message ID is required")
+	}
+
+	list, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	meta := &checkpointMeta{MessageID: messageID, Seq: 1, CreatedAt: now.UTC()}
+	linkDest := ""
+	if len(list) > 0 {
+		last := list[len(list)-1]
+		meta.Seq = last.Seq + 1
+		linkDest = filepath.Join(s.path(last.Seq), "upper")
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(s.dir, fmt.Sprintf(".tmp-%06d", meta.Seq))
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(tmp, 0700); err != nil {
+		return nil, err
+	}
+	if err := snapshotTree(s.upper, filepath.Join(tmp, "upper"), linkDest); err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, fmt.Errorf("failed to snapshot workspace: %w", err)
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "meta.json"), data, 0600); err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, s.path(meta.Seq)); err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	for _, old := range list {
+		if old.MessageID == messageID {
+			if err := os.RemoveAll(s.path(old.Seq)); err != nil {
+				return nil, fmt.Errorf("failed to remove replaced checkpoint: %w", err)
+			}
+		}
+	}
+	return meta, nil
+}
+
+// checkpointDiff is the result of diff. Patch is a unified diff of the
+// changed files, or of the single requested file.
+type checkpointDiff struct {
+	Files []checkpointChange `json:"files"`
+	Patch string             `json:"patch"`
+}
+
+// diff compares the checkpoint for messageID (the "a" side) with the current
+// workspace (the "b" side). If path is set, only that file is compared.
+func (s *checkpointStore) diff(messageID, path string) (*checkpointDiff, error) {
+	meta, err := s.find(messageID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.changes(filepath.Join(s.path(meta.Seq), "upper"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &checkpointDiff{Files: []checkpointChange{}}
+	var patch strings.Builder
+	for _, c := range fileChanges(changes) {
+		if path != "" && c.Path != filepath.Clean(path) {
+			continue
+		}
+		result.Files = append(result.Files, c)
+
+		p, err := s.filePatch(filepath.Join(s.path(meta.Seq), "upper"), c)
+		if err != nil {
+			return nil, err
+		}
+		patch.WriteString(p)
+	}
+	result.Patch = patch.String()
+	return result, nil
+}
+
+// restore makes the workspace match the checkpoint for messageID and returns
+// the paths it changed.
+func (s *checkpointStore) restore(messageID string) ([]checkpointChange, error) {
+	meta, err := s.find(messageID)
+	if err != nil {
+		return nil, err
+	}
+	ckptUpper := filepath.Join(s.path(meta.Seq), "upper")
+	changes, err := s.changes(ckptUpper)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove what must go, deepest first, including entries whose type changed
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		_, cur, err := overlayLookup(s.upper, s.lower, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		_, old, err := overlayLookup(ckptUpper, s.lower, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if cur != nil && (old == nil || old.Mode().Type() != cur.Mode().Type()) {
+			if err := os.RemoveAll(filepath.Join(s.merged, c.Path)); err != nil {
+				return nil, fmt.Errorf("failed to remove %s: %w", c.Path, err)
+			}
+		}
+	}
+
+	// Recreate what the checkpoint had, parents first
+	for _, c := range changes {
+		src, old, err := overlayLookup(ckptUpper, s.lower, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if old == nil {
+			continue
+		}
+		if err := restoreEntry(src, filepath.Join(s.merged, c.Path), old); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", c.Path, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// changes returns every path, sorted, whose entry differs between the view of
+// the checkpoint and the current workspace. Only paths in either upper
+// directory can differ, plus lower paths hidden by a whiteout or opaque
+// directory in either of them.
+func (s *checkpointStore) changes(ckptUpper string) ([]checkpointChange, error) {
+	candidates := map[string]bool{}
+	for _, upper := range []string{ckptUpper, s.upper} {
+		err := walkTree(upper, func(rel string, info fs.FileInfo) error {
+			candidates[rel] = true
+			if isWhiteout(info) || (info.IsDir() && isOpaqueDir(filepath.Join(upper, rel))) {
+				return walkTree(filepath.Join(s.lower, rel), func(sub string, _ fs.FileInfo) error {
+					candidates[filepath.Join(rel, sub)] = true
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(candidates))
+	for p := range candidates {
+		if p != "." {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var changes []checkpointChange
+	for _, p := range paths {
+		oldPath, old, err := overlayLookup(ckptUpper, s.lower, p)
+		if err != nil {
+			return nil, err
+		}
+		curPath, cur, err := overlayLookup(s.upper, s.lower, p)
+		if err != nil {
+			return nil, err
+		}
+
+		var status string
+		switch {
+		case old == nil && cur == nil:
+			continue
+		case old == nil:
+			status = "added"
+		case cur == nil:
+			status = "deleted"
+		default:
+			same, err := sameEntry(oldPath, old, curPath, cur)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				continue
+			}
+			status = "modified"
+		}
+
+		dir := (old != nil && old.IsDir()) || (cur != nil && cur.IsDir())
+		changes = append(changes, checkpointChange{Path: p, Status: status, Dir: dir})
+	}
+	return changes, nil
+}
+
+// filePatch returns a unified diff of one changed file.
+func (s *checkpointStore) filePatch(ckptUpper string, c checkpointChange) (string, error) {
+	oldPath, old, err := overlayLookup(ckptUpper, s.lower, c.Path)
+	if err != nil {
+		return "", err
+	}
+	curPath, cur, err := overlayLookup(s.upper, s.lower, c.Path)
+	if err != nil {
+		return "", err
+	}
+	if (old != nil && !old.Mode().IsRegular()) || (cur != nil && !cur.Mode().IsRegular()) {
+		return fmt.Sprintf("diff a/%s b/%s\nNon-regular file changed\n", c.Path, c.Path), nil
+	}
+	if old == nil {
+		oldPath = os.DevNull
+	}
+	if cur == nil {
+		curPath = os.DevNull
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command("diff", "-u", "--label", "a/"+c.Path, "--label", "b/"+c.Path, oldPath, curPath)
+	cmd.Stdout = &out
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", fmt.Errorf("diff %s: %w", c.Path, err)
+	}
+	if out.Len() == 0 {
+		// Content is identical; only the mode changed
+		return fmt.Sprintf("diff a/%s b/%s\nold mode %o\nnew mode %o\n", c.Path, c.Path, old.Mode().Perm(), cur.Mode().Perm()), nil
+	}
+	return out.String(), nil
+}
+
+// fileChanges drops directory entries, which the UI does not show.
+func fileChanges(changes []checkpointChange) []checkpointChange {
+	files := []checkpointChange{}
+	for _, c := range changes {
+		if !c.Dir {
+			files = append(files, c)
+		}
+	}
+	return files
+}
+
+// adoptForkedCheckpoints moves the checkpoints of forkedFrom to sessionID,
+// dropping those recorded after forkMessageID. It reports whether any were
+// adopted, and does nothing if sessionID already has checkpoints.
+func adoptForkedCheckpoints(root, forkedFrom, sessionID, forkMessageID string) (bool, error) {
+	targetDir := filepath.Join(root, sessionID)
+	if _, err := os.Stat(targetDir); err == nil {
+		return false, nil
+	}
+	if err := os.Rename(filepath.Join(root, forkedFrom), targetDir); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if forkMessageID == "" {
+		return true, nil
+	}
+
+	store := &checkpointStore{dir: targetDir}
+	fork, err := store.find(forkMessageID)
+	if err != nil {
+		if errors.Is(err, errCheckpointNotFound) {
+			return true, nil
+		}
+		return true, err
+	}
+	list, err := store.list()
+	if err != nil {
+		return true, err
+	}
+	for _, c := range list {
+		if c.Seq > fork.Seq {
+			if err := os.RemoveAll(store.path(c.Seq)); err != nil {
+				return true, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// overlayLookup resolves rel in the view formed by mounting upper over lower.
+// It returns the path that holds the entry and its info, or a nil info if rel
+// does not exist in that view.
+func overlayLookup(upper, lower, rel string) (string, fs.FileInfo, error) {
+	lowerVisible := true
+	parts := strings.Split(filepath.Clean(rel), string(filepath.Separator))
+	for i := range parts {
+		p := filepath.Join(upper, filepath.Join(parts[:i+1]...))
+		info, err := os.Lstat(p)
+		if err != nil {
+			if isMissing(err) {
+				break
+			}
+			return "", nil, err
+		}
+		if isWhiteout(info) {
+			return "", nil, nil
+		}
+		if i == len(parts)-1 {
+			return p, info, nil
+		}
+		if !info.IsDir() {
+			// A file in the upper layer hides everything below its path
+			return "", nil, nil
+		}
+		if isOpaqueDir(p) {
+			lowerVisible = false
+		}
+	}
+
+	if !lowerVisible {
+		return "", nil, nil
+	}
+	p := filepath.Join(lower, rel)
+	info, err := os.Lstat(p)
+	if err != nil {
+		if isMissing(err) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+	return p, info, nil
+}
+
+// sameEntry reports whether two entries have the same type, mode, owner and
+// content.
+func sameEntry(aPath string, a fs.FileInfo, bPath string, b fs.FileInfo) (bool, error) {
+	if a.Mode() != b.Mode() {
+		return false, nil
+	}
+	if aSt, ok := a.Sys().(*syscall.Stat_t); ok {
+		if bSt, ok := b.Sys().(*syscall.Stat_t); ok && (aSt.Uid != bSt.Uid || aSt.Gid != bSt.Gid) {
+			return false, nil
+		}
+	}
+
+	switch {
+	case a.IsDir():
+		return true, nil
+	case a.Mode()&fs.ModeSymlink != 0:
+		aLink, err := os.Readlink(aPath)
+		if err != nil {
+			return false, err
+		}
+		bLink, err := os.Readlink(bPath)
+		if err != nil {
+			return false, err
+		}
+		return aLink == bLink, nil
+	case a.Mode().IsRegular():
+		if a.Size() != b.Size() {
+			return false, nil
+		}
+		if os.SameFile(a, b) {
+			return true, nil
+		}
+		return sameContent(aPath, bPath)
+	default:
+		return true, nil
+	}
+}
+
+func sameContent(aPath, bPath string) (bool, error) {
+	af, err := os.Open(aPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = af.Close() }()
+	bf, err := os.Open(bPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = bf.Close() }()
+
+	aBuf := make([]byte, 64*1024)
+	bBuf := make([]byte, 64*1024)
+	for {
+		an, aErr := io.ReadFull(af, aBuf)
+		bn, bErr := io.ReadFull(bf, bBuf)
+		if !bytes.Equal(aBuf[:an], bBuf[:bn]) {
+			return false, nil
+		}
+		aDone := aErr == io.EOF || aErr == io.ErrUnexpectedEOF
+		bDone := bErr == io.EOF || bErr == io.ErrUnexpectedEOF
+		if aErr != nil && !aDone {
+			return false, aErr
+		}
+		if bErr != nil && !bDone {
+			return false, bErr
+		}
+		if aDone || bDone {
+			return aDone == bDone, nil
+		}
+	}
+}
+
+// snapshotTree copies src to dst preserving overlay whiteouts, opaque
+// directories, modes, owners and mtimes. Regular files identical to the
+// same path under linkDest are hardlinked instead of copied. A missing src
+// produces an empty dst.
+func snapshotTree(src, dst, linkDest string) error {
+	if err := os.Mkdir(dst, 0755); err != nil {
+		return err
+	}
+
+	return walkTree(src, func(rel string, info fs.FileInfo) error {
+		from := filepath.Join(src, rel)
+		to := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			if rel != "." {
+				if err := os.Mkdir(to, 0700); err != nil {
+					return err
+				}
+			}
+			if isOpaqueDir(from) {
+				if err := syscall.Setxattr(to, overlayOpaqueXattr, []byte("y"), 0); err != nil {
+					return fmt.Errorf("failed to mark %s opaque: %w", rel, err)
+				}
+			}
+		case isWhiteout(info):
+			if err := syscall.Mknod(to, syscall.S_IFCHR, 0); err != nil {
+				return fmt.Errorf("failed to copy whiteout %s: %w", rel, err)
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(from)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(target, to); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if linkDest != "" && unchangedSince(filepath.Join(linkDest, rel), info) {
+				if err := os.Link(filepath.Join(linkDest, rel), to); err == nil {
+					return nil
+				}
+			}
+			if err := copyFileContent(from, to); err != nil {
+				return err
+			}
+		default:
+			// Sockets and pipes carry no content worth restoring
+			return nil
+		}
+
+		return copyMetadata(to, info)
+	})
+}
+
+// unchangedSince reports whether the regular file at prev has the size,
+// mtime, mode and owner described by info.
+func unchangedSince(prev string, info fs.FileInfo) bool {
+	prevInfo, err := os.Lstat(prev)
+	if err != nil || !prevInfo.Mode().IsRegular() {
+		return false
+	}
+	if prevInfo.Size() != info.Size() || !prevInfo.ModTime().Equal(info.ModTime()) || prevInfo.Mode() != info.Mode() {
+		return false
+	}
+	prevSt, ok1 := prevInfo.Sys().(*syscall.Stat_t)
+	st, ok2 := info.Sys().(*syscall.Stat_t)
+	return ok1 && ok2 && prevSt.Uid == st.Uid && prevSt.Gid == st.Gid
+}
+
+// restoreEntry recreates the entry at src (described by info) at dst, which
+// is a path in the mounted workspace.
+func restoreEntry(src, dst string, info fs.FileInfo) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		if err := os.Mkdir(dst, 0700); err != nil && !os.IsExist(err) {
+			return err
+		}
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return err
+		}
+	case info.Mode().IsRegular():
+		tmp := filepath.Join(filepath.Dir(dst), ".discobot-restore-"+filepath.Base(dst))
+		if err := copyFileContent(src, tmp); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		if err := copyMetadata(tmp, info); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		return os.Rename(tmp, dst)
+	default:
+		return nil
+	}
+
+	return copyMetadata(dst, info)
+}
+
+// copyMetadata applies the owner, mode and (for regular files) mtime of info
+// to path.
+func copyMetadata(path string, info fs.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(path, int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	if err := os.Chmod(path, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	if info.Mode().IsRegular() {
+		return os.Chtimes(path, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+func copyFileContent(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// walkTree calls fn for root (as ".") and every entry below it with paths
+// relative to root, without following symlinks. Entries that disappear while
+// walking are skipped, and a missing root is treated as empty.
+func walkTree(root string, fn func(rel string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if isMissing(err) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			if isMissing(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(rel, info)
+	})
+}
+
+// isWhiteout reports whether info is an overlayfs whiteout, a 0/0 character
+// device marking a deleted lower entry.
+func isWhiteout(info fs.FileInfo) bool {
+	if info.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Rdev == 0
+}
+
+// isOpaqueDir reports whether the upper directory at path hides the lower
+// directory of the same name.
+func isOpaqueDir(path string) bool {
+	buf := make([]byte, 1)
+	n, err := syscall.Getxattr(path, overlayOpaqueXattr, buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
+
+func isMissing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestCheckpointStore returns a store over temp directories. The merged
+// view is the upper directory itself, which matches a real mount as long as
+// the lower directory is empty.
+func newTestCheckpointStore(t *testing.T) *checkpointStore {
+	t.Helper()
+	root := t.TempDir()
+	s := &checkpointStore{
+		dir:   filepath.Join(root, "checkpoints"),
+		upper: filepath.Join(root, "upper"),
+		lower: filepath.Join(root, "lower"),
+	}
+	s.merged = s.upper
+	for _, dir := range []string{s.upper, s.lower} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func requireRoot(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("requires root for whiteouts and trusted xattrs")
+	}
+}
+
+func TestOverlayLookup(t *testing.T) {
+	s := newTestCheckpointStore(t)
+	writeTestFile(t, filepath.Join(s.lower, "a.txt"), "lower")
+	writeTestFile(t, filepath.Join(s.lower, "b.txt"), "lower")
+	writeTestFile(t, filepath.Join(s.lower, "dir/c.txt"), "lower")
+	writeTestFile(t, filepath.Join(s.upper, "a.txt"), "upper")
+	writeTestFile(t, filepath.Join(s.upper, "dir/d.txt"), "upper")
+
+	tests := []struct {
+		rel  string
+		want string // "" means absent
+	}{
+		{"a.txt", filepath.Join(s.upper, "a.txt")},
+		{"b.txt", filepath.Join(s.lower, "b.txt")},
+		{"dir/c.txt", filepath.Join(s.lower, "dir/c.txt")},
+		{"dir/d.txt", filepath.Join(s.upper, "dir/d.txt")},
+		{"missing", ""},
+		{"a.txt/below", ""},
+	}
+	for _, tt := range tests {
+		got, info, err := overlayLookup(s.upper, s.lower, tt.rel)
+		if err != nil {
+			t.Fatalf("overlayLookup(%s): %v", tt.rel, err)
+		}
+		if got != tt.want || (info == nil) != (tt.want == "") {
+			t.Errorf("overlayLookup(%s) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestOverlayLookup_WhiteoutAndOpaque(t *testing.T) {
+	requireRoot(t)
+	s := newTestCheckpointStore(t)
+	writeTestFile(t, filepath.Join(s.lower, "deleted.txt"), "lower")
+	writeTestFile(t, filepath.Join(s.lower, "opaque/hidden.txt"), "lower")
+	if err := syscall.Mknod(filepath.Join(s.upper, "deleted.txt"), syscall.S_IFCHR, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(s.upper, "opaque"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Setxattr(filepath.Join(s.upper, "opaque"), overlayOpaqueXattr, []byte("y"), 0); err != nil {
+		t.Skipf("trusted xattrs not supported: %v", err)
+	}
+
+	for _, rel := range []string{"deleted.txt", "opaque/hidden.txt"} {
+		if _, info, err := overlayLookup(s.upper, s.lower, rel); err != nil || info != nil {
+			t.Errorf("overlayLookup(%s) should be absent, got %v, %v", rel, info, err)
+		}
+	}
+
+	// Snapshots keep whiteouts and opaque markers
+	s.dir = filepath.Join(filepath.Dir(s.upper), "checkpoints")
+	meta, err := s.create("m1", time.Now())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	snap := filepath.Join(s.path(meta.Seq), "upper")
+	info, err := os.Lstat(filepath.Join(snap, "deleted.txt"))
+	if err != nil || !isWhiteout(info) {
+		t.Errorf("whiteout not preserved: %v", err)
+	}
+	if !isOpaqueDir(filepath.Join(snap, "opaque")) {
+		t.Error("opaque marker not preserved")
+	}
+}
+
+func TestCheckpointCreateAndDiff(t *testing.T) {
+	s := newTestCheckpointStore(t)
+	writeTestFile(t, filepath.Join(s.lower, "a.txt"), "base\n")
+	writeTestFile(t, filepath.Join(s.lower, "b.txt"), "base\n")
+	writeTestFile(t, filepath.Join(s.upper, "a.txt"), "turn 1\n")
+	writeTestFile(t, filepath.Join(s.upper, "same.txt"), "unchanged\n")
+
+	first, err := s.create("m1", time.Now())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(s.upper, "a.txt"), "turn 2\n")
+	writeTestFile(t, filepath.Join(s.upper, "c.txt"), "new\n")
+	if err := os.Remove(filepath.Join(s.upper, "same.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := s.diff("m1", "")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []checkpointChange{
+		{Path: "a.txt", Status: "modified"},
+		{Path: "c.txt", Status: "added"},
+		{Path: "same.txt", Status: "deleted"},
+	}
+	if !reflect.DeepEqual(diff.Files, want) {
+		t.Errorf("files = %+v, want %+v", diff.Files, want)
+	}
+	if !strings.Contains(diff.Patch, "--- a/a.txt") || !strings.Contains(diff.Patch, "+turn 2") {
+		t.Errorf("unexpected patch:\n%s", diff.Patch)
+	}
+
+	single, err := s.diff("m1", "c.txt")
+	if err != nil {
+		t.Fatalf("diff single file: %v", err)
+	}
+	if len(single.Files) != 1 || !strings.Contains(single.Patch, "+new") || strings.Contains(single.Patch, "a.txt") {
+		t.Errorf("unexpected single file diff: %+v", single)
+	}
+
+	if _, err := s.diff("missing", ""); err == nil || !strings.Contains(err.Error(), errCheckpointNotFound.Error()) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// Files unchanged since the last checkpoint are hardlinked to it
+	second, err := s.create("m2", time.Now())
+	if err != nil {
+		t.Fatalf("create second: %v", err)
+	}
+	firstInfo, _ := os.Stat(filepath.Join(s.path(first.Seq), "upper", "a.txt"))
+	writeTestFile(t, filepath.Join(s.upper, "a.txt"), "turn 3\n")
+	third, err := s.create("m3", time.Now())
+	if err != nil {
+		t.Fatalf("create third: %v", err)
+	}
+	secondC, _ := os.Stat(filepath.Join(s.path(second.Seq), "upper", "c.txt"))
+	thirdC, _ := os.Stat(filepath.Join(s.path(third.Seq), "upper", "c.txt"))
+	if !os.SameFile(secondC, thirdC) {
+		t.Error("unchanged file should be hardlinked to the previous checkpoint")
+	}
+	thirdA, _ := os.Stat(filepath.Join(s.path(third.Seq), "upper", "a.txt"))
+	if os.SameFile(firstInfo, thirdA) {
+		t.Error("changed file must not share the old checkpoint's inode")
+	}
+
+	list, err := s.list()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var ids []string
+	for _, c := range list {
+		ids = append(ids, c.MessageID)
+	}
+	if !reflect.DeepEqual(ids, []string{"m1", "m2", "m3"}) {
+		t.Errorf("list = %v", ids)
+	}
+
+	// Recording the same message again replaces its checkpoint
+	if _, err := s.create("m2", time.Now()); err != nil {
+		t.Fatalf("re-create: %v", err)
+	}
+	list, _ = s.list()
+	if len(list) != 3 || list[2].MessageID != "m2" {
+		t.Errorf("expected m2 to be replaced and moved last, got %+v", list)
+	}
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	s := newTestCheckpointStore(t)
+	writeTestFile(t, filepath.Join(s.upper, "keep.txt"), "keep\n")
+	writeTestFile(t, filepath.Join(s.upper, "edit.txt"), "original\n")
+	writeTestFile(t, filepath.Join(s.upper, "dir/gone.txt"), "gone\n")
+	if err := os.Symlink("keep.txt", filepath.Join(s.upper, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(s.upper, "edit.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.create("m1", time.Now()); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(s.upper, "edit.txt"), "broken\n")
+	writeTestFile(t, filepath.Join(s.upper, "added/new.txt"), "new\n")
+	if err := os.RemoveAll(filepath.Join(s.upper, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(s.upper, "link")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(s.upper, "link"), "now a file\n")
+
+	changes, err := s.restore("m1")
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	want := []checkpointChange{
+		{Path: "added/new.txt", Status: "added"},
+		{Path: "dir/gone.txt", Status: "deleted"},
+		{Path: "edit.txt", Status: "modified"},
+		{Path: "link", Status: "modified"},
+	}
+	if got := fileChanges(changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored = %+v, want %+v", got, want)
+	}
+
+	for path, content := range map[string]string{
+		"keep.txt":     "keep\n",
+		"edit.txt":     "original\n",
+		"dir/gone.txt": "gone\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(s.merged, path))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v; want %q", path, data, err, content)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(s.merged, "edit.txt")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("edit.txt mode not restored: %v, %v", info, err)
+	}
+	if target, err := os.Readlink(filepath.Join(s.merged, "link")); err != nil || target != "keep.txt" {
+		t.Errorf("link = %q, %v", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.merged, "added")); !os.IsNotExist(err) {
+		t.Errorf("added directory should be removed, stat err = %v", err)
+	}
+
+	// Nothing is left to restore
+	diff, err := s.diff("m1", "")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(diff.Files) != 0 {
+		t.Errorf("expected no differences after restore, got %+v", diff.Files)
+	}
+}
+
+func TestAdoptForkedCheckpoints(t *testing.T) {
+	root := t.TempDir()
+	source := &checkpointStore{
+		dir:   filepath.Join(root, "source"),
+		upper: filepath.Join(t.TempDir(), "upper"),
+	}
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if _, err := source.create(id, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	adopted, err := adoptForkedCheckpoints(root, "source", "fork", "m2")
+	if err != nil || !adopted {
+		t.Fatalf("adoptForkedCheckpoints = %v, %v", adopted, err)
+	}
+	list, err := (&checkpointStore{dir: filepath.Join(root, "fork")}).list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].MessageID != "m2" {
+		t.Errorf("expected checkpoints up to m2, got %+v", list)
+	}
+
+	// Restarts keep what was adopted
+	if adopted, err := adoptForkedCheckpoints(root, "source", "fork", "m2"); err != nil || adopted {
+		t.Errorf("restart: adopted = %v, err = %v", adopted, err)
+	}
+	// A source without checkpoints is not an error
+	if adopted, err := adoptForkedCheckpoints(root, "missing", "other", ""); err != nil || adopted {
+		t.Errorf("missing source: adopted = %v, err = %v", adopted, err)
+	}
+}
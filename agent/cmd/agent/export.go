@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Exports write the session's work to stdout in a form that can be taken to
+// another machine: the commits since the session's base as mbox patches or a
+// git bundle, the uncommitted changes as a binary-safe diff, or the changed
+// files as an archive. Uncommitted changes, including untracked files, are
+// staged into a throwaway index so the workspace's own index is left alone.
+
+const (
+	// exportEmptyExit is the exit code used when there is nothing to export,
+	// so callers can tell it from a failure.
+	exportEmptyExit = 3
+)
+
+var errNothingToExport = errors.New("nothing to export")
+
+// runExport implements the export subcommand.
+func runExport(args []string) error {
+	const usage = "usage: discobot-agent export <mbox|bundle|diff|zip|tar> [base-commit]"
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New(usage)
+	}
+	base := ""
+	if len(args) == 2 {
+		base = args[1]
+	}
+	switch args[0] {
+	case "mbox", "bundle", "diff", "zip", "tar":
+	default:
+		return errors.New(usage)
+	}
+
+	return exportWorkspace(os.Stdout, filepath.Join(mountHome, checkpointScope), args[0], base)
+}
+
+// exportWorkspace writes the repository at dir to w in format. The commit
+// formats and the archives need base; the diff is always against HEAD.
+func exportWorkspace(w io.Writer, dir, format, base string) error {
+	if format != "diff" && base == "" {
+		return fmt.Errorf("%s export requires a base commit", format)
+	}
+
+	switch format {
+	case "mbox", "bundle":
+		if err := checkExportCommits(dir, base); err != nil {
+			return err
+		}
+		if format == "mbox" {
+			return runGitTo(w, dir, nil, "format-patch", "--stdout", "--binary", base+"..HEAD")
+		}
+		return runGitTo(w, dir, nil, "bundle", "create", "-", base+"..HEAD")
+
+	case "diff":
+		tree, err := worktreeTree(dir)
+		if err != nil {
+			return err
+		}
+		if err := runGitTo(io.Discard, dir, nil, "diff", "--quiet", "HEAD", tree); err == nil {
+			return errNothingToExport
+		}
+		return runGitTo(w, dir, nil, "diff", "--binary", "HEAD", tree)
+
+	case "zip", "tar":
+		tree, err := worktreeTree(dir)
+		if err != nil {
+			return err
+		}
+		changed, err := changedTree(dir, base, tree)
+		if err != nil {
+			return err
+		}
+		return runGitTo(w, dir, nil, "archive", "--format="+format, changed)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// checkExportCommits verifies that HEAD has commits that base does not.
+func checkExportCommits(dir, base string) error {
+	count, err := gitOutput(dir, nil, "rev-list", "--count", base+"..HEAD")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(count) == "0" {
+		return errNothingToExport
+	}
+	return nil
+}
+
+// worktreeTree writes the working tree, including untracked files that are
+// not ignored, as a tree object and returns its ID. It stages into a
+// temporary index starting from HEAD.
+func worktreeTree(dir string) (string, error) {
+	return withTempIndex(dir, func(env []string) error {
+		if _, err := gitOutput(dir, env, "read-tree", "HEAD"); err != nil {
+			return err
+		}
+		_, err := gitOutput(dir, env, "add", "-A")
+		return err
+	})
+}
+
+// changedTree writes a tree of only the files added or modified between base
+// and tree, and returns its ID. The files are staged into an empty temporary
+// index through stdin rather than passed as arguments, so any number of them
+// fits on the command line.
+func changedTree(dir, base, tree string) (string, error) {
+	out, err := gitOutput(dir, nil, "diff", "--raw", "-z", "--no-abbrev", "--no-renames", "--diff-filter=d", base, tree)
+	if err != nil {
+		return "", err
+	}
+
+	// Each change is ":<old mode> <new mode> <old id> <new id> <status>\0<path>\0"
+	var entries strings.Builder
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		info := strings.Fields(fields[i])
+		if len(info) != 5 {
+			return "", fmt.Errorf("unexpected git diff output %q", fields[i])
+		}
+		fmt.Fprintf(&entries, "%s %s\t%s\x00", info[1], info[3], fields[i+1])
+	}
+	if entries.Len() == 0 {
+		return "", errNothingToExport
+	}
+
+	return withTempIndex(dir, func(env []string) error {
+		return runGit(io.Discard, strings.NewReader(entries.String()), dir, env, "update-index", "-z", "--index-info")
+	})
+}
+
+// withTempIndex calls stage with the environment of an empty temporary index
+// of the repository at dir, then writes the index as a tree object and
+// returns its ID.
+func withTempIndex(dir string, stage func(env []string) error) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "discobot-export-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmpDir, "index")}
+	if err := stage(env); err != nil {
+		return "", err
+	}
+	tree, err := gitOutput(dir, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// runGitTo runs git in dir with its output going to w.
+func runGitTo(w io.Writer, dir string, env []string, args ...string) error {
+	return runGit(w, nil, dir, env, args...)
+}
+
+// runGit runs git in dir with stdin as its input and its output going to w.
+func runGit(w io.Writer, stdin io.Reader, dir string, env []string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// gitOutput runs git in dir and returns its output.
+func gitOutput(dir string, env []string, args ...string) (string, error) {
+	var out bytes.Buffer
+	if err := runGitTo(&out, dir, env, args...); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
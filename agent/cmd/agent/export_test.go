@@ -0,0 +1,127 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestExportRepo creates a repository with one base commit and returns
+// its directory and the base commit.
+func newTestExportRepo(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(cmd.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "-q", "-b", "main")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "hello\n")
+	writeTestFile(t, filepath.Join(dir, "old.txt"), "old\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+	base := git("rev-parse", "HEAD")
+
+	writeTestFile(t, filepath.Join(dir, "README.md"), "hello, world\n")
+	git("rm", "-q", "old.txt")
+	git("commit", "-q", "-am", "agent work")
+	return dir, base
+}
+
+func TestExportWorkspace_Commits(t *testing.T) {
+	dir, base := newTestExportRepo(t)
+
+	var mbox bytes.Buffer
+	if err := exportWorkspace(&mbox, dir, "mbox", base); err != nil {
+		t.Fatalf("mbox export: %v", err)
+	}
+	if !strings.HasPrefix(mbox.String(), "From ") || !strings.Contains(mbox.String(), "Subject: [PATCH] agent work") {
+		t.Errorf("unexpected mbox:\n%s", mbox.String())
+	}
+
+	var bundle bytes.Buffer
+	if err := exportWorkspace(&bundle, dir, "bundle", base); err != nil {
+		t.Fatalf("bundle export: %v", err)
+	}
+	if !strings.HasPrefix(bundle.String(), "# v2 git bundle") {
+		t.Errorf("unexpected bundle header %q", strings.SplitN(bundle.String(), "\n", 2)[0])
+	}
+
+	head, err := gitOutput(dir, nil, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exportWorkspace(&bytes.Buffer{}, dir, "mbox", strings.TrimSpace(head)); !errors.Is(err, errNothingToExport) {
+		t.Errorf("expected errNothingToExport without new commits, got %v", err)
+	}
+}
+
+func TestExportWorkspace_Diff(t *testing.T) {
+	dir, _ := newTestExportRepo(t)
+
+	if err := exportWorkspace(&bytes.Buffer{}, dir, "diff", ""); !errors.Is(err, errNothingToExport) {
+		t.Fatalf("expected errNothingToExport for a clean tree, got %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "README.md"), "hello, again\n")
+	writeTestFile(t, filepath.Join(dir, "new/file.txt"), "untracked\n")
+	var diff bytes.Buffer
+	if err := exportWorkspace(&diff, dir, "diff", ""); err != nil {
+		t.Fatalf("diff export: %v", err)
+	}
+	for _, want := range []string{"+hello, again", "diff --git a/new/file.txt b/new/file.txt", "+untracked"} {
+		if !strings.Contains(diff.String(), want) {
+			t.Errorf("expected diff to contain %q:\n%s", want, diff.String())
+		}
+	}
+
+	// The workspace's own index is untouched
+	status, err := gitOutput(dir, nil, "status", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(status, "?? new/") {
+		t.Errorf("expected new/ to stay untracked, got %q", status)
+	}
+}
+
+func TestExportWorkspace_Archive(t *testing.T) {
+	dir, base := newTestExportRepo(t)
+	writeTestFile(t, filepath.Join(dir, "new.txt"), "uncommitted\n")
+	writeTestFile(t, filepath.Join(dir, "src", "pkg", "new *.go"), "package pkg\n")
+
+	var archive bytes.Buffer
+	if err := exportWorkspace(&archive, dir, "zip", base); err != nil {
+		t.Fatalf("zip export: %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	// old.txt was deleted, so it is not part of the archive
+	if want := []string{"README.md", "new.txt", "src/pkg/new *.go"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("archive contains %v, want %v", names, want)
+	}
+}
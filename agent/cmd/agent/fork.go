@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Forked sessions start from a clone of another session's data volume. The
+// clone still holds the source session's overlay and agent state under the
+// source's ID, so before the overlay is mounted it is moved to this session,
+// and afterwards the agent's transcript is pointed at this session and cut
+// back to the fork point.
+
+// sessionMappingsFile is where agent-api maps discobot session IDs to
+// agent-native session IDs, relative to the user's home.
+const sessionMappingsFile = ".config/discobot/session-mappings.json"
+
+// adoptForkedOverlay moves the overlay directory of forkedFrom to sessionID.
+// It does nothing if sessionID already has an overlay (e.g. on restart).
+func adoptForkedOverlay(overlayRoot, forkedFrom, sessionID string) error {
+	targetDir := filepath.Join(overlayRoot, sessionID)
+	if _, err := os.Stat(targetDir); err == nil {
+		return nil
+	}
+
+	sourceDir := filepath.Join(overlayRoot, forkedFrom)
+	if _, err := os.Stat(sourceDir); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("discobot-agent: warning: no overlay for forked session %s, starting fresh\n", forkedFrom)
+			return nil
+		}
+		return err
+	}
+
+	// The work dir is overlayfs scratch space and must be empty when mounted
+	if err := os.RemoveAll(filepath.Join(sourceDir, "work")); err != nil {
+		return fmt.Errorf("failed to clear overlay work dir: %w", err)
+	}
+	if err := os.Rename(sourceDir, targetDir); err != nil {
+		return fmt.Errorf("failed to move overlay: %w", err)
+	}
+
+	fmt.Printf("discobot-agent: adopted overlay of forked session %s\n", forkedFrom)
+	return nil
+}
+
+// forkAgentSession maps sessionID to the agent-native session of forkedFrom so
+// the agent resumes the cloned conversation. If forkMessageID is set, the Claude
+// transcript is truncated after that message. It does nothing if sessionID is
+// already mapped, so a restarted sandbox keeps its own history.
+func forkAgentSession(home, forkedFrom, sessionID, forkMessageID string, u *userInfo) error {
+	mappingsPath := filepath.Join(home, sessionMappingsFile)
+	data, err := os.ReadFile(mappingsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// The source never talked to the agent; there is nothing to carry over
+			return nil
+		}
+		return fmt.Errorf("failed to read session mappings: %w", err)
+	}
+
+	var mappings map[string]string
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return fmt.Errorf("failed to parse session mappings: %w", err)
+	}
+	if _, ok := mappings[sessionID]; ok {
+		return nil
+	}
+	nativeID, ok := mappings[forkedFrom]
+	if !ok {
+		return nil
+	}
+
+	if forkMessageID != "" {
+		if err := truncateClaudeTranscript(home, nativeID, forkMessageID, u); err != nil {
+			return err
+		}
+	}
+
+	mappings[sessionID] = nativeID
+	out, err := json.MarshalIndent(mappings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAs(mappingsPath, out, u); err != nil {
+		return fmt.Errorf("failed to write session mappings: %w", err)
+	}
+
+	fmt.Printf("discobot-agent: forked agent session %s from %s\n", nativeID, forkedFrom)
+	return nil
+}
+
+// truncateClaudeTranscript cuts the Claude transcript for nativeID after the
+// message with the given UI message ID. Agents without a Claude transcript
+// are left alone.
+func truncateClaudeTranscript(home, nativeID, messageID string, u *userInfo) error {
+	matches, err := filepath.Glob(filepath.Join(home, ".claude", "projects", "*", nativeID+".jsonl"))
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Printf("discobot-agent: warning: no Claude transcript for %s, history not truncated\n", nativeID)
+		return nil
+	}
+
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read transcript: %w", err)
+		}
+
+		truncated, ok := truncateTranscript(data, messageID)
+		if !ok {
+			fmt.Printf("discobot-agent: warning: message %s not found in %s, history not truncated\n", messageID, path)
+			continue
+		}
+		if err := writeFileAs(path, truncated, u); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+	}
+	return nil
+}
+
+// transcriptRecord holds the fields of a Claude JSONL record needed to find turn boundaries.
+type transcriptRecord struct {
+	Type    string `json:"type"`
+	UUID    string `json:"uuid"`
+	Message struct {
+		ID      string          `json:"id"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// isPrompt reports whether a user record starts a new turn, i.e. it has text
+// or image content rather than only tool results.
+func (r *transcriptRecord) isPrompt() bool {
+	if r.Type != "user" || r.UUID == "" {
+		return false
+	}
+
+	var text string
+	if err := json.Unmarshal(r.Message.Content, &text); err == nil {
+		return strings.TrimSpace(text) != ""
+	}
+
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(r.Message.Content, &blocks); err != nil {
+		return false
+	}
+	for _, b := range blocks {
+		if (b.Type == "text" && strings.TrimSpace(b.Text) != "") || b.Type == "image" {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateTranscript returns the records of a Claude JSONL transcript up to
+// and including the UI message with the given ID, using the same IDs as
+// agent-api: a prompt's record uuid, or the message.id of the first assistant
+// record of a response. Returns false if the message is not found.
+func truncateTranscript(data []byte, messageID string) ([]byte, bool) {
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	if scanner.Err() != nil {
+		return nil, false
+	}
+
+	records := make([]transcriptRecord, len(lines))
+	for i, line := range lines {
+		// Unparseable lines keep a zero record and never mark a boundary
+		_ = json.Unmarshal(line, &records[i])
+	}
+
+	match := -1
+	matchIsPrompt := false
+	for i := range records {
+		r := &records[i]
+		if r.isPrompt() && r.UUID == messageID {
+			match, matchIsPrompt = i, true
+			break
+		}
+		if r.Type == "assistant" && r.Message.ID == messageID {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return nil, false
+	}
+
+	// A prompt ends where the response starts; a response ends at the next prompt
+	cut := len(lines)
+	for i := match + 1; i < len(records); i++ {
+		r := &records[i]
+		if r.isPrompt() || (matchIsPrompt && r.Type == "assistant") {
+			cut = i
+			break
+		}
+	}
+
+	var out bytes.Buffer
+	for _, line := range lines[:cut] {
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), true
+}
+
+// writeFileAs atomically replaces path with data, owned by the given user.
+func writeFileAs(path string, data []byte, u *userInfo) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if u != nil {
+		if err := os.Chown(tmp, u.uid, u.gid); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// transcriptLines is a Claude transcript with two turns. The first response
+// spans two API calls with a tool result in between.
+var transcriptLines = []string{
+	`{"type":"summary","summary":"test"}`,
+	`{"type":"user","uuid":"u1","message":{"role":"user","content":"first prompt"}}`,
+	`{"type":"assistant","uuid":"a1","message":{"id":"msg_1","content":[{"type":"tool_use","id":"t1"}]}}`,
+	`{"type":"user","uuid":"r1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1"}]}}`,
+	`{"type":"assistant","uuid":"a2","message":{"id":"msg_2","content":[{"type":"text","text":"done"}]}}`,
+	`{"type":"user","uuid":"u2","message":{"role":"user","content":[{"type":"text","text":"second prompt"}]}}`,
+	`{"type":"assistant","uuid":"a3","message":{"id":"msg_3","content":[{"type":"text","text":"ok"}]}}`,
+}
+
+func TestTruncateTranscript(t *testing.T) {
+	data := []byte(strings.Join(transcriptLines, "\n") + "\n")
+
+	tests := []struct {
+		name      string
+		messageID string
+		wantLines int
+		wantFound bool
+	}{
+		{name: "first prompt", messageID: "u1", wantLines: 2, wantFound: true},
+		{name: "first response keeps tool results", messageID: "msg_1", wantLines: 5, wantFound: true},
+		{name: "second prompt", messageID: "u2", wantLines: 6, wantFound: true},
+		{name: "last response", messageID: "msg_3", wantLines: 7, wantFound: true},
+		{name: "tool result is not a message", messageID: "r1", wantFound: false},
+		{name: "unknown", messageID: "missing", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := truncateTranscript(data, tt.messageID)
+			if found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if !found {
+				return
+			}
+			want := strings.Join(transcriptLines[:tt.wantLines], "\n") + "\n"
+			if string(got) != want {
+				t.Errorf("truncated transcript =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestAdoptForkedOverlay(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"source/upper/workspace", "source/work/work"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "source/upper/workspace/file.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := adoptForkedOverlay(root, "source", "fork"); err != nil {
+		t.Fatalf("adoptForkedOverlay: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "fork/upper/workspace/file.txt"))
+	if err != nil || string(data) != "changed" {
+		t.Errorf("upper dir not moved: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "fork/work")); !os.IsNotExist(err) {
+		t.Errorf("work dir should be cleared, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "source")); !os.IsNotExist(err) {
+		t.Errorf("source overlay should be gone, stat err = %v", err)
+	}
+
+	// Restarts keep the adopted overlay
+	if err := adoptForkedOverlay(root, "source", "fork"); err != nil {
+		t.Fatalf("adoptForkedOverlay on restart: %v", err)
+	}
+	// A missing source is not an error
+	if err := adoptForkedOverlay(root, "missing", "other"); err != nil {
+		t.Fatalf("adoptForkedOverlay with missing source: %v", err)
+	}
+}
+
+func TestForkAgentSession(t *testing.T) {
+	home := t.TempDir()
+	transcriptDir := filepath.Join(home, ".claude", "projects", "-home-discobot-workspace")
+	if err := os.MkdirAll(transcriptDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	transcript := filepath.Join(transcriptDir, "native-1.jsonl")
+	if err := os.WriteFile(transcript, []byte(strings.Join(transcriptLines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mappingsPath := filepath.Join(home, sessionMappingsFile)
+	if err := os.MkdirAll(filepath.Dir(mappingsPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mappingsPath, []byte(`{"source":"native-1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := forkAgentSession(home, "source", "fork", "msg_1", nil); err != nil {
+		t.Fatalf("forkAgentSession: %v", err)
+	}
+
+	var mappings map[string]string
+	data, _ := os.ReadFile(mappingsPath)
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		t.Fatalf("invalid mappings: %v", err)
+	}
+	if mappings["fork"] != "native-1" || mappings["source"] != "native-1" {
+		t.Errorf("unexpected mappings: %v", mappings)
+	}
+
+	data, _ = os.ReadFile(transcript)
+	if got := strings.Count(string(data), "\n"); got != 5 {
+		t.Errorf("expected 5 transcript lines, got %d", got)
+	}
+
+	// Already mapped: a restart must not truncate again
+	if err := forkAgentSession(home, "source", "fork", "u1", nil); err != nil {
+		t.Fatalf("forkAgentSession on restart: %v", err)
+	}
+	data, _ = os.ReadFile(transcript)
+	if got := strings.Count(string(data), "\n"); got != 5 {
+		t.Errorf("transcript changed on restart: %d lines", got)
+	}
+}
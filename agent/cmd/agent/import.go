@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Imports apply work from outside the session to its workspace: mbox patches
+// with git am, or a diff with git apply, both falling back to a three-way
+// merge. On conflicts the workspace is restored to its state before the
+// import unless the conflicts are to be kept for the agent to resolve.
+
+const (
+	// importEmptyExit is the exit code used when the input has no changes,
+	// the same as for an empty export.
+	importEmptyExit = exportEmptyExit
+)
+
+var errNothingToImport = errors.New("nothing to import")
+
+// importCommit is a commit created by an import.
+type importCommit struct {
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+}
+
+// importConflictFile is a file left unmerged by an import, with its conflict
+// markers.
+type importConflictFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// importConflict describes why an import stopped.
+type importConflict struct {
+	Patch string               `json:"patch,omitempty"` // Subject of the patch that failed (mbox only)
+	Files []importConflictFile `json:"files"`
+	Kept  bool                 `json:"kept"` // Whether the conflicted state was left in the workspace
+}
+
+// importResult is written to stdout as JSON.
+type importResult struct {
+	Commits  []importCommit  `json:"commits"`
+	Files    []string        `json:"files"`
+	Conflict *importConflict `json:"conflict,omitempty"`
+}
+
+// runImport implements the import subcommand. The patch is read from stdin.
+func runImport(args []string) error {
+	const usage = "usage: discobot-agent import <mbox|diff> [--keep-conflicts]"
+	if len(args) < 1 || len(args) > 2 || (args[0] != "mbox" && args[0] != "diff") {
+		return errors.New(usage)
+	}
+	keep := false
+	if len(args) == 2 {
+		if args[1] != "--keep-conflicts" {
+			return errors.New(usage)
+		}
+		keep = true
+	}
+
+	result, err := importPatch(filepath.Join(mountHome, checkpointScope), args[0], os.Stdin, keep)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
+
+// importPatch applies patch to the repository at dir.
+func importPatch(dir, format string, patch io.Reader, keep bool) (*importResult, error) {
+	tmpDir, err := os.MkdirTemp("", "discobot-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	patchFile := filepath.Join(tmpDir, "patch")
+	f, err := os.Create(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(f, patch)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+	if n == 0 {
+		return nil, errNothingToImport
+	}
+
+	if format == "mbox" {
+		return importMbox(dir, patchFile, keep)
+	}
+	return importDiff(dir, patchFile, filepath.Join(tmpDir, "backup"), keep)
+}
+
+// importMbox applies patches with git am. Without keep, a conflict aborts
+// the whole import, including patches applied before it.
+func importMbox(dir, patchFile string, keep bool) (*importResult, error) {
+	if _, err := os.Stat(gitPath(dir, "rebase-apply")); err == nil {
+		return nil, errors.New("a git am or rebase is already in progress")
+	}
+	start, err := gitOutput(dir, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	start = strings.TrimSpace(start)
+
+	result := &importResult{Commits: []importCommit{}, Files: []string{}}
+	if amErr := runGitTo(io.Discard, dir, nil, "am", "--3way", "--quiet", patchFile); amErr != nil {
+		conflict := collectImportConflict(dir)
+		if conflict != nil {
+			conflict.Patch = amPatchSubject(dir)
+		}
+		if conflict == nil || !keep {
+			if err := runGitTo(io.Discard, dir, nil, "am", "--abort"); err != nil {
+				return nil, fmt.Errorf("%v; failed to abort: %w", amErr, err)
+			}
+		}
+		if conflict == nil {
+			return nil, amErr
+		}
+		conflict.Kept = keep
+		result.Conflict = conflict
+		if !keep {
+			return result, nil
+		}
+	}
+
+	log, err := gitOutput(dir, nil, "log", "--reverse", "--format=%H%x00%s", start+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(log), "\n") {
+		if sha, subject, ok := strings.Cut(line, "\x00"); ok {
+			result.Commits = append(result.Commits, importCommit{SHA: sha, Subject: subject})
+		}
+	}
+	files, err := gitOutput(dir, nil, "diff", "--name-only", "-z", start, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	result.Files = splitNul(files)
+	if result.Conflict != nil {
+		for _, c := range result.Conflict.Files {
+			result.Files = appendUnique(result.Files, c.Path)
+		}
+	}
+	return result, nil
+}
+
+// importDiff applies a diff to the working tree with git apply, leaving the
+// index as it was. The files the diff touches and the index are backed up
+// first so a conflicted three-way apply can be undone.
+func importDiff(dir, patchFile, backupDir string, keep bool) (*importResult, error) {
+	numstat, err := gitOutput(dir, nil, "apply", "--numstat", "-z", patchFile)
+	if err != nil {
+		return nil, err
+	}
+	paths := numstatPaths(numstat)
+	if len(paths) == 0 {
+		return nil, errNothingToImport
+	}
+
+	backup, err := backupImportFiles(dir, backupDir, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up files: %w", err)
+	}
+
+	result := &importResult{Commits: []importCommit{}, Files: paths}
+	if err := runGitTo(io.Discard, dir, nil, "apply", patchFile); err == nil {
+		return result, nil
+	}
+
+	applyErr := runGitTo(io.Discard, dir, nil, "apply", "--3way", patchFile)
+	var conflict *importConflict
+	if applyErr != nil {
+		conflict = collectImportConflict(dir)
+	}
+	switch {
+	case applyErr == nil:
+		// A clean three-way apply stages its result; keep only the files
+		if err := backup.restoreIndex(); err != nil {
+			return nil, err
+		}
+		return result, nil
+	case conflict == nil || !keep:
+		if err := backup.restore(); err != nil {
+			return nil, fmt.Errorf("%v; failed to restore files: %w", applyErr, err)
+		}
+		if conflict == nil {
+			return nil, applyErr
+		}
+	}
+	conflict.Kept = keep
+	result.Conflict = conflict
+	return result, nil
+}
+
+// collectImportConflict returns the unmerged files of the repository, or nil
+// if there are none.
+func collectImportConflict(dir string) *importConflict {
+	out, err := gitOutput(dir, nil, "diff", "--name-only", "-z", "--diff-filter=U")
+	if err != nil {
+		return nil
+	}
+	paths := splitNul(out)
+	if len(paths) == 0 {
+		return nil
+	}
+	conflict := &importConflict{}
+	for _, path := range paths {
+		content, _ := os.ReadFile(filepath.Join(dir, path))
+		conflict.Files = append(conflict.Files, importConflictFile{Path: path, Content: string(content)})
+	}
+	return conflict
+}
+
+// amPatchSubject returns the subject of the patch a stopped git am is at.
+func amPatchSubject(dir string) string {
+	info, err := os.ReadFile(gitPath(dir, "rebase-apply/info"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(info), "\n") {
+		if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+			return subject
+		}
+	}
+	return ""
+}
+
+// gitPath resolves a path inside the repository's git directory.
+func gitPath(dir, name string) string {
+	out, err := gitOutput(dir, nil, "rev-parse", "--git-path", name)
+	if err != nil {
+		return filepath.Join(dir, ".git", name)
+	}
+	path := strings.TrimSpace(out)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// numstatPaths returns the paths of git apply --numstat -z output. Renames
+// contribute both their old and new path.
+func numstatPaths(out string) []string {
+	var paths []string
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		record := fields[i]
+		if record == "" {
+			continue
+		}
+		parts := strings.SplitN(record, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[2] != "" {
+			paths = appendUnique(paths, parts[2])
+			continue
+		}
+		// Rename: the old and new path follow as separate fields
+		if i+2 < len(fields) {
+			paths = appendUnique(paths, fields[i+1])
+			paths = appendUnique(paths, fields[i+2])
+			i += 2
+		}
+	}
+	return paths
+}
+
+// importBackup holds copies of the index and of the files an import touches.
+type importBackup struct {
+	repoDir string
+	dir     string
+	index   string // path of the repository's index
+	paths   map[string]bool
+}
+
+func backupImportFiles(repoDir, backupDir string, paths []string) (*importBackup, error) {
+	b := &importBackup{repoDir: repoDir, dir: backupDir, index: gitPath(repoDir, "index"), paths: make(map[string]bool)}
+	if err := os.MkdirAll(filepath.Join(backupDir, "files"), 0700); err != nil {
+		return nil, err
+	}
+	if err := copyIfExists(b.index, filepath.Join(backupDir, "index")); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		src := filepath.Join(repoDir, path)
+		info, err := os.Lstat(src)
+		if os.IsNotExist(err) {
+			b.paths[path] = false
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", path)
+		}
+		dst := filepath.Join(backupDir, "files", path)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return nil, err
+		}
+		if err := copyFileContent(src, dst); err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+		b.paths[path] = true
+	}
+	return b, nil
+}
+
+// restore puts the backed up files and index back, and removes files that
+// did not exist before.
+func (b *importBackup) restore() error {
+	for path, existed := range b.paths {
+		dst := filepath.Join(b.repoDir, path)
+		if !existed {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		src := filepath.Join(b.dir, "files", path)
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(dst)
+		if err := copyFileContent(src, dst); err != nil {
+			return err
+		}
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return b.restoreIndex()
+}
+
+func (b *importBackup) restoreIndex() error {
+	return copyIfExists(filepath.Join(b.dir, "index"), b.index)
+}
+
+// copyIfExists copies src to dst if src exists.
+func copyIfExists(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return copyFileContent(src, dst)
+}
+
+func splitNul(s string) []string {
+	s = strings.TrimRight(s, "\x00")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\x00")
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setTestGitIdentity(t *testing.T) {
+	t.Helper()
+	for _, kv := range [][2]string{
+		{"GIT_AUTHOR_NAME", "Test"}, {"GIT_AUTHOR_EMAIL", "test@example.com"},
+		{"GIT_COMMITTER_NAME", "Test"}, {"GIT_COMMITTER_EMAIL", "test@example.com"},
+	} {
+		t.Setenv(kv[0], kv[1])
+	}
+}
+
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := gitOutput(dir, nil, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(out)
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestImportPatch_Mbox(t *testing.T) {
+	setTestGitIdentity(t)
+	dir, base := newTestExportRepo(t)
+
+	var mbox bytes.Buffer
+	if err := exportWorkspace(&mbox, dir, "mbox", base); err != nil {
+		t.Fatal(err)
+	}
+	mustGit(t, dir, "reset", "-q", "--hard", base)
+
+	result, err := importPatch(dir, "mbox", bytes.NewReader(mbox.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importPatch: %v", err)
+	}
+	if len(result.Commits) != 1 || result.Commits[0].Subject != "agent work" || result.Conflict != nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if strings.Join(result.Files, ",") != "README.md,old.txt" {
+		t.Errorf("files = %v", result.Files)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "README.md")); got != "hello, world\n" {
+		t.Errorf("README.md = %q", got)
+	}
+}
+
+func TestImportPatch_MboxConflict(t *testing.T) {
+	setTestGitIdentity(t)
+	dir, base := newTestExportRepo(t)
+
+	var mbox bytes.Buffer
+	if err := exportWorkspace(&mbox, dir, "mbox", base); err != nil {
+		t.Fatal(err)
+	}
+	mustGit(t, dir, "reset", "-q", "--hard", base)
+	writeTestFile(t, filepath.Join(dir, "README.md"), "hello, there\n")
+	mustGit(t, dir, "commit", "-q", "-am", "local work")
+	head := mustGit(t, dir, "rev-parse", "HEAD")
+
+	result, err := importPatch(dir, "mbox", bytes.NewReader(mbox.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importPatch: %v", err)
+	}
+	if result.Conflict == nil || result.Conflict.Kept || result.Conflict.Patch != "agent work" {
+		t.Fatalf("expected an aborted conflict, got %+v", result)
+	}
+	if len(result.Conflict.Files) != 1 || !strings.Contains(result.Conflict.Files[0].Content, "<<<<<<<") {
+		t.Errorf("unexpected conflict files %+v", result.Conflict.Files)
+	}
+	if got := mustGit(t, dir, "rev-parse", "HEAD"); got != head {
+		t.Errorf("HEAD moved to %s after an aborted import", got)
+	}
+	if _, err := os.Stat(gitPath(dir, "rebase-apply")); err == nil {
+		t.Error("git am is still in progress after an aborted import")
+	}
+
+	result, err = importPatch(dir, "mbox", bytes.NewReader(mbox.Bytes()), true)
+	if err != nil {
+		t.Fatalf("importPatch: %v", err)
+	}
+	if result.Conflict == nil || !result.Conflict.Kept {
+		t.Fatalf("expected a kept conflict, got %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "README.md")); !strings.Contains(got, "<<<<<<<") {
+		t.Errorf("expected conflict markers in README.md, got %q", got)
+	}
+}
+
+func TestImportPatch_Diff(t *testing.T) {
+	setTestGitIdentity(t)
+	dir, _ := newTestExportRepo(t)
+
+	writeTestFile(t, filepath.Join(dir, "README.md"), "hello, again\n")
+	writeTestFile(t, filepath.Join(dir, "new.txt"), "new\n")
+	var diff bytes.Buffer
+	if err := exportWorkspace(&diff, dir, "diff", ""); err != nil {
+		t.Fatal(err)
+	}
+	mustGit(t, dir, "checkout", "-q", "--", "README.md")
+	if err := os.Remove(filepath.Join(dir, "new.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A conflicting local change is restored when the conflict is not kept
+	writeTestFile(t, filepath.Join(dir, "README.md"), "hello, there\n")
+	mustGit(t, dir, "add", "README.md")
+	result, err := importPatch(dir, "diff", bytes.NewReader(diff.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importPatch: %v", err)
+	}
+	if result.Conflict == nil || len(result.Conflict.Files) != 1 || result.Conflict.Files[0].Path != "README.md" {
+		t.Fatalf("expected a conflict in README.md, got %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "README.md")); got != "hello, there\n" {
+		t.Errorf("README.md = %q after an aborted import", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Error("new.txt was left behind by an aborted import")
+	}
+	if got := mustGit(t, dir, "status", "--porcelain"); got != "M  README.md" {
+		t.Errorf("status = %q, want the staged local change", got)
+	}
+
+	mustGit(t, dir, "reset", "-q", "--hard", "HEAD")
+	result, err = importPatch(dir, "diff", bytes.NewReader(diff.Bytes()), false)
+	if err != nil {
+		t.Fatalf("importPatch: %v", err)
+	}
+	if result.Conflict != nil || strings.Join(result.Files, ",") != "README.md,new.txt" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "README.md")); got != "hello, again\n" {
+		t.Errorf("README.md = %q", got)
+	}
+}
+
+func TestNumstatPaths(t *testing.T) {
+	out := "1\t1\ta.txt\x000\t0\t\x00old.txt\x00new.txt\x00-\t-\tbin.dat\x00"
+	if got := strings.Join(numstatPaths(out), ","); got != "a.txt,old.txt,new.txt,bin.dat" {
+		t.Errorf("numstatPaths = %s", got)
+	}
+}
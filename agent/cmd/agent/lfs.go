@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Git LFS objects are downloaded after setup, once the proxy runs, so that
+// they are stored in and served from the proxy cache shared by the
+// project's sessions.
+
+// lfsProxyTimeout bounds how long the download waits for the proxy.
+const lfsProxyTimeout = time.Minute
+
+// setupLFS enables the Git LFS filters in the clone at dir. For workspaces
+// from an HTTP(S) remote, LFS is pointed at the remote's LFS server instead
+// of the source mount, so downloads go through the proxy.
+func setupLFS(dir, source string, submodules bool) error {
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		return errors.New("git-lfs is not installed")
+	}
+	if err := runGitTo(os.Stdout, dir, nil, "lfs", "install", "--local"); err != nil {
+		return err
+	}
+	if endpoint := lfsEndpoint(source); endpoint != "" {
+		if err := runGitTo(os.Stdout, dir, nil, "config", "lfs.url", endpoint); err != nil {
+			return err
+		}
+	}
+	if submodules {
+		return runGitTo(os.Stdout, dir, nil, "submodule", "foreach", "--quiet", "--recursive", "git lfs install --local")
+	}
+	return nil
+}
+
+// lfsEndpoint returns the LFS server URL git-lfs derives from an HTTP(S)
+// remote, or "" for other sources.
+func lfsEndpoint(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(path, ".git") {
+		path += ".git"
+	}
+	u.Path = path + "/info/lfs"
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
+// startLFSPull downloads the LFS objects of the workspace at dir in the
+// background as the session user. Objects the remote does not serve are
+// copied from the source workspace at srcDir. Returns a function that waits
+// for the download to finish.
+func startLFSPull(dir, srcDir string, submodules bool, u *userInfo) func() {
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		start := time.Now()
+
+		env := append(os.Environ(), "HOME="+u.homeDir, "USER="+u.username)
+		if err := waitForProxy(lfsProxyTimeout); err != nil {
+			fmt.Printf("discobot-agent: warning: downloading LFS objects without the proxy: %v\n", err)
+		} else {
+			env = append(env, getProxyEnvVars()...)
+		}
+		git := func(args ...string) error {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			cmd.Env = env
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			cmd.SysProcAttr = &syscall.SysProcAttr{
+				Credential: &syscall.Credential{
+					Uid:    uint32(u.uid),
+					Gid:    uint32(u.gid),
+					Groups: u.groups,
+				},
+			}
+			return cmd.Run()
+		}
+
+		err := git("lfs", "pull")
+		if err != nil {
+			fmt.Printf("discobot-agent: warning: LFS download failed, copying objects from %s: %v\n", srcDir, err)
+			err = git("-c", "lfs.url=file://"+srcDir, "lfs", "pull")
+		}
+		if err == nil && submodules {
+			err = git("submodule", "foreach", "--quiet", "--recursive", "git lfs pull")
+		}
+		if err != nil {
+			fmt.Printf("discobot-agent: warning: failed to download LFS objects: %v\n", err)
+			return
+		}
+		fmt.Printf("discobot-agent: [%.3fs] LFS objects downloaded\n", time.Since(start).Seconds())
+	}()
+	return func() { <-done }
+}
+
+// waitForProxy waits until the proxy accepts connections.
+func waitForProxy(timeout time.Duration) error {
+	addr := fmt.Sprintf("localhost:%d", proxyPort)
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
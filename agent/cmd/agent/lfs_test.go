@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestLFSEndpoint(t *testing.T) {
+	for remote, want := range map[string]string{
+		"https://github.com/org/repo":     "https://github.com/org/repo.git/info/lfs",
+		"https://github.com/org/repo.git": "https://github.com/org/repo.git/info/lfs",
+		"https://gitlab.com/group/repo/":  "https://gitlab.com/group/repo.git/info/lfs",
+		"http://git.internal:8080/r.git":  "http://git.internal:8080/r.git/info/lfs",
+		"git@github.com:org/repo.git":     "",
+		"ssh://git@github.com/org/repo":   "",
+		"/home/user/code/repo":            "",
+	} {
+		if got := lfsEndpoint(remote); got != want {
+			t.Errorf("lfsEndpoint(%q) = %q, want %q", remote, got, want)
+		}
+	}
+}
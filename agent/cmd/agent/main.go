@@ -1,7 +1,10 @@
 // Package main is the entry point for the discobot-agent init process.
-// This binary provides two subcommands:
+// This binary provides five subcommands:
 // - setup: Container initialization (workspace, overlayfs, certs, env files)
 // - proxy: VSOCK port proxy for VZ VMs (Docker event watching + socat forwarding)
+// - checkpoint: Per-turn workspace checkpoints (create, list, diff, restore)
+// - export: Session work as patches, a bundle, a diff or an archive on stdout
+// - import: Apply patches or a diff from stdin to the workspace
 package main
 
 import (
@@ -12,6 +15,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -51,7 +55,7 @@ const (
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "usage: discobot-agent <setup|proxy>\n")
+		fmt.Fprintf(os.Stderr, "usage: discobot-agent <setup|proxy|checkpoint|export|import>\n")
 		os.Exit(1)
 	}
 
@@ -61,13 +65,28 @@ func main() {
 		err = runSetup()
 	case "proxy":
 		err = runProxy()
+	case "checkpoint":
+		err = runCheckpoint(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
 	default:
-		fmt.Fprintf(os.Stderr, "unknown command: %s\nusage: discobot-agent <setup|proxy>\n", os.Args[1])
+		fmt.Fprintf(os.Stderr, "unknown command: %s\nusage: discobot-agent <setup|proxy|checkpoint|export|import>\n", os.Args[1])
 		os.Exit(1)
 	}
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "discobot-agent %s: %v\n", os.Args[1], err)
+		if errors.Is(err, errCheckpointNotFound) {
+			os.Exit(checkpointNotFoundExit)
+		}
+		if errors.Is(err, errNothingToExport) {
+			os.Exit(exportEmptyExit)
+		}
+		if errors.Is(err, errNothingToImport) {
+			os.Exit(importEmptyExit)
+		}
 		os.Exit(1)
 	}
 }
@@ -99,6 +118,13 @@ func runSetup() error {
 	sessionID := os.Getenv("SESSION_ID")
 	workspacePath := os.Getenv("WORKSPACE_PATH")
 	workspaceCommit := os.Getenv("WORKSPACE_COMMIT")
+	workspaceOpts := workspaceOptions{
+		source:     os.Getenv("WORKSPACE_SOURCE"),
+		submodules: os.Getenv("WORKSPACE_SUBMODULES") == "1",
+		lfs:        os.Getenv("WORKSPACE_LFS") == "1",
+	}
+	forkedFrom := os.Getenv("FORKED_FROM_SESSION_ID")
+	forkMessageID := os.Getenv("FORK_MESSAGE_ID")
 
 	if sessionID == "" {
 		return fmt.Errorf("SESSION_ID environment variable is required")
@@ -125,7 +151,7 @@ func runSetup() error {
 
 	// Step 2: Clone workspace
 	stepStart = time.Now()
-	if err := setupWorkspace(workspacePath, workspaceCommit, userInfo); err != nil {
+	if err := setupWorkspace(workspacePath, workspaceCommit, workspaceOpts, userInfo); err != nil {
 		return fmt.Errorf("workspace setup failed: %w", err)
 	}
 	fmt.Printf("discobot-agent: [%.3fs] workspace setup completed\n", time.Since(stepStart).Seconds())
@@ -134,12 +160,35 @@ func runSetup() error {
 	stepStart = time.Now()
 	fmt.Printf("discobot-agent: using OverlayFS\n")
 
+	// A forked session's volume holds the source session's overlay; take it over
+	restoreForkPoint := false
+	if forkedFrom != "" {
+		if err := adoptForkedOverlay(overlayFSDir, forkedFrom, sessionID); err != nil {
+			return fmt.Errorf("forked overlay setup failed: %w", err)
+		}
+		adopted, err := adoptForkedCheckpoints(checkpointsDir, forkedFrom, sessionID, forkMessageID)
+		if err != nil {
+			fmt.Printf("discobot-agent: warning: failed to adopt checkpoints: %v\n", err)
+		}
+		restoreForkPoint = adopted && forkMessageID != ""
+	}
 	if err := setupOverlayFS(sessionID, userInfo); err != nil {
 		return fmt.Errorf("overlayfs setup failed: %w", err)
 	}
 	if err := mountOverlayFS(sessionID); err != nil {
 		return fmt.Errorf("overlayfs mount failed: %w", err)
 	}
+	if forkedFrom != "" {
+		if err := forkAgentSession(mountHome, forkedFrom, sessionID, forkMessageID, userInfo); err != nil {
+			fmt.Printf("discobot-agent: warning: failed to fork agent session: %v\n", err)
+		}
+		// Files should look as they did at the fork point, not at clone time
+		if restoreForkPoint {
+			if _, err := newCheckpointStore(sessionID).restore(forkMessageID); err != nil && !errors.Is(err, errCheckpointNotFound) {
+				fmt.Printf("discobot-agent: warning: failed to restore fork checkpoint: %v\n", err)
+			}
+		}
+	}
 	fmt.Printf("discobot-agent: [%.3fs] filesystem setup completed (overlayfs)\n", time.Since(stepStart).Seconds())
 
 	// Step 4: Mount cache directories
@@ -205,6 +254,13 @@ func runSetup() error {
 	}
 	fmt.Printf("discobot-agent: [%.3fs] environment files written\n", time.Since(stepStart).Seconds())
 
+	// Step 10: Download Git LFS objects in the background once the proxy,
+	// which starts after setup, accepts connections
+	waitLFS := func() {}
+	if workspaceOpts.lfs && workspacePath != "" {
+		waitLFS = startLFSPull(filepath.Join(mountHome, "workspace"), workspacePath, workspaceOpts.submodules, userInfo)
+	}
+
 	// Notify systemd that setup is complete so dependent services can start
 	// while background session hooks continue running.
 	fmt.Printf("discobot-agent: [%.3fs] setup completed successfully\n", time.Since(startupStart).Seconds())
@@ -219,6 +275,7 @@ func runSetup() error {
 	if d := time.Since(stepStart); d > 50*time.Millisecond {
 		fmt.Printf("discobot-agent: [%.3fs] waited for background session hooks\n", d.Seconds())
 	}
+	waitLFS()
 
 	return nil
 }
@@ -691,8 +748,16 @@ func copyFile(src, dst string) error {
 	return err
 }
 
+// workspaceOptions select what setupWorkspace checks out besides the
+// repository itself.
+type workspaceOptions struct {
+	source     string // WORKSPACE_SOURCE, the workspace's git URL or local path
+	submodules bool   // initialize submodules recursively
+	lfs        bool   // enable Git LFS; objects are downloaded after setup
+}
+
 // setupWorkspace clones the workspace if it doesn't exist.
-func setupWorkspace(workspacePath, workspaceCommit string, u *userInfo) error {
+func setupWorkspace(workspacePath, workspaceCommit string, opts workspaceOptions, u *userInfo) error {
 	// If workspace already exists, nothing to do
 	if _, err := os.Stat(workspaceDir); err == nil {
 		fmt.Printf("discobot-agent: workspace already exists at %s\n", workspaceDir)
@@ -744,6 +809,18 @@ func setupWorkspace(workspacePath, workspaceCommit string, u *userInfo) error {
 		}
 	}
 
+	if opts.submodules {
+		fmt.Printf("discobot-agent: initializing submodules\n")
+		if err := initSubmodules(stagingDir, workspacePath); err != nil {
+			return fmt.Errorf("submodule setup failed: %w", err)
+		}
+	}
+	if opts.lfs {
+		if err := setupLFS(stagingDir, opts.source, opts.submodules); err != nil {
+			fmt.Printf("discobot-agent: warning: Git LFS setup failed: %v\n", err)
+		}
+	}
+
 	// Change ownership of all files to the target user
 	fmt.Printf("discobot-agent: changing workspace ownership to %s\n", u.username)
 	if err := chownRecursive(stagingDir, u.uid, u.gid); err != nil {
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Submodules are cloned like the workspace itself: from the checkouts the
+// server made of them in the read-only source mount, so setup needs no
+// network access before the proxy runs.
+
+// initSubmodules checks out the submodules of the clone at dir recursively.
+// Each is cloned from its checkout in the source workspace at srcDir, or from
+// the URL in .gitmodules if the source has not checked it out.
+func initSubmodules(dir, srcDir string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".gitmodules")); err != nil {
+		return nil
+	}
+	out, err := gitOutput(dir, nil, "config", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	if err != nil {
+		return nil // no submodules listed
+	}
+	if err := runGitTo(os.Stdout, dir, nil, "submodule", "init"); err != nil {
+		return err
+	}
+
+	// The source checkouts belong to the host user, like the mount
+	updateArgs := []string{"-c", "protocol.file.allow=always"}
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		key, path, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		paths = append(paths, path)
+		src := filepath.Join(srcDir, path)
+		if _, err := os.Stat(filepath.Join(src, ".git")); err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "submodule."), ".path")
+		if err := runGitTo(io.Discard, dir, nil, "config", "submodule."+name+".url", src); err != nil {
+			return err
+		}
+		updateArgs = append(updateArgs, "-c", "safe.directory="+src)
+	}
+	if err := runGitTo(os.Stdout, dir, nil, append(updateArgs, "submodule", "update")...); err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := initSubmodules(filepath.Join(dir, path), filepath.Join(srcDir, path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitSubmodules(t *testing.T) {
+	setTestGitIdentity(t)
+	newRepo := func(name string) string {
+		t.Helper()
+		dir := filepath.Join(t.TempDir(), name)
+		mustGit(t, "", "init", "-q", "-b", "main", dir)
+		writeTestFile(t, filepath.Join(dir, name+".txt"), name+"\n")
+		mustGit(t, dir, "add", "-A")
+		mustGit(t, dir, "commit", "-q", "-m", name)
+		return dir
+	}
+
+	deep := newRepo("deep")
+	lib := newRepo("lib")
+	mustGit(t, lib, "-c", "protocol.file.allow=always", "submodule", "add", "-q", deep, "deep")
+	mustGit(t, lib, "commit", "-q", "-m", "add deep")
+	src := newRepo("src")
+	mustGit(t, src, "-c", "protocol.file.allow=always", "submodule", "add", "-q", lib, "lib")
+	mustGit(t, src, "commit", "-q", "-m", "add lib")
+	mustGit(t, src, "-c", "protocol.file.allow=always", "submodule", "update", "-q", "--init", "--recursive")
+
+	// Only the source workspace's checkouts are left to clone from
+	for _, dir := range []string{deep, lib} {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	mustGit(t, "", "clone", "-q", src, dir)
+	if err := initSubmodules(dir, src); err != nil {
+		t.Fatalf("initSubmodules: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "lib", "deep", "deep.txt")); got != "deep\n" {
+		t.Errorf("deep.txt = %q", got)
+	}
+	if got := mustGit(t, dir, "config", "submodule.lib.url"); got != filepath.Join(src, "lib") {
+		t.Errorf("lib cloned from %s, want the source checkout", got)
+	}
+}
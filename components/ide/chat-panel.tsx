@@ -620,11 +620,13 @@ export function ChatPanel({
 								status={chatStatus}
 								isLocked={
 									session?.commitStatus === CommitStatus.PENDING ||
-									session?.commitStatus === CommitStatus.COMMITTING
+									session?.commitStatus === CommitStatus.COMMITTING ||
+									session?.commitStatus === CommitStatus.CONFLICTED
 								}
 								placeholder={
 									session?.commitStatus === CommitStatus.PENDING ||
-									session?.commitStatus === CommitStatus.COMMITTING
+									session?.commitStatus === CommitStatus.COMMITTING ||
+									session?.commitStatus === CommitStatus.CONFLICTED
 										? "Chat disabled during commit..."
 										: "Type a message..."
 								}
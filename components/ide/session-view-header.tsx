@@ -71,7 +71,8 @@ export function SessionViewHeader() {
 	const isSessionCommitPending =
 		selectedSession?.commitStatus === CommitStatus.PENDING;
 	const isSessionCommitting =
-		selectedSession?.commitStatus === CommitStatus.COMMITTING;
+		selectedSession?.commitStatus === CommitStatus.COMMITTING ||
+		selectedSession?.commitStatus === CommitStatus.CONFLICTED;
 	const showCommitBusy =
 		isCommitting || isSessionCommitPending || isSessionCommitting;
 
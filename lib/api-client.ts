@@ -24,10 +24,12 @@ import type {
 	CodexExchangeResponse,
 	CreateAgentRequest,
 	CreateCredentialRequest,
+	CreateReviewThreadRequest,
 	CreateWorkspaceRequest,
 	CredentialInfo,
 	DeleteSessionFileRequest,
 	DeleteSessionFileResponse,
+	GitCredentialInfo,
 	GitHubCopilotDeviceCodeRequest,
 	GitHubCopilotDeviceCodeResponse,
 	GitHubCopilotPollRequest,
@@ -35,9 +37,13 @@ import type {
 	HookOutputResponse,
 	HookRerunResponse,
 	HooksStatusResponse,
+	ImportSessionRequest,
+	ImportSessionResponse,
+	ListNetworkResponse,
 	ListServicesResponse,
 	ListSessionFilesResponse,
 	ModelsResponse,
+	NetworkFilter,
 	OAuthAuthorizeResponse,
 	OAuthExchangeRequest,
 	OAuthExchangeResponse,
@@ -48,12 +54,18 @@ import type {
 	ReadSessionFileResponse,
 	RenameSessionFileRequest,
 	RenameSessionFileResponse,
+	ReviewComment,
+	ReviewThread,
 	SearchSessionFilesResponse,
+	SendReviewRequest,
+	SendReviewResponse,
 	ServerConfig,
 	Session,
 	SessionDiffFilesResponse,
 	SessionDiffResponse,
+	SessionExportFormat,
 	SessionSingleFileDiffResponse,
+	SetGitCredentialRequest,
 	StartServiceResponse,
 	StopServiceResponse,
 	Suggestion,
@@ -162,7 +174,12 @@ class ApiClient {
 
 	async updateWorkspace(
 		id: string,
-		data: { path?: string; displayName?: string | null },
+		data: {
+			path?: string;
+			displayName?: string | null;
+			submodules?: boolean;
+			lfs?: boolean;
+		},
 	): Promise<Workspace> {
 		return this.fetch<Workspace>(`/workspaces/${id}`, {
 			method: "PUT",
@@ -368,6 +385,170 @@ class ApiClient {
 		return this.fetch(`/sessions/${sessionId}/diff${query ? `?${query}` : ""}`);
 	}
 
+	/**
+	 * Get the download URL of a session's changes. The server streams the
+	 * export as an attachment, so it can be used directly as a link target.
+	 * @param sessionId Session ID
+	 * @param format Export format (mbox patches by default)
+	 */
+	getSessionExportUrl(
+		sessionId: string,
+		format: SessionExportFormat = "mbox",
+	): string {
+		return appendAuthToken(
+			`${this.base}/sessions/${sessionId}/export?format=${format}`,
+		);
+	}
+
+	/**
+	 * Apply a patch, a ref of the workspace's remote, or another session's
+	 * commits to a session's workspace.
+	 * @param sessionId Session ID
+	 * @param data What to import
+	 */
+	async importSession(
+		sessionId: string,
+		data: ImportSessionRequest,
+	): Promise<ImportSessionResponse> {
+		return this.fetch<ImportSessionResponse>(
+			`/sessions/${sessionId}/import`,
+			{
+				method: "POST",
+				body: JSON.stringify(data),
+			},
+		);
+	}
+
+	// Network
+	/**
+	 * List the HTTP requests recorded by the session's sandbox proxy. Fails
+	 * with 409 when the sandbox is not running.
+	 * @param sessionId Session ID
+	 * @param filter Optional filter
+	 */
+	async getNetwork(
+		sessionId: string,
+		filter?: NetworkFilter,
+	): Promise<ListNetworkResponse> {
+		return this.fetch<ListNetworkResponse>(
+			`/sessions/${sessionId}/network${networkQuery(filter)}`,
+		);
+	}
+
+	/**
+	 * Get the download URL of the requests recorded by the session's sandbox
+	 * proxy as a HAR file.
+	 * @param sessionId Session ID
+	 * @param filter Optional filter
+	 */
+	getNetworkHarUrl(sessionId: string, filter?: NetworkFilter): string {
+		return appendAuthToken(
+			`${this.base}/sessions/${sessionId}/network/har${networkQuery(filter)}`,
+		);
+	}
+
+	/**
+	 * Clear the requests recorded by the session's sandbox proxy.
+	 * @param sessionId Session ID
+	 */
+	async clearNetwork(sessionId: string): Promise<{ status: string }> {
+		return this.fetch(`/sessions/${sessionId}/network`, {
+			method: "DELETE",
+		});
+	}
+
+	// Reviews
+	async getReviewThreads(
+		sessionId: string,
+	): Promise<{ threads: ReviewThread[] }> {
+		return this.fetch<{ threads: ReviewThread[] }>(
+			`/sessions/${sessionId}/reviews`,
+		);
+	}
+
+	async createReviewThread(
+		sessionId: string,
+		data: CreateReviewThreadRequest,
+	): Promise<ReviewThread> {
+		return this.fetch<ReviewThread>(`/sessions/${sessionId}/reviews`, {
+			method: "POST",
+			body: JSON.stringify(data),
+		});
+	}
+
+	async updateReviewThread(
+		sessionId: string,
+		threadId: string,
+		data: { resolved: boolean },
+	): Promise<ReviewThread> {
+		return this.fetch<ReviewThread>(
+			`/sessions/${sessionId}/reviews/${threadId}`,
+			{
+				method: "PUT",
+				body: JSON.stringify(data),
+			},
+		);
+	}
+
+	async deleteReviewThread(sessionId: string, threadId: string): Promise<void> {
+		await this.fetch(`/sessions/${sessionId}/reviews/${threadId}`, {
+			method: "DELETE",
+		});
+	}
+
+	async addReviewComment(
+		sessionId: string,
+		threadId: string,
+		body: string,
+	): Promise<ReviewComment> {
+		return this.fetch<ReviewComment>(
+			`/sessions/${sessionId}/reviews/${threadId}/comments`,
+			{
+				method: "POST",
+				body: JSON.stringify({ body }),
+			},
+		);
+	}
+
+	async updateReviewComment(
+		sessionId: string,
+		threadId: string,
+		commentId: string,
+		body: string,
+	): Promise<ReviewComment> {
+		return this.fetch<ReviewComment>(
+			`/sessions/${sessionId}/reviews/${threadId}/comments/${commentId}`,
+			{
+				method: "PUT",
+				body: JSON.stringify({ body }),
+			},
+		);
+	}
+
+	async deleteReviewComment(
+		sessionId: string,
+		threadId: string,
+		commentId: string,
+	): Promise<void> {
+		await this.fetch(
+			`/sessions/${sessionId}/reviews/${threadId}/comments/${commentId}`,
+			{ method: "DELETE" },
+		);
+	}
+
+	async sendReview(
+		sessionId: string,
+		data: SendReviewRequest = {},
+	): Promise<SendReviewResponse> {
+		return this.fetch<SendReviewResponse>(
+			`/sessions/${sessionId}/reviews/send`,
+			{
+				method: "POST",
+				body: JSON.stringify(data),
+			},
+		);
+	}
+
 	// Messages
 	async getMessages(sessionId: string): Promise<{ messages: ChatMessage[] }> {
 		return this.fetch<{ messages: ChatMessage[] }>(
@@ -500,6 +681,28 @@ class ApiClient {
 		return this.fetch<{ suggestions: Suggestion[] }>(`/suggestions?${params}`);
 	}
 
+	// Git credentials
+	async getGitCredentials(): Promise<{ credentials: GitCredentialInfo[] }> {
+		return this.fetch<{ credentials: GitCredentialInfo[] }>(
+			"/git-credentials",
+		);
+	}
+
+	async setGitCredential(
+		data: SetGitCredentialRequest,
+	): Promise<GitCredentialInfo> {
+		return this.fetch<GitCredentialInfo>("/git-credentials", {
+			method: "POST",
+			body: JSON.stringify(data),
+		});
+	}
+
+	async deleteGitCredential(credentialId: string): Promise<void> {
+		await this.fetch(`/git-credentials/${credentialId}`, {
+			method: "DELETE",
+		});
+	}
+
 	// Credentials
 	async getCredentials(): Promise<{ credentials: CredentialInfo[] }> {
 		return this.fetch<{ credentials: CredentialInfo[] }>("/credentials");
@@ -732,4 +935,14 @@ class ApiClient {
 	}
 }
 
+/** Query string of a network filter, empty if nothing is filtered. */
+function networkQuery(filter?: NetworkFilter): string {
+	const params = new URLSearchParams();
+	if (filter?.since) params.set("since", String(filter.since));
+	if (filter?.host) params.set("host", filter.host);
+	if (filter?.limit) params.set("limit", String(filter.limit));
+	const query = params.toString();
+	return query ? `?${query}` : "";
+}
+
 export const api = new ApiClient();
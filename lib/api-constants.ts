@@ -20,6 +20,7 @@ export const CommitStatus = {
 	NONE: "",
 	PENDING: "pending",
 	COMMITTING: "committing",
+	CONFLICTED: "conflicted",
 	COMPLETED: "completed",
 	FAILED: "failed",
 } as const;
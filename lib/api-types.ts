@@ -49,6 +49,21 @@ export type SessionStatus =
 export type CommitStatus =
 	(typeof CommitStatusConstants)[keyof typeof CommitStatusConstants];
 
+/** A patch that conflicted with the workspace during a commit */
+export interface CommitConflicts {
+	/** Subject of the conflicting patch */
+	patch: string;
+	conflicts: {
+		path: string;
+		hunks?: {
+			startLine: number;
+			ours: string;
+			base?: string;
+			theirs: string;
+		}[];
+	}[];
+}
+
 export interface Session {
 	id: string;
 	name: string;
@@ -65,6 +80,12 @@ export interface Session {
 	baseCommit?: string;
 	/** Final commit SHA after patches applied to workspace */
 	appliedCommit?: string;
+	/** Branch commits go to instead of the workspace checkout */
+	targetBranch?: string;
+	/** Conflicts of the last patch that failed to apply */
+	commitConflicts?: CommitConflicts;
+	/** Times the agent was asked to rebase conflicting patches in this commit */
+	conflictRetries?: number;
 	/** Error message if status is "error" */
 	errorMessage?: string;
 	files: FileNode[];
@@ -105,6 +126,10 @@ export interface Workspace {
 	errorMessage?: string;
 	/** Current commit SHA (if git workspace) */
 	commit?: string;
+	/** Submodules are initialized recursively */
+	submodules?: boolean;
+	/** Git LFS objects are downloaded */
+	lfs?: boolean;
 	/** Working directory path on disk (if initialized) */
 	workDir?: string;
 }
@@ -181,6 +206,10 @@ export interface CreateWorkspaceRequest {
 	displayName?: string;
 	sourceType: "local" | "git";
 	provider?: string;
+	/** Initialize submodules recursively */
+	submodules?: boolean;
+	/** Download Git LFS objects */
+	lfs?: boolean;
 }
 
 export interface CreateSessionRequest {
@@ -290,6 +319,32 @@ export interface CreateCredentialRequest {
 	oauthData?: OAuthData;
 }
 
+export type GitCredentialAuthType = "ssh_key" | "https_token";
+
+/** Client-safe git credential (no secrets) */
+export interface GitCredentialInfo {
+	id: string;
+	host: string;
+	authType: GitCredentialAuthType;
+	/** Username sent with HTTPS tokens */
+	username?: string;
+	/** SHA256 fingerprint of SSH keys */
+	fingerprint?: string;
+	/** Whether git inside sandboxes can use the credential */
+	forwardToSandbox: boolean;
+	createdAt: string;
+	updatedAt: string;
+}
+
+export interface SetGitCredentialRequest {
+	host: string;
+	authType: GitCredentialAuthType;
+	privateKey?: string;
+	token?: string;
+	username?: string;
+	forwardToSandbox?: boolean;
+}
+
 export interface OAuthExchangeRequest {
 	code: string;
 	verifier: string;
@@ -604,6 +659,155 @@ export interface SessionSingleFileDiffResponse {
 	patch: string;
 }
 
+/**
+ * Download format of a session's changes: patches or a git bundle of the
+ * commits since the base commit, a diff of the uncommitted changes, or an
+ * archive of the changed files.
+ */
+export type SessionExportFormat = "mbox" | "bundle" | "diff" | "zip" | "tar";
+
+/** Start of a request or response body recorded by the sandbox proxy */
+export interface NetworkBody {
+	text: string;
+	/** "base64" for binary bodies */
+	encoding?: "base64";
+	truncated?: boolean;
+}
+
+/**
+ * HTTP request recorded by the sandbox proxy. Sensitive header values,
+ * including credentials the proxy injected, are "[REDACTED]".
+ */
+export interface NetworkEntry {
+	id: number;
+	startedAt: string;
+	method: string;
+	url: string;
+	proto: string;
+	requestHeaders: Record<string, string[]>;
+	requestSize: number;
+	requestBody?: NetworkBody;
+	status?: number;
+	responseHeaders?: Record<string, string[]>;
+	responseSize: number;
+	responseBody?: NetworkBody;
+	/** Time until the response headers arrived */
+	waitMs: number;
+	/** Time until the response body was read */
+	durationMs: number;
+	/** "HIT" for responses served from the proxy cache */
+	cache?: string;
+	/** Refused by the proxy allowlist */
+	blocked?: boolean;
+	error?: string;
+	/** False while the request is in progress */
+	done: boolean;
+}
+
+/** Selects recorded requests */
+export interface NetworkFilter {
+	/** Only requests with a greater id, for polling */
+	since?: number;
+	/** Only requests whose host contains this */
+	host?: string;
+	/** At most this many of the most recent requests */
+	limit?: number;
+}
+
+/** Response from listing recorded requests */
+export interface ListNetworkResponse {
+	entries: NetworkEntry[];
+}
+
+/**
+ * Work to apply to a session's workspace. Exactly one of patch, ref and
+ * sourceSessionId is set.
+ */
+export interface ImportSessionRequest {
+	/** Uploaded patch in format */
+	patch?: string;
+	/** Format of patch (default "mbox") */
+	format?: "mbox" | "diff";
+	/** Ref of the workspace's remote, e.g. refs/pull/12/head */
+	ref?: string;
+	/** Another session of the project whose commits are imported */
+	sourceSessionId?: string;
+	/** Leave a conflicted import in the workspace instead of undoing it */
+	keepConflicts?: boolean;
+	/** Tell the agent what changed */
+	notify?: boolean;
+	model?: string;
+	mode?: string;
+}
+
+export interface ImportSessionResponse {
+	/** False when a conflict undid the import */
+	applied: boolean;
+	commits: { sha: string; subject: string }[];
+	files: string[];
+	/** Present when the import stopped at a conflict */
+	conflict?: CommitConflicts;
+	/** Conflict markers were left in the workspace */
+	conflictsKept?: boolean;
+	/** Notification sent to the agent */
+	messageId?: string;
+}
+
+/** Side of a file diff a review thread is anchored to */
+export type ReviewSide = "new" | "old";
+
+/** Comment of a review thread */
+export interface ReviewComment {
+	id: string;
+	userId?: string;
+	body: string;
+	/** True until the comment is sent to the agent */
+	pending: boolean;
+	sentAt?: string;
+	createdAt: string;
+	updatedAt: string;
+}
+
+/** Review thread anchored to lines of the session diff */
+export interface ReviewThread {
+	id: string;
+	path: string;
+	side: ReviewSide;
+	startLine: number;
+	endLine: number;
+	/** Version of the file's diff the thread was anchored to */
+	revision: string;
+	/** The anchored lines changed in a later diff */
+	outdated: boolean;
+	resolved: boolean;
+	createdBy?: string;
+	comments: ReviewComment[];
+	createdAt: string;
+}
+
+/** Request to comment on lines of the session diff */
+export interface CreateReviewThreadRequest {
+	path: string;
+	side?: ReviewSide;
+	startLine: number;
+	endLine?: number;
+	body: string;
+}
+
+/** Request to send pending review comments to the agent */
+export interface SendReviewRequest {
+	/** Overall comment placed before the line comments */
+	body?: string;
+	model?: string;
+	mode?: string;
+}
+
+/** Response once the agent started working on a review */
+export interface SendReviewResponse {
+	messageId: string;
+	comments: number;
+}
+
 // ============================================================================
 // Service Types
 // ============================================================================
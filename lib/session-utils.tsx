@@ -66,6 +66,9 @@ export function getSessionStatusIndicator(
 	if (session.commitStatus === CommitStatus.COMMITTING) {
 		return <Loader2 className={`${iconSize} text-blue-500 animate-spin`} />;
 	}
+	if (session.commitStatus === CommitStatus.CONFLICTED) {
+		return <Loader2 className={`${iconSize} text-yellow-500 animate-spin`} />;
+	}
 	if (session.commitStatus === CommitStatus.FAILED) {
 		return <AlertCircle className={`${iconSize} text-destructive`} />;
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,6 +16,7 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/config"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
 	"github.com/obot-platform/discobot/proxy/internal/proxy"
+	"github.com/obot-platform/discobot/proxy/internal/recorder"
 )
 
 // Server is the API server for runtime configuration.
@@ -54,6 +56,11 @@ func (s *Server) setupRoutes() {
 	r.Get("/api/cache/stats", s.handleCacheStats)
 	r.Delete("/api/cache", s.handleClearCache)
 
+	// Traffic recording endpoints
+	r.Get("/api/network", s.handleListNetwork)
+	r.Get("/api/network/har", s.handleNetworkHAR)
+	r.Delete("/api/network", s.handleClearNetwork)
+
 	s.router = r
 }
 
@@ -135,6 +142,21 @@ func (s *Server) validateConfig(cfg *config.RuntimeConfig) error {
 		}
 	}
 
+	for placeholder, secret := range cfg.Secrets {
+		if secret.Value == "" && len(secret.Hosts) == 0 {
+			continue // Removes the placeholder
+		}
+		if err := secret.Validate(placeholder); err != nil {
+			return fmt.Errorf("invalid secret: %w", err)
+		}
+	}
+
+	if cfg.Policy != nil {
+		if err := cfg.Policy.Validate(); err != nil {
+			return fmt.Errorf("invalid policy: %w", err)
+		}
+	}
+
 	// Validate domain patterns in allowlist
 	if cfg.Allowlist != nil {
 		for _, domain := range cfg.Allowlist.Domains {
@@ -196,6 +218,60 @@ func (s *Server) handleClearCache(w http.ResponseWriter, _ *http.Request) {
 	s.jsonOK(w, map[string]string{"status": "ok"})
 }
 
+// handleListNetwork handles GET /api/network.
+func (s *Server) handleListNetwork(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseNetworkFilter(r)
+	if err != nil {
+		s.jsonError(w, err.Error())
+		return
+	}
+
+	entries := s.proxy.GetRecorder().List(filter)
+	if entries == nil {
+		entries = []recorder.Entry{}
+	}
+	s.jsonOK(w, map[string]interface{}{"entries": entries})
+}
+
+// handleNetworkHAR handles GET /api/network/har.
+func (s *Server) handleNetworkHAR(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseNetworkFilter(r)
+	if err != nil {
+		s.jsonError(w, err.Error())
+		return
+	}
+
+	s.jsonOK(w, recorder.ToHAR(s.proxy.GetRecorder().List(filter)))
+}
+
+// handleClearNetwork handles DELETE /api/network.
+func (s *Server) handleClearNetwork(w http.ResponseWriter, _ *http.Request) {
+	s.proxy.GetRecorder().Clear()
+	s.logger.Info("network recording cleared via API")
+	s.jsonOK(w, map[string]string{"status": "ok"})
+}
+
+// parseNetworkFilter parses the since, host and limit query parameters.
+func parseNetworkFilter(r *http.Request) (recorder.Filter, error) {
+	query := r.URL.Query()
+	filter := recorder.Filter{Host: query.Get("host")}
+	if since := query.Get("since"); since != "" {
+		id, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since: %s", since)
+		}
+		filter.Since = id
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid limit: %s", limit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
 func calculateHitRate(stats cache.Stats) float64 {
 	total := stats.Hits + stats.Misses
 	if total == 0 {
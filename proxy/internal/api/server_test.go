@@ -8,8 +8,10 @@ import (
 	"testing"
 
 	"github.com/obot-platform/discobot/proxy/internal/config"
+	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
 	"github.com/obot-platform/discobot/proxy/internal/proxy"
+	"github.com/obot-platform/discobot/proxy/internal/recorder"
 )
 
 func testLogger(t *testing.T) *logger.Logger {
@@ -196,6 +198,105 @@ func TestAPI_POSTConfig_InvalidDomainPattern(t *testing.T) {
 	}
 }
 
+func TestAPI_PATCHConfig_Secrets(t *testing.T) {
+	proxyServer := createTestProxyServer(t)
+	log := testLogger(t)
+	apiServer := New(proxyServer, log)
+
+	const placeholder = "sk-ant-REDACTED"
+	patch := func(body string) int {
+		req := httptest.NewRequest("PATCH", "/api/config", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		apiServer.ServeHTTP(w, req)
+		return w.Code
+	}
+	apply := func() string {
+		req := httptest.NewRequest("GET", "https://api.anthropic.com/v1/messages", nil)
+		req.Header.Set("X-Api-Key", placeholder)
+		proxyServer.GetInjector().Apply(req)
+		return req.Header.Get("X-Api-Key")
+	}
+
+	code := patch(`{"secrets": {"` + placeholder + `": {"value": "sk-ant-real", "hosts": ["api.anthropic.com"]}}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if got := apply(); got != "sk-ant-real" {
+		t.Errorf("Expected the placeholder to be substituted, got %q", got)
+	}
+
+	// A null secret removes the placeholder
+	if code := patch(`{"secrets": {"` + placeholder + `": null}}`); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if got := apply(); got != placeholder {
+		t.Errorf("Expected the placeholder to be kept after removal, got %q", got)
+	}
+
+	invalid := []string{
+		`{"secrets": {"short": {"value": "v", "hosts": ["api.anthropic.com"]}}}`,
+		`{"secrets": {"` + placeholder + `": {"value": "v"}}}`,
+		`{"secrets": {"` + placeholder + `": {"value": "v", "hosts": ["invalid**pattern"]}}}`,
+	}
+	for _, body := range invalid {
+		if code := patch(body); code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, code)
+		}
+	}
+}
+
+func TestAPI_PATCHConfig_Policy(t *testing.T) {
+	proxyServer := createTestProxyServer(t)
+	log := testLogger(t)
+	apiServer := New(proxyServer, log)
+
+	patch := func(body string) int {
+		req := httptest.NewRequest("PATCH", "/api/config", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		apiServer.ServeHTTP(w, req)
+		return w.Code
+	}
+	post := filter.Request{Protocol: config.ProtocolHTTP, Host: "pastebin.com", Port: 443, Method: "POST", Path: "/api/api_post.php"}
+
+	code := patch(`{"policy": {"rules": [{"name": "no pastebin posts", "action": "deny", "hosts": ["pastebin.com"], "methods": ["POST"]}]}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if d := proxyServer.GetFilter().Check(post); d.Allowed || d.Rule != "no pastebin posts" {
+		t.Errorf("Expected the POST denied by the rule, got %+v", d)
+	}
+
+	// Other PATCHes keep the policy
+	if code := patch(`{"headers": {"example.com": {"set": {"X-Test": "1"}}}}`); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if d := proxyServer.GetFilter().Check(post); d.Allowed {
+		t.Error("Expected the policy kept by an unrelated PATCH")
+	}
+
+	// A new policy replaces the rules
+	if code := patch(`{"policy": {"default": "allow", "rules": []}}`); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if d := proxyServer.GetFilter().Check(post); !d.Allowed {
+		t.Errorf("Expected the POST allowed after replacing the policy, got %+v", d)
+	}
+
+	invalid := []string{
+		`{"policy": {"default": "block"}}`,
+		`{"policy": {"rules": [{"action": "deny", "path_regex": "("}]}}`,
+		`{"policy": {"rules": [{"action": "deny", "hosts": ["invalid**pattern"]}]}}`,
+		`{"policy": {"rules": [{"action": "allow", "protocols": ["ftp"]}]}}`,
+	}
+	for _, body := range invalid {
+		if code := patch(body); code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, code)
+		}
+	}
+}
+
 func TestAPI_MethodNotAllowed(t *testing.T) {
 	proxyServer := createTestProxyServer(t)
 	log := testLogger(t)
@@ -314,6 +415,79 @@ func TestAPI_POSTConfig_DisableAllowlist(t *testing.T) {
 	}
 }
 
+func TestAPI_Network(t *testing.T) {
+	cfg := config.Default()
+	cfg.TLS.CertDir = t.TempDir()
+	cfg.Recorder.Enabled = true
+	proxyServer, err := proxy.New(cfg, testLogger(t))
+	if err != nil {
+		t.Fatalf("Failed to create proxy server: %v", err)
+	}
+	apiServer := New(proxyServer, testLogger(t))
+
+	rec := proxyServer.GetRecorder()
+	for _, rawURL := range []string{"https://registry.npmjs.org/left-pad", "https://api.github.com/user"} {
+		req := httptest.NewRequest("GET", rawURL, nil)
+		rec.Response(rec.Start(req, nil), &http.Response{StatusCode: 200, Header: http.Header{}}, nil)
+	}
+
+	// List
+	req := httptest.NewRequest("GET", "/api/network?host=github&since=0", nil)
+	w := httptest.NewRecorder()
+	apiServer.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		Entries []struct {
+			ID  uint64 `json:"id"`
+			URL string `json:"url"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Entries) != 1 || list.Entries[0].URL != "https://api.github.com/user" {
+		t.Errorf("Unexpected entries: %+v", list.Entries)
+	}
+
+	// Invalid filter
+	req = httptest.NewRequest("GET", "/api/network?limit=-1", nil)
+	w = httptest.NewRecorder()
+	apiServer.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid limit, got %d", w.Code)
+	}
+
+	// HAR
+	req = httptest.NewRequest("GET", "/api/network/har", nil)
+	w = httptest.NewRecorder()
+	apiServer.ServeHTTP(w, req)
+	var har struct {
+		Log struct {
+			Version string            `json:"version"`
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &har); err != nil {
+		t.Fatalf("Failed to decode HAR: %v", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Errorf("Unexpected HAR: version %q with %d entries", har.Log.Version, len(har.Log.Entries))
+	}
+
+	// Clear
+	req = httptest.NewRequest("DELETE", "/api/network", nil)
+	w = httptest.NewRecorder()
+	apiServer.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if entries := rec.List(recorder.Filter{}); len(entries) != 0 {
+		t.Errorf("Expected no entries after clearing, got %d", len(entries))
+	}
+}
+
 // ServeHTTP implements http.Handler for testing
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
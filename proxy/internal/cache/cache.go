@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,8 +23,13 @@ var (
 	ErrCacheMiss = errors.New("cache miss")
 	// ErrCacheDisabled indicates the cache is not enabled.
 	ErrCacheDisabled = errors.New("cache disabled")
+	// ErrTooLarge indicates an entry is larger than the whole cache.
+	ErrTooLarge = errors.New("entry exceeds cache size")
 )
 
+// tempPrefix starts the names of cache files that are still being written.
+const tempPrefix = ".tmp-"
+
 // Cache provides content caching with LRU eviction.
 type Cache struct {
 	dir     string
@@ -46,13 +52,30 @@ type Stats struct {
 	CurrentSize int64
 }
 
-// Entry represents a cached HTTP response.
+// Entry represents a cached HTTP response. Entries returned by Get keep
+// their cache file open so the body can be streamed from disk; close them
+// when done.
 type Entry struct {
 	StatusCode int
 	Headers    http.Header
-	Body       []byte
 	CachedAt   time.Time
-	Size       int64
+	Size       int64 // Size of the body
+
+	file       *os.File // open cache file
+	bodyOffset int64    // where the body starts in file
+}
+
+// Body returns a reader of the cached body.
+func (e *Entry) Body() *io.SectionReader {
+	return io.NewSectionReader(e.file, e.bodyOffset, e.Size)
+}
+
+// Close closes the cache file.
+func (e *Entry) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	return e.file.Close()
 }
 
 // New creates a new cache instance.
@@ -81,7 +104,8 @@ func New(dir string, maxSize int64, enabled bool, logger *zap.Logger) (*Cache, e
 	return c, nil
 }
 
-// Get retrieves a cached response.
+// Get retrieves a cached response. Only its header is read; the caller
+// streams the body and must close the entry.
 func (c *Cache) Get(key string) (*Entry, error) {
 	if !c.enabled {
 		return nil, ErrCacheDisabled
@@ -96,8 +120,9 @@ func (c *Cache) Get(key string) (*Entry, error) {
 		return nil, ErrCacheMiss
 	}
 
-	// Read from disk
-	entry, err := c.readEntry(key)
+	// Open from disk. The open file stays readable if the entry is evicted
+	// or replaced while it is served.
+	entry, err := c.openEntry(key)
 	if err != nil {
 		c.stats.Errors++
 		c.logger.Debug("cache read error", zap.String("key", key), zap.Error(err))
@@ -111,24 +136,112 @@ func (c *Cache) Get(key string) (*Entry, error) {
 	return entry, nil
 }
 
-// Put stores a response in the cache.
-func (c *Cache) Put(key string, entry *Entry) error {
+// Put stores a response with the body read from body in the cache.
+func (c *Cache) Put(key string, entry *Entry, body io.Reader) error {
+	w, err := c.NewWriter(key, entry.StatusCode, entry.Headers)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		w.Abort()
+		return err
+	}
+	_, err = w.Commit()
+	return err
+}
+
+// Writer writes a cache entry to a temporary file in the cache directory.
+// The entry is invisible to Get until Commit renames the file into place.
+type Writer struct {
+	cache      *Cache
+	key        string
+	file       *os.File
+	headerSize int64
+	size       int64 // bytes written, header included
+	err        error // sticky write error
+}
+
+// NewWriter starts a cache entry for key with the given response status and
+// headers; the body is written to the returned Writer.
+func (c *Cache) NewWriter(key string, statusCode int, headers http.Header) (*Writer, error) {
 	if !c.enabled {
-		return ErrCacheDisabled
+		return nil, ErrCacheDisabled
+	}
+
+	file, err := os.CreateTemp(c.dir, tempPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("create cache file: %w", err)
+	}
+
+	w := &Writer{cache: c, key: key, file: file}
+	header := encodeEntryHeader(&Entry{StatusCode: statusCode, Headers: headers, CachedAt: time.Now()})
+	if _, err := w.Write(header); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	w.headerSize = w.size
+
+	return w, nil
+}
+
+// Write appends p to the entry. It fails with ErrTooLarge once the entry
+// outgrows the cache, so an oversized download does not fill the disk.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.size+int64(len(p)) > w.cache.maxSize {
+		w.err = ErrTooLarge
+		return 0, w.err
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("write cache file: %w", err)
+	}
+	return n, w.err
+}
+
+// Commit atomically adds the entry to the cache, replacing any previous
+// entry for its key, and returns the size of its body. The entry is
+// discarded if a write failed.
+func (w *Writer) Commit() (int64, error) {
+	if w.err != nil {
+		w.Abort()
+		return 0, w.err
+	}
+	if err := w.file.Close(); err != nil {
+		_ = os.Remove(w.file.Name())
+		return 0, fmt.Errorf("close cache file: %w", err)
 	}
 
+	c := w.cache
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Write to disk
-	if err := c.writeEntry(key, entry); err != nil {
+	hash := cacheKey(w.key)
+
+	// Write metadata file with original key first: data files without one
+	// are skipped when the index is loaded
+	metaPath := filepath.Join(c.dir, hash+".meta")
+	if err := os.WriteFile(metaPath, []byte(w.key), 0644); err != nil {
 		c.stats.Errors++
-		return err
+		_ = os.Remove(w.file.Name())
+		return 0, fmt.Errorf("write meta file: %w", err)
+	}
+	if err := os.Rename(w.file.Name(), filepath.Join(c.dir, hash)); err != nil {
+		c.stats.Errors++
+		_ = os.Remove(w.file.Name())
+		return 0, fmt.Errorf("rename cache file: %w", err)
 	}
 
 	// Add to index
-	c.index.add(key, entry.Size)
-	c.stats.CurrentSize += entry.Size
+	if size, ok := c.index.sizeOf(w.key); ok {
+		c.stats.CurrentSize -= size
+	}
+	c.index.add(w.key, w.size)
+	c.stats.CurrentSize += w.size
 	c.stats.Stores++
 
 	// Evict if over size limit
@@ -139,7 +252,68 @@ func (c *Cache) Put(key string, entry *Entry) error {
 		}
 	}
 
-	return nil
+	return w.size - w.headerSize, nil
+}
+
+// Body returns a reader of the body written so far.
+func (w *Writer) Body() *io.SectionReader {
+	return io.NewSectionReader(w.file, w.headerSize, w.size-w.headerSize)
+}
+
+// Abort discards the entry.
+func (w *Writer) Abort() {
+	_ = w.file.Close()
+	_ = os.Remove(w.file.Name())
+}
+
+// Check verifies a cached entry with v, removing it if verification fails.
+// It is used for entries cached before their expected digest was known and
+// returns the verification error, or ErrCacheMiss if key is not cached.
+func (c *Cache) Check(key string, v *DigestVerifier) error {
+	if !c.enabled {
+		return ErrCacheDisabled
+	}
+
+	c.mu.Lock()
+	if !c.index.exists(key) {
+		c.mu.Unlock()
+		return ErrCacheMiss
+	}
+	entry, err := c.openEntry(key)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+
+	if _, err := io.Copy(v, entry.Body()); err != nil {
+		return fmt.Errorf("read cache file: %w", err)
+	}
+	verifyErr := v.Verify(entry.Body())
+	if verifyErr == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Keep the entry if it was replaced while it was being checked
+	checked, err := entry.file.Stat()
+	if err != nil {
+		return verifyErr
+	}
+	current, err := os.Stat(filepath.Join(c.dir, cacheKey(key)))
+	if err != nil || !os.SameFile(checked, current) {
+		return verifyErr
+	}
+	if size, ok := c.index.sizeOf(key); ok {
+		c.index.remove(key)
+		c.stats.CurrentSize -= size
+		if err := c.removeFiles(key); err != nil {
+			c.logger.Warn("failed to remove cache entry", zap.String("key", key), zap.Error(err))
+		}
+	}
+	return verifyErr
 }
 
 // GetStats returns current cache statistics.
@@ -177,59 +351,58 @@ func cacheKey(path string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// readEntry reads a cache entry from disk.
-func (c *Cache) readEntry(key string) (*Entry, error) {
+// openEntry opens a cache entry on disk and reads its header.
+func (c *Cache) openEntry(key string) (*Entry, error) {
 	path := filepath.Join(c.dir, cacheKey(key))
 
-	data, err := os.ReadFile(path)
+	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
+			c.index.remove(key)
 			return nil, ErrCacheMiss
 		}
-		return nil, fmt.Errorf("read cache file: %w", err)
+		return nil, fmt.Errorf("open cache file: %w", err)
 	}
 
-	entry, err := deserializeEntry(data)
+	info, err := file.Stat()
 	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat cache file: %w", err)
+	}
+
+	entry, err := readEntryHeader(file)
+	if err != nil || entry.bodyOffset > info.Size() {
 		// Corrupt cache file, remove it
+		file.Close()
 		_ = os.Remove(path)
 		c.index.remove(key)
 		return nil, ErrCacheMiss
 	}
+	entry.file = file
+	entry.Size = info.Size() - entry.bodyOffset
 
 	return entry, nil
 }
 
-// writeEntry writes a cache entry to disk.
-func (c *Cache) writeEntry(key string, entry *Entry) error {
-	hash := cacheKey(key)
-	path := filepath.Join(c.dir, hash)
-
-	data, err := serializeEntry(entry)
-	if err != nil {
-		return fmt.Errorf("serialize entry: %w", err)
+// evictLRU evicts the least recently used entry.
+func (c *Cache) evictLRU() error {
+	key, size := c.index.evict()
+	if key == "" {
+		return errors.New("no entries to evict")
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("write cache file: %w", err)
+	if err := c.removeFiles(key); err != nil {
+		return err
 	}
 
-	// Write metadata file with original key
-	metaPath := filepath.Join(c.dir, hash+".meta")
-	if err := os.WriteFile(metaPath, []byte(key), 0644); err != nil {
-		return fmt.Errorf("write meta file: %w", err)
-	}
+	c.stats.CurrentSize -= size
+	c.stats.Evictions++
 
 	return nil
 }
 
-// evictLRU evicts the least recently used entry.
-func (c *Cache) evictLRU() error {
-	key, size := c.index.evict()
-	if key == "" {
-		return errors.New("no entries to evict")
-	}
-
+// removeFiles removes the files of a cache entry.
+func (c *Cache) removeFiles(key string) error {
 	hash := cacheKey(key)
 	path := filepath.Join(c.dir, hash)
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
@@ -240,9 +413,6 @@ func (c *Cache) evictLRU() error {
 	metaPath := filepath.Join(c.dir, hash+".meta")
 	_ = os.Remove(metaPath) // Ignore error if meta file doesn't exist
 
-	c.stats.CurrentSize -= size
-	c.stats.Evictions++
-
 	return nil
 }
 
@@ -258,6 +428,12 @@ func (c *Cache) loadIndex() error {
 			continue
 		}
 
+		// Remove entries left half-written by a previous run
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			_ = os.Remove(filepath.Join(c.dir, entry.Name()))
+			continue
+		}
+
 		// Skip metadata files
 		if filepath.Ext(entry.Name()) == ".meta" {
 			continue
@@ -284,24 +460,30 @@ func (c *Cache) loadIndex() error {
 	return nil
 }
 
-// serializeEntry converts an Entry to bytes.
-func serializeEntry(entry *Entry) ([]byte, error) {
+const (
+	// entryHeaderSize is the size of the fixed part of an entry header:
+	// status code, timestamp and headers length.
+	entryHeaderSize = 16
+
+	// maxHeadersSize bounds the serialized headers read from a cache file.
+	maxHeadersSize = 1 << 20
+)
+
+// encodeEntryHeader encodes the part of a cache file preceding the body.
+func encodeEntryHeader(entry *Entry) []byte {
 	var buf bytes.Buffer
 
 	// Write status code (4 bytes)
-	statusBytes := []byte{
+	buf.Write([]byte{
 		byte(entry.StatusCode >> 24),
 		byte(entry.StatusCode >> 16),
 		byte(entry.StatusCode >> 8),
 		byte(entry.StatusCode),
-	}
-	if _, err := buf.Write(statusBytes); err != nil {
-		return nil, err
-	}
+	})
 
 	// Write timestamp (8 bytes)
 	timestamp := entry.CachedAt.Unix()
-	timeBytes := []byte{
+	buf.Write([]byte{
 		byte(timestamp >> 56),
 		byte(timestamp >> 48),
 		byte(timestamp >> 40),
@@ -310,38 +492,26 @@ func serializeEntry(entry *Entry) ([]byte, error) {
 		byte(timestamp >> 16),
 		byte(timestamp >> 8),
 		byte(timestamp),
-	}
-	if _, err := buf.Write(timeBytes); err != nil {
-		return nil, err
-	}
+	})
 
 	// Write headers (length-prefixed)
 	headersData := serializeHeaders(entry.Headers)
 	headerLen := len(headersData)
-	headerLenBytes := []byte{
+	buf.Write([]byte{
 		byte(headerLen >> 24),
 		byte(headerLen >> 16),
 		byte(headerLen >> 8),
 		byte(headerLen),
-	}
-	if _, err := buf.Write(headerLenBytes); err != nil {
-		return nil, err
-	}
-	if _, err := buf.Write(headersData); err != nil {
-		return nil, err
-	}
+	})
+	buf.Write(headersData)
 
-	// Write body
-	if _, err := buf.Write(entry.Body); err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
+	return buf.Bytes()
 }
 
-// deserializeEntry converts bytes to an Entry.
-func deserializeEntry(data []byte) (*Entry, error) {
-	if len(data) < 16 {
+// readEntryHeader reads the part of a cache file preceding the body.
+func readEntryHeader(r io.Reader) (*Entry, error) {
+	var data [entryHeaderSize]byte
+	if _, err := io.ReadFull(r, data[:]); err != nil {
 		return nil, errors.New("invalid entry data")
 	}
 
@@ -355,19 +525,17 @@ func deserializeEntry(data []byte) (*Entry, error) {
 		int64(data[8])<<24 | int64(data[9])<<16 | int64(data[10])<<8 | int64(data[11])
 	entry.CachedAt = time.Unix(timestamp, 0)
 
-	// Read headers length
-	headerLen := int(data[12])<<24 | int(data[13])<<16 | int(data[14])<<8 | int(data[15])
-	if len(data) < 16+headerLen {
+	// Read headers
+	headerLen := int64(data[12])<<24 | int64(data[13])<<16 | int64(data[14])<<8 | int64(data[15])
+	if headerLen > maxHeadersSize {
+		return nil, errors.New("invalid header length")
+	}
+	headersData := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, headersData); err != nil {
 		return nil, errors.New("invalid header length")
 	}
-
-	// Read headers
-	headersData := data[16 : 16+headerLen]
 	entry.Headers = deserializeHeaders(headersData)
-
-	// Read body
-	entry.Body = data[16+headerLen:]
-	entry.Size = int64(len(data))
+	entry.bodyOffset = entryHeaderSize + headerLen
 
 	return entry, nil
 }
@@ -403,48 +571,3 @@ func deserializeHeaders(data []byte) http.Header {
 	}
 	return headers
 }
-
-// CaptureResponse captures an HTTP response for caching.
-func CaptureResponse(resp *http.Response) (*Entry, error) {
-	if resp == nil {
-		return nil, errors.New("nil response")
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response body: %w", err)
-	}
-	resp.Body.Close()
-
-	// Restore body for downstream use
-	resp.Body = io.NopCloser(bytes.NewReader(body))
-
-	entry := &Entry{
-		StatusCode: resp.StatusCode,
-		Headers:    resp.Header.Clone(),
-		Body:       body,
-		CachedAt:   time.Now(),
-		Size:       int64(len(body)),
-	}
-
-	return entry, nil
-}
-
-// RestoreResponse creates an HTTP response from a cache entry.
-func RestoreResponse(entry *Entry, req *http.Request) *http.Response {
-	resp := &http.Response{
-		StatusCode: entry.StatusCode,
-		Header:     entry.Headers.Clone(),
-		Body:       io.NopCloser(bytes.NewReader(entry.Body)),
-		Request:    req,
-		Proto:      "HTTP/1.1",
-		ProtoMajor: 1,
-		ProtoMinor: 1,
-	}
-
-	// Add cache header
-	resp.Header.Set("X-Cache", "HIT")
-	resp.Header.Set("X-Cache-Date", entry.CachedAt.Format(time.RFC3339))
-
-	return resp
-}
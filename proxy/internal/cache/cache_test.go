@@ -1,9 +1,14 @@
 package cache
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap"
@@ -28,11 +33,9 @@ func TestCache_GetPut(t *testing.T) {
 	entry := &Entry{
 		StatusCode: 200,
 		Headers:    http.Header{"Content-Type": []string{"text/plain"}},
-		Body:       []byte("test body"),
-		Size:       9,
 	}
 
-	if err := c.Put("test-key", entry); err != nil {
+	if err := c.Put("test-key", entry, strings.NewReader("test body")); err != nil {
 		t.Fatalf("Put failed: %v", err)
 	}
 
@@ -41,13 +44,17 @@ func TestCache_GetPut(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Get failed: %v", err)
 	}
+	defer retrieved.Close()
 
 	if retrieved.StatusCode != entry.StatusCode {
 		t.Errorf("status code mismatch: got %d, want %d", retrieved.StatusCode, entry.StatusCode)
 	}
 
-	if string(retrieved.Body) != string(entry.Body) {
-		t.Errorf("body mismatch: got %s, want %s", retrieved.Body, entry.Body)
+	if body := readBody(t, retrieved); body != "test body" {
+		t.Errorf("body mismatch: got %s, want %s", body, "test body")
+	}
+	if retrieved.Size != 9 {
+		t.Errorf("size mismatch: got %d, want 9", retrieved.Size)
 	}
 
 	// Check stats
@@ -78,11 +85,9 @@ func TestCache_Eviction(t *testing.T) {
 		entry := &Entry{
 			StatusCode: 200,
 			Headers:    http.Header{},
-			Body:       make([]byte, 50), // 50 bytes each
-			Size:       50,
 		}
 		key := string(rune('a' + i))
-		if err := c.Put(key, entry); err != nil {
+		if err := c.Put(key, entry, bytes.NewReader(make([]byte, 50))); err != nil { // 50 bytes each
 			t.Fatalf("Put failed: %v", err)
 		}
 	}
@@ -112,10 +117,8 @@ func TestCache_Clear(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		entry := &Entry{
 			StatusCode: 200,
-			Body:       []byte("test"),
-			Size:       4,
 		}
-		if err := c.Put(string(rune('a'+i)), entry); err != nil {
+		if err := c.Put(string(rune('a'+i)), entry, strings.NewReader("test")); err != nil {
 			t.Fatalf("Put failed: %v", err)
 		}
 	}
@@ -153,11 +156,9 @@ func TestCache_Disabled(t *testing.T) {
 	// Operations should return ErrCacheDisabled
 	entry := &Entry{
 		StatusCode: 200,
-		Body:       []byte("test"),
-		Size:       4,
 	}
 
-	if err := c.Put("key", entry); err != ErrCacheDisabled {
+	if err := c.Put("key", entry, strings.NewReader("test")); err != ErrCacheDisabled {
 		t.Errorf("expected ErrCacheDisabled, got %v", err)
 	}
 
@@ -179,11 +180,9 @@ func TestCache_Persistence(t *testing.T) {
 	entry := &Entry{
 		StatusCode: 200,
 		Headers:    http.Header{"X-Test": []string{"value"}},
-		Body:       []byte("persistent data"),
-		Size:       15,
 	}
 
-	if err := c1.Put("persist-key", entry); err != nil {
+	if err := c1.Put("persist-key", entry, strings.NewReader("persistent data")); err != nil {
 		t.Fatalf("Put failed: %v", err)
 	}
 
@@ -198,9 +197,13 @@ func TestCache_Persistence(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Get failed after reload: %v", err)
 	}
+	defer retrieved.Close()
 
-	if string(retrieved.Body) != string(entry.Body) {
-		t.Errorf("body mismatch after reload: got %s, want %s", retrieved.Body, entry.Body)
+	if body := readBody(t, retrieved); body != "persistent data" {
+		t.Errorf("body mismatch after reload: got %s, want %s", body, "persistent data")
+	}
+	if got := retrieved.Headers.Get("X-Test"); got != "value" {
+		t.Errorf("header mismatch after reload: got %q, want %q", got, "value")
 	}
 }
 
@@ -225,7 +228,7 @@ func TestCacheKey(t *testing.T) {
 	}
 }
 
-func TestSerializeDeserialize(t *testing.T) {
+func TestEncodeReadEntryHeader(t *testing.T) {
 	entry := &Entry{
 		StatusCode: 404,
 		Headers: http.Header{
@@ -233,20 +236,15 @@ func TestSerializeDeserialize(t *testing.T) {
 			"X-Custom":       []string{"value1", "value2"},
 			"Content-Length": []string{"123"},
 		},
-		Body: []byte("test body content"),
-		Size: 17,
 	}
 
-	// Serialize
-	data, err := serializeEntry(entry)
-	if err != nil {
-		t.Fatalf("serializeEntry failed: %v", err)
-	}
+	// Encode, followed by the body
+	data := append(encodeEntryHeader(entry), "test body content"...)
 
-	// Deserialize
-	retrieved, err := deserializeEntry(data)
+	// Read back
+	retrieved, err := readEntryHeader(bytes.NewReader(data))
 	if err != nil {
-		t.Fatalf("deserializeEntry failed: %v", err)
+		t.Fatalf("readEntryHeader failed: %v", err)
 	}
 
 	// Verify fields
@@ -254,8 +252,8 @@ func TestSerializeDeserialize(t *testing.T) {
 		t.Errorf("status code mismatch: got %d, want %d", retrieved.StatusCode, entry.StatusCode)
 	}
 
-	if string(retrieved.Body) != string(entry.Body) {
-		t.Errorf("body mismatch: got %s, want %s", retrieved.Body, entry.Body)
+	if body := string(data[retrieved.bodyOffset:]); body != "test body content" {
+		t.Errorf("body offset mismatch: body at %d is %q", retrieved.bodyOffset, body)
 	}
 
 	// Verify all headers
@@ -271,54 +269,155 @@ func TestSerializeDeserialize(t *testing.T) {
 			}
 		}
 	}
+
+	if _, err := readEntryHeader(bytes.NewReader(data[:10])); err == nil {
+		t.Error("expected error for truncated header")
+	}
 }
 
-func TestCaptureAndRestoreResponse(t *testing.T) {
-	// Create a test directory for cache files
+func TestCache_WriterAbort(t *testing.T) {
 	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "test.txt")
-	if err := os.WriteFile(testFile, []byte("test content"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
+	c, err := New(tmpDir, 10*1024*1024, true, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
 	}
 
-	// Create a file response
-	file, err := os.Open(testFile)
+	w, err := c.NewWriter("key", 200, http.Header{})
 	if err != nil {
-		t.Fatalf("failed to open test file: %v", err)
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := w.Write([]byte("partial")); err != nil {
+		t.Fatalf("Write failed: %v", err)
 	}
-	defer file.Close()
 
-	resp := &http.Response{
-		StatusCode: 200,
-		Header: http.Header{
-			"Content-Type": []string{"text/plain"},
-		},
-		Body: file,
+	// Not visible before commit
+	if _, err := c.Get("key"); err != ErrCacheMiss {
+		t.Errorf("expected cache miss before commit, got %v", err)
+	}
+
+	w.Abort()
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
 	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files after abort, got %d", len(entries))
+	}
+}
 
-	// Capture response
-	entry, err := CaptureResponse(resp)
+func TestCache_WriterTooLarge(t *testing.T) {
+	c, err := New(t.TempDir(), 100, true, zap.NewNop())
 	if err != nil {
-		t.Fatalf("CaptureResponse failed: %v", err)
+		t.Fatalf("New failed: %v", err)
 	}
 
-	if entry.StatusCode != 200 {
-		t.Errorf("status code mismatch: got %d, want 200", entry.StatusCode)
+	err = c.Put("key", &Entry{StatusCode: 200}, bytes.NewReader(make([]byte, 200)))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if stats := c.GetStats(); stats.Stores != 0 || stats.CurrentSize != 0 {
+		t.Errorf("expected nothing stored, got %+v", stats)
 	}
+}
 
-	if string(entry.Body) != "test content" {
-		t.Errorf("body mismatch: got %s, want 'test content'", entry.Body)
+func TestCache_ReplaceEntry(t *testing.T) {
+	c, err := New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
 	}
 
-	// Restore response
-	req, _ := http.NewRequest("GET", "http://example.com/test", nil)
-	restored := RestoreResponse(entry, req)
+	if err := c.Put("key", &Entry{StatusCode: 200}, strings.NewReader("first version")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	size := c.GetStats().CurrentSize
+
+	// An entry being served keeps its content when it is replaced
+	old, err := c.Get("key")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer old.Close()
 
-	if restored.StatusCode != entry.StatusCode {
-		t.Errorf("restored status code mismatch: got %d, want %d", restored.StatusCode, entry.StatusCode)
+	if err := c.Put("key", &Entry{StatusCode: 200}, strings.NewReader("second version")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := c.GetStats().CurrentSize; got != size+1 {
+		t.Errorf("expected size %d after replacing, got %d", size+1, got)
+	}
+	if body := readBody(t, old); body != "first version" {
+		t.Errorf("old entry body: got %q, want %q", body, "first version")
 	}
 
-	if restored.Header.Get("X-Cache") != "HIT" {
-		t.Error("expected X-Cache: HIT header")
+	entry, err := c.Get("key")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer entry.Close()
+	if body := readBody(t, entry); body != "second version" {
+		t.Errorf("new entry body: got %q, want %q", body, "second version")
+	}
+}
+
+func TestCache_LoadIndexRemovesTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, tempPrefix+"123"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(tmpDir, 10*1024*1024, true, zap.NewNop()); err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, tempPrefix+"123")); !os.IsNotExist(err) {
+		t.Errorf("expected stale temp file to be removed, got %v", err)
+	}
+}
+
+func TestCache_Check(t *testing.T) {
+	c, err := New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	sum, _ := hex.DecodeString(helloDigest)
+	verifier := func() *DigestVerifier {
+		return newDigestVerifier([]digest{{alg: algSHA256, sum: sum, source: "test"}})
+	}
+
+	if err := c.Check("good", verifier()); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expected ErrCacheMiss for missing entry, got %v", err)
+	}
+
+	_ = c.Put("good", &Entry{StatusCode: 200}, strings.NewReader("hello world"))
+	_ = c.Put("bad", &Entry{StatusCode: 200}, strings.NewReader("goodbye world"))
+	size := c.GetStats().CurrentSize
+
+	if err := c.Check("good", verifier()); err != nil {
+		t.Errorf("expected matching entry to verify, got %v", err)
+	}
+	if err := c.Check("bad", verifier()); err == nil {
+		t.Error("expected mismatching entry to fail verification")
+	}
+
+	if _, err := c.Get("bad"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expected mismatching entry to be removed, got %v", err)
+	}
+	entry, err := c.Get("good")
+	if err != nil {
+		t.Fatalf("expected matching entry to be kept, got %v", err)
+	}
+	entry.Close()
+	if got := c.GetStats().CurrentSize; got >= size {
+		t.Errorf("expected size below %d after removal, got %d", size, got)
+	}
+}
+
+// readBody reads the whole body of a cache entry.
+func readBody(t *testing.T, entry *Entry) string {
+	t.Helper()
+	data, err := io.ReadAll(entry.Body())
+	if err != nil {
+		t.Fatalf("read body: %v", err)
 	}
+	return string(data)
 }
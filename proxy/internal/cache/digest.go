@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1" //#nosec G505 -- npm and Maven still publish sha1 checksums
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Digest algorithms.
+const (
+	algSHA1       = "sha1"
+	algSHA256     = "sha256"
+	algSHA512     = "sha512"
+	algBlake2b256 = "blake2b-256"
+	algGoZipHash  = "h1"        // go.sum hash of a module zip
+	algGoModHash  = "h1 go.mod" // go.sum hash of a go.mod file
+)
+
+// digest is the expected digest of a response body.
+type digest struct {
+	alg    string
+	sum    []byte // the go.sum hash string for the h1 algorithms
+	source string // where the digest came from, for errors
+}
+
+// newHash returns a hash for a streamed algorithm, or nil for those computed
+// from the whole body.
+func newHash(alg string) hash.Hash {
+	switch alg {
+	case algSHA1:
+		return sha1.New() //#nosec G401 -- verifying published checksums
+	case algSHA256:
+		return sha256.New()
+	case algSHA512:
+		return sha512.New()
+	case algBlake2b256:
+		h, _ := blake2b.New256(nil)
+		return h
+	}
+	return nil
+}
+
+// DigestVerifier checks a response body against the digests expected for
+// it. The body is hashed as it is written, so a response can be verified
+// while it streams to the client.
+type DigestVerifier struct {
+	digests []digest
+	hashes  map[string]hash.Hash // streamed hashes by algorithm
+}
+
+func newDigestVerifier(digests []digest) *DigestVerifier {
+	v := &DigestVerifier{digests: digests, hashes: make(map[string]hash.Hash)}
+	for _, d := range digests {
+		if h := newHash(d.alg); h != nil {
+			v.hashes[d.alg] = h
+		}
+	}
+	return v
+}
+
+// Write adds p to the hashed body.
+func (v *DigestVerifier) Write(p []byte) (int, error) {
+	for _, h := range v.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// Verify returns nil if the body written so far matches every expected
+// digest, and an error describing the first mismatch otherwise. Digests
+// that cannot be streamed, such as the go.sum hash of a zip, are computed
+// from body, which reads the same content back.
+func (v *DigestVerifier) Verify(body *io.SectionReader) error {
+	for _, d := range v.digests {
+		var actual, expected string
+		if h := v.hashes[d.alg]; h != nil {
+			actual, expected = hex.EncodeToString(h.Sum(nil)), hex.EncodeToString(d.sum)
+		} else {
+			var err error
+			if actual, err = goHash(d.alg, body); err != nil {
+				return fmt.Errorf("%s: %w", d.alg, err)
+			}
+			expected = string(d.sum)
+		}
+		if actual != expected {
+			return fmt.Errorf("%s mismatch: %s claims %s, body hashes to %s", d.alg, d.source, expected, actual)
+		}
+	}
+	return nil
+}
+
+// goHash computes the go.sum hash ("h1:") of a module zip or go.mod file:
+// the base64 sha256 of a summary listing the sha256 of each file.
+func goHash(alg string, body *io.SectionReader) (string, error) {
+	if body == nil {
+		return "", fmt.Errorf("body not available")
+	}
+
+	files := make(map[string]func() (io.ReadCloser, error))
+	switch alg {
+	case algGoModHash:
+		files["go.mod"] = func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(body, 0, body.Size())), nil
+		}
+	case algGoZipHash:
+		z, err := zip.NewReader(body, body.Size())
+		if err != nil {
+			return "", err
+		}
+		for _, f := range z.File {
+			files[f.Name] = f.Open
+		}
+	default:
+		return "", fmt.Errorf("unknown digest algorithm")
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if strings.Contains(name, "\n") {
+			return "", fmt.Errorf("file name %q contains a newline", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var summary bytes.Buffer
+	for _, name := range names {
+		r, err := files[name]()
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	sum := sha256.Sum256(summary.Bytes())
+	return "h1:" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
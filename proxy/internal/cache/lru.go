@@ -62,6 +62,14 @@ func (idx *lruIndex) exists(key string) bool {
 	return exists
 }
 
+// sizeOf returns the size of an item and whether it exists.
+func (idx *lruIndex) sizeOf(key string) (int64, bool) {
+	if item, exists := idx.items[key]; exists {
+		return item.size, true
+	}
+	return 0, false
+}
+
 // remove removes an item from the index.
 func (idx *lruIndex) remove(key string) {
 	if item, exists := idx.items[key]; exists {
@@ -1,11 +1,20 @@
 package cache
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // dockerMediaTypePrefixes are the OCI/Docker vendor media type prefixes.
@@ -18,13 +27,21 @@ var dockerMediaTypePrefixes = []string{
 
 // sha256DigestRe extracts a sha256 hex digest from a URL path in either format:
 //   - OCI standard:          "sha256:HEX64"       (e.g. ghcr.io, docker.io)
-//   - Registry storage path: "/HEX64/"            (e.g. Cloudflare R2 /registry-v2/.../sha256/ab/HEX64/data)
-var sha256DigestRe = regexp.MustCompile(`sha256:([a-fA-F0-9]{64})|/([a-fA-F0-9]{64})/`)
+//   - Storage path component: "/HEX64/" or a trailing "/HEX64"
+//     (e.g. Cloudflare R2 /registry-v2/.../sha256/ab/HEX64/data, Git LFS /objects/HEX64)
+var sha256DigestRe = regexp.MustCompile(`sha256:([a-fA-F0-9]{64})|/([a-fA-F0-9]{64})(?:/|$)`)
+
+// maxLearnedDigests bounds the package file digests a Matcher remembers.
+const maxLearnedDigests = 100000
 
 // Matcher determines if a request should be cached.
 type Matcher struct {
 	patterns     []*regexp.Regexp
-	contentAware bool // detect Docker/OCI CAS blobs by URL digest + request/response headers
+	contentAware bool       // detect Docker/OCI CAS blobs by URL digest + request/response headers
+	profiles     []*profile // language package registries
+
+	mu      sync.Mutex
+	learned map[string][]digest // expected digests of package files by cache key
 }
 
 // NewMatcher creates a new cache matcher.
@@ -37,10 +54,23 @@ type Matcher struct {
 // is cached when either check passes. This lets callers cover CDN backends (e.g.
 // Cloudflare R2 registry storage) whose URLs don't carry standard OCI Accept
 // headers while still using content-aware detection for normal registry traffic.
-func NewMatcher(patterns []string, contentAware bool) (*Matcher, error) {
+//
+// Each named profile ("npm", "pypi", "go" or "maven") caches the package
+// files of a language package registry and revalidates its metadata; the
+// profile decides for every request to the registry's hosts.
+func NewMatcher(patterns []string, contentAware bool, profileNames ...string) (*Matcher, error) {
 	m := &Matcher{
 		contentAware: contentAware,
 		patterns:     make([]*regexp.Regexp, 0, len(patterns)),
+		learned:      make(map[string][]digest),
+	}
+
+	for _, name := range profileNames {
+		p, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cache profile %q (want one of %s)", name, strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
+		}
+		m.profiles = append(m.profiles, p)
 	}
 
 	for _, pattern := range patterns {
@@ -63,6 +93,11 @@ func (m *Matcher) ShouldCache(req *http.Request) bool {
 
 	path := req.URL.Path
 
+	// Registry profiles decide for their hosts
+	if m.profileFor(req.URL) != nil {
+		return m.classify(req.URL) != classNone
+	}
+
 	// Content-aware: URL must contain a sha256 digest and request must carry
 	// Docker/OCI Accept headers confirming this is registry content.
 	// Query params are permitted here — they are auth tokens (e.g. signed URLs)
@@ -94,7 +129,8 @@ func (m *Matcher) ShouldCache(req *http.Request) bool {
 
 // ShouldCacheResponse determines if a response should be cached.
 func (m *Matcher) ShouldCacheResponse(resp *http.Response) bool {
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+	// Partial content would be cached as if it were the whole body
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.StatusCode == http.StatusPartialContent {
 		return false
 	}
 
@@ -103,6 +139,21 @@ func (m *Matcher) ShouldCacheResponse(resp *http.Response) bool {
 		return false
 	}
 
+	// Registry profiles cache complete responses only, package files only
+	// unencoded so their digests can be checked, and metadata only when it
+	// can be revalidated
+	if req := resp.Request; req != nil && m.profileFor(req.URL) != nil {
+		switch m.classify(req.URL) {
+		case classImmutable:
+			encoding := resp.Header.Get("Content-Encoding")
+			return resp.StatusCode == http.StatusOK && (encoding == "" || strings.EqualFold(encoding, "identity"))
+		case classMetadata:
+			return resp.StatusCode == http.StatusOK &&
+				(resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "")
+		}
+		return false
+	}
+
 	if m.contentAware {
 		return isDockerResponse(resp)
 	}
@@ -145,19 +196,147 @@ func isDockerResponse(resp *http.Response) bool {
 	return strings.HasPrefix(ct, "application/octet-stream")
 }
 
-// GenerateKey generates a cache key from a request.
+// GenerateKey generates a cache key from a request. Registry metadata can
+// be served in several formats and encodings, so its key includes the
+// request's Accept and Accept-Encoding headers.
 func (m *Matcher) GenerateKey(req *http.Request) string {
-	return req.URL.Host + req.URL.Path
+	key := req.URL.Host + req.URL.Path
+	if m.classify(req.URL) == classMetadata {
+		key += "#" + req.Header.Get("Accept") + "#" + req.Header.Get("Accept-Encoding")
+	}
+	return key
+}
+
+// ShouldRevalidate reports whether a cached response for req must be
+// revalidated with upstream before it is served, as registry metadata
+// changes whenever a package is published.
+func (m *Matcher) ShouldRevalidate(req *http.Request) bool {
+	return m.classify(req.URL) == classMetadata
 }
 
-// VerifyDigest checks that body's sha256 hash matches the digest embedded in the
-// URL path. Recognises two formats:
+// VerifyDigest returns a DigestVerifier that checks the sha256 hash of the
+// body written to it against the digest embedded in the URL path. Recognises
+// two formats:
 //   - OCI standard:          "sha256:HEX64"   (e.g. /v2/…/blobs/sha256:abc…)
-//   - Registry storage path: "/HEX64/"        (e.g. /registry-v2/…/sha256/ab/HEX64/data)
+//   - Storage path component: "/HEX64/" or a trailing "/HEX64"
+//     (e.g. /registry-v2/…/sha256/ab/HEX64/data, Git LFS /objects/HEX64)
 //
-// Returns nil if no digest is found in the path or the digest matches.
-// Returns an error describing the mismatch otherwise.
-func (m *Matcher) VerifyDigest(path string, body []byte) error {
+// Paths without a digest verify any body.
+func (m *Matcher) VerifyDigest(path string) *DigestVerifier {
+	var digests []digest
+	if d := urlSHA256(path); d != nil {
+		digests = append(digests, *d)
+	}
+	return newDigestVerifier(digests)
+}
+
+// Verifier returns a DigestVerifier for the response to req. Besides a
+// sha256 digest in the URL path, it checks the digests registry profiles
+// know for the URL: embedded in it (PyPI) or learned from metadata and
+// checksum responses (npm, Go, Maven).
+func (m *Matcher) Verifier(req *http.Request) *DigestVerifier {
+	var digests []digest
+	if d := urlSHA256(req.URL.Path); d != nil {
+		digests = append(digests, *d)
+	}
+	if p := m.profileFor(req.URL); p != nil && p.urlDigest != nil {
+		if d := p.urlDigest(req.URL.Path); d != nil {
+			digests = append(digests, *d)
+		}
+	}
+
+	m.mu.Lock()
+	digests = append(digests, m.learned[m.GenerateKey(req)]...)
+	m.mu.Unlock()
+
+	return newDigestVerifier(digests)
+}
+
+// LearnedVerifier returns a DigestVerifier for the digests learned for a
+// cache key, or nil if there are none.
+func (m *Matcher) LearnedVerifier(key string) *DigestVerifier {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	digests := m.learned[key]
+	if len(digests) == 0 {
+		return nil
+	}
+	return newDigestVerifier(digests)
+}
+
+// Learns reports whether the response to req lists the digests of package
+// files, such as an npm package document, a Go checksum database lookup or
+// a Maven checksum file. Its body should be passed to Learn.
+func (m *Matcher) Learns(req *http.Request) bool {
+	p := m.profileFor(req.URL)
+	return p != nil && p.learns != nil && p.learns(req.URL.Hostname(), req.URL.Path)
+}
+
+// Learn records the package file digests listed in body, the response to
+// req with the given headers, for verifying those files. It returns the
+// cache keys of the files whose digests it learned.
+func (m *Matcher) Learn(req *http.Request, header http.Header, body []byte) []string {
+	p := m.profileFor(req.URL)
+	if p == nil || p.learn == nil {
+		return nil
+	}
+	body, err := decodeBody(header.Get("Content-Encoding"), body)
+	if err != nil {
+		return nil
+	}
+
+	learned := make(map[string][]digest)
+	for _, ld := range p.learn(req.URL.Hostname(), req.URL.Path, body) {
+		// Package files are fetched the way the listing was, so they share
+		// its port in the cache key
+		host := ld.host
+		if port := req.URL.Port(); port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		key := host + ld.path
+		learned[key] = append(learned[key], ld.digest)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.learned)+len(learned) > maxLearnedDigests {
+		// Forget everything rather than tracking recency; the digests are
+		// learned again with the next metadata request
+		m.learned = make(map[string][]digest)
+	}
+	keys := make([]string, 0, len(learned))
+	for key, digests := range learned {
+		m.learned[key] = digests
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// profileFor returns the registry profile for a URL's host, if enabled.
+func (m *Matcher) profileFor(u *url.URL) *profile {
+	host := u.Hostname()
+	for _, p := range m.profiles {
+		if slices.Contains(p.hosts, host) {
+			return p
+		}
+	}
+	return nil
+}
+
+// classify returns how the registry profile for a URL caches it.
+func (m *Matcher) classify(u *url.URL) urlClass {
+	p := m.profileFor(u)
+	if p == nil || u.RawQuery != "" {
+		return classNone
+	}
+	return p.classify(u.Hostname(), u.Path)
+}
+
+// urlSHA256 returns the sha256 digest embedded in a URL path, if any.
+func urlSHA256(path string) *digest {
 	matches := sha256DigestRe.FindStringSubmatch(path)
 	if len(matches) < 2 {
 		return nil // no digest in path, nothing to verify
@@ -168,13 +347,30 @@ func (m *Matcher) VerifyDigest(path string, body []byte) error {
 	if expected == "" && len(matches) > 2 {
 		expected = strings.ToLower(matches[2])
 	}
-	if expected == "" {
+	sum, err := hex.DecodeString(expected)
+	if err != nil {
 		return nil
 	}
+	return &digest{alg: algSHA256, sum: sum, source: "URL"}
+}
 
-	actual := fmt.Sprintf("%x", sha256.Sum256(body))
-	if expected != actual {
-		return fmt.Errorf("sha256 mismatch: URL claims %s, body hashes to %s", expected, actual)
+// decodeBody undoes the Content-Encoding of a response body.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(encoding) {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
 	}
-	return nil
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
 }
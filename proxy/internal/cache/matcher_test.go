@@ -359,6 +359,11 @@ func TestMatcher_PatternMode_ShouldCacheResponse(t *testing.T) {
 			statusCode: 500,
 			expected:   false,
 		},
+		{
+			name:       "206 Partial Content - should not cache",
+			statusCode: 206,
+			expected:   false,
+		},
 		{
 			name:         "200 with no-store - should not cache",
 			statusCode:   200,
@@ -463,6 +468,16 @@ func TestMatcher_CombinedMode(t *testing.T) {
 		t.Error("R2 presigned URL with sha256 in path should be cached despite query params")
 	}
 
+	// Git LFS object presigned URL — the oid ends the path.
+	lfsMatcher, err := NewMatcher([]string{`/[0-9a-f]{2}/[0-9a-f]{2}/[0-9a-f]{64}$`}, true)
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+	req6, _ := http.NewRequest("GET", "https://github-cloud.githubusercontent.com/alambic/media/123/49/32/493218ed0f404132311952996fea8ce85e50c49f5a717f26f25c52a25fcb2e56?X-Amz-Signature=abc123", nil)
+	if !lfsMatcher.ShouldCache(req6) {
+		t.Error("LFS presigned URL with the oid in path should be cached despite query params")
+	}
+
 	// OCI blob presigned URL — content-aware with query params should also work.
 	req5, _ := http.NewRequest("GET", "https://ghcr.io/v2/foo/blobs/sha256:abc123def456abc123def456abc123def456abc123def456abc123def456abcd?token=xyz", nil)
 	req5.Header.Set("Accept", dockerAccept)
@@ -479,14 +494,14 @@ func TestMatcher_VerifyDigest(t *testing.T) {
 
 	t.Run("OCI colon format - correct digest", func(t *testing.T) {
 		m, _ := NewMatcher(nil, true)
-		if err := m.VerifyDigest("/v2/foo/blobs/sha256:"+correctDigest, body); err != nil {
+		if err := verifyDigest(m, "/v2/foo/blobs/sha256:"+correctDigest, body); err != nil {
 			t.Errorf("expected no error for correct digest, got: %v", err)
 		}
 	})
 
 	t.Run("OCI colon format - wrong digest", func(t *testing.T) {
 		m, _ := NewMatcher(nil, true)
-		if err := m.VerifyDigest("/v2/foo/blobs/sha256:"+wrongDigest, body); err == nil {
+		if err := verifyDigest(m, "/v2/foo/blobs/sha256:"+wrongDigest, body); err == nil {
 			t.Error("expected error for wrong digest, got nil")
 		}
 	})
@@ -495,7 +510,7 @@ func TestMatcher_VerifyDigest(t *testing.T) {
 		// Cloudflare R2 format: /registry-v2/.../sha256/PREFIX/HEX64/data
 		m, _ := NewMatcher(nil, false)
 		path := "/registry-v2/docker/registry/v2/blobs/sha256/b9/" + correctDigest + "/data"
-		if err := m.VerifyDigest(path, body); err != nil {
+		if err := verifyDigest(m, path, body); err != nil {
 			t.Errorf("expected no error for R2 path with correct digest, got: %v", err)
 		}
 	})
@@ -503,26 +518,70 @@ func TestMatcher_VerifyDigest(t *testing.T) {
 	t.Run("registry storage path format - wrong digest", func(t *testing.T) {
 		m, _ := NewMatcher(nil, false)
 		path := "/registry-v2/docker/registry/v2/blobs/sha256/00/" + wrongDigest + "/data"
-		if err := m.VerifyDigest(path, body); err == nil {
+		if err := verifyDigest(m, path, body); err == nil {
 			t.Error("expected error for R2 path with wrong digest, got nil")
 		}
 	})
 
+	t.Run("Git LFS object path - correct digest", func(t *testing.T) {
+		m, _ := NewMatcher(nil, true)
+		if err := verifyDigest(m, "/org/repo.git/gitlab-lfs/objects/"+correctDigest, body); err != nil {
+			t.Errorf("expected no error for LFS path with correct digest, got: %v", err)
+		}
+	})
+
+	t.Run("Git LFS object path - wrong digest", func(t *testing.T) {
+		m, _ := NewMatcher(nil, true)
+		if err := verifyDigest(m, "/alambic/media/123/00/00/"+wrongDigest, body); err == nil {
+			t.Error("expected error for LFS path with wrong digest, got nil")
+		}
+	})
+
 	t.Run("CDN redirect path with correct digest", func(t *testing.T) {
 		m, _ := NewMatcher(nil, true)
-		if err := m.VerifyDigest("/ghcr1/blobs/sha256:"+correctDigest, body); err != nil {
+		if err := verifyDigest(m, "/ghcr1/blobs/sha256:"+correctDigest, body); err != nil {
 			t.Errorf("expected no error for correct digest in CDN path, got: %v", err)
 		}
 	})
 
 	t.Run("no digest in path skips verification", func(t *testing.T) {
 		m, _ := NewMatcher(nil, true)
-		if err := m.VerifyDigest("/v2/ubuntu/manifests/latest", body); err != nil {
+		if err := verifyDigest(m, "/v2/ubuntu/manifests/latest", body); err != nil {
 			t.Errorf("expected no error when path has no digest, got: %v", err)
 		}
 	})
 }
 
+func TestMatcher_VerifyDigest_Chunked(t *testing.T) {
+	m, _ := NewMatcher(nil, true)
+	path := "/v2/foo/blobs/sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+	v := m.VerifyDigest(path)
+	for _, chunk := range []string{"hello", " ", "world"} {
+		if _, err := v.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := v.Verify(nil); err != nil {
+		t.Errorf("expected no error for body written in chunks, got: %v", err)
+	}
+
+	v = m.VerifyDigest(path)
+	_, _ = v.Write([]byte("hello"))
+	if err := v.Verify(nil); err == nil {
+		t.Error("expected error for truncated body, got nil")
+	}
+}
+
+// verifyDigest verifies body against the digest in path in one write.
+func verifyDigest(m *Matcher, path string, body []byte) error {
+	v := m.VerifyDigest(path)
+	if _, err := v.Write(body); err != nil {
+		return err
+	}
+	return v.Verify(nil)
+}
+
 func TestNewMatcher_ContentAwareParam(t *testing.T) {
 	m1, _ := NewMatcher(nil, true)
 	if !m1.contentAware {
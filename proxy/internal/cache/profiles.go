@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// urlClass is how a registry profile caches a URL.
+type urlClass int
+
+const (
+	// classNone URLs are not cached.
+	classNone urlClass = iota
+	// classImmutable URLs never change once published and are served from
+	// the cache without contacting upstream.
+	classImmutable
+	// classMetadata URLs change as packages are published; cached copies
+	// are revalidated with a conditional request every time.
+	classMetadata
+)
+
+// profile describes a language package registry: which of its URLs are
+// immutable package files and which are metadata, and where the expected
+// digests of package files come from.
+type profile struct {
+	hosts []string
+
+	// classify returns how to cache a path on one of hosts.
+	classify func(host, path string) urlClass
+
+	// urlDigest returns a digest embedded in a package file's path.
+	urlDigest func(path string) *digest
+
+	// learns reports whether responses for a path list digests of package
+	// files, which learn then extracts.
+	learns func(host, path string) bool
+	learn  func(host, path string, body []byte) []learnedDigest
+}
+
+// learnedDigest is the expected digest of a package file found in another
+// response.
+type learnedDigest struct {
+	host, path string
+	digest     digest
+}
+
+// profiles are the built-in registry profiles by name.
+var profiles = map[string]*profile{
+	"npm":   npmProfile,
+	"pypi":  pypiProfile,
+	"go":    goProfile,
+	"maven": mavenProfile,
+}
+
+// npm: tarballs are immutable (a published version can never be replaced)
+// and package documents list the sha512 integrity of each version's
+// tarball.
+var (
+	npmTarballRe   = regexp.MustCompile(`^/(@[^/]+/)?[^/]+/-/[^/]+\.tgz$`)
+	npmPackageRe   = regexp.MustCompile(`^/(@[^/]+/)?[^/-][^/]*$`)
+	npmIntegrityRe = regexp.MustCompile(`^(sha1|sha256|sha512)-([A-Za-z0-9+/=]+)`)
+	npmProfile     = &profile{
+		hosts:    []string{"registry.npmjs.org"},
+		classify: classifyNPM,
+		learns:   learnsNPM,
+		learn:    learnNPM,
+	}
+)
+
+func classifyNPM(_, path string) urlClass {
+	switch {
+	case npmTarballRe.MatchString(path):
+		return classImmutable
+	case npmPackageRe.MatchString(path):
+		return classMetadata
+	}
+	return classNone
+}
+
+func learnsNPM(host, path string) bool {
+	return classifyNPM(host, path) == classMetadata
+}
+
+// learnNPM extracts tarball digests from a package document (full or
+// abbreviated).
+func learnNPM(_, _ string, body []byte) []learnedDigest {
+	var doc struct {
+		Versions map[string]struct {
+			Dist struct {
+				Tarball   string `json:"tarball"`
+				Integrity string `json:"integrity"`
+				Shasum    string `json:"shasum"`
+			} `json:"dist"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil
+	}
+
+	var learned []learnedDigest
+	for _, version := range doc.Versions {
+		u, err := url.Parse(version.Dist.Tarball)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		digests := parseIntegrity(version.Dist.Integrity)
+		if len(digests) == 0 {
+			// Packages published before integrity was added only have a sha1
+			if sum, err := hex.DecodeString(version.Dist.Shasum); err == nil && len(sum) == 20 {
+				digests = []digest{{alg: algSHA1, sum: sum}}
+			}
+		}
+		for _, d := range digests {
+			d.source = "package metadata"
+			learned = append(learned, learnedDigest{host: u.Hostname(), path: u.Path, digest: d})
+		}
+	}
+	return learned
+}
+
+// parseIntegrity parses a Subresource Integrity value such as
+// "sha512-<base64>", which may list several hashes.
+func parseIntegrity(integrity string) []digest {
+	var digests []digest
+	for _, field := range strings.Fields(integrity) {
+		m := npmIntegrityRe.FindStringSubmatch(field)
+		if m == nil {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(m[2])
+		if err != nil {
+			continue
+		}
+		digests = append(digests, digest{alg: m[1], sum: sum})
+	}
+	return digests
+}
+
+// PyPI: files.pythonhosted.org stores every file under its blake2b-256
+// digest, split as /packages/ab/cd/<remaining 60 hex digits>/<filename>.
+// Project pages on pypi.org are metadata.
+var (
+	pypiFileRe    = regexp.MustCompile(`^/packages/([0-9a-f]{2})/([0-9a-f]{2})/([0-9a-f]{60})/[^/]+$`)
+	pypiProjectRe = regexp.MustCompile(`^/(simple/[^/]+/|pypi/[^/]+(/[^/]+)?/json)$`)
+	pypiProfile   = &profile{
+		hosts:     []string{"files.pythonhosted.org", "pypi.org"},
+		classify:  classifyPyPI,
+		urlDigest: pypiDigest,
+	}
+)
+
+func classifyPyPI(host, path string) urlClass {
+	switch {
+	case host == "files.pythonhosted.org" && pypiFileRe.MatchString(path):
+		return classImmutable
+	case host == "pypi.org" && pypiProjectRe.MatchString(path):
+		return classMetadata
+	}
+	return classNone
+}
+
+func pypiDigest(path string) *digest {
+	m := pypiFileRe.FindStringSubmatch(path)
+	if m == nil {
+		return nil
+	}
+	sum, err := hex.DecodeString(m[1] + m[2] + m[3])
+	if err != nil {
+		return nil
+	}
+	return &digest{alg: algBlake2b256, sum: sum, source: "URL"}
+}
+
+// Go: the module proxy serves immutable .info, .mod and .zip files for
+// each released version, and the checksum database lists their go.sum
+// hashes. Version lists and @latest are metadata.
+var (
+	goVersionFileRe = regexp.MustCompile(`^/(.+)/@v/(v[0-9]+\.[0-9]+\.[0-9]+(?:[-+][0-9A-Za-z.!+-]*)?)\.(info|mod|zip)$`)
+	goMetadataRe    = regexp.MustCompile(`^/.+/(@v/list|@latest)$`)
+	goLookupRe      = regexp.MustCompile(`^(/sumdb/sum\.golang\.org)?/lookup/[^@]+@[^/]+$`)
+	goProfile       = &profile{
+		hosts:    []string{"proxy.golang.org", "sum.golang.org"},
+		classify: classifyGo,
+		learns:   learnsGo,
+		learn:    learnGo,
+	}
+)
+
+func classifyGo(host, path string) urlClass {
+	if host != "proxy.golang.org" {
+		return classNone
+	}
+	switch {
+	case goVersionFileRe.MatchString(path):
+		return classImmutable
+	case goMetadataRe.MatchString(path):
+		return classMetadata
+	}
+	return classNone
+}
+
+func learnsGo(_, path string) bool {
+	return goLookupRe.MatchString(path)
+}
+
+// learnGo extracts go.sum hashes from a checksum database lookup, whose
+// records read "<module> <version>[/go.mod] h1:<hash>".
+func learnGo(_, _ string, body []byte) []learnedDigest {
+	var learned []learnedDigest
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || !strings.HasPrefix(fields[2], "h1:") {
+			continue
+		}
+		module, version := escapeModulePath(fields[0]), fields[1]
+		d := digest{alg: algGoZipHash, sum: []byte(fields[2]), source: "checksum database"}
+		ext := ".zip"
+		if v, ok := strings.CutSuffix(version, "/go.mod"); ok {
+			version, ext = v, ".mod"
+			d.alg = algGoModHash
+		}
+		learned = append(learned, learnedDigest{
+			host:   "proxy.golang.org",
+			path:   "/" + module + "/@v/" + escapeModulePath(version) + ext,
+			digest: d,
+		})
+	}
+	return learned
+}
+
+// escapeModulePath escapes a module path or version for the module proxy
+// protocol, which writes upper-case letters as "!" and the lower-case
+// letter.
+func escapeModulePath(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Maven: released artifacts never change and come with .sha1 (and, for
+// newer ones, .sha256 and .sha512) checksum files. maven-metadata.xml
+// lists versions and is metadata.
+var (
+	mavenChecksumExts = map[string]string{".sha1": algSHA1, ".sha256": algSHA256, ".sha512": algSHA512}
+	mavenProfile      = &profile{
+		hosts:    []string{"repo1.maven.org", "repo.maven.apache.org"},
+		classify: classifyMaven,
+		learns:   learnsMaven,
+		learn:    learnMaven,
+	}
+)
+
+func classifyMaven(_, path string) urlClass {
+	rest, ok := strings.CutPrefix(path, "/maven2/")
+	if !ok {
+		return classNone
+	}
+	parts := strings.Split(rest, "/")
+	file := parts[len(parts)-1]
+	if strings.HasPrefix(file, "maven-metadata.xml") {
+		return classMetadata
+	}
+
+	// <group path>/<artifact>/<version>/<artifact>-<version>[-classifier].<ext>
+	if len(parts) < 4 {
+		return classNone
+	}
+	artifact, version := parts[len(parts)-3], parts[len(parts)-2]
+	if strings.HasSuffix(version, "-SNAPSHOT") || !strings.HasPrefix(file, artifact+"-"+version) {
+		return classNone
+	}
+	return classImmutable
+}
+
+func learnsMaven(host, path string) bool {
+	ext := fileExt(path)
+	return mavenChecksumExts[ext] != "" && classifyMaven(host, path) == classImmutable
+}
+
+// learnMaven reads a checksum file, which holds the hex digest of the file
+// it is named after, sometimes followed by the file name.
+func learnMaven(host, path string, body []byte) []learnedDigest {
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return nil
+	}
+	sum, err := hex.DecodeString(strings.ToLower(fields[0]))
+	if err != nil {
+		return nil
+	}
+	ext := fileExt(path)
+	return []learnedDigest{{
+		host:   host,
+		path:   strings.TrimSuffix(path, ext),
+		digest: digest{alg: mavenChecksumExts[ext], sum: sum, source: "checksum file"},
+	}}
+}
+
+// fileExt returns the extension of the last element of a URL path.
+func fileExt(path string) string {
+	if i := strings.LastIndexAny(path, "./"); i >= 0 && path[i] == '.' {
+		return path[i:]
+	}
+	return ""
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Digests of "hello world".
+const (
+	helloSHA512Integrity = "sha512-MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw=="
+	helloSHA1            = "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"
+	helloBlake2b256      = "256c83b297114d201b30179f3f0ef0cace9783622da5974326b436178aeef610"
+)
+
+func newProfileMatcher(t *testing.T) *Matcher {
+	t.Helper()
+	m, err := NewMatcher(nil, true, "npm", "pypi", "go", "maven")
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+	return m
+}
+
+// newRequest creates a GET request the way the MITM proxy sees it, with
+// the port in the URL host.
+func newRequest(t *testing.T, rawURL string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	return req
+}
+
+// verifyBody verifies body with v in one write.
+func verifyBody(v *DigestVerifier, body []byte) error {
+	if _, err := v.Write(body); err != nil {
+		return err
+	}
+	return v.Verify(io.NewSectionReader(bytes.NewReader(body), 0, int64(len(body))))
+}
+
+func TestMatcher_UnknownProfile(t *testing.T) {
+	if _, err := NewMatcher(nil, true, "cargo"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestMatcher_ProfileShouldCache(t *testing.T) {
+	m := newProfileMatcher(t)
+	pypiFile := "https://files.pythonhosted.org:443/packages/25/6c/" + helloBlake2b256[4:] + "/pkg-1.0-py3-none-any.whl"
+
+	tests := []struct {
+		name           string
+		url            string
+		wantCache      bool
+		wantRevalidate bool
+	}{
+		{name: "npm tarball", url: "https://registry.npmjs.org:443/left-pad/-/left-pad-1.3.0.tgz", wantCache: true},
+		{name: "npm scoped tarball", url: "https://registry.npmjs.org:443/@types/node/-/node-20.0.0.tgz", wantCache: true},
+		{name: "npm package document", url: "https://registry.npmjs.org:443/left-pad", wantCache: true, wantRevalidate: true},
+		{name: "npm scoped package document", url: "https://registry.npmjs.org:443/@types%2fnode", wantCache: true, wantRevalidate: true},
+		{name: "npm API", url: "https://registry.npmjs.org:443/-/v1/search"},
+		{name: "npm query", url: "https://registry.npmjs.org:443/left-pad?write=true"},
+		{name: "pypi file", url: pypiFile, wantCache: true},
+		{name: "pypi simple index", url: "https://pypi.org:443/simple/requests/", wantCache: true, wantRevalidate: true},
+		{name: "pypi JSON API", url: "https://pypi.org:443/pypi/requests/2.31.0/json", wantCache: true, wantRevalidate: true},
+		{name: "pypi other page", url: "https://pypi.org:443/project/requests/"},
+		{name: "go module zip", url: "https://proxy.golang.org:443/github.com/!burnt!sushi/toml/@v/v1.3.2.zip", wantCache: true},
+		{name: "go module mod", url: "https://proxy.golang.org:443/golang.org/x/mod/@v/v0.17.0.mod", wantCache: true},
+		{name: "go version list", url: "https://proxy.golang.org:443/golang.org/x/mod/@v/list", wantCache: true, wantRevalidate: true},
+		{name: "go latest", url: "https://proxy.golang.org:443/golang.org/x/mod/@latest", wantCache: true, wantRevalidate: true},
+		{name: "go non-canonical version", url: "https://proxy.golang.org:443/golang.org/x/mod/@v/master.info"},
+		{name: "go checksum database", url: "https://sum.golang.org:443/lookup/golang.org/x/mod@v0.17.0"},
+		{name: "maven jar", url: "https://repo1.maven.org:443/maven2/com/google/guava/guava/33.0.0-jre/guava-33.0.0-jre.jar", wantCache: true},
+		{name: "maven checksum", url: "https://repo1.maven.org:443/maven2/com/google/guava/guava/33.0.0-jre/guava-33.0.0-jre.jar.sha1", wantCache: true},
+		{name: "maven metadata", url: "https://repo1.maven.org:443/maven2/com/google/guava/guava/maven-metadata.xml", wantCache: true, wantRevalidate: true},
+		{name: "maven snapshot", url: "https://repo1.maven.org:443/maven2/com/example/lib/1.0-SNAPSHOT/lib-1.0-20240101.120000-1.jar"},
+		{name: "other host", url: "https://example.com:443/left-pad/-/left-pad-1.3.0.tgz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, tt.url)
+			if got := m.ShouldCache(req); got != tt.wantCache {
+				t.Errorf("ShouldCache = %v, want %v", got, tt.wantCache)
+			}
+			if got := m.ShouldRevalidate(req); got != tt.wantRevalidate {
+				t.Errorf("ShouldRevalidate = %v, want %v", got, tt.wantRevalidate)
+			}
+		})
+	}
+
+	t.Run("disabled profile", func(t *testing.T) {
+		npmOnly, err := NewMatcher(nil, true, "npm")
+		if err != nil {
+			t.Fatalf("NewMatcher failed: %v", err)
+		}
+		if npmOnly.ShouldCache(newRequest(t, pypiFile)) {
+			t.Error("expected pypi file not to be cached without the pypi profile")
+		}
+	})
+}
+
+func TestMatcher_ProfileShouldCacheResponse(t *testing.T) {
+	m := newProfileMatcher(t)
+	tarball := "https://registry.npmjs.org:443/left-pad/-/left-pad-1.3.0.tgz"
+	document := "https://registry.npmjs.org:443/left-pad"
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		header http.Header
+		want   bool
+	}{
+		{name: "package file", url: tarball, status: 200, want: true},
+		{name: "partial package file", url: tarball, status: 206},
+		{name: "encoded package file", url: tarball, status: 200, header: http.Header{"Content-Encoding": {"gzip"}}},
+		{name: "metadata with ETag", url: document, status: 200, header: http.Header{"Etag": {`"abc"`}}, want: true},
+		{name: "metadata with Last-Modified", url: document, status: 200, header: http.Header{"Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"}}, want: true},
+		{name: "metadata without validators", url: document, status: 200},
+		{name: "no-store", url: document, status: 200, header: http.Header{"Etag": {`"abc"`}, "Cache-Control": {"no-store"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			resp := &http.Response{StatusCode: tt.status, Header: header, Request: newRequest(t, tt.url)}
+			if got := m.ShouldCacheResponse(resp); got != tt.want {
+				t.Errorf("ShouldCacheResponse = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_ProfileGenerateKey(t *testing.T) {
+	m := newProfileMatcher(t)
+
+	tarball := newRequest(t, "https://registry.npmjs.org:443/left-pad/-/left-pad-1.3.0.tgz")
+	tarball.Header.Set("Accept-Encoding", "gzip")
+	if got, want := m.GenerateKey(tarball), "registry.npmjs.org:443/left-pad/-/left-pad-1.3.0.tgz"; got != want {
+		t.Errorf("package file key: got %q, want %q", got, want)
+	}
+
+	full := newRequest(t, "https://registry.npmjs.org:443/left-pad")
+	full.Header.Set("Accept", "application/json")
+	abbreviated := newRequest(t, "https://registry.npmjs.org:443/left-pad")
+	abbreviated.Header.Set("Accept", "application/vnd.npm.install-v1+json")
+	if m.GenerateKey(full) == m.GenerateKey(abbreviated) {
+		t.Error("expected metadata in different formats to have different keys")
+	}
+}
+
+func TestMatcher_LearnNPM(t *testing.T) {
+	m := newProfileMatcher(t)
+	document := `{"name":"left-pad","versions":{
+		"1.3.0":{"dist":{"tarball":"https://registry.npmjs.org/left-pad/-/left-pad-1.3.0.tgz","integrity":"` + helloSHA512Integrity + `"}},
+		"1.0.0":{"dist":{"tarball":"https://registry.npmjs.org/left-pad/-/left-pad-1.0.0.tgz","shasum":"` + helloSHA1 + `"}}
+	}}`
+
+	req := newRequest(t, "https://registry.npmjs.org:443/left-pad")
+	if !m.Learns(req) {
+		t.Fatal("expected package document to be learned from")
+	}
+
+	// Documents are usually served compressed
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(document))
+	gz.Close()
+	keys := m.Learn(req, http.Header{"Content-Encoding": {"gzip"}}, compressed.Bytes())
+
+	want := []string{
+		"registry.npmjs.org:443/left-pad/-/left-pad-1.0.0.tgz",
+		"registry.npmjs.org:443/left-pad/-/left-pad-1.3.0.tgz",
+	}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("learned keys: got %v, want %v", keys, want)
+	}
+
+	for _, version := range []string{"1.3.0", "1.0.0"} {
+		tarball := newRequest(t, "https://registry.npmjs.org:443/left-pad/-/left-pad-"+version+".tgz")
+		if err := verifyBody(m.Verifier(tarball), []byte("hello world")); err != nil {
+			t.Errorf("%s: expected matching tarball to verify, got %v", version, err)
+		}
+		if err := verifyBody(m.Verifier(tarball), []byte("tampered")); err == nil {
+			t.Errorf("%s: expected tampered tarball to fail verification", version)
+		}
+	}
+
+	if v := m.LearnedVerifier("registry.npmjs.org:443/other/-/other-1.0.0.tgz"); v != nil {
+		t.Error("expected no verifier for an unknown tarball")
+	}
+}
+
+func TestMatcher_PyPIDigest(t *testing.T) {
+	m := newProfileMatcher(t)
+	req := newRequest(t, "https://files.pythonhosted.org:443/packages/25/6c/"+helloBlake2b256[4:]+"/pkg-1.0.tar.gz")
+
+	if err := verifyBody(m.Verifier(req), []byte("hello world")); err != nil {
+		t.Errorf("expected matching file to verify, got %v", err)
+	}
+	err := verifyBody(m.Verifier(req), []byte("tampered"))
+	if err == nil || !strings.Contains(err.Error(), "blake2b-256 mismatch") {
+		t.Errorf("expected blake2b-256 mismatch, got %v", err)
+	}
+}
+
+func TestMatcher_LearnGo(t *testing.T) {
+	m := newProfileMatcher(t)
+	goMod := "module example.com/Mod\n"
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for name, content := range map[string]string{
+		"example.com/Mod@v1.0.0/go.mod": goMod,
+		"example.com/Mod@v1.0.0/mod.go": "package mod\n",
+	} {
+		f, _ := zw.Create(name)
+		_, _ = f.Write([]byte(content))
+	}
+	zw.Close()
+
+	// Checksum database lookups are fetched through the module proxy
+	req := newRequest(t, "https://proxy.golang.org:443/sumdb/sum.golang.org/lookup/example.com/Mod@v1.0.0")
+	if !m.Learns(req) {
+		t.Fatal("expected checksum database lookup to be learned from")
+	}
+	lookup := "12345\n" +
+		"example.com/Mod v1.0.0 h1:L1L1hJ0cB2D87FZoNuYfK/g+dAl9BIlR3WRry63MIps=\n" +
+		"example.com/Mod v1.0.0/go.mod h1:LzdX3hYFPELWWa2OxTgCzKEFQRvEnmZZIvDgIwdNE+s=\n" +
+		"\ngo.sum database tree\n"
+	keys := m.Learn(req, http.Header{}, []byte(lookup))
+
+	want := []string{
+		"proxy.golang.org:443/example.com/!mod/@v/v1.0.0.mod",
+		"proxy.golang.org:443/example.com/!mod/@v/v1.0.0.zip",
+	}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("learned keys: got %v, want %v", keys, want)
+	}
+
+	zipReq := newRequest(t, "https://proxy.golang.org:443/example.com/!mod/@v/v1.0.0.zip")
+	if err := verifyBody(m.Verifier(zipReq), zipped.Bytes()); err != nil {
+		t.Errorf("expected matching zip to verify, got %v", err)
+	}
+	modReq := newRequest(t, "https://proxy.golang.org:443/example.com/!mod/@v/v1.0.0.mod")
+	if err := verifyBody(m.Verifier(modReq), []byte(goMod)); err != nil {
+		t.Errorf("expected matching go.mod to verify, got %v", err)
+	}
+	if err := verifyBody(m.Verifier(modReq), []byte("module example.com/Evil\n")); err == nil {
+		t.Error("expected tampered go.mod to fail verification")
+	}
+}
+
+func TestMatcher_LearnMaven(t *testing.T) {
+	m := newProfileMatcher(t)
+	jar := "https://repo1.maven.org:443/maven2/com/example/lib/1.0/lib-1.0.jar"
+
+	req := newRequest(t, jar+".sha1")
+	if !m.Learns(req) {
+		t.Fatal("expected checksum file to be learned from")
+	}
+	keys := m.Learn(req, http.Header{}, []byte(strings.ToUpper(helloSHA1)+"  lib-1.0.jar\n"))
+	if len(keys) != 1 || keys[0] != "repo1.maven.org:443/maven2/com/example/lib/1.0/lib-1.0.jar" {
+		t.Fatalf("unexpected learned keys: %v", keys)
+	}
+
+	if err := verifyBody(m.Verifier(newRequest(t, jar)), []byte("hello world")); err != nil {
+		t.Errorf("expected matching jar to verify, got %v", err)
+	}
+	if err := verifyBody(m.Verifier(newRequest(t, jar)), []byte("tampered")); err == nil {
+		t.Error("expected tampered jar to fail verification")
+	}
+}
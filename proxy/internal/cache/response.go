@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaptureResponse caches resp while the client downloads it: resp.Body is
+// replaced by a reader that tees what the client reads into w and
+// verifier. Once the body has been read to the end and the digest
+// verifies, the entry is committed; if the download fails, the client goes
+// away early or the digest does not match, it is discarded. done is called
+// once with the outcome.
+//
+// The client receives the body as it arrives from upstream, so a body that
+// fails verification has already been sent; it is just not cached.
+func CaptureResponse(resp *http.Response, w *Writer, verifier *DigestVerifier, done func(size int64, err error)) {
+	resp.Body = &teeBody{
+		body:     resp.Body,
+		writer:   w,
+		verifier: verifier,
+		done:     done,
+	}
+}
+
+// teeBody is a response body that copies what is read from it into a cache
+// entry.
+type teeBody struct {
+	body     io.ReadCloser
+	writer   *Writer
+	verifier *DigestVerifier
+	done     func(size int64, err error)
+	finished bool
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.body.Read(p)
+	if n > 0 && !t.finished {
+		// A failing cache write must not break the download
+		if _, werr := t.writer.Write(p[:n]); werr != nil {
+			t.finish(0, werr)
+		} else {
+			_, _ = t.verifier.Write(p[:n])
+		}
+	}
+	switch {
+	case err == io.EOF:
+		t.commit()
+	case err != nil:
+		t.finish(0, fmt.Errorf("read response body: %w", err))
+	}
+	return n, err
+}
+
+func (t *teeBody) Close() error {
+	t.finish(0, errors.New("response body closed before it was fully read"))
+	return t.body.Close()
+}
+
+// commit verifies and commits the entry once the whole body was read.
+func (t *teeBody) commit() {
+	if t.finished {
+		return
+	}
+	if err := t.verifier.Verify(t.writer.Body()); err != nil {
+		t.finish(0, fmt.Errorf("digest verification failed: %w", err))
+		return
+	}
+	t.finished = true
+	t.done(t.writer.Commit())
+}
+
+// finish discards the entry unless it was already committed or discarded.
+func (t *teeBody) finish(size int64, err error) {
+	if t.finished {
+		return
+	}
+	t.finished = true
+	t.writer.Abort()
+	t.done(size, err)
+}
+
+// ObserveResponse replaces resp.Body with a reader that keeps a copy of what
+// the client reads, and calls fn with it once the whole body has been read.
+// fn is not called for bodies larger than limit or not read to the end.
+func ObserveResponse(resp *http.Response, limit int, fn func(body []byte)) {
+	resp.Body = &observedBody{body: resp.Body, limit: limit, fn: fn}
+}
+
+// observedBody is a response body that keeps a copy of what is read.
+type observedBody struct {
+	body  io.ReadCloser
+	buf   bytes.Buffer
+	limit int
+	fn    func(body []byte)
+	done  bool
+}
+
+func (o *observedBody) Read(p []byte) (int, error) {
+	n, err := o.body.Read(p)
+	if !o.done {
+		if o.buf.Len()+n > o.limit {
+			o.done = true
+			o.buf = bytes.Buffer{}
+		} else {
+			o.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !o.done {
+		o.done = true
+		o.fn(o.buf.Bytes())
+	}
+	return n, err
+}
+
+func (o *observedBody) Close() error {
+	return o.body.Close()
+}
+
+// SetConditionalHeaders turns req into a conditional request revalidating
+// a cached entry with its ETag and Last-Modified validators. It returns
+// false, leaving req unchanged, if the entry has no validators or req is
+// already conditional, in which case the client handles the answer itself.
+func SetConditionalHeaders(req *http.Request, entry *Entry) bool {
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	etag, lastModified := entry.Headers.Get("ETag"), entry.Headers.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return false
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return true
+}
+
+// RestoreResponse creates an HTTP response from a cache entry that streams
+// the body from the cache file and closes the entry when done. A single
+// byte range requested with a Range header is answered with 206 Partial
+// Content; other Range headers get the whole body.
+func RestoreResponse(entry *Entry, req *http.Request) *http.Response {
+	resp := &http.Response{
+		StatusCode:    entry.StatusCode,
+		Header:        entry.Headers.Clone(),
+		Body:          entryBody{Reader: entry.Body(), entry: entry},
+		ContentLength: entry.Size,
+		Request:       req,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+	}
+	resp.Header.Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+
+	// Add cache header
+	resp.Header.Set("X-Cache", "HIT")
+	resp.Header.Set("X-Cache-Date", entry.CachedAt.Format(time.RFC3339))
+
+	if entry.StatusCode != http.StatusOK {
+		return resp
+	}
+	resp.Header.Set("Accept-Ranges", "bytes")
+
+	rangeHeader := req.Header.Get("Range")
+	if rangeHeader == "" || !ifRangeMatches(req, entry) {
+		return resp
+	}
+	start, end, ok := parseRange(rangeHeader, entry.Size)
+	if !ok {
+		return resp
+	}
+	if start < 0 {
+		resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", entry.Size))
+		resp.Header.Set("Content-Length", "0")
+		resp.ContentLength = 0
+		resp.Body = entryBody{Reader: strings.NewReader(""), entry: entry}
+		return resp
+	}
+
+	length := end - start + 1
+	resp.StatusCode = http.StatusPartialContent
+	resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, entry.Size))
+	resp.Header.Set("Content-Length", strconv.FormatInt(length, 10))
+	resp.ContentLength = length
+	resp.Body = entryBody{Reader: io.NewSectionReader(entry.Body(), start, length), entry: entry}
+	return resp
+}
+
+// entryBody is a response body read from a cache entry.
+type entryBody struct {
+	io.Reader
+	entry *Entry
+}
+
+func (b entryBody) Close() error {
+	return b.entry.Close()
+}
+
+// ifRangeMatches reports whether a Range header applies given the request's
+// If-Range header: a range of a changed representation gets the whole body.
+func ifRangeMatches(req *http.Request, entry *Entry) bool {
+	ifRange := req.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		// Weak validators never match (RFC 9110, section 13.1.5)
+		etag := entry.Headers.Get("ETag")
+		return etag != "" && !strings.HasPrefix(etag, "W/") && etag == ifRange
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(entry.Headers.Get("Last-Modified"))
+	return err == nil && !modified.After(t)
+}
+
+// parseRange parses a Range header with a single byte range for a body of
+// the given size. ok is false for headers it does not handle, which are
+// ignored; an unsatisfiable range gives a negative start.
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		if n == 0 || size == 0 {
+			return -1, -1, true
+		}
+		return max(size-n, 0), size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return -1, -1, true
+	}
+	return start, end, true
+}
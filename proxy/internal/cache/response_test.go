@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// helloDigest is the sha256 digest of "hello world".
+const helloDigest = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+// chunkedReader returns its data a few bytes per Read, like a network body.
+type chunkedReader struct {
+	data   string
+	closed bool
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), 3)], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *chunkedReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// captureOutcome records the outcome reported by CaptureResponse.
+type captureOutcome struct {
+	calls int
+	size  int64
+	err   error
+}
+
+func (o *captureOutcome) done(size int64, err error) {
+	o.calls++
+	o.size, o.err = size, err
+}
+
+func newCapture(t *testing.T, c *Cache, path, body string) (*http.Response, *chunkedReader, *captureOutcome) {
+	t.Helper()
+
+	upstream := &chunkedReader{data: body}
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/octet-stream"}},
+		Body:       upstream,
+	}
+	w, err := c.NewWriter(path, resp.StatusCode, resp.Header)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	m, _ := NewMatcher(nil, true)
+	outcome := &captureOutcome{}
+	CaptureResponse(resp, w, m.VerifyDigest(path), outcome.done)
+	return resp, upstream, outcome
+}
+
+func TestCaptureResponse(t *testing.T) {
+	path := "/v2/foo/blobs/sha256:" + helloDigest
+
+	t.Run("streams and commits the body", func(t *testing.T) {
+		c, _ := New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+		resp, upstream, outcome := newCapture(t, c, path, "hello world")
+
+		// Not cached until the client has read everything
+		buf := make([]byte, 4)
+		if _, err := resp.Body.Read(buf); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if _, err := c.Get(path); err != ErrCacheMiss {
+			t.Errorf("expected cache miss mid-download, got %v", err)
+		}
+
+		rest, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if got := string(buf[:3]) + string(rest); got != "hello world" {
+			t.Errorf("client got %q, want %q", got, "hello world")
+		}
+		resp.Body.Close()
+
+		if !upstream.closed {
+			t.Error("expected upstream body to be closed")
+		}
+		if outcome.calls != 1 || outcome.err != nil || outcome.size != 11 {
+			t.Errorf("unexpected outcome: %+v", outcome)
+		}
+
+		entry, err := c.Get(path)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		defer entry.Close()
+		if body := readBody(t, entry); body != "hello world" {
+			t.Errorf("cached body: got %q, want %q", body, "hello world")
+		}
+	})
+
+	t.Run("discards a body closed early", func(t *testing.T) {
+		c, _ := New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+		resp, _, outcome := newCapture(t, c, path, "hello world")
+
+		_, _ = resp.Body.Read(make([]byte, 4))
+		resp.Body.Close()
+
+		if outcome.calls != 1 || outcome.err == nil {
+			t.Errorf("expected one failed outcome, got %+v", outcome)
+		}
+		if _, err := c.Get(path); err != ErrCacheMiss {
+			t.Errorf("expected cache miss, got %v", err)
+		}
+	})
+
+	t.Run("discards a body failing digest verification", func(t *testing.T) {
+		c, _ := New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+		resp, _, outcome := newCapture(t, c, path, "goodbye world")
+
+		// The client still receives the body
+		body, err := io.ReadAll(resp.Body)
+		if err != nil || string(body) != "goodbye world" {
+			t.Fatalf("ReadAll = %q, %v", body, err)
+		}
+		resp.Body.Close()
+
+		if outcome.calls != 1 || outcome.err == nil || !strings.Contains(outcome.err.Error(), "sha256 mismatch") {
+			t.Errorf("expected digest mismatch, got %+v", outcome)
+		}
+		if _, err := c.Get(path); err != ErrCacheMiss {
+			t.Errorf("expected cache miss, got %v", err)
+		}
+	})
+
+	t.Run("keeps streaming a body too large to cache", func(t *testing.T) {
+		c, _ := New(t.TempDir(), 64, true, zap.NewNop())
+		body := strings.Repeat("x", 200)
+		resp, _, outcome := newCapture(t, c, "/big", body)
+
+		got, err := io.ReadAll(resp.Body)
+		if err != nil || string(got) != body {
+			t.Fatalf("ReadAll = %d bytes, %v", len(got), err)
+		}
+		resp.Body.Close()
+
+		if outcome.calls != 1 || !errors.Is(outcome.err, ErrTooLarge) {
+			t.Errorf("expected ErrTooLarge, got %+v", outcome)
+		}
+	})
+}
+
+func TestRestoreResponse(t *testing.T) {
+	c, err := New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	headers := http.Header{
+		"Content-Type": []string{"text/plain"},
+		"Etag":         []string{`"v1"`},
+	}
+	if err := c.Put("key", &Entry{StatusCode: 200, Headers: headers}, strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+		wantRange  string
+		wantLength string
+	}{
+		{name: "whole body", wantStatus: 200, wantBody: "0123456789", wantLength: "10"},
+		{name: "range", header: http.Header{"Range": {"bytes=2-5"}}, wantStatus: 206, wantBody: "2345", wantRange: "bytes 2-5/10", wantLength: "4"},
+		{name: "open-ended range", header: http.Header{"Range": {"bytes=7-"}}, wantStatus: 206, wantBody: "789", wantRange: "bytes 7-9/10", wantLength: "3"},
+		{name: "suffix range", header: http.Header{"Range": {"bytes=-3"}}, wantStatus: 206, wantBody: "789", wantRange: "bytes 7-9/10", wantLength: "3"},
+		{name: "range past the end", header: http.Header{"Range": {"bytes=8-20"}}, wantStatus: 206, wantBody: "89", wantRange: "bytes 8-9/10", wantLength: "2"},
+		{name: "unsatisfiable range", header: http.Header{"Range": {"bytes=10-"}}, wantStatus: 416, wantRange: "bytes */10", wantLength: "0"},
+		{name: "multiple ranges get whole body", header: http.Header{"Range": {"bytes=0-1,4-5"}}, wantStatus: 200, wantBody: "0123456789", wantLength: "10"},
+		{name: "matching If-Range", header: http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v1"`}}, wantStatus: 206, wantBody: "01", wantRange: "bytes 0-1/10", wantLength: "2"},
+		{name: "stale If-Range gets whole body", header: http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"v0"`}}, wantStatus: 200, wantBody: "0123456789", wantLength: "10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := c.Get("key")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			req, _ := http.NewRequest("GET", "http://example.com/blob", nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			resp := RestoreResponse(entry, req)
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status: got %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body: got %q, want %q", body, tt.wantBody)
+			}
+			if got := resp.Header.Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range: got %q, want %q", got, tt.wantRange)
+			}
+			if got := resp.Header.Get("Content-Length"); got != tt.wantLength {
+				t.Errorf("Content-Length: got %q, want %q", got, tt.wantLength)
+			}
+			if resp.Header.Get("X-Cache") != "HIT" {
+				t.Error("expected X-Cache: HIT header")
+			}
+		})
+	}
+}
+
+func TestObserveResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		closeEarly bool
+		want       string
+		wantCalls  int
+	}{
+		{name: "whole body", limit: 64, want: "hello world", wantCalls: 1},
+		{name: "body over limit", limit: 4},
+		{name: "body closed early", limit: 64, closeEarly: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Body: &chunkedReader{data: "hello world"}}
+			var calls int
+			var observed string
+			ObserveResponse(resp, tt.limit, func(body []byte) {
+				calls++
+				observed = string(body)
+			})
+
+			if tt.closeEarly {
+				_, _ = resp.Body.Read(make([]byte, 4))
+			} else {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil || string(body) != "hello world" {
+					t.Fatalf("ReadAll = %q, %v", body, err)
+				}
+			}
+			resp.Body.Close()
+
+			if calls != tt.wantCalls || observed != tt.want {
+				t.Errorf("observed %q in %d calls, want %q in %d", observed, calls, tt.want, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSetConditionalHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     http.Header
+		request   http.Header
+		want      bool
+		wantMatch string
+		wantSince string
+	}{
+		{
+			name:      "ETag and Last-Modified",
+			entry:     http.Header{"Etag": {`"v1"`}, "Last-Modified": {"Mon, 01 Jan 2024 00:00:00 GMT"}},
+			want:      true,
+			wantMatch: `"v1"`,
+			wantSince: "Mon, 01 Jan 2024 00:00:00 GMT",
+		},
+		{name: "no validators", entry: http.Header{}},
+		{
+			name:      "conditional request",
+			entry:     http.Header{"Etag": {`"v1"`}},
+			request:   http.Header{"If-None-Match": {`"v0"`}},
+			wantMatch: `"v0"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com/pkg", nil)
+			for k, v := range tt.request {
+				req.Header[k] = v
+			}
+			if got := SetConditionalHeaders(req, &Entry{Headers: tt.entry}); got != tt.want {
+				t.Errorf("SetConditionalHeaders = %v, want %v", got, tt.want)
+			}
+			if got := req.Header.Get("If-None-Match"); got != tt.wantMatch {
+				t.Errorf("If-None-Match: got %q, want %q", got, tt.wantMatch)
+			}
+			if got := req.Header.Get("If-Modified-Since"); got != tt.wantSince {
+				t.Errorf("If-Modified-Since: got %q, want %q", got, tt.wantSince)
+			}
+		})
+	}
+}
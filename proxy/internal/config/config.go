@@ -7,6 +7,8 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,9 +20,12 @@ type Config struct {
 	Proxy     ProxyConfig     `yaml:"proxy" json:"proxy"`
 	TLS       TLSConfig       `yaml:"tls" json:"tls"`
 	Allowlist AllowlistConfig `yaml:"allowlist" json:"allowlist"`
+	Policy    PolicyConfig    `yaml:"policy" json:"policy"`
 	Headers   HeadersConfig   `yaml:"headers" json:"headers"`
+	Secrets   SecretsConfig   `yaml:"secrets" json:"secrets"`
 	Logging   LoggingConfig   `yaml:"logging" json:"logging"`
 	Cache     CacheConfig     `yaml:"cache" json:"cache"`
+	Recorder  RecorderConfig  `yaml:"recorder" json:"recorder"`
 }
 
 // ProxyConfig contains proxy server settings.
@@ -43,6 +48,45 @@ type AllowlistConfig struct {
 	IPs     []string `yaml:"ips" json:"ips"`
 }
 
+// PolicyConfig is an ordered egress policy. The first allow or deny rule a
+// connection or request matches decides it; the default action decides
+// the rest. The policy applies on top of the allowlist.
+type PolicyConfig struct {
+	Default string       `yaml:"default" json:"default"` // allow or deny, empty for allow
+	Rules   []PolicyRule `yaml:"rules" json:"rules"`
+}
+
+// PolicyRule matches connections and requests. Empty fields match anything.
+// Methods and paths can only be checked on HTTP requests, including HTTPS
+// requests the proxy decrypts, so rules using them never match SOCKS
+// connections.
+type PolicyRule struct {
+	Name      string   `yaml:"name,omitempty" json:"name,omitempty"`             // Shown in logs and block responses
+	Action    string   `yaml:"action" json:"action"`                             // allow, deny or log
+	Hosts     []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`           // Domain patterns, IPs or CIDRs
+	Ports     []int    `yaml:"ports,omitempty" json:"ports,omitempty"`           // Destination ports
+	Protocols []string `yaml:"protocols,omitempty" json:"protocols,omitempty"`   // http, connect or socks
+	Methods   []string `yaml:"methods,omitempty" json:"methods,omitempty"`       // HTTP methods
+	Paths     []string `yaml:"paths,omitempty" json:"paths,omitempty"`           // URL path prefixes
+	PathRegex string   `yaml:"path_regex,omitempty" json:"path_regex,omitempty"` // Regular expression the URL path must match
+}
+
+// Policy actions. Log rules only log what they match and let later rules
+// decide.
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+	ActionLog   = "log"
+)
+
+// Protocols policy rules match: HTTP requests, CONNECT tunnels and SOCKS5
+// connections.
+const (
+	ProtocolHTTP    = "http"
+	ProtocolConnect = "connect"
+	ProtocolSOCKS   = "socks"
+)
+
 // HeadersConfig maps domain patterns to header rules.
 type HeadersConfig map[string]HeaderRule
 
@@ -62,6 +106,22 @@ type Condition struct {
 	Equals string `yaml:"equals" json:"equals"`
 }
 
+// SecretsConfig maps placeholder tokens to the secrets they stand for.
+type SecretsConfig map[string]SecretRule
+
+// SecretRule is a secret that replaces its placeholder in the headers of
+// HTTPS requests to its hosts. Requests to other hosts, and plain HTTP
+// requests, keep the placeholder.
+type SecretRule struct {
+	Value   string   `yaml:"value" json:"value"`
+	Hosts   []string `yaml:"hosts" json:"hosts"`                         // Domain patterns the secret may be sent to
+	Headers []string `yaml:"headers,omitempty" json:"headers,omitempty"` // Headers searched, default Authorization and X-Api-Key
+}
+
+// MinPlaceholderLength is the length below which a placeholder could occur
+// in a header by accident.
+const MinPlaceholderLength = 16
+
 // LoggingConfig contains logging settings.
 type LoggingConfig struct {
 	Level       string `yaml:"level" json:"level"`
@@ -77,13 +137,24 @@ type CacheConfig struct {
 	MaxSize      int64    `yaml:"max_size" json:"max_size"`           // In bytes
 	Patterns     []string `yaml:"patterns" json:"patterns"`           // URL patterns to cache
 	ContentAware bool     `yaml:"content_aware" json:"content_aware"` // Detect Docker/OCI CAS blobs by URL digest + headers
+	Profiles     []string `yaml:"profiles" json:"profiles"`           // Built-in registry profiles: npm, pypi, go, maven
+}
+
+// RecorderConfig contains traffic recording settings.
+type RecorderConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	MaxEntries    int      `yaml:"max_entries" json:"max_entries"`       // Requests kept, oldest dropped first
+	MaxBodySize   int      `yaml:"max_body_size" json:"max_body_size"`   // Bytes of each body kept, 0 = no bodies
+	RedactHeaders []string `yaml:"redact_headers" json:"redact_headers"` // Headers redacted besides the built-in ones
 }
 
 // RuntimeConfig is the JSON structure for API updates.
 // It contains only the fields that can be updated at runtime.
 type RuntimeConfig struct {
 	Allowlist *RuntimeAllowlistConfig `json:"allowlist,omitempty"`
+	Policy    *PolicyConfig           `json:"policy,omitempty"` // Replaced as a whole, rule order matters
 	Headers   HeadersConfig           `json:"headers,omitempty"`
+	Secrets   SecretsConfig           `json:"secrets,omitempty"`
 }
 
 // RuntimeAllowlistConfig is the allowlist portion of RuntimeConfig.
@@ -121,6 +192,10 @@ func Default() *Config {
 			MaxSize:  20 * 1024 * 1024 * 1024, // 20GB default
 			Patterns: []string{},
 		},
+		Recorder: RecorderConfig{
+			Enabled:    false,
+			MaxEntries: 1000,
+		},
 	}
 }
 
@@ -166,6 +241,12 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for placeholder, rule := range c.Secrets {
+		if err := rule.Validate(placeholder); err != nil {
+			return fmt.Errorf("invalid secret: %w", err)
+		}
+	}
+
 	// Validate domain patterns in allowlist
 	for _, pattern := range c.Allowlist.Domains {
 		if !IsValidDomainPattern(pattern) {
@@ -183,6 +264,10 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := c.Policy.Validate(); err != nil {
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+
 	// Validate logging level
 	switch c.Logging.Level {
 	case "debug", "info", "warn", "error":
@@ -209,6 +294,14 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate recorder config
+	if c.Recorder.Enabled && c.Recorder.MaxEntries <= 0 {
+		return errors.New("recorder max_entries must be positive when recorder is enabled")
+	}
+	if c.Recorder.MaxBodySize < 0 {
+		return errors.New("recorder max_body_size cannot be negative")
+	}
+
 	return nil
 }
 
@@ -264,6 +357,109 @@ func (r *HeaderRule) Validate() error {
 	return nil
 }
 
+// Validate checks if a SecretRule is valid for a placeholder. Errors never
+// include the secret.
+func (r *SecretRule) Validate(placeholder string) error {
+	if len(placeholder) < MinPlaceholderLength {
+		return fmt.Errorf("placeholder must be at least %d characters", MinPlaceholderLength)
+	}
+	if r.Value == "" {
+		return errors.New("secret value cannot be empty")
+	}
+	if strings.Contains(r.Value, placeholder) {
+		return errors.New("secret value cannot contain its placeholder")
+	}
+	if len(r.Hosts) == 0 {
+		return errors.New("secret hosts cannot be empty")
+	}
+	for _, host := range r.Hosts {
+		if !IsValidDomainPattern(host) {
+			return fmt.Errorf("invalid secret host pattern: %s", host)
+		}
+	}
+	for _, header := range r.Headers {
+		if header == "" {
+			return errors.New("secret header name cannot be empty")
+		}
+	}
+	return nil
+}
+
+// Validate checks if a PolicyConfig is valid.
+func (p *PolicyConfig) Validate() error {
+	switch p.Default {
+	case "", ActionAllow, ActionDeny:
+		// Valid
+	default:
+		return fmt.Errorf("invalid default action: %s", p.Default)
+	}
+	for i, rule := range p.Rules {
+		if err := rule.Validate(); err != nil {
+			if rule.Name != "" {
+				return fmt.Errorf("rule %d (%s): %w", i, rule.Name, err)
+			}
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks if a PolicyRule is valid.
+func (r *PolicyRule) Validate() error {
+	switch r.Action {
+	case ActionAllow, ActionDeny, ActionLog:
+		// Valid
+	default:
+		return fmt.Errorf("invalid action: %q", r.Action)
+	}
+	for _, host := range r.Hosts {
+		if _, _, err := net.ParseCIDR(host); err == nil || net.ParseIP(host) != nil {
+			continue
+		}
+		if !IsValidDomainPattern(host) {
+			return fmt.Errorf("invalid host pattern: %s", host)
+		}
+	}
+	for _, port := range r.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port: %d", port)
+		}
+	}
+	for _, proto := range r.Protocols {
+		switch proto {
+		case ProtocolHTTP, ProtocolConnect, ProtocolSOCKS:
+			// Valid
+		default:
+			return fmt.Errorf("invalid protocol: %s", proto)
+		}
+	}
+	for _, method := range r.Methods {
+		if method == "" || strings.ContainsFunc(method, func(c rune) bool { return c < '!' || c > '~' }) {
+			return fmt.Errorf("invalid method: %q", method)
+		}
+	}
+	for _, path := range r.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path must start with /: %s", path)
+		}
+	}
+	if r.PathRegex != "" {
+		if _, err := regexp.Compile(r.PathRegex); err != nil {
+			return fmt.Errorf("invalid path regex: %w", err)
+		}
+	}
+	if r.InspectsRequests() && len(r.Protocols) > 0 && !slices.Contains(r.Protocols, ProtocolHTTP) {
+		return errors.New("methods and paths only apply to the http protocol")
+	}
+	return nil
+}
+
+// InspectsRequests reports whether the rule matches on what only HTTP
+// requests carry: the method and path.
+func (r *PolicyRule) InspectsRequests() bool {
+	return len(r.Methods) > 0 || len(r.Paths) > 0 || r.PathRegex != ""
+}
+
 // Validate checks if a Condition is valid.
 func (c *Condition) Validate() error {
 	if c.Header == "" {
@@ -192,6 +192,112 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid secret",
+			modify: func(c *Config) {
+				c.Secrets = SecretsConfig{
+					"sk-ant-REDACTED": {Value: "sk-ant-real", Hosts: []string{"api.anthropic.com"}},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid secret - short placeholder",
+			modify: func(c *Config) {
+				c.Secrets = SecretsConfig{
+					"sk-ant": {Value: "sk-ant-real", Hosts: []string{"api.anthropic.com"}},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid secret - no hosts",
+			modify: func(c *Config) {
+				c.Secrets = SecretsConfig{
+					"sk-ant-REDACTED": {Value: "sk-ant-real"},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid secret - empty value",
+			modify: func(c *Config) {
+				c.Secrets = SecretsConfig{
+					"sk-ant-REDACTED": {Hosts: []string{"api.anthropic.com"}},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid policy",
+			modify: func(c *Config) {
+				c.Policy = PolicyConfig{
+					Default: ActionDeny,
+					Rules: []PolicyRule{
+						{Name: "github reads", Action: ActionAllow, Hosts: []string{"github.com"}, Methods: []string{"GET"}},
+						{Action: ActionLog, Hosts: []string{"10.0.0.0/8"}, Ports: []int{22}, Protocols: []string{ProtocolSOCKS}},
+						{Action: ActionDeny, Paths: []string{"/upload"}, PathRegex: `^/api/v[0-9]+/`},
+					},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid policy default",
+			modify: func(c *Config) {
+				c.Policy = PolicyConfig{Default: ActionLog}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid policy action",
+			modify: func(c *Config) {
+				c.Policy = PolicyConfig{Rules: []PolicyRule{{Action: "block"}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid policy path regex",
+			modify: func(c *Config) {
+				c.Policy = PolicyConfig{Rules: []PolicyRule{{Action: ActionDeny, PathRegex: "("}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid policy methods for socks",
+			modify: func(c *Config) {
+				c.Policy = PolicyConfig{Rules: []PolicyRule{{Action: ActionDeny, Methods: []string{"POST"}, Protocols: []string{ProtocolSOCKS}}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid policy port",
+			modify: func(c *Config) {
+				c.Policy = PolicyConfig{Rules: []PolicyRule{{Action: ActionDeny, Ports: []int{70000}}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid recorder",
+			modify: func(c *Config) {
+				c.Recorder = RecorderConfig{Enabled: true, MaxEntries: 500, MaxBodySize: 4096}
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid recorder max entries",
+			modify: func(c *Config) {
+				c.Recorder = RecorderConfig{Enabled: true, MaxEntries: 0}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid recorder max body size",
+			modify: func(c *Config) {
+				c.Recorder.MaxBodySize = -1
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
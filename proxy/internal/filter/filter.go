@@ -1,4 +1,5 @@
-// Package filter provides connection filtering by domain and IP.
+// Package filter provides connection filtering by domain and IP, and an
+// egress policy on connections and HTTP requests.
 package filter
 
 import (
@@ -8,13 +9,16 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 )
 
-// Filter manages domain and IP allowlists.
+// Filter manages domain and IP allowlists and the egress policy.
 type Filter struct {
 	mu       sync.RWMutex
 	enabled  bool
 	domains  []string
 	cidrs    []*net.IPNet
 	singleIP []net.IP
+
+	rules       []*policyRule
+	defaultDeny bool
 }
 
 // New creates a new Filter.
@@ -112,7 +116,10 @@ func (f *Filter) RemoveDomain(domain string) {
 func (f *Filter) AllowHost(host string) bool {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
+	return f.allowHostLocked(host)
+}
 
+func (f *Filter) allowHostLocked(host string) bool {
 	// If filtering is disabled, allow all
 	if !f.enabled {
 		return true
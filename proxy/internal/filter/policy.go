@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/obot-platform/discobot/proxy/internal/config"
+	"github.com/obot-platform/discobot/proxy/internal/injector"
+)
+
+// Request is a connection or HTTP request checked against the filter.
+type Request struct {
+	Protocol string // config.ProtocolHTTP, ProtocolConnect or ProtocolSOCKS
+	Host     string // Domain or IP, without port
+	Port     int
+	Method   string // HTTP requests only
+	Path     string // HTTP requests only
+}
+
+// String describes the request for block messages, e.g.
+// "POST pastebin.com:443/api/api_post.php".
+func (r Request) String() string {
+	hostPort := net.JoinHostPort(r.Host, fmt.Sprint(r.Port))
+	if r.Protocol == config.ProtocolHTTP {
+		return r.Method + " " + hostPort + r.Path
+	}
+	return strings.ToUpper(r.Protocol) + " " + hostPort
+}
+
+// Decision is the outcome of checking a Request.
+type Decision struct {
+	Allowed bool
+	Rule    string   // Name of the deciding rule, empty for the allowlist and the default action
+	Reason  string   // Why the request was allowed or blocked
+	Logged  []string // Names of the log rules the request matched
+}
+
+// policyRule is a config.PolicyRule prepared for matching.
+type policyRule struct {
+	name      string
+	action    string
+	domains   []string
+	ips       []net.IP
+	cidrs     []*net.IPNet
+	ports     []int
+	protocols []string
+	methods   []string
+	paths     []string
+	pathRegex *regexp.Regexp
+	inspects  bool
+}
+
+func newPolicyRule(i int, r config.PolicyRule) (*policyRule, error) {
+	rule := &policyRule{
+		name:      r.Name,
+		action:    r.Action,
+		ports:     slices.Clone(r.Ports),
+		protocols: slices.Clone(r.Protocols),
+		paths:     slices.Clone(r.Paths),
+		inspects:  r.InspectsRequests(),
+	}
+	if rule.name == "" {
+		rule.name = fmt.Sprintf("rule %d", i)
+	}
+	for _, host := range r.Hosts {
+		if _, cidr, err := net.ParseCIDR(host); err == nil {
+			rule.cidrs = append(rule.cidrs, cidr)
+		} else if ip := net.ParseIP(host); ip != nil {
+			rule.ips = append(rule.ips, ip)
+		} else {
+			rule.domains = append(rule.domains, host)
+		}
+	}
+	for _, method := range r.Methods {
+		rule.methods = append(rule.methods, strings.ToUpper(method))
+	}
+	if r.PathRegex != "" {
+		re, err := regexp.Compile(r.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid path regex: %w", rule.name, err)
+		}
+		rule.pathRegex = re
+	}
+	return rule, nil
+}
+
+// matchesConnection reports whether the rule matches the host, port and
+// protocol of a request.
+func (r *policyRule) matchesConnection(req Request, protocol string) bool {
+	if len(r.protocols) > 0 && !slices.Contains(r.protocols, protocol) {
+		return false
+	}
+	if len(r.ports) > 0 && !slices.Contains(r.ports, req.Port) {
+		return false
+	}
+	if len(r.domains) == 0 && len(r.ips) == 0 && len(r.cidrs) == 0 {
+		return true
+	}
+	if ip := net.ParseIP(req.Host); ip != nil {
+		return slices.ContainsFunc(r.ips, ip.Equal) ||
+			slices.ContainsFunc(r.cidrs, func(cidr *net.IPNet) bool { return cidr.Contains(ip) })
+	}
+	return slices.ContainsFunc(r.domains, func(pattern string) bool { return injector.MatchDomain(pattern, req.Host) })
+}
+
+// matchesRequest reports whether the rule matches the method and path of an
+// HTTP request.
+func (r *policyRule) matchesRequest(req Request) bool {
+	if len(r.methods) > 0 && !slices.Contains(r.methods, strings.ToUpper(req.Method)) {
+		return false
+	}
+	if len(r.paths) > 0 && !slices.ContainsFunc(r.paths, func(prefix string) bool { return strings.HasPrefix(req.Path, prefix) }) {
+		return false
+	}
+	return r.pathRegex == nil || r.pathRegex.MatchString(req.Path)
+}
+
+// SetPolicy replaces the policy. The policy must have been validated.
+func (f *Filter) SetPolicy(policy config.PolicyConfig) error {
+	rules := make([]*policyRule, 0, len(policy.Rules))
+	for i, r := range policy.Rules {
+		rule, err := newPolicyRule(i, r)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.defaultDeny = policy.Default == config.ActionDeny
+	f.rules = rules
+	return nil
+}
+
+// Check decides whether a connection or request may go out: the host must
+// pass the allowlist, then the policy decides.
+//
+// The proxy decrypts HTTPS, so a CONNECT tunnel is allowed by a rule that
+// allows some of the HTTP requests to its host; each request is checked
+// again. SOCKS connections cannot be inspected, so rules on methods and
+// paths never match them.
+func (f *Filter) Check(req Request) Decision {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.allowHostLocked(req.Host) {
+		return Decision{Reason: req.Host + " is not in the allowlist"}
+	}
+
+	var logged []string
+	for _, rule := range f.rules {
+		if req.Protocol == config.ProtocolConnect && (rule.inspects || !rule.matchesConnection(req, req.Protocol)) {
+			// A rule on the requests in the tunnel decides them once decrypted
+			if rule.action == config.ActionAllow && rule.matchesConnection(req, config.ProtocolHTTP) {
+				return Decision{Allowed: true, Rule: rule.name, Reason: "requests are checked by " + rule.name, Logged: logged}
+			}
+			continue
+		}
+		matched := rule.matchesConnection(req, req.Protocol)
+		if rule.inspects {
+			matched = matched && req.Protocol == config.ProtocolHTTP && rule.matchesRequest(req)
+		}
+		if !matched {
+			continue
+		}
+
+		switch rule.action {
+		case config.ActionLog:
+			logged = append(logged, rule.name)
+		case config.ActionAllow:
+			return Decision{Allowed: true, Rule: rule.name, Reason: "allowed by " + rule.name, Logged: logged}
+		default:
+			return Decision{Rule: rule.name, Reason: "denied by " + rule.name, Logged: logged}
+		}
+	}
+
+	if f.defaultDeny {
+		return Decision{Reason: "no rule allows it and the default action is deny", Logged: logged}
+	}
+	return Decision{Allowed: true, Reason: "no rule denies it", Logged: logged}
+}
@@ -0,0 +1,231 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/proxy/internal/config"
+)
+
+func httpReq(method, host, path string) Request {
+	return Request{Protocol: config.ProtocolHTTP, Host: host, Port: 443, Method: method, Path: path}
+}
+
+func connectReq(host string, port int) Request {
+	return Request{Protocol: config.ProtocolConnect, Host: host, Port: port}
+}
+
+func socksReq(host string, port int) Request {
+	return Request{Protocol: config.ProtocolSOCKS, Host: host, Port: port}
+}
+
+func TestFilter_Policy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   config.PolicyConfig
+		req      Request
+		want     bool
+		wantRule string
+	}{
+		{
+			name:   "no policy allows all",
+			req:    httpReq("POST", "example.com", "/"),
+			want:   true,
+			policy: config.PolicyConfig{},
+		},
+		{
+			name: "no POST to pastebin",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Name: "no-pastebin-posts", Action: config.ActionDeny, Hosts: []string{"pastebin.com"}, Methods: []string{"POST"}},
+			}},
+			req:      httpReq("POST", "pastebin.com", "/api/api_post.php"),
+			want:     false,
+			wantRule: "no-pastebin-posts",
+		},
+		{
+			name: "GET from pastebin still allowed",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Name: "no-pastebin-posts", Action: config.ActionDeny, Hosts: []string{"pastebin.com"}, Methods: []string{"POST"}},
+			}},
+			req:  httpReq("GET", "pastebin.com", "/raw/abc"),
+			want: true,
+		},
+		{
+			name:     "GET only from github.com",
+			policy:   githubReadOnly,
+			req:      httpReq("GET", "github.com", "/obot-platform/discobot"),
+			want:     true,
+			wantRule: "github-reads",
+		},
+		{
+			name:     "GET only from github.com denies push",
+			policy:   githubReadOnly,
+			req:      httpReq("POST", "github.com", "/obot-platform/discobot.git/git-receive-pack"),
+			want:     false,
+			wantRule: "github-rest",
+		},
+		{
+			name:     "CONNECT allowed when some requests are",
+			policy:   githubReadOnly,
+			req:      connectReq("github.com", 443),
+			want:     true,
+			wantRule: "github-reads",
+		},
+		{
+			name:     "SOCKS denied when only some requests are allowed",
+			policy:   githubReadOnly,
+			req:      socksReq("github.com", 443),
+			want:     false,
+			wantRule: "github-rest",
+		},
+		{
+			name: "CONNECT allowed by an http rule under default deny",
+			policy: config.PolicyConfig{Default: config.ActionDeny, Rules: []config.PolicyRule{
+				{Action: config.ActionAllow, Hosts: []string{"*.npmjs.org"}, Protocols: []string{config.ProtocolHTTP}},
+			}},
+			req:      connectReq("registry.npmjs.org", 443),
+			want:     true,
+			wantRule: "rule 0",
+		},
+		{
+			name: "CONNECT not denied by a rule on some requests",
+			policy: config.PolicyConfig{Default: config.ActionDeny, Rules: []config.PolicyRule{
+				{Action: config.ActionDeny, Hosts: []string{"example.com"}, Paths: []string{"/admin"}},
+				{Action: config.ActionAllow, Hosts: []string{"example.com"}},
+			}},
+			req:      connectReq("example.com", 443),
+			want:     true,
+			wantRule: "rule 1",
+		},
+		{
+			name: "CONNECT denied by protocol",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Name: "no-tunnels", Action: config.ActionDeny, Protocols: []string{config.ProtocolConnect}, Ports: []int{22}},
+			}},
+			req:      connectReq("example.com", 22),
+			want:     false,
+			wantRule: "no-tunnels",
+		},
+		{
+			name: "port mismatch",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Action: config.ActionDeny, Ports: []int{22}},
+			}},
+			req:  socksReq("example.com", 443),
+			want: true,
+		},
+		{
+			name: "path prefix",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Name: "no-uploads", Action: config.ActionDeny, Paths: []string{"/upload"}},
+			}},
+			req:      httpReq("PUT", "files.example.com", "/upload/big.tar"),
+			want:     false,
+			wantRule: "no-uploads",
+		},
+		{
+			name: "path regex",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Action: config.ActionDeny, PathRegex: `^/repos/[^/]+/[^/]+/issues$`, Methods: []string{"post"}},
+			}},
+			req:      httpReq("POST", "api.github.com", "/repos/a/b/issues"),
+			want:     false,
+			wantRule: "rule 0",
+		},
+		{
+			name: "CIDR",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Name: "no-metadata", Action: config.ActionDeny, Hosts: []string{"169.254.0.0/16"}},
+			}},
+			req:      socksReq("169.254.169.254", 80),
+			want:     false,
+			wantRule: "no-metadata",
+		},
+		{
+			name: "first match wins",
+			policy: config.PolicyConfig{Rules: []config.PolicyRule{
+				{Name: "allow-health", Action: config.ActionAllow, Paths: []string{"/health"}},
+				{Name: "deny-all", Action: config.ActionDeny},
+			}},
+			req:      httpReq("GET", "example.com", "/health"),
+			want:     true,
+			wantRule: "allow-health",
+		},
+		{
+			name: "log rules do not decide",
+			policy: config.PolicyConfig{Default: config.ActionDeny, Rules: []config.PolicyRule{
+				{Name: "log-all", Action: config.ActionLog},
+			}},
+			req:  httpReq("GET", "example.com", "/"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New()
+			if err := f.SetPolicy(tt.policy); err != nil {
+				t.Fatalf("SetPolicy failed: %v", err)
+			}
+			d := f.Check(tt.req)
+			if d.Allowed != tt.want {
+				t.Errorf("Check(%s) allowed = %v, want %v (%s)", tt.req, d.Allowed, tt.want, d.Reason)
+			}
+			if d.Rule != tt.wantRule {
+				t.Errorf("Check(%s) rule = %q, want %q", tt.req, d.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+// githubReadOnly allows only GET and HEAD requests to github.com.
+var githubReadOnly = config.PolicyConfig{Rules: []config.PolicyRule{
+	{Name: "github-reads", Action: config.ActionAllow, Hosts: []string{"github.com"}, Methods: []string{"GET", "HEAD"}},
+	{Name: "github-rest", Action: config.ActionDeny, Hosts: []string{"github.com"}},
+}}
+
+func TestFilter_PolicyLogged(t *testing.T) {
+	f := New()
+	if err := f.SetPolicy(config.PolicyConfig{Rules: []config.PolicyRule{
+		{Name: "audit-posts", Action: config.ActionLog, Methods: []string{"POST"}},
+		{Name: "audit-all", Action: config.ActionLog},
+	}}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+
+	d := f.Check(httpReq("POST", "example.com", "/"))
+	if !d.Allowed || strings.Join(d.Logged, ",") != "audit-posts,audit-all" {
+		t.Errorf("Unexpected decision: %+v", d)
+	}
+	if d := f.Check(httpReq("GET", "example.com", "/")); strings.Join(d.Logged, ",") != "audit-all" {
+		t.Errorf("Unexpected logged rules: %v", d.Logged)
+	}
+}
+
+func TestFilter_PolicyAfterAllowlist(t *testing.T) {
+	f := New()
+	f.SetEnabled(true)
+	f.SetAllowlist([]string{"example.com"}, nil)
+	if err := f.SetPolicy(config.PolicyConfig{Rules: []config.PolicyRule{
+		{Action: config.ActionAllow, Hosts: []string{"other.com"}},
+	}}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+
+	d := f.Check(httpReq("GET", "other.com", "/"))
+	if d.Allowed || !strings.Contains(d.Reason, "allowlist") {
+		t.Errorf("Expected the allowlist to block other.com, got %+v", d)
+	}
+	if d := f.Check(httpReq("GET", "example.com", "/")); !d.Allowed {
+		t.Errorf("Expected example.com allowed, got %+v", d)
+	}
+}
+
+func TestRequest_String(t *testing.T) {
+	if got, want := httpReq("POST", "pastebin.com", "/api").String(), "POST pastebin.com:443/api"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := socksReq("::1", 22).String(), "SOCKS [::1]:22"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -3,21 +3,29 @@ package injector
 import (
 	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/obot-platform/discobot/proxy/internal/config"
 )
 
-// Injector manages header injection rules.
+// defaultSecretHeaders are the headers searched for placeholders when a
+// secret names none.
+var defaultSecretHeaders = []string{"Authorization", "X-Api-Key"}
+
+// Injector manages header injection rules and secret substitution.
 type Injector struct {
-	mu    sync.RWMutex
-	rules map[string]config.HeaderRule
+	mu      sync.RWMutex
+	rules   map[string]config.HeaderRule
+	secrets map[string]config.SecretRule // by placeholder
 }
 
 // New creates a new Injector.
 func New() *Injector {
 	return &Injector{
-		rules: make(map[string]config.HeaderRule),
+		rules:   make(map[string]config.HeaderRule),
+		secrets: make(map[string]config.SecretRule),
 	}
 }
 
@@ -60,22 +68,53 @@ func (i *Injector) DeleteDomain(domain string) {
 	delete(i.rules, domain)
 }
 
+// SetSecrets replaces all secrets atomically.
+func (i *Injector) SetSecrets(secrets config.SecretsConfig) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.secrets = make(map[string]config.SecretRule)
+	for placeholder, secret := range secrets {
+		i.secrets[placeholder] = copySecret(secret)
+	}
+}
+
+// SetSecret sets the secret for a placeholder, or removes it if the secret
+// has no value.
+func (i *Injector) SetSecret(placeholder string, secret config.SecretRule) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if secret.Value == "" {
+		delete(i.secrets, placeholder)
+		return
+	}
+	i.secrets[placeholder] = copySecret(secret)
+}
+
 // MatchResult contains information about a header injection match.
 type MatchResult struct {
 	Matched bool
 	Pattern string
 	Host    string
 	Headers []string // Names of headers that were set/appended
+	Secrets []string // Names of headers whose placeholders were replaced by secrets
 }
 
-// Apply injects matching headers into the request.
+// Apply injects matching headers into the request, then replaces the
+// placeholders of secrets allowed for the host it is sent to.
 // Returns match information for logging purposes.
 func (i *Injector) Apply(req *http.Request) MatchResult {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
 	host := extractHost(req.Host)
+	result := i.applyRules(req, host)
+	result.Secrets = i.substituteSecrets(req)
+	return result
+}
 
+func (i *Injector) applyRules(req *http.Request, host string) MatchResult {
 	// Try exact match first
 	if rule, ok := i.rules[host]; ok {
 		headers := applyRule(req, rule)
@@ -93,6 +132,48 @@ func (i *Injector) Apply(req *http.Request) MatchResult {
 	return MatchResult{Matched: false, Host: host}
 }
 
+// substituteSecrets replaces placeholders with their secrets in the headers
+// of an HTTPS request, and returns the names of the headers it changed.
+// Placeholders bound for other hosts are left alone: they are worthless
+// there. So are those in plain HTTP requests, which would carry the secret in
+// cleartext.
+//
+// The request goes to its URL's host, which for decrypted requests is the
+// CONNECT target. The Host header is set by the client, so a request whose
+// Host header names another host keeps its placeholders.
+func (i *Injector) substituteSecrets(req *http.Request) []string {
+	if req.URL == nil || req.URL.Scheme != "https" {
+		return nil
+	}
+	host := req.URL.Hostname()
+	if req.Host != "" && !strings.EqualFold(extractHost(req.Host), host) {
+		return nil
+	}
+	var changed []string
+	for placeholder, secret := range i.secrets {
+		if !slices.ContainsFunc(secret.Hosts, func(pattern string) bool { return MatchDomain(pattern, host) }) {
+			continue
+		}
+		headers := secret.Headers
+		if len(headers) == 0 {
+			headers = defaultSecretHeaders
+		}
+		for _, name := range headers {
+			name = http.CanonicalHeaderKey(name)
+			values := req.Header[name]
+			for j, value := range values {
+				if strings.Contains(value, placeholder) {
+					values[j] = strings.ReplaceAll(value, placeholder, secret.Value)
+					if !slices.Contains(changed, name) {
+						changed = append(changed, name)
+					}
+				}
+			}
+		}
+	}
+	return changed
+}
+
 // GetRules returns a copy of all rules (for testing).
 func (i *Injector) GetRules() map[string]config.HeaderRule {
 	i.mu.RLock()
@@ -175,6 +256,14 @@ func copyMap(m map[string]string) map[string]string {
 	return c
 }
 
+func copySecret(secret config.SecretRule) config.SecretRule {
+	return config.SecretRule{
+		Value:   secret.Value,
+		Hosts:   slices.Clone(secret.Hosts),
+		Headers: slices.Clone(secret.Headers),
+	}
+}
+
 func copyConditions(conditions []config.Condition) []config.Condition {
 	if conditions == nil {
 		return nil
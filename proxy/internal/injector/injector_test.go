@@ -408,3 +408,148 @@ func TestInjector_Apply_Conditions_CaseSensitive(t *testing.T) {
 		t.Errorf("Authorization = %q, want empty (case mismatch)", got)
 	}
 }
+
+func TestInjector_Apply_Secrets(t *testing.T) {
+	const placeholder = "sk-ant-REDACTED"
+	inj := New()
+	inj.SetSecrets(config.SecretsConfig{
+		placeholder: config.SecretRule{Value: "sk-ant-real", Hosts: []string{"api.anthropic.com", "*.anthropic.com"}},
+		"ghu_discobot_0123456789abcdef": config.SecretRule{
+			Value:   "ghu_real",
+			Hosts:   []string{"api.githubcopilot.com"},
+			Headers: []string{"authorization", "X-Custom-Token"},
+		},
+	})
+
+	tests := []struct {
+		name        string
+		url         string
+		header      string
+		value       string
+		want        string
+		wantSecrets []string
+	}{
+		{
+			name:        "api key to allowed host",
+			url:         "https://api.anthropic.com/v1/messages",
+			header:      "X-Api-Key",
+			value:       placeholder,
+			want:        "sk-ant-real",
+			wantSecrets: []string{"X-Api-Key"},
+		},
+		{
+			name:        "bearer token to wildcard host with port",
+			url:         "https://console.anthropic.com:443/v1/oauth",
+			header:      "Authorization",
+			value:       "Bearer " + placeholder,
+			want:        "Bearer sk-ant-real",
+			wantSecrets: []string{"Authorization"},
+		},
+		{
+			name:   "other host keeps placeholder",
+			url:    "https://pastebin.com/api",
+			header: "Authorization",
+			value:  "Bearer " + placeholder,
+			want:   "Bearer " + placeholder,
+		},
+		{
+			name:   "plain http keeps placeholder",
+			url:    "http://api.anthropic.com/v1/messages",
+			header: "X-Api-Key",
+			value:  placeholder,
+			want:   placeholder,
+		},
+		{
+			name:   "plain http on port 443 keeps placeholder",
+			url:    "http://api.anthropic.com:443/v1/messages",
+			header: "X-Api-Key",
+			value:  placeholder,
+			want:   placeholder,
+		},
+		{
+			name:   "header not searched",
+			url:    "https://api.anthropic.com/v1/messages",
+			header: "X-Other",
+			value:  placeholder,
+			want:   placeholder,
+		},
+		{
+			name:        "custom headers",
+			url:         "https://api.githubcopilot.com/chat",
+			header:      "X-Custom-Token",
+			value:       "ghu_discobot_0123456789abcdef",
+			want:        "ghu_real",
+			wantSecrets: []string{"X-Custom-Token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set(tt.header, tt.value)
+			result := inj.Apply(req)
+
+			if got := req.Header.Get(tt.header); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+			}
+			if len(result.Secrets) != len(tt.wantSecrets) || (len(tt.wantSecrets) > 0 && result.Secrets[0] != tt.wantSecrets[0]) {
+				t.Errorf("Secrets = %v, want %v", result.Secrets, tt.wantSecrets)
+			}
+		})
+	}
+}
+
+func TestInjector_Apply_SecretsFollowURLHost(t *testing.T) {
+	const placeholder = "sk-ant-REDACTED"
+	inj := New()
+	inj.SetSecrets(config.SecretsConfig{
+		placeholder: config.SecretRule{Value: "sk-ant-real", Hosts: []string{"api.anthropic.com"}},
+	})
+
+	tests := []struct {
+		name string
+		url  string
+		host string
+		want string
+	}{
+		{name: "host header matches", url: "https://api.anthropic.com/v1/messages", host: "API.anthropic.com:443", want: "sk-ant-real"},
+		{name: "host header spoofed", url: "https://evil.com/v1/messages", host: "api.anthropic.com", want: placeholder},
+		{name: "sent elsewhere than host header", url: "https://api.anthropic.com/v1/messages", host: "evil.com", want: placeholder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Host = tt.host
+			req.Header.Set("X-Api-Key", placeholder)
+			inj.Apply(req)
+
+			if got := req.Header.Get("X-Api-Key"); got != tt.want {
+				t.Errorf("X-Api-Key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjector_SetSecret(t *testing.T) {
+	const placeholder = "sk-ant-REDACTED"
+	inj := New()
+	inj.SetSecret(placeholder, config.SecretRule{Value: "first", Hosts: []string{"api.anthropic.com"}})
+	inj.SetSecret(placeholder, config.SecretRule{Value: "refreshed", Hosts: []string{"api.anthropic.com"}})
+
+	req := httptest.NewRequest("GET", "https://api.anthropic.com/", nil)
+	req.Header.Set("Authorization", "Bearer "+placeholder)
+	inj.Apply(req)
+	if got := req.Header.Get("Authorization"); got != "Bearer refreshed" {
+		t.Errorf("Authorization = %q, want the latest secret", got)
+	}
+
+	// A secret without a value removes the placeholder
+	inj.SetSecret(placeholder, config.SecretRule{})
+	req = httptest.NewRequest("GET", "https://api.anthropic.com/", nil)
+	req.Header.Set("Authorization", "Bearer "+placeholder)
+	inj.Apply(req)
+	if got := req.Header.Get("Authorization"); got != "Bearer "+placeholder {
+		t.Errorf("Authorization = %q, want the placeholder kept", got)
+	}
+}
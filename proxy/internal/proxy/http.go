@@ -3,6 +3,7 @@ package proxy
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"net"
 	"net/http"
 	"time"
@@ -14,8 +15,13 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/recorder"
 )
 
+// maxLearnedBodySize bounds the registry metadata bodies buffered to learn
+// package file digests from.
+const maxLearnedBodySize = 32 << 20
+
 // HTTPProxy wraps goproxy for HTTP/HTTPS proxying.
 type HTTPProxy struct {
 	proxy        *goproxy.ProxyHttpServer
@@ -24,17 +30,25 @@ type HTTPProxy struct {
 	logger       *logger.Logger
 	cache        *cache.Cache
 	cacheMatcher *cache.Matcher
+	recorder     *recorder.Recorder
+
+	// secretTransport sends requests carrying substituted secrets. Unlike
+	// goproxy's default transport it verifies upstream certificates, so
+	// real credentials only reach the genuine host.
+	secretTransport http.RoundTripper
 }
 
 // requestMeta is stored in goproxy's ctx.UserData to carry per-request state
 // between the request and response handlers.
 type requestMeta struct {
-	startTime time.Time
-	cacheHit  bool
+	startTime  time.Time
+	cacheHit   bool
+	revalidate *cache.Entry // cached entry served if upstream answers 304
+	record     *recorder.Entry
 }
 
 // NewHTTPProxy creates a new HTTP proxy.
-func NewHTTPProxy(certMgr *cert.Manager, inj *injector.Injector, flt *filter.Filter, log *logger.Logger, c *cache.Cache, matcher *cache.Matcher) *HTTPProxy {
+func NewHTTPProxy(certMgr *cert.Manager, inj *injector.Injector, flt *filter.Filter, log *logger.Logger, c *cache.Cache, matcher *cache.Matcher, rec *recorder.Recorder) *HTTPProxy {
 	proxy := goproxy.NewProxyHttpServer()
 	proxy.Verbose = false
 
@@ -45,6 +59,9 @@ func NewHTTPProxy(certMgr *cert.Manager, inj *injector.Injector, flt *filter.Fil
 		logger:       log,
 		cache:        c,
 		cacheMatcher: matcher,
+		recorder:     rec,
+
+		secretTransport: &http.Transport{Proxy: http.ProxyFromEnvironment},
 	}
 
 	h.setupMITM(certMgr)
@@ -103,9 +120,16 @@ func (h *HTTPProxy) setupMITM(certMgr *cert.Manager) {
 
 func (h *HTTPProxy) setupHandlers() {
 	// Handle CONNECT requests (HTTPS)
-	h.proxy.OnRequest().HandleConnectFunc(func(host string, _ *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
-		if !h.filter.AllowHost(host) {
-			h.logger.LogBlocked(host, "filter")
+	h.proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		creq := connectRequest(host)
+		decision := h.filter.Check(creq)
+		logPolicyMatches(h.logger, creq, decision)
+		if !decision.Allowed {
+			h.logger.LogBlocked(host, decision.Reason)
+			if ctx.Req != nil {
+				h.recorder.Blocked(ctx.Req)
+				ctx.Resp = goproxy.NewResponse(ctx.Req, goproxy.ContentTypeText, http.StatusForbidden, blockedMessage(creq, decision))
+			}
 			return goproxy.RejectConnect, host
 		}
 		return goproxy.MitmConnect, host
@@ -116,88 +140,165 @@ func (h *HTTPProxy) setupHandlers() {
 		meta := &requestMeta{startTime: time.Now()}
 		ctx.UserData = meta
 
-		// Filter check (for plain HTTP)
-		if !h.filter.AllowHost(req.Host) {
-			h.logger.LogBlocked(req.Host, "filter")
-			return req, goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusForbidden, "Blocked by proxy")
+		// Filter check, on the method and path too
+		freq := httpRequest(req)
+		decision := h.filter.Check(freq)
+		logPolicyMatches(h.logger, freq, decision)
+		if !decision.Allowed {
+			h.logger.LogBlocked(freq.Host, decision.Reason)
+			h.recorder.Blocked(req)
+			return req, goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusForbidden, blockedMessage(freq, decision))
 		}
 
 		// Check cache
 		if h.cacheMatcher != nil && h.cacheMatcher.ShouldCache(req) {
 			key := h.cacheMatcher.GenerateKey(req)
-			if entry, err := h.cache.Get(key); err == nil {
+			entry, err := h.cache.Get(key)
+			switch {
+			case err != nil:
+				h.logger.Debug("cache miss", "host", req.Host, "path", req.URL.Path)
+			case h.cacheMatcher.ShouldRevalidate(req):
+				// Metadata is only served once upstream confirms it is current
+				if cache.SetConditionalHeaders(req, entry) {
+					meta.revalidate = entry
+					h.logger.Debug("cache revalidate", "host", req.Host, "path", req.URL.Path)
+				} else {
+					entry.Close()
+				}
+			default:
 				meta.cacheHit = true
+				meta.record = h.recorder.Start(req, nil)
 				h.logger.Info("cache hit",
 					"host", req.Host,
 					"path", req.URL.Path,
 					"size", entry.Size,
+					"range", req.Header.Get("Range"),
 					"cached_at", entry.CachedAt.Format(time.RFC3339),
 				)
 				return req, cache.RestoreResponse(entry, req)
 			}
-			h.logger.Debug("cache miss", "host", req.Host, "path", req.URL.Path)
 		}
 
-		// Inject headers
+		// Inject headers and substitute secrets
 		match := h.injector.Apply(req)
 		if match.Matched {
 			h.logger.LogHeaderInjection(match.Host, match.Pattern, match.Headers)
 		}
+		if len(match.Secrets) > 0 {
+			h.logger.Debug("secrets substituted", "host", req.URL.Hostname(), "headers", match.Secrets)
+			ctx.RoundTripper = goproxy.RoundTripperFunc(func(req *http.Request, _ *goproxy.ProxyCtx) (*http.Response, error) {
+				return h.secretTransport.RoundTrip(req)
+			})
+		}
 
-		// Log request
+		// Log and record request; injected headers and those carrying
+		// secrets are redacted in the recording
 		h.logger.LogRequest(req)
+		meta.record = h.recorder.Start(req, append(match.Headers, match.Secrets...))
 
 		return req, nil
 	})
 
-	// Log responses and cache if applicable
+	// Log, cache and record responses
 	h.proxy.OnResponse().DoFunc(func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
-		if resp == nil || ctx.Req == nil {
-			return resp
-		}
+		resp = h.handleResponse(resp, ctx)
 
-		meta, _ := ctx.UserData.(*requestMeta)
+		// Record the response the client receives
+		if meta, _ := ctx.UserData.(*requestMeta); meta != nil {
+			if resp == nil {
+				h.recorder.Fail(meta.record, ctx.Error)
+			} else {
+				h.recorder.Response(meta.record, resp, nil)
+			}
+		}
+		return resp
+	})
+}
 
-		// Cache hits were already logged in the request handler and never
-		// contacted upstream — nothing more to do here.
-		if meta != nil && meta.cacheHit {
-			return resp
+// handleResponse logs a response and caches it if applicable.
+func (h *HTTPProxy) handleResponse(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
+	meta, _ := ctx.UserData.(*requestMeta)
+	if resp == nil || ctx.Req == nil {
+		if meta != nil && meta.revalidate != nil {
+			meta.revalidate.Close()
 		}
+		return resp
+	}
 
-		var duration time.Duration
-		if meta != nil {
-			duration = time.Since(meta.startTime)
+	// Cache hits were already logged in the request handler and never
+	// contacted upstream — nothing more to do here.
+	if meta != nil && meta.cacheHit {
+		return resp
+	}
+
+	var duration time.Duration
+	if meta != nil {
+		duration = time.Since(meta.startTime)
+	}
+	h.logger.LogResponse(resp, ctx.Req, duration)
+
+	// Serve revalidated metadata from the cache
+	if meta != nil && meta.revalidate != nil {
+		entry := meta.revalidate
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			h.logger.Info("cache hit",
+				"host", ctx.Req.Host,
+				"path", ctx.Req.URL.Path,
+				"size", entry.Size,
+				"revalidated", true,
+				"cached_at", entry.CachedAt.Format(time.RFC3339),
+			)
+			return cache.RestoreResponse(entry, ctx.Req)
 		}
-		h.logger.LogResponse(resp, ctx.Req, duration)
-
-		// Cache response if applicable
-		if h.cacheMatcher != nil && h.cacheMatcher.ShouldCache(ctx.Req) {
-			if !h.cacheMatcher.ShouldCacheResponse(resp) {
-				h.logger.Debug("response not cacheable",
-					"path", ctx.Req.URL.Path,
-					"status", resp.StatusCode,
-					"content_type", resp.Header.Get("Content-Type"),
-					"cache_control", resp.Header.Get("Cache-Control"),
-				)
+		entry.Close()
+	}
+
+	// Learn package file digests from registry metadata and checksums
+	if h.cacheMatcher != nil && resp.StatusCode == http.StatusOK && h.cacheMatcher.Learns(ctx.Req) {
+		req := ctx.Req
+		cache.ObserveResponse(resp, maxLearnedBodySize, func(body []byte) {
+			for _, key := range h.cacheMatcher.Learn(req, resp.Header, body) {
+				// Files cached before their digest was known are checked now
+				if v := h.cacheMatcher.LearnedVerifier(key); v != nil {
+					go func() {
+						if err := h.cache.Check(key, v); err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+							h.logger.Warn("removed cached file", "key", key, "error", err.Error())
+						}
+					}()
+				}
+			}
+		})
+	}
+
+	// Cache response if applicable
+	if h.cacheMatcher != nil && h.cacheMatcher.ShouldCache(ctx.Req) {
+		if !h.cacheMatcher.ShouldCacheResponse(resp) {
+			h.logger.Debug("response not cacheable",
+				"path", ctx.Req.URL.Path,
+				"status", resp.StatusCode,
+				"content_type", resp.Header.Get("Content-Type"),
+				"cache_control", resp.Header.Get("Cache-Control"),
+			)
+		} else {
+			path := ctx.Req.URL.Path
+			w, err := h.cache.NewWriter(h.cacheMatcher.GenerateKey(ctx.Req), resp.StatusCode, resp.Header)
+			if err != nil {
+				h.logger.Warn("cache store failed", "path", path, "error", err.Error())
 			} else {
-				entry, err := cache.CaptureResponse(resp)
-				if err != nil {
-					h.logger.Warn("failed to read response for caching", "path", ctx.Req.URL.Path, "error", err.Error())
-				} else if err := h.cacheMatcher.VerifyDigest(ctx.Req.URL.Path, entry.Body); err != nil {
-					h.logger.Warn("digest verification failed, not caching", "path", ctx.Req.URL.Path, "error", err.Error())
-				} else {
-					key := h.cacheMatcher.GenerateKey(ctx.Req)
-					if err := h.cache.Put(key, entry); err != nil {
-						h.logger.Warn("cache store failed", "path", ctx.Req.URL.Path, "error", err.Error())
-					} else {
-						h.logger.Info("cached response", "path", ctx.Req.URL.Path, "size", entry.Size)
+				// The body streams to the client while it is cached
+				cache.CaptureResponse(resp, w, h.cacheMatcher.Verifier(ctx.Req), func(size int64, err error) {
+					if err != nil {
+						h.logger.Warn("response not cached", "path", path, "error", err.Error())
+						return
 					}
-				}
+					h.logger.Info("cached response", "path", path, "size", size)
+				})
 			}
 		}
+	}
 
-		return resp
-	})
+	return resp
 }
 
 // ServeConn serves an HTTP connection.
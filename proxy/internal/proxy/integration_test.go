@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
@@ -13,10 +15,12 @@ import (
 
 	"github.com/elazarl/goproxy"
 
+	"github.com/obot-platform/discobot/proxy/internal/cert"
 	"github.com/obot-platform/discobot/proxy/internal/config"
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/recorder"
 )
 
 // testLogger creates a test logger
@@ -137,6 +141,220 @@ func TestIntegration_HTTPProxy_HeaderInjection(t *testing.T) {
 	}
 }
 
+func TestIntegration_HTTPProxy_Recording(t *testing.T) {
+	var receivedToken string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedToken = r.Header.Get("X-Upstream-Token")
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "got %s", body)
+	}))
+	defer backend.Close()
+
+	backendHost, _, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	inj := injector.New()
+	inj.SetRules(config.HeadersConfig{
+		backendHost: config.HeaderRule{Set: map[string]string{"X-Upstream-Token": "secret-token"}},
+	})
+
+	certMgr, err := cert.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %v", err)
+	}
+	rec := recorder.New(config.RecorderConfig{Enabled: true, MaxEntries: 10, MaxBodySize: 1024})
+	h := NewHTTPProxy(certMgr, inj, filter.New(), testLogger(t), nil, nil, rec)
+
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	resp, err := client.Post(backend.URL+"/items", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Request through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "got hello" || receivedToken != "secret-token" {
+		t.Fatalf("Unexpected exchange: body %q, token %q", body, receivedToken)
+	}
+
+	entries := rec.List(recorder.Filter{})
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 recorded entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Method != "POST" || e.URL != backend.URL+"/items" || e.Status != http.StatusOK || !e.Done {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	if got := e.RequestHeaders.Get("X-Upstream-Token"); got != "[REDACTED]" {
+		t.Errorf("Expected injected header to be redacted, got %q", got)
+	}
+	if e.RequestBody == nil || e.RequestBody.Text != "hello" || e.RequestSize != 5 {
+		t.Errorf("Unexpected request body: %+v (size %d)", e.RequestBody, e.RequestSize)
+	}
+	if e.ResponseBody == nil || e.ResponseBody.Text != "got hello" || e.ResponseSize != 9 {
+		t.Errorf("Unexpected response body: %+v (size %d)", e.ResponseBody, e.ResponseSize)
+	}
+}
+
+// mitmClient returns a client going through the proxy at proxyURL and
+// trusting its CA, as sandboxes do.
+func mitmClient(t *testing.T, certMgr *cert.Manager, proxyURL string) *http.Client {
+	t.Helper()
+	caCert, err := x509.ParseCertificate(certMgr.GetCA().Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	u, _ := url.Parse(proxyURL)
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(u), TLSClientConfig: &tls.Config{RootCAs: roots}},
+		Timeout:   5 * time.Second,
+	}
+}
+
+func TestIntegration_HTTPProxy_SecretSubstitution(t *testing.T) {
+	const placeholder = "sk-discobot-0123456789abcdef"
+	var receivedKeys []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedKeys = append(receivedKeys, r.Header.Get("X-Goog-Api-Key"))
+		w.WriteHeader(http.StatusOK)
+	})
+	tlsBackend := httptest.NewTLSServer(handler)
+	defer tlsBackend.Close()
+	plainBackend := httptest.NewServer(handler)
+	defer plainBackend.Close()
+
+	backendHost, _, _ := net.SplitHostPort(strings.TrimPrefix(tlsBackend.URL, "https://"))
+	inj := injector.New()
+	inj.SetSecrets(config.SecretsConfig{
+		placeholder: config.SecretRule{Value: "sk-real-secret", Hosts: []string{backendHost}, Headers: []string{"X-Goog-Api-Key"}},
+	})
+
+	certMgr, err := cert.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %v", err)
+	}
+	rec := recorder.New(config.RecorderConfig{Enabled: true, MaxEntries: 10})
+	h := NewHTTPProxy(certMgr, inj, filter.New(), testLogger(t), nil, nil, rec)
+
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	client := mitmClient(t, certMgr, proxyServer.URL)
+	send := func(targetURL, host string) error {
+		req, _ := http.NewRequest("GET", targetURL+"/v1/models", nil)
+		req.Host = host
+		req.Header.Set("X-Goog-Api-Key", placeholder)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil
+	}
+
+	// The backend's certificate is not trusted, so the secret is not sent
+	_ = send(tlsBackend.URL, "")
+	if len(receivedKeys) != 0 {
+		t.Fatalf("Upstream with an untrusted certificate received %v", receivedKeys)
+	}
+
+	backendRoots := x509.NewCertPool()
+	backendRoots.AddCert(tlsBackend.Certificate())
+	h.secretTransport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: backendRoots}}
+
+	_, port, _ := net.SplitHostPort(tlsBackend.Listener.Addr().String())
+	for _, r := range []struct{ url, host string }{
+		{tlsBackend.URL, ""},
+		{plainBackend.URL, ""},
+		// Tunnel to another host, claiming the secret's host in the Host header
+		{"https://localhost:" + port, net.JoinHostPort(backendHost, port)},
+	} {
+		if err := send(r.url, r.host); err != nil {
+			t.Fatalf("Request to %s through proxy failed: %v", r.url, err)
+		}
+	}
+
+	// The secret is only sent over TLS to the host the request goes to
+	if want := []string{"sk-real-secret", placeholder, placeholder}; strings.Join(receivedKeys, ",") != strings.Join(want, ",") {
+		t.Errorf("Upstream received %v, want %v", receivedKeys, want)
+	}
+	redacted := 0
+	for _, entry := range rec.List(recorder.Filter{}) {
+		if entry.RequestHeaders.Get("X-Goog-Api-Key") == "[REDACTED]" {
+			redacted++
+		}
+	}
+	if redacted != 2 {
+		t.Errorf("Expected the substituted header redacted in 2 entries, got %d", redacted)
+	}
+}
+
+func TestIntegration_HTTPProxy_Policy(t *testing.T) {
+	var received []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendHost, _, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	flt := filter.New()
+	if err := flt.SetPolicy(config.PolicyConfig{Rules: []config.PolicyRule{
+		{Name: "read-only", Action: config.ActionDeny, Hosts: []string{backendHost}, Methods: []string{"POST", "PUT", "DELETE"}},
+	}}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+
+	certMgr, err := cert.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %v", err)
+	}
+	rec := recorder.New(config.RecorderConfig{Enabled: true, MaxEntries: 10})
+	h := NewHTTPProxy(certMgr, injector.New(), flt, testLogger(t), nil, nil, rec)
+
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	resp, err := client.Get(backend.URL + "/paste/1")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected GET allowed, got status %d", resp.StatusCode)
+	}
+
+	resp, err = client.Post(backend.URL+"/paste", "text/plain", strings.NewReader("secret"))
+	if err != nil {
+		t.Fatalf("POST through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected POST blocked with 403, got %d", resp.StatusCode)
+	}
+	for _, want := range []string{"POST " + backendHost, "/paste", "read-only"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected the block message to mention %q, got %q", want, body)
+		}
+	}
+
+	if len(received) != 1 || received[0] != "GET /paste/1" {
+		t.Errorf("Upstream received %v, want only the GET", received)
+	}
+	if entries := rec.List(recorder.Filter{}); len(entries) != 2 || !entries[1].Blocked {
+		t.Errorf("Expected the POST recorded as blocked, got %+v", entries)
+	}
+}
+
 func TestIntegration_HTTPProxy_HeaderAppend(t *testing.T) {
 	// Create a test HTTP server that echoes headers
 	var receivedHeaders http.Header
@@ -618,3 +836,55 @@ func TestIntegration_MySQLOverSOCKS5(t *testing.T) {
 		t.Errorf("Expected MySQL protocol version 10, got: %d", mysqlBuf[4])
 	}
 }
+
+func TestIntegration_HTTPProxy_PolicyIgnoresHostHeader(t *testing.T) {
+	var received []string
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Method+" "+r.Host+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendHost, _, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "https://"))
+	flt := filter.New()
+	if err := flt.SetPolicy(config.PolicyConfig{Rules: []config.PolicyRule{
+		{Name: "no-paste", Action: config.ActionDeny, Hosts: []string{backendHost}, Methods: []string{"POST"}, Paths: []string{"/api/"}},
+	}}); err != nil {
+		t.Fatalf("SetPolicy failed: %v", err)
+	}
+
+	certMgr, err := cert.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %v", err)
+	}
+	h := NewHTTPProxy(certMgr, injector.New(), flt, testLogger(t), nil, nil, recorder.New(config.RecorderConfig{}))
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+	client := mitmClient(t, certMgr, proxyServer.URL)
+
+	// The tunnel is allowed since the rule only denies some requests in it
+	for _, r := range []struct{ path, host string }{
+		{"/api/post", ""},
+		// The Host header names a host the rule does not cover
+		{"/api/post", "example.com"},
+		// Unclean paths still fall under the rule's prefix
+		{"//api/post", ""},
+		{"/./api/post", ""},
+	} {
+		req, _ := http.NewRequest("POST", backend.URL+r.path, strings.NewReader("secret"))
+		if r.host != "" {
+			req.Host = r.host
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s through proxy failed: %v", r.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected POST %s (Host %q) blocked with 403, got %d", r.path, r.host, resp.StatusCode)
+		}
+	}
+	if len(received) != 0 {
+		t.Errorf("Upstream received %v, want nothing", received)
+	}
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/obot-platform/discobot/proxy/internal/config"
+	"github.com/obot-platform/discobot/proxy/internal/filter"
+	"github.com/obot-platform/discobot/proxy/internal/logger"
+)
+
+// connectRequest describes a CONNECT tunnel to host:port for the filter.
+func connectRequest(hostPort string) filter.Request {
+	host, port := splitHostPort(hostPort, 443)
+	return filter.Request{Protocol: config.ProtocolConnect, Host: host, Port: port}
+}
+
+// httpRequest describes an HTTP request, or an HTTPS request decrypted by
+// the proxy, for the filter. The host is the one the request is sent to: its
+// URL's, which for decrypted requests is the CONNECT target. The Host header
+// is set by the client and could name any host.
+func httpRequest(req *http.Request) filter.Request {
+	defaultPort := 80
+	if req.URL.Scheme == "https" {
+		defaultPort = 443
+	}
+	hostPort := req.URL.Host
+	if hostPort == "" {
+		hostPort = req.Host
+	}
+	host, port := splitHostPort(hostPort, defaultPort)
+	return filter.Request{
+		Protocol: config.ProtocolHTTP,
+		Host:     host,
+		Port:     port,
+		Method:   req.Method,
+		Path:     cleanPath(req.URL.Path),
+	}
+}
+
+// cleanPath returns the canonical form of a request path, so that path rules
+// match "//api" and "/./api" like "/api". A trailing slash is kept.
+func cleanPath(p string) string {
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// splitHostPort splits host:port, using defaultPort when there is no port.
+func splitHostPort(hostPort string, defaultPort int) (string, int) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return hostPort, defaultPort
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return host, defaultPort
+	}
+	return host, port
+}
+
+// blockedMessage is the body of a 403 response to a blocked request. It
+// tells the client, often an agent, what was blocked and why, so it does not
+// mistake the block for a network failure and retry.
+func blockedMessage(req filter.Request, d filter.Decision) string {
+	return fmt.Sprintf("Blocked by proxy: %s (%s)\n", req, d.Reason)
+}
+
+// logPolicyMatches logs the log rules of the policy a request matched.
+func logPolicyMatches(log *logger.Logger, req filter.Request, d filter.Decision) {
+	for _, rule := range d.Logged {
+		log.Info("policy match", "rule", rule, "request", req.String(), "allowed", d.Allowed)
+	}
+}
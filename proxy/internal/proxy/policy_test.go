@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		host     string
+		wantHost string
+		wantPort int
+		wantPath string
+	}{
+		{name: "plain", url: "http://example.com/api", wantHost: "example.com", wantPort: 80, wantPath: "/api"},
+		{name: "https with port", url: "https://example.com:8443/api", wantHost: "example.com", wantPort: 8443, wantPath: "/api"},
+		{name: "host header ignored", url: "https://pastebin.com/api", host: "example.com", wantHost: "pastebin.com", wantPort: 443, wantPath: "/api"},
+		{name: "double slash", url: "https://pastebin.com//api/post", wantHost: "pastebin.com", wantPort: 443, wantPath: "/api/post"},
+		{name: "dot segments", url: "https://pastebin.com/./x/../api/post", wantHost: "pastebin.com", wantPort: 443, wantPath: "/api/post"},
+		{name: "trailing slash kept", url: "https://pastebin.com/api//", wantHost: "pastebin.com", wantPort: 443, wantPath: "/api/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			got := httpRequest(req)
+			if got.Host != tt.wantHost || got.Port != tt.wantPort || got.Path != tt.wantPath {
+				t.Errorf("httpRequest = %s:%d%s, want %s:%d%s", got.Host, got.Port, got.Path, tt.wantHost, tt.wantPort, tt.wantPath)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/recorder"
 )
 
 // Server is the main proxy server with protocol detection.
@@ -26,6 +27,7 @@ type Server struct {
 	certMgr      *cert.Manager
 	cache        *cache.Cache
 	cacheMatcher *cache.Matcher
+	recorder     *recorder.Recorder
 
 	mu       sync.RWMutex
 	running  bool
@@ -52,12 +54,14 @@ func New(cfg *config.Config, log *logger.Logger) (*Server, error) {
 	// Initialize cache matcher
 	var matcher *cache.Matcher
 	if cfg.Cache.Enabled {
-		matcher, err = cache.NewMatcher(cfg.Cache.Patterns, cfg.Cache.ContentAware)
+		matcher, err = cache.NewMatcher(cfg.Cache.Patterns, cfg.Cache.ContentAware, cfg.Cache.Profiles...)
 		if err != nil {
 			return nil, fmt.Errorf("cache matcher: %w", err)
 		}
 	}
 
+	rec := recorder.New(cfg.Recorder)
+
 	s := &Server{
 		cfg:          cfg,
 		injector:     inj,
@@ -66,10 +70,11 @@ func New(cfg *config.Config, log *logger.Logger) (*Server, error) {
 		certMgr:      certMgr,
 		cache:        c,
 		cacheMatcher: matcher,
+		recorder:     rec,
 		shutdown:     make(chan struct{}),
 	}
 
-	s.httpProxy = NewHTTPProxy(certMgr, inj, flt, log, c, matcher)
+	s.httpProxy = NewHTTPProxy(certMgr, inj, flt, log, c, matcher, rec)
 	s.socksProxy = NewSOCKSProxy(flt, log)
 
 	// Apply initial configuration
@@ -84,8 +89,11 @@ func (s *Server) ApplyConfig(cfg *config.Config) {
 	defer s.mu.Unlock()
 
 	s.injector.SetRules(cfg.Headers)
+	s.injector.SetSecrets(cfg.Secrets)
 	s.filter.SetEnabled(cfg.Allowlist.Enabled)
 	s.filter.SetAllowlist(cfg.Allowlist.Domains, cfg.Allowlist.IPs)
+	s.setPolicy(cfg.Policy)
+	s.recorder.Configure(cfg.Recorder)
 }
 
 // ApplyRuntimeConfig applies runtime configuration from API.
@@ -105,6 +113,11 @@ func (s *Server) ApplyRuntimeConfig(cfg *config.RuntimeConfig, merge bool) {
 			}
 		}
 
+		// A secret without a value removes its placeholder
+		for placeholder, secret := range cfg.Secrets {
+			s.injector.SetSecret(placeholder, secret)
+		}
+
 		if cfg.Allowlist != nil {
 			if cfg.Allowlist.Enabled != nil {
 				s.filter.SetEnabled(*cfg.Allowlist.Enabled)
@@ -116,6 +129,11 @@ func (s *Server) ApplyRuntimeConfig(cfg *config.RuntimeConfig, merge bool) {
 				s.filter.AddIPs(cfg.Allowlist.IPs)
 			}
 		}
+
+		// Rules are ordered, so the policy is replaced rather than merged
+		if cfg.Policy != nil {
+			s.setPolicy(*cfg.Policy)
+		}
 	} else {
 		// POST: complete overwrite
 		if cfg.Headers != nil {
@@ -123,6 +141,7 @@ func (s *Server) ApplyRuntimeConfig(cfg *config.RuntimeConfig, merge bool) {
 		} else {
 			s.injector.SetRules(nil)
 		}
+		s.injector.SetSecrets(cfg.Secrets)
 
 		if cfg.Allowlist != nil {
 			enabled := cfg.Allowlist.Enabled != nil && *cfg.Allowlist.Enabled
@@ -132,6 +151,20 @@ func (s *Server) ApplyRuntimeConfig(cfg *config.RuntimeConfig, merge bool) {
 			s.filter.SetEnabled(false)
 			s.filter.SetAllowlist(nil, nil)
 		}
+
+		if cfg.Policy != nil {
+			s.setPolicy(*cfg.Policy)
+		} else {
+			s.setPolicy(config.PolicyConfig{})
+		}
+	}
+}
+
+// setPolicy replaces the egress policy, keeping the current one if the new
+// one does not compile. Configs are validated before they get here.
+func (s *Server) setPolicy(policy config.PolicyConfig) {
+	if err := s.filter.SetPolicy(policy); err != nil {
+		s.logger.Error("failed to set policy", "error", err.Error())
 	}
 }
 
@@ -238,3 +271,8 @@ func (s *Server) GetCACertPath() string {
 func (s *Server) GetCache() *cache.Cache {
 	return s.cache
 }
+
+// GetRecorder returns the traffic recorder.
+func (s *Server) GetRecorder() *recorder.Recorder {
+	return s.recorder
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/things-go/go-socks5"
 
+	"github.com/obot-platform/discobot/proxy/internal/config"
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
 )
@@ -42,7 +43,7 @@ func (s *SOCKSProxy) ServeConn(conn net.Conn) error {
 	return s.server.ServeConn(conn)
 }
 
-// filterRule implements socks5.RuleSet for allowlist filtering.
+// filterRule implements socks5.RuleSet for allowlist and policy filtering.
 type filterRule struct {
 	filter *filter.Filter
 	logger *logger.Logger
@@ -57,10 +58,15 @@ func (r *filterRule) Allow(ctx context.Context, req *socks5.Request) (context.Co
 		host = req.DestAddr.IP.String()
 	}
 
-	allowed := r.filter.AllowHost(host)
-	r.logger.LogSOCKSConnect(host, req.DestAddr.Port, allowed)
+	freq := filter.Request{Protocol: config.ProtocolSOCKS, Host: host, Port: req.DestAddr.Port}
+	decision := r.filter.Check(freq)
+	logPolicyMatches(r.logger, freq, decision)
+	r.logger.LogSOCKSConnect(host, req.DestAddr.Port, decision.Allowed)
+	if !decision.Allowed {
+		r.logger.LogBlocked(host, decision.Reason)
+	}
 
-	return ctx, allowed
+	return ctx, decision.Allowed
 }
 
 // socksLogger adapts our logger to socks5.Logger interface.
@@ -0,0 +1,212 @@
+package recorder
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// HAR is an HTTP Archive (HAR 1.2) document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of a HAR document.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the application that created a HAR document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a request and its response.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest is a recorded request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is a recorded response.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is a header or query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a recorded request body.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent is a recorded response body.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings splits the time of an entry into phases; -1 means unknown.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// truncatedComment marks bodies recorded only in part.
+const truncatedComment = "truncated"
+
+// ToHAR converts entries to a HAR document. Entries still in progress are
+// left out.
+func ToHAR(entries []Entry) *HAR {
+	har := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "discobot-proxy", Version: "1.0"},
+		Entries: []HAREntry{},
+	}}
+	for _, e := range entries {
+		if e.Done {
+			har.Log.Entries = append(har.Log.Entries, toHAREntry(e))
+		}
+	}
+	return har
+}
+
+func toHAREntry(e Entry) HAREntry {
+	entry := HAREntry{
+		StartedDateTime: e.StartedAt.Format(time.RFC3339Nano),
+		Time:            e.Duration,
+		Request: HARRequest{
+			Method:      e.Method,
+			URL:         e.URL,
+			HTTPVersion: e.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(e.RequestHeaders),
+			QueryString: harQuery(e.URL),
+			HeadersSize: -1,
+			BodySize:    e.RequestSize,
+		},
+		Response: HARResponse{
+			Status:      e.Status,
+			StatusText:  http.StatusText(e.Status),
+			HTTPVersion: e.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(e.ResponseHeaders),
+			Content: HARContent{
+				Size:     e.ResponseSize,
+				MimeType: e.ResponseHeaders.Get("Content-Type"),
+			},
+			RedirectURL: e.ResponseHeaders.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    e.ResponseSize,
+		},
+		Timings: HARTimings{Send: 0, Wait: e.Wait, Receive: e.Duration - e.Wait},
+	}
+
+	if b := e.RequestBody; b != nil {
+		entry.Request.PostData = &HARPostData{
+			MimeType: e.RequestHeaders.Get("Content-Type"),
+			Text:     b.Text,
+		}
+		if b.Truncated {
+			entry.Request.PostData.Comment = truncatedComment
+		}
+	}
+	if b := e.ResponseBody; b != nil {
+		entry.Response.Content.Text = b.Text
+		entry.Response.Content.Encoding = b.Encoding
+		if b.Truncated {
+			entry.Response.Content.Comment = truncatedComment
+		}
+	}
+
+	switch {
+	case e.Blocked:
+		entry.Comment = "blocked by proxy"
+		entry.Timings = HARTimings{Send: -1, Wait: -1, Receive: -1}
+	case e.Cache != "":
+		entry.Comment = "cache " + e.Cache
+	}
+	if e.Error != "" {
+		entry.Comment = e.Error
+	}
+	if e.Status == 0 {
+		entry.Timings.Receive = -1
+	}
+	return entry
+}
+
+// harHeaders converts headers to HAR name/value pairs in a stable order.
+func harHeaders(headers http.Header) []HARNameValue {
+	pairs := []HARNameValue{}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// harQuery returns the query parameters of a URL.
+func harQuery(rawURL string) []HARNameValue {
+	pairs := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return pairs
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
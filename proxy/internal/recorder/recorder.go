@@ -0,0 +1,359 @@
+// Package recorder keeps a bounded record of the HTTP traffic through the
+// proxy for inspection.
+package recorder
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/obot-platform/discobot/proxy/internal/config"
+)
+
+// redacted replaces the values of sensitive headers.
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are always redacted.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"Api-Key",
+	"X-Auth-Token",
+	"Private-Token",
+}
+
+// Entry is a recorded request and its response. Entries are added when the
+// request starts and completed when its response body has been read.
+type Entry struct {
+	ID        uint64    `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	Proto          string      `json:"proto"`
+	RequestHeaders http.Header `json:"request_headers"`
+	RequestSize    int64       `json:"request_size"`
+	RequestBody    *Body       `json:"request_body,omitempty"`
+
+	Status          int         `json:"status,omitempty"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	ResponseSize    int64       `json:"response_size"`
+	ResponseBody    *Body       `json:"response_body,omitempty"`
+
+	Wait     float64 `json:"wait_ms"`     // Until the response headers arrived
+	Duration float64 `json:"duration_ms"` // Until the response body was read
+
+	Cache   string `json:"cache,omitempty"` // "HIT" for responses served from the cache
+	Blocked bool   `json:"blocked,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Done    bool   `json:"done"`
+}
+
+// Body is the start of a request or response body.
+type Body struct {
+	Text      string `json:"text"`
+	Encoding  string `json:"encoding,omitempty"` // "base64" for binary bodies
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// Filter selects recorded entries.
+type Filter struct {
+	Since uint64 // Only entries with a greater ID
+	Host  string // Only entries whose URL host contains Host
+	Limit int    // At most the Limit most recent entries, 0 for all
+}
+
+// Recorder records HTTP traffic in a ring buffer, dropping the oldest
+// entries once it is full.
+type Recorder struct {
+	mu          sync.Mutex
+	enabled     bool
+	entries     []*Entry // ring buffer, oldest at start
+	start       int
+	maxEntries  int
+	maxBodySize int
+	redact      map[string]bool
+	nextID      uint64
+}
+
+// New creates a Recorder from configuration.
+func New(cfg config.RecorderConfig) *Recorder {
+	r := &Recorder{nextID: 1}
+	r.Configure(cfg)
+	return r
+}
+
+// Configure applies configuration. Recorded entries are kept unless the
+// buffer shrinks below their number.
+func (r *Recorder) Configure(cfg config.RecorderConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.enabled = cfg.Enabled
+	r.maxBodySize = cfg.MaxBodySize
+	r.redact = make(map[string]bool)
+	for _, name := range sensitiveHeaders {
+		r.redact[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range cfg.RedactHeaders {
+		r.redact[http.CanonicalHeaderKey(name)] = true
+	}
+
+	entries := r.list(Filter{})
+	if len(entries) > max(cfg.MaxEntries, 0) {
+		entries = entries[len(entries)-max(cfg.MaxEntries, 0):]
+	}
+	r.entries, r.start, r.maxEntries = entries, 0, cfg.MaxEntries
+}
+
+// Start records a request, with the values of the redact headers (such as
+// those set by the header injector) and of sensitive headers replaced. The
+// request body is recorded as it is sent. It returns nil if recording is
+// disabled.
+func (r *Recorder) Start(req *http.Request, redact []string) *Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.enabled || r.maxEntries <= 0 {
+		return nil
+	}
+
+	e := &Entry{
+		StartedAt:      time.Now(),
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		Proto:          req.Proto,
+		RequestHeaders: r.redactHeaders(req.Header, redact),
+	}
+	r.add(e)
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &capture{body: req.Body, limit: r.maxBodySize, done: func(c *capture, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			e.RequestSize = c.size
+			e.RequestBody = c.recorded()
+		}}
+	}
+	return e
+}
+
+// Blocked records a request refused by the filter.
+func (r *Recorder) Blocked(req *http.Request) {
+	if e := r.Start(req, nil); e != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		e.Blocked, e.Done = true, true
+		e.Duration = milliseconds(time.Since(e.StartedAt))
+	}
+}
+
+// Response records the response to a request started with Start. The entry
+// is completed once the response body has been read or closed.
+func (r *Recorder) Response(e *Entry, resp *http.Response, redact []string) {
+	if e == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.Status = resp.StatusCode
+	e.ResponseHeaders = r.redactHeaders(resp.Header, redact)
+	e.Cache = resp.Header.Get("X-Cache")
+	e.Wait = milliseconds(time.Since(e.StartedAt))
+
+	if resp.Body == nil || resp.Body == http.NoBody {
+		e.Done, e.Duration = true, e.Wait
+		return
+	}
+	resp.Body = &capture{body: resp.Body, limit: r.maxBodySize, done: func(c *capture, err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		e.ResponseSize = c.size
+		e.ResponseBody = c.recorded()
+		if err != nil && e.Error == "" {
+			e.Error = err.Error()
+		}
+		e.Done = true
+		e.Duration = milliseconds(time.Since(e.StartedAt))
+	}}
+}
+
+// Fail completes an entry whose request failed without a response.
+func (r *Recorder) Fail(e *Entry, err error) {
+	if e == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.Done = true
+	e.Duration = milliseconds(time.Since(e.StartedAt))
+}
+
+// List returns copies of the recorded entries selected by f, oldest first.
+func (r *Recorder) List(f Filter) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.list(f)
+	result := make([]Entry, len(entries))
+	for i, e := range entries {
+		result[i] = *e
+	}
+	return result
+}
+
+// Clear removes all recorded entries.
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries, r.start = nil, 0
+}
+
+// list returns the entries selected by f, oldest first.
+func (r *Recorder) list(f Filter) []*Entry {
+	var entries []*Entry
+	for i := range r.entries {
+		e := r.entries[(r.start+i)%len(r.entries)]
+		if e.ID <= f.Since || (f.Host != "" && !strings.Contains(hostOf(e.URL), f.Host)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries
+}
+
+// add adds an entry, replacing the oldest one if the buffer is full.
+func (r *Recorder) add(e *Entry) {
+	e.ID = r.nextID
+	r.nextID++
+
+	if len(r.entries) < r.maxEntries {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % len(r.entries)
+}
+
+// redactHeaders returns a copy of headers with the values of sensitive
+// headers and those named in extra replaced.
+func (r *Recorder) redactHeaders(headers http.Header, extra []string) http.Header {
+	result := headers.Clone()
+	if result == nil {
+		result = http.Header{}
+	}
+	for name, values := range result {
+		if r.redact[name] {
+			result[name] = redactValues(values)
+		}
+	}
+	for _, name := range extra {
+		name = http.CanonicalHeaderKey(name)
+		if values, ok := result[name]; ok {
+			result[name] = redactValues(values)
+		}
+	}
+	return result
+}
+
+func redactValues(values []string) []string {
+	result := make([]string, len(values))
+	for i := range result {
+		result[i] = redacted
+	}
+	return result
+}
+
+// hostOf returns the host of a recorded URL.
+func hostOf(rawURL string) string {
+	_, rest, found := strings.Cut(rawURL, "://")
+	if !found {
+		rest = rawURL
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return host
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// capture is a body that counts what is read from it and keeps the first
+// limit bytes. done is called once, at the end of the body or when it is
+// closed early.
+type capture struct {
+	body     io.ReadCloser
+	limit    int
+	buf      []byte
+	size     int64
+	done     func(c *capture, err error)
+	finished bool
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	c.size += int64(n)
+	if keep := min(n, c.limit-len(c.buf)); keep > 0 {
+		c.buf = append(c.buf, p[:keep]...)
+	}
+	switch {
+	case err == io.EOF:
+		c.finish(nil)
+	case err != nil:
+		c.finish(err)
+	}
+	return n, err
+}
+
+func (c *capture) Close() error {
+	c.finish(nil)
+	return c.body.Close()
+}
+
+func (c *capture) finish(err error) {
+	if c.finished {
+		return
+	}
+	c.finished = true
+	c.done(c, err)
+}
+
+// recorded returns the kept start of the body, or nil if bodies are not
+// recorded.
+func (c *capture) recorded() *Body {
+	if c.limit <= 0 || c.size == 0 {
+		return nil
+	}
+	b := &Body{Truncated: c.size > int64(len(c.buf))}
+	text := c.buf
+	if b.Truncated {
+		// Don't mistake a character cut in half for binary data
+		for i := 0; i < utf8.UTFMax-1 && len(text) > 0 && !utf8.Valid(text); i++ {
+			text = text[:len(text)-1]
+		}
+	}
+	if utf8.Valid(text) {
+		b.Text = string(text)
+	} else {
+		b.Text, b.Encoding = base64.StdEncoding.EncodeToString(c.buf), "base64"
+	}
+	return b
+}
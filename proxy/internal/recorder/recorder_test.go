@@ -0,0 +1,263 @@
+package recorder
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/proxy/internal/config"
+)
+
+func newRecorder(maxEntries, maxBodySize int) *Recorder {
+	return New(config.RecorderConfig{Enabled: true, MaxEntries: maxEntries, MaxBodySize: maxBodySize})
+}
+
+// roundTrip records a request and a response with the given bodies, and
+// reads both to the end.
+func roundTrip(t *testing.T, r *Recorder, rawURL, reqBody, respBody string) *Entry {
+	t.Helper()
+
+	var body io.Reader
+	if reqBody != "" {
+		body = strings.NewReader(reqBody)
+	}
+	req, err := http.NewRequest("POST", rawURL, body)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	e := r.Start(req, nil)
+	if req.Body != nil {
+		_, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader(respBody)),
+	}
+	r.Response(e, resp, nil)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return e
+}
+
+func TestRecorder_RingBuffer(t *testing.T) {
+	r := newRecorder(3, 0)
+	for i := range 5 {
+		roundTrip(t, r, "http://example.com/"+string(rune('a'+i)), "", "ok")
+	}
+
+	entries := r.List(Filter{})
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	for i, e := range entries {
+		if want := uint64(i + 3); e.ID != want {
+			t.Errorf("entry %d: got ID %d, want %d", i, e.ID, want)
+		}
+	}
+
+	// Shrinking keeps the most recent entries
+	r.Configure(config.RecorderConfig{Enabled: true, MaxEntries: 2})
+	entries = r.List(Filter{})
+	if len(entries) != 2 || entries[0].ID != 4 || entries[1].ID != 5 {
+		t.Errorf("unexpected entries after shrinking: %+v", entries)
+	}
+
+	r.Clear()
+	if entries := r.List(Filter{}); len(entries) != 0 {
+		t.Errorf("expected no entries after Clear, got %d", len(entries))
+	}
+}
+
+func TestRecorder_Filter(t *testing.T) {
+	r := newRecorder(10, 0)
+	roundTrip(t, r, "https://registry.npmjs.org/left-pad", "", "")
+	roundTrip(t, r, "https://api.github.com/user", "", "")
+	roundTrip(t, r, "https://registry.npmjs.org/react", "", "")
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []uint64
+	}{
+		{name: "all", want: []uint64{1, 2, 3}},
+		{name: "since", filter: Filter{Since: 1}, want: []uint64{2, 3}},
+		{name: "host", filter: Filter{Host: "npmjs"}, want: []uint64{1, 3}},
+		{name: "limit keeps most recent", filter: Filter{Limit: 2}, want: []uint64{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint64
+			for _, e := range r.List(tt.filter) {
+				got = append(got, e.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got IDs %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got IDs %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecorder_Redaction(t *testing.T) {
+	r := New(config.RecorderConfig{Enabled: true, MaxEntries: 10, RedactHeaders: []string{"x-session-secret"}})
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Injected-Token", "injected-secret")
+	req.Header.Set("X-Session-Secret", "configured-secret")
+	req.Header.Set("Accept", "application/json")
+	e := r.Start(req, []string{"x-injected-token"})
+	r.Response(e, &http.Response{StatusCode: 200, Header: http.Header{"Set-Cookie": {"session=abc"}}}, nil)
+
+	entry := r.List(Filter{})[0]
+	for _, name := range []string{"Authorization", "X-Injected-Token", "X-Session-Secret"} {
+		if got := entry.RequestHeaders.Get(name); got != redacted {
+			t.Errorf("%s: got %q, want it redacted", name, got)
+		}
+	}
+	if got := entry.RequestHeaders.Get("Accept"); got != "application/json" {
+		t.Errorf("Accept: got %q, want it kept", got)
+	}
+	if got := entry.ResponseHeaders.Get("Set-Cookie"); got != redacted {
+		t.Errorf("Set-Cookie: got %q, want it redacted", got)
+	}
+
+	// The request itself is untouched
+	if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("request header changed to %q", got)
+	}
+}
+
+func TestRecorder_Bodies(t *testing.T) {
+	t.Run("not recorded by default", func(t *testing.T) {
+		r := newRecorder(10, 0)
+		roundTrip(t, r, "http://example.com/", "request", "response")
+
+		e := r.List(Filter{})[0]
+		if e.RequestBody != nil || e.ResponseBody != nil {
+			t.Errorf("expected no bodies, got %+v and %+v", e.RequestBody, e.ResponseBody)
+		}
+		if e.RequestSize != 7 || e.ResponseSize != 8 {
+			t.Errorf("sizes: got %d and %d, want 7 and 8", e.RequestSize, e.ResponseSize)
+		}
+		if !e.Done || e.Status != 200 {
+			t.Errorf("expected a completed 200 entry, got %+v", e)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		r := newRecorder(10, 4)
+		roundTrip(t, r, "http://example.com/", "request", "aéé")
+
+		e := r.List(Filter{})[0]
+		if e.RequestBody == nil || e.RequestBody.Text != "requ" || !e.RequestBody.Truncated {
+			t.Errorf("unexpected request body: %+v", e.RequestBody)
+		}
+		// The cut "é" is dropped rather than making the body look binary
+		if e.ResponseBody == nil || e.ResponseBody.Text != "aé" || e.ResponseBody.Encoding != "" {
+			t.Errorf("unexpected response body: %+v", e.ResponseBody)
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		r := newRecorder(10, 64)
+		roundTrip(t, r, "http://example.com/", "", "\xff\xfe")
+
+		e := r.List(Filter{})[0]
+		if e.ResponseBody == nil || e.ResponseBody.Encoding != "base64" || e.ResponseBody.Text != "//4=" {
+			t.Errorf("unexpected response body: %+v", e.ResponseBody)
+		}
+	})
+}
+
+func TestRecorder_Lifecycle(t *testing.T) {
+	r := newRecorder(10, 0)
+
+	req, _ := http.NewRequest("GET", "http://example.com/slow", nil)
+	e := r.Start(req, nil)
+	resp := &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("data"))}
+	r.Response(e, resp, nil)
+	if got := r.List(Filter{})[0]; got.Done {
+		t.Error("expected entry in progress until the body is read")
+	}
+	_, _ = io.ReadAll(resp.Body)
+	if got := r.List(Filter{})[0]; !got.Done {
+		t.Error("expected entry done once the body was read")
+	}
+
+	failed, _ := http.NewRequest("GET", "http://unreachable.example/", nil)
+	r.Fail(r.Start(failed, nil), errors.New("connection refused"))
+	blocked, _ := http.NewRequest("GET", "http://blocked.example/", nil)
+	r.Blocked(blocked)
+
+	entries := r.List(Filter{})
+	if e := entries[1]; !e.Done || e.Error != "connection refused" {
+		t.Errorf("unexpected failed entry: %+v", e)
+	}
+	if e := entries[2]; !e.Done || !e.Blocked {
+		t.Errorf("unexpected blocked entry: %+v", e)
+	}
+}
+
+func TestRecorder_Disabled(t *testing.T) {
+	r := New(config.RecorderConfig{Enabled: false, MaxEntries: 10})
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	e := r.Start(req, nil)
+	if e != nil {
+		t.Fatal("expected no entry while disabled")
+	}
+	// Completing a nil entry is a no-op
+	r.Response(e, &http.Response{StatusCode: 200, Header: http.Header{}}, nil)
+	r.Fail(e, nil)
+	r.Blocked(req)
+
+	if entries := r.List(Filter{}); len(entries) != 0 {
+		t.Errorf("expected no entries, got %d", len(entries))
+	}
+}
+
+func TestToHAR(t *testing.T) {
+	r := newRecorder(10, 64)
+	roundTrip(t, r, "https://api.example.com/items?page=2", `{"name":"x"}`, "created")
+	req, _ := http.NewRequest("GET", "https://api.example.com/pending", nil)
+	r.Start(req, nil)
+
+	har := ToHAR(r.List(Filter{}))
+	if har.Log.Version != "1.2" {
+		t.Errorf("version: got %q, want 1.2", har.Log.Version)
+	}
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("expected the completed entry only, got %d", len(har.Log.Entries))
+	}
+
+	e := har.Log.Entries[0]
+	if e.Request.Method != "POST" || e.Request.URL != "https://api.example.com/items?page=2" {
+		t.Errorf("unexpected request: %+v", e.Request)
+	}
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0] != (HARNameValue{Name: "page", Value: "2"}) {
+		t.Errorf("unexpected query string: %+v", e.Request.QueryString)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.Text != `{"name":"x"}` {
+		t.Errorf("unexpected post data: %+v", e.Request.PostData)
+	}
+	if e.Response.Status != 200 || e.Response.StatusText != "OK" {
+		t.Errorf("unexpected response status: %d %q", e.Response.Status, e.Response.StatusText)
+	}
+	if e.Response.Content.Text != "created" || e.Response.Content.MimeType != "text/plain" || e.Response.Content.Size != 7 {
+		t.Errorf("unexpected content: %+v", e.Response.Content)
+	}
+	if e.Timings.Wait < 0 || e.Timings.Receive < 0 {
+		t.Errorf("unexpected timings: %+v", e.Timings)
+	}
+}
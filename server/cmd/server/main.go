@@ -27,9 +27,11 @@ import (
 	"github.com/obot-platform/discobot/server/internal/logfile"
 	"github.com/obot-platform/discobot/server/internal/middleware"
 	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/pgnotify"
 	"github.com/obot-platform/discobot/server/internal/routes"
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 	"github.com/obot-platform/discobot/server/internal/sandbox/docker"
+	"github.com/obot-platform/discobot/server/internal/sandbox/kubernetes"
 	"github.com/obot-platform/discobot/server/internal/sandbox/local"
 	"github.com/obot-platform/discobot/server/internal/sandbox/vm"
 	"github.com/obot-platform/discobot/server/internal/sandbox/vz"
@@ -96,23 +98,55 @@ func main() {
 	// Initialize git provider (required)
 	// Create workspace source for git provider to lookup workspace info
 	workspaceSource := git.NewStoreWorkspaceSource(s)
-	gitProvider, err := git.NewLocalProvider(cfg.WorkspaceDir, git.WithWorkspaceSource(workspaceSource))
+	// The credential service provides the project's keys and tokens for
+	// cloning and fetching private repositories
+	gitCredentialSource, err := service.NewCredentialService(s, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create credential service: %v", err)
+	}
+	gitOpts := []git.LocalProviderOption{
+		git.WithWorkspaceSource(workspaceSource),
+		git.WithCredentialSource(gitCredentialSource),
+		git.WithCloneDepth(cfg.GitCloneDepth),
+	}
+	var gitProvider git.Provider
+	if cfg.GitProvider == "go" {
+		gitProvider, err = git.NewGoGitProvider(cfg.WorkspaceDir, gitOpts...)
+	} else {
+		gitProvider, err = git.NewLocalProvider(cfg.WorkspaceDir, gitOpts...)
+	}
 	if err != nil {
 		log.Fatalf("Failed to initialize git provider: %v", err)
 	}
-	log.Printf("Git provider initialized at %s", cfg.WorkspaceDir)
+	log.Printf("Git provider (%s) initialized at %s", cfg.GitProvider, cfg.WorkspaceDir)
 
 	// Initialize sandbox providers
 	// Create a manager that can route to different providers based on workspace configuration
 	sandboxManager := sandbox.NewManager()
 
 	// Create event poller and broker for SSE (needed by startup manager)
-	eventPoller := events.NewPoller(s, events.DefaultPollerConfig())
+	pollerCfg := events.DefaultPollerConfig()
+	pollerCfg.PollInterval = cfg.EventPollInterval
+	eventPoller := events.NewPoller(s, pollerCfg)
 	if err := eventPoller.Start(context.Background()); err != nil {
 		log.Fatalf("Failed to start event poller: %v", err)
 	}
 	eventBroker := events.NewBroker(s, eventPoller)
 
+	// Postgres notifications wake the pollers of every node when another node
+	// enqueues a job or publishes an event. Handlers are registered below and
+	// the listener is started once the dispatcher exists.
+	var notifyListener *pgnotify.Listener
+	if cfg.DatabaseNotify {
+		if cfg.DatabaseDriver == "postgres" {
+			notifyListener = pgnotify.NewListener(cfg.CleanDSN())
+			notifyListener.Handle(pgnotify.ChannelEvents, eventPoller.NotifyNewEvent)
+			eventBroker.SetNotifyFunc(pgnotify.Notifier(s, pgnotify.ChannelEvents))
+		} else {
+			log.Printf("DATABASE_NOTIFY requires PostgreSQL, ignoring for %s", cfg.DatabaseDriver)
+		}
+	}
+
 	// Create startup task manager for tracking long-running startup operations
 	// Use the default project ID ("local") for startup events
 	systemManager := startup.NewSystemManager(eventBroker, model.DefaultProjectID)
@@ -171,6 +205,16 @@ func main() {
 		}
 	}
 
+	// Initialize Kubernetes provider (only if enabled via config)
+	if cfg.KubernetesEnabled {
+		if k8sProvider, k8sErr := kubernetes.NewProvider(cfg, sessionProjectResolver); k8sErr != nil {
+			log.Printf("Warning: Failed to initialize Kubernetes sandbox provider: %v", k8sErr)
+		} else {
+			sandboxManager.RegisterProvider("kubernetes", k8sProvider)
+			log.Printf("Kubernetes sandbox provider initialized (namespace: %s)", cfg.KubernetesNamespace)
+		}
+	}
+
 	// Create provider proxy that routes based on workspace configuration
 	// The proxy will look up the session's workspace and use its provider setting
 	var sandboxProvider sandbox.Provider
@@ -206,6 +250,14 @@ func main() {
 
 	// Create job queue early so it can be passed to services
 	jobQueue := jobs.NewQueue(s, cfg)
+	jobQueue.SetEventBroker(eventBroker)
+
+	// Queue a webhook delivery for every event this server publishes
+	webhookSvc, err := service.NewWebhookService(s, cfg, jobQueue)
+	if err != nil {
+		log.Fatalf("Failed to create webhook service: %v", err)
+	}
+	eventBroker.SetPublishHook(webhookSvc.HandleEvent)
 
 	// Start sandbox watcher to sync session states with sandbox states
 	// This handles external changes (e.g., Docker containers deleted outside Discobot)
@@ -266,6 +318,10 @@ func main() {
 		// Register workspace init executor
 		workspaceSvc := service.NewWorkspaceService(s, gitProvider, eventBroker)
 		disp.RegisterExecutor(dispatcher.NewWorkspaceInitExecutor(workspaceSvc))
+		disp.RegisterExecutor(dispatcher.NewWebhookDeliveryExecutor(webhookSvc))
+		disp.RegisterExecutor(dispatcher.NewEventRetentionExecutor(s))
+		prSvc := service.NewPullRequestService(s, service.NewGitService(s, gitProvider), service.NewForgeRegistry(cfg), eventBroker)
+		disp.RegisterExecutor(dispatcher.NewSessionPullRequestExecutor(prSvc))
 
 		// Register session init, delete, and commit executors if sandbox provider is available
 		if sandboxProvider != nil {
@@ -281,6 +337,12 @@ func main() {
 			disp.RegisterExecutor(dispatcher.NewSessionInitExecutor(sessionSvc))
 			disp.RegisterExecutor(dispatcher.NewSessionDeleteExecutor(sessionSvc))
 			disp.RegisterExecutor(dispatcher.NewSessionCommitExecutor(sessionSvc))
+
+			// Register schedule run executor and let the leader evaluate schedules
+			chatSvc := service.NewChatService(s, sessionSvc, jobQueue, eventBroker, dispSandboxSvc, gitSvc)
+			scheduleSvc := service.NewScheduleService(s, chatSvc, jobQueue)
+			disp.RegisterExecutor(dispatcher.NewScheduleRunExecutor(scheduleSvc))
+			disp.SetScheduler(scheduleSvc)
 		}
 
 		disp.Start(context.Background())
@@ -378,10 +440,27 @@ func main() {
 	// Initialize handlers
 	h := handler.New(s, cfg, gitProvider, sandboxProvider, sandboxManager, eventBroker, jobQueue, systemManager)
 
-	// Wire up job queue notification to dispatcher for immediate execution
+	// Wire up job queue notification to dispatcher for immediate execution.
+	// With Postgres notifications, new jobs also wake the leader when it runs
+	// on another node.
+	notifyNewJob := func() {}
 	if disp != nil {
-		h.JobQueue().SetNotifyFunc(disp.NotifyNewJob)
+		notifyNewJob = disp.NotifyNewJob
+		h.JobService().SetCanceller(disp)
 	}
+	if notifyListener != nil {
+		if disp != nil {
+			notifyListener.Handle(pgnotify.ChannelJobs, disp.NotifyNewJob)
+		}
+		notifyLocal, notifyCluster := notifyNewJob, pgnotify.Notifier(s, pgnotify.ChannelJobs)
+		notifyNewJob = func() {
+			notifyLocal()
+			notifyCluster()
+		}
+		notifyListener.Start(context.Background())
+		log.Println("Postgres notification listener started")
+	}
+	h.JobQueue().SetNotifyFunc(notifyNewJob)
 
 	// Route registry for metadata
 	reg := routes.GetRegistry()
@@ -949,6 +1028,28 @@ func main() {
 							Group:       "Sessions",
 							Description: "Commit session changes",
 							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+							Body:        map[string]any{"pullRequest": true},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/pull-request",
+						Handler: h.CreatePullRequest,
+						Meta: routes.Meta{
+							Group:       "Sessions",
+							Description: "Push session commits and open a pull request",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/fork",
+						Handler: h.ForkSession,
+						Meta: routes.Meta{
+							Group:       "Sessions",
+							Description: "Fork session from a message",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+							Body:        map[string]any{"id": "def456", "messageId": "msg-1"},
 						},
 					})
 
@@ -1053,6 +1154,218 @@ func main() {
 						},
 					})
 
+					sidReg.Register(r, routes.Route{
+						Method: "GET", Pattern: "/export",
+						Handler: h.ExportSession,
+						Meta: routes.Meta{
+							Group:       "Files",
+							Description: "Download session changes",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "format", In: "query", Example: "mbox"},
+							},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/import",
+						Handler: h.ImportSession,
+						Meta: routes.Meta{
+							Group:       "Files",
+							Description: "Apply a patch, ref or session's commits",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+							},
+							Body: map[string]any{"ref": "refs/pull/12/head", "notify": true},
+						},
+					})
+
+					// HTTP traffic recorded by the sandbox proxy
+					sidReg.Register(r, routes.Route{
+						Method: "GET", Pattern: "/network",
+						Handler: h.ListNetwork,
+						Meta: routes.Meta{
+							Group:       "Network",
+							Description: "List recorded HTTP requests",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "since", In: "query", Example: "0"},
+								{Name: "host", In: "query", Example: "github.com"},
+								{Name: "limit", In: "query", Example: "100"},
+							},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "GET", Pattern: "/network/har",
+						Handler: h.ExportNetworkHAR,
+						Meta: routes.Meta{
+							Group:       "Network",
+							Description: "Download recorded HTTP requests as HAR",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "DELETE", Pattern: "/network",
+						Handler: h.ClearNetwork,
+						Meta: routes.Meta{
+							Group:       "Network",
+							Description: "Clear recorded HTTP requests",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						},
+					})
+
+					// Review threads on the session diff
+					sidReg.Register(r, routes.Route{
+						Method: "GET", Pattern: "/reviews",
+						Handler: h.ListReviewThreads,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "List review threads",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/reviews",
+						Handler: h.CreateReviewThread,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Comment on diff lines",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+							Body:        map[string]any{"path": "main.go", "side": "new", "startLine": 10, "endLine": 12, "body": "Handle the error here"},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/reviews/send",
+						Handler: h.SendReview,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Send pending comments to the agent",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+							Body:        map[string]any{"body": "Looks good overall"},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "PUT", Pattern: "/reviews/{threadId}",
+						Handler: h.UpdateReviewThread,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Resolve or reopen review thread",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "threadId", Example: "thread-1"},
+							},
+							Body: map[string]any{"resolved": true},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "DELETE", Pattern: "/reviews/{threadId}",
+						Handler: h.DeleteReviewThread,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Delete review thread",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "threadId", Example: "thread-1"},
+							},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/reviews/{threadId}/comments",
+						Handler: h.AddReviewComment,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Reply to review thread",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "threadId", Example: "thread-1"},
+							},
+							Body: map[string]any{"body": "Also add a test"},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "PUT", Pattern: "/reviews/{threadId}/comments/{commentId}",
+						Handler: h.UpdateReviewComment,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Edit pending review comment",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "threadId", Example: "thread-1"},
+								{Name: "commentId", Example: "comment-1"},
+							},
+							Body: map[string]any{"body": "Handle the error and log it"},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "DELETE", Pattern: "/reviews/{threadId}/comments/{commentId}",
+						Handler: h.DeleteReviewComment,
+						Meta: routes.Meta{
+							Group:       "Reviews",
+							Description: "Delete review comment",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "threadId", Example: "thread-1"},
+								{Name: "commentId", Example: "comment-1"},
+							},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "GET", Pattern: "/checkpoints",
+						Handler: h.ListCheckpoints,
+						Meta: routes.Meta{
+							Group:       "Checkpoints",
+							Description: "List workspace checkpoints",
+							Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "GET", Pattern: "/checkpoints/{messageId}/diff",
+						Handler: h.GetCheckpointDiff,
+						Meta: routes.Meta{
+							Group:       "Checkpoints",
+							Description: "Diff checkpoint against current files",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "messageId", Example: "msg-1"},
+								{Name: "path", In: "query", Example: "README.md"},
+							},
+						},
+					})
+
+					sidReg.Register(r, routes.Route{
+						Method: "POST", Pattern: "/checkpoints/{messageId}/restore",
+						Handler: h.RestoreCheckpoint,
+						Meta: routes.Meta{
+							Group:       "Checkpoints",
+							Description: "Restore files to checkpoint",
+							Params: []routes.Param{
+								{Name: "projectId", Example: "local"},
+								{Name: "sessionId", Example: "abc123"},
+								{Name: "messageId", Example: "msg-1"},
+							},
+						},
+					})
+
 					sidReg.Register(r, routes.Route{
 						Method: "GET", Pattern: "/messages",
 						Handler: h.ListMessages,
@@ -1414,6 +1727,42 @@ func main() {
 				})
 			})
 
+			// Git credentials for private repositories
+			r.Route("/git-credentials", func(r chi.Router) {
+				gitCredReg := projReg.WithPrefix("/git-credentials")
+
+				gitCredReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/",
+					Handler: h.ListGitCredentials,
+					Meta: routes.Meta{
+						Group:       "Credentials",
+						Description: "List git credentials",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+					},
+				})
+
+				gitCredReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/",
+					Handler: h.SetGitCredential,
+					Meta: routes.Meta{
+						Group:       "Credentials",
+						Description: "Create or update git credential",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+						Body:        map[string]any{"host": "github.com", "authType": "https_token", "token": "ghp_...", "forwardToSandbox": true},
+					},
+				})
+
+				gitCredReg.Register(r, routes.Route{
+					Method: "DELETE", Pattern: "/{credentialId}",
+					Handler: h.DeleteGitCredential,
+					Meta: routes.Meta{
+						Group:       "Credentials",
+						Description: "Delete git credential",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "credentialId", Example: "cred-123"}},
+					},
+				})
+			})
+
 			// Chat endpoint
 			projReg.Register(r, routes.Route{
 				Method: "POST", Pattern: "/chat",
@@ -1481,6 +1830,323 @@ func main() {
 					},
 				},
 			})
+
+			// Schedules
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/schedules",
+				Handler: h.ListSchedules,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "List schedules",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/schedules",
+				Handler: h.CreateSchedule,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "Create schedule",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+					},
+					Body: map[string]any{"name": "Nightly dependency update", "cron": "0 2 * * *", "timezone": "UTC", "workspaceId": "ws-1", "agentId": "agent-1", "promptTemplate": "Update dependencies and run the tests ({{.Date}})", "autoCommit": true},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/schedules/{scheduleId}",
+				Handler: h.GetSchedule,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "Get schedule",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "scheduleId", Example: "sched-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "PUT", Pattern: "/schedules/{scheduleId}",
+				Handler: h.UpdateSchedule,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "Update schedule",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "scheduleId", Example: "sched-1"},
+					},
+					Body: map[string]any{"enabled": false},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "DELETE", Pattern: "/schedules/{scheduleId}",
+				Handler: h.DeleteSchedule,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "Delete schedule",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "scheduleId", Example: "sched-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/schedules/{scheduleId}/runs",
+				Handler: h.ListScheduleRuns,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "List schedule runs",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "scheduleId", Example: "sched-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/schedules/{scheduleId}/run",
+				Handler: h.TriggerSchedule,
+				Meta: routes.Meta{
+					Group:       "Schedules",
+					Description: "Run schedule now",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "scheduleId", Example: "sched-1"},
+					},
+				},
+			})
+
+			// Webhooks
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/webhooks",
+				Handler: h.ListWebhooks,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "List webhooks",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/webhooks",
+				Handler: h.CreateWebhook,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "Create webhook",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+					},
+					Body: map[string]any{"url": "https://example.com/hooks/discobot", "eventTypes": []string{"session_updated", "job_completed"}},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/webhooks/{webhookId}",
+				Handler: h.GetWebhook,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "Get webhook",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "webhookId", Example: "webhook-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "PUT", Pattern: "/webhooks/{webhookId}",
+				Handler: h.UpdateWebhook,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "Update webhook",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "webhookId", Example: "webhook-1"},
+					},
+					Body: map[string]any{"enabled": false},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "DELETE", Pattern: "/webhooks/{webhookId}",
+				Handler: h.DeleteWebhook,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "Delete webhook",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "webhookId", Example: "webhook-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/webhooks/{webhookId}/deliveries",
+				Handler: h.ListWebhookDeliveries,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "List webhook deliveries",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "webhookId", Example: "webhook-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/webhooks/{webhookId}/deliveries/{deliveryId}",
+				Handler: h.GetWebhookDelivery,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "Get webhook delivery",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "webhookId", Example: "webhook-1"},
+						{Name: "deliveryId", Example: "delivery-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver",
+				Handler: h.RedeliverWebhook,
+				Meta: routes.Meta{
+					Group:       "Webhooks",
+					Description: "Redeliver webhook delivery",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "webhookId", Example: "webhook-1"},
+						{Name: "deliveryId", Example: "delivery-1"},
+					},
+				},
+			})
+
+			// Jobs
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/jobs",
+				Handler: h.ListJobs,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "List jobs",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "type", In: "query", Example: "session_init"},
+						{Name: "status", In: "query", Example: "failed"},
+						{Name: "resourceType", In: "query", Example: "session"},
+						{Name: "resourceId", In: "query"},
+						{Name: "limit", In: "query", Example: "100"},
+						{Name: "offset", In: "query"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/jobs/{jobId}",
+				Handler: h.GetJob,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "Get job",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "jobId", Example: "job-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/jobs/{jobId}/retry",
+				Handler: h.RetryJob,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "Retry failed or cancelled job",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "jobId", Example: "job-1"},
+					},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/jobs/{jobId}/cancel",
+				Handler: h.CancelJob,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "Cancel pending or running job",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "jobId", Example: "job-1"},
+					},
+				},
+			})
+		})
+
+		// Server administration (admin users only)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.Admin(cfg))
+			adminReg := apiReg.WithPrefix("/admin")
+
+			adminReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/jobs",
+				Handler: h.AdminListJobs,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "List jobs",
+					Params: []routes.Param{
+						{Name: "projectId", In: "query"},
+						{Name: "type", In: "query", Example: "session_init"},
+						{Name: "status", In: "query", Example: "failed"},
+						{Name: "resourceType", In: "query", Example: "session"},
+						{Name: "resourceId", In: "query"},
+						{Name: "limit", In: "query", Example: "100"},
+						{Name: "offset", In: "query"},
+					},
+				},
+			})
+
+			adminReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/jobs/{jobId}",
+				Handler: h.AdminGetJob,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "Get job",
+					Params: []routes.Param{
+						{Name: "jobId", Example: "job-1"},
+					},
+				},
+			})
+
+			adminReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/jobs/{jobId}/retry",
+				Handler: h.AdminRetryJob,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "Retry failed or cancelled job",
+					Params: []routes.Param{
+						{Name: "jobId", Example: "job-1"},
+					},
+				},
+			})
+
+			adminReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/jobs/{jobId}/cancel",
+				Handler: h.AdminCancelJob,
+				Meta: routes.Meta{
+					Group:       "Jobs",
+					Description: "Cancel pending or running job",
+					Params: []routes.Param{
+						{Name: "jobId", Example: "job-1"},
+					},
+				},
+			})
 		})
 	})
 
@@ -1588,7 +2254,10 @@ func main() {
 		disp.Stop()
 	}
 
-	// Stop event poller
+	// Stop notification listener and event poller
+	if notifyListener != nil {
+		notifyListener.Stop()
+	}
 	eventPoller.Stop()
 
 	// Close handler resources (stops Codex callback server, etc.)
@@ -16,6 +16,10 @@ import (
 
 const appName = "discobot"
 
+// DefaultKubernetesNamespace is the namespace of sandbox pods and volumes
+// when KUBERNETES_NAMESPACE is not set.
+const DefaultKubernetesNamespace = "discobot"
+
 // DefaultSandboxImage returns the default sandbox image for sessions,
 // tagged with the current build version.
 func DefaultSandboxImage() string {
@@ -39,16 +43,41 @@ type Config struct {
 	// Database
 	DatabaseDSN    string
 	DatabaseDriver string // "postgres" or "sqlite3", auto-detected from DSN
+	DatabaseNotify bool   // Wake job and event pollers on all nodes via Postgres LISTEN/NOTIFY (default: false)
+
+	// Event poller
+	EventPollInterval time.Duration // How often to poll for new project events (default: 2s, 30s with DatabaseNotify)
+
+	// Event retention (0 disables a limit)
+	EventRetentionDays          int           // Delete project events older than this many days (default: 0, disabled)
+	EventRetentionMaxPerProject int           // Keep at most this many events per project (default: 0, disabled)
+	EventRetentionInterval      time.Duration // How often the leader enqueues the retention job (default: 1h)
 
 	// Authentication
-	AuthEnabled bool // If false, uses anonymous user (default: false)
+	AuthEnabled bool     // If false, uses anonymous user (default: false)
+	AdminEmails []string // Users allowed to use the server-wide admin API when auth is enabled
 
 	// Security
 	SessionSecret []byte
 	EncryptionKey []byte // 32 bytes for AES-256-GCM
 
+	// Give sandboxes placeholder tokens that the sandbox proxy swaps for the
+	// real provider credentials on requests to the provider's hosts (default: false)
+	CredentialPlaceholders bool
+
+	// Allow webhooks to loopback, link-local and private addresses (default: false)
+	WebhookAllowPrivateNetworks bool
+
 	// Workspaces and Git
-	WorkspaceDir string // Base directory for workspaces and git cache
+	WorkspaceDir  string // Base directory for workspaces and git cache
+	GitCloneDepth int    // Commits of history kept per branch of git workspaces (0 = full history)
+	GitProvider   string // Git implementation for workspace operations: "cli" (git binary) or "go" (go-git, still running git to clone, fetch, push and merge)
+
+	// Pull request forges (a forge is enabled when its token is set)
+	GitHubToken  string // Token for pushing branches and opening pull requests on GitHub
+	GitHubAPIURL string // GitHub API URL (default: https://api.github.com, set for GitHub Enterprise)
+	GiteaURL     string // Base URL of a Gitea or Forgejo instance
+	GiteaToken   string // Token for pushing branches and opening pull requests on GiteaURL
 
 	// Sandbox runtime settings
 	SandboxImage       string        // Default sandbox image
@@ -75,6 +104,14 @@ type Config struct {
 	LocalProviderEnabled bool   // Enable local sandbox provider (default: false)
 	LocalAgentBinary     string // Path to agent API binary for local provider (default: obot-agent-api in PATH)
 
+	// Kubernetes provider settings
+	KubernetesEnabled        bool          // Enable Kubernetes sandbox provider (default: false)
+	KubernetesKubeconfig     string        // Path to kubeconfig (empty = in-cluster config)
+	KubernetesNamespace      string        // Namespace for sandbox pods and volumes (default: discobot)
+	KubernetesStorageClass   string        // Storage class for data volumes (empty = cluster default)
+	KubernetesDataVolumeSize string        // Default data volume size (default: 10Gi)
+	KubernetesStartTimeout   time.Duration // How long Start waits for a pod to become ready (default: 5m)
+
 	// SSH server settings
 	SSHEnabled     bool   // Enable SSH server (default: true)
 	SSHPort        int    // SSH server port (default: 3333)
@@ -128,9 +165,23 @@ func Load() (*Config, error) {
 	// Database - defaults to XDG_DATA_HOME/discobot/discobot.db
 	cfg.DatabaseDSN = getEnv("DATABASE_DSN", "sqlite3://"+filepath.Join(xdg.DataHome, appName, "discobot.db"))
 	cfg.DatabaseDriver = detectDriver(cfg.DatabaseDSN)
+	cfg.DatabaseNotify = getEnvBool("DATABASE_NOTIFY", false)
+
+	// Event poller - notifications make polling a fallback, so poll less often
+	eventPollInterval := 2 * time.Second
+	if cfg.DatabaseNotify && cfg.DatabaseDriver == "postgres" {
+		eventPollInterval = 30 * time.Second
+	}
+	cfg.EventPollInterval = getEnvDuration("EVENT_POLL_INTERVAL", eventPollInterval)
+
+	// Event retention
+	cfg.EventRetentionDays = getEnvInt("EVENT_RETENTION_DAYS", 0)
+	cfg.EventRetentionMaxPerProject = getEnvInt("EVENT_RETENTION_MAX_PER_PROJECT", 0)
+	cfg.EventRetentionInterval = getEnvDuration("EVENT_RETENTION_INTERVAL", time.Hour)
 
 	// Authentication - defaults to disabled (anonymous user mode)
 	cfg.AuthEnabled = getEnvBool("AUTH_ENABLED", false)
+	cfg.AdminEmails = getEnvList("ADMIN_EMAILS", nil)
 
 	// Security - Session secret (required only if auth is enabled)
 	sessionSecret := getEnv("SESSION_SECRET", "")
@@ -160,9 +211,22 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("ENCRYPTION_KEY must be exactly 32 bytes (64 hex chars), got %d bytes", len(encryptionKey))
 	}
 	cfg.EncryptionKey = encryptionKey
+	cfg.CredentialPlaceholders = getEnvBool("CREDENTIAL_PLACEHOLDERS", false)
+	cfg.WebhookAllowPrivateNetworks = getEnvBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false)
 
 	// Workspaces and Git - defaults to XDG_DATA_HOME/discobot/workspaces
 	cfg.WorkspaceDir = getEnv("WORKSPACE_DIR", filepath.Join(xdg.DataHome, appName, "workspaces"))
+	cfg.GitCloneDepth = getEnvInt("GIT_CLONE_DEPTH", 0)
+	cfg.GitProvider = getEnv("GIT_PROVIDER", "cli")
+	if cfg.GitProvider != "cli" && cfg.GitProvider != "go" {
+		return nil, fmt.Errorf("GIT_PROVIDER must be \"cli\" or \"go\", got %q", cfg.GitProvider)
+	}
+
+	// Pull request forges
+	cfg.GitHubToken = getEnv("GITHUB_TOKEN", "")
+	cfg.GitHubAPIURL = getEnv("GITHUB_API_URL", "https://api.github.com")
+	cfg.GiteaURL = getEnv("GITEA_URL", "")
+	cfg.GiteaToken = getEnv("GITEA_TOKEN", "")
 
 	// Sandbox runtime settings
 	cfg.SandboxImage = getEnv("SANDBOX_IMAGE", DefaultSandboxImage())
@@ -192,6 +256,14 @@ func Load() (*Config, error) {
 	cfg.LocalProviderEnabled = getEnvBool("LOCAL_PROVIDER_ENABLED", false)
 	cfg.LocalAgentBinary = getEnv("LOCAL_AGENT_BINARY", "obot-agent-api")
 
+	// Kubernetes provider settings
+	cfg.KubernetesEnabled = getEnvBool("KUBERNETES_PROVIDER_ENABLED", false)
+	cfg.KubernetesKubeconfig = getEnv("KUBECONFIG", "")
+	cfg.KubernetesNamespace = getEnv("KUBERNETES_NAMESPACE", DefaultKubernetesNamespace)
+	cfg.KubernetesStorageClass = getEnv("KUBERNETES_STORAGE_CLASS", "")
+	cfg.KubernetesDataVolumeSize = getEnv("KUBERNETES_DATA_VOLUME_SIZE", "10Gi")
+	cfg.KubernetesStartTimeout = getEnvDuration("KUBERNETES_START_TIMEOUT", 5*time.Minute)
+
 	// SSH server settings
 	// SSH host key defaults to XDG_STATE_HOME/discobot/ssh_host_key
 	cfg.SSHEnabled = getEnvBool("SSH_ENABLED", true)
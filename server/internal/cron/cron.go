@@ -0,0 +1,208 @@
+// Package cron parses standard five-field cron expressions and computes
+// their activation times.
+//
+// Supported syntax per field: "*", single values, ranges ("1-5"), steps
+// ("*/15", "10-30/5") and comma-separated lists of those. Month and
+// day-of-week fields also accept three-letter names (JAN, MON). Day of week
+// 0 and 7 are both Sunday. The macros @yearly, @annually, @monthly, @weekly,
+// @daily, @midnight and @hourly are accepted as well.
+//
+// As in Vixie cron, when both day of month and day of week are restricted a
+// day matches if either field matches. A field starting with "*", such as
+// "*/2", does not count as restricted here, so "0 0 */2 * 1" runs on
+// Mondays that are odd days of the month.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny record whether the day fields started with "*"
+	domAny, dowAny bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expanded, ok := macros[strings.ToLower(expr)]; ok {
+		expr = expanded
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	// 7 is an alias for Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	// Like Vixie cron, a day field starting with "*" (such as "*/2") does
+	// not make the other one an alternative
+	s.domAny = strings.HasPrefix(fields[2], "*")
+	s.dowAny = strings.HasPrefix(fields[4], "*")
+	return &s, nil
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		b, err := parseRange(part, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+// parseRange parses one list element: "*", "N", "N-M", each optionally
+// followed by "/step".
+func parseRange(expr string, f field) (uint64, error) {
+	rangeExpr, stepExpr, hasStep := strings.Cut(expr, "/")
+
+	start, end := f.min, f.max
+	if rangeExpr != "*" {
+		lo, hi, isRange := strings.Cut(rangeExpr, "-")
+		var err error
+		if start, err = parseValue(lo, f); err != nil {
+			return 0, err
+		}
+		end = start
+		if isRange {
+			if end, err = parseValue(hi, f); err != nil {
+				return 0, err
+			}
+		} else if hasStep {
+			// "N/step" runs from N to the end of the range
+			end = f.max
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid %s range %q", f.name, rangeExpr)
+		}
+	}
+
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepExpr)
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid %s step %q", f.name, stepExpr)
+		}
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s value %d out of range %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// maxSearchYears bounds Next for expressions that rarely or never match,
+// such as "0 0 30 2 *".
+const maxSearchYears = 5
+
+// Next returns the first activation time strictly after t, in t's location.
+// Returns the zero time if there is none within the next few years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
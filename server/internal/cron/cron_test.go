@@ -0,0 +1,121 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Wednesday
+	start := time.Date(2026, 1, 14, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 14, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 1, 15, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 14, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON", time.Date(2026, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 1, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{"30 10 14 1 *", time.Date(2027, 1, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0,30 8-9 * * *", time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)},
+		// Day of month OR day of week when both are restricted: the 20th or any Friday
+		{"0 0 20 * FRI", time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(start); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNext_DayFields(t *testing.T) {
+	// Wednesday
+	start := time.Date(2026, 1, 14, 10, 30, 15, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		// Both restricted: the 20th or any Friday
+		{"0 0 20 * FRI", []time.Time{day(1, 16), day(1, 20), day(1, 23)}},
+		// A stepped "*" restricts without making the fields alternatives:
+		// Mondays on odd days only
+		{"0 0 */2 * 1", []time.Time{day(1, 19), day(2, 9), day(2, 23)}},
+		// Odd days that are a Sunday, Tuesday, Thursday or Saturday
+		{"0 0 1-31/2 * */2", []time.Time{day(1, 15), day(1, 17), day(1, 25)}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		next := start
+		for i, want := range tt.want {
+			if next = s.Next(next); !next.Equal(want) {
+				t.Errorf("Next(%q) #%d = %v, want %v", tt.expr, i+1, next, want)
+				break
+			}
+		}
+	}
+}
+
+func TestNext_Location(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	s, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.Next(time.Date(2026, 1, 14, 12, 0, 0, 0, loc))
+	want := time.Date(2026, 1, 15, 2, 0, 0, 0, loc)
+	if !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestNext_Impossible(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next = %v, want zero time", got)
+	}
+}
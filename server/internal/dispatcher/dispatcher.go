@@ -3,6 +3,7 @@ package dispatcher
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"sync"
 	"time"
@@ -16,6 +17,19 @@ import (
 	"github.com/obot-platform/discobot/server/internal/store"
 )
 
+// ErrJobCancelled is the cancellation cause of a running job's context when
+// the job is cancelled through the API.
+var ErrJobCancelled = errors.New("job cancelled")
+
+// scheduleCheckInterval is how often the leader looks for due schedules.
+const scheduleCheckInterval = 15 * time.Second
+
+// Scheduler starts recurring work. It is implemented by service.ScheduleService.
+type Scheduler interface {
+	// ProcessSchedules starts everything due at now.
+	ProcessSchedules(ctx context.Context, now time.Time) error
+}
+
 // Service manages job processing with leader election.
 type Service struct {
 	store       *store.Store
@@ -31,6 +45,13 @@ type Service struct {
 	runningJobs   map[jobs.JobType]int
 	runningJobsMu sync.Mutex
 
+	// Evaluated by the leader every scheduleCheckInterval (optional)
+	scheduler Scheduler
+
+	// Cancel functions of jobs executing on this server, by job ID
+	jobCancels   map[string]context.CancelCauseFunc
+	jobCancelsMu sync.Mutex
+
 	// Leadership state
 	isLeader   bool
 	isLeaderMu sync.RWMutex
@@ -55,6 +76,7 @@ func NewService(s *store.Store, cfg *config.Config, eventBroker *events.Broker)
 		singleNode:  cfg.DatabaseDriver == "sqlite",
 		executors:   make(map[jobs.JobType]JobExecutor),
 		runningJobs: make(map[jobs.JobType]int),
+		jobCancels:  make(map[string]context.CancelCauseFunc),
 		notifyCh:    make(chan struct{}, 100), // Buffered to avoid blocking enqueuers
 	}
 }
@@ -64,6 +86,12 @@ func (d *Service) RegisterExecutor(executor JobExecutor) {
 	d.executors[executor.Type()] = executor
 }
 
+// SetScheduler sets the scheduler the leader runs periodically.
+// Must be called before Start.
+func (d *Service) SetScheduler(s Scheduler) {
+	d.scheduler = s
+}
+
 // ServerID returns this server's unique ID.
 func (d *Service) ServerID() string {
 	return d.serverID
@@ -122,6 +150,18 @@ func (d *Service) Start(parentCtx context.Context) {
 	// Start stale job cleanup loop
 	d.wg.Add(1)
 	go d.staleJobCleanupLoop()
+
+	// Start schedule loop
+	if d.scheduler != nil {
+		d.wg.Add(1)
+		go d.scheduleLoop()
+	}
+
+	// Start event retention loop
+	if d.cfg.EventRetentionInterval > 0 && (d.cfg.EventRetentionDays > 0 || d.cfg.EventRetentionMaxPerProject > 0) {
+		d.wg.Add(1)
+		go d.eventRetentionLoop()
+	}
 }
 
 // Stop gracefully stops the dispatcher.
@@ -221,6 +261,7 @@ func (d *Service) jobProcessingLoop() {
 		case <-d.ctx.Done():
 			return
 		case <-ticker.C:
+			d.cancelJobsCancelledElsewhere()
 			d.processAvailableJobs()
 		case <-d.notifyCh:
 			// Immediate execution notification - try to process right away
@@ -302,16 +343,27 @@ func (d *Service) executeJob(job *model.Job) {
 		return
 	}
 
-	// Execute with timeout
-	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.DispatcherJobTimeout)
-	defer cancel()
+	// Execute with timeout; CancelJob cancels the context with ErrJobCancelled
+	timeoutCtx, cancelTimeout := context.WithTimeout(d.ctx, d.cfg.DispatcherJobTimeout)
+	defer cancelTimeout()
+	ctx, cancel := context.WithCancelCause(timeoutCtx)
+	d.trackJob(job.ID, cancel)
+	defer d.untrackJob(job.ID)
+
+	d.publishJobUpdated(job.ID)
 
 	err := executor.Execute(ctx, job)
+	if errors.Is(context.Cause(ctx), ErrJobCancelled) {
+		// The job was marked cancelled (and its events published) by whoever cancelled it
+		log.Printf("Job %s cancelled", job.ID)
+		return
+	}
 	if err != nil {
 		log.Printf("Job %s failed: %v", job.ID, err)
 		if err := d.store.FailJob(d.ctx, job.ID, err.Error(), d.cfg.JobRetryBackoff); err != nil {
 			log.Printf("Failed to mark job %s as failed: %v", job.ID, err)
 		}
+		d.publishJobUpdated(job.ID)
 		// Publish job completion event (failure)
 		d.publishJobCompletionEvent(job, "failed", err.Error())
 		return
@@ -321,10 +373,62 @@ func (d *Service) executeJob(job *model.Job) {
 	if err := d.store.CompleteJob(d.ctx, job.ID); err != nil {
 		log.Printf("Failed to mark job %s as completed: %v", job.ID, err)
 	}
+	d.publishJobUpdated(job.ID)
 	// Publish job completion event (success)
 	d.publishJobCompletionEvent(job, "completed", "")
 }
 
+// CancelJob cancels the context of a job executing on this server.
+// Returns false if the job is not running here.
+func (d *Service) CancelJob(jobID string) bool {
+	d.jobCancelsMu.Lock()
+	cancel, ok := d.jobCancels[jobID]
+	d.jobCancelsMu.Unlock()
+	if ok {
+		cancel(ErrJobCancelled)
+	}
+	return ok
+}
+
+// cancelJobsCancelledElsewhere cancels local jobs that were marked cancelled
+// in the database, e.g. through the API of another server.
+func (d *Service) cancelJobsCancelledElsewhere() {
+	d.jobCancelsMu.Lock()
+	ids := make([]string, 0, len(d.jobCancels))
+	for id := range d.jobCancels {
+		ids = append(ids, id)
+	}
+	d.jobCancelsMu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	cancelled, err := d.store.FilterCancelledJobs(d.ctx, ids)
+	if err != nil {
+		log.Printf("Failed to check for cancelled jobs: %v", err)
+		return
+	}
+	for _, id := range cancelled {
+		d.CancelJob(id)
+	}
+}
+
+func (d *Service) trackJob(jobID string, cancel context.CancelCauseFunc) {
+	d.jobCancelsMu.Lock()
+	d.jobCancels[jobID] = cancel
+	d.jobCancelsMu.Unlock()
+}
+
+func (d *Service) untrackJob(jobID string) {
+	d.jobCancelsMu.Lock()
+	cancel := d.jobCancels[jobID]
+	delete(d.jobCancels, jobID)
+	d.jobCancelsMu.Unlock()
+	if cancel != nil {
+		cancel(nil)
+	}
+}
+
 // decrementRunning decrements the running job count for a type.
 func (d *Service) decrementRunning(jobType jobs.JobType) {
 	d.runningJobsMu.Lock()
@@ -358,6 +462,76 @@ func (d *Service) staleJobCleanupLoop() {
 	}
 }
 
+// scheduleLoop periodically lets the scheduler start due work.
+func (d *Service) scheduleLoop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			if !d.IsLeader() {
+				continue
+			}
+
+			if err := d.scheduler.ProcessSchedules(d.ctx, time.Now()); err != nil {
+				log.Printf("Schedule processing error: %v", err)
+			}
+		}
+	}
+}
+
+// eventRetentionLoop periodically enqueues an event_retention job on the
+// leader. The job is deduplicated, so a slow run is never queued twice.
+func (d *Service) eventRetentionLoop() {
+	defer d.wg.Done()
+
+	queue := jobs.NewQueue(d.store, d.cfg)
+	queue.SetEventBroker(d.eventBroker)
+	queue.SetNotifyFunc(d.NotifyNewJob)
+
+	ticker := time.NewTicker(d.cfg.EventRetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			if !d.IsLeader() {
+				continue
+			}
+
+			err := queue.Enqueue(d.ctx, jobs.EventRetentionPayload{
+				MaxAgeDays:    d.cfg.EventRetentionDays,
+				MaxPerProject: d.cfg.EventRetentionMaxPerProject,
+			})
+			if err != nil && !errors.Is(err, jobs.ErrJobAlreadyExists) {
+				log.Printf("Failed to enqueue event retention job: %v", err)
+			}
+		}
+	}
+}
+
+// publishJobUpdated publishes the job's current state from the database.
+func (d *Service) publishJobUpdated(jobID string) {
+	if d.eventBroker == nil {
+		return
+	}
+	job, err := d.store.GetJobByID(d.ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to load job %s for event: %v", jobID, err)
+		return
+	}
+	if err := d.eventBroker.PublishJobUpdated(d.ctx, job); err != nil {
+		log.Printf("Failed to publish job update event for job %s: %v", jobID, err)
+	}
+}
+
 // publishJobCompletionEvent publishes a job completion event to the event broker.
 func (d *Service) publishJobCompletionEvent(job *model.Job, status, errorMsg string) {
 	if d.eventBroker == nil {
@@ -395,9 +569,14 @@ func (d *Service) publishJobCompletionEvent(job *model.Job, status, errorMsg str
 	}
 }
 
-// extractProjectIDFromJob extracts the projectId from the job payload.
+// extractProjectIDFromJob returns the job's project, falling back to the
+// projectId in the payload for jobs created before jobs had a project column.
 // Returns empty string if projectId cannot be found.
 func (d *Service) extractProjectIDFromJob(job *model.Job) string {
+	if job.ProjectID != nil && *job.ProjectID != "" {
+		return *job.ProjectID
+	}
+
 	var payload map[string]interface{}
 	if err := json.Unmarshal(job.Payload, &payload); err != nil {
 		return ""
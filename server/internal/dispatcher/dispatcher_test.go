@@ -0,0 +1,107 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/database"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// blockingExecutor runs until its context is done and reports the cause.
+type blockingExecutor struct {
+	started chan struct{}
+	cause   chan error
+}
+
+func (e *blockingExecutor) Type() jobs.JobType { return jobs.JobTypeSessionInit }
+
+func (e *blockingExecutor) Execute(ctx context.Context, _ *model.Job) error {
+	close(e.started)
+	<-ctx.Done()
+	e.cause <- context.Cause(ctx)
+	return ctx.Err()
+}
+
+func newTestDispatcher(t *testing.T) (*Service, *store.Store) {
+	t.Helper()
+
+	cfg := &config.Config{
+		DatabaseDSN:          fmt.Sprintf("sqlite3://%s", filepath.Join(t.TempDir(), "test.db")),
+		DatabaseDriver:       "sqlite",
+		DispatcherJobTimeout: time.Minute,
+		JobRetryBackoff:      time.Second,
+	}
+	db, err := database.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	s := store.New(db.DB, db.ReadDB)
+
+	d := NewService(s, cfg, nil)
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	t.Cleanup(d.cancel)
+	return d, s
+}
+
+func TestCancelJobStopsRunningExecutor(t *testing.T) {
+	d, s := newTestDispatcher(t)
+	ctx := context.Background()
+
+	executor := &blockingExecutor{started: make(chan struct{}), cause: make(chan error, 1)}
+	d.RegisterExecutor(executor)
+
+	job := &model.Job{Type: string(jobs.JobTypeSessionInit), Payload: []byte(`{}`), MaxAttempts: 3}
+	if err := s.CreateJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := s.ClaimJob(ctx, job.Type, d.ServerID())
+	if err != nil || claimed == nil {
+		t.Fatalf("ClaimJob = %v, %v", claimed, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.executeJob(claimed)
+		close(done)
+	}()
+	<-executor.started
+
+	// Cancel through the database, as another server's API would
+	if ok, err := s.CancelJob(ctx, job.ID, "cancelled by user"); err != nil || !ok {
+		t.Fatalf("CancelJob = %v, %v", ok, err)
+	}
+	d.cancelJobsCancelledElsewhere()
+
+	select {
+	case cause := <-executor.cause:
+		if !errors.Is(cause, ErrJobCancelled) {
+			t.Errorf("context cause = %v, want ErrJobCancelled", cause)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("executor was not cancelled")
+	}
+	<-done
+
+	got, err := s.GetJobByID(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != string(model.JobStatusCancelled) {
+		t.Errorf("status = %q, want cancelled", got.Status)
+	}
+	if d.CancelJob(job.ID) {
+		t.Error("finished job should no longer be cancellable")
+	}
+}
@@ -0,0 +1,61 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// EventRetentionExecutor handles event_retention jobs.
+type EventRetentionExecutor struct {
+	store *store.Store
+}
+
+// NewEventRetentionExecutor creates a new event retention executor.
+func NewEventRetentionExecutor(s *store.Store) *EventRetentionExecutor {
+	return &EventRetentionExecutor{store: s}
+}
+
+// Type returns the job type this executor handles.
+func (e *EventRetentionExecutor) Type() jobs.JobType {
+	return jobs.JobTypeEventRetention
+}
+
+// Execute processes the job.
+func (e *EventRetentionExecutor) Execute(ctx context.Context, job *model.Job) error {
+	var payload jobs.EventRetentionPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	if payload.MaxAgeDays < 0 || payload.MaxPerProject < 0 {
+		return fmt.Errorf("retention limits must not be negative")
+	}
+
+	var deleted int64
+	if payload.MaxAgeDays > 0 {
+		n, err := e.store.DeleteOldProjectEvents(ctx, time.Duration(payload.MaxAgeDays)*24*time.Hour)
+		if err != nil {
+			return fmt.Errorf("failed to delete old events: %w", err)
+		}
+		deleted += n
+	}
+	if payload.MaxPerProject > 0 {
+		n, err := e.store.TrimProjectEvents(ctx, payload.MaxPerProject)
+		if err != nil {
+			return fmt.Errorf("failed to trim project events: %w", err)
+		}
+		deleted += n
+	}
+
+	if deleted > 0 {
+		log.Printf("Event retention deleted %d project events", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestEventRetentionExecutor(t *testing.T) {
+	d, s := newTestDispatcher(t)
+	ctx := context.Background()
+
+	projects := make(map[string]string)
+	for _, slug := range []string{"project-a", "project-b", "project-c"} {
+		project := &model.Project{Name: slug, Slug: slug}
+		if err := s.CreateProject(ctx, project); err != nil {
+			t.Fatal(err)
+		}
+		projects[slug] = project.ID
+	}
+
+	for _, projectID := range []string{projects["project-a"], projects["project-b"]} {
+		for i := 0; i < 3; i++ {
+			if err := s.CreateProjectEvent(ctx, &model.ProjectEvent{ProjectID: projectID, Type: "test", Data: json.RawMessage(`{}`)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// An old event is deleted by age even though project-c is under the limit
+	old := &model.ProjectEvent{ProjectID: projects["project-c"], Type: "test", Data: json.RawMessage(`{}`), CreatedAt: time.Now().Add(-48 * time.Hour)}
+	if err := s.CreateProjectEvent(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// A limit of 0 keeps every event
+	if deleted, err := s.TrimProjectEvents(ctx, 0); err != nil || deleted != 0 {
+		t.Fatalf("TrimProjectEvents(0) = %d, %v, want nothing deleted", deleted, err)
+	}
+
+	payload, _ := json.Marshal(jobs.EventRetentionPayload{MaxAgeDays: 1, MaxPerProject: 2})
+	executor := NewEventRetentionExecutor(d.store)
+	if err := executor.Execute(ctx, &model.Job{Type: string(jobs.JobTypeEventRetention), Payload: payload}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	for slug, want := range map[string]int{"project-a": 2, "project-b": 2, "project-c": 0} {
+		events, err := s.ListProjectEventsAfterSeq(ctx, projects[slug], 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != want {
+			t.Errorf("%s: expected %d events, got %d", slug, want, len(events))
+		}
+	}
+}
@@ -5,8 +5,10 @@ import "github.com/obot-platform/discobot/server/internal/jobs"
 // ConcurrencyLimits defines max concurrent jobs per type.
 // These can be made configurable via config.Config if needed.
 var ConcurrencyLimits = map[jobs.JobType]int{
-	jobs.JobTypeSessionInit:   2, // Max 2 session inits at once
-	jobs.JobTypeSessionDelete: 2, // Max 2 session deletes at once
+	jobs.JobTypeSessionInit:     2, // Max 2 session inits at once
+	jobs.JobTypeSessionDelete:   2, // Max 2 session deletes at once
+	jobs.JobTypeScheduleRun:     2, // Max 2 scheduled sessions starting at once
+	jobs.JobTypeWebhookDelivery: 4, // Deliveries are independent HTTP requests
 }
 
 // DefaultConcurrencyLimit is used for job types not in ConcurrencyLimits.
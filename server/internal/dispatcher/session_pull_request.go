@@ -0,0 +1,47 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// SessionPullRequestExecutor handles session_pull_request jobs.
+type SessionPullRequestExecutor struct {
+	pullRequestService *service.PullRequestService
+}
+
+// NewSessionPullRequestExecutor creates a new session pull request executor.
+func NewSessionPullRequestExecutor(pullRequestSvc *service.PullRequestService) *SessionPullRequestExecutor {
+	return &SessionPullRequestExecutor{pullRequestService: pullRequestSvc}
+}
+
+// Type returns the job type this executor handles.
+func (e *SessionPullRequestExecutor) Type() jobs.JobType {
+	return jobs.JobTypeSessionPullRequest
+}
+
+// Execute processes the job.
+func (e *SessionPullRequestExecutor) Execute(ctx context.Context, job *model.Job) error {
+	if e.pullRequestService == nil {
+		return fmt.Errorf("pull request service not available")
+	}
+
+	var payload jobs.SessionPullRequestPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	if payload.SessionID == "" {
+		return fmt.Errorf("sessionId is required")
+	}
+	if payload.ProjectID == "" {
+		return fmt.Errorf("projectId is required")
+	}
+
+	return e.pullRequestService.OpenPullRequest(ctx, payload.ProjectID, payload.SessionID)
+}
@@ -0,0 +1,45 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// WebhookDeliveryExecutor handles webhook_delivery jobs.
+type WebhookDeliveryExecutor struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookDeliveryExecutor creates a new webhook delivery executor.
+func NewWebhookDeliveryExecutor(webhookSvc *service.WebhookService) *WebhookDeliveryExecutor {
+	return &WebhookDeliveryExecutor{webhookService: webhookSvc}
+}
+
+// Type returns the job type this executor handles.
+func (e *WebhookDeliveryExecutor) Type() jobs.JobType {
+	return jobs.JobTypeWebhookDelivery
+}
+
+// Execute processes the job.
+func (e *WebhookDeliveryExecutor) Execute(ctx context.Context, job *model.Job) error {
+	if e.webhookService == nil {
+		return fmt.Errorf("webhook service not available")
+	}
+
+	var payload jobs.WebhookDeliveryPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	if payload.DeliveryID == "" {
+		return fmt.Errorf("deliveryId is required")
+	}
+
+	// The attempt count includes this attempt
+	return e.webhookService.Deliver(ctx, payload.DeliveryID, job.Attempts >= job.MaxAttempts)
+}
@@ -5,7 +5,9 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -13,6 +15,10 @@ import (
 	"github.com/obot-platform/discobot/server/internal/store"
 )
 
+// ErrEventsPruned is returned when resuming after an event that retention
+// has already deleted, so events the client has not seen may be gone too.
+var ErrEventsPruned = errors.New("events pruned")
+
 // EventType represents the type of event being broadcast
 type EventType string
 
@@ -21,8 +27,12 @@ const (
 	EventTypeSessionUpdated EventType = "session_updated"
 	// EventTypeWorkspaceUpdated indicates a workspace's state has changed
 	EventTypeWorkspaceUpdated EventType = "workspace_updated"
-	// EventTypeJobCompleted indicates a job has completed (success or failure)
+	// EventTypeJobCompleted indicates a job has completed (success, failure or cancellation)
 	EventTypeJobCompleted EventType = "job_completed"
+	// EventTypeJobUpdated indicates a job's state has changed
+	EventTypeJobUpdated EventType = "job_updated"
+	// EventTypeSessionPullRequest indicates a pull request was opened for a session
+	EventTypeSessionPullRequest EventType = "session_pull_request"
 )
 
 // Event represents a server-sent event
@@ -64,10 +74,29 @@ type JobCompletedData struct {
 	JobType      string `json:"jobType"`
 	ResourceType string `json:"resourceType,omitempty"`
 	ResourceID   string `json:"resourceId,omitempty"`
-	Status       string `json:"status"` // "completed" or "failed"
+	Status       string `json:"status"` // "completed", "failed" or "cancelled"
+	Error        string `json:"error,omitempty"`
+}
+
+// JobUpdatedData is the payload for job_updated events
+type JobUpdatedData struct {
+	JobID        string `json:"jobId"`
+	JobType      string `json:"jobType"`
+	ResourceType string `json:"resourceType,omitempty"`
+	ResourceID   string `json:"resourceId,omitempty"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts"`
 	Error        string `json:"error,omitempty"`
 }
 
+// SessionPullRequestData is the payload for session_pull_request events
+type SessionPullRequestData struct {
+	SessionID string `json:"sessionId"`
+	Branch    string `json:"branch"`
+	Number    int    `json:"number"`
+	URL       string `json:"url"`
+}
+
 // Subscriber represents a client subscribed to events for a specific project.
 type Subscriber struct {
 	ID        string
@@ -98,8 +127,24 @@ func (s *Subscriber) Done() <-chan struct{} {
 // Events are persisted to the database first, then the poller picks them up
 // and broadcasts to subscribers.
 type Broker struct {
-	store  *store.Store
-	poller *Poller
+	store      *store.Store
+	poller     *Poller
+	notifyFunc func()         // Called after an event is persisted to wake other nodes' pollers
+	hookQueue  chan hookEvent // Events waiting for the publish hook
+}
+
+// PublishHook is called with every event after it has been persisted.
+type PublishHook func(ctx context.Context, projectID string, event *Event)
+
+// publishHookQueueSize is how many published events can wait for the publish
+// hook. Publish drops events for the hook beyond that rather than block.
+const publishHookQueueSize = 1024
+
+// hookEvent is a published event waiting for the publish hook.
+type hookEvent struct {
+	ctx       context.Context
+	projectID string
+	event     Event
 }
 
 // NewBroker creates a new event broker.
@@ -111,6 +156,28 @@ func NewBroker(s *store.Store, poller *Poller) *Broker {
 	}
 }
 
+// SetNotifyFunc sets a function to call after an event is persisted, in
+// addition to waking the local poller. Multi-node deployments use it to
+// wake the pollers of the other nodes.
+func (b *Broker) SetNotifyFunc(f func()) {
+	b.notifyFunc = f
+}
+
+// SetPublishHook sets a function called with every event published through
+// this broker, after it is persisted. The hook runs in the background, one
+// event at a time in publish order, so a slow hook does not delay publishers;
+// while publishHookQueueSize events are waiting, further ones skip it.
+// It must be set before events are published.
+func (b *Broker) SetPublishHook(h PublishHook) {
+	queue := make(chan hookEvent, publishHookQueueSize)
+	b.hookQueue = queue
+	go func() {
+		for e := range queue {
+			h(e.ctx, e.projectID, &e.event)
+		}
+	}()
+}
+
 // Subscribe creates a new subscription for a project's events.
 // Events are delivered through the returned Subscriber's Events channel.
 func (b *Broker) Subscribe(projectID string) *Subscriber {
@@ -141,6 +208,17 @@ func (b *Broker) Publish(ctx context.Context, projectID string, event *Event) er
 
 	// Notify poller to pick up the event immediately
 	b.poller.NotifyNewEvent()
+	if b.notifyFunc != nil {
+		b.notifyFunc()
+	}
+	if b.hookQueue != nil {
+		// The hook outlives the publisher's request
+		select {
+		case b.hookQueue <- hookEvent{ctx: context.WithoutCancel(ctx), projectID: projectID, event: *event}:
+		default:
+			log.Printf("Warning: publish hook queue full, skipping the hook for event %s of project %s", event.ID, projectID)
+		}
+	}
 
 	return nil
 }
@@ -217,6 +295,61 @@ func (b *Broker) PublishJobCompleted(ctx context.Context, projectID, jobID, jobT
 	return b.Publish(ctx, projectID, event)
 }
 
+// PublishJobUpdated publishes the current state of a job. Jobs without a
+// project are not published, since events are scoped to projects.
+func (b *Broker) PublishJobUpdated(ctx context.Context, job *model.Job) error {
+	if job.ProjectID == nil || *job.ProjectID == "" {
+		return nil
+	}
+
+	data := JobUpdatedData{
+		JobID:    job.ID,
+		JobType:  job.Type,
+		Status:   job.Status,
+		Attempts: job.Attempts,
+	}
+	if job.ResourceType != nil {
+		data.ResourceType = *job.ResourceType
+	}
+	if job.ResourceID != nil {
+		data.ResourceID = *job.ResourceID
+	}
+	if job.Error != nil {
+		data.Error = *job.Error
+	}
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	event := &Event{
+		ID:        generateEventID(),
+		Type:      EventTypeJobUpdated,
+		Timestamp: time.Now(),
+		Data:      dataBytes,
+	}
+
+	return b.Publish(ctx, *job.ProjectID, event)
+}
+
+// PublishSessionPullRequest publishes the pull request opened for a session.
+func (b *Broker) PublishSessionPullRequest(ctx context.Context, projectID string, data SessionPullRequestData) error {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	event := &Event{
+		ID:        generateEventID(),
+		Type:      EventTypeSessionPullRequest,
+		Timestamp: time.Now(),
+		Data:      dataBytes,
+	}
+
+	return b.Publish(ctx, projectID, event)
+}
+
 // GetEventsSince returns all persisted events for a project since the given time.
 func (b *Broker) GetEventsSince(ctx context.Context, projectID string, since time.Time) ([]*Event, error) {
 	modelEvents, err := b.store.ListProjectEventsSince(ctx, projectID, since)
@@ -245,6 +378,37 @@ func (b *Broker) GetEventsAfterID(ctx context.Context, projectID, afterID string
 	return events, nil
 }
 
+// GetEventsAfterSeq returns all persisted events for a project with a
+// sequence number greater than seq. Returns ErrEventsPruned if the event
+// with sequence number seq no longer exists. Retention deletes the oldest
+// events first, so a surviving seq means nothing after it was deleted.
+func (b *Broker) GetEventsAfterSeq(ctx context.Context, projectID string, seq int64) ([]*Event, error) {
+	exists, err := b.store.ProjectEventSeqExists(ctx, projectID, seq)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrEventsPruned
+	}
+
+	modelEvents, err := b.store.ListProjectEventsAfterSeq(ctx, projectID, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, len(modelEvents))
+	for i, e := range modelEvents {
+		events[i] = FromModel(&e)
+	}
+	return events, nil
+}
+
+// LatestSeq returns the sequence number of a project's newest event, or 0 if
+// it has none.
+func (b *Broker) LatestSeq(ctx context.Context, projectID string) (int64, error) {
+	return b.store.GetMaxProjectEventSeq(ctx, projectID)
+}
+
 // generateEventID creates a unique event ID
 func generateEventID() string {
 	return time.Now().Format("20060102150405.000000000")
@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -257,6 +258,88 @@ func TestBroker_PublishPersistsAndNotifies(t *testing.T) {
 	}
 }
 
+func TestBroker_PublishHookRunsInBackground(t *testing.T) {
+	env := testSetup(t)
+	defer env.Cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	broker := NewBroker(env.Store, NewPoller(env.Store, DefaultPollerConfig()))
+
+	release := make(chan struct{})
+	hooked := make(chan string, 2)
+	broker.SetPublishHook(func(ctx context.Context, projectID string, event *Event) {
+		<-release
+		if ctx.Err() != nil {
+			t.Errorf("Hook context canceled with the publisher's: %v", ctx.Err())
+		}
+		hooked <- projectID + "/" + event.ID
+	})
+
+	// A blocked hook does not hold up publishing
+	for _, id := range []string{"evt-1", "evt-2"} {
+		event := &Event{ID: id, Type: EventTypeSessionUpdated, Timestamp: time.Now(), Data: json.RawMessage(`{}`)}
+		if err := broker.Publish(ctx, env.ProjectID, event); err != nil {
+			t.Fatalf("Failed to publish event: %v", err)
+		}
+	}
+	cancel()
+	close(release)
+
+	for _, want := range []string{env.ProjectID + "/evt-1", env.ProjectID + "/evt-2"} {
+		select {
+		case got := <-hooked:
+			if got != want {
+				t.Errorf("Expected hook for %s, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for publish hook")
+		}
+	}
+}
+
+func TestBroker_PublishHookQueueFull(t *testing.T) {
+	env := testSetup(t)
+	defer env.Cleanup()
+
+	ctx := context.Background()
+	broker := NewBroker(env.Store, NewPoller(env.Store, DefaultPollerConfig()))
+	// A queue nothing reads from, with room for one event
+	broker.hookQueue = make(chan hookEvent, 1)
+
+	done := make(chan error, 1)
+	go func() {
+		for _, id := range []string{"evt-1", "evt-2"} {
+			event := &Event{ID: id, Type: EventTypeSessionUpdated, Timestamp: time.Now(), Data: json.RawMessage(`{}`)}
+			if err := broker.Publish(ctx, env.ProjectID, event); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed to publish event: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a full hook queue")
+	}
+	if e := <-broker.hookQueue; e.event.ID != "evt-1" {
+		t.Errorf("Expected evt-1 queued for the hook, got %s", e.event.ID)
+	}
+
+	// The dropped event was still persisted
+	events, err := env.Store.ListProjectEventsAfterSeq(ctx, env.ProjectID, 0)
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("Expected 2 events in database, got %d", len(events))
+	}
+}
+
 func TestBroker_PublishSessionUpdated(t *testing.T) {
 	env := testSetup(t)
 	defer env.Cleanup()
@@ -461,6 +544,63 @@ func TestBroker_GetEventsSince(t *testing.T) {
 	}
 }
 
+func TestBroker_GetEventsAfterSeq(t *testing.T) {
+	env := testSetup(t)
+	defer env.Cleanup()
+
+	ctx := context.Background()
+
+	poller := NewPoller(env.Store, DefaultPollerConfig())
+	if err := poller.Start(ctx); err != nil {
+		t.Fatalf("Failed to start poller: %v", err)
+	}
+	defer poller.Stop()
+
+	broker := NewBroker(env.Store, poller)
+	otherProjectID := env.createSecondProject(t)
+
+	var seqs []int64
+	for i := 0; i < 4; i++ {
+		event := &Event{ID: fmt.Sprintf("evt-%d", i), Type: EventTypeSessionUpdated, Data: json.RawMessage(`{}`)}
+		if err := broker.Publish(ctx, env.ProjectID, event); err != nil {
+			t.Fatalf("Failed to publish event %d: %v", i, err)
+		}
+		seqs = append(seqs, event.Seq)
+	}
+	if err := broker.Publish(ctx, otherProjectID, &Event{ID: "evt-other", Type: EventTypeSessionUpdated, Data: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("Failed to publish event: %v", err)
+	}
+
+	events, err := broker.GetEventsAfterSeq(ctx, env.ProjectID, seqs[1])
+	if err != nil {
+		t.Fatalf("GetEventsAfterSeq failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Seq != seqs[2] || events[1].Seq != seqs[3] {
+		t.Errorf("Expected events %v, got %+v", seqs[2:], events)
+	}
+
+	// Keep the newest 2 events per project
+	deleted, err := env.Store.TrimProjectEvents(ctx, 2)
+	if err != nil {
+		t.Fatalf("TrimProjectEvents failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deleted events, got %d", deleted)
+	}
+
+	if _, err := broker.GetEventsAfterSeq(ctx, env.ProjectID, seqs[1]); !errors.Is(err, ErrEventsPruned) {
+		t.Errorf("Expected ErrEventsPruned after trimming, got %v", err)
+	}
+	if events, err := broker.GetEventsAfterSeq(ctx, env.ProjectID, seqs[2]); err != nil || len(events) != 1 {
+		t.Errorf("Expected 1 event after a retained seq, got %d (%v)", len(events), err)
+	}
+
+	latest, err := broker.LatestSeq(ctx, env.ProjectID)
+	if err != nil || latest != seqs[3] {
+		t.Errorf("LatestSeq = %d, %v; want %d", latest, err, seqs[3])
+	}
+}
+
 func TestSubscriber_Close(t *testing.T) {
 	env := testSetup(t)
 	defer env.Cleanup()
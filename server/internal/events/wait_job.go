@@ -11,7 +11,7 @@ import (
 
 // WaitForJobCompletion waits for a job to complete for a specific resource.
 // This provides near-instant notification when the job completes, without polling the database.
-// Returns the job status ("completed" or "failed") and any error message.
+// Returns the job status ("completed", "failed" or "cancelled") and any error message.
 // Timeout is enforced via the context.
 func WaitForJobCompletion(
 	ctx context.Context,
@@ -32,12 +32,12 @@ func WaitForJobCompletion(
 		switch job.Status {
 		case "completed":
 			return "completed", "", nil
-		case "failed":
+		case "failed", "cancelled":
 			errMsg := ""
 			if job.Error != nil {
 				errMsg = *job.Error
 			}
-			return "failed", errMsg, nil
+			return job.Status, errMsg, nil
 		}
 	}
 
@@ -90,12 +90,12 @@ func WaitForJobCompletion(
 				switch job.Status {
 				case "completed":
 					return "completed", "", nil
-				case "failed":
+				case "failed", "cancelled":
 					errMsg := ""
 					if job.Error != nil {
 						errMsg = *job.Error
 					}
-					return "failed", errMsg, nil
+					return job.Status, errMsg, nil
 				}
 			}
 		}
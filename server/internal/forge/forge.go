@@ -0,0 +1,123 @@
+// Package forge opens pull requests on git hosting services. Each supported
+// service (GitHub, Gitea/Forgejo) implements Forge; a Registry picks the
+// forge serving a workspace's remote URL.
+package forge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Common errors
+var (
+	ErrNoForge       = errors.New("no forge configured for remote")
+	ErrInvalidRemote = errors.New("invalid remote URL")
+)
+
+// Repo identifies a repository on a forge.
+type Repo struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+// FullName returns "owner/name".
+func (r Repo) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// PullRequestRequest describes a pull request to open.
+type PullRequestRequest struct {
+	Title string
+	Body  string
+	Head  string // Branch with the changes
+	Base  string // Branch to merge into
+}
+
+// PullRequest is an opened pull request.
+type PullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// Forge is a git hosting service that can open pull requests.
+type Forge interface {
+	// Name returns the kind of forge, e.g. "github".
+	Name() string
+
+	// Host returns the git host the forge serves, e.g. "github.com".
+	Host() string
+
+	// PushCredentials returns the HTTPS basic auth credentials for pushing.
+	PushCredentials() (username, password string)
+
+	// CreatePullRequest opens a pull request. If one is already open for
+	// the head branch, it is returned instead.
+	CreatePullRequest(ctx context.Context, repo Repo, req PullRequestRequest) (*PullRequest, error)
+}
+
+// Registry maps git hosts to forges.
+type Registry struct {
+	forges map[string]Forge
+}
+
+// NewRegistry creates a registry of the given forges, keyed by host.
+func NewRegistry(forges ...Forge) *Registry {
+	r := &Registry{forges: make(map[string]Forge)}
+	for _, f := range forges {
+		r.forges[strings.ToLower(f.Host())] = f
+	}
+	return r
+}
+
+// ForRemote returns the forge serving a remote URL and the repository it
+// points to. Returns ErrNoForge if no configured forge serves the host.
+func (r *Registry) ForRemote(remote string) (Forge, Repo, error) {
+	repo, err := ParseRemote(remote)
+	if err != nil {
+		return nil, Repo{}, err
+	}
+	if r != nil {
+		if f, ok := r.forges[strings.ToLower(repo.Host)]; ok {
+			return f, repo, nil
+		}
+	}
+	return nil, Repo{}, fmt.Errorf("%w: %s", ErrNoForge, repo.Host)
+}
+
+// ParseRemote parses HTTPS, SSH and scp-style (git@host:owner/name) remote
+// URLs. The repository name is the last path segment; everything before it
+// is the owner.
+func ParseRemote(remote string) (Repo, error) {
+	var host, path string
+	switch {
+	case strings.Contains(remote, "://"):
+		u, err := url.Parse(remote)
+		if err != nil {
+			return Repo{}, fmt.Errorf("%w: %v", ErrInvalidRemote, err)
+		}
+		host, path = u.Hostname(), u.Path
+	case strings.Contains(remote, "@") && strings.Contains(remote, ":"):
+		// scp-style: git@host:owner/name.git
+		_, rest, _ := strings.Cut(remote, "@")
+		host, path, _ = strings.Cut(rest, ":")
+	default:
+		return Repo{}, fmt.Errorf("%w: %s", ErrInvalidRemote, remote)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	i := strings.LastIndex(path, "/")
+	if host == "" || i <= 0 || i == len(path)-1 {
+		return Repo{}, fmt.Errorf("%w: %s", ErrInvalidRemote, remote)
+	}
+	return Repo{Host: host, Owner: path[:i], Name: path[i+1:]}, nil
+}
+
+// IsHTTPRemote reports whether a remote URL is pushed to over HTTP(S), and
+// so needs PushCredentials.
+func IsHTTPRemote(remote string) bool {
+	return strings.HasPrefix(remote, "https://") || strings.HasPrefix(remote, "http://")
+}
@@ -0,0 +1,166 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   Repo
+	}{
+		{"https://github.com/obot-platform/discobot.git", Repo{"github.com", "obot-platform", "discobot"}},
+		{"https://github.com/obot-platform/discobot", Repo{"github.com", "obot-platform", "discobot"}},
+		{"git@github.com:obot-platform/discobot.git", Repo{"github.com", "obot-platform", "discobot"}},
+		{"ssh://git@gitea.example.com:2222/org/repo.git", Repo{"gitea.example.com", "org", "repo"}},
+		{"https://gitlab.example.com/group/sub/repo.git", Repo{"gitlab.example.com", "group/sub", "repo"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRemote(tt.remote)
+		if err != nil {
+			t.Errorf("ParseRemote(%q) failed: %v", tt.remote, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRemote(%q) = %+v, want %+v", tt.remote, got, tt.want)
+		}
+	}
+
+	for _, remote := range []string{"/local/path", "https://github.com/repo", "git@github.com:"} {
+		if _, err := ParseRemote(remote); !errors.Is(err, ErrInvalidRemote) {
+			t.Errorf("ParseRemote(%q): expected ErrInvalidRemote, got %v", remote, err)
+		}
+	}
+}
+
+func TestRegistry_ForRemote(t *testing.T) {
+	gh := NewGitHub("", "tok")
+	gt := NewGitea("https://codeberg.org/", "tok")
+	r := NewRegistry(gh, gt)
+
+	if f, repo, err := r.ForRemote("git@github.com:o/n.git"); err != nil || f != gh || repo.FullName() != "o/n" {
+		t.Errorf("Expected GitHub for github.com remote, got %v %+v %v", f, repo, err)
+	}
+	if f, _, err := r.ForRemote("https://codeberg.org/o/n.git"); err != nil || f != gt {
+		t.Errorf("Expected Gitea for codeberg.org remote, got %v %v", f, err)
+	}
+	if _, _, err := r.ForRemote("https://example.com/o/n.git"); !errors.Is(err, ErrNoForge) {
+		t.Errorf("Expected ErrNoForge, got %v", err)
+	}
+
+	if got := NewGitHub("https://ghe.example.com/api/v3", "tok").Host(); got != "ghe.example.com" {
+		t.Errorf("GitHub Enterprise host = %q", got)
+	}
+}
+
+func TestGitHub_CreatePullRequest(t *testing.T) {
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/o/n/pulls" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number":7,"html_url":"https://github.com/o/n/pull/7"}`))
+	}))
+	defer srv.Close()
+
+	gh := NewGitHub(srv.URL, "tok")
+	pr, err := gh.CreatePullRequest(context.Background(), Repo{Owner: "o", Name: "n"}, PullRequestRequest{
+		Title: "Add feature", Body: "body", Head: "discobot/feature", Base: "main",
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest failed: %v", err)
+	}
+	if pr.Number != 7 || pr.URL != "https://github.com/o/n/pull/7" {
+		t.Errorf("Unexpected pull request %+v", pr)
+	}
+	if body["head"] != "discobot/feature" || body["base"] != "main" || body["title"] != "Add feature" {
+		t.Errorf("Unexpected request body %v", body)
+	}
+}
+
+func TestGitHub_CreatePullRequestExisting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"message":"Validation Failed"}`))
+		case http.MethodGet:
+			if got := r.URL.Query().Get("head"); got != "o:discobot/feature" {
+				t.Errorf("head filter = %q", got)
+			}
+			_, _ = w.Write([]byte(`[{"number":3,"html_url":"https://github.com/o/n/pull/3"}]`))
+		}
+	}))
+	defer srv.Close()
+
+	pr, err := NewGitHub(srv.URL, "tok").CreatePullRequest(context.Background(), Repo{Owner: "o", Name: "n"}, PullRequestRequest{Head: "discobot/feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("CreatePullRequest failed: %v", err)
+	}
+	if pr.Number != 3 {
+		t.Errorf("Expected existing pull request 3, got %+v", pr)
+	}
+}
+
+func TestGitea_CreatePullRequest(t *testing.T) {
+	created := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/repos/o/n/pulls" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		switch {
+		case r.Method == http.MethodPost && !created:
+			created = true
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"number":1,"html_url":"https://gitea.test/o/n/pulls/1"}`))
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			_, _ = w.Write([]byte(`[{"number":2,"head":{"ref":"other"}},{"number":1,"html_url":"https://gitea.test/o/n/pulls/1","head":{"ref":"discobot/feature"}}]`))
+		}
+	}))
+	defer srv.Close()
+
+	gt := NewGitea(srv.URL, "tok")
+	req := PullRequestRequest{Title: "t", Head: "discobot/feature", Base: "main"}
+	for i := 0; i < 2; i++ {
+		pr, err := gt.CreatePullRequest(context.Background(), Repo{Owner: "o", Name: "n"}, req)
+		if err != nil {
+			t.Fatalf("CreatePullRequest #%d failed: %v", i, err)
+		}
+		if pr.Number != 1 || pr.URL != "https://gitea.test/o/n/pulls/1" {
+			t.Errorf("CreatePullRequest #%d = %+v", i, pr)
+		}
+	}
+
+	if user, pass := gt.PushCredentials(); user != "tok" || pass == "" {
+		t.Errorf("Unexpected push credentials %q %q", user, pass)
+	}
+}
+
+func TestCreatePullRequest_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+	}))
+	defer srv.Close()
+
+	if _, err := NewGitHub(srv.URL, "tok").CreatePullRequest(context.Background(), Repo{Owner: "o", Name: "n"}, PullRequestRequest{}); err == nil {
+		t.Error("Expected error for 404 response")
+	}
+}
@@ -0,0 +1,93 @@
+package forge
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Gitea opens pull requests through the Gitea API. Forgejo serves the same
+// API and is supported by the same client.
+type Gitea struct {
+	client *apiClient
+	host   string
+	token  string
+}
+
+// NewGitea creates a Gitea/Forgejo forge for the instance at baseURL,
+// e.g. "https://codeberg.org".
+func NewGitea(baseURL, token string) *Gitea {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	host := ""
+	if u, err := url.Parse(baseURL); err == nil {
+		host = u.Host
+	}
+
+	return &Gitea{
+		client: &apiClient{
+			baseURL: baseURL + "/api/v1",
+			header: http.Header{
+				"Authorization": {"token " + token},
+				"Accept":        {"application/json"},
+			},
+		},
+		host:  host,
+		token: token,
+	}
+}
+
+// Name returns "gitea".
+func (g *Gitea) Name() string { return "gitea" }
+
+// Host returns the host of the instance.
+func (g *Gitea) Host() string { return g.host }
+
+// PushCredentials returns the token as the username, which Gitea accepts
+// with any password.
+func (g *Gitea) PushCredentials() (string, string) {
+	return g.token, "x-oauth-basic"
+}
+
+// giteaPullRequest is the subset of the Gitea pull request object we use.
+type giteaPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+// CreatePullRequest opens a pull request. Gitea answers 409 (older versions
+// 422) if one is already open for the head branch, in which case that one is
+// returned.
+func (g *Gitea) CreatePullRequest(ctx context.Context, repo Repo, req PullRequestRequest) (*PullRequest, error) {
+	path := "/repos/" + repo.FullName() + "/pulls"
+
+	var created giteaPullRequest
+	status, err := g.client.do(ctx, http.MethodPost, path, map[string]string{
+		"title": req.Title,
+		"body":  req.Body,
+		"head":  req.Head,
+		"base":  req.Base,
+	}, &created)
+	if err == nil {
+		return &PullRequest{Number: created.Number, URL: created.HTMLURL}, nil
+	}
+	if status != http.StatusConflict && status != http.StatusUnprocessableEntity {
+		return nil, err
+	}
+
+	// The list endpoint cannot filter by head branch
+	var open []giteaPullRequest
+	if _, listErr := g.client.do(ctx, http.MethodGet, path+"?state=open&limit=50", nil, &open); listErr != nil {
+		return nil, err
+	}
+	for _, pr := range open {
+		if pr.Head.Ref == req.Head {
+			return &PullRequest{Number: pr.Number, URL: pr.HTMLURL}, nil
+		}
+	}
+	return nil, err
+}
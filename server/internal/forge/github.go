@@ -0,0 +1,91 @@
+package forge
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitHubAPIURL is the API of github.com.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHub opens pull requests through the GitHub REST API. It also serves
+// GitHub Enterprise when created with the instance's API URL.
+type GitHub struct {
+	client *apiClient
+	host   string
+	token  string
+}
+
+// NewGitHub creates a GitHub forge. apiURL defaults to DefaultGitHubAPIURL;
+// for GitHub Enterprise it is e.g. "https://github.example.com/api/v3".
+func NewGitHub(apiURL, token string) *GitHub {
+	if apiURL == "" {
+		apiURL = DefaultGitHubAPIURL
+	}
+	apiURL = strings.TrimSuffix(apiURL, "/")
+
+	host := "github.com"
+	if u, err := url.Parse(apiURL); err == nil && u.Hostname() != "api.github.com" {
+		host = u.Host
+	}
+
+	return &GitHub{
+		client: &apiClient{
+			baseURL: apiURL,
+			header: http.Header{
+				"Authorization":        {"Bearer " + token},
+				"Accept":               {"application/vnd.github+json"},
+				"X-GitHub-Api-Version": {"2022-11-28"},
+			},
+		},
+		host:  host,
+		token: token,
+	}
+}
+
+// Name returns "github".
+func (g *GitHub) Name() string { return "github" }
+
+// Host returns the git host, "github.com" unless using GitHub Enterprise.
+func (g *GitHub) Host() string { return g.host }
+
+// PushCredentials returns the token as an x-access-token password.
+func (g *GitHub) PushCredentials() (string, string) {
+	return "x-access-token", g.token
+}
+
+// githubPullRequest is the subset of the GitHub pull request object we use.
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreatePullRequest opens a pull request. GitHub answers 422 if one is
+// already open for the head branch, in which case that one is returned.
+func (g *GitHub) CreatePullRequest(ctx context.Context, repo Repo, req PullRequestRequest) (*PullRequest, error) {
+	path := "/repos/" + repo.FullName() + "/pulls"
+
+	var created githubPullRequest
+	status, err := g.client.do(ctx, http.MethodPost, path, map[string]string{
+		"title": req.Title,
+		"body":  req.Body,
+		"head":  req.Head,
+		"base":  req.Base,
+	}, &created)
+	if err == nil {
+		return &PullRequest{Number: created.Number, URL: created.HTMLURL}, nil
+	}
+	if status != http.StatusUnprocessableEntity {
+		return nil, err
+	}
+
+	// The head filter needs the owner of the branch's repository
+	var open []githubPullRequest
+	query := url.Values{"state": {"open"}, "head": {repo.Owner + ":" + req.Head}}
+	if _, listErr := g.client.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &open); listErr != nil || len(open) == 0 {
+		return nil, err
+	}
+	return &PullRequest{Number: open[0].Number, URL: open[0].HTMLURL}, nil
+}
@@ -0,0 +1,184 @@
+package git
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Credentials authenticate clone, fetch and push against a private remote.
+// Either SSHKey (a PEM or OpenSSH private key without passphrase) or
+// Username and Password (an HTTPS token) is set.
+type Credentials struct {
+	SSHKey   []byte
+	Username string
+	Password string
+}
+
+// CredentialSource provides the credentials for a project's remotes.
+// GitCredentials returns nil when the project has none for the remote.
+type CredentialSource interface {
+	GitCredentials(ctx context.Context, projectID, remote string) (*Credentials, error)
+}
+
+// WithCredentialSource sets where the provider looks up credentials for
+// cloning and fetching private repositories.
+func WithCredentialSource(src CredentialSource) LocalProviderOption {
+	return func(p *LocalProvider) {
+		p.credentialSource = src
+	}
+}
+
+// RemoteHost returns the lowercased host of a git remote such as
+// "git@github.com:org/repo.git" or "https://github.com/org/repo", or "" if
+// remote is not a URL.
+func RemoteHost(remote string) string {
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return ""
+		}
+		return strings.ToLower(u.Hostname())
+	}
+
+	// scp-like syntax: [user@]host:path
+	before, _, ok := strings.Cut(remote, ":")
+	if !ok || strings.Contains(before, "/") {
+		return ""
+	}
+	if i := strings.LastIndex(before, "@"); i >= 0 {
+		before = before[i+1:]
+	}
+	return strings.ToLower(before)
+}
+
+// IsSSHRemote reports whether remote is reached over SSH, either as an
+// ssh:// URL or in scp-like syntax.
+func IsSSHRemote(remote string) bool {
+	if strings.Contains(remote, "://") {
+		return strings.HasPrefix(remote, "ssh://") || strings.HasPrefix(remote, "git+ssh://")
+	}
+	return RemoteHost(remote) != ""
+}
+
+// BasicAuthHeader returns the http.extraHeader value that authenticates with
+// username and password.
+func BasicAuthHeader(username, password string) string {
+	return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// remoteEnv returns the environment that authenticates git against remote
+// with the project's credentials, and a function that removes the temporary
+// key file it may have written. The key file lives in the system temp
+// directory, never inside a workspace.
+func (p *LocalProvider) remoteEnv(ctx context.Context, projectID, remote string) ([]string, func(), error) {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	cleanup := func() {}
+	if p.credentialSource == nil || projectID == "" || RemoteHost(remote) == "" {
+		return env, cleanup, nil
+	}
+
+	creds, err := p.credentialSource.GitCredentials(ctx, projectID, remote)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to load git credentials: %w", err)
+	}
+	if creds == nil {
+		return env, cleanup, nil
+	}
+
+	if len(creds.SSHKey) > 0 {
+		keyFile, err := writeKeyFile(creds.SSHKey)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		env = append(env,
+			"GIT_SSH_COMMAND=ssh -i "+shellQuote(keyFile)+" -o IdentitiesOnly=yes -o BatchMode=yes -o StrictHostKeyChecking=accept-new",
+			"GIT_SSH_VARIANT=ssh",
+		)
+		return env, func() { _ = os.Remove(keyFile) }, nil
+	}
+
+	if creds.Username != "" || creds.Password != "" {
+		env = append(env, basicAuthEnv(remote, creds.Username, creds.Password)...)
+	}
+	return env, cleanup, nil
+}
+
+// basicAuthEnv returns the git config environment that sends username and
+// password to an http(s) remote, or nothing for other remotes. The header
+// is scoped to the remote's host so redirects and submodules on other hosts
+// never see the token.
+func basicAuthEnv(remote, username, password string) []string {
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil
+	}
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http." + u.Scheme + "://" + u.Host + "/.extraHeader",
+		"GIT_CONFIG_VALUE_0=" + BasicAuthHeader(username, password),
+	}
+}
+
+// writeKeyFile writes an SSH private key to a new file only the server user
+// can read.
+func writeKeyFile(key []byte) (string, error) {
+	f, err := os.CreateTemp("", "discobot-git-key-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create key file: %w", err)
+	}
+	name := f.Name()
+
+	// ssh rejects keys without a trailing newline
+	if key[len(key)-1] != '\n' {
+		key = append(key[:len(key):len(key)], '\n')
+	}
+	if _, err := f.Write(key); err != nil {
+		_ = f.Close()
+		_ = os.Remove(name)
+		return "", fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(name)
+		return "", fmt.Errorf("failed to write key file: %w", err)
+	}
+	return name, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runRemoteGit runs a git command that talks to remote, authenticating with
+// the project's credentials for it.
+func (p *LocalProvider) runRemoteGit(ctx context.Context, projectID, remote, workDir string, args ...string) error {
+	env, cleanup, err := p.remoteEnv(ctx, projectID, remote)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	return p.runGitEnv(ctx, workDir, env, args...)
+}
+
+// originURL returns the URL of the origin remote of workDir, or "".
+func (p *LocalProvider) originURL(ctx context.Context, workDir string) string {
+	out, err := p.runGitOutput(ctx, workDir, "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// workspaceProject returns the project of an indexed workspace, or "".
+func (p *LocalProvider) workspaceProject(workspaceID string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if info, ok := p.workspaceIndex[workspaceID]; ok {
+		return info.projectID
+	}
+	return ""
+}
@@ -6,6 +6,8 @@ package git
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -18,6 +20,9 @@ var (
 	ErrFetchFailed    = errors.New("fetch failed")
 	ErrCheckoutFailed = errors.New("checkout failed")
 	ErrDirtyWorkTree  = errors.New("working tree has uncommitted changes")
+	ErrPushFailed     = errors.New("push failed")
+	ErrPatchConflict  = errors.New("patches conflict with the workspace")
+	ErrInvalidBranch  = errors.New("invalid branch name")
 )
 
 // WorkspaceSource provides workspace information to the git provider.
@@ -32,6 +37,8 @@ type WorkspaceInfo struct {
 	ProjectID   string
 	Path        string // git URL or local path
 	SourceType  string // "local" or "git"
+	Submodules  bool   // Check out submodules recursively
+	LFS         bool   // Download Git LFS objects
 }
 
 // Provider defines the interface for git operations.
@@ -41,6 +48,8 @@ type Provider interface {
 	// For git URLs: clones directly to the workspace directory.
 	// For local paths: clones to get an isolated working copy.
 	// projectID scopes the clone to a specific project's directory.
+	// Submodules and Git LFS objects are checked out when the workspace's
+	// WorkspaceInfo asks for them.
 	// Returns the absolute path to the working directory and the current HEAD commit SHA.
 	EnsureWorkspace(ctx context.Context, projectID, workspaceID, source, ref string) (workDir string, commit string, err error)
 
@@ -60,9 +69,11 @@ type Provider interface {
 	Branches(ctx context.Context, workspaceID string) ([]Branch, error)
 
 	// FileTree returns the file listing at a specific ref (or HEAD if empty).
+	// Git LFS files are listed with the size of their content.
 	FileTree(ctx context.Context, workspaceID, ref string) ([]FileEntry, error)
 
 	// ReadFile reads a file at a specific ref (or working tree if ref is empty).
+	// Git LFS pointers are resolved to their content when it is available.
 	ReadFile(ctx context.Context, workspaceID, ref, path string) ([]byte, error)
 
 	// WriteFile writes content to a file in the working tree.
@@ -89,9 +100,38 @@ type Provider interface {
 	// If application fails, the working tree is reset to the original state.
 	ApplyPatches(ctx context.Context, workspaceID string, patches []byte) (finalCommit string, err error)
 
+	// ApplyPatchesThreeWay applies mbox-format patches like ApplyPatches, but
+	// falls back to a three-way merge for patches whose context has changed.
+	// If the merge conflicts, the workspace is restored and a *ConflictError
+	// describing the conflicted files is returned.
+	ApplyPatchesThreeWay(ctx context.Context, workspaceID string, patches []byte) (finalCommit string, err error)
+
+	// ApplyPatchesToBranch applies patches like ApplyPatchesThreeWay, but to
+	// branch in a separate worktree, leaving the workspace checkout alone.
+	// A missing branch is created from origin's branch of the same name or,
+	// failing that, from the workspace HEAD. The branch must not be checked
+	// out in the workspace.
+	ApplyPatchesToBranch(ctx context.Context, workspaceID, branch string, patches []byte) (finalCommit string, err error)
+
+	// ResolveBranch returns the commit of a local branch, or of origin's
+	// branch of the same name. Returns ErrNotFound if neither exists.
+	ResolveBranch(ctx context.Context, workspaceID, branch string) (string, error)
+
+	// FetchRef fetches one ref of the workspace's origin remote, such as a
+	// branch or a pull request ref (refs/pull/12/head), and returns its
+	// commit. No local ref is created for it.
+	FetchRef(ctx context.Context, workspaceID, ref string) (string, error)
+
+	// FormatPatch returns the commits reachable from head but not from base
+	// as mbox-format patches (git format-patch).
+	FormatPatch(ctx context.Context, workspaceID, base, head string) ([]byte, error)
+
 	// GetUserConfig retrieves the global git user name and email configuration.
 	// Returns empty strings if not configured.
 	GetUserConfig(ctx context.Context) (name, email string)
+
+	// Push pushes a commit to a branch of the workspace's origin remote.
+	Push(ctx context.Context, workspaceID string, opts PushOptions) error
 }
 
 // Status represents the git status of a repository.
@@ -142,6 +182,35 @@ type FileDiff struct {
 	Patch     string `json:"patch"` // Unified diff content
 }
 
+// ConflictError is returned by ApplyPatchesThreeWay when a patch conflicts
+// with the workspace. It matches ErrPatchConflict with errors.Is.
+type ConflictError struct {
+	Patch     string     `json:"patch"` // Subject of the patch that failed to apply
+	Conflicts []Conflict `json:"conflicts"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: %q conflicts in %d file(s)", ErrPatchConflict, e.Patch, len(e.Conflicts))
+}
+
+func (e *ConflictError) Unwrap() error { return ErrPatchConflict }
+
+// Conflict is a file that could not be merged.
+type Conflict struct {
+	Path string `json:"path"`
+	// Conflict regions of the file. Empty for conflicts without markers,
+	// such as a patch modifying a file the workspace deleted.
+	Hunks []ConflictHunk `json:"hunks,omitempty"`
+}
+
+// ConflictHunk is one conflict region of a file.
+type ConflictHunk struct {
+	StartLine int    `json:"startLine"`      // 1-based line of the <<<<<<< marker
+	Ours      string `json:"ours"`           // Workspace side
+	Base      string `json:"base,omitempty"` // Common ancestor (diff3 conflict style only)
+	Theirs    string `json:"theirs"`         // Patch side
+}
+
 // Branch represents a git branch.
 type Branch struct {
 	Name      string `json:"name"`
@@ -149,6 +218,10 @@ type Branch struct {
 	IsCurrent bool   `json:"isCurrent"`
 	Commit    string `json:"commit"`   // HEAD commit SHA
 	Upstream  string `json:"upstream"` // Upstream branch name (if tracking)
+
+	// IDs of sessions committing to this branch. Set by the service layer;
+	// a branch that no session has committed to yet has no Commit.
+	Sessions []string `json:"sessions,omitempty"`
 }
 
 // FileEntry represents a file in the repository tree.
@@ -157,7 +230,8 @@ type FileEntry struct {
 	Name  string `json:"name"`
 	IsDir bool   `json:"isDir"`
 	Size  int64  `json:"size"`
-	Mode  string `json:"mode"` // File mode (e.g., "100644")
+	Mode  string `json:"mode"`          // File mode (e.g., "100644")
+	LFS   bool   `json:"lfs,omitempty"` // Stored with Git LFS; Size is that of the real content
 }
 
 // Commit represents a git commit.
@@ -188,6 +262,42 @@ type LogOptions struct {
 	Skip int
 }
 
+// PushOptions configures a push to the origin remote.
+type PushOptions struct {
+	// Commit or ref to push (default: HEAD)
+	Ref string
+
+	// Remote branch to update
+	Branch string
+
+	// Overwrite the remote branch even if it is not an ancestor
+	Force bool
+
+	// HTTPS basic auth credentials (optional)
+	Username string
+	Password string
+}
+
+// ValidateBranchName checks that name is a valid branch name, following the
+// rules of git check-ref-format --branch.
+func ValidateBranchName(name string) error {
+	invalid := name == "" || name == "@" ||
+		strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/") ||
+		strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") ||
+		strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") ||
+		strings.Contains(name, "/.") || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, " ~^:?*[\\\x7f")
+	for _, r := range name {
+		if r < 0x20 {
+			invalid = true
+		}
+	}
+	if invalid {
+		return fmt.Errorf("%w: %q", ErrInvalidBranch, name)
+	}
+	return nil
+}
+
 // IsGitURL returns true if the source looks like a git URL.
 func IsGitURL(source string) bool {
 	// Check common git URL patterns
@@ -0,0 +1,914 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/binary"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// GoGitProvider implements Provider with go-git, reading and writing the
+// repositories of workspaces directly instead of running the git CLI and
+// parsing its output. Status, Diff, Branches, Log, FileTree, ReadFile,
+// Stage, Commit and ApplyPatches run in process, as does ApplyPatchesThreeWay
+// for patches that apply cleanly. Cloning, fetching, pushing, three-way
+// merges and applying patches to other branches are inherited from
+// LocalProvider, so the git binary is still required.
+type GoGitProvider struct {
+	*LocalProvider
+}
+
+// NewGoGitProvider creates a go-git based provider. It takes the options of
+// NewLocalProvider and keeps workspaces in the same layout, so a server can
+// switch between the two on an existing baseDir.
+func NewGoGitProvider(baseDir string, opts ...LocalProviderOption) (*GoGitProvider, error) {
+	local, err := NewLocalProvider(baseDir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GoGitProvider{LocalProvider: local}, nil
+}
+
+// openRepo opens the repository of a workspace.
+func (p *GoGitProvider) openRepo(ctx context.Context, workspaceID string) (*gogit.Repository, string, error) {
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return nil, "", fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+	repo, err := gogit.PlainOpenWithOptions(workDir, &gogit.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s: %v", ErrNotARepository, workDir, err)
+	}
+	return repo, workDir, nil
+}
+
+// Status returns the current git status.
+func (p *GoGitProvider) Status(ctx context.Context, workspaceID string) (*Status, error) {
+	repo, _, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Staged:    []FileStatus{},
+		Unstaged:  []FileStatus{},
+		Untracked: []string{},
+	}
+
+	if head, err := repo.Head(); err == nil {
+		// A detached HEAD is reported as "HEAD", like git rev-parse --abbrev-ref
+		status.Branch = "HEAD"
+		if head.Name().IsBranch() {
+			status.Branch = head.Name().Short()
+		}
+		status.Commit = head.Hash().String()
+		status.CommitShort = status.Commit[:7]
+		status.Ahead, status.Behind = aheadBehind(repo, head)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	files, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// go-git uses the status codes of git status --porcelain
+	status.IsClean = true
+	for _, path := range paths {
+		file := files[path]
+		if file.Staging == gogit.Unmodified && file.Worktree == gogit.Unmodified {
+			continue
+		}
+		status.IsClean = false
+		p.addFileStatus(status, byte(file.Staging), byte(file.Worktree), path)
+	}
+
+	return status, nil
+}
+
+// aheadBehind counts the commits of HEAD's branch missing from its upstream
+// branch and the other way around. Both are 0 without an upstream.
+func aheadBehind(repo *gogit.Repository, head *plumbing.Reference) (ahead, behind int) {
+	if !head.Name().IsBranch() {
+		return 0, 0
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return 0, 0
+	}
+	branch, ok := cfg.Branches[head.Name().Short()]
+	if !ok || branch.Remote == "" || !branch.Merge.IsBranch() {
+		return 0, 0
+	}
+	upstreamName := plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short())
+	if branch.Remote == "." {
+		upstreamName = branch.Merge
+	}
+	upstream, err := repo.Reference(upstreamName, true)
+	if err != nil {
+		return 0, 0
+	}
+	return countMissing(repo, head.Hash(), upstream.Hash()), countMissing(repo, upstream.Hash(), head.Hash())
+}
+
+// countMissing counts the commits reachable from from but not from exclude.
+func countMissing(repo *gogit.Repository, from, exclude plumbing.Hash) int {
+	if from == exclude {
+		return 0
+	}
+	excluded := make(map[plumbing.Hash]bool)
+	if commit, err := repo.CommitObject(exclude); err == nil {
+		_ = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+	}
+	commit, err := repo.CommitObject(from)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	_ = object.NewCommitPreorderIter(commit, excluded, nil).ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	return count
+}
+
+// Diff returns file diffs. The unified diff of each file is generated by
+// go-git and parsed like the output of git diff.
+func (p *GoGitProvider) Diff(ctx context.Context, workspaceID string, opts DiffOptions) ([]FileDiff, error) {
+	repo, workDir, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	// Compare what git diff compares: the index to the working tree by
+	// default, a commit instead of the index with BaseRef, a commit (HEAD
+	// by default) to the index with Staged and two commits with both refs
+	var from, to map[string]treeFile
+	toWorktree := false
+	switch {
+	case opts.BaseRef != "" && opts.HeadRef != "":
+		if from, err = commitFiles(repo, opts.BaseRef); err != nil {
+			return nil, err
+		}
+		if to, err = commitFiles(repo, opts.HeadRef); err != nil {
+			return nil, err
+		}
+	case opts.Staged:
+		if from, err = commitFiles(repo, opts.BaseRef); err != nil {
+			return nil, err
+		}
+		to = indexFiles(idx)
+	default:
+		if opts.BaseRef != "" {
+			if from, err = commitFiles(repo, opts.BaseRef); err != nil {
+				return nil, err
+			}
+		} else {
+			from = indexFiles(idx)
+		}
+		to = worktreeFiles(workDir, idx)
+		toWorktree = true
+	}
+
+	patches, err := diffFiles(repo, workDir, from, to, toWorktree, opts.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	contextLines := fdiff.DefaultContextLines
+	if opts.Context > 0 {
+		contextLines = opts.Context
+	}
+	var out strings.Builder
+	if err := fdiff.NewUnifiedEncoder(&out, contextLines).Encode(patches); err != nil {
+		return nil, fmt.Errorf("failed to encode diff: %w", err)
+	}
+
+	return p.parseDiff(out.String()), nil
+}
+
+// Branches lists all branches.
+func (p *GoGitProvider) Branches(ctx context.Context, workspaceID string) ([]Branch, error) {
+	repo, _, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	head, _ := repo.Head() // nil on an unborn branch
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+
+	var branches []Branch
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		// Symbolic refs such as origin/HEAD are not branches
+		if ref.Type() != plumbing.HashReference || (!name.IsBranch() && !name.IsRemote()) {
+			return nil
+		}
+		branch := Branch{
+			Name:     name.Short(),
+			IsRemote: name.IsRemote(),
+			Commit:   ref.Hash().String()[:7],
+		}
+		if strings.HasSuffix(branch.Name, "/HEAD") {
+			return nil
+		}
+		if name.IsBranch() {
+			branch.IsCurrent = head != nil && head.Name() == name
+			if upstream, ok := cfg.Branches[branch.Name]; ok && upstream.Remote != "" && upstream.Merge.IsBranch() {
+				branch.Upstream = upstream.Remote + "/" + upstream.Merge.Short()
+				if upstream.Remote == "." {
+					branch.Upstream = upstream.Merge.Short()
+				}
+			}
+		}
+		branches = append(branches, branch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Local branches first, like git branch -a
+	sort.Slice(branches, func(i, j int) bool {
+		if branches[i].IsRemote != branches[j].IsRemote {
+			return !branches[i].IsRemote
+		}
+		return branches[i].Name < branches[j].Name
+	})
+
+	return branches, nil
+}
+
+// FileTree returns the file listing at a specific ref.
+func (p *GoGitProvider) FileTree(ctx context.Context, workspaceID, ref string) ([]FileEntry, error) {
+	repo, _, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := resolveCommit(repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", commit.Hash, err)
+	}
+
+	var entries []FileEntry
+	var blobs []plumbing.Hash // Blobs of the entries that may be LFS pointers
+	hasAttributes := false
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		path, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Like git ls-tree -r, list files and submodules but not directories
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+
+		var size int64
+		blob := plumbing.ZeroHash
+		if entry.Mode != filemode.Submodule {
+			if size, err = repo.Storer.EncodedObjectSize(entry.Hash); err != nil {
+				return nil, fmt.Errorf("failed to read size of %s: %w", path, err)
+			}
+			if size >= int64(len(lfsPointerPrefix)) && size <= lfsPointerMaxSize {
+				blob = entry.Hash
+			}
+		}
+		entries = append(entries, FileEntry{
+			Path: path,
+			Name: entry.Name,
+			Size: size,
+			Mode: fmt.Sprintf("%06o", uint32(entry.Mode)),
+		})
+		blobs = append(blobs, blob)
+		if entry.Name == ".gitattributes" {
+			hasAttributes = true
+		}
+	}
+
+	// Only repositories with attributes can track files with LFS
+	if hasAttributes {
+		for i, hash := range blobs {
+			if hash.IsZero() {
+				continue
+			}
+			content, err := readBlob(repo, hash)
+			if err != nil {
+				return nil, err
+			}
+			if ptr, ok := parseLFSPointer(content); ok {
+				entries[i].LFS = true
+				entries[i].Size = ptr.Size
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// ReadFile reads a file at a specific ref.
+func (p *GoGitProvider) ReadFile(ctx context.Context, workspaceID, ref, path string) ([]byte, error) {
+	repo, workDir, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if ref == "" {
+		// Read from working tree, where LFS files without their object
+		// downloaded are still pointers
+		if content, err = os.ReadFile(filepath.Join(workDir, path)); err != nil {
+			return nil, err
+		}
+	} else {
+		commit, err := resolveCommit(repo, ref)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s at %s", ErrNotFound, path, ref)
+		}
+		file, err := commit.File(filepath.ToSlash(path))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s at %s", ErrNotFound, path, ref)
+		}
+		if content, err = readBlob(repo, file.Hash); err != nil {
+			return nil, err
+		}
+	}
+
+	if ptr, ok := parseLFSPointer(content); ok {
+		_, commonDir := gitDirs(workDir)
+		if data, ok := readLFSObject(commonDir, ptr); ok {
+			return data, nil
+		}
+	}
+	return content, nil
+}
+
+// Stage stages files for commit.
+func (p *GoGitProvider) Stage(ctx context.Context, workspaceID string, paths []string) error {
+	repo, _, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := wt.Add(filepath.ToSlash(path)); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Commit creates a commit with the staged changes. Like git, the committer
+// is the configured user even when the author is given.
+func (p *GoGitProvider) Commit(ctx context.Context, workspaceID, message, authorName, authorEmail string) (*Commit, error) {
+	repo, _, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	// go-git only refuses to commit an empty index
+	files, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	staged := false
+	for _, file := range files {
+		if file.Staging != gogit.Unmodified && file.Staging != gogit.Untracked {
+			staged = true
+			break
+		}
+	}
+	if !staged {
+		return nil, fmt.Errorf("nothing to commit: no changes staged")
+	}
+
+	opts := &gogit.CommitOptions{}
+	if authorName != "" && authorEmail != "" {
+		now := time.Now()
+		opts.Author = &object.Signature{Name: authorName, Email: authorEmail, When: now}
+		opts.Committer = configSignature(repo, now)
+	}
+	hash, err := wt.Commit(strings.TrimSpace(message)+"\n", opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	info := commitInfo(commit)
+	return &info, nil
+}
+
+// Log returns commit history, newest commits first.
+func (p *GoGitProvider) Log(ctx context.Context, workspaceID string, opts LogOptions) ([]Commit, error) {
+	repo, _, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	from, err := resolveCommit(repo, opts.Ref)
+	if err != nil {
+		return nil, err
+	}
+	logOpts := &gogit.LogOptions{From: from.Hash, Order: gogit.LogOrderCommitterTime}
+	if len(opts.Paths) > 0 {
+		logOpts.PathFilter = func(path string) bool { return matchesPaths(path, opts.Paths) }
+	}
+	iter, err := repo.Log(logOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []Commit
+	skip := opts.Skip
+	err = iter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if skip > 0 {
+			skip--
+			return nil
+		}
+		commits = append(commits, commitInfo(c))
+		if len(commits) == limit {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// resolveCommit returns the commit a revision such as a branch, a tag, a
+// SHA or HEAD~2 names. An empty revision is HEAD.
+func resolveCommit(repo *gogit.Repository, rev string) (*object.Commit, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRef, rev)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a commit", ErrInvalidRef, rev)
+	}
+	return commit, nil
+}
+
+// readBlob returns the content of a blob.
+func readBlob(repo *gogit.Repository, hash plumbing.Hash) ([]byte, error) {
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", hash, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// configSignature returns the user configured for repo, or nil if there is
+// none.
+func configSignature(repo *gogit.Repository, when time.Time) *object.Signature {
+	cfg, err := repo.ConfigScoped(config.SystemScope)
+	if err != nil || cfg.User.Name == "" || cfg.User.Email == "" {
+		return nil
+	}
+	return &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: when}
+}
+
+// commitInfo converts a go-git commit. The message is the subject, as in
+// the git log format used by LocalProvider.
+func commitInfo(c *object.Commit) Commit {
+	var parents []string
+	for _, parent := range c.ParentHashes {
+		parents = append(parents, parent.String())
+	}
+	sha := c.Hash.String()
+	return Commit{
+		SHA:         sha,
+		ShortSHA:    sha[:7],
+		Message:     commitSubject(c.Message),
+		Author:      c.Author.Name,
+		AuthorEmail: c.Author.Email,
+		AuthorDate:  c.Author.When,
+		Committer:   c.Committer.Name,
+		CommitDate:  c.Committer.When,
+		Parents:     parents,
+	}
+}
+
+// commitSubject returns the first paragraph of a commit message on one
+// line, like git's %s placeholder.
+func commitSubject(message string) string {
+	paragraph, _, _ := strings.Cut(strings.TrimSpace(message), "\n\n")
+	lines := strings.Split(paragraph, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, " ")
+}
+
+// matchesPaths reports whether path is one of paths or inside one of them.
+func matchesPaths(path string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+		if p == "." || p == "" || path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// gitDirs returns the git directory of the working tree at workDir and the
+// common directory it shares with the repository's other worktrees.
+func gitDirs(workDir string) (gitDir, commonDir string) {
+	gitDir = filepath.Join(workDir, ".git")
+	// The .git of a linked worktree is a file pointing to its git directory
+	if data, err := os.ReadFile(gitDir); err == nil {
+		if dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: "); ok {
+			gitDir = dir
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(workDir, gitDir)
+			}
+		}
+	}
+	commonDir = gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	return filepath.Clean(gitDir), filepath.Clean(commonDir)
+}
+
+// --- Diffs ---
+
+// treeFile is a file in a commit's tree, the index or the working tree.
+type treeFile struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+// commitFiles returns the files of a commit's tree by path. The files of
+// an unborn HEAD are empty.
+func commitFiles(repo *gogit.Repository, rev string) (map[string]treeFile, error) {
+	files := make(map[string]treeFile)
+	if rev == "" || rev == "HEAD" {
+		if _, err := repo.Head(); err == plumbing.ErrReferenceNotFound {
+			return files, nil
+		}
+	}
+	commit, err := resolveCommit(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", commit.Hash, err)
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		path, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode != filemode.Dir {
+			files[path] = treeFile{hash: entry.Hash, mode: entry.Mode}
+		}
+	}
+	return files, nil
+}
+
+// indexFiles returns the files of the index by path. Unmerged files are
+// left out; merged entries are at stage 0 (go-git's index.Merged is 1).
+func indexFiles(idx *index.Index) map[string]treeFile {
+	files := make(map[string]treeFile, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if e.Stage == 0 {
+			files[e.Name] = treeFile{hash: e.Hash, mode: e.Mode}
+		}
+	}
+	return files
+}
+
+// worktreeFiles returns the files of the working tree at workDir that are
+// in the index, by path. Files whose size and modification time match
+// their index entry are not read again, unless they were modified too
+// shortly before the index was written to tell.
+func worktreeFiles(workDir string, idx *index.Index) map[string]treeFile {
+	var indexTime time.Time
+	gitDir, _ := gitDirs(workDir)
+	if info, err := os.Stat(filepath.Join(gitDir, "index")); err == nil {
+		indexTime = info.ModTime()
+	}
+
+	files := make(map[string]treeFile, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			continue
+		}
+		// Submodule checkouts are not compared
+		if e.Mode == filemode.Submodule {
+			files[e.Name] = treeFile{hash: e.Hash, mode: e.Mode}
+			continue
+		}
+
+		fullPath := filepath.Join(workDir, filepath.FromSlash(e.Name))
+		info, err := os.Lstat(fullPath)
+		if err != nil || info.IsDir() {
+			continue // Deleted
+		}
+		mode, err := filemode.NewFromOSFileMode(info.Mode())
+		if err != nil {
+			continue
+		}
+		if mode == e.Mode && uint32(info.Size()) == e.Size && info.ModTime().Equal(e.ModifiedAt) && info.ModTime().Before(indexTime) {
+			files[e.Name] = treeFile{hash: e.Hash, mode: mode}
+			continue
+		}
+
+		content, err := readWorktreeFile(fullPath, mode)
+		if err != nil {
+			continue
+		}
+		files[e.Name] = treeFile{hash: plumbing.ComputeHash(plumbing.BlobObject, content), mode: mode}
+	}
+	return files
+}
+
+// readWorktreeFile returns the content git stores for a file of the working
+// tree: the target of symlinks.
+func readWorktreeFile(fullPath string, mode filemode.FileMode) ([]byte, error) {
+	if mode == filemode.Symlink {
+		target, err := os.Readlink(fullPath)
+		return []byte(filepath.ToSlash(target)), err
+	}
+	return os.ReadFile(fullPath)
+}
+
+// diffFiles compares the files of two sides of a diff, limited to paths.
+// Files moved without changes are reported as renames, like git's exact
+// rename detection.
+func diffFiles(repo *gogit.Repository, workDir string, from, to map[string]treeFile, toWorktree bool, paths []string) (filePatches, error) {
+	var all []string
+	for path := range from {
+		if matchesPaths(path, paths) {
+			all = append(all, path)
+		}
+	}
+	for path := range to {
+		if _, ok := from[path]; !ok && matchesPaths(path, paths) {
+			all = append(all, path)
+		}
+	}
+	sort.Strings(all)
+
+	// Pair deleted files with added files of the same content
+	deleted := make(map[plumbing.Hash][]string)
+	for _, path := range all {
+		if f, ok := from[path]; ok && f.mode != filemode.Submodule {
+			if _, ok := to[path]; !ok {
+				deleted[f.hash] = append(deleted[f.hash], path)
+			}
+		}
+	}
+	renamedTo := make(map[string]string)   // Old path to new path
+	renamedFrom := make(map[string]string) // New path to old path
+	for _, path := range all {
+		if _, ok := from[path]; ok {
+			continue
+		}
+		t := to[path]
+		if candidates := deleted[t.hash]; len(candidates) > 0 && t.mode != filemode.Submodule {
+			renamedTo[candidates[0]] = path
+			renamedFrom[path] = candidates[0]
+			deleted[t.hash] = candidates[1:]
+		}
+	}
+
+	var patches filePatches
+	for _, path := range all {
+		f, inFrom := from[path]
+		t, inTo := to[path]
+		var fp *filePatch
+		var err error
+		switch {
+		case inFrom && inTo:
+			if f == t {
+				continue
+			}
+			fp, err = newFilePatch(repo, workDir, toWorktree, path, &f, path, &t)
+		case inFrom:
+			if newPath, ok := renamedTo[path]; ok {
+				newFile := to[newPath]
+				fp, err = newFilePatch(repo, workDir, toWorktree, path, &f, newPath, &newFile)
+			} else {
+				fp, err = newFilePatch(repo, workDir, toWorktree, path, &f, "", nil)
+			}
+		default:
+			if _, ok := renamedFrom[path]; ok {
+				continue
+			}
+			fp, err = newFilePatch(repo, workDir, toWorktree, "", nil, path, &t)
+		}
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, fp)
+	}
+	return patches, nil
+}
+
+// newFilePatch diffs the content of a file, which is nil on the side where
+// it does not exist.
+func newFilePatch(repo *gogit.Repository, workDir string, toWorktree bool, fromPath string, from *treeFile, toPath string, to *treeFile) (*filePatch, error) {
+	fp := &filePatch{}
+	var oldContent, newContent []byte
+	var err error
+	if from != nil {
+		fp.from = &patchFile{path: fromPath, treeFile: *from}
+		if oldContent, err = fileContent(repo, workDir, false, fromPath, *from); err != nil {
+			return nil, err
+		}
+	}
+	if to != nil {
+		fp.to = &patchFile{path: toPath, treeFile: *to}
+		if newContent, err = fileContent(repo, workDir, toWorktree, toPath, *to); err != nil {
+			return nil, err
+		}
+	}
+	if from != nil && to != nil && from.hash == to.hash {
+		return fp, nil // Only the mode or path changed
+	}
+
+	fp.binary = isBinary(oldContent) || isBinary(newContent)
+	if fp.binary {
+		return fp, nil
+	}
+	for _, d := range diff.Do(string(oldContent), string(newContent)) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		}
+		fp.chunks = append(fp.chunks, chunk{content: d.Text, op: op})
+	}
+	return fp, nil
+}
+
+// fileContent returns the content of a file on one side of a diff. The
+// content of a submodule is the commit it is at, as shown by git diff.
+func fileContent(repo *gogit.Repository, workDir string, worktree bool, path string, f treeFile) ([]byte, error) {
+	if f.mode == filemode.Submodule {
+		return []byte("Subproject commit " + f.hash.String() + "\n"), nil
+	}
+	if worktree {
+		return readWorktreeFile(filepath.Join(workDir, filepath.FromSlash(path)), f.mode)
+	}
+	return readBlob(repo, f.hash)
+}
+
+// isBinary reports whether content looks binary to git.
+func isBinary(content []byte) bool {
+	ok, _ := binary.IsBinary(bytes.NewReader(content))
+	return ok
+}
+
+// filePatches implements diff.Patch for go-git's unified diff encoder.
+type filePatches []*filePatch
+
+func (p filePatches) FilePatches() []fdiff.FilePatch {
+	patches := make([]fdiff.FilePatch, len(p))
+	for i, fp := range p {
+		patches[i] = fp
+	}
+	return patches
+}
+
+func (p filePatches) Message() string { return "" }
+
+// filePatch implements diff.FilePatch.
+type filePatch struct {
+	from, to *patchFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (p *filePatch) IsBinary() bool { return p.binary }
+
+func (p *filePatch) Files() (from, to fdiff.File) {
+	// Missing sides must be nil interfaces
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+func (p *filePatch) Chunks() []fdiff.Chunk { return p.chunks }
+
+// patchFile implements diff.File.
+type patchFile struct {
+	treeFile
+	path string
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+// chunk implements diff.Chunk.
+type chunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c chunk) Content() string       { return c.content }
+func (c chunk) Type() fdiff.Operation { return c.op }
+
+// Ensure GoGitProvider implements Provider
+var _ Provider = (*GoGitProvider)(nil)
@@ -0,0 +1,489 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ApplyPatches applies mbox-format patches (from git format-patch) like git
+// am. The patches are applied to HEAD's tree in memory, so HEAD, the index
+// and the working tree are only updated once all of them applied. Like git
+// am, it refuses to touch files with local changes and to commit on top of
+// staged changes. The committer of the new commits is the configured user,
+// or the patch author if there is none.
+func (p *GoGitProvider) ApplyPatches(ctx context.Context, workspaceID string, patches []byte) (string, error) {
+	commit, _, err := p.applyPatches(ctx, workspaceID, patches)
+	return commit, err
+}
+
+// ApplyPatchesThreeWay applies patches that apply cleanly like ApplyPatches.
+// Others are left to git am -3 of LocalProvider, which merges them and
+// reports conflicts.
+func (p *GoGitProvider) ApplyPatchesThreeWay(ctx context.Context, workspaceID string, patches []byte) (string, error) {
+	commit, updated, err := p.applyPatches(ctx, workspaceID, patches)
+	if err == nil || updated || errors.Is(err, ErrNotFound) || errors.Is(err, ErrNotARepository) || ctx.Err() != nil {
+		return commit, err
+	}
+	return p.LocalProvider.ApplyPatchesThreeWay(ctx, workspaceID, patches)
+}
+
+// applyPatches implements ApplyPatches. updated reports whether HEAD was
+// moved to the new commits, even if checking them out failed.
+func (p *GoGitProvider) applyPatches(ctx context.Context, workspaceID string, patches []byte) (commitID string, updated bool, err error) {
+	repo, workDir, err := p.openRepo(ctx, workspaceID)
+	if err != nil {
+		return "", false, err
+	}
+
+	mails, err := parseMbox(patches)
+	if err != nil {
+		return "", false, fmt.Errorf("patches will not apply cleanly: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	baseTree, err := headCommit.Tree()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", false, err
+	}
+	if err := checkLocalChanges(wt, mails); err != nil {
+		return "", false, fmt.Errorf("patches will not apply cleanly: %w", err)
+	}
+
+	editor := &treeEditor{repo: repo, base: baseTree, changes: make(map[string]*object.TreeEntry)}
+	commit := head.Hash()
+	for _, mail := range mails {
+		if err := ctx.Err(); err != nil {
+			return "", false, err
+		}
+		if err := editor.apply(mail.files); err != nil {
+			return "", false, fmt.Errorf("failed to apply patches: %q: %w", mail.header.Title, err)
+		}
+		tree, err := editor.write()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to write tree: %w", err)
+		}
+		if commit, err = writePatchCommit(repo, tree, commit, mail.header); err != nil {
+			return "", false, fmt.Errorf("failed to commit %q: %w", mail.header.Title, err)
+		}
+	}
+
+	// HEAD is a branch, or the commit itself when detached
+	if err := repo.Storer.CheckAndSetReference(plumbing.NewHashReference(head.Name(), commit), head); err != nil {
+		return "", false, fmt.Errorf("failed to update %s: %w", head.Name(), err)
+	}
+	if err := editor.checkout(workDir); err != nil {
+		return "", true, fmt.Errorf("failed to check out patched files: %w", err)
+	}
+	p.syncAfterApply(ctx, workspaceID, workDir)
+
+	return commit.String(), true, nil
+}
+
+// mboxPatch is one patch of an mbox.
+type mboxPatch struct {
+	header *gitdiff.PatchHeader
+	files  []*gitdiff.File
+}
+
+// mboxFromRe matches the line starting each patch written by git
+// format-patch.
+var mboxFromRe = regexp.MustCompile(`(?m)^From [0-9a-f]{40} `)
+
+// parseMbox splits an mbox into its patches and parses them.
+func parseMbox(data []byte) ([]mboxPatch, error) {
+	starts := mboxFromRe.FindAllIndex(data, -1)
+	if len(starts) == 0 {
+		return nil, errors.New("no patches found")
+	}
+
+	var patches []mboxPatch
+	for i, start := range starts {
+		end := len(data)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		files, preamble, err := gitdiff.Parse(bytes.NewReader(data[start[0]:end]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid patch %d: %w", i+1, err)
+		}
+		header, err := gitdiff.ParsePatchHeader(preamble)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header of patch %d: %w", i+1, err)
+		}
+		if header.Author == nil {
+			return nil, fmt.Errorf("patch %q has no author", header.Title)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("patch %q is empty", header.Title)
+		}
+		patches = append(patches, mboxPatch{header: header, files: files})
+	}
+	return patches, nil
+}
+
+// checkLocalChanges returns an error if changes are staged or if the
+// patches touch files with local changes, which git am refuses too.
+func checkLocalChanges(wt *gogit.Worktree, mails []mboxPatch) error {
+	status, err := wt.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+	for path, file := range status {
+		if file.Staging != gogit.Unmodified && file.Staging != gogit.Untracked {
+			return fmt.Errorf("dirty index: %s has staged changes", path)
+		}
+	}
+	for _, mail := range mails {
+		for _, f := range mail.files {
+			for _, name := range []string{f.OldName, f.NewName} {
+				if file, ok := status[name]; ok && file.Worktree != gogit.Unmodified {
+					return fmt.Errorf("%s: local changes would be overwritten", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writePatchCommit commits tree on top of parent with the author and message
+// of a patch.
+func writePatchCommit(repo *gogit.Repository, tree, parent plumbing.Hash, header *gitdiff.PatchHeader) (plumbing.Hash, error) {
+	author := object.Signature{Name: header.Author.Name, Email: header.Author.Email, When: header.AuthorDate}
+	now := time.Now()
+	if author.When.IsZero() {
+		author.When = now
+	}
+	committer := configSignature(repo, now)
+	if committer == nil {
+		committer = &object.Signature{Name: author.Name, Email: author.Email, When: now}
+	}
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    *committer,
+		Message:      header.Message() + "\n",
+		TreeHash:     tree,
+		ParentHashes: []plumbing.Hash{parent},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// treeEditor applies patches to a tree in memory.
+type treeEditor struct {
+	repo *gogit.Repository
+	base *object.Tree
+
+	// Changed files by path; deleted files are nil
+	changes map[string]*object.TreeEntry
+}
+
+// entry returns the current entry of a file, or nil if there is none.
+func (e *treeEditor) entry(name string) *object.TreeEntry {
+	if entry, ok := e.changes[name]; ok {
+		return entry
+	}
+	entry, err := e.base.FindEntry(name)
+	if err != nil || entry.Mode == filemode.Dir {
+		return nil
+	}
+	return entry
+}
+
+// content returns the content of a file entry. The content of a submodule
+// is the commit it is at, as in the patch.
+func (e *treeEditor) content(entry *object.TreeEntry) ([]byte, error) {
+	if entry.Mode == filemode.Submodule {
+		return []byte("Subproject commit " + entry.Hash.String() + "\n"), nil
+	}
+	return readBlob(e.repo, entry.Hash)
+}
+
+// apply applies the changes of one patch.
+func (e *treeEditor) apply(files []*gitdiff.File) error {
+	for _, f := range files {
+		var src []byte
+		mode := filemode.Regular
+		if f.IsNew {
+			if e.entry(f.NewName) != nil {
+				return fmt.Errorf("%s: already exists", f.NewName)
+			}
+		} else {
+			entry := e.entry(f.OldName)
+			if entry == nil {
+				return fmt.Errorf("%s: does not exist", f.OldName)
+			}
+			var err error
+			if src, err = e.content(entry); err != nil {
+				return err
+			}
+			mode = entry.Mode
+		}
+
+		if f.IsDelete {
+			e.changes[f.OldName] = nil
+			continue
+		}
+
+		var dst bytes.Buffer
+		if err := gitdiff.Apply(&dst, bytes.NewReader(src), f); err != nil {
+			return fmt.Errorf("%s: %w", f.NewName, err)
+		}
+		// Patches carry git's modes, such as 0100644
+		if f.NewMode != 0 {
+			mode = filemode.FileMode(f.NewMode)
+		}
+
+		var hash plumbing.Hash
+		if mode == filemode.Submodule {
+			sha, ok := strings.CutPrefix(strings.TrimSpace(dst.String()), "Subproject commit ")
+			if !ok || !plumbing.IsHash(sha) {
+				return fmt.Errorf("%s: invalid submodule commit", f.NewName)
+			}
+			hash = plumbing.NewHash(sha)
+		} else {
+			var err error
+			if hash, err = writeBlob(e.repo, dst.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		if f.IsRename {
+			e.changes[f.OldName] = nil
+		}
+		e.changes[f.NewName] = &object.TreeEntry{Name: path.Base(f.NewName), Mode: mode, Hash: hash}
+	}
+	return nil
+}
+
+// write stores the base tree with the changes applied and returns its hash.
+func (e *treeEditor) write() (plumbing.Hash, error) {
+	hash, _, err := e.writeTree(e.base, e.changes)
+	return hash, err
+}
+
+// writeTree stores tree with changes to paths relative to it applied.
+// Empty subtrees are not stored and reported as such.
+func (e *treeEditor) writeTree(tree *object.Tree, changes map[string]*object.TreeEntry) (plumbing.Hash, bool, error) {
+	entries := make(map[string]object.TreeEntry)
+	if tree != nil {
+		for _, entry := range tree.Entries {
+			entries[entry.Name] = entry
+		}
+	}
+
+	files := make(map[string]*object.TreeEntry)
+	dirs := make(map[string]map[string]*object.TreeEntry)
+	for name, change := range changes {
+		dir, rest, nested := strings.Cut(name, "/")
+		if !nested {
+			files[name] = change
+			continue
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]*object.TreeEntry)
+		}
+		dirs[dir][rest] = change
+	}
+
+	// Directories go first, so a file replacing a directory whose files
+	// were deleted is not removed with it
+	for dir, changes := range dirs {
+		var subtree *object.Tree
+		if entry, ok := entries[dir]; ok && entry.Mode == filemode.Dir {
+			var err error
+			if subtree, err = e.repo.TreeObject(entry.Hash); err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+		}
+		hash, empty, err := e.writeTree(subtree, changes)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+		if empty {
+			delete(entries, dir)
+		} else {
+			entries[dir] = object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash}
+		}
+	}
+	for name, change := range files {
+		switch {
+		case change != nil:
+			entries[name] = *change
+		case entries[name].Mode != filemode.Dir:
+			// A deleted file may have been replaced by a directory above
+			delete(entries, name)
+		}
+	}
+
+	if len(entries) == 0 && tree != e.base {
+		return plumbing.ZeroHash, true, nil
+	}
+
+	// Git sorts tree entries by name, with a slash after directory names
+	sorted := make([]object.TreeEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return treeEntryKey(sorted[i]) < treeEntryKey(sorted[j])
+	})
+
+	obj := e.repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{Entries: sorted}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	hash, err := e.repo.Storer.SetEncodedObject(obj)
+	return hash, len(entries) == 0, err
+}
+
+func treeEntryKey(entry object.TreeEntry) string {
+	if entry.Mode == filemode.Dir {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
+
+// checkout writes the changed files to the working tree at workDir and the
+// index. Submodules are left for syncAfterApply to check out.
+func (e *treeEditor) checkout(workDir string) error {
+	idx, err := e.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(e.changes))
+	for name := range e.changes {
+		names = append(names, name)
+	}
+	// Deletions first, to make room for files replacing directories
+	sort.Slice(names, func(i, j int) bool {
+		if deleted := e.changes[names[i]] == nil; deleted != (e.changes[names[j]] == nil) {
+			return deleted
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		entry := e.changes[name]
+		fullPath := filepath.Join(workDir, filepath.FromSlash(name))
+		if entry == nil {
+			_, _ = idx.Remove(name)
+			if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removeEmptyDirs(workDir, filepath.Dir(fullPath))
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return err
+		}
+		if entry.Mode == filemode.Submodule {
+			if err := os.MkdirAll(fullPath, 0755); err != nil {
+				return err
+			}
+			indexEntry(idx, name).Hash, indexEntry(idx, name).Mode = entry.Hash, entry.Mode
+			continue
+		}
+
+		content, err := readBlob(e.repo, entry.Hash)
+		if err != nil {
+			return err
+		}
+		// Replace the file, as writing keeps the mode of an existing one
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		switch entry.Mode {
+		case filemode.Symlink:
+			err = os.Symlink(filepath.FromSlash(string(content)), fullPath)
+		case filemode.Executable:
+			err = os.WriteFile(fullPath, content, 0755)
+		default:
+			err = os.WriteFile(fullPath, content, 0644)
+		}
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Lstat(fullPath)
+		if err != nil {
+			return err
+		}
+		ie := indexEntry(idx, name)
+		ie.Hash = entry.Hash
+		ie.Mode = entry.Mode
+		ie.Size = uint32(info.Size())
+		ie.ModifiedAt = info.ModTime()
+	}
+
+	return e.repo.Storer.SetIndex(idx)
+}
+
+// indexEntry returns the index entry of a file, adding one if needed.
+func indexEntry(idx *index.Index, name string) *index.Entry {
+	if entry, err := idx.Entry(name); err == nil {
+		return entry
+	}
+	return idx.Add(name)
+}
+
+// removeEmptyDirs removes dir and its parents up to root while they are
+// empty, like git does when deleting files.
+func removeEmptyDirs(root, dir string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// writeBlob stores content as a blob.
+func writeBlob(repo *gogit.Repository, content []byte) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		_ = w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
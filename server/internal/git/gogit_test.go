@@ -0,0 +1,142 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newGoGitTestProvider(t *testing.T, baseDir string, opts ...LocalProviderOption) Provider {
+	t.Helper()
+
+	provider, err := NewGoGitProvider(baseDir, opts...)
+	if err != nil {
+		t.Fatalf("NewGoGitProvider failed: %v", err)
+	}
+	return provider
+}
+
+// TestGoGitProvider runs the tests of the operations GoGitProvider
+// implements itself against it.
+func TestGoGitProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(*testing.T, testProviderFunc)
+	}{
+		{"Status", testStatus},
+		{"Diff", testDiff},
+		{"Branches", testBranches},
+		{"FileTree", testFileTree},
+		{"ReadFile", testReadFile},
+		{"Stage", testStage},
+		{"Commit", testCommit},
+		{"Log", testLog},
+		{"ApplyPatches", testApplyPatches},
+		{"ApplyPatchesThreeWay", testApplyPatchesThreeWay},
+		{"Submodules", testSubmodules},
+		{"LFSPointers", testLFSPointers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newGoGitTestProvider)
+		})
+	}
+}
+
+// TestGoGitProvider_ApplyPatchesMatchesGitAm checks that patches applied by
+// GoGitProvider produce the same tree as git am.
+func TestGoGitProvider_ApplyPatchesMatchesGitAm(t *testing.T) {
+	ctx := context.Background()
+
+	sourceRepo := createTestRepo(t)
+	writeFile := func(dir, name, content string, perm os.FileMode) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(sourceRepo, "src/main.go", "package main\n\nfunc main() {}\n", 0644)
+	writeFile(sourceRepo, "src/util/util.go", "package util\n", 0644)
+	writeFile(sourceRepo, "docs/old.md", "# Old\n\nSome docs\n", 0644)
+	writeFile(sourceRepo, "obsolete.txt", "remove me\n", 0644)
+	writeFile(sourceRepo, "run.sh", "#!/bin/sh\necho hi\n", 0644)
+	runGit(t, sourceRepo, "add", ".")
+	runGit(t, sourceRepo, "commit", "-m", "Add files")
+
+	// Build a series of patches covering the kinds of changes git supports
+	patchRepo := t.TempDir()
+	runGit(t, patchRepo, "clone", sourceRepo, ".")
+	runGit(t, patchRepo, "config", "user.email", "patch@example.com")
+	runGit(t, patchRepo, "config", "user.name", "Patch Author")
+	base := strings.TrimSpace(runGit(t, patchRepo, "rev-parse", "HEAD"))
+
+	writeFile(patchRepo, "src/main.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n", 0644)
+	writeFile(patchRepo, "src/util/deep/nested.go", "package deep\n", 0644)
+	runGit(t, patchRepo, "add", ".")
+	runGit(t, patchRepo, "commit", "-m", "Modify and add nested files")
+
+	runGit(t, patchRepo, "rm", "-q", "obsolete.txt", "src/util/util.go")
+	runGit(t, patchRepo, "mv", "docs/old.md", "docs/new.md")
+	runGit(t, patchRepo, "commit", "-m", "Delete and rename files")
+
+	runGit(t, patchRepo, "update-index", "--chmod=+x", "run.sh")
+	writeFile(patchRepo, "image.bin", "\x89PNG\x00\x01\x02\x03binary", 0644)
+	runGit(t, patchRepo, "add", "image.bin")
+	runGit(t, patchRepo, "commit", "-m", "Make executable and add binary")
+
+	patches := runGit(t, patchRepo, "format-patch", "--stdout", "--binary", base+"..HEAD")
+
+	// Apply them with git am
+	amRepo := t.TempDir()
+	runGit(t, amRepo, "clone", sourceRepo, ".")
+	runGit(t, amRepo, "config", "user.email", "committer@example.com")
+	runGit(t, amRepo, "config", "user.name", "Test Committer")
+	cmd := exec.Command("git", "am")
+	cmd.Dir = amRepo
+	cmd.Env = cleanGitEnv()
+	cmd.Stdin = strings.NewReader(patches)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git am failed: %v\n%s", err, out)
+	}
+	wantTree := strings.TrimSpace(runGit(t, amRepo, "rev-parse", "HEAD^{tree}"))
+
+	// Apply them with GoGitProvider
+	provider := newGoGitTestProvider(t, t.TempDir())
+	workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+	runGit(t, workDir, "config", "user.email", "committer@example.com")
+	runGit(t, workDir, "config", "user.name", "Test Committer")
+	commit, err := provider.ApplyPatches(ctx, "ws1", []byte(patches))
+	if err != nil {
+		t.Fatalf("ApplyPatches failed: %v", err)
+	}
+
+	if tree := strings.TrimSpace(runGit(t, workDir, "rev-parse", commit+"^{tree}")); tree != wantTree {
+		t.Errorf("tree = %s, want %s (git am)", tree, wantTree)
+	}
+	if count := strings.TrimSpace(runGit(t, workDir, "rev-list", "--count", base+".."+commit)); count != "3" {
+		t.Errorf("commits applied = %s, want 3", count)
+	}
+	if status := runGit(t, workDir, "status", "--porcelain"); status != "" {
+		t.Errorf("working tree not clean after ApplyPatches:\n%s", status)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "src", "util", "util.go")); !os.IsNotExist(err) {
+		t.Errorf("deleted file still in working tree: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(workDir, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("run.sh mode = %v, want executable", info.Mode())
+	}
+}
@@ -0,0 +1,170 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Git LFS replaces the content of tracked files in the repository with small
+// pointer files; the content lives in .git/lfs/objects once downloaded.
+
+const (
+	// lfsPointerPrefix starts every Git LFS pointer file.
+	lfsPointerPrefix = "version https://git-lfs.github.com/spec/v1\n"
+
+	// lfsPointerMaxSize is the size limit of pointer files set by the spec.
+	lfsPointerMaxSize = 1024
+)
+
+var errLFSNotInstalled = errors.New("git-lfs is not installed")
+
+var lfsOIDRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// lfsPointer is a parsed Git LFS pointer file.
+type lfsPointer struct {
+	OID  string // sha256 of the content
+	Size int64  // size of the content
+}
+
+// parseLFSPointer parses data as a Git LFS pointer file.
+func parseLFSPointer(data []byte) (*lfsPointer, bool) {
+	if len(data) > lfsPointerMaxSize || !bytes.HasPrefix(data, []byte(lfsPointerPrefix)) {
+		return nil, false
+	}
+	ptr := &lfsPointer{Size: -1}
+	for _, line := range strings.Split(string(data[len(lfsPointerPrefix):]), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			ptr.OID, _ = strings.CutPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			ptr.Size = size
+		}
+	}
+	if !lfsOIDRe.MatchString(ptr.OID) || ptr.Size < 0 {
+		return nil, false
+	}
+	return ptr, true
+}
+
+// gitCommonDir returns the git directory shared by workDir's worktrees,
+// where LFS objects are stored.
+func (p *LocalProvider) gitCommonDir(ctx context.Context, workDir string) string {
+	out, err := p.runGitOutput(ctx, workDir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return filepath.Join(workDir, ".git")
+	}
+	gitDir := strings.TrimSpace(out)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(workDir, gitDir)
+	}
+	return gitDir
+}
+
+// lfsObjectPath returns where the repository at gitDir stores an LFS object.
+func lfsObjectPath(gitDir, oid string) string {
+	return filepath.Join(gitDir, "lfs", "objects", oid[:2], oid[2:4], oid)
+}
+
+// readLFSObject reads the content ptr stands for from the repository at
+// gitDir, if it has been downloaded.
+func readLFSObject(gitDir string, ptr *lfsPointer) ([]byte, bool) {
+	content, err := os.ReadFile(lfsObjectPath(gitDir, ptr.OID))
+	if err != nil || int64(len(content)) != ptr.Size {
+		return nil, false
+	}
+	return content, true
+}
+
+// resolveLFS returns the content data stands for if it is an LFS pointer
+// whose object has been downloaded, and data itself otherwise.
+func (p *LocalProvider) resolveLFS(ctx context.Context, workDir string, data []byte) []byte {
+	ptr, ok := parseLFSPointer(data)
+	if !ok {
+		return data
+	}
+	if content, ok := readLFSObject(p.gitCommonDir(ctx, workDir), ptr); ok {
+		return content
+	}
+	return data
+}
+
+// readLFSPointers reads the given blobs and returns those that are LFS
+// pointers, keyed by blob SHA.
+func (p *LocalProvider) readLFSPointers(ctx context.Context, workDir string, shas []string) (map[string]*lfsPointer, error) {
+	pointers := make(map[string]*lfsPointer)
+	if len(shas) == 0 {
+		return pointers, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Dir = workDir
+	cmd.Env = cleanGitEnv()
+	cmd.Stdin = strings.NewReader(strings.Join(shas, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file --batch: %w", err)
+	}
+
+	// Each object is "<sha> <type> <size>\n<content>\n"
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue // "<sha> missing"
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git cat-file header %q", header)
+		}
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, err
+		}
+		if ptr, ok := parseLFSPointer(content[:size]); ok {
+			pointers[fields[0]] = ptr
+		}
+	}
+	return pointers, nil
+}
+
+// pullLFS enables the LFS filters in workDir and downloads the objects its
+// checkout needs, with the project's credentials for the origin remote.
+func (p *LocalProvider) pullLFS(ctx context.Context, projectID, workDir string, submodules bool) error {
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		return errLFSNotInstalled
+	}
+	if err := p.runGit(ctx, workDir, "lfs", "install", "--local"); err != nil {
+		return err
+	}
+	remote := p.originURL(ctx, workDir)
+	if err := p.runRemoteGit(ctx, projectID, remote, workDir, "lfs", "pull"); err != nil {
+		return err
+	}
+	if submodules {
+		return p.runRemoteGit(ctx, projectID, remote, workDir,
+			"submodule", "foreach", "--quiet", "--recursive", "git lfs install --local && git lfs pull")
+	}
+	return nil
+}
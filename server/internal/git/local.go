@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -24,6 +25,16 @@ type LocalProvider struct {
 	// workspaceSource provides workspace info for lookup operations
 	workspaceSource WorkspaceSource
 
+	// credentialSource provides credentials for private remotes
+	credentialSource CredentialSource
+
+	// cloneDepth makes mirrors shallow when positive
+	cloneDepth int
+
+	// Bare mirrors of remotes that workspaces are cloned from, keyed by path
+	mirrorsMu sync.Mutex
+	mirrors   map[string]*mirror
+
 	// Per-project mutexes for EnsureWorkspace operations
 	projectMu    sync.Mutex
 	projectLocks map[string]*sync.Mutex
@@ -65,6 +76,7 @@ func NewLocalProvider(baseDir string, opts ...LocalProviderOption) (*LocalProvid
 		baseDir:        baseDir,
 		projectLocks:   make(map[string]*sync.Mutex),
 		workspaceIndex: make(map[string]*workspaceInfo),
+		mirrors:        make(map[string]*mirror),
 	}
 
 	for _, opt := range opts {
@@ -148,14 +160,13 @@ func (p *LocalProvider) EnsureWorkspace(ctx context.Context, projectID, workspac
 	var info *workspaceInfo
 
 	if IsGitURL(source) {
-		// Remote repository - clone directly
-		args := []string{"clone"}
-		if ref != "" {
-			args = append(args, "-b", ref)
+		// Remote repository - clone from the project's mirror of it, so only
+		// the first workspace of a remote downloads its history
+		mirrorDir, err := p.ensureMirror(ctx, projectID, source)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrCloneFailed, err)
 		}
-		args = append(args, source, workDir)
-
-		if err := p.runGit(ctx, "", args...); err != nil {
+		if err := p.cloneFromMirror(ctx, mirrorDir, source, ref, workDir); err != nil {
 			return "", "", fmt.Errorf("%w: %v", ErrCloneFailed, err)
 		}
 
@@ -197,6 +208,11 @@ func (p *LocalProvider) EnsureWorkspace(ctx context.Context, projectID, workspac
 		}
 	}
 
+	if err := p.checkoutExtras(ctx, projectID, workspaceID, workDir); err != nil {
+		_ = os.RemoveAll(workDir)
+		return "", "", fmt.Errorf("%w: %v", ErrCloneFailed, err)
+	}
+
 	p.mu.Lock()
 	p.workspaceIndex[workspaceID] = info
 	p.mu.Unlock()
@@ -280,7 +296,15 @@ func (p *LocalProvider) Fetch(ctx context.Context, workspaceID string) error {
 		return fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
 	}
 
-	if err := p.runGit(ctx, workDir, "fetch", "--all", "--prune"); err != nil {
+	projectID := p.workspaceProject(workspaceID)
+	if mirrorDir := p.workspaceMirror(ctx, workDir); mirrorDir != "" {
+		if err := p.fetchFromMirror(ctx, projectID, workDir, mirrorDir); err != nil {
+			return fmt.Errorf("%w: %v", ErrFetchFailed, err)
+		}
+		return nil
+	}
+
+	if err := p.runRemoteGit(ctx, projectID, p.originURL(ctx, workDir), workDir, "fetch", "--all", "--prune"); err != nil {
 		return fmt.Errorf("%w: %v", ErrFetchFailed, err)
 	}
 
@@ -297,6 +321,9 @@ func (p *LocalProvider) Checkout(ctx context.Context, workspaceID, ref string) e
 	if err := p.runGit(ctx, workDir, "checkout", ref); err != nil {
 		return fmt.Errorf("%w: %v", ErrCheckoutFailed, err)
 	}
+	if err := p.checkoutExtras(ctx, p.workspaceProject(workspaceID), workspaceID, workDir); err != nil {
+		return fmt.Errorf("%w: %v", ErrCheckoutFailed, err)
+	}
 
 	return nil
 }
@@ -353,38 +380,40 @@ func (p *LocalProvider) Status(ctx context.Context, workspaceID string) (*Status
 		}
 
 		status.IsClean = false
-		index := entry[0]
-		worktree := entry[1]
-		path := entry[3:]
+		p.addFileStatus(status, entry[0], entry[1], entry[3:])
+	}
 
-		// Check for conflicts
-		if index == 'U' || worktree == 'U' || (index == 'A' && worktree == 'A') || (index == 'D' && worktree == 'D') {
-			status.HasConflicts = true
-		}
+	return status, nil
+}
 
-		// Staged changes
-		if index != ' ' && index != '?' {
-			status.Staged = append(status.Staged, FileStatus{
-				Path:   path,
-				Status: p.statusCodeToString(index),
-			})
-		}
+// addFileStatus adds a file to status given its porcelain status codes for
+// the index and the working tree.
+func (p *LocalProvider) addFileStatus(status *Status, index, worktree byte, path string) {
+	// Check for conflicts
+	if index == 'U' || worktree == 'U' || (index == 'A' && worktree == 'A') || (index == 'D' && worktree == 'D') {
+		status.HasConflicts = true
+	}
 
-		// Unstaged changes
-		if worktree != ' ' && worktree != '?' {
-			status.Unstaged = append(status.Unstaged, FileStatus{
-				Path:   path,
-				Status: p.statusCodeToString(worktree),
-			})
-		}
+	// Staged changes
+	if index != ' ' && index != '?' {
+		status.Staged = append(status.Staged, FileStatus{
+			Path:   path,
+			Status: p.statusCodeToString(index),
+		})
+	}
 
-		// Untracked files
-		if index == '?' && worktree == '?' {
-			status.Untracked = append(status.Untracked, path)
-		}
+	// Unstaged changes
+	if worktree != ' ' && worktree != '?' {
+		status.Unstaged = append(status.Unstaged, FileStatus{
+			Path:   path,
+			Status: p.statusCodeToString(worktree),
+		})
 	}
 
-	return status, nil
+	// Untracked files
+	if index == '?' && worktree == '?' {
+		status.Untracked = append(status.Untracked, path)
+	}
 }
 
 // Diff returns file diffs.
@@ -432,7 +461,7 @@ func (p *LocalProvider) Branches(ctx context.Context, workspaceID string) ([]Bra
 		return nil, fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
 	}
 
-	output, err := p.runGitOutput(ctx, workDir, "branch", "-a", "--format=%(refname:short)|%(objectname:short)|%(upstream:short)|%(HEAD)")
+	output, err := p.runGitOutput(ctx, workDir, "branch", "-a", "--format=%(refname)|%(objectname:short)|%(upstream:short)|%(HEAD)")
 	if err != nil {
 		return nil, err
 	}
@@ -446,10 +475,12 @@ func (p *LocalProvider) Branches(ctx context.Context, workspaceID string) ([]Bra
 			continue
 		}
 
-		name := strings.TrimSpace(parts[0])
+		// Use the full ref to tell remote branches from local ones with a slash
+		ref := strings.TrimSpace(parts[0])
+		name := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/remotes/")
 		branch := Branch{
 			Name:      name,
-			IsRemote:  strings.HasPrefix(name, "origin/") || strings.Contains(name, "/"),
+			IsRemote:  strings.HasPrefix(ref, "refs/remotes/"),
 			Commit:    strings.TrimSpace(parts[1]),
 			Upstream:  strings.TrimSpace(parts[2]),
 			IsCurrent: strings.TrimSpace(parts[3]) == "*",
@@ -483,6 +514,8 @@ func (p *LocalProvider) FileTree(ctx context.Context, workspaceID, ref string) (
 	}
 
 	var entries []FileEntry
+	var blobs []string // SHAs of the entries, for blobs that may be LFS pointers
+	hasAttributes := false
 	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -510,6 +543,34 @@ func (p *LocalProvider) FileTree(ctx context.Context, workspaceID, ref string) (
 			Size:  size,
 			Mode:  meta[0],
 		})
+		blob := ""
+		if meta[1] == "blob" && size >= int64(len(lfsPointerPrefix)) && size <= lfsPointerMaxSize {
+			blob = meta[2]
+		}
+		blobs = append(blobs, blob)
+		if filepath.Base(path) == ".gitattributes" {
+			hasAttributes = true
+		}
+	}
+
+	// Only repositories with attributes can track files with LFS
+	if hasAttributes {
+		var candidates []string
+		for _, sha := range blobs {
+			if sha != "" {
+				candidates = append(candidates, sha)
+			}
+		}
+		pointers, err := p.readLFSPointers(ctx, workDir, candidates)
+		if err != nil {
+			return nil, err
+		}
+		for i, sha := range blobs {
+			if ptr, ok := pointers[sha]; ok {
+				entries[i].LFS = true
+				entries[i].Size = ptr.Size
+			}
+		}
 	}
 
 	return entries, nil
@@ -523,9 +584,14 @@ func (p *LocalProvider) ReadFile(ctx context.Context, workspaceID, ref, path str
 	}
 
 	if ref == "" {
-		// Read from working tree
+		// Read from working tree, where LFS files without their object
+		// downloaded are still pointers
 		fullPath := filepath.Join(workDir, path)
-		return os.ReadFile(fullPath)
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		return p.resolveLFS(ctx, workDir, content), nil
 	}
 
 	// Read from specific ref
@@ -534,7 +600,7 @@ func (p *LocalProvider) ReadFile(ctx context.Context, workspaceID, ref, path str
 		return nil, fmt.Errorf("%w: %s at %s", ErrNotFound, path, ref)
 	}
 
-	return []byte(output), nil
+	return p.resolveLFS(ctx, workDir, []byte(output)), nil
 }
 
 // WriteFile writes content to a file in the working tree.
@@ -672,23 +738,34 @@ func (p *LocalProvider) GetWorkDir(ctx context.Context, workspaceID string) stri
 	return ""
 }
 
-// RemoveWorkspace removes the workspace working directory.
-func (p *LocalProvider) RemoveWorkspace(_ context.Context, workspaceID string) error {
+// RemoveWorkspace removes the workspace working directory, and the mirror it
+// was cloned from once no other workspace uses it.
+func (p *LocalProvider) RemoveWorkspace(ctx context.Context, workspaceID string) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	info, ok := p.workspaceIndex[workspaceID]
 	if !ok {
+		p.mu.Unlock()
 		return nil // Not in index, nothing to remove
 	}
-
 	delete(p.workspaceIndex, workspaceID)
-	return os.RemoveAll(info.workDir)
+	p.mu.Unlock()
+
+	mirrorDir := p.workspaceMirror(ctx, info.workDir)
+	if err := os.RemoveAll(info.workDir); err != nil {
+		return err
+	}
+	if mirrorDir != "" {
+		if err := p.removeUnusedMirror(ctx, info.projectID, mirrorDir); err != nil {
+			log.Printf("Failed to remove unused mirror %s: %v", mirrorDir, err)
+		}
+	}
+	return nil
 }
 
 // ApplyPatches applies mbox-format patches (from git format-patch) to the workspace.
 // Returns the final commit SHA after all patches are applied.
 // If application fails, the operation is aborted without losing local changes.
+// Checked-out submodules are moved to the commits the patches record.
 func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, patches []byte) (string, error) {
 	workDir := p.GetWorkDir(ctx, workspaceID)
 	if workDir == "" {
@@ -710,6 +787,7 @@ func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, pa
 		_ = p.runGit(ctx, workDir, "am", "--abort")
 		return "", fmt.Errorf("failed to apply patches: %w", err)
 	}
+	p.syncAfterApply(ctx, workspaceID, workDir)
 
 	// Get the final commit SHA
 	finalCommit, err := p.runGitOutput(ctx, workDir, "rev-parse", "HEAD")
@@ -720,6 +798,270 @@ func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, pa
 	return strings.TrimSpace(finalCommit), nil
 }
 
+// ApplyPatchesThreeWay applies mbox-format patches with git am -3. On a
+// conflict the conflicted files are collected before the application is
+// aborted, which restores the original HEAD.
+func (p *LocalProvider) ApplyPatchesThreeWay(ctx context.Context, workspaceID string, patches []byte) (string, error) {
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return "", fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+
+	finalCommit, err := p.applyThreeWay(ctx, workDir, patches)
+	if err != nil {
+		return "", err
+	}
+	p.syncAfterApply(ctx, workspaceID, workDir)
+	return finalCommit, nil
+}
+
+// applyThreeWay runs git am -3 in workDir and returns the final commit.
+func (p *LocalProvider) applyThreeWay(ctx context.Context, workDir string, patches []byte) (string, error) {
+	if err := p.runGitWithStdin(ctx, workDir, patches, "am", "-3", "--keep-cr", "--no-gpg-sign"); err != nil {
+		conflictErr := p.collectConflicts(ctx, workDir)
+		_ = p.runGit(ctx, workDir, "am", "--abort")
+		if conflictErr != nil {
+			return "", conflictErr
+		}
+		return "", fmt.Errorf("failed to apply patches: %w", err)
+	}
+
+	finalCommit, err := p.runGitOutput(ctx, workDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get final commit: %w", err)
+	}
+
+	return strings.TrimSpace(finalCommit), nil
+}
+
+// ApplyPatchesToBranch applies mbox-format patches with git am -3 in a
+// temporary worktree of branch, so commits to different branches of a
+// workspace can run concurrently without touching its checkout.
+func (p *LocalProvider) ApplyPatchesToBranch(ctx context.Context, workspaceID, branch string, patches []byte) (string, error) {
+	if err := ValidateBranchName(branch); err != nil {
+		return "", err
+	}
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return "", fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+
+	var finalCommit string
+	err := p.withWorktree(ctx, workspaceID, workDir, branch, func(treeDir string) error {
+		var err error
+		finalCommit, err = p.applyThreeWay(ctx, treeDir, patches)
+		return err
+	})
+	return finalCommit, err
+}
+
+// ResolveBranch returns the commit of a local branch, falling back to the
+// branch of the same name on origin.
+func (p *LocalProvider) ResolveBranch(ctx context.Context, workspaceID, branch string) (string, error) {
+	if err := ValidateBranchName(branch); err != nil {
+		return "", err
+	}
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return "", fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		if commit, err := p.runGitOutput(ctx, workDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+			return strings.TrimSpace(commit), nil
+		}
+	}
+	return "", fmt.Errorf("%w: branch %s", ErrNotFound, branch)
+}
+
+// FetchRef fetches a ref from the workspace's origin remote directly, since
+// mirrors only keep branches and tags, and returns the fetched commit.
+func (p *LocalProvider) FetchRef(ctx context.Context, workspaceID, ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, ": \t\n") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRef, ref)
+	}
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return "", fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+
+	if err := p.runRemoteGit(ctx, p.workspaceProject(workspaceID), p.originURL(ctx, workDir), workDir, "fetch", "--no-tags", "origin", ref); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	commit, err := p.runGitOutput(ctx, workDir, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%w: %s is not a commit", ErrInvalidRef, ref)
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// FormatPatch returns the commits in base..head as mbox-format patches.
+func (p *LocalProvider) FormatPatch(ctx context.Context, workspaceID, base, head string) ([]byte, error) {
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return nil, fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+	out, err := p.runGitOutput(ctx, workDir, "format-patch", "--stdout", "--binary", base+".."+head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format patches: %w", err)
+	}
+	return []byte(out), nil
+}
+
+// withWorktree checks branch out in a new worktree of the workspace, runs fn
+// in it and removes the worktree again. A missing branch is created from
+// origin's branch of the same name, or from HEAD.
+func (p *LocalProvider) withWorktree(ctx context.Context, workspaceID, workDir, branch string, fn func(treeDir string) error) error {
+	p.mu.RLock()
+	projectID := ""
+	if info, ok := p.workspaceIndex[workspaceID]; ok {
+		projectID = info.projectID
+	}
+	p.mu.RUnlock()
+
+	worktreesDir := filepath.Join(p.baseDir, projectID, "worktrees")
+	if err := os.MkdirAll(worktreesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create worktrees directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(worktreesDir, workspaceID+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	treeDir := filepath.Join(tmpDir, "tree")
+
+	args := []string{"worktree", "add", "--quiet", treeDir, branch}
+	if _, err := p.runGitOutput(ctx, workDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		startPoint := "HEAD"
+		if _, err := p.runGitOutput(ctx, workDir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err == nil {
+			startPoint = "origin/" + branch
+		}
+		args = []string{"worktree", "add", "--quiet", "--no-track", "-b", branch, treeDir, startPoint}
+	}
+	if err := p.runGit(ctx, workDir, args...); err != nil {
+		return fmt.Errorf("failed to add worktree for branch %s: %w", branch, err)
+	}
+	defer func() {
+		// Use a fresh context so the worktree is removed even if ctx was cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := p.runGit(cleanupCtx, workDir, "worktree", "remove", "--force", treeDir); err != nil {
+			_ = os.RemoveAll(treeDir)
+			_ = p.runGit(cleanupCtx, workDir, "worktree", "prune")
+		}
+	}()
+
+	return fn(treeDir)
+}
+
+// collectConflicts describes the unmerged files of a stopped git am.
+// Returns nil if there are none, i.e. the patch failed for another reason.
+func (p *LocalProvider) collectConflicts(ctx context.Context, workDir string) *ConflictError {
+	output, err := p.runGitOutput(ctx, workDir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil || strings.TrimSpace(output) == "" {
+		return nil
+	}
+
+	conflictErr := &ConflictError{}
+	for _, path := range strings.Split(strings.TrimSpace(output), "\n") {
+		conflict := Conflict{Path: path}
+		if content, err := os.ReadFile(filepath.Join(workDir, path)); err == nil {
+			conflict.Hunks = ParseConflictHunks(string(content))
+		}
+		conflictErr.Conflicts = append(conflictErr.Conflicts, conflict)
+	}
+
+	// git am keeps the headers of the patch being applied in rebase-apply/info
+	if infoPath, err := p.runGitOutput(ctx, workDir, "rev-parse", "--git-path", "rebase-apply/info"); err == nil {
+		infoPath = strings.TrimSpace(infoPath)
+		if !filepath.IsAbs(infoPath) {
+			infoPath = filepath.Join(workDir, infoPath)
+		}
+		if info, err := os.ReadFile(infoPath); err == nil {
+			for _, line := range strings.Split(string(info), "\n") {
+				if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+					conflictErr.Patch = subject
+					break
+				}
+			}
+		}
+	}
+
+	return conflictErr
+}
+
+// ParseConflictHunks extracts the conflict regions delimited by merge markers.
+func ParseConflictHunks(content string) []ConflictHunk {
+	var hunks []ConflictHunk
+	var current *ConflictHunk
+	var section *strings.Builder
+	var ours, base, theirs strings.Builder
+
+	for i, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<<"):
+			current = &ConflictHunk{StartLine: i + 1}
+			ours.Reset()
+			base.Reset()
+			theirs.Reset()
+			section = &ours
+		case current != nil && strings.HasPrefix(line, "|||||||"):
+			section = &base
+		case current != nil && strings.HasPrefix(line, "======="):
+			section = &theirs
+		case current != nil && strings.HasPrefix(line, ">>>>>>>"):
+			current.Ours, current.Base, current.Theirs = ours.String(), base.String(), theirs.String()
+			hunks = append(hunks, *current)
+			current = nil
+		case current != nil:
+			section.WriteString(line)
+			section.WriteString("\n")
+		}
+	}
+
+	return hunks
+}
+
+// Push pushes a commit to a branch of the workspace's origin remote.
+// Credentials are passed to git through the environment so they do not show
+// up in the process list.
+func (p *LocalProvider) Push(ctx context.Context, workspaceID string, opts PushOptions) error {
+	workDir := p.GetWorkDir(ctx, workspaceID)
+	if workDir == "" {
+		return fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+	}
+	if opts.Branch == "" {
+		return fmt.Errorf("%w: branch is required", ErrInvalidRef)
+	}
+
+	ref := opts.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	args := []string{"push"}
+	if opts.Force {
+		args = append(args, "--force")
+	}
+	args = append(args, "origin", ref+":refs/heads/"+opts.Branch)
+
+	// Explicit credentials take precedence over the project's own
+	if opts.Username == "" && opts.Password == "" {
+		projectID := p.workspaceProject(workspaceID)
+		if err := p.runRemoteGit(ctx, projectID, p.originURL(ctx, workDir), workDir, args...); err != nil {
+			return fmt.Errorf("%w: %v", ErrPushFailed, err)
+		}
+		return nil
+	}
+
+	env := append([]string{"GIT_TERMINAL_PROMPT=0"}, basicAuthEnv(p.originURL(ctx, workDir), opts.Username, opts.Password)...)
+	if err := p.runGitEnv(ctx, workDir, env, args...); err != nil {
+		return fmt.Errorf("%w: %v", ErrPushFailed, err)
+	}
+
+	return nil
+}
+
 // --- Internal helpers ---
 
 // cleanGitEnv returns the current environment with GIT_* variables removed that
@@ -738,11 +1080,16 @@ func cleanGitEnv() []string {
 
 // runGit runs a git command.
 func (p *LocalProvider) runGit(ctx context.Context, workDir string, args ...string) error {
+	return p.runGitEnv(ctx, workDir, nil, args...)
+}
+
+// runGitEnv runs a git command with additional environment variables.
+func (p *LocalProvider) runGitEnv(ctx context.Context, workDir string, env []string, args ...string) error {
 	cmd := exec.CommandContext(ctx, "git", args...)
 	if workDir != "" {
 		cmd.Dir = workDir
 	}
-	cmd.Env = cleanGitEnv()
+	cmd.Env = append(cleanGitEnv(), env...)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -2,10 +2,16 @@ package git
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -46,6 +52,20 @@ func runGit(t *testing.T, dir string, args ...string) string {
 	return string(output)
 }
 
+// testProviderFunc creates the Provider that the tests shared by all
+// implementations run against.
+type testProviderFunc func(t *testing.T, baseDir string, opts ...LocalProviderOption) Provider
+
+func newLocalTestProvider(t *testing.T, baseDir string, opts ...LocalProviderOption) Provider {
+	t.Helper()
+
+	provider, err := NewLocalProvider(baseDir, opts...)
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+	return provider
+}
+
 func TestNewLocalProvider(t *testing.T) {
 	t.Run("creates base directory", func(t *testing.T) {
 		baseDir := filepath.Join(t.TempDir(), "newdir")
@@ -269,11 +289,15 @@ func TestCheckout(t *testing.T) {
 }
 
 func TestStatus(t *testing.T) {
+	testStatus(t, newLocalTestProvider)
+}
+
+func testStatus(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("returns clean status", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -298,7 +322,7 @@ func TestStatus(t *testing.T) {
 
 	t.Run("detects untracked files", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -319,7 +343,7 @@ func TestStatus(t *testing.T) {
 
 	t.Run("detects staged changes", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -337,7 +361,7 @@ func TestStatus(t *testing.T) {
 
 	t.Run("detects unstaged changes", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -354,11 +378,15 @@ func TestStatus(t *testing.T) {
 }
 
 func TestDiff(t *testing.T) {
+	testDiff(t, newLocalTestProvider)
+}
+
+func testDiff(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("returns empty diff for clean repo", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -375,7 +403,7 @@ func TestDiff(t *testing.T) {
 
 	t.Run("returns diff for modified file", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -403,7 +431,7 @@ func TestDiff(t *testing.T) {
 
 	t.Run("returns staged diff", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -424,16 +452,21 @@ func TestDiff(t *testing.T) {
 }
 
 func TestBranches(t *testing.T) {
+	testBranches(t, newLocalTestProvider)
+}
+
+func testBranches(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("lists branches", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 		runGit(t, sourceRepo, "branch", "feature")
 		runGit(t, sourceRepo, "branch", "develop")
 
-		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		runGit(t, workDir, "branch", "feature/local")
 
 		branches, err := provider.Branches(ctx, "ws1")
 		if err != nil {
@@ -456,15 +489,32 @@ func TestBranches(t *testing.T) {
 		if !hasCurrent {
 			t.Error("Expected one branch to be current")
 		}
+
+		for _, b := range branches {
+			switch b.Name {
+			case "feature/local":
+				if b.IsRemote {
+					t.Error("Expected feature/local to be a local branch")
+				}
+			case "origin/feature":
+				if !b.IsRemote {
+					t.Error("Expected origin/feature to be a remote branch")
+				}
+			}
+		}
 	})
 }
 
 func TestFileTree(t *testing.T) {
+	testFileTree(t, newLocalTestProvider)
+}
+
+func testFileTree(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("lists files at HEAD", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -496,11 +546,15 @@ func TestFileTree(t *testing.T) {
 }
 
 func TestReadFile(t *testing.T) {
+	testReadFile(t, newLocalTestProvider)
+}
+
+func testReadFile(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("reads file from working tree", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -517,7 +571,7 @@ func TestReadFile(t *testing.T) {
 
 	t.Run("reads file from specific ref", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -534,7 +588,7 @@ func TestReadFile(t *testing.T) {
 
 	t.Run("fails for nonexistent file", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -587,11 +641,15 @@ func TestWriteFile(t *testing.T) {
 }
 
 func TestStage(t *testing.T) {
+	testStage(t, newLocalTestProvider)
+}
+
+func testStage(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("stages file", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -610,7 +668,7 @@ func TestStage(t *testing.T) {
 
 	t.Run("stages multiple files", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -630,7 +688,7 @@ func TestStage(t *testing.T) {
 
 	t.Run("stages all with dot", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -650,11 +708,15 @@ func TestStage(t *testing.T) {
 }
 
 func TestCommit(t *testing.T) {
+	testCommit(t, newLocalTestProvider)
+}
+
+func testCommit(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("creates commit", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -685,7 +747,7 @@ func TestCommit(t *testing.T) {
 
 	t.Run("fails with no staged changes", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -698,11 +760,15 @@ func TestCommit(t *testing.T) {
 }
 
 func TestLog(t *testing.T) {
+	testLog(t, newLocalTestProvider)
+}
+
+func testLog(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("returns commit history", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -723,7 +789,7 @@ func TestLog(t *testing.T) {
 
 	t.Run("respects limit", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		// Add more commits
@@ -741,6 +807,103 @@ func TestLog(t *testing.T) {
 	})
 }
 
+func TestPush(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pushes a commit to a new branch", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+		runGit(t, workDir, "config", "user.email", "test@example.com")
+		runGit(t, workDir, "config", "user.name", "Test User")
+		runGit(t, workDir, "commit", "--allow-empty", "-m", "Session change")
+		head := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD"))
+
+		if err := provider.Push(ctx, "ws1", PushOptions{Ref: head, Branch: "discobot/change"}); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+
+		got := strings.TrimSpace(runGit(t, sourceRepo, "rev-parse", "refs/heads/discobot/change"))
+		if got != head {
+			t.Errorf("Expected pushed branch at %s, got %s", head, got)
+		}
+
+		// A rewritten commit needs Force
+		runGit(t, workDir, "commit", "--amend", "--allow-empty", "-m", "Session change v2")
+		if err := provider.Push(ctx, "ws1", PushOptions{Branch: "discobot/change"}); !errors.Is(err, ErrPushFailed) {
+			t.Errorf("Expected ErrPushFailed for non-fast-forward, got %v", err)
+		}
+		if err := provider.Push(ctx, "ws1", PushOptions{Branch: "discobot/change", Force: true}); err != nil {
+			t.Errorf("Force push failed: %v", err)
+		}
+	})
+
+	t.Run("requires a branch", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+
+		if err := provider.Push(ctx, "ws1", PushOptions{}); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("Expected ErrInvalidRef, got %v", err)
+		}
+	})
+}
+
+func TestFetchRefAndFormatPatch(t *testing.T) {
+	ctx := context.Background()
+	baseDir := t.TempDir()
+	provider, _ := NewLocalProvider(baseDir)
+	sourceRepo := createTestRepo(t)
+	base := strings.TrimSpace(runGit(t, sourceRepo, "rev-parse", "HEAD"))
+
+	workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+
+	// A pull request ref is not fetched with the branches
+	runGit(t, sourceRepo, "checkout", "-q", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(sourceRepo, "feature.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, sourceRepo, "add", "feature.go")
+	runGit(t, sourceRepo, "commit", "-m", "Feature work")
+	head := strings.TrimSpace(runGit(t, sourceRepo, "rev-parse", "HEAD"))
+	runGit(t, sourceRepo, "update-ref", "refs/pull/1/head", head)
+
+	commit, err := provider.FetchRef(ctx, "ws1", "refs/pull/1/head")
+	if err != nil {
+		t.Fatalf("FetchRef failed: %v", err)
+	}
+	if commit != head {
+		t.Errorf("Expected fetched commit %s, got %s", head, commit)
+	}
+	if _, err := provider.FetchRef(ctx, "ws1", "refs/pull/2/head"); !errors.Is(err, ErrFetchFailed) {
+		t.Errorf("Expected ErrFetchFailed for a missing ref, got %v", err)
+	}
+	if _, err := provider.FetchRef(ctx, "ws1", "+main:main"); !errors.Is(err, ErrInvalidRef) {
+		t.Errorf("Expected ErrInvalidRef for a refspec, got %v", err)
+	}
+
+	patches, err := provider.FormatPatch(ctx, "ws1", base, commit)
+	if err != nil {
+		t.Fatalf("FormatPatch failed: %v", err)
+	}
+	if !strings.Contains(string(patches), "Subject: [PATCH] Feature work") {
+		t.Errorf("Unexpected patches:\n%s", patches)
+	}
+	if refs := runGit(t, workDir, "for-each-ref", "refs/pull"); refs != "" {
+		t.Errorf("Expected no local ref for the fetched ref, got %s", refs)
+	}
+}
+
 func TestGetWorkDir(t *testing.T) {
 	ctx := context.Background()
 
@@ -834,11 +997,15 @@ func TestIsGitURL(t *testing.T) {
 }
 
 func TestApplyPatches(t *testing.T) {
+	testApplyPatches(t, newLocalTestProvider)
+}
+
+func testApplyPatches(t *testing.T, newProvider testProviderFunc) {
 	ctx := context.Background()
 
 	t.Run("applies single commit patch", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -905,7 +1072,7 @@ func TestApplyPatches(t *testing.T) {
 
 	t.Run("applies multiple commit patches", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -969,7 +1136,7 @@ func TestApplyPatches(t *testing.T) {
 
 	t.Run("fails for unknown workspace", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 
 		_, err := provider.ApplyPatches(ctx, "nonexistent", []byte("patch content"))
 		if err == nil {
@@ -979,7 +1146,7 @@ func TestApplyPatches(t *testing.T) {
 
 	t.Run("fails and rolls back on invalid patch", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -1000,7 +1167,7 @@ func TestApplyPatches(t *testing.T) {
 
 	t.Run("preserves local changes when patch fails to apply", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -1059,7 +1226,7 @@ func TestApplyPatches(t *testing.T) {
 
 	t.Run("preserves commit signatures in patches", func(t *testing.T) {
 		baseDir := t.TempDir()
-		provider, _ := NewLocalProvider(baseDir)
+		provider := newProvider(t, baseDir)
 		sourceRepo := createTestRepo(t)
 
 		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
@@ -1101,6 +1268,235 @@ func TestApplyPatches(t *testing.T) {
 	})
 }
 
+func TestApplyPatchesThreeWay(t *testing.T) {
+	testApplyPatchesThreeWay(t, newLocalTestProvider)
+}
+
+func testApplyPatchesThreeWay(t *testing.T, newProvider testProviderFunc) {
+	ctx := context.Background()
+
+	// setup creates a workspace with a three-line file and returns a patch
+	// changing its last line, made against the initial commit.
+	setup := func(t *testing.T) (Provider, string, string) {
+		t.Helper()
+		provider := newProvider(t, t.TempDir())
+		sourceRepo := createTestRepo(t)
+		if err := os.WriteFile(filepath.Join(sourceRepo, "lines.txt"), []byte("one\ntwo\nthree\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, sourceRepo, "add", "lines.txt")
+		runGit(t, sourceRepo, "commit", "-m", "Add lines")
+
+		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		runGit(t, workDir, "config", "user.email", "committer@example.com")
+		runGit(t, workDir, "config", "user.name", "Test Committer")
+
+		patchRepo := t.TempDir()
+		runGit(t, patchRepo, "init")
+		runGit(t, patchRepo, "config", "user.email", "patch@example.com")
+		runGit(t, patchRepo, "config", "user.name", "Patch Author")
+		runGit(t, patchRepo, "fetch", workDir, "HEAD")
+		runGit(t, patchRepo, "reset", "--hard", "FETCH_HEAD")
+		if err := os.WriteFile(filepath.Join(patchRepo, "lines.txt"), []byte("one\ntwo\nTHREE\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, patchRepo, "commit", "-am", "Shout three")
+		patches := runGit(t, patchRepo, "format-patch", "--stdout", "HEAD~1..HEAD")
+
+		return provider, workDir, patches
+	}
+
+	t.Run("applies a clean patch", func(t *testing.T) {
+		provider, workDir, patches := setup(t)
+
+		commit, err := provider.ApplyPatchesThreeWay(ctx, "ws1", []byte(patches))
+		if err != nil {
+			t.Fatalf("ApplyPatchesThreeWay failed: %v", err)
+		}
+		if head := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD")); head != commit {
+			t.Errorf("HEAD = %s, want %s", head, commit)
+		}
+		content, _ := os.ReadFile(filepath.Join(workDir, "lines.txt"))
+		if string(content) != "one\ntwo\nTHREE\n" {
+			t.Errorf("Unexpected content %q", content)
+		}
+		if status := runGit(t, workDir, "status", "--porcelain"); status != "" {
+			t.Errorf("Expected clean work tree, got %q", status)
+		}
+	})
+
+	t.Run("merges a patch whose context changed", func(t *testing.T) {
+		provider, workDir, patches := setup(t)
+
+		if err := os.WriteFile(filepath.Join(workDir, "lines.txt"), []byte("ONE\ntwo\nthree\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, workDir, "commit", "-am", "Shout one")
+
+		if _, err := provider.ApplyPatches(ctx, "ws1", []byte(patches)); err == nil {
+			t.Fatal("Expected plain ApplyPatches to fail on changed context")
+		}
+
+		if _, err := provider.ApplyPatchesThreeWay(ctx, "ws1", []byte(patches)); err != nil {
+			t.Fatalf("ApplyPatchesThreeWay failed: %v", err)
+		}
+		content, _ := os.ReadFile(filepath.Join(workDir, "lines.txt"))
+		if string(content) != "ONE\ntwo\nTHREE\n" {
+			t.Errorf("Unexpected merged content %q", content)
+		}
+	})
+
+	t.Run("reports conflicts and restores the workspace", func(t *testing.T) {
+		provider, workDir, patches := setup(t)
+
+		if err := os.WriteFile(filepath.Join(workDir, "lines.txt"), []byte("one\ntwo\n3\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, workDir, "commit", "-am", "Number three")
+		head := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD"))
+
+		_, err := provider.ApplyPatchesThreeWay(ctx, "ws1", []byte(patches))
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) || !errors.Is(err, ErrPatchConflict) {
+			t.Fatalf("Expected ConflictError, got %v", err)
+		}
+		if conflictErr.Patch != "Shout three" {
+			t.Errorf("Patch = %q", conflictErr.Patch)
+		}
+		if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Path != "lines.txt" {
+			t.Fatalf("Unexpected conflicts %+v", conflictErr.Conflicts)
+		}
+		hunks := conflictErr.Conflicts[0].Hunks
+		if len(hunks) != 1 || hunks[0].StartLine != 3 || hunks[0].Ours != "3\n" || hunks[0].Theirs != "THREE\n" {
+			t.Errorf("Unexpected hunks %+v", hunks)
+		}
+
+		if got := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD")); got != head {
+			t.Errorf("Expected HEAD restored to %s, got %s", head, got)
+		}
+		if status := runGit(t, workDir, "status", "--porcelain"); status != "" {
+			t.Errorf("Expected clean work tree, got %q", status)
+		}
+	})
+}
+
+func TestParseConflictHunks(t *testing.T) {
+	content := "a\n<<<<<<< HEAD\nours\n||||||| base\nbase\n=======\ntheirs 1\ntheirs 2\n>>>>>>> patch\nb\n<<<<<<< HEAD\n=======\nadded\n>>>>>>> patch\n"
+	hunks := ParseConflictHunks(content)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+	want := ConflictHunk{StartLine: 2, Ours: "ours\n", Base: "base\n", Theirs: "theirs 1\ntheirs 2\n"}
+	if hunks[0] != want {
+		t.Errorf("hunks[0] = %+v, want %+v", hunks[0], want)
+	}
+	if hunks[1].StartLine != 11 || hunks[1].Ours != "" || hunks[1].Theirs != "added\n" {
+		t.Errorf("hunks[1] = %+v", hunks[1])
+	}
+}
+
+func TestApplyPatchesToBranch(t *testing.T) {
+	ctx := context.Background()
+	provider, _ := NewLocalProvider(t.TempDir())
+	sourceRepo := createTestRepo(t)
+	runGit(t, sourceRepo, "branch", "remote-only")
+	remoteOnly := strings.TrimSpace(runGit(t, sourceRepo, "rev-parse", "remote-only"))
+
+	workDir, head, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+	runGit(t, workDir, "config", "user.email", "committer@example.com")
+	runGit(t, workDir, "config", "user.name", "Test Committer")
+	checkedOut := strings.TrimSpace(runGit(t, workDir, "rev-parse", "--abbrev-ref", "HEAD"))
+
+	// patchAdding returns a patch against the workspace HEAD adding a file
+	patchAdding := func(name string) []byte {
+		patchRepo := t.TempDir()
+		runGit(t, patchRepo, "init")
+		runGit(t, patchRepo, "config", "user.email", "patch@example.com")
+		runGit(t, patchRepo, "config", "user.name", "Patch Author")
+		runGit(t, patchRepo, "fetch", workDir, "HEAD")
+		runGit(t, patchRepo, "reset", "--hard", "FETCH_HEAD")
+		if err := os.WriteFile(filepath.Join(patchRepo, name), []byte(name+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, patchRepo, "add", name)
+		runGit(t, patchRepo, "commit", "-m", "Add "+name)
+		return []byte(runGit(t, patchRepo, "format-patch", "--stdout", "HEAD~1..HEAD"))
+	}
+
+	if _, err := provider.ResolveBranch(ctx, "ws1", "feature-a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing branch, got %v", err)
+	}
+	if commit, err := provider.ResolveBranch(ctx, "ws1", "remote-only"); err != nil || commit != remoteOnly {
+		t.Errorf("ResolveBranch(remote-only) = %s, %v; want %s", commit, err, remoteOnly)
+	}
+
+	// Commits to different branches run concurrently
+	var wg sync.WaitGroup
+	commits := make([]string, 2)
+	errs := make([]error, 2)
+	for i, branch := range []string{"feature-a", "feature-b"} {
+		patch := patchAdding(branch + ".txt")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			commits[i], errs[i] = provider.ApplyPatchesToBranch(ctx, "ws1", branch, patch)
+		}()
+	}
+	wg.Wait()
+
+	for i, branch := range []string{"feature-a", "feature-b"} {
+		if errs[i] != nil {
+			t.Fatalf("ApplyPatchesToBranch(%s) failed: %v", branch, errs[i])
+		}
+		if commit, _ := provider.ResolveBranch(ctx, "ws1", branch); commit != commits[i] {
+			t.Errorf("Branch %s at %s, want %s", branch, commit, commits[i])
+		}
+		if parent := strings.TrimSpace(runGit(t, workDir, "rev-parse", branch+"~1")); parent != head {
+			t.Errorf("Branch %s created from %s, want HEAD %s", branch, parent, head)
+		}
+	}
+
+	// A second commit builds on the branch
+	second, err := provider.ApplyPatchesToBranch(ctx, "ws1", "feature-a", patchAdding("more.txt"))
+	if err != nil {
+		t.Fatalf("Second ApplyPatchesToBranch failed: %v", err)
+	}
+	if parent := strings.TrimSpace(runGit(t, workDir, "rev-parse", second+"~1")); parent != commits[0] {
+		t.Errorf("Second commit parent = %s, want %s", parent, commits[0])
+	}
+
+	// The workspace checkout is untouched and the worktrees are removed
+	if got := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD")); got != head {
+		t.Errorf("Workspace HEAD moved to %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "feature-a.txt")); !os.IsNotExist(err) {
+		t.Error("Expected feature-a.txt not to be in the workspace checkout")
+	}
+	if worktrees := strings.Count(runGit(t, workDir, "worktree", "list"), "\n"); worktrees != 1 {
+		t.Errorf("Expected only the main worktree, got %d", worktrees)
+	}
+
+	if _, err := provider.ApplyPatchesToBranch(ctx, "ws1", checkedOut, patchAdding("x.txt")); err == nil {
+		t.Error("Expected applying to the checked out branch to fail")
+	}
+	if _, err := provider.ApplyPatchesToBranch(ctx, "ws1", "bad..name", patchAdding("y.txt")); !errors.Is(err, ErrInvalidBranch) {
+		t.Errorf("Expected ErrInvalidBranch, got %v", err)
+	}
+}
+
+func TestValidateBranchName(t *testing.T) {
+	for _, name := range []string{"main", "feature/login", "release-1.2", "user@host"} {
+		if err := ValidateBranchName(name); err != nil {
+			t.Errorf("ValidateBranchName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-x", "a..b", "a b", "a:b", "a/", "/a", "a.lock", "a//b", "a@{1}", ".hidden", "a/.b", "a~1", "a\\b", "@"} {
+		if err := ValidateBranchName(name); !errors.Is(err, ErrInvalidBranch) {
+			t.Errorf("ValidateBranchName(%q) = %v, want ErrInvalidBranch", name, err)
+		}
+	}
+}
+
 func TestWorkspaceIsolation(t *testing.T) {
 	ctx := context.Background()
 
@@ -1215,3 +1611,414 @@ func TestGetUserConfig(t *testing.T) {
 		}
 	})
 }
+
+// staticCredentials returns the same credentials for every remote.
+type staticCredentials struct {
+	creds *Credentials
+}
+
+func (s staticCredentials) GitCredentials(context.Context, string, string) (*Credentials, error) {
+	return s.creds, nil
+}
+
+func TestEnsureWorkspace_PrivateHTTPRemote(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not found")
+	}
+
+	source := createTestRepo(t)
+	root := t.TempDir()
+	runGit(t, root, "clone", "--bare", source, "private.git")
+
+	// Serve the bare repository over smart HTTP, requiring basic auth
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "x-access-token" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	remote := server.URL + "/private.git"
+	ctx := context.Background()
+
+	t.Run("fails without credentials", func(t *testing.T) {
+		provider, err := NewLocalProvider(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewLocalProvider failed: %v", err)
+		}
+
+		if _, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-anonymous", remote, ""); !errors.Is(err, ErrCloneFailed) {
+			t.Fatalf("expected ErrCloneFailed, got %v", err)
+		}
+	})
+
+	t.Run("clones and fetches with a token", func(t *testing.T) {
+		creds := staticCredentials{&Credentials{Username: "x-access-token", Password: "secret"}}
+		provider, err := NewLocalProvider(t.TempDir(), WithCredentialSource(creds))
+		if err != nil {
+			t.Fatalf("NewLocalProvider failed: %v", err)
+		}
+
+		workDir, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-private", remote, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, "README.md")); err != nil {
+			t.Fatalf("expected cloned files: %v", err)
+		}
+
+		// The token must not be persisted in the clone's config
+		config, err := os.ReadFile(filepath.Join(workDir, ".git", "config"))
+		if err != nil {
+			t.Fatalf("failed to read config: %v", err)
+		}
+		if strings.Contains(string(config), "secret") || strings.Contains(string(config), "extraHeader") {
+			t.Errorf("credentials leaked into workspace config:\n%s", config)
+		}
+
+		if err := provider.Fetch(ctx, "ws-private"); err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+	})
+}
+
+func TestBasicAuthEnv(t *testing.T) {
+	env := basicAuthEnv("https://github.com/org/repo.git", "x-access-token", "secret")
+	want := []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://github.com/.extraHeader",
+		"GIT_CONFIG_VALUE_0=" + BasicAuthHeader("x-access-token", "secret"),
+	}
+	if !slices.Equal(env, want) {
+		t.Errorf("basicAuthEnv = %v, want %v", env, want)
+	}
+
+	if env := basicAuthEnv("git@github.com:org/repo.git", "x-access-token", "secret"); env != nil {
+		t.Errorf("expected no header for an SSH remote, got %v", env)
+	}
+}
+
+func TestRemoteEnv_SSHKey(t *testing.T) {
+	baseDir := t.TempDir()
+	creds := staticCredentials{&Credentials{SSHKey: []byte("PRIVATE KEY")}}
+	provider, err := NewLocalProvider(baseDir, WithCredentialSource(creds))
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+
+	env, cleanup, err := provider.remoteEnv(context.Background(), "proj", "git@github.com:org/repo.git")
+	if err != nil {
+		t.Fatalf("remoteEnv failed: %v", err)
+	}
+
+	var keyFile string
+	for _, e := range env {
+		if cmd, ok := strings.CutPrefix(e, "GIT_SSH_COMMAND="); ok {
+			_, rest, _ := strings.Cut(cmd, "-i '")
+			keyFile, _, _ = strings.Cut(rest, "'")
+		}
+	}
+	if keyFile == "" {
+		t.Fatalf("expected GIT_SSH_COMMAND with a key file, got %v", env)
+	}
+	if strings.HasPrefix(keyFile, baseDir) {
+		t.Errorf("key file %s written below the workspace directory", keyFile)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatalf("key file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(keyFile); string(data) != "PRIVATE KEY\n" {
+		t.Errorf("key file content = %q", data)
+	}
+
+	cleanup()
+	if _, err := os.Stat(keyFile); !os.IsNotExist(err) {
+		t.Errorf("expected key file to be removed, got %v", err)
+	}
+}
+
+func TestRemoteHost(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:org/repo.git":         "github.com",
+		"https://GitHub.com/org/repo":         "github.com",
+		"ssh://git@git.example.com:2222/repo": "git.example.com",
+		"http://localhost:3000/repo.git":      "localhost",
+		"/home/user/repo":                     "",
+		"./relative/repo":                     "",
+	}
+	for remote, want := range tests {
+		if got := RemoteHost(remote); got != want {
+			t.Errorf("RemoteHost(%q) = %q, want %q", remote, got, want)
+		}
+	}
+
+	if !IsSSHRemote("git@github.com:org/repo.git") || !IsSSHRemote("ssh://git@host/repo") {
+		t.Error("expected SSH remotes")
+	}
+	if IsSSHRemote("https://github.com/org/repo") || IsSSHRemote("/home/user/repo") {
+		t.Error("expected non-SSH remotes")
+	}
+}
+
+func TestEnsureWorkspace_Mirror(t *testing.T) {
+	source := createTestRepo(t)
+	remote := filepath.Join(t.TempDir(), "origin.git")
+	runGit(t, "", "clone", "--bare", source, remote)
+	runGit(t, source, "remote", "add", "origin", remote)
+
+	baseDir := t.TempDir()
+	provider, err := NewLocalProvider(baseDir)
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+	ctx := context.Background()
+
+	ws1, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-1", remote, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+	ws2, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-2", remote, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+
+	mirrors, err := filepath.Glob(filepath.Join(baseDir, "proj", "mirrors", "*.git"))
+	if err != nil || len(mirrors) != 1 {
+		t.Fatalf("expected one mirror, got %v (%v)", mirrors, err)
+	}
+	for _, ws := range []string{ws1, ws2} {
+		if got := strings.TrimSpace(runGit(t, ws, "remote", "get-url", "origin")); got != remote {
+			t.Errorf("origin of %s = %s, want %s", ws, got, remote)
+		}
+		if _, err := os.Stat(filepath.Join(ws, ".git", "objects", "info", "alternates")); !os.IsNotExist(err) {
+			t.Errorf("workspace %s should not borrow objects through alternates", ws)
+		}
+	}
+
+	// Fetches of both workspaces see a new commit through the mirror
+	if err := os.WriteFile(filepath.Join(source, "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, source, "add", ".")
+	runGit(t, source, "commit", "-m", "New commit")
+	runGit(t, source, "push", "origin", "HEAD")
+	head := strings.TrimSpace(runGit(t, source, "rev-parse", "HEAD"))
+	branch := strings.TrimSpace(runGit(t, source, "rev-parse", "--abbrev-ref", "HEAD"))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, id := range []string{"ws-1", "ws-2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = provider.Fetch(ctx, id)
+		}()
+	}
+	wg.Wait()
+	for i, ws := range []string{ws1, ws2} {
+		if errs[i] != nil {
+			t.Fatalf("Fetch failed: %v", errs[i])
+		}
+		if got := strings.TrimSpace(runGit(t, ws, "rev-parse", "origin/"+branch)); got != head {
+			t.Errorf("origin/%s of %s = %s, want %s", branch, ws, got, head)
+		}
+	}
+
+	// The mirror is removed with its last workspace
+	if err := provider.RemoveWorkspace(ctx, "ws-1"); err != nil {
+		t.Fatalf("RemoveWorkspace failed: %v", err)
+	}
+	if _, err := os.Stat(mirrors[0]); err != nil {
+		t.Fatalf("mirror removed while still in use: %v", err)
+	}
+	if err := provider.RemoveWorkspace(ctx, "ws-2"); err != nil {
+		t.Fatalf("RemoveWorkspace failed: %v", err)
+	}
+	if _, err := os.Stat(mirrors[0]); !os.IsNotExist(err) {
+		t.Errorf("expected unused mirror to be removed, got %v", err)
+	}
+}
+
+func TestEnsureWorkspace_CloneDepth(t *testing.T) {
+	source := createTestRepo(t)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		runGit(t, source, "add", ".")
+		runGit(t, source, "commit", "-m", "Add "+name)
+	}
+	remote := filepath.Join(t.TempDir(), "origin.git")
+	runGit(t, "", "clone", "--bare", source, remote)
+
+	provider, err := NewLocalProvider(t.TempDir(), WithCloneDepth(1))
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+
+	// A plain path would be cloned locally, ignoring the depth
+	workDir, _, err := provider.EnsureWorkspace(context.Background(), "proj", "ws-shallow", "file://"+remote, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+	if got := strings.TrimSpace(runGit(t, workDir, "rev-list", "--count", "HEAD")); got != "1" {
+		t.Errorf("expected 1 commit of history, got %s", got)
+	}
+}
+
+// testWorkspaceSource serves workspace options from a map.
+type testWorkspaceSource map[string]*WorkspaceInfo
+
+func (s testWorkspaceSource) GetWorkspaceInfo(_ context.Context, workspaceID string) (*WorkspaceInfo, error) {
+	if info, ok := s[workspaceID]; ok {
+		return info, nil
+	}
+	return nil, ErrNotFound
+}
+
+func TestSubmodules(t *testing.T) {
+	testSubmodules(t, newLocalTestProvider)
+}
+
+func testSubmodules(t *testing.T, newProvider testProviderFunc) {
+	// Submodules of local paths need the file protocol, and git am an identity
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[protocol \"file\"]\n\tallow = always\n[user]\n\tname = Test User\n\temail = test@example.com\n"), 0644); err != nil {
+		t.Fatalf("failed to write gitconfig: %v", err)
+	}
+
+	sub := createTestRepo(t)
+	source := createTestRepo(t)
+	runGit(t, source, "submodule", "add", sub, "lib")
+	runGit(t, source, "commit", "-m", "Add submodule")
+
+	ctx := context.Background()
+	provider := newProvider(t, t.TempDir(), WithWorkspaceSource(testWorkspaceSource{
+		"ws-sub": {ProjectID: "proj", Path: source, Submodules: true},
+	}))
+
+	plainDir, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-plain", source, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(plainDir, "lib", "README.md")); !os.IsNotExist(err) {
+		t.Errorf("submodule checked out without the option: %v", err)
+	}
+
+	workDir, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-sub", source, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "lib", "README.md")); err != nil {
+		t.Fatalf("submodule not checked out: %v", err)
+	}
+
+	// A submodule pointer bump applies cleanly and moves the checkout
+	if err := os.WriteFile(filepath.Join(sub, "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, sub, "add", ".")
+	runGit(t, sub, "commit", "-m", "New commit")
+	subHead := strings.TrimSpace(runGit(t, sub, "rev-parse", "HEAD"))
+	runGit(t, filepath.Join(source, "lib"), "fetch", "-q", sub, "HEAD")
+	runGit(t, filepath.Join(source, "lib"), "checkout", "-q", "FETCH_HEAD")
+	runGit(t, source, "commit", "-am", "Bump lib")
+	patches := runGit(t, source, "format-patch", "-1", "--stdout")
+
+	if _, err := provider.ApplyPatches(ctx, "ws-sub", []byte(patches)); err != nil {
+		t.Fatalf("ApplyPatches failed: %v", err)
+	}
+	if got := strings.TrimSpace(runGit(t, filepath.Join(workDir, "lib"), "rev-parse", "HEAD")); got != subHead {
+		t.Errorf("submodule at %s after the bump, want %s", got, subHead)
+	}
+	if status := runGit(t, workDir, "status", "--porcelain"); status != "" {
+		t.Errorf("workspace not clean after the bump:\n%s", status)
+	}
+}
+
+func TestLFSPointers(t *testing.T) {
+	testLFSPointers(t, newLocalTestProvider)
+}
+
+func testLFSPointers(t *testing.T, newProvider testProviderFunc) {
+	content := []byte("hello, large file\n")
+	oid := "49454600e4de1f591a5df59aa53d41264e8cb3f98d9ca4eeaa221e0228aa997c"
+	pointer := lfsPointerPrefix + "oid sha256:" + oid + "\nsize 18\n"
+
+	source := createTestRepo(t)
+	if err := os.WriteFile(filepath.Join(source, ".gitattributes"), []byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"), 0644); err != nil {
+		t.Fatalf("failed to write .gitattributes: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "data.bin"), []byte(pointer), 0644); err != nil {
+		t.Fatalf("failed to write pointer: %v", err)
+	}
+	runGit(t, source, "add", ".")
+	runGit(t, source, "commit", "-m", "Add LFS file")
+
+	ctx := context.Background()
+	provider := newProvider(t, t.TempDir())
+	workDir, _, err := provider.EnsureWorkspace(ctx, "proj", "ws-lfs", source, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+
+	files, err := provider.FileTree(ctx, "ws-lfs", "")
+	if err != nil {
+		t.Fatalf("FileTree failed: %v", err)
+	}
+	for _, f := range files {
+		switch f.Path {
+		case "data.bin":
+			if !f.LFS || f.Size != int64(len(content)) {
+				t.Errorf("data.bin = %+v, want an LFS file of %d bytes", f, len(content))
+			}
+		case "README.md":
+			if f.LFS {
+				t.Error("README.md reported as an LFS file")
+			}
+		}
+	}
+
+	// Without the object the pointer is all there is
+	if got, err := provider.ReadFile(ctx, "ws-lfs", "HEAD", "data.bin"); err != nil || string(got) != pointer {
+		t.Errorf("ReadFile = %q, %v; want the pointer", got, err)
+	}
+
+	objPath := filepath.Join(workDir, ".git", "lfs", "objects", oid[:2], oid[2:4], oid)
+	if err := os.MkdirAll(filepath.Dir(objPath), 0755); err != nil {
+		t.Fatalf("failed to create object directory: %v", err)
+	}
+	if err := os.WriteFile(objPath, content, 0644); err != nil {
+		t.Fatalf("failed to write object: %v", err)
+	}
+	for _, ref := range []string{"HEAD", ""} {
+		if got, err := provider.ReadFile(ctx, "ws-lfs", ref, "data.bin"); err != nil || string(got) != string(content) {
+			t.Errorf("ReadFile(%q) = %q, %v; want the object content", ref, got, err)
+		}
+	}
+
+	for _, data := range []string{
+		"version https://git-lfs.github.com/spec/v1\nsize 18\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:xyz\nsize 18\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize -\n",
+		"oid sha256:" + oid + "\nsize 18\n",
+	} {
+		if _, ok := parseLFSPointer([]byte(data)); ok {
+			t.Errorf("parseLFSPointer accepted %q", data)
+		}
+	}
+}
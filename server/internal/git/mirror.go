@@ -0,0 +1,209 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mirrorConfigKey records in a workspace's git config the mirror it was
+// cloned from.
+const mirrorConfigKey = "discobot.mirror"
+
+// mirror serializes the updates of one bare mirror.
+type mirror struct {
+	mu      sync.Mutex
+	fetched time.Time // when the last fetch from the remote finished
+}
+
+// WithCloneDepth makes mirrors shallow, keeping the last depth commits of
+// each branch. Zero keeps the full history.
+func WithCloneDepth(depth int) LocalProviderOption {
+	return func(p *LocalProvider) {
+		p.cloneDepth = depth
+	}
+}
+
+// mirrorDir returns the path of the bare mirror of remote for a project:
+// {baseDir}/{projectID}/mirrors/{hash of remote}.git
+func (p *LocalProvider) mirrorDir(projectID, remote string) string {
+	sum := sha256.Sum256([]byte(remote))
+	return filepath.Join(p.baseDir, projectID, "mirrors", hex.EncodeToString(sum[:8])+".git")
+}
+
+// getMirror returns the update state of a mirror, creating it if needed.
+func (p *LocalProvider) getMirror(dir string) *mirror {
+	p.mirrorsMu.Lock()
+	defer p.mirrorsMu.Unlock()
+
+	if m, ok := p.mirrors[dir]; ok {
+		return m
+	}
+	m := &mirror{}
+	p.mirrors[dir] = m
+	return m
+}
+
+// ensureMirror creates the project's bare mirror of remote, or brings an
+// existing one up to date, and returns its path.
+func (p *LocalProvider) ensureMirror(ctx context.Context, projectID, remote string) (string, error) {
+	dir := p.mirrorDir(projectID, remote)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, p.updateMirror(ctx, projectID, dir, time.Now())
+	}
+
+	m := p.getMirror(dir)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create mirrors directory: %w", err)
+	}
+
+	// Clone next to the final path so a failed clone never leaves a
+	// half-populated mirror behind
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), ".clone-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	// Unlike --mirror, only branches and tags are kept; forges publish far
+	// more refs (e.g. GitHub's refs/pull/*) that workspaces never use.
+	args := []string{"clone", "--bare"}
+	if p.cloneDepth > 0 {
+		args = append(args, "--depth", strconv.Itoa(p.cloneDepth), "--no-single-branch")
+	}
+	args = append(args, remote, tmpDir)
+	if err := p.runRemoteGit(ctx, projectID, remote, "", args...); err != nil {
+		return "", err
+	}
+	if err := p.runGit(ctx, tmpDir, "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"); err != nil {
+		return "", err
+	}
+	if err := p.runGit(ctx, tmpDir, "config", "--add", "remote.origin.fetch", "+refs/tags/*:refs/tags/*"); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", fmt.Errorf("failed to move mirror into place: %w", err)
+	}
+	m.fetched = time.Now()
+
+	return dir, nil
+}
+
+// updateMirror fetches the remote into a mirror. When several workspaces of
+// the same mirror fetch at once, only the first one goes to the remote: a
+// fetch that finished after since already covers the caller.
+func (p *LocalProvider) updateMirror(ctx context.Context, projectID, dir string, since time.Time) error {
+	m := p.getMirror(dir)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fetched.After(since) {
+		return nil
+	}
+
+	args := []string{"fetch", "--prune"}
+	if p.cloneDepth > 0 {
+		args = append(args, "--depth", strconv.Itoa(p.cloneDepth))
+	}
+	args = append(args, "origin")
+	if err := p.runRemoteGit(ctx, projectID, p.originURL(ctx, dir), dir, args...); err != nil {
+		return err
+	}
+	m.fetched = time.Now()
+
+	return nil
+}
+
+// cloneFromMirror creates a workspace from a mirror. The clone hardlinks the
+// mirror's objects instead of borrowing them through alternates: the
+// workspace is mounted read-only into sandboxes, where the mirror's path
+// does not exist, so it has to be a self-contained repository.
+// The origin remote points at the real remote again afterwards.
+func (p *LocalProvider) cloneFromMirror(ctx context.Context, mirrorDir, remote, ref, workDir string) error {
+	args := []string{"clone", "--local"}
+	if ref != "" {
+		args = append(args, "-b", ref)
+	}
+	args = append(args, mirrorDir, workDir)
+	if err := p.runGit(ctx, "", args...); err != nil {
+		return err
+	}
+
+	if err := p.runGit(ctx, workDir, "remote", "set-url", "origin", remote); err != nil {
+		_ = os.RemoveAll(workDir)
+		return err
+	}
+	if err := p.runGit(ctx, workDir, "config", mirrorConfigKey, mirrorDir); err != nil {
+		_ = os.RemoveAll(workDir)
+		return err
+	}
+	return nil
+}
+
+// fetchFromMirror updates a workspace's remote-tracking branches and tags
+// from its mirror after updating the mirror from the remote.
+func (p *LocalProvider) fetchFromMirror(ctx context.Context, projectID, workDir, mirrorDir string) error {
+	if err := p.updateMirror(ctx, projectID, mirrorDir, time.Now()); err != nil {
+		return err
+	}
+	return p.runGit(ctx, workDir, "fetch", "--prune", mirrorDir,
+		"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*")
+}
+
+// workspaceMirror returns the mirror a workspace was cloned from, or "" if it
+// was cloned directly.
+func (p *LocalProvider) workspaceMirror(ctx context.Context, workDir string) string {
+	out, err := p.runGitOutput(ctx, "", "config", "--file", filepath.Join(workDir, ".git", "config"), "--get", mirrorConfigKey)
+	if err != nil {
+		return ""
+	}
+	dir := strings.TrimSpace(out)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// removeUnusedMirror deletes a mirror once no workspace of the project was
+// cloned from it. It holds the project lock so no workspace is being cloned
+// from the mirror meanwhile.
+func (p *LocalProvider) removeUnusedMirror(ctx context.Context, projectID, mirrorDir string) error {
+	projectLock := p.getProjectLock(projectID)
+	projectLock.Lock()
+	defer projectLock.Unlock()
+
+	workspaces, err := os.ReadDir(filepath.Join(p.baseDir, projectID, "workspaces"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, ws := range workspaces {
+		if !ws.IsDir() {
+			continue
+		}
+		if p.workspaceMirror(ctx, filepath.Join(p.baseDir, projectID, "workspaces", ws.Name())) == mirrorDir {
+			return nil
+		}
+	}
+
+	m := p.getMirror(mirrorDir)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.RemoveAll(mirrorDir); err != nil {
+		return fmt.Errorf("failed to remove mirror: %w", err)
+	}
+	p.mirrorsMu.Lock()
+	delete(p.mirrors, mirrorDir)
+	p.mirrorsMu.Unlock()
+	return nil
+}
@@ -27,5 +27,7 @@ func (s *StoreWorkspaceSource) GetWorkspaceInfo(ctx context.Context, workspaceID
 		ProjectID:   ws.ProjectID,
 		Path:        ws.Path,
 		SourceType:  ws.SourceType,
+		Submodules:  ws.Submodules,
+		LFS:         ws.LFS,
 	}, nil
 }
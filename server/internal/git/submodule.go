@@ -0,0 +1,72 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// workspaceOptions returns whether a workspace's submodules and Git LFS
+// objects are to be checked out. Both are off without a workspace source.
+func (p *LocalProvider) workspaceOptions(ctx context.Context, workspaceID string) (submodules, lfs bool) {
+	if p.workspaceSource == nil {
+		return false, false
+	}
+	info, err := p.workspaceSource.GetWorkspaceInfo(ctx, workspaceID)
+	if err != nil {
+		return false, false
+	}
+	return info.Submodules, info.LFS
+}
+
+// checkoutExtras brings the submodules and LFS files of a workspace in line
+// with its HEAD, as far as the workspace's options ask for them. Missing LFS
+// objects are not an error: their pointers stay in the working tree.
+func (p *LocalProvider) checkoutExtras(ctx context.Context, projectID, workspaceID, workDir string) error {
+	submodules, lfs := p.workspaceOptions(ctx, workspaceID)
+	if submodules {
+		if err := p.updateSubmodules(ctx, projectID, workDir, true); err != nil {
+			return fmt.Errorf("failed to update submodules: %w", err)
+		}
+	}
+	if lfs {
+		if err := p.pullLFS(ctx, projectID, workDir, submodules); err != nil {
+			log.Printf("Failed to pull LFS objects of workspace %s: %v", workspaceID, err)
+		}
+	}
+	return nil
+}
+
+// updateSubmodules checks out the submodules of workDir recursively at the
+// commits its HEAD records, fetching them with the project's credentials.
+// Without init only submodules that are already checked out are updated.
+func (p *LocalProvider) updateSubmodules(ctx context.Context, projectID, workDir string, init bool) error {
+	if _, err := os.Stat(filepath.Join(workDir, ".gitmodules")); err != nil {
+		return nil
+	}
+	args := []string{"submodule", "update", "--recursive"}
+	if init {
+		args = append(args, "--init")
+	}
+	return p.runRemoteGit(ctx, projectID, p.originURL(ctx, workDir), workDir, args...)
+}
+
+// syncAfterApply updates a workspace after patches moved its HEAD: git am
+// only records submodule pointer bumps in the index, leaving the submodules'
+// checkouts behind, and writes LFS pointers instead of their content.
+// Commits made inside a sandbox's submodule may not exist upstream, so
+// failures are logged rather than returned.
+func (p *LocalProvider) syncAfterApply(ctx context.Context, workspaceID, workDir string) {
+	projectID := p.workspaceProject(workspaceID)
+	submodules, lfs := p.workspaceOptions(ctx, workspaceID)
+	if err := p.updateSubmodules(ctx, projectID, workDir, submodules); err != nil {
+		log.Printf("Failed to update submodules of workspace %s: %v", workspaceID, err)
+	}
+	if lfs {
+		if err := p.pullLFS(ctx, projectID, workDir, submodules); err != nil {
+			log.Printf("Failed to pull LFS objects of workspace %s: %v", workspaceID, err)
+		}
+	}
+}
@@ -89,7 +89,7 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// Block chat during commit states
-		if existingSession.CommitStatus == "pending" || existingSession.CommitStatus == "committing" {
+		if existingSession.CommitStatus == "pending" || existingSession.CommitStatus == "committing" || existingSession.CommitStatus == "conflicted" {
 			h.Error(w, http.StatusConflict, "Cannot send messages while session is committing")
 			return
 		}
@@ -152,6 +152,15 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		streamCancel()
 		if completionDone {
+			// Keep the transcript server-side so it outlives the sandbox
+			if err := h.chatService.MirrorTranscript(sendCtx, projectID, sessionID); err != nil {
+				log.Printf("[Chat] Warning: failed to store transcript for session %s: %v", sessionID, err)
+			}
+			// Checkpoint the files before the session is marked ready, so a
+			// ready session's latest turn can always be restored
+			if _, err := h.chatService.RecordCheckpoint(sendCtx, projectID, sessionID); err != nil {
+				log.Printf("[Chat] Warning: failed to record checkpoint for session %s: %v", sessionID, err)
+			}
 			// Reset session status to ready after chat completion
 			// Use sendCtx (not request ctx) since the request may already be cancelled
 			if _, err := h.sessionService.UpdateStatus(sendCtx, projectID, sessionID, model.SessionStatusReady, nil); err != nil {
@@ -427,6 +436,14 @@ func (h *Handler) ChatCancel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Store the partial turn and checkpoint the files like a finished turn
+	if err := h.chatService.MirrorTranscript(ctx, projectID, sessionID); err != nil {
+		log.Printf("[ChatCancel] Warning: failed to store transcript for session %s: %v", sessionID, err)
+	}
+	if _, err := h.chatService.RecordCheckpoint(ctx, projectID, sessionID); err != nil {
+		log.Printf("[ChatCancel] Warning: failed to record checkpoint for session %s: %v", sessionID, err)
+	}
+
 	// Update session status to ready after successful cancellation
 	// UpdateStatus now automatically publishes SSE event
 	if _, err := h.sessionService.UpdateStatus(ctx, projectID, sessionID, model.SessionStatusReady, nil); err != nil {
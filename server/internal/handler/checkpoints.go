@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// ListCheckpoints returns the workspace checkpoints recorded for a session,
+// one per finished chat completion.
+// GET /api/projects/{projectId}/sessions/{sessionId}/checkpoints
+func (h *Handler) ListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	checkpoints, err := h.chatService.ListCheckpoints(ctx, projectID, sessionID)
+	if err != nil {
+		h.checkpointError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"checkpoints": checkpoints})
+}
+
+// GetCheckpointDiff compares the checkpoint recorded for a message with the
+// session's current files.
+// GET /api/projects/{projectId}/sessions/{sessionId}/checkpoints/{messageId}/diff?path=...
+func (h *Handler) GetCheckpointDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	messageID := chi.URLParam(r, "messageId")
+	path := r.URL.Query().Get("path")
+
+	diff, err := h.chatService.DiffCheckpoint(ctx, projectID, sessionID, messageID, path)
+	if err != nil {
+		h.checkpointError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, diff)
+}
+
+// RestoreCheckpoint resets the session's files to the checkpoint recorded for a message.
+// POST /api/projects/{projectId}/sessions/{sessionId}/checkpoints/{messageId}/restore
+func (h *Handler) RestoreCheckpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	messageID := chi.URLParam(r, "messageId")
+
+	result, err := h.chatService.RestoreCheckpoint(ctx, projectID, sessionID, messageID)
+	if err != nil {
+		h.checkpointError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) checkpointError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCheckpointNotFound):
+		h.Error(w, http.StatusNotFound, "No checkpoint for this message")
+	case errors.Is(err, service.ErrCompletionRunning):
+		h.Error(w, http.StatusConflict, err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		h.Error(w, http.StatusNotFound, "Session not found")
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strings"
@@ -124,6 +125,11 @@ func (h *Handler) RefreshCredential(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sandbox proxies holding the old token get the new one
+	if h.sandboxService != nil {
+		go h.sandboxService.PushProjectCredentialSecrets(context.WithoutCancel(r.Context()), projectID)
+	}
+
 	// Return success response with new expiration time
 	response := map[string]any{
 		"success":   true,
@@ -489,3 +495,73 @@ func (h *Handler) CodexExchange(w http.ResponseWriter, r *http.Request) {
 
 	h.JSON(w, http.StatusOK, response)
 }
+
+// SetGitCredentialRequest is the request body for creating/updating a git credential
+type SetGitCredentialRequest struct {
+	Host             string `json:"host"`
+	AuthType         string `json:"authType"` // "ssh_key" or "https_token"
+	Username         string `json:"username,omitempty"`
+	PrivateKey       string `json:"privateKey,omitempty"`
+	Token            string `json:"token,omitempty"`
+	ForwardToSandbox bool   `json:"forwardToSandbox"`
+}
+
+// ListGitCredentials returns the git credentials of a project (safe info only)
+func (h *Handler) ListGitCredentials(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+
+	credentials, err := h.credentialService.ListGitCredentials(r.Context(), projectID)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, "Failed to list git credentials")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"credentials": credentials})
+}
+
+// SetGitCredential creates or updates the git credential of a host
+func (h *Handler) SetGitCredential(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+
+	var req SetGitCredentialRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	info, err := h.credentialService.SetGitCredential(r.Context(), projectID, service.GitCredentialInput{
+		Host:             req.Host,
+		AuthType:         req.AuthType,
+		Username:         req.Username,
+		PrivateKey:       req.PrivateKey,
+		Token:            req.Token,
+		ForwardToSandbox: req.ForwardToSandbox,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidGitCredential) {
+			h.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to save git credential")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, info)
+}
+
+// DeleteGitCredential deletes a git credential
+func (h *Handler) DeleteGitCredential(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	credentialID := chi.URLParam(r, "credentialId")
+
+	if err := h.credentialService.DeleteGitCredential(r.Context(), projectID, credentialID); err != nil {
+		if errors.Is(err, service.ErrGitCredentialNotFound) {
+			h.Error(w, http.StatusNotFound, "Git credential not found")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to delete git credential")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
@@ -2,11 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/events"
 )
 
 // Events handles SSE event streaming for a project.
@@ -15,7 +20,12 @@ import (
 //   - since: RFC3339 timestamp to get events after (e.g., "2024-01-15T10:30:00Z")
 //   - after: Event ID to get events after (alternative to since)
 //
-// If neither is provided, only new events from the time of connection are streamed.
+// Every event carries its sequence number as the SSE id. A Last-Event-ID
+// header (sent by EventSource on reconnect) resumes after that event and takes
+// precedence over the query parameters. If events after it may have been
+// deleted by retention, a "reset" event tells the client to reload its state.
+//
+// If none is provided, only new events from the time of connection are streamed.
 func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "projectId")
 	if projectID == "" {
@@ -33,6 +43,7 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	sinceStr := r.URL.Query().Get("since")
 	afterID := r.URL.Query().Get("after")
+	lastEventID := r.Header.Get("Last-Event-ID")
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -53,23 +64,35 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 	sentEventIDs := make(map[string]bool)
 
 	// Send historical events if requested
-	if afterID != "" {
-		// Get events after a specific event ID
-		events, err := h.eventBroker.GetEventsAfterID(r.Context(), projectID, afterID)
+	sendHistory := func(history []*events.Event, err error) {
 		if err != nil {
 			_, _ = fmt.Fprintf(w, "event: error\ndata: {\"error\":\"failed to get historical events\"}\n\n")
 			flusher.Flush()
-		} else {
-			for _, event := range events {
-				data, err := json.Marshal(event)
-				if err != nil {
-					continue
-				}
-				_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			return
+		}
+		for _, event := range history {
+			if writeSSEEvent(w, event) == nil {
 				sentEventIDs[event.ID] = true
 			}
+		}
+		flusher.Flush()
+	}
+
+	if lastEventID != "" {
+		// Resume after the last event the client received
+		seq, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			h.writeResetEvent(w, r, projectID, lastEventID, "invalid_last_event_id")
+			flusher.Flush()
+		} else if history, err := h.eventBroker.GetEventsAfterSeq(r.Context(), projectID, seq); errors.Is(err, events.ErrEventsPruned) {
+			h.writeResetEvent(w, r, projectID, lastEventID, "events_pruned")
 			flusher.Flush()
+		} else {
+			sendHistory(history, err)
 		}
+	} else if afterID != "" {
+		// Get events after a specific event ID
+		sendHistory(h.eventBroker.GetEventsAfterID(r.Context(), projectID, afterID))
 	} else if sinceStr != "" {
 		// Parse timestamp and get events since that time
 		since, err := time.Parse(time.RFC3339, sinceStr)
@@ -85,21 +108,7 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if !since.IsZero() {
-			events, err := h.eventBroker.GetEventsSince(r.Context(), projectID, since)
-			if err != nil {
-				_, _ = fmt.Fprintf(w, "event: error\ndata: {\"error\":\"failed to get historical events\"}\n\n")
-				flusher.Flush()
-			} else {
-				for _, event := range events {
-					data, err := json.Marshal(event)
-					if err != nil {
-						continue
-					}
-					_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
-					sentEventIDs[event.ID] = true
-				}
-				flusher.Flush()
-			}
+			sendHistory(h.eventBroker.GetEventsSince(r.Context(), projectID, since))
 		}
 	}
 
@@ -121,15 +130,35 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			// Serialize event data
-			data, err := json.Marshal(event)
-			if err != nil {
-				continue
+			if writeSSEEvent(w, event) == nil {
+				flusher.Flush()
 			}
-
-			// Write SSE format: event: <type>\ndata: <json>\n\n
-			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
-			flusher.Flush()
 		}
 	}
 }
+
+// writeSSEEvent writes an event in SSE format: id: <seq>\nevent: <type>\ndata: <json>\n\n
+func writeSSEEvent(w io.Writer, event *events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+	return err
+}
+
+// writeResetEvent tells the client that the stream cannot be resumed from
+// lastEventID. Its id is the project's newest event so that a reconnect after
+// the client reloaded its state resumes from there.
+func (h *Handler) writeResetEvent(w io.Writer, r *http.Request, projectID, lastEventID, reason string) {
+	id := ""
+	if seq, err := h.eventBroker.LatestSeq(r.Context(), projectID); err == nil && seq > 0 {
+		id = strconv.FormatInt(seq, 10)
+	}
+
+	data, _ := json.Marshal(map[string]string{
+		"reason":      reason,
+		"lastEventId": lastEventID,
+	})
+	_, _ = fmt.Fprintf(w, "id: %s\nevent: reset\ndata: %s\n\n", id, data)
+}
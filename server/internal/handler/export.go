@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// ExportSession downloads a session's work as mbox patches, a git bundle, a
+// diff of the uncommitted changes, or a zip or tar archive of the changed files
+// GET /api/projects/{projectId}/sessions/{sessionId}/export?format=mbox
+func (h *Handler) ExportSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "mbox"
+	}
+
+	export, err := h.chatService.ExportSession(ctx, projectID, sessionID, format)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidExportFormat):
+			h.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrNothingToExport):
+			h.Error(w, http.StatusNotFound, err.Error())
+		case strings.Contains(err.Error(), "not found"), strings.Contains(err.Error(), "does not belong"):
+			h.Error(w, http.StatusNotFound, "Session not found")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	defer func() { _ = export.Body.Close() }()
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.Filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, export.Body); err != nil {
+		// The status is already sent; abort so the client sees a truncated
+		// download rather than a complete one
+		log.Printf("Failed to export session %s as %s: %v", sessionID, format, err)
+		panic(http.ErrAbortHandler)
+	}
+}
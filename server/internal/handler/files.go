@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -413,5 +414,10 @@ func (h *Handler) GetSessionDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Review threads on lines this diff no longer shows are outdated
+	if err := h.reviewService.UpdateOutdated(ctx, sessionID, result); err != nil {
+		log.Printf("Failed to update outdated review threads for session %s: %v", sessionID, err)
+	}
+
 	h.JSON(w, http.StatusOK, result)
 }
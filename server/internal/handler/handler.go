@@ -37,6 +37,11 @@ type Handler struct {
 	workspaceService    *service.WorkspaceService
 	projectService      *service.ProjectService
 	preferenceService   *service.PreferenceService
+	jobService          *service.JobService
+	scheduleService     *service.ScheduleService
+	webhookService      *service.WebhookService
+	pullRequestService  *service.PullRequestService
+	reviewService       *service.ReviewService
 	jobQueue            *jobs.Queue
 	eventBroker         *events.Broker
 	codexCallbackServer *CodexCallbackServer
@@ -87,6 +92,19 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 	workspaceSvc := service.NewWorkspaceService(s, gitProvider, eventBroker)
 	projectSvc := service.NewProjectService(s, sandboxProvider)
 	preferenceSvc := service.NewPreferenceService(s)
+	jobSvc := service.NewJobService(s, eventBroker)
+	scheduleSvc := service.NewScheduleService(s, chatSvc, jobQueue)
+	reviewSvc := service.NewReviewService(s, chatSvc)
+	webhookSvc, err := service.NewWebhookService(s, cfg, jobQueue)
+	if err != nil {
+		// This should only fail if the encryption key is invalid
+		panic("failed to create webhook service: " + err.Error())
+	}
+
+	var pullRequestSvc *service.PullRequestService
+	if gitSvc != nil {
+		pullRequestSvc = service.NewPullRequestService(s, gitSvc, service.NewForgeRegistry(cfg), eventBroker)
+	}
 
 	// Convert agentTypes for models service
 	serviceAgentTypes := make([]service.AgentType, len(agentTypes))
@@ -100,25 +118,30 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 	modelsSvc := service.NewModelsService(s, agentSvc, credSvc, sandboxSvc, serviceAgentTypes)
 
 	h := &Handler{
-		store:             s,
-		cfg:               cfg,
-		authService:       service.NewAuthService(s, cfg),
-		credentialService: credSvc,
-		gitService:        gitSvc,
-		gitProvider:       gitProvider,
-		sandboxProvider:   sandboxProvider,
-		sandboxManager:    sandboxManager,
-		sandboxService:    sandboxSvc,
-		sessionService:    sessionSvc,
-		chatService:       chatSvc,
-		agentService:      agentSvc,
-		modelsService:     modelsSvc,
-		workspaceService:  workspaceSvc,
-		projectService:    projectSvc,
-		preferenceService: preferenceSvc,
-		jobQueue:          jobQueue,
-		eventBroker:       eventBroker,
-		systemManager:     systemManager,
+		store:              s,
+		cfg:                cfg,
+		authService:        service.NewAuthService(s, cfg),
+		credentialService:  credSvc,
+		gitService:         gitSvc,
+		gitProvider:        gitProvider,
+		sandboxProvider:    sandboxProvider,
+		sandboxManager:     sandboxManager,
+		sandboxService:     sandboxSvc,
+		sessionService:     sessionSvc,
+		chatService:        chatSvc,
+		agentService:       agentSvc,
+		modelsService:      modelsSvc,
+		workspaceService:   workspaceSvc,
+		projectService:     projectSvc,
+		preferenceService:  preferenceSvc,
+		jobService:         jobSvc,
+		scheduleService:    scheduleSvc,
+		webhookService:     webhookSvc,
+		pullRequestService: pullRequestSvc,
+		reviewService:      reviewSvc,
+		jobQueue:           jobQueue,
+		eventBroker:        eventBroker,
+		systemManager:      systemManager,
 	}
 
 	// Create Codex callback server (will be started on first use)
@@ -152,6 +175,11 @@ func (h *Handler) JobQueue() *jobs.Queue {
 	return h.jobQueue
 }
 
+// JobService returns the handler's job service.
+func (h *Handler) JobService() *service.JobService {
+	return h.jobService
+}
+
 // EventBroker returns the handler's event broker for SSE.
 func (h *Handler) EventBroker() *events.Broker {
 	return h.eventBroker
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// ImportSession applies a patch, a ref of the workspace's remote, or another
+// session's commits to the session's workspace
+// POST /api/projects/{projectId}/sessions/{sessionId}/import
+func (h *Handler) ImportSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	var req service.ImportSessionRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.chatService.ImportSession(ctx, projectID, sessionID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidImport), errors.Is(err, service.ErrNothingToImport):
+			h.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrImportSessionBusy):
+			h.Error(w, http.StatusConflict, err.Error())
+		case strings.Contains(err.Error(), "not found"), strings.Contains(err.Error(), "does not belong"):
+			h.Error(w, http.StatusNotFound, "Session not found")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
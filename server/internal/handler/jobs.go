@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// defaultJobListLimit caps job listings when no limit is given.
+const defaultJobListLimit = 100
+
+// ListJobs returns the project's jobs, newest first.
+// GET /api/projects/{projectId}/jobs?type=...&status=...&resourceType=...&resourceId=...&limit=...&offset=...
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	filter := jobFilterFromQuery(r)
+	filter.ProjectID = middleware.GetProjectID(r.Context())
+	h.listJobs(w, r, filter)
+}
+
+// GetJob returns a project job, including its payload and last error.
+// GET /api/projects/{projectId}/jobs/{jobId}
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	h.getJob(w, r, middleware.GetProjectID(r.Context()))
+}
+
+// RetryJob requeues a failed or cancelled project job.
+// POST /api/projects/{projectId}/jobs/{jobId}/retry
+func (h *Handler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	h.retryJob(w, r, middleware.GetProjectID(r.Context()))
+}
+
+// CancelJob cancels a pending or running project job.
+// POST /api/projects/{projectId}/jobs/{jobId}/cancel
+func (h *Handler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	h.cancelJob(w, r, middleware.GetProjectID(r.Context()))
+}
+
+// AdminListJobs returns jobs across all projects, newest first.
+// GET /api/admin/jobs?projectId=...&type=...&status=...&resourceType=...&resourceId=...&limit=...&offset=...
+func (h *Handler) AdminListJobs(w http.ResponseWriter, r *http.Request) {
+	filter := jobFilterFromQuery(r)
+	filter.ProjectID = r.URL.Query().Get("projectId")
+	h.listJobs(w, r, filter)
+}
+
+// AdminGetJob returns any job.
+// GET /api/admin/jobs/{jobId}
+func (h *Handler) AdminGetJob(w http.ResponseWriter, r *http.Request) {
+	h.getJob(w, r, "")
+}
+
+// AdminRetryJob requeues any failed or cancelled job.
+// POST /api/admin/jobs/{jobId}/retry
+func (h *Handler) AdminRetryJob(w http.ResponseWriter, r *http.Request) {
+	h.retryJob(w, r, "")
+}
+
+// AdminCancelJob cancels any pending or running job.
+// POST /api/admin/jobs/{jobId}/cancel
+func (h *Handler) AdminCancelJob(w http.ResponseWriter, r *http.Request) {
+	h.cancelJob(w, r, "")
+}
+
+func (h *Handler) listJobs(w http.ResponseWriter, r *http.Request, filter store.JobFilter) {
+	jobList, err := h.jobService.ListJobs(r.Context(), filter)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"jobs": jobList})
+}
+
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request, projectID string) {
+	job, err := h.jobService.GetJob(r.Context(), projectID, chi.URLParam(r, "jobId"))
+	if err != nil {
+		h.jobError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, job)
+}
+
+func (h *Handler) retryJob(w http.ResponseWriter, r *http.Request, projectID string) {
+	job, err := h.jobService.RetryJob(r.Context(), projectID, chi.URLParam(r, "jobId"))
+	if err != nil {
+		h.jobError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, job)
+}
+
+func (h *Handler) cancelJob(w http.ResponseWriter, r *http.Request, projectID string) {
+	job, err := h.jobService.CancelJob(r.Context(), projectID, chi.URLParam(r, "jobId"))
+	if err != nil {
+		h.jobError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, job)
+}
+
+func (h *Handler) jobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		h.Error(w, http.StatusNotFound, "Job not found")
+	case errors.Is(err, service.ErrJobNotRetryable),
+		errors.Is(err, service.ErrJobNotCancellable),
+		errors.Is(err, jobs.ErrJobAlreadyExists):
+		h.Error(w, http.StatusConflict, err.Error())
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// jobFilterFromQuery reads the job list filters shared by the project and
+// admin endpoints.
+func jobFilterFromQuery(r *http.Request) store.JobFilter {
+	q := r.URL.Query()
+	filter := store.JobFilter{
+		Type:         q.Get("type"),
+		Status:       q.Get("status"),
+		ResourceType: q.Get("resourceType"),
+		ResourceID:   q.Get("resourceId"),
+		Limit:        defaultJobListLimit,
+	}
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		filter.Limit = n
+	}
+	if n, err := strconv.Atoi(q.Get("offset")); err == nil && n > 0 {
+		filter.Offset = n
+	}
+	return filter
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// ListNetwork lists the HTTP requests recorded by the sandbox proxy
+// GET /api/projects/{projectId}/sessions/{sessionId}/network?since=41&host=github.com&limit=100
+func (h *Handler) ListNetwork(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	filter, err := parseNetworkFilter(r)
+	if err != nil {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.chatService.ListNetwork(ctx, projectID, sessionID, filter)
+	if err != nil {
+		h.networkError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+// ExportNetworkHAR downloads the HTTP requests recorded by the sandbox proxy
+// as a HAR file
+// GET /api/projects/{projectId}/sessions/{sessionId}/network/har
+func (h *Handler) ExportNetworkHAR(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	filter, err := parseNetworkFilter(r)
+	if err != nil {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	har, err := h.chatService.ExportNetworkHAR(ctx, projectID, sessionID, filter)
+	if err != nil {
+		h.networkError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="session-`+sessionID+`.har"`)
+	h.JSON(w, http.StatusOK, har)
+}
+
+// ClearNetwork discards the HTTP requests recorded by the sandbox proxy
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/network
+func (h *Handler) ClearNetwork(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	if err := h.chatService.ClearNetwork(ctx, projectID, sessionID); err != nil {
+		h.networkError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// networkError writes the response for a failed network request.
+func (h *Handler) networkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sandbox.ErrNotRunning):
+		h.Error(w, http.StatusConflict, "Sandbox is not running")
+	case strings.Contains(err.Error(), "not found"), strings.Contains(err.Error(), "does not belong"):
+		h.Error(w, http.StatusNotFound, "Session not found")
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// parseNetworkFilter parses the since, host and limit query parameters.
+func parseNetworkFilter(r *http.Request) (service.NetworkFilter, error) {
+	var f service.NetworkFilter
+	q := r.URL.Query()
+	if v := q.Get("since"); v != "" {
+		since, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid since")
+		}
+		f.Since = since
+	}
+	f.Host = q.Get("host")
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = limit
+	}
+	return f, nil
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// ReviewCommentRequest is the request body for adding or editing a review comment.
+type ReviewCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// UpdateReviewThreadRequest is the request body for resolving or reopening a thread.
+type UpdateReviewThreadRequest struct {
+	Resolved bool `json:"resolved"`
+}
+
+// ListReviewThreads returns the review threads of a session
+// GET /api/projects/{projectId}/sessions/{sessionId}/reviews
+func (h *Handler) ListReviewThreads(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+
+	threads, err := h.reviewService.ListReviewThreads(r.Context(), projectID, sessionID)
+	if err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"threads": threads})
+}
+
+// CreateReviewThread comments on a line range of the session's diff
+// POST /api/projects/{projectId}/sessions/{sessionId}/reviews
+func (h *Handler) CreateReviewThread(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	var req service.CreateReviewThreadRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	thread, err := h.reviewService.CreateReviewThread(ctx, projectID, sessionID, middleware.GetUserID(ctx), req)
+	if err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, thread)
+}
+
+// UpdateReviewThread resolves or reopens a review thread
+// PUT /api/projects/{projectId}/sessions/{sessionId}/reviews/{threadId}
+func (h *Handler) UpdateReviewThread(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+	threadID := chi.URLParam(r, "threadId")
+
+	var req UpdateReviewThreadRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	thread, err := h.reviewService.SetReviewThreadResolved(r.Context(), projectID, sessionID, threadID, req.Resolved)
+	if err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, thread)
+}
+
+// DeleteReviewThread deletes a review thread and its comments
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/reviews/{threadId}
+func (h *Handler) DeleteReviewThread(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+	threadID := chi.URLParam(r, "threadId")
+
+	if err := h.reviewService.DeleteReviewThread(r.Context(), projectID, sessionID, threadID); err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// AddReviewComment replies to a review thread
+// POST /api/projects/{projectId}/sessions/{sessionId}/reviews/{threadId}/comments
+func (h *Handler) AddReviewComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	threadID := chi.URLParam(r, "threadId")
+
+	var req ReviewCommentRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	comment, err := h.reviewService.AddReviewComment(ctx, projectID, sessionID, threadID, middleware.GetUserID(ctx), req.Body)
+	if err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, comment)
+}
+
+// UpdateReviewComment edits a pending review comment
+// PUT /api/projects/{projectId}/sessions/{sessionId}/reviews/{threadId}/comments/{commentId}
+func (h *Handler) UpdateReviewComment(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+	threadID := chi.URLParam(r, "threadId")
+	commentID := chi.URLParam(r, "commentId")
+
+	var req ReviewCommentRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	comment, err := h.reviewService.UpdateReviewComment(r.Context(), projectID, sessionID, threadID, commentID, req.Body)
+	if err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, comment)
+}
+
+// DeleteReviewComment deletes a review comment, and its thread if it was the last one
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/reviews/{threadId}/comments/{commentId}
+func (h *Handler) DeleteReviewComment(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+	threadID := chi.URLParam(r, "threadId")
+	commentID := chi.URLParam(r, "commentId")
+
+	if err := h.reviewService.DeleteReviewComment(r.Context(), projectID, sessionID, threadID, commentID); err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// SendReview sends the pending review comments to the session's agent
+// POST /api/projects/{projectId}/sessions/{sessionId}/reviews/send
+func (h *Handler) SendReview(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+
+	var req service.SendReviewRequest
+	if r.ContentLength != 0 {
+		if err := h.DecodeJSON(r, &req); err != nil {
+			h.Error(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	result, err := h.reviewService.SendReview(r.Context(), projectID, sessionID, req)
+	if err != nil {
+		h.reviewError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusAccepted, result)
+}
+
+func (h *Handler) reviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrReviewThreadNotFound):
+		h.Error(w, http.StatusNotFound, "Review thread not found")
+	case errors.Is(err, service.ErrReviewCommentNotFound):
+		h.Error(w, http.StatusNotFound, "Review comment not found")
+	case errors.Is(err, store.ErrNotFound), strings.Contains(err.Error(), "does not belong"):
+		h.Error(w, http.StatusNotFound, "Session not found")
+	case errors.Is(err, service.ErrInvalidReview), errors.Is(err, service.ErrNoPendingReview):
+		h.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrReviewCommentSent), errors.Is(err, service.ErrReviewSessionBusy):
+		h.Error(w, http.StatusConflict, err.Error())
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
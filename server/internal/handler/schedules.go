@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// ListSchedules returns all schedules for a project
+// GET /api/projects/{projectId}/schedules
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+
+	schedules, err := h.scheduleService.ListSchedules(r.Context(), projectID)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"schedules": schedules})
+}
+
+// CreateSchedule creates a schedule that starts a session on a cron schedule
+// POST /api/projects/{projectId}/schedules
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+
+	var req service.ScheduleRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(r.Context(), projectID, req)
+	if err != nil {
+		h.scheduleError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, schedule)
+}
+
+// GetSchedule returns a single schedule
+// GET /api/projects/{projectId}/schedules/{scheduleId}
+func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	schedule, err := h.scheduleService.GetSchedule(r.Context(), projectID, scheduleID)
+	if err != nil {
+		h.scheduleError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, schedule)
+}
+
+// UpdateSchedule updates the fields present in the request body
+// PUT /api/projects/{projectId}/schedules/{scheduleId}
+func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	var req service.ScheduleRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	schedule, err := h.scheduleService.UpdateSchedule(r.Context(), projectID, scheduleID, req)
+	if err != nil {
+		h.scheduleError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, schedule)
+}
+
+// DeleteSchedule deletes a schedule and its run history
+// DELETE /api/projects/{projectId}/schedules/{scheduleId}
+func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	if err := h.scheduleService.DeleteSchedule(r.Context(), projectID, scheduleID); err != nil {
+		h.scheduleError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// ListScheduleRuns returns the run history of a schedule, newest first
+// GET /api/projects/{projectId}/schedules/{scheduleId}/runs
+func (h *Handler) ListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	runs, err := h.scheduleService.ListScheduleRuns(r.Context(), projectID, scheduleID)
+	if err != nil {
+		h.scheduleError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+// TriggerSchedule starts a run of the schedule immediately
+// POST /api/projects/{projectId}/schedules/{scheduleId}/run
+func (h *Handler) TriggerSchedule(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	scheduleID := chi.URLParam(r, "scheduleId")
+
+	run, err := h.scheduleService.TriggerSchedule(r.Context(), projectID, scheduleID)
+	if err != nil {
+		h.scheduleError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusAccepted, run)
+}
+
+func (h *Handler) scheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrScheduleNotFound):
+		h.Error(w, http.StatusNotFound, "Schedule not found")
+	case errors.Is(err, service.ErrInvalidSchedule):
+		h.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrScheduleRunActive):
+		h.Error(w, http.StatusConflict, err.Error())
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/obot-platform/discobot/server/internal/git"
 	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
 	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/store"
 )
 
 // GetSession returns a single session
@@ -50,6 +55,24 @@ func (h *Handler) UpdateSession(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Handle targetBranch: only process if key is present; null or "" clears it
+	if targetBranchValue, hasTargetBranch := rawReq["targetBranch"]; hasTargetBranch {
+		targetBranch, _ := targetBranchValue.(string)
+		if _, err := h.sessionService.SetTargetBranch(r.Context(), middleware.GetProjectID(r.Context()), sessionID, targetBranch); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				h.Error(w, http.StatusNotFound, "Session not found")
+			case errors.Is(err, git.ErrInvalidBranch):
+				h.Error(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, service.ErrCommitInProgress):
+				h.Error(w, http.StatusConflict, err.Error())
+			default:
+				h.Error(w, http.StatusInternalServerError, "Failed to update session")
+			}
+			return
+		}
+	}
+
 	session, err := h.sessionService.UpdateSession(r.Context(), sessionID, name, displayName, status)
 	if err != nil {
 		h.Error(w, http.StatusInternalServerError, "Failed to update session")
@@ -77,7 +100,8 @@ func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
-// ListMessages returns messages for a session by querying the container.
+// ListMessages returns messages for a session from its container, or from the
+// stored transcript when the session is stopped or the container is unavailable.
 func (h *Handler) ListMessages(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	projectID := middleware.GetProjectID(ctx)
@@ -105,12 +129,39 @@ func (h *Handler) ListSessionsByWorkspace(w http.ResponseWriter, r *http.Request
 	h.JSON(w, http.StatusOK, map[string]any{"sessions": sessions})
 }
 
+// CommitSessionRequest represents the optional request body for committing a session.
+type CommitSessionRequest struct {
+	// PullRequest also pushes the commit to a branch and opens a pull request
+	// once the commit completes (git URL workspaces only).
+	PullRequest bool `json:"pullRequest,omitempty"`
+}
+
 // CommitSession initiates async commit of a session
 func (h *Handler) CommitSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionId")
 	ctx := r.Context()
 	projectID := middleware.GetProjectID(ctx)
 
+	var req CommitSessionRequest
+	if r.ContentLength != 0 {
+		if err := h.DecodeJSON(r, &req); err != nil {
+			h.Error(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	// Reject an unsupported pull request before starting the commit
+	if req.PullRequest {
+		if h.pullRequestService == nil {
+			h.pullRequestError(w, service.ErrPullRequestUnsupported)
+			return
+		}
+		if err := h.pullRequestService.ValidatePullRequest(ctx, projectID, sessionID); err != nil {
+			h.pullRequestError(w, err)
+			return
+		}
+	}
+
 	if err := h.sessionService.CommitSession(ctx, projectID, sessionID, h.jobQueue); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			h.Error(w, http.StatusNotFound, "Session not found")
@@ -120,9 +171,103 @@ func (h *Handler) CommitSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.PullRequest {
+		if err := h.requestPullRequest(ctx, projectID, sessionID); err != nil {
+			h.pullRequestError(w, err)
+			return
+		}
+	}
+
 	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
+// CreatePullRequest pushes the session's committed changes to a branch and
+// opens a pull request for them. It runs as a job after any pending commit.
+// POST /api/projects/{projectId}/sessions/{sessionId}/pull-request
+func (h *Handler) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	if err := h.requestPullRequest(ctx, projectID, sessionID); err != nil {
+		h.pullRequestError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusAccepted, map[string]bool{"success": true})
+}
+
+func (h *Handler) requestPullRequest(ctx context.Context, projectID, sessionID string) error {
+	if h.pullRequestService == nil {
+		return service.ErrPullRequestUnsupported
+	}
+	return h.pullRequestService.RequestPullRequest(ctx, projectID, sessionID, h.jobQueue)
+}
+
+// pullRequestError maps pull request errors to HTTP responses.
+func (h *Handler) pullRequestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.Error(w, http.StatusNotFound, "Session not found")
+	case errors.Is(err, service.ErrPullRequestUnsupported), errors.Is(err, service.ErrPullRequestNotReady):
+		h.Error(w, http.StatusConflict, err.Error())
+	default:
+		h.Error(w, http.StatusInternalServerError, "Failed to request pull request")
+	}
+}
+
+// ForkSessionRequest represents the request body for forking a session.
+type ForkSessionRequest struct {
+	ID        string `json:"id,omitempty"`
+	MessageID string `json:"messageId,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// ForkSession creates a new session from the sandbox state and chat history of
+// an existing one. If messageId is set, history after that message is dropped.
+// POST /api/projects/{projectId}/sessions/{sessionId}/fork
+func (h *Handler) ForkSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	var req ForkSessionRequest
+	if r.ContentLength != 0 {
+		if err := h.DecodeJSON(r, &req); err != nil {
+			h.Error(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	session, err := h.chatService.ForkSession(ctx, service.ForkSessionRequest{
+		ProjectID:       projectID,
+		SourceSessionID: sessionID,
+		SessionID:       req.ID,
+		MessageID:       req.MessageID,
+		Name:            req.Name,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForkMessageNotFound), errors.Is(err, service.ErrInvalidSessionID), errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrSessionExists):
+			h.Error(w, http.StatusConflict, err.Error())
+		case errors.Is(err, sandbox.ErrNotFound):
+			// The source session's sandbox data is gone
+			h.Error(w, http.StatusNotFound, err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			h.Error(w, http.StatusNotFound, "Session not found")
+		case strings.Contains(err.Error(), "not been initialized"), strings.Contains(err.Error(), "being deleted"):
+			h.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			h.Error(w, http.StatusInternalServerError, "Failed to fork session: "+err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, session)
+}
+
 // CreateSessionRequest represents the request body for creating a session without sending a message.
 type CreateSessionRequest struct {
 	ID          string `json:"id"`
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// ListWebhooks returns all webhooks for a project
+// GET /api/projects/{projectId}/webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+
+	webhooks, err := h.webhookService.ListWebhooks(r.Context(), projectID)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"webhooks": webhooks})
+}
+
+// CreateWebhook creates a webhook subscription
+// POST /api/projects/{projectId}/webhooks
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+
+	var req service.WebhookRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(r.Context(), projectID, req)
+	if err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, webhook)
+}
+
+// GetWebhook returns a single webhook
+// GET /api/projects/{projectId}/webhooks/{webhookId}
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	webhookID := chi.URLParam(r, "webhookId")
+
+	webhook, err := h.webhookService.GetWebhook(r.Context(), projectID, webhookID)
+	if err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook updates the fields present in the request body
+// PUT /api/projects/{projectId}/webhooks/{webhookId}
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	webhookID := chi.URLParam(r, "webhookId")
+
+	var req service.WebhookRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(r.Context(), projectID, webhookID, req)
+	if err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, webhook)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+// DELETE /api/projects/{projectId}/webhooks/{webhookId}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	webhookID := chi.URLParam(r, "webhookId")
+
+	if err := h.webhookService.DeleteWebhook(r.Context(), projectID, webhookID); err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook, newest first
+// GET /api/projects/{projectId}/webhooks/{webhookId}/deliveries
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	webhookID := chi.URLParam(r, "webhookId")
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), projectID, webhookID)
+	if err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"deliveries": deliveries})
+}
+
+// GetWebhookDelivery returns a delivery with its request payload
+// GET /api/projects/{projectId}/webhooks/{webhookId}/deliveries/{deliveryId}
+func (h *Handler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	webhookID := chi.URLParam(r, "webhookId")
+	deliveryID := chi.URLParam(r, "deliveryId")
+
+	delivery, err := h.webhookService.GetDelivery(r.Context(), projectID, webhookID, deliveryID)
+	if err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, delivery)
+}
+
+// RedeliverWebhook sends a delivery's payload again as a new delivery
+// POST /api/projects/{projectId}/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	projectID := middleware.GetProjectID(r.Context())
+	webhookID := chi.URLParam(r, "webhookId")
+	deliveryID := chi.URLParam(r, "deliveryId")
+
+	delivery, err := h.webhookService.Redeliver(r.Context(), projectID, webhookID, deliveryID)
+	if err != nil {
+		h.webhookError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusAccepted, delivery)
+}
+
+func (h *Handler) webhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		h.Error(w, http.StatusNotFound, "Webhook not found")
+	case errors.Is(err, service.ErrWebhookDeliveryNotFound):
+		h.Error(w, http.StatusNotFound, "Webhook delivery not found")
+	case errors.Is(err, service.ErrInvalidWebhook):
+		h.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
 )
 
 // ListWorkspaces returns all workspaces for a project
@@ -31,6 +32,7 @@ func (h *Handler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		DisplayName *string `json:"displayName"`
 		SourceType  string  `json:"sourceType"`
 		Provider    string  `json:"provider"`
+		service.WorkspaceOptions
 	}
 	if err := h.DecodeJSON(r, &req); err != nil {
 		h.Error(w, http.StatusBadRequest, "Invalid request body")
@@ -44,7 +46,7 @@ func (h *Handler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		req.SourceType = "local"
 	}
 
-	workspace, err := h.workspaceService.CreateWorkspace(r.Context(), projectID, req.Path, req.SourceType, req.Provider)
+	workspace, err := h.workspaceService.CreateWorkspace(r.Context(), projectID, req.Path, req.SourceType, req.Provider, req.WorkspaceOptions)
 	if err != nil {
 		// Pass through the detailed error message from the service
 		h.Error(w, http.StatusBadRequest, err.Error())
@@ -138,6 +140,16 @@ func (h *Handler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
 		modified = true
 	}
 
+	// Checkout options apply to the next clone, checkout or sandbox
+	if submodules, ok := rawReq["submodules"].(bool); ok {
+		workspace.Submodules = submodules
+		modified = true
+	}
+	if lfs, ok := rawReq["lfs"].(bool); ok {
+		workspace.LFS = lfs
+		modified = true
+	}
+
 	// Note: Provider cannot be updated after creation - it's set only on Create
 
 	// Save if we modified the workspace
@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log"
 
 	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/events"
 	"github.com/obot-platform/discobot/server/internal/model"
 	"github.com/obot-platform/discobot/server/internal/store"
 )
 
 // Queue provides helper methods for enqueueing jobs.
 type Queue struct {
-	store      *store.Store
-	cfg        *config.Config
-	notifyFunc func() // Called after job creation to notify dispatcher
+	store       *store.Store
+	cfg         *config.Config
+	notifyFunc  func() // Called after job creation to notify dispatcher
+	eventBroker *events.Broker
 }
 
 // NewQueue creates a new job queue helper.
@@ -28,6 +31,11 @@ func (q *Queue) SetNotifyFunc(f func()) {
 	q.notifyFunc = f
 }
 
+// SetEventBroker sets the broker used to publish job_updated events for new jobs.
+func (q *Queue) SetEventBroker(b *events.Broker) {
+	q.eventBroker = b
+}
+
 // notify calls the notify function if set.
 func (q *Queue) notify() {
 	if q.notifyFunc != nil {
@@ -39,8 +47,18 @@ func (q *Queue) notify() {
 const (
 	ResourceTypeSession   = "session"
 	ResourceTypeWorkspace = "workspace"
+	ResourceTypeSchedule  = "schedule"
+	ResourceTypeBranch    = "branch" // Branch of a workspace, ID from BranchResourceID
+
+	ResourceTypeWebhookDelivery = "webhook_delivery"
+	ResourceTypeProjectEvents   = "project_events"
 )
 
+// BranchResourceID returns the resource ID of a branch of a workspace.
+func BranchResourceID(workspaceID, branch string) string {
+	return workspaceID + ":" + branch
+}
+
 // ErrJobAlreadyExists is returned when a job for the resource already exists.
 var ErrJobAlreadyExists = errors.New("job already exists for resource")
 
@@ -83,6 +101,7 @@ func (q *Queue) Enqueue(ctx context.Context, payload JobPayload) error {
 	}
 
 	job := &model.Job{
+		ProjectID:    payloadProjectID(data),
 		Type:         string(payload.JobType()),
 		Payload:      data,
 		Status:       string(model.JobStatusPending),
@@ -95,6 +114,23 @@ func (q *Queue) Enqueue(ctx context.Context, payload JobPayload) error {
 	if err := q.store.CreateJob(ctx, job); err != nil {
 		return err
 	}
+	if q.eventBroker != nil {
+		if err := q.eventBroker.PublishJobUpdated(ctx, job); err != nil {
+			log.Printf("Failed to publish job event for job %s: %v", job.ID, err)
+		}
+	}
 	q.notify()
 	return nil
 }
+
+// payloadProjectID returns the projectId field of a marshaled payload, or nil
+// if the payload has none.
+func payloadProjectID(data []byte) *string {
+	var p struct {
+		ProjectID string `json:"projectId"`
+	}
+	if err := json.Unmarshal(data, &p); err != nil || p.ProjectID == "" {
+		return nil
+	}
+	return &p.ProjectID
+}
@@ -5,10 +5,14 @@ package jobs
 type JobType string
 
 const (
-	JobTypeSessionInit   JobType = "session_init"
-	JobTypeSessionDelete JobType = "session_delete"
-	JobTypeSessionCommit JobType = "session_commit"
-	JobTypeWorkspaceInit JobType = "workspace_init"
+	JobTypeSessionInit        JobType = "session_init"
+	JobTypeSessionDelete      JobType = "session_delete"
+	JobTypeSessionCommit      JobType = "session_commit"
+	JobTypeWorkspaceInit      JobType = "workspace_init"
+	JobTypeScheduleRun        JobType = "schedule_run"
+	JobTypeWebhookDelivery    JobType = "webhook_delivery"
+	JobTypeEventRetention     JobType = "event_retention"
+	JobTypeSessionPullRequest JobType = "session_pull_request"
 )
 
 // JobPayload is implemented by all job payloads. The payload struct itself
@@ -67,16 +71,87 @@ func (p SessionDeletePayload) JobType() JobType              { return JobTypeSes
 func (p SessionDeletePayload) ResourceKey() (string, string) { return ResourceTypeSession, p.SessionID }
 func (p SessionDeletePayload) Priority() int                 { return 5 }
 
-// SessionCommitPayload is the payload for session_commit jobs.
+// SessionCommitPayload is the payload for session_commit jobs. Commits to the
+// workspace checkout are keyed on the workspace; commits to another branch,
+// which are applied in a worktree, are keyed on that branch.
 type SessionCommitPayload struct {
 	ProjectID   string `json:"projectId"`
 	SessionID   string `json:"sessionId"`
 	WorkspaceID string `json:"workspaceId"`
+	Branch      string `json:"branch,omitempty"`
 }
 
 func (p SessionCommitPayload) JobType() JobType { return JobTypeSessionCommit }
 func (p SessionCommitPayload) ResourceKey() (string, string) {
-	return ResourceTypeWorkspace, p.WorkspaceID
+	return CommitResourceKey(p.WorkspaceID, p.Branch)
 }
 func (p SessionCommitPayload) MaxAttempts() int      { return 1 }
 func (p SessionCommitPayload) AllowDuplicates() bool { return true }
+
+// SessionPullRequestPayload is the payload for session_pull_request jobs,
+// which push a session's commits to a branch and open a pull request. It is
+// keyed like the session's commit so it runs after a pending commit.
+type SessionPullRequestPayload struct {
+	ProjectID   string `json:"projectId"`
+	SessionID   string `json:"sessionId"`
+	WorkspaceID string `json:"workspaceId"`
+	Branch      string `json:"branch,omitempty"`
+}
+
+func (p SessionPullRequestPayload) JobType() JobType { return JobTypeSessionPullRequest }
+func (p SessionPullRequestPayload) ResourceKey() (string, string) {
+	return CommitResourceKey(p.WorkspaceID, p.Branch)
+}
+func (p SessionPullRequestPayload) MaxAttempts() int      { return 3 }
+func (p SessionPullRequestPayload) AllowDuplicates() bool { return true }
+
+// ScheduleRunPayload is the payload for schedule_run jobs, which start the
+// session for one run of a schedule.
+type ScheduleRunPayload struct {
+	ProjectID  string `json:"projectId"`
+	ScheduleID string `json:"scheduleId"`
+	RunID      string `json:"runId"`
+}
+
+func (p ScheduleRunPayload) JobType() JobType { return JobTypeScheduleRun }
+func (p ScheduleRunPayload) ResourceKey() (string, string) {
+	return ResourceTypeSchedule, p.ScheduleID
+}
+func (p ScheduleRunPayload) MaxAttempts() int { return 1 }
+
+// WebhookDeliveryPayload is the payload for webhook_delivery jobs, which send
+// one event to one webhook. Failed deliveries are retried with the queue's
+// backoff.
+type WebhookDeliveryPayload struct {
+	ProjectID  string `json:"projectId"`
+	DeliveryID string `json:"deliveryId"`
+}
+
+func (p WebhookDeliveryPayload) JobType() JobType { return JobTypeWebhookDelivery }
+func (p WebhookDeliveryPayload) ResourceKey() (string, string) {
+	return ResourceTypeWebhookDelivery, p.DeliveryID
+}
+func (p WebhookDeliveryPayload) MaxAttempts() int { return 5 }
+
+// EventRetentionPayload is the payload for event_retention jobs, which prune
+// project events according to the retention settings. A zero limit is not
+// applied.
+type EventRetentionPayload struct {
+	MaxAgeDays    int `json:"maxAgeDays,omitempty"`
+	MaxPerProject int `json:"maxPerProject,omitempty"`
+}
+
+func (p EventRetentionPayload) JobType() JobType { return JobTypeEventRetention }
+func (p EventRetentionPayload) ResourceKey() (string, string) {
+	return ResourceTypeProjectEvents, "retention"
+}
+func (p EventRetentionPayload) MaxAttempts() int { return 1 }
+
+// CommitResourceKey returns the resource a commit job is serialized on: the
+// workspace, or the branch when committing to a branch in a worktree.
+func CommitResourceKey(workspaceID, branch string) (string, string) {
+	if branch == "" {
+		return ResourceTypeWorkspace, workspaceID
+	}
+	return ResourceTypeBranch, BranchResourceID(workspaceID, branch)
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+)
+
+// Admin middleware restricts a route to server administrators: the users
+// listed in cfg.AdminEmails. When auth is disabled the anonymous user is
+// the only user and is treated as an administrator.
+// Must run after Auth.
+func Admin(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cfg.AuthEnabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			email := GetUserEmail(r.Context())
+			for _, admin := range cfg.AdminEmails {
+				if email != "" && strings.EqualFold(email, strings.TrimSpace(admin)) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, `{"error":"Admin access required"}`, http.StatusForbidden)
+		})
+	}
+}
@@ -16,11 +16,13 @@ const (
 	JobStatusRunning   JobStatus = "running"
 	JobStatusCompleted JobStatus = "completed"
 	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
 )
 
 // Job represents a background job in the queue.
 type Job struct {
 	ID          string          `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID   *string         `gorm:"column:project_id;type:text;index" json:"project_id,omitempty"`
 	Type        string          `gorm:"not null;type:text;index:idx_job_status_type" json:"type"`
 	Payload     json.RawMessage `gorm:"type:text;not null" json:"payload"`
 	Status      string          `gorm:"not null;type:text;default:pending;index:idx_job_status_type" json:"status"`
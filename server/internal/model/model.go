@@ -152,9 +152,10 @@ const (
 // When a workspace has no provider set (empty string), the platform default is used
 // at runtime: "vz" on macOS, "docker" on other platforms.
 const (
-	WorkspaceProviderVZ     = "vz"     // Run in Virtualization.framework VMs (macOS only)
-	WorkspaceProviderDocker = "docker" // Run in Docker containers
-	WorkspaceProviderLocal  = "local"  // Run in local directory without isolation
+	WorkspaceProviderVZ         = "vz"         // Run in Virtualization.framework VMs (macOS only)
+	WorkspaceProviderDocker     = "docker"     // Run in Docker containers
+	WorkspaceProviderLocal      = "local"      // Run in local directory without isolation
+	WorkspaceProviderKubernetes = "kubernetes" // Run in Kubernetes pods
 )
 
 // Workspace represents a working directory (local folder or git repo).
@@ -167,6 +168,8 @@ type Workspace struct {
 	Provider     string    `gorm:"type:text;default:''" json:"provider,omitempty"`
 	Status       string    `gorm:"not null;type:text;default:initializing" json:"status"`
 	ErrorMessage *string   `gorm:"column:error_message;type:text" json:"errorMessage,omitempty"`
+	Submodules   bool      `gorm:"column:submodules;default:false" json:"submodules"` // Check out submodules recursively
+	LFS          bool      `gorm:"column:lfs;default:false" json:"lfs"`               // Download Git LFS objects
 	CreatedAt    time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 
@@ -204,6 +207,7 @@ const (
 	CommitStatusPending    = "pending"    // Commit requested, waiting to start
 	CommitStatusCommitting = "committing" // Commit in progress
 	CommitStatusCompleted  = "completed"  // Commit completed successfully
+	CommitStatusConflicted = "conflicted" // Patches conflicted, agent is rebasing onto the new base commit
 	CommitStatusFailed     = "failed"     // Commit failed
 )
 
@@ -221,15 +225,26 @@ type Session struct {
 	CommitError     *string   `gorm:"column:commit_error;type:text" json:"commitError,omitempty"`
 	BaseCommit      *string   `gorm:"column:base_commit;type:text" json:"baseCommit,omitempty"`
 	AppliedCommit   *string   `gorm:"column:applied_commit;type:text" json:"appliedCommit,omitempty"`
+	TargetBranch    *string   `gorm:"column:target_branch;type:text" json:"targetBranch,omitempty"`       // Commit to this branch in a worktree instead of the workspace checkout
+	CommitConflicts *string   `gorm:"column:commit_conflicts;type:text" json:"commitConflicts,omitempty"` // JSON git.ConflictError of the last conflict
+	ConflictRetries int       `gorm:"column:conflict_retries;default:0" json:"conflictRetries"`
 	ErrorMessage    *string   `gorm:"column:error_message;type:text" json:"errorMessage,omitempty"`
 	WorkspacePath   *string   `gorm:"column:workspace_path;type:text" json:"workspacePath,omitempty"`
 	WorkspaceCommit *string   `gorm:"column:workspace_commit;type:text" json:"workspaceCommit,omitempty"`
 	Model           *string   `gorm:"column:model;type:text" json:"model,omitempty"`
 	Reasoning       *string   `gorm:"column:reasoning;type:text" json:"reasoning,omitempty"`
 	Mode            *string   `gorm:"column:mode;type:text" json:"mode,omitempty"`
+	ForkedFrom      *string   `gorm:"column:forked_from;type:text;index" json:"forkedFrom,omitempty"`
+	ForkMessageID   *string   `gorm:"column:fork_message_id;type:text" json:"forkMessageId,omitempty"`
 	CreatedAt       time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 
+	// Pull request opened from the session's commits (git URL workspaces only)
+	PullRequestBranch *string `gorm:"column:pull_request_branch;type:text" json:"pullRequestBranch,omitempty"`
+	PullRequestNumber *int    `gorm:"column:pull_request_number" json:"pullRequestNumber,omitempty"`
+	PullRequestURL    *string `gorm:"column:pull_request_url;type:text" json:"pullRequestUrl,omitempty"`
+	PullRequestError  *string `gorm:"column:pull_request_error;type:text" json:"pullRequestError,omitempty"`
+
 	Project   *Project   `gorm:"foreignKey:ProjectID" json:"-"`
 	Workspace *Workspace `gorm:"foreignKey:WorkspaceID" json:"-"`
 	Agent     *Agent     `gorm:"foreignKey:AgentID" json:"-"`
@@ -249,7 +264,8 @@ func (s *Session) BeforeCreate(_ *gorm.DB) error {
 // Stored in UIMessage format compatible with AI SDK.
 type Message struct {
 	ID        string          `gorm:"primaryKey;type:text" json:"id"`
-	SessionID string          `gorm:"column:session_id;not null;type:text;index" json:"sessionId"`
+	SessionID string          `gorm:"column:session_id;not null;type:text;index;index:idx_messages_session_message" json:"sessionId"`
+	MessageID string          `gorm:"column:message_id;type:text;default:'';index:idx_messages_session_message" json:"messageId"` // UIMessage ID in the sandbox; empty for messages not mirrored from it
 	Role      string          `gorm:"not null;type:text" json:"role"`
 	Parts     json.RawMessage `gorm:"type:text;not null" json:"parts"`
 	CreatedAt time.Time       `gorm:"autoCreateTime" json:"createdAt"`
@@ -303,6 +319,32 @@ func (c *Credential) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
+// GitCredential authenticates clone, fetch and push of a project's git
+// workspaces against one host, with an SSH deploy key or an HTTPS token.
+type GitCredential struct {
+	ID               string    `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID        string    `gorm:"column:project_id;not null;type:text;uniqueIndex:idx_git_credential_host" json:"project_id"`
+	Host             string    `gorm:"not null;type:text;uniqueIndex:idx_git_credential_host" json:"host"`
+	AuthType         string    `gorm:"column:auth_type;not null;type:text;uniqueIndex:idx_git_credential_host" json:"auth_type"`
+	Username         string    `gorm:"type:text" json:"username"`
+	EncryptedData    []byte    `gorm:"column:encrypted_data" json:"-"`
+	Fingerprint      string    `gorm:"type:text" json:"fingerprint"`
+	ForwardToSandbox bool      `gorm:"column:forward_to_sandbox;default:false" json:"forward_to_sandbox"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Project *Project `gorm:"foreignKey:ProjectID" json:"-"`
+}
+
+func (GitCredential) TableName() string { return "git_credentials" }
+
+func (c *GitCredential) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // TerminalHistory represents a terminal command/output entry.
 type TerminalHistory struct {
 	ID        string    `gorm:"primaryKey;type:text" json:"id"`
@@ -385,10 +427,17 @@ func AllModels() []interface{} {
 		&Session{},
 		&Message{},
 		&Credential{},
+		&GitCredential{},
 		&TerminalHistory{},
 		&ProjectEvent{},
 		&Job{},
 		&DispatcherLeader{},
 		&UserPreference{},
+		&Schedule{},
+		&ScheduleRun{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&ReviewThread{},
+		&ReviewComment{},
 	}
 }
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Review thread sides: which version of the file the anchored lines belong to
+const (
+	ReviewSideNew = "new" // Lines of the changed file (context and additions)
+	ReviewSideOld = "old" // Lines of the original file (context and deletions)
+)
+
+// ReviewThread is a discussion anchored to a line range of a session's diff.
+type ReviewThread struct {
+	ID        string `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID string `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	SessionID string `gorm:"column:session_id;not null;type:text;index" json:"sessionId"`
+	Path      string `gorm:"not null;type:text" json:"path"`
+	Side      string `gorm:"not null;type:text;default:new" json:"side"`
+	StartLine int    `gorm:"column:start_line;not null" json:"startLine"`
+	EndLine   int    `gorm:"column:end_line;not null" json:"endLine"`
+	// Revision identifies the file's diff the thread was anchored to
+	Revision string `gorm:"not null;type:text" json:"revision"`
+	// AnchorText holds the anchored lines as they were in that diff
+	AnchorText string    `gorm:"column:anchor_text;type:text" json:"-"`
+	Outdated   bool      `gorm:"not null;default:false" json:"outdated"`
+	Resolved   bool      `gorm:"not null;default:false" json:"resolved"`
+	CreatedBy  string    `gorm:"column:created_by;type:text" json:"createdBy,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	Session *Session `gorm:"foreignKey:SessionID" json:"-"`
+}
+
+func (ReviewThread) TableName() string { return "review_threads" }
+
+func (t *ReviewThread) BeforeCreate(_ *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	if t.Side == "" {
+		t.Side = ReviewSideNew
+	}
+	return nil
+}
+
+// ReviewComment is a comment in a review thread. Comments are pending until
+// they are sent to the agent as part of a review.
+type ReviewComment struct {
+	ID        string     `gorm:"primaryKey;type:text" json:"id"`
+	ThreadID  string     `gorm:"column:thread_id;not null;type:text;index" json:"threadId"`
+	SessionID string     `gorm:"column:session_id;not null;type:text;index" json:"sessionId"`
+	ProjectID string     `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	UserID    string     `gorm:"column:user_id;type:text" json:"userId,omitempty"`
+	Body      string     `gorm:"not null;type:text" json:"body"`
+	SentAt    *time.Time `gorm:"column:sent_at" json:"sentAt,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (ReviewComment) TableName() string { return "review_comments" }
+
+func (c *ReviewComment) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
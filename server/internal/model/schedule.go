@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Schedule run status constants
+const (
+	ScheduleRunStatusPending   = "pending"   // Job enqueued, session not created yet
+	ScheduleRunStatusRunning   = "running"   // Prompt sent, agent is working
+	ScheduleRunStatusCompleted = "completed" // Agent finished
+	ScheduleRunStatusFailed    = "failed"    // Session or prompt failed
+	ScheduleRunStatusSkipped   = "skipped"   // Not started because the previous run was still active
+)
+
+// Schedule starts a new session with a prompt on a cron schedule.
+type Schedule struct {
+	ID             string     `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID      string     `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	Name           string     `gorm:"not null;type:text" json:"name"`
+	Cron           string     `gorm:"not null;type:text" json:"cron"`
+	Timezone       string     `gorm:"type:text;default:''" json:"timezone,omitempty"`
+	WorkspaceID    string     `gorm:"column:workspace_id;not null;type:text;index" json:"workspaceId"`
+	AgentID        string     `gorm:"column:agent_id;not null;type:text" json:"agentId"`
+	Model          *string    `gorm:"column:model;type:text" json:"model,omitempty"`
+	Mode           *string    `gorm:"column:mode;type:text" json:"mode,omitempty"`
+	PromptTemplate string     `gorm:"column:prompt_template;not null;type:text" json:"promptTemplate"`
+	AutoCommit     bool       `gorm:"column:auto_commit;default:false" json:"autoCommit"`
+	Enabled        bool       `gorm:"not null;default:false" json:"enabled"`
+	NextRunAt      *time.Time `gorm:"column:next_run_at;index" json:"nextRunAt,omitempty"`
+	LastRunAt      *time.Time `gorm:"column:last_run_at" json:"lastRunAt,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	Project   *Project   `gorm:"foreignKey:ProjectID" json:"-"`
+	Workspace *Workspace `gorm:"foreignKey:WorkspaceID" json:"-"`
+}
+
+func (Schedule) TableName() string { return "schedules" }
+
+func (s *Schedule) BeforeCreate(_ *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ScheduleRun records one activation of a schedule.
+type ScheduleRun struct {
+	ID           string     `gorm:"primaryKey;type:text" json:"id"`
+	ScheduleID   string     `gorm:"column:schedule_id;not null;type:text;index" json:"scheduleId"`
+	ProjectID    string     `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	SessionID    *string    `gorm:"column:session_id;type:text;index" json:"sessionId,omitempty"`
+	Status       string     `gorm:"not null;type:text;default:pending;index" json:"status"`
+	Error        *string    `gorm:"type:text" json:"error,omitempty"`
+	ScheduledFor time.Time  `gorm:"column:scheduled_for;not null" json:"scheduledFor"`
+	StartedAt    *time.Time `gorm:"column:started_at" json:"startedAt,omitempty"`
+	CompletedAt  *time.Time `gorm:"column:completed_at" json:"completedAt,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (ScheduleRun) TableName() string { return "schedule_runs" }
+
+func (r *ScheduleRun) BeforeCreate(_ *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.Status == "" {
+		r.Status = ScheduleRunStatusPending
+	}
+	return nil
+}
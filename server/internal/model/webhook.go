@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery status constants
+const (
+	WebhookDeliveryStatusPending   = "pending"   // Queued or waiting for a retry
+	WebhookDeliveryStatusSucceeded = "succeeded" // Receiver answered with a 2xx status
+	WebhookDeliveryStatusFailed    = "failed"    // All attempts failed
+)
+
+// Webhook is a project subscription that receives project events over HTTP.
+type Webhook struct {
+	ID              string    `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID       string    `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	URL             string    `gorm:"column:url;not null;type:text" json:"url"`
+	EncryptedSecret []byte    `gorm:"column:encrypted_secret" json:"-"`
+	EventTypes      string    `gorm:"column:event_types;type:text;default:''" json:"eventTypes"` // Comma-separated; empty means all
+	Enabled         bool      `gorm:"not null;default:false" json:"enabled"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	Project *Project `gorm:"foreignKey:ProjectID" json:"-"`
+}
+
+func (Webhook) TableName() string { return "webhooks" }
+
+func (w *Webhook) BeforeCreate(_ *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// WebhookDelivery records the delivery of one event to a webhook.
+type WebhookDelivery struct {
+	ID             string          `gorm:"primaryKey;type:text" json:"id"`
+	WebhookID      string          `gorm:"column:webhook_id;not null;type:text;index" json:"webhookId"`
+	ProjectID      string          `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	EventID        string          `gorm:"column:event_id;not null;type:text" json:"eventId"`
+	EventType      string          `gorm:"column:event_type;not null;type:text" json:"eventType"`
+	Payload        json.RawMessage `gorm:"type:text;not null" json:"payload"` // Request body, kept for redelivery
+	Status         string          `gorm:"not null;type:text;default:pending" json:"status"`
+	Attempts       int             `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus *int            `gorm:"column:response_status" json:"responseStatus,omitempty"`
+	ResponseBody   *string         `gorm:"column:response_body;type:text" json:"responseBody,omitempty"`
+	Error          *string         `gorm:"type:text" json:"error,omitempty"`
+	DurationMs     *int64          `gorm:"column:duration_ms" json:"durationMs,omitempty"`
+	RedeliveryOf   *string         `gorm:"column:redelivery_of;type:text" json:"redeliveryOf,omitempty"`
+	DeliveredAt    *time.Time      `gorm:"column:delivered_at" json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime;index" json:"createdAt"`
+	UpdatedAt      time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (WebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+func (d *WebhookDelivery) BeforeCreate(_ *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.Status == "" {
+		d.Status = WebhookDeliveryStatusPending
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package pgnotify wakes the job dispatcher and event pollers on every server
+// node through Postgres LISTEN/NOTIFY.
+//
+// Notifications only reduce latency. Pollers keep polling on their interval,
+// so a lost notification or a dropped listener connection delays work until
+// the next poll but never loses it.
+package pgnotify
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Notification channels
+const (
+	// ChannelJobs is notified when a job is enqueued.
+	ChannelJobs = "discobot_jobs"
+	// ChannelEvents is notified when a project event is persisted.
+	ChannelEvents = "discobot_events"
+)
+
+const (
+	// notifyTimeout bounds a single NOTIFY so a slow database can't stall
+	// the enqueuer or publisher.
+	notifyTimeout = 5 * time.Second
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// conn is the subset of *pgx.Conn used by the listener.
+type conn interface {
+	Exec(ctx context.Context, sql string) error
+	WaitForNotification(ctx context.Context) (channel string, err error)
+	Close(ctx context.Context) error
+}
+
+// Listener holds a dedicated Postgres connection that listens on the
+// registered channels and calls their handlers when notified.
+type Listener struct {
+	connect  func(ctx context.Context) (conn, error)
+	handlers map[string][]func()
+
+	// Lifecycle management
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewListener creates a listener for the Postgres database at dsn.
+func NewListener(dsn string) *Listener {
+	return &Listener{
+		connect: func(ctx context.Context) (conn, error) {
+			c, err := pgx.Connect(ctx, dsn)
+			if err != nil {
+				return nil, err
+			}
+			return pgxConn{c}, nil
+		},
+		handlers: make(map[string][]func()),
+	}
+}
+
+// Handle registers fn to be called when channel is notified.
+// Must be called before Start.
+func (l *Listener) Handle(channel string, fn func()) {
+	l.handlers[channel] = append(l.handlers[channel], fn)
+}
+
+// Start connects and begins listening in the background. Connection errors
+// are logged and retried with backoff.
+func (l *Listener) Start(parentCtx context.Context) {
+	l.ctx, l.cancel = context.WithCancel(parentCtx)
+
+	l.wg.Add(1)
+	go l.run()
+}
+
+// Stop closes the listener connection.
+func (l *Listener) Stop() {
+	l.cancel()
+	l.wg.Wait()
+}
+
+// run keeps a listening connection open until the listener is stopped.
+func (l *Listener) run() {
+	defer l.wg.Done()
+
+	delay := minReconnectDelay
+	for {
+		connected, err := l.listen()
+		if connected {
+			delay = minReconnectDelay
+		}
+		if err != nil && l.ctx.Err() == nil {
+			log.Printf("Postgres notification listener: %v (reconnecting in %s)", err, delay)
+		}
+
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen opens a connection, subscribes to all channels and dispatches
+// notifications until the connection fails or the listener is stopped.
+// connected reports whether it got as far as listening.
+func (l *Listener) listen() (connected bool, err error) {
+	c, err := l.connect(l.ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		_ = c.Close(closeCtx)
+	}()
+
+	for channel := range l.handlers {
+		if err := c.Exec(l.ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return false, err
+		}
+	}
+	log.Printf("Postgres notification listener connected (%d channels)", len(l.handlers))
+
+	// Notifications sent while disconnected are lost; wake every handler so
+	// its poller catches up now rather than on its next tick.
+	for channel := range l.handlers {
+		l.dispatch(channel)
+	}
+
+	for {
+		channel, err := c.WaitForNotification(l.ctx)
+		if err != nil {
+			return true, err
+		}
+		l.dispatch(channel)
+	}
+}
+
+func (l *Listener) dispatch(channel string) {
+	for _, fn := range l.handlers[channel] {
+		fn()
+	}
+}
+
+// Notifier returns a function that notifies channel through the store's
+// database. Errors are logged; pollers pick up the change on their next tick.
+func Notifier(s *store.Store, channel string) func() {
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := s.Notify(ctx, channel); err != nil {
+			log.Printf("Failed to send %s notification: %v", channel, err)
+		}
+	}
+}
+
+// pgxConn adapts *pgx.Conn to conn.
+type pgxConn struct {
+	*pgx.Conn
+}
+
+func (c pgxConn) Exec(ctx context.Context, sql string) error {
+	_, err := c.Conn.Exec(ctx, sql)
+	return err
+}
+
+func (c pgxConn) WaitForNotification(ctx context.Context) (string, error) {
+	n, err := c.Conn.WaitForNotification(ctx)
+	if err != nil {
+		return "", err
+	}
+	return n.Channel, nil
+}
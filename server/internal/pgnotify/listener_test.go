@@ -0,0 +1,143 @@
+package pgnotify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn delivers notifications from a channel. Closing the channel
+// simulates a dropped connection.
+type fakeConn struct {
+	notifications chan string
+
+	mu     sync.Mutex
+	listen []string
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listen = append(c.listen, sql)
+	return nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case ch, ok := <-c.notifications:
+		if !ok {
+			return "", errors.New("connection lost")
+		}
+		return ch, nil
+	}
+}
+
+func (c *fakeConn) Close(context.Context) error { return nil }
+
+// counter counts handler calls and signals each one.
+type counter struct {
+	mu    sync.Mutex
+	count int
+	ch    chan struct{}
+}
+
+func newCounter() *counter {
+	return &counter{ch: make(chan struct{}, 100)}
+}
+
+func (c *counter) inc() {
+	c.mu.Lock()
+	c.count++
+	c.mu.Unlock()
+	c.ch <- struct{}{}
+}
+
+func (c *counter) wait(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		select {
+		case <-c.ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for handler")
+		}
+	}
+}
+
+func (c *counter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+func TestListener_DispatchesByChannel(t *testing.T) {
+	fake := &fakeConn{notifications: make(chan string)}
+	l := &Listener{
+		connect:  func(context.Context) (conn, error) { return fake, nil },
+		handlers: make(map[string][]func()),
+	}
+	jobsCalled, eventsCalled := newCounter(), newCounter()
+	l.Handle(ChannelJobs, jobsCalled.inc)
+	l.Handle(ChannelEvents, eventsCalled.inc)
+
+	l.Start(context.Background())
+	defer l.Stop()
+
+	// Every handler runs once on connect to catch up
+	jobsCalled.wait(t, 1)
+	eventsCalled.wait(t, 1)
+
+	fake.notifications <- ChannelJobs
+	fake.notifications <- ChannelJobs
+	jobsCalled.wait(t, 2)
+
+	// The next notification is only received after the previous ones were
+	// dispatched, so this also flushes them
+	fake.notifications <- "unknown"
+	if got := eventsCalled.get(); got != 1 {
+		t.Errorf("events handler called %d times, want 1", got)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.listen) != 2 {
+		t.Errorf("expected 2 LISTEN statements, got %v", fake.listen)
+	}
+}
+
+func TestListener_Reconnects(t *testing.T) {
+	var mu sync.Mutex
+	var conns []*fakeConn
+	l := &Listener{
+		connect: func(context.Context) (conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c := &fakeConn{notifications: make(chan string)}
+			conns = append(conns, c)
+			return c, nil
+		},
+		handlers: make(map[string][]func()),
+	}
+	called := newCounter()
+	l.Handle(ChannelEvents, called.inc)
+
+	l.Start(context.Background())
+	defer l.Stop()
+	called.wait(t, 1)
+
+	// Drop the connection: the listener reconnects and wakes the handler
+	// again since notifications may have been missed
+	mu.Lock()
+	close(conns[0].notifications)
+	mu.Unlock()
+	called.wait(t, 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(conns) != 2 {
+		t.Errorf("expected 2 connections, got %d", len(conns))
+	}
+}
@@ -321,6 +321,20 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 	if opts.WorkspaceCommit != "" {
 		env = append(env, fmt.Sprintf("WORKSPACE_COMMIT=%s", opts.WorkspaceCommit))
 	}
+	if opts.WorkspaceSubmodules {
+		env = append(env, "WORKSPACE_SUBMODULES=1")
+	}
+	if opts.WorkspaceLFS {
+		env = append(env, "WORKSPACE_LFS=1")
+	}
+
+	// Tell the agent to adopt the state of the session it was forked from
+	if opts.ForkedFrom != "" {
+		env = append(env, fmt.Sprintf("FORKED_FROM_SESSION_ID=%s", opts.ForkedFrom))
+	}
+	if opts.ForkMessageID != "" {
+		env = append(env, fmt.Sprintf("FORK_MESSAGE_ID=%s", opts.ForkMessageID))
+	}
 
 	// Container configuration
 	containerConfig := &containerTypes.Config{
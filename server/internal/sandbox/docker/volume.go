@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	containerTypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	volumeTypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// cloneContainerPrefix is the prefix for the short-lived containers that copy volumes.
+const cloneContainerPrefix = "discobot-clone-"
+
+// CloneVolume copies the data volume of sourceSessionID into a new data volume
+// for targetSessionID. Implements sandbox.VolumeCloner.
+//
+// The copy runs in a short-lived container using the sandbox image, so it works
+// the same for the local daemon and for Docker inside a project VM. The source
+// container may keep running; files written during the copy may or may not be
+// included.
+func (p *Provider) CloneVolume(ctx context.Context, sourceSessionID, targetSessionID string) error {
+	srcVolName := volumeName(sourceSessionID)
+	dstVolName := volumeName(targetSessionID)
+
+	if _, err := p.client.VolumeInspect(ctx, srcVolName); err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return fmt.Errorf("%w: data volume %s does not exist", sandbox.ErrNotFound, srcVolName)
+		}
+		return fmt.Errorf("failed to inspect data volume %s: %w", srcVolName, err)
+	}
+	if _, err := p.client.VolumeInspect(ctx, dstVolName); err == nil {
+		return fmt.Errorf("%w: data volume %s already exists", sandbox.ErrAlreadyExists, dstVolName)
+	}
+
+	if err := p.EnsureImage(ctx); err != nil {
+		return fmt.Errorf("%w: %v", sandbox.ErrInvalidImage, err)
+	}
+
+	// Same labels as the volume created by Create, so removal works unchanged
+	if _, err := p.client.VolumeCreate(ctx, volumeTypes.CreateOptions{
+		Name: dstVolName,
+		Labels: map[string]string{
+			"discobot.session.id": targetSessionID,
+			"discobot.managed":    "true",
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to create data volume: %w", err)
+	}
+
+	if err := p.copyVolume(ctx, srcVolName, dstVolName, cloneContainerPrefix+targetSessionID); err != nil {
+		// Don't leave a partial copy behind for Create to pick up
+		if rmErr := p.client.VolumeRemove(context.WithoutCancel(ctx), dstVolName, true); rmErr != nil && !cerrdefs.IsNotFound(rmErr) {
+			log.Printf("Failed to remove partially cloned volume %s: %v", dstVolName, rmErr)
+		}
+		return err
+	}
+
+	log.Printf("Cloned data volume %s to %s", srcVolName, dstVolName)
+	return nil
+}
+
+// copyVolume copies the contents of one volume into another, preserving
+// ownership and permissions, using a container named name.
+func (p *Provider) copyVolume(ctx context.Context, srcVolName, dstVolName, name string) error {
+	// Clean up a container left over from an interrupted copy
+	if err := p.client.ContainerRemove(ctx, name, containerTypes.RemoveOptions{Force: true}); err != nil && !cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to remove stale clone container: %w", err)
+	}
+
+	resp, err := p.client.ContainerCreate(ctx,
+		&containerTypes.Config{
+			Image:      p.cfg.SandboxImage,
+			Entrypoint: []string{"cp"},
+			Cmd:        []string{"-a", "/from/.", "/to/"},
+		},
+		&containerTypes.HostConfig{
+			Mounts: []mount.Mount{
+				{Type: mount.TypeVolume, Source: srcVolName, Target: "/from", ReadOnly: true},
+				{Type: mount.TypeVolume, Source: dstVolName, Target: "/to"},
+			},
+		},
+		nil, nil, name)
+	if err != nil {
+		return fmt.Errorf("failed to create clone container: %w", err)
+	}
+	defer func() {
+		if err := p.client.ContainerRemove(context.WithoutCancel(ctx), resp.ID, containerTypes.RemoveOptions{Force: true}); err != nil {
+			log.Printf("Failed to remove clone container %s: %v", name, err)
+		}
+	}()
+
+	// Register the wait before starting so a fast exit is not missed
+	statusCh, errCh := p.client.ContainerWait(ctx, resp.ID, containerTypes.WaitConditionNextExit)
+
+	if err := p.client.ContainerStart(ctx, resp.ID, containerTypes.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start clone container: %w", err)
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed waiting for volume copy: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("volume copy failed with exit code %d: %s", status.StatusCode, p.containerStderr(ctx, resp.ID))
+		}
+	}
+
+	return nil
+}
+
+// containerStderr returns the trimmed stderr of a stopped container, for error messages.
+func (p *Provider) containerStderr(ctx context.Context, containerID string) string {
+	logs, err := p.client.ContainerLogs(ctx, containerID, containerTypes.LogsOptions{ShowStderr: true})
+	if err != nil {
+		return ""
+	}
+	defer logs.Close()
+
+	var stderr strings.Builder
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, io.LimitReader(logs, 64*1024)); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(stderr.String())
+}
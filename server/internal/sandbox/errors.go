@@ -33,4 +33,7 @@ var (
 
 	// ErrResourceLimit indicates a resource limit was exceeded.
 	ErrResourceLimit = errors.New("resource limit exceeded")
+
+	// ErrNotSupported indicates the provider does not support the operation.
+	ErrNotSupported = errors.New("operation not supported by sandbox provider")
 )
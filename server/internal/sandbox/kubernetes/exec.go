@@ -0,0 +1,402 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// ExecutorFactory creates a remotecommand.Executor for a pod exec subresource URL.
+type ExecutorFactory func(method string, u *url.URL) (remotecommand.Executor, error)
+
+// newDefaultExecutorFactory returns a factory that prefers the WebSocket exec
+// protocol and falls back to SPDY for API servers that don't support it.
+func newDefaultExecutorFactory(restConfig *rest.Config) ExecutorFactory {
+	return func(method string, u *url.URL) (remotecommand.Executor, error) {
+		if restConfig == nil {
+			return nil, fmt.Errorf("no REST config available for exec")
+		}
+
+		spdyExec, err := remotecommand.NewSPDYExecutor(restConfig, method, u)
+		if err != nil {
+			return nil, err
+		}
+		wsExec, err := remotecommand.NewWebSocketExecutor(restConfig, "GET", u.String())
+		if err != nil {
+			return nil, err
+		}
+		return remotecommand.NewFallbackExecutor(wsExec, spdyExec, func(err error) bool {
+			return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+		})
+	}
+}
+
+// execURL builds the URL of the pod exec subresource for a session.
+func (p *Provider) execURL(sessionID string, opts *corev1.PodExecOptions) (*url.URL, error) {
+	base := &url.URL{Scheme: "https", Host: "localhost"}
+	if p.restConfig != nil {
+		serverURL, _, err := rest.DefaultServerUrlFor(p.restConfig)
+		if err != nil {
+			return nil, err
+		}
+		base = serverURL
+	}
+
+	params, err := scheme.ParameterCodec.EncodeParameters(opts, corev1.SchemeGroupVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *base
+	u.Path = path.Join(base.Path, "/api/v1/namespaces", p.namespace, "pods", podName(sessionID), "exec")
+	u.RawQuery = params.Encode()
+	return &u, nil
+}
+
+// newExecutor creates an executor for running cmd in the session's sandbox container.
+func (p *Provider) newExecutor(sessionID string, cmd []string, stdin, stderr, tty bool) (remotecommand.Executor, error) {
+	u, err := p.execURL(sessionID, &corev1.PodExecOptions{
+		Container: sandboxContainer,
+		Command:   cmd,
+		Stdin:     stdin,
+		Stdout:    true,
+		Stderr:    stderr,
+		TTY:       tty,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.executorFactory("POST", u)
+}
+
+// checkRunning returns nil if the session's pod is running, sandbox.ErrNotFound
+// if the sandbox doesn't exist, and sandbox.ErrNotRunning otherwise.
+func (p *Provider) checkRunning(ctx context.Context, sessionID string) error {
+	pod, err := p.clientset.CoreV1().Pods(p.namespace).Get(ctx, podName(sessionID), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get sandbox pod: %w", err)
+		}
+		if _, err := p.getSecret(ctx, sessionID); err != nil {
+			return err
+		}
+		return sandbox.ErrNotRunning
+	}
+
+	if status, _ := podStatus(pod); status != sandbox.StatusRunning {
+		return sandbox.ErrNotRunning
+	}
+	return nil
+}
+
+// wrapCommand adapts a command to the options the exec subresource doesn't
+// support natively: the user is switched with runuser, and the working
+// directory and environment are applied with env.
+func wrapCommand(cmd []string, workDir string, env map[string]string, user string) []string {
+	var wrapped []string
+	if user != "" {
+		wrapped = append(wrapped, "runuser", "-u", user, "--")
+	}
+
+	if workDir != "" || len(env) > 0 {
+		wrapped = append(wrapped, "env")
+		if workDir != "" {
+			wrapped = append(wrapped, "-C", workDir)
+		}
+
+		// Sort for deterministic command lines
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			wrapped = append(wrapped, fmt.Sprintf("%s=%s", k, env[k]))
+		}
+	}
+
+	return append(wrapped, cmd...)
+}
+
+// exitCodeFromError extracts the remote command's exit code from a stream error.
+// A non-zero exit is reported as an exit code, not an error.
+func exitCodeFromError(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return exitErr.ExitStatus(), nil
+	}
+	return -1, err
+}
+
+// Exec runs a non-interactive command in the sandbox.
+func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
+	if err := p.checkRunning(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	executor, err := p.newExecutor(sessionID, wrapCommand(cmd, opts.WorkDir, opts.Env, opts.User), opts.Stdin != nil, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	streamErr := executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+
+	exitCode, err := exitCodeFromError(streamErr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
+	}
+
+	return &sandbox.ExecResult{
+		ExitCode: exitCode,
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+	}, nil
+}
+
+// detectShell determines the best available shell in the sandbox.
+// It tries shells in this order: $SHELL → /bin/bash → /bin/sh
+func (p *Provider) detectShell(ctx context.Context, sessionID string) []string {
+	// Create a quick timeout context for shell detection
+	detectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	script := `if [ -n "$SHELL" ] && [ -x "$SHELL" ]; then echo "$SHELL"; elif [ -x /bin/bash ]; then echo /bin/bash; else echo /bin/sh; fi`
+	result, err := p.Exec(detectCtx, sessionID, []string{"sh", "-c", script}, sandbox.ExecOptions{})
+	if err == nil && result.ExitCode == 0 {
+		if shell := strings.TrimSpace(string(result.Stdout)); shell != "" {
+			return []string{shell}
+		}
+	}
+
+	// Fall back to /bin/sh (should always exist)
+	return []string{"/bin/sh"}
+}
+
+// Attach creates an interactive PTY session to the sandbox.
+func (p *Provider) Attach(ctx context.Context, sessionID string, opts sandbox.AttachOptions) (sandbox.PTY, error) {
+	if err := p.checkRunning(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	// Determine shell to use
+	cmd := opts.Cmd
+	if len(cmd) == 0 {
+		cmd = p.detectShell(ctx, sessionID)
+	}
+
+	executor, err := p.newExecutor(sessionID, wrapCommand(cmd, "", opts.Env, opts.User), true, false, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrAttachFailed, err)
+	}
+
+	session := startExecSession(ctx, executor, true)
+
+	// Resize PTY if dimensions provided
+	if opts.Rows > 0 && opts.Cols > 0 {
+		_ = session.Resize(ctx, opts.Rows, opts.Cols)
+	}
+
+	return session, nil
+}
+
+// ExecStream runs a command with bidirectional streaming I/O (no TTY).
+func (p *Provider) ExecStream(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error) {
+	if err := p.checkRunning(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	// In TTY mode stdout and stderr are merged into a single stream
+	executor, err := p.newExecutor(sessionID, wrapCommand(cmd, opts.WorkDir, opts.Env, opts.User), true, !opts.TTY, opts.TTY)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	return startExecSession(ctx, executor, opts.TTY), nil
+}
+
+// execSession implements sandbox.PTY and sandbox.Stream on top of a
+// remotecommand.Executor running in the background.
+type execSession struct {
+	stdinWriter  *io.PipeWriter
+	stdoutReader *io.PipeReader
+	stderrReader *io.PipeReader
+	sizes        *sizeQueue
+	tty          bool
+	cancel       context.CancelFunc
+
+	done     chan struct{}
+	exitCode int
+	err      error
+
+	closeOnce sync.Once
+}
+
+// startExecSession starts streaming the executor in the background.
+// The session outlives ctx; it ends when the command exits or Close is called.
+func startExecSession(ctx context.Context, executor remotecommand.Executor, tty bool) *execSession {
+	streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	s := &execSession{
+		stdinWriter:  stdinWriter,
+		stdoutReader: stdoutReader,
+		tty:          tty,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+
+	streamOpts := remotecommand.StreamOptions{
+		Stdin:  stdinReader,
+		Stdout: stdoutWriter,
+		Tty:    tty,
+	}
+
+	var stderrWriter *io.PipeWriter
+	if tty {
+		s.sizes = newSizeQueue()
+		streamOpts.TerminalSizeQueue = s.sizes
+	} else {
+		s.stderrReader, stderrWriter = io.Pipe()
+		streamOpts.Stderr = stderrWriter
+	}
+
+	go func() {
+		defer close(s.done)
+		s.exitCode, s.err = exitCodeFromError(executor.StreamWithContext(streamCtx, streamOpts))
+
+		_ = stdoutWriter.Close()
+		if stderrWriter != nil {
+			_ = stderrWriter.Close()
+		}
+		_ = stdinReader.Close()
+		if s.sizes != nil {
+			s.sizes.close()
+		}
+	}()
+
+	return s
+}
+
+func (s *execSession) Read(b []byte) (int, error) {
+	return s.stdoutReader.Read(b)
+}
+
+func (s *execSession) Stderr() io.Reader {
+	if s.tty {
+		return nil
+	}
+	return s.stderrReader
+}
+
+func (s *execSession) Write(b []byte) (int, error) {
+	return s.stdinWriter.Write(b)
+}
+
+func (s *execSession) Resize(_ context.Context, rows, cols int) error {
+	if !s.tty {
+		return nil
+	}
+	s.sizes.push(rows, cols)
+	return nil
+}
+
+func (s *execSession) CloseWrite() error {
+	return s.stdinWriter.Close()
+}
+
+func (s *execSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		_ = s.stdinWriter.Close()
+		_ = s.stdoutReader.Close()
+		if s.stderrReader != nil {
+			_ = s.stderrReader.Close()
+		}
+	})
+	return nil
+}
+
+func (s *execSession) Wait(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case <-s.done:
+	}
+	return s.exitCode, s.err
+}
+
+// sizeQueue implements remotecommand.TerminalSizeQueue, keeping only the most
+// recent size so a slow consumer never blocks Resize.
+type sizeQueue struct {
+	ch        chan remotecommand.TerminalSize
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSizeQueue() *sizeQueue {
+	return &sizeQueue{
+		ch:   make(chan remotecommand.TerminalSize, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// Next blocks until a new size is available, returning nil once the queue is closed.
+func (q *sizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.ch:
+		return &size
+	case <-q.done:
+		return nil
+	}
+}
+
+func (q *sizeQueue) push(rows, cols int) {
+	size := remotecommand.TerminalSize{Width: uint16(cols), Height: uint16(rows)}
+	for {
+		select {
+		case <-q.done:
+			return
+		case q.ch <- size:
+			return
+		default:
+			// Drop the stale pending size and retry
+			select {
+			case <-q.ch:
+			default:
+			}
+		}
+	}
+}
+
+func (q *sizeQueue) close() {
+	q.closeOnce.Do(func() { close(q.done) })
+}
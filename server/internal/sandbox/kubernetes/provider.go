@@ -0,0 +1,1201 @@
+// Package kubernetes provides a Kubernetes-based implementation of the sandbox.Provider interface.
+//
+// Each session is backed by three namespaced resources, all labelled with
+// discobot.managed=true, discobot.session.id and discobot.project.id:
+//
+//   - a Secret (discobot-session-<id>) holding the raw shared secret and the
+//     options the sandbox was created with,
+//   - a PersistentVolumeClaim (discobot-data-<id>) mounted at /.data, and
+//   - a Pod (discobot-session-<id>) that only exists while the sandbox is started.
+//
+// Pods cannot be paused, so Stop deletes the pod and Start recreates it from
+// the stored options. The Secret is the source of truth for whether a sandbox
+// exists at all.
+package kubernetes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+const (
+	// Label keys applied to every managed resource.
+	labelManaged   = "discobot.managed"
+	labelSessionID = "discobot.session.id"
+	labelProjectID = "discobot.project.id"
+
+	// Annotation keys on the session Secret recording lifecycle timestamps.
+	annotationStartedAt = "discobot.started-at"
+	annotationStoppedAt = "discobot.stopped-at"
+
+	// Keys within the session Secret's data.
+	secretKeySecret  = "secret"
+	secretKeyOptions = "options"
+
+	// sandboxContainer is the name of the sandbox container within the pod.
+	sandboxContainer = "sandbox"
+
+	// containerPort is the fixed port exposed by all sandboxes.
+	containerPort = 3002
+
+	// workspacePath is where workspaces are mounted inside the container.
+	workspacePath = "/.workspace"
+
+	// dataVolumePath is where the persistent data volume is mounted inside the container.
+	dataVolumePath = "/.data"
+
+	// dataVolumePrefix is the prefix for data volume claim names.
+	dataVolumePrefix = "discobot-data-"
+)
+
+// SessionProjectResolver looks up the project ID for a session from the database.
+type SessionProjectResolver func(ctx context.Context, sessionID string) (projectID string, err error)
+
+// Provider implements the sandbox.Provider interface using Kubernetes pods.
+type Provider struct {
+	clientset  k8s.Interface
+	restConfig *rest.Config
+	cfg        *config.Config
+	namespace  string
+
+	// sessionProjectResolver looks up session -> project mapping from the database.
+	sessionProjectResolver SessionProjectResolver
+
+	// executorFactory builds remote command executors for the pod exec subresource.
+	executorFactory ExecutorFactory
+
+	// dialer connects to the agent API inside a pod.
+	dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// pollInterval controls how often Start and Stop check pod state.
+	pollInterval time.Duration
+}
+
+// Option configures the Kubernetes provider.
+type Option func(*Provider)
+
+// WithClientset configures the provider to use an existing clientset instead of
+// building one from the kubeconfig. This is primarily used for testing with a
+// fake clientset.
+func WithClientset(clientset k8s.Interface) Option {
+	return func(p *Provider) {
+		p.clientset = clientset
+	}
+}
+
+// WithRESTConfig configures the REST config used for exec requests.
+func WithRESTConfig(restConfig *rest.Config) Option {
+	return func(p *Provider) {
+		p.restConfig = restConfig
+	}
+}
+
+// WithExecutorFactory overrides how exec requests are sent to the API server.
+func WithExecutorFactory(factory ExecutorFactory) Option {
+	return func(p *Provider) {
+		p.executorFactory = factory
+	}
+}
+
+// WithDialer overrides how the provider connects to the agent API inside a pod.
+// By default it dials the pod IP directly, which requires the server to run
+// inside the cluster (or otherwise have a route to the pod network).
+func WithDialer(dialer func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(p *Provider) {
+		p.dialer = dialer
+	}
+}
+
+// WithPollInterval overrides how often Start and Stop poll for pod state changes.
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *Provider) {
+		p.pollInterval = interval
+	}
+}
+
+// NewProvider creates a new Kubernetes sandbox provider.
+// The sessionProjectResolver is required for labelling resources by project.
+// Without WithClientset, the client is built from cfg.KubernetesKubeconfig, or
+// the in-cluster config if no kubeconfig is set.
+func NewProvider(cfg *config.Config, sessionProjectResolver SessionProjectResolver, opts ...Option) (*Provider, error) {
+	if sessionProjectResolver == nil {
+		return nil, fmt.Errorf("sessionProjectResolver is required")
+	}
+
+	p := &Provider{
+		cfg:                    cfg,
+		namespace:              cfg.KubernetesNamespace,
+		sessionProjectResolver: sessionProjectResolver,
+		pollInterval:           time.Second,
+	}
+	if p.namespace == "" {
+		p.namespace = config.DefaultKubernetesNamespace
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.clientset == nil {
+		if p.restConfig == nil {
+			restConfig, err := loadRESTConfig(cfg.KubernetesKubeconfig)
+			if err != nil {
+				return nil, err
+			}
+			p.restConfig = restConfig
+		}
+
+		clientset, err := k8s.NewForConfig(p.restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+		p.clientset = clientset
+
+		// Verify connection
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := clientset.CoreV1().Namespaces().Get(ctx, p.namespace, metav1.GetOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to access namespace %s: %w", p.namespace, err)
+		}
+	}
+
+	if p.executorFactory == nil {
+		p.executorFactory = newDefaultExecutorFactory(p.restConfig)
+	}
+	if p.dialer == nil {
+		var d net.Dialer
+		p.dialer = d.DialContext
+	}
+
+	log.Printf("Kubernetes provider initialized (namespace: %s)", p.namespace)
+	return p, nil
+}
+
+// loadRESTConfig builds a REST config from a kubeconfig path, or the in-cluster
+// config when the path is empty.
+func loadRESTConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+		}
+		return restConfig, nil
+	}
+
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s: %w", kubeconfig, err)
+	}
+	return restConfig, nil
+}
+
+// podName generates a consistent pod name from session ID.
+func podName(sessionID string) string {
+	return fmt.Sprintf("discobot-session-%s", sessionID)
+}
+
+// secretName returns the name of the Secret holding a session's sandbox state.
+func secretName(sessionID string) string {
+	return fmt.Sprintf("discobot-session-%s", sessionID)
+}
+
+// volumeName returns the PersistentVolumeClaim name for a session's data volume.
+func volumeName(sessionID string) string {
+	return fmt.Sprintf("%s%s", dataVolumePrefix, sessionID)
+}
+
+// managedSelector selects all resources managed by discobot.
+func managedSelector() string {
+	return labels.SelectorFromSet(labels.Set{labelManaged: "true"}).String()
+}
+
+// ImageExists always reports true: images are pulled by the kubelet on the
+// node that schedules the pod, so there is nothing to check from the server.
+func (p *Provider) ImageExists(_ context.Context) bool {
+	return true
+}
+
+// Image returns the configured sandbox image name.
+func (p *Provider) Image() string {
+	return p.cfg.SandboxImage
+}
+
+// Create creates the Secret and data volume for the given session.
+// The pod itself is created by Start.
+func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error) {
+	secrets := p.clientset.CoreV1().Secrets(p.namespace)
+
+	// Clean up any stale sandbox from a previous run, keeping the data volume
+	if _, err := secrets.Get(ctx, secretName(sessionID), metav1.GetOptions{}); err == nil {
+		log.Printf("Removing stale sandbox %s before creating new sandbox", secretName(sessionID))
+		if err := p.Remove(ctx, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to remove stale sandbox: %w", err)
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to check for existing sandbox: %w", err)
+	}
+
+	// Resolve project for labelling
+	projectID, err := p.sessionProjectResolver(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project for session %s: %w", sessionID, err)
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("session %s has no associated project", sessionID)
+	}
+
+	resourceLabels := map[string]string{
+		labelManaged:   "true",
+		labelSessionID: sessionID,
+		labelProjectID: projectID,
+	}
+
+	// Create data volume for persistent storage (kept across rebuilds)
+	if err := p.ensureDataVolume(ctx, sessionID, resourceLabels, opts.Resources.DiskMB); err != nil {
+		return nil, fmt.Errorf("failed to create data volume: %w", err)
+	}
+
+	optionsJSON, err := json.Marshal(storedOptions{
+		Labels:              opts.Labels,
+		WorkspacePath:       opts.WorkspacePath,
+		WorkspaceSource:     opts.WorkspaceSource,
+		WorkspaceCommit:     opts.WorkspaceCommit,
+		WorkspaceSubmodules: opts.WorkspaceSubmodules,
+		WorkspaceLFS:        opts.WorkspaceLFS,
+		ForkedFrom:          opts.ForkedFrom,
+		ForkMessageID:       opts.ForkMessageID,
+		Resources:           opts.Resources,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sandbox options: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   secretName(sessionID),
+			Labels: resourceLabels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			secretKeySecret:  []byte(opts.SharedSecret),
+			secretKeyOptions: optionsJSON,
+		},
+	}
+
+	created, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, sandbox.ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
+	}
+
+	return &sandbox.Sandbox{
+		ID:        string(created.UID),
+		SessionID: sessionID,
+		Status:    sandbox.StatusCreated,
+		Image:     p.cfg.SandboxImage,
+		CreatedAt: time.Now(),
+		Metadata: map[string]string{
+			"name":      podName(sessionID),
+			"namespace": p.namespace,
+		},
+	}, nil
+}
+
+// storedOptions is the subset of sandbox.CreateOptions persisted in the session
+// Secret so that Start can rebuild the pod after a Stop.
+type storedOptions struct {
+	Labels              map[string]string      `json:"labels,omitempty"`
+	WorkspacePath       string                 `json:"workspacePath,omitempty"`
+	WorkspaceSource     string                 `json:"workspaceSource,omitempty"`
+	WorkspaceCommit     string                 `json:"workspaceCommit,omitempty"`
+	WorkspaceSubmodules bool                   `json:"workspaceSubmodules,omitempty"`
+	WorkspaceLFS        bool                   `json:"workspaceLfs,omitempty"`
+	ForkedFrom          string                 `json:"forkedFrom,omitempty"`
+	ForkMessageID       string                 `json:"forkMessageId,omitempty"`
+	Resources           sandbox.ResourceConfig `json:"resources"`
+}
+
+// ensureDataVolume creates the session's data volume claim if it doesn't exist.
+func (p *Provider) ensureDataVolume(ctx context.Context, sessionID string, resourceLabels map[string]string, diskMB int) error {
+	size := p.cfg.KubernetesDataVolumeSize
+	if diskMB > 0 {
+		size = fmt.Sprintf("%dMi", diskMB)
+	}
+	if size == "" {
+		size = "10Gi"
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return fmt.Errorf("invalid data volume size %q: %w", size, err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   volumeName(sessionID),
+			Labels: resourceLabels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: quantity,
+				},
+			},
+		},
+	}
+	if p.cfg.KubernetesStorageClass != "" {
+		storageClass := p.cfg.KubernetesStorageClass
+		pvc.Spec.StorageClassName = &storageClass
+	}
+
+	_, err = p.clientset.CoreV1().PersistentVolumeClaims(p.namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// CloneVolume creates the data volume claim for targetSessionID as a clone of
+// the source session's claim. Implements sandbox.VolumeCloner.
+//
+// The copy is done by the CSI driver, so the storage class must support volume
+// cloning. Create finds the claim already present and leaves it alone.
+func (p *Provider) CloneVolume(ctx context.Context, sourceSessionID, targetSessionID string) error {
+	pvcs := p.clientset.CoreV1().PersistentVolumeClaims(p.namespace)
+
+	source, err := pvcs.Get(ctx, volumeName(sourceSessionID), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: data volume %s does not exist", sandbox.ErrNotFound, volumeName(sourceSessionID))
+		}
+		return fmt.Errorf("failed to get data volume: %w", err)
+	}
+
+	resourceLabels := make(map[string]string, len(source.Labels))
+	for k, v := range source.Labels {
+		resourceLabels[k] = v
+	}
+	resourceLabels[labelSessionID] = targetSessionID
+
+	// A clone must be at least as large as its source
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   volumeName(targetSessionID),
+			Labels: resourceLabels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      source.Spec.AccessModes,
+			Resources:        source.Spec.Resources,
+			StorageClassName: source.Spec.StorageClassName,
+			VolumeMode:       source.Spec.VolumeMode,
+			DataSource: &corev1.TypedLocalObjectReference{
+				Kind: "PersistentVolumeClaim",
+				Name: source.Name,
+			},
+		},
+	}
+
+	if _, err := pvcs.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("%w: data volume %s already exists", sandbox.ErrAlreadyExists, pvc.Name)
+		}
+		return fmt.Errorf("failed to clone data volume: %w", err)
+	}
+
+	log.Printf("Cloned data volume %s to %s", source.Name, pvc.Name)
+	return nil
+}
+
+// buildPod builds the pod spec for a session from its stored options.
+func (p *Provider) buildPod(sessionID string, secret *corev1.Secret, opts storedOptions) *corev1.Pod {
+	podLabels := make(map[string]string, len(secret.Labels)+len(opts.Labels))
+	for k, v := range opts.Labels {
+		podLabels[k] = v
+	}
+	for k, v := range secret.Labels {
+		podLabels[k] = v
+	}
+
+	// Build environment variables
+	env := []corev1.EnvVar{
+		// Add session ID (required by discobot-agent for filesystem setup)
+		{Name: "SESSION_ID", Value: sessionID},
+	}
+
+	// Add hashed secret as DISCOBOT_SECRET env var
+	if raw := string(secret.Data[secretKeySecret]); raw != "" {
+		env = append(env, corev1.EnvVar{Name: "DISCOBOT_SECRET", Value: hashSecret(raw)})
+	}
+
+	// Handle workspace environment variables
+	// WORKSPACE_PATH is always the mount point inside the container
+	// WORKSPACE_SOURCE is the original source (local path or git URL)
+	if opts.WorkspacePath != "" {
+		env = append(env, corev1.EnvVar{Name: "WORKSPACE_PATH", Value: workspacePath})
+	}
+	if opts.WorkspaceSource != "" {
+		env = append(env, corev1.EnvVar{Name: "WORKSPACE_SOURCE", Value: opts.WorkspaceSource})
+	}
+	if opts.WorkspaceCommit != "" {
+		env = append(env, corev1.EnvVar{Name: "WORKSPACE_COMMIT", Value: opts.WorkspaceCommit})
+	}
+	if opts.WorkspaceSubmodules {
+		env = append(env, corev1.EnvVar{Name: "WORKSPACE_SUBMODULES", Value: "1"})
+	}
+	if opts.WorkspaceLFS {
+		env = append(env, corev1.EnvVar{Name: "WORKSPACE_LFS", Value: "1"})
+	}
+	if opts.ForkedFrom != "" {
+		env = append(env, corev1.EnvVar{Name: "FORKED_FROM_SESSION_ID", Value: opts.ForkedFrom})
+	}
+	if opts.ForkMessageID != "" {
+		env = append(env, corev1.EnvVar{Name: "FORK_MESSAGE_ID", Value: opts.ForkMessageID})
+	}
+
+	privileged := true
+	hostPathDirectory := corev1.HostPathDirectory
+	automountToken := false
+
+	volumes := []corev1.Volume{
+		{
+			Name: "data",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: volumeName(sessionID),
+				},
+			},
+		},
+		// systemd needs read-write access to the host cgroup hierarchy
+		{
+			Name: "cgroup",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: "/sys/fs/cgroup", Type: &hostPathDirectory},
+			},
+		},
+		// systemd requires tmpfs on /run and /run/lock
+		{Name: "run", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+		{Name: "run-lock", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+		// Shared memory for Chromium, etc.
+		{Name: "shm", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: "data", MountPath: dataVolumePath},
+		{Name: "cgroup", MountPath: "/sys/fs/cgroup"},
+		{Name: "run", MountPath: "/run"},
+		{Name: "run-lock", MountPath: "/run/lock"},
+		{Name: "shm", MountPath: "/dev/shm"},
+	}
+
+	// Mount workspace directory read-only from the node. This assumes the
+	// workspace directory is available at the same path on every node
+	// (e.g. a shared filesystem or a single-node cluster).
+	if opts.WorkspacePath != "" {
+		sourcePath := opts.WorkspacePath
+		if abs, err := filepath.Abs(sourcePath); err == nil {
+			sourcePath = abs
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name: "workspace",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: sourcePath, Type: &hostPathDirectory},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: "workspace", MountPath: workspacePath, ReadOnly: true})
+	}
+
+	// Apply resource limits
+	limits := corev1.ResourceList{}
+	if opts.Resources.MemoryMB > 0 {
+		limits[corev1.ResourceMemory] = *resource.NewQuantity(int64(opts.Resources.MemoryMB)*1024*1024, resource.BinarySI)
+	}
+	if opts.Resources.CPUCores > 0 {
+		limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(opts.Resources.CPUCores*1000), resource.DecimalSI)
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   podName(sessionID),
+			Labels: podLabels,
+		},
+		Spec: corev1.PodSpec{
+			Hostname:      "discobot",
+			RestartPolicy: corev1.RestartPolicyNever,
+			// Sandboxes must not get credentials for the cluster they run in
+			AutomountServiceAccountToken: &automountToken,
+			Containers: []corev1.Container{{
+				Name:  sandboxContainer,
+				Image: p.cfg.SandboxImage,
+				Env:   env,
+				Ports: []corev1.ContainerPort{{
+					Name:          "agent",
+					ContainerPort: containerPort,
+					Protocol:      corev1.ProtocolTCP,
+				}},
+				Stdin: true,
+				TTY:   true,
+				// Privileged mode for running the Docker daemon and systemd inside the sandbox
+				SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				Resources:       corev1.ResourceRequirements{Limits: limits},
+				VolumeMounts:    mounts,
+			}},
+			Volumes: volumes,
+		},
+	}
+}
+
+// hashSecret creates a salted SHA-256 hash of the secret.
+// This matches the Docker provider implementation.
+func hashSecret(secret string) string {
+	// Generate a random 16-byte salt
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		// Fall back to a zero salt if random fails (shouldn't happen)
+		salt = make([]byte, 16)
+	}
+
+	// Hash the secret with the salt
+	hasher := sha256.New()
+	hasher.Write(salt)
+	hasher.Write([]byte(secret))
+	hash := hasher.Sum(nil)
+
+	// Return salt:hash in hex format
+	return fmt.Sprintf("%s:%s", hex.EncodeToString(salt), hex.EncodeToString(hash))
+}
+
+// Reconcile is a no-op for Kubernetes; images and nodes are managed by the cluster.
+func (p *Provider) Reconcile(_ context.Context) error {
+	return nil
+}
+
+// RemoveProject deletes all pods, secrets and volume claims labelled with the project.
+func (p *Provider) RemoveProject(ctx context.Context, projectID string) error {
+	selector := labels.SelectorFromSet(labels.Set{
+		labelManaged:   "true",
+		labelProjectID: projectID,
+	}).String()
+	listOpts := metav1.ListOptions{LabelSelector: selector}
+	core := p.clientset.CoreV1()
+
+	// Resources are listed and deleted individually rather than with
+	// DeleteCollection so partial failures don't stop the rest of the cleanup.
+	var errs []error
+	deleteNamed := func(kind string, names []string, del func(name string) error) {
+		for _, name := range names {
+			if err := del(name); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to delete %s %s: %w", kind, name, err))
+			}
+		}
+	}
+
+	if secrets, err := core.Secrets(p.namespace).List(ctx, listOpts); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list secrets: %w", err))
+	} else {
+		names := make([]string, 0, len(secrets.Items))
+		for _, s := range secrets.Items {
+			names = append(names, s.Name)
+		}
+		deleteNamed("secret", names, func(name string) error {
+			return core.Secrets(p.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		})
+	}
+
+	if pods, err := core.Pods(p.namespace).List(ctx, listOpts); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list pods: %w", err))
+	} else {
+		names := make([]string, 0, len(pods.Items))
+		for _, pod := range pods.Items {
+			names = append(names, pod.Name)
+		}
+		var graceSeconds int64
+		deleteNamed("pod", names, func(name string) error {
+			return core.Pods(p.namespace).Delete(ctx, name, metav1.DeleteOptions{GracePeriodSeconds: &graceSeconds})
+		})
+	}
+
+	if pvcs, err := core.PersistentVolumeClaims(p.namespace).List(ctx, listOpts); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list volume claims: %w", err))
+	} else {
+		names := make([]string, 0, len(pvcs.Items))
+		for _, pvc := range pvcs.Items {
+			names = append(names, pvc.Name)
+		}
+		deleteNamed("volume claim", names, func(name string) error {
+			return core.PersistentVolumeClaims(p.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		})
+	}
+
+	return errors.Join(errs...)
+}
+
+// Start creates the pod for a previously created sandbox and waits until it is running.
+func (p *Provider) Start(ctx context.Context, sessionID string) error {
+	secret, err := p.getSecret(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	pods := p.clientset.CoreV1().Pods(p.namespace)
+
+	existing, err := pods.Get(ctx, podName(sessionID), metav1.GetOptions{})
+	switch {
+	case err == nil:
+		status, _ := podStatus(existing)
+		if status == sandbox.StatusRunning {
+			return nil
+		}
+		if existing.DeletionTimestamp == nil && (status == sandbox.StatusCreated) {
+			// Pod is already scheduled; just wait for it
+			return p.waitForRunning(ctx, sessionID)
+		}
+		// Pod has exited or is terminating - replace it
+		if err := p.deletePod(ctx, sessionID, 0); err != nil {
+			return fmt.Errorf("%w: failed to remove previous pod: %v", sandbox.ErrStartFailed, err)
+		}
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
+	}
+
+	var opts storedOptions
+	if err := json.Unmarshal(secret.Data[secretKeyOptions], &opts); err != nil {
+		return fmt.Errorf("%w: invalid stored sandbox options: %v", sandbox.ErrStartFailed, err)
+	}
+
+	if _, err := pods.Create(ctx, p.buildPod(sessionID, secret, opts), metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
+	}
+
+	p.annotateSecret(ctx, sessionID, annotationStartedAt)
+
+	return p.waitForRunning(ctx, sessionID)
+}
+
+// waitForRunning polls the pod until it is running, has failed, or the start timeout elapses.
+func (p *Provider) waitForRunning(ctx context.Context, sessionID string) error {
+	timeout := p.cfg.KubernetesStartTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		pod, err := p.clientset.CoreV1().Pods(p.namespace).Get(ctx, podName(sessionID), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
+		}
+		status, errMsg := podStatus(pod)
+		switch status {
+		case sandbox.StatusRunning:
+			return nil
+		case sandbox.StatusFailed, sandbox.StatusStopped:
+			if errMsg == "" {
+				errMsg = "pod exited during startup"
+			}
+			return fmt.Errorf("%w: %s", sandbox.ErrStartFailed, errMsg)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("%w: pod did not become ready within %s", sandbox.ErrTimeout, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop deletes the sandbox pod, keeping its Secret and data volume so it can be restarted.
+func (p *Provider) Stop(ctx context.Context, sessionID string, timeout time.Duration) error {
+	if _, err := p.getSecret(ctx, sessionID); err != nil {
+		return err
+	}
+
+	if err := p.deletePod(ctx, sessionID, timeout); err != nil {
+		return fmt.Errorf("failed to stop sandbox: %w", err)
+	}
+
+	p.annotateSecret(ctx, sessionID, annotationStoppedAt)
+	return nil
+}
+
+// deletePod deletes the session's pod and waits for it to be gone.
+// A missing pod is not an error.
+func (p *Provider) deletePod(ctx context.Context, sessionID string, gracePeriod time.Duration) error {
+	pods := p.clientset.CoreV1().Pods(p.namespace)
+	graceSeconds := int64(gracePeriod.Seconds())
+
+	err := pods.Delete(ctx, podName(sessionID), metav1.DeleteOptions{GracePeriodSeconds: &graceSeconds})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Wait for the kubelet to finish terminating the pod (grace period plus some slack)
+	waitCtx, cancel := context.WithTimeout(ctx, gracePeriod+30*time.Second)
+	defer cancel()
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := pods.Get(waitCtx, podName(sessionID), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("timed out waiting for pod %s to terminate", podName(sessionID))
+		case <-ticker.C:
+		}
+	}
+}
+
+// annotateSecret records the current time under the given annotation on the session Secret.
+func (p *Provider) annotateSecret(ctx context.Context, sessionID, key string) {
+	secrets := p.clientset.CoreV1().Secrets(p.namespace)
+	secret, err := secrets.Get(ctx, secretName(sessionID), metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Warning: failed to get secret for session %s: %v", sessionID, err)
+		return
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[key] = time.Now().UTC().Format(time.RFC3339Nano)
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		log.Printf("Warning: failed to annotate secret for session %s: %v", sessionID, err)
+	}
+}
+
+// Remove removes a sandbox pod and Secret, and optionally its data volume.
+// By default, data volumes are preserved (useful for rebuilds).
+// Pass sandbox.RemoveVolumes() to delete volumes (for session deletion).
+func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox.RemoveOption) error {
+	cfg := sandbox.ParseRemoveOptions(opts)
+	core := p.clientset.CoreV1()
+
+	// Delete the Secret first so watchers observing the pod deletion see the
+	// sandbox as removed rather than stopped.
+	if err := core.Secrets(p.namespace).Delete(ctx, secretName(sessionID), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove sandbox secret: %w", err)
+	}
+
+	var graceSeconds int64
+	if err := core.Pods(p.namespace).Delete(ctx, podName(sessionID), metav1.DeleteOptions{GracePeriodSeconds: &graceSeconds}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove sandbox pod: %w", err)
+	}
+
+	// Explicitly remove the data volume if requested
+	if cfg.RemoveVolumes {
+		if err := core.PersistentVolumeClaims(p.namespace).Delete(ctx, volumeName(sessionID), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to remove data volume %s: %w", volumeName(sessionID), err)
+		}
+	}
+
+	return nil
+}
+
+// getSecret returns the session Secret, or sandbox.ErrNotFound if the sandbox doesn't exist.
+func (p *Provider) getSecret(ctx context.Context, sessionID string) (*corev1.Secret, error) {
+	secret, err := p.clientset.CoreV1().Secrets(p.namespace).Get(ctx, secretName(sessionID), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get sandbox secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Get returns the current state of a sandbox.
+func (p *Provider) Get(ctx context.Context, sessionID string) (*sandbox.Sandbox, error) {
+	secret, err := p.getSecret(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := p.clientset.CoreV1().Pods(p.namespace).Get(ctx, podName(sessionID), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get sandbox pod: %w", err)
+		}
+		pod = nil
+	}
+
+	return p.toSandbox(sessionID, secret, pod), nil
+}
+
+// toSandbox builds a sandbox.Sandbox from a session Secret and its pod (which may be nil).
+func (p *Provider) toSandbox(sessionID string, secret *corev1.Secret, pod *corev1.Pod) *sandbox.Sandbox {
+	s := &sandbox.Sandbox{
+		ID:        string(secret.UID),
+		SessionID: sessionID,
+		Image:     p.cfg.SandboxImage,
+		CreatedAt: secret.CreationTimestamp.Time,
+		Metadata: map[string]string{
+			"name":      podName(sessionID),
+			"namespace": p.namespace,
+		},
+	}
+
+	if pod == nil {
+		// No pod: the sandbox was either never started or has been stopped
+		if _, started := secret.Annotations[annotationStartedAt]; started {
+			s.Status = sandbox.StatusStopped
+			if stopped, err := time.Parse(time.RFC3339Nano, secret.Annotations[annotationStoppedAt]); err == nil {
+				s.StoppedAt = &stopped
+			}
+		} else {
+			s.Status = sandbox.StatusCreated
+		}
+		return s
+	}
+
+	s.Status, s.Error = podStatus(pod)
+	for _, c := range pod.Spec.Containers {
+		if c.Name == sandboxContainer {
+			s.Image = c.Image
+			s.Env = make(map[string]string, len(c.Env))
+			for _, e := range c.Env {
+				s.Env[e.Name] = e.Value
+			}
+		}
+	}
+	if cs := sandboxContainerStatus(pod); cs != nil {
+		if cs.State.Running != nil {
+			started := cs.State.Running.StartedAt.Time
+			s.StartedAt = &started
+		}
+		if cs.State.Terminated != nil {
+			stopped := cs.State.Terminated.FinishedAt.Time
+			s.StoppedAt = &stopped
+		}
+	}
+	if pod.Spec.NodeName != "" {
+		s.Metadata["node"] = pod.Spec.NodeName
+	}
+
+	// The agent port is reachable directly on the pod IP
+	if pod.Status.PodIP != "" {
+		s.Ports = []sandbox.AssignedPort{{
+			ContainerPort: containerPort,
+			HostPort:      containerPort,
+			HostIP:        pod.Status.PodIP,
+			Protocol:      "tcp",
+		}}
+	}
+
+	return s
+}
+
+// sandboxContainerStatus returns the status of the sandbox container, if reported.
+func sandboxContainerStatus(pod *corev1.Pod) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == sandboxContainer {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// podStatus maps a pod's phase and container state to a sandbox status and error message.
+func podStatus(pod *corev1.Pod) (sandbox.Status, string) {
+	if pod.DeletionTimestamp != nil {
+		return sandbox.StatusStopped, ""
+	}
+
+	cs := sandboxContainerStatus(pod)
+
+	// Terminated containers: exit codes 0, 137 (SIGKILL) and 143 (SIGTERM) are
+	// expected when a pod is stopped and are treated as stopped, not failed.
+	if cs != nil && cs.State.Terminated != nil {
+		if cs.State.Terminated.Reason == "OOMKilled" {
+			return sandbox.StatusFailed, "out of memory"
+		}
+		code := cs.State.Terminated.ExitCode
+		if code == 0 || code == 137 || code == 143 {
+			return sandbox.StatusStopped, ""
+		}
+		return sandbox.StatusFailed, fmt.Sprintf("exited with code %d", code)
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodPending, "":
+		// Phase is empty until the pod is scheduled. Surface errors
+		// that will never resolve on their own.
+		if cs != nil && cs.State.Waiting != nil {
+			switch cs.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError", "CreateContainerError":
+				msg := cs.State.Waiting.Reason
+				if cs.State.Waiting.Message != "" {
+					msg += ": " + cs.State.Waiting.Message
+				}
+				return sandbox.StatusFailed, msg
+			}
+		}
+		return sandbox.StatusCreated, ""
+	case corev1.PodRunning:
+		if cs != nil && cs.State.Running == nil {
+			return sandbox.StatusCreated, ""
+		}
+		return sandbox.StatusRunning, ""
+	case corev1.PodSucceeded:
+		return sandbox.StatusStopped, ""
+	case corev1.PodFailed:
+		msg := pod.Status.Message
+		if msg == "" {
+			msg = pod.Status.Reason
+		}
+		return sandbox.StatusFailed, msg
+	default:
+		return sandbox.StatusFailed, "pod status unknown"
+	}
+}
+
+// GetSecret returns the raw shared secret stored during sandbox creation.
+func (p *Provider) GetSecret(ctx context.Context, sessionID string) (string, error) {
+	secret, err := p.getSecret(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	raw := string(secret.Data[secretKeySecret])
+	if raw == "" {
+		return "", fmt.Errorf("shared secret not found for sandbox")
+	}
+	return raw, nil
+}
+
+// List returns all sandboxes managed by discobot.
+func (p *Provider) List(ctx context.Context) ([]*sandbox.Sandbox, error) {
+	listOpts := metav1.ListOptions{LabelSelector: managedSelector()}
+
+	secrets, err := p.clientset.CoreV1().Secrets(p.namespace).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sandboxes: %w", err)
+	}
+
+	pods, err := p.clientset.CoreV1().Pods(p.namespace).List(ctx, listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sandbox pods: %w", err)
+	}
+	podsBySession := make(map[string]*corev1.Pod, len(pods.Items))
+	for i := range pods.Items {
+		podsBySession[pods.Items[i].Labels[labelSessionID]] = &pods.Items[i]
+	}
+
+	result := make([]*sandbox.Sandbox, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		sessionID := secret.Labels[labelSessionID]
+		if sessionID == "" {
+			continue
+		}
+		result = append(result, p.toSandbox(sessionID, secret, podsBySession[sessionID]))
+	}
+
+	return result, nil
+}
+
+// HTTPClient returns an HTTP client configured to communicate with the sandbox.
+// For Kubernetes, this dials the agent port on the pod IP.
+func (p *Provider) HTTPClient(ctx context.Context, sessionID string) (*http.Client, error) {
+	sb, err := p.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sb.Status != sandbox.StatusRunning {
+		return nil, fmt.Errorf("sandbox is not running: %s", sb.Status)
+	}
+	if len(sb.Ports) == 0 {
+		return nil, fmt.Errorf("sandbox pod has no IP address")
+	}
+
+	// Always dial the pod's agent port, ignoring the addr from the URL
+	addr := net.JoinHostPort(sb.Ports[0].HostIP, strconv.Itoa(containerPort))
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return p.dialer(ctx, "tcp", addr)
+		},
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   60 * time.Second,
+	}, nil
+}
+
+// Watch returns a channel that receives sandbox state change events.
+// It first replays the current state of all existing sandboxes, then streams
+// state changes as they occur using a pod informer.
+func (p *Provider) Watch(ctx context.Context) (<-chan sandbox.StateEvent, error) {
+	eventCh := make(chan sandbox.StateEvent, 100)
+
+	factory := informers.NewSharedInformerFactoryWithOptions(p.clientset, 0,
+		informers.WithNamespace(p.namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = managedSelector()
+		}),
+	)
+	podInformer := factory.Core().V1().Pods().Informer()
+
+	// Live events are held back until the replay has been sent so a stale
+	// replayed state never overwrites a newer event.
+	replayed := make(chan struct{})
+	send := func(event *sandbox.StateEvent) {
+		if event == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-replayed:
+		}
+		select {
+		case <-ctx.Done():
+		case eventCh <- *event:
+		}
+	}
+
+	_, err := podInformer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			// Existing pods are covered by the replay below
+			if isInInitialList {
+				return
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				send(translatePodEvent(nil, pod))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, _ := oldObj.(*corev1.Pod)
+			newPod, ok := newObj.(*corev1.Pod)
+			if ok {
+				send(translatePodEvent(oldPod, newPod))
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				send(p.translatePodDeletion(ctx, pod))
+			}
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register pod event handler: %w", err)
+	}
+
+	// Start the informer before replaying so no changes are missed in between
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	go func() {
+		defer close(eventCh)
+		// Shutdown blocks until all informer goroutines (and event handlers) have exited
+		defer factory.Shutdown()
+
+		// First, replay current state of all managed sandboxes
+		sandboxes, err := p.List(ctx)
+		if err != nil {
+			log.Printf("Watch: failed to list sandboxes for replay: %v", err)
+			// Continue anyway - we can still watch for new events
+		} else {
+			for _, sb := range sandboxes {
+				select {
+				case <-ctx.Done():
+					return
+				case eventCh <- sandbox.StateEvent{
+					SessionID: sb.SessionID,
+					Status:    sb.Status,
+					Timestamp: time.Now(),
+					Error:     sb.Error,
+				}:
+				}
+			}
+		}
+		close(replayed)
+
+		<-ctx.Done()
+	}()
+
+	return eventCh, nil
+}
+
+// translatePodEvent converts a pod add/update into a sandbox StateEvent.
+// Returns nil if the event should be ignored (no status change).
+func translatePodEvent(oldPod, newPod *corev1.Pod) *sandbox.StateEvent {
+	sessionID := newPod.Labels[labelSessionID]
+	if sessionID == "" {
+		return nil
+	}
+
+	status, errMsg := podStatus(newPod)
+	if oldPod != nil {
+		if oldStatus, _ := podStatus(oldPod); oldStatus == status {
+			return nil
+		}
+	}
+
+	return &sandbox.StateEvent{
+		SessionID: sessionID,
+		Status:    status,
+		Timestamp: time.Now(),
+		Error:     errMsg,
+	}
+}
+
+// translatePodDeletion converts a pod deletion into a sandbox StateEvent.
+// The sandbox is only reported as removed once its Secret is gone; a pod
+// deleted while the Secret still exists means the sandbox was stopped.
+func (p *Provider) translatePodDeletion(ctx context.Context, pod *corev1.Pod) *sandbox.StateEvent {
+	sessionID := pod.Labels[labelSessionID]
+	if sessionID == "" {
+		return nil
+	}
+
+	status := sandbox.StatusStopped
+	if _, err := p.getSecret(ctx, sessionID); errors.Is(err, sandbox.ErrNotFound) {
+		status = sandbox.StatusRemoved
+	}
+
+	return &sandbox.StateEvent{
+		SessionID: sessionID,
+		Status:    status,
+		Timestamp: time.Now(),
+	}
+}
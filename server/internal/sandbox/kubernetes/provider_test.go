@@ -0,0 +1,721 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+const testNamespace = "discobot-test"
+
+func newTestProvider(t *testing.T, opts ...Option) (*Provider, *fake.Clientset) {
+	t.Helper()
+
+	clientset := fake.NewSimpleClientset()
+	cfg := &config.Config{
+		SandboxImage:             "ghcr.io/obot-platform/discobot:test",
+		KubernetesNamespace:      testNamespace,
+		KubernetesDataVolumeSize: "1Gi",
+		KubernetesStartTimeout:   5 * time.Second,
+	}
+	resolver := func(_ context.Context, sessionID string) (string, error) {
+		if strings.HasPrefix(sessionID, "other-") {
+			return "project-2", nil
+		}
+		return "project-1", nil
+	}
+
+	opts = append([]Option{WithClientset(clientset), WithPollInterval(10 * time.Millisecond)}, opts...)
+	p, err := NewProvider(cfg, resolver, opts...)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	return p, clientset
+}
+
+// markPodRunning waits for the session's pod to be created and then reports it
+// as running, standing in for the kubelet.
+func markPodRunning(t *testing.T, clientset *fake.Clientset, sessionID string) {
+	t.Helper()
+
+	pods := clientset.CoreV1().Pods(testNamespace)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pod, err := pods.Get(context.Background(), podName(sessionID), metav1.GetOptions{})
+		if err == nil {
+			pod.Status.Phase = corev1.PodRunning
+			pod.Status.PodIP = "10.0.0.5"
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:  sandboxContainer,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.Now()}},
+			}}
+			if _, err := pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+				t.Errorf("failed to update pod status: %v", err)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("pod for session %s was never created", sessionID)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func startSandbox(t *testing.T, p *Provider, clientset *fake.Clientset, sessionID string) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		markPodRunning(t, clientset, sessionID)
+	}()
+	if err := p.Start(context.Background(), sessionID); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	<-done
+}
+
+func TestProvider_Lifecycle(t *testing.T) {
+	p, clientset := newTestProvider(t)
+	ctx := context.Background()
+	sessionID := "session-1"
+
+	sb, err := p.Create(ctx, sessionID, sandbox.CreateOptions{
+		SharedSecret:    "raw-secret",
+		Labels:          map[string]string{"discobot.workspace.id": "ws-1"},
+		WorkspacePath:   "/srv/workspaces/ws-1",
+		WorkspaceSource: "https://github.com/example/repo.git",
+		WorkspaceCommit: "abc123",
+		Resources:       sandbox.ResourceConfig{MemoryMB: 512, CPUCores: 1.5},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if sb.Status != sandbox.StatusCreated {
+		t.Errorf("expected status %s after create, got %s", sandbox.StatusCreated, sb.Status)
+	}
+
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volumeName(sessionID), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected data volume claim: %v", err)
+	}
+	if pvc.Labels[labelProjectID] != "project-1" || pvc.Labels[labelSessionID] != sessionID {
+		t.Errorf("unexpected volume claim labels: %v", pvc.Labels)
+	}
+
+	got, err := p.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != sandbox.StatusCreated {
+		t.Errorf("expected status %s before start, got %s", sandbox.StatusCreated, got.Status)
+	}
+
+	startSandbox(t, p, clientset, sessionID)
+
+	pod, err := clientset.CoreV1().Pods(testNamespace).Get(ctx, podName(sessionID), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected pod after start: %v", err)
+	}
+	if pod.Labels["discobot.workspace.id"] != "ws-1" || pod.Labels[labelManaged] != "true" {
+		t.Errorf("unexpected pod labels: %v", pod.Labels)
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != "ghcr.io/obot-platform/discobot:test" {
+		t.Errorf("unexpected image %q", container.Image)
+	}
+	if mem := container.Resources.Limits[corev1.ResourceMemory]; mem.Value() != 512*1024*1024 {
+		t.Errorf("unexpected memory limit %s", mem.String())
+	}
+	if cpu := container.Resources.Limits[corev1.ResourceCPU]; cpu.MilliValue() != 1500 {
+		t.Errorf("unexpected cpu limit %s", cpu.String())
+	}
+
+	got, err = p.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != sandbox.StatusRunning {
+		t.Errorf("expected status %s after start, got %s", sandbox.StatusRunning, got.Status)
+	}
+	if got.Env["WORKSPACE_PATH"] != workspacePath || got.Env["WORKSPACE_COMMIT"] != "abc123" || got.Env["SESSION_ID"] != sessionID {
+		t.Errorf("unexpected env: %v", got.Env)
+	}
+	if hashed := got.Env["DISCOBOT_SECRET"]; hashed == "" || hashed == "raw-secret" {
+		t.Errorf("expected hashed DISCOBOT_SECRET, got %q", hashed)
+	}
+	if len(got.Ports) != 1 || got.Ports[0].HostIP != "10.0.0.5" || got.Ports[0].ContainerPort != containerPort {
+		t.Errorf("unexpected ports: %+v", got.Ports)
+	}
+
+	secret, err := p.GetSecret(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("GetSecret failed: %v", err)
+	}
+	if secret != "raw-secret" {
+		t.Errorf("expected raw secret, got %q", secret)
+	}
+
+	// Starting an already running sandbox is a no-op
+	if err := p.Start(ctx, sessionID); err != nil {
+		t.Errorf("Start on running sandbox failed: %v", err)
+	}
+
+	if err := p.Stop(ctx, sessionID, time.Second); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if _, err := clientset.CoreV1().Pods(testNamespace).Get(ctx, podName(sessionID), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected pod to be deleted after stop, got %v", err)
+	}
+	got, err = p.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get after stop failed: %v", err)
+	}
+	if got.Status != sandbox.StatusStopped {
+		t.Errorf("expected status %s after stop, got %s", sandbox.StatusStopped, got.Status)
+	}
+	if got.StoppedAt == nil {
+		t.Error("expected StoppedAt to be set after stop")
+	}
+
+	// Restart recreates the pod from the stored options
+	startSandbox(t, p, clientset, sessionID)
+	got, err = p.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("Get after restart failed: %v", err)
+	}
+	if got.Status != sandbox.StatusRunning {
+		t.Errorf("expected status %s after restart, got %s", sandbox.StatusRunning, got.Status)
+	}
+
+	// Remove without volumes keeps the data volume
+	if err := p.Remove(ctx, sessionID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := p.Get(ctx, sessionID); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after remove, got %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volumeName(sessionID), metav1.GetOptions{}); err != nil {
+		t.Errorf("expected data volume to be preserved: %v", err)
+	}
+
+	if err := p.Remove(ctx, sessionID, sandbox.RemoveVolumes()); err != nil {
+		t.Fatalf("Remove with volumes failed: %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volumeName(sessionID), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected data volume to be removed, got %v", err)
+	}
+}
+
+func TestProvider_NotFound(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	if _, err := p.Get(ctx, "missing"); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("Get: expected ErrNotFound, got %v", err)
+	}
+	if _, err := p.GetSecret(ctx, "missing"); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("GetSecret: expected ErrNotFound, got %v", err)
+	}
+	if err := p.Start(ctx, "missing"); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("Start: expected ErrNotFound, got %v", err)
+	}
+	if _, err := p.Exec(ctx, "missing", []string{"true"}, sandbox.ExecOptions{}); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("Exec: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestProvider_CloneVolume(t *testing.T) {
+	p, clientset := newTestProvider(t)
+	ctx := context.Background()
+
+	if err := p.CloneVolume(ctx, "session-1", "session-2"); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Fatalf("CloneVolume without source: expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := p.Create(ctx, "session-1", sandbox.CreateOptions{SharedSecret: "secret"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := p.CloneVolume(ctx, "session-1", "session-2"); err != nil {
+		t.Fatalf("CloneVolume failed: %v", err)
+	}
+
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(testNamespace).Get(ctx, volumeName("session-2"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cloned data volume not found: %v", err)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Kind != "PersistentVolumeClaim" || pvc.Spec.DataSource.Name != volumeName("session-1") {
+		t.Errorf("unexpected data source: %+v", pvc.Spec.DataSource)
+	}
+	if pvc.Labels[labelSessionID] != "session-2" || pvc.Labels[labelProjectID] != "project-1" {
+		t.Errorf("unexpected labels: %v", pvc.Labels)
+	}
+	if got := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; got.String() != "1Gi" {
+		t.Errorf("expected clone size 1Gi, got %s", got.String())
+	}
+
+	if err := p.CloneVolume(ctx, "session-1", "session-2"); !errors.Is(err, sandbox.ErrAlreadyExists) {
+		t.Errorf("CloneVolume onto existing volume: expected ErrAlreadyExists, got %v", err)
+	}
+
+	// Creating the forked sandbox keeps the cloned claim and passes the fork through
+	if _, err := p.Create(ctx, "session-2", sandbox.CreateOptions{ForkedFrom: "session-1", ForkMessageID: "msg-3"}); err != nil {
+		t.Fatalf("Create forked sandbox failed: %v", err)
+	}
+	startSandbox(t, p, clientset, "session-2")
+
+	pod, err := clientset.CoreV1().Pods(testNamespace).Get(ctx, podName("session-2"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("pod not found: %v", err)
+	}
+	env := map[string]string{}
+	for _, e := range pod.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["FORKED_FROM_SESSION_ID"] != "session-1" || env["FORK_MESSAGE_ID"] != "msg-3" {
+		t.Errorf("fork environment not set: %v", env)
+	}
+}
+
+func TestProvider_StartFailsOnImagePullError(t *testing.T) {
+	p, clientset := newTestProvider(t)
+	ctx := context.Background()
+
+	if _, err := p.Create(ctx, "session-1", sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	go func() {
+		pods := clientset.CoreV1().Pods(testNamespace)
+		for {
+			pod, err := pods.Get(ctx, podName("session-1"), metav1.GetOptions{})
+			if err == nil {
+				pod.Status.Phase = corev1.PodPending
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					Name:  sandboxContainer,
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+				}}
+				_, _ = pods.UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	err := p.Start(ctx, "session-1")
+	if !errors.Is(err, sandbox.ErrStartFailed) {
+		t.Fatalf("expected ErrStartFailed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "ImagePullBackOff") {
+		t.Errorf("expected error to mention ImagePullBackOff, got %v", err)
+	}
+}
+
+func TestProvider_List(t *testing.T) {
+	p, clientset := newTestProvider(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"session-1", "session-2"} {
+		if _, err := p.Create(ctx, id, sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+			t.Fatalf("Create %s failed: %v", id, err)
+		}
+	}
+	startSandbox(t, p, clientset, "session-2")
+
+	sandboxes, err := p.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	statuses := make(map[string]sandbox.Status)
+	for _, sb := range sandboxes {
+		statuses[sb.SessionID] = sb.Status
+	}
+	want := map[string]sandbox.Status{
+		"session-1": sandbox.StatusCreated,
+		"session-2": sandbox.StatusRunning,
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("List statuses = %v, want %v", statuses, want)
+	}
+}
+
+func TestProvider_RemoveProject(t *testing.T) {
+	p, clientset := newTestProvider(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"session-1", "other-session"} {
+		if _, err := p.Create(ctx, id, sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+			t.Fatalf("Create %s failed: %v", id, err)
+		}
+		startSandbox(t, p, clientset, id)
+	}
+
+	if err := p.RemoveProject(ctx, "project-1"); err != nil {
+		t.Fatalf("RemoveProject failed: %v", err)
+	}
+
+	core := clientset.CoreV1()
+	if _, err := core.Secrets(testNamespace).Get(ctx, secretName("session-1"), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected project-1 secret to be deleted, got %v", err)
+	}
+	if _, err := core.Pods(testNamespace).Get(ctx, podName("session-1"), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected project-1 pod to be deleted, got %v", err)
+	}
+	if _, err := core.PersistentVolumeClaims(testNamespace).Get(ctx, volumeName("session-1"), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected project-1 volume claim to be deleted, got %v", err)
+	}
+
+	// Resources of other projects are untouched
+	if _, err := p.Get(ctx, "other-session"); err != nil {
+		t.Errorf("expected other project's sandbox to remain, got %v", err)
+	}
+	if _, err := core.PersistentVolumeClaims(testNamespace).Get(ctx, volumeName("other-session"), metav1.GetOptions{}); err != nil {
+		t.Errorf("expected other project's volume claim to remain, got %v", err)
+	}
+}
+
+func TestProvider_Watch(t *testing.T) {
+	p, clientset := newTestProvider(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := p.Create(ctx, "existing", sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	events, err := p.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	// The fake clientset drops changes made between the informer's list and
+	// watch calls, so wait for the watch to be established.
+	watching := func() bool {
+		for _, action := range clientset.Actions() {
+			if action.GetVerb() == "watch" && action.GetResource().Resource == "pods" {
+				return true
+			}
+		}
+		return false
+	}
+	for deadline := time.Now().Add(5 * time.Second); !watching(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("informer never started watching pods")
+		}
+	}
+
+	expect := func(sessionID string, status sandbox.Status) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					t.Fatalf("event channel closed waiting for %s/%s", sessionID, status)
+				}
+				if ev.SessionID == sessionID && ev.Status == status {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s/%s", sessionID, status)
+			}
+		}
+	}
+
+	// Replay of existing sandboxes
+	expect("existing", sandbox.StatusCreated)
+
+	if _, err := p.Create(ctx, "session-1", sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	startSandbox(t, p, clientset, "session-1")
+	expect("session-1", sandbox.StatusRunning)
+
+	if err := p.Stop(ctx, "session-1", 0); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	expect("session-1", sandbox.StatusStopped)
+
+	startSandbox(t, p, clientset, "session-1")
+	expect("session-1", sandbox.StatusRunning)
+
+	if err := p.Remove(ctx, "session-1", sandbox.RemoveVolumes()); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	expect("session-1", sandbox.StatusRemoved)
+
+	cancel()
+	for range events {
+		// Drain until the watcher closes the channel
+	}
+}
+
+// fakeExecutor records the exec URL and replays canned output.
+type fakeExecutor struct {
+	url      *url.URL
+	stdout   string
+	stderr   string
+	exitCode int
+	stdin    string
+	ttySize  *remotecommand.TerminalSize
+}
+
+func (e *fakeExecutor) Stream(opts remotecommand.StreamOptions) error {
+	return e.StreamWithContext(context.Background(), opts)
+}
+
+func (e *fakeExecutor) StreamWithContext(_ context.Context, opts remotecommand.StreamOptions) error {
+	if opts.TerminalSizeQueue != nil {
+		e.ttySize = opts.TerminalSizeQueue.Next()
+	}
+	if opts.Stdin != nil {
+		buf := new(strings.Builder)
+		b := make([]byte, 64)
+		for {
+			n, err := opts.Stdin.Read(b)
+			buf.Write(b[:n])
+			if err != nil {
+				break
+			}
+		}
+		e.stdin = buf.String()
+	}
+	if opts.Stdout != nil {
+		_, _ = opts.Stdout.Write([]byte(e.stdout))
+	}
+	if opts.Stderr != nil {
+		_, _ = opts.Stderr.Write([]byte(e.stderr))
+	}
+	if e.exitCode != 0 {
+		return utilexec.CodeExitError{Err: errors.New("command failed"), Code: e.exitCode}
+	}
+	return nil
+}
+
+func TestProvider_Exec(t *testing.T) {
+	executor := &fakeExecutor{stdout: "hello\n", stderr: "warn\n", exitCode: 3}
+	p, clientset := newTestProvider(t, WithExecutorFactory(func(method string, u *url.URL) (remotecommand.Executor, error) {
+		if method != "POST" {
+			t.Errorf("expected POST, got %s", method)
+		}
+		executor.url = u
+		return executor, nil
+	}))
+	ctx := context.Background()
+
+	if _, err := p.Create(ctx, "session-1", sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := p.Exec(ctx, "session-1", []string{"true"}, sandbox.ExecOptions{}); !errors.Is(err, sandbox.ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning before start, got %v", err)
+	}
+
+	startSandbox(t, p, clientset, "session-1")
+
+	result, err := p.Exec(ctx, "session-1", []string{"echo", "hello"}, sandbox.ExecOptions{
+		WorkDir: "/workspace",
+		Env:     map[string]string{"FOO": "bar"},
+		User:    "discobot",
+		Stdin:   strings.NewReader("input"),
+	})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if result.ExitCode != 3 || string(result.Stdout) != "hello\n" || string(result.Stderr) != "warn\n" {
+		t.Errorf("unexpected result: exit=%d stdout=%q stderr=%q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+	if executor.stdin != "input" {
+		t.Errorf("expected stdin to be forwarded, got %q", executor.stdin)
+	}
+
+	if !strings.HasSuffix(executor.url.Path, "/api/v1/namespaces/"+testNamespace+"/pods/"+podName("session-1")+"/exec") {
+		t.Errorf("unexpected exec path %s", executor.url.Path)
+	}
+	query := executor.url.Query()
+	wantCmd := []string{"runuser", "-u", "discobot", "--", "env", "-C", "/workspace", "FOO=bar", "echo", "hello"}
+	if !reflect.DeepEqual(query["command"], wantCmd) {
+		t.Errorf("command = %v, want %v", query["command"], wantCmd)
+	}
+	if query.Get("container") != sandboxContainer || query.Get("stdin") != "true" || query.Get("stderr") != "true" {
+		t.Errorf("unexpected exec query: %v", query)
+	}
+}
+
+func TestProvider_AttachAndExecStream(t *testing.T) {
+	var executor *fakeExecutor
+	p, clientset := newTestProvider(t, WithExecutorFactory(func(_ string, u *url.URL) (remotecommand.Executor, error) {
+		executor = &fakeExecutor{url: u, stdout: "out", stderr: "err", exitCode: 7}
+		return executor, nil
+	}))
+	ctx := context.Background()
+
+	if _, err := p.Create(ctx, "session-1", sandbox.CreateOptions{SharedSecret: "s"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	startSandbox(t, p, clientset, "session-1")
+
+	pty, err := p.Attach(ctx, "session-1", sandbox.AttachOptions{Cmd: []string{"/bin/bash"}, Rows: 40, Cols: 120})
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	if _, err := pty.Write([]byte("ls\n")); err != nil {
+		t.Fatalf("PTY write failed: %v", err)
+	}
+	_ = pty.(*execSession).CloseWrite()
+	out := make([]byte, 16)
+	n, _ := pty.Read(out)
+	if string(out[:n]) != "out" {
+		t.Errorf("PTY read = %q, want %q", out[:n], "out")
+	}
+	code, err := pty.Wait(ctx)
+	if err != nil || code != 7 {
+		t.Errorf("PTY Wait = %d, %v; want 7, nil", code, err)
+	}
+	if executor.ttySize == nil || executor.ttySize.Height != 40 || executor.ttySize.Width != 120 {
+		t.Errorf("unexpected terminal size: %+v", executor.ttySize)
+	}
+	if executor.url.Query().Get("tty") != "true" {
+		t.Errorf("expected tty exec, got %v", executor.url.Query())
+	}
+	_ = pty.Close()
+
+	stream, err := p.ExecStream(ctx, "session-1", []string{"cat"}, sandbox.ExecStreamOptions{})
+	if err != nil {
+		t.Fatalf("ExecStream failed: %v", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite failed: %v", err)
+	}
+	n, _ = stream.Read(out)
+	if string(out[:n]) != "out" {
+		t.Errorf("stream stdout = %q, want %q", out[:n], "out")
+	}
+	n, _ = stream.Stderr().Read(out)
+	if string(out[:n]) != "err" {
+		t.Errorf("stream stderr = %q, want %q", out[:n], "err")
+	}
+	code, err = stream.Wait(ctx)
+	if err != nil || code != 7 {
+		t.Errorf("stream Wait = %d, %v; want 7, nil", code, err)
+	}
+	_ = stream.Close()
+}
+
+func TestWrapCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     []string
+		workDir string
+		env     map[string]string
+		user    string
+		want    []string
+	}{
+		{
+			name: "plain command",
+			cmd:  []string{"ls", "-la"},
+			want: []string{"ls", "-la"},
+		},
+		{
+			name:    "work dir",
+			cmd:     []string{"pwd"},
+			workDir: "/tmp",
+			want:    []string{"env", "-C", "/tmp", "pwd"},
+		},
+		{
+			name: "sorted env",
+			cmd:  []string{"printenv"},
+			env:  map[string]string{"B": "2", "A": "1"},
+			want: []string{"env", "A=1", "B=2", "printenv"},
+		},
+		{
+			name: "user",
+			cmd:  []string{"whoami"},
+			user: "discobot",
+			want: []string{"runuser", "-u", "discobot", "--", "whoami"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapCommand(tt.cmd, tt.workDir, tt.env, tt.user)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodStatus(t *testing.T) {
+	terminated := func(code int32, reason string) []corev1.ContainerStatus {
+		return []corev1.ContainerStatus{{
+			Name:  sandboxContainer,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code, Reason: reason}},
+		}}
+	}
+	now := metav1.Now()
+
+	tests := []struct {
+		name    string
+		pod     corev1.Pod
+		want    sandbox.Status
+		wantErr string
+	}{
+		{
+			name: "pending",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}},
+			want: sandbox.StatusCreated,
+		},
+		{
+			name: "running",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			want: sandbox.StatusRunning,
+		},
+		{
+			name: "terminating",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			want: sandbox.StatusStopped,
+		},
+		{
+			name: "sigterm exit is a stop",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: terminated(143, "Error")}},
+			want: sandbox.StatusStopped,
+		},
+		{
+			name:    "non-zero exit is a failure",
+			pod:     corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: terminated(2, "Error")}},
+			want:    sandbox.StatusFailed,
+			wantErr: "exited with code 2",
+		},
+		{
+			name:    "oom killed",
+			pod:     corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: terminated(1, "OOMKilled")}},
+			want:    sandbox.StatusFailed,
+			wantErr: "out of memory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, errMsg := podStatus(&tt.pod)
+			if status != tt.want || errMsg != tt.wantErr {
+				t.Errorf("podStatus() = %s, %q; want %s, %q", status, errMsg, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
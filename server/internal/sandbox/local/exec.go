@@ -0,0 +1,248 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/creack/pty"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// defaultTerm is the TERM value for PTY sessions when the caller does not set one.
+const defaultTerm = "xterm-256color"
+
+// resolveWorkDir returns the absolute directory for a command, relative to the
+// workspace. Absolute paths are treated as rooted at the workspace, and paths
+// that would escape the workspace are rejected.
+func resolveWorkDir(workspacePath, workDir string) (string, error) {
+	dir := filepath.Join(workspacePath, workDir)
+	rel, err := filepath.Rel(workspacePath, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("working directory %q is outside the workspace", workDir)
+	}
+	return dir, nil
+}
+
+// commandEnv builds the environment for a command run in the sandbox.
+func commandEnv(info *processInfo, extra map[string]string) []string {
+	env := os.Environ()
+	for k, v := range info.env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	for k, v := range extra {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
+
+// defaultShell returns the user's login shell, falling back to /bin/sh.
+func defaultShell() []string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		if _, err := exec.LookPath(shell); err == nil {
+			return []string{shell, "-l"}
+		}
+	}
+	return []string{"/bin/sh"}
+}
+
+// startPTY starts cmd under a new pseudo-terminal.
+func startPTY(cmd *exec.Cmd, rows, cols int) (*process, error) {
+	var (
+		tty *os.File
+		err error
+	)
+	if rows > 0 && cols > 0 {
+		tty, err = pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+	} else {
+		tty, err = pty.Start(cmd)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &process{
+		cmd:    cmd,
+		tty:    tty,
+		stdin:  tty,
+		stdout: tty,
+		done:   make(chan struct{}),
+	}
+	go p.wait()
+	return p, nil
+}
+
+// startPipes starts cmd with separate stdin, stdout and stderr pipes.
+func startPipes(cmd *exec.Cmd) (*process, error) {
+	// os.Pipe is used instead of cmd.StdoutPipe so that cmd.Wait does not
+	// close the read ends before the caller has drained them.
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		closeAll(stdinR, stdinW)
+		return nil, err
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		closeAll(stdinR, stdinW, stdoutR, stdoutW)
+		return nil, err
+	}
+
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		closeAll(stdinR, stdinW, stdoutR, stdoutW, stderrR, stderrW)
+		return nil, err
+	}
+
+	// The child holds its own copies of these.
+	closeAll(stdinR, stdoutW, stderrW)
+
+	p := &process{
+		cmd:    cmd,
+		stdin:  stdinW,
+		stdout: stdoutR,
+		stderr: stderrR,
+		done:   make(chan struct{}),
+	}
+	go p.wait()
+	return p, nil
+}
+
+func closeAll(files ...*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// process is a host process started by Attach or ExecStream.
+// It implements both sandbox.PTY and sandbox.Stream.
+type process struct {
+	cmd *exec.Cmd
+
+	// tty is the PTY master, or nil when running with pipes.
+	tty    *os.File
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+
+	closeOnce      sync.Once
+	closeWriteOnce sync.Once
+
+	done     chan struct{}
+	exitCode int
+	err      error
+}
+
+// wait reaps the process and records its exit code.
+func (p *process) wait() {
+	defer close(p.done)
+
+	err := p.cmd.Wait()
+	if err == nil {
+		return
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		p.exitCode = -1
+		p.err = err
+		return
+	}
+	// Report signals the same way a shell (and docker exec) does.
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		p.exitCode = 128 + int(status.Signal())
+		return
+	}
+	p.exitCode = exitErr.ExitCode()
+}
+
+func (p *process) Read(buf []byte) (int, error) {
+	n, err := p.stdout.Read(buf)
+	if err != nil && p.tty != nil && errors.Is(err, syscall.EIO) {
+		// Linux returns EIO from the master once the last slave fd is closed.
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Stderr returns the command's stderr, or nil when running under a PTY.
+func (p *process) Stderr() io.Reader {
+	if p.stderr == nil {
+		return nil
+	}
+	return p.stderr
+}
+
+func (p *process) Write(buf []byte) (int, error) {
+	return p.stdin.Write(buf)
+}
+
+func (p *process) Resize(_ context.Context, rows, cols int) error {
+	if p.tty == nil {
+		return nil
+	}
+	return pty.Setsize(p.tty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// CloseWrite signals EOF to the command's stdin. Under a PTY this sends the
+// terminal EOF character, since the master cannot be half-closed.
+func (p *process) CloseWrite() error {
+	var err error
+	p.closeWriteOnce.Do(func() {
+		if p.tty != nil {
+			_, err = p.tty.Write([]byte{4})
+			return
+		}
+		err = p.stdin.Close()
+	})
+	return err
+}
+
+// Close terminates the command and releases its file descriptors.
+func (p *process) Close() error {
+	p.closeOnce.Do(func() {
+		select {
+		case <-p.done:
+		default:
+			p.terminate()
+		}
+
+		if p.tty != nil {
+			_ = p.tty.Close()
+			return
+		}
+		_ = p.stdin.Close()
+		_ = p.stdout.Close()
+		_ = p.stderr.Close()
+	})
+	return nil
+}
+
+func (p *process) Wait(ctx context.Context) (int, error) {
+	select {
+	case <-p.done:
+		return p.exitCode, p.err
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+}
+
+var (
+	_ sandbox.PTY    = (*process)(nil)
+	_ sandbox.Stream = (*process)(nil)
+)
@@ -0,0 +1,25 @@
+//go:build unix
+
+package local
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs cmd in its own process group so Close also stops
+// any children.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminate stops the process and everything in its group. A PTY session
+// gets a hangup like a closed terminal would send; a piped command is
+// killed outright.
+func (p *process) terminate() {
+	sig := syscall.SIGKILL
+	if p.tty != nil {
+		sig = syscall.SIGHUP
+	}
+	_ = syscall.Kill(-p.cmd.Process.Pid, sig)
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package local
+
+import "os/exec"
+
+// setProcessGroup does nothing on Windows, which has no process groups.
+func setProcessGroup(_ *exec.Cmd) {}
+
+// terminate kills the process. Its children are left running.
+func (p *process) terminate() {
+	_ = p.cmd.Process.Kill()
+}
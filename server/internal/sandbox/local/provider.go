@@ -8,7 +8,6 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
@@ -150,6 +149,12 @@ func (p *Provider) Create(_ context.Context, sessionID string, opts sandbox.Crea
 	if opts.WorkspaceCommit != "" {
 		env["WORKSPACE_COMMIT"] = opts.WorkspaceCommit
 	}
+	if opts.WorkspaceSubmodules {
+		env["WORKSPACE_SUBMODULES"] = "1"
+	}
+	if opts.WorkspaceLFS {
+		env["WORKSPACE_LFS"] = "1"
+	}
 
 	// Create process info (not started yet)
 	now := time.Now()
@@ -464,23 +469,15 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 		return nil, fmt.Errorf("command is required")
 	}
 
-	// Create command
-	execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
-	execCmd.Dir = info.workspacePath
-
-	// Set working directory if specified
-	if opts.WorkDir != "" {
-		execCmd.Dir = filepath.Join(info.workspacePath, opts.WorkDir)
+	dir, err := resolveWorkDir(info.workspacePath, opts.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
 	}
 
-	// Set environment
-	execCmd.Env = os.Environ()
-	for k, v := range info.env {
-		execCmd.Env = append(execCmd.Env, fmt.Sprintf("%s=%s", k, v))
-	}
-	for k, v := range opts.Env {
-		execCmd.Env = append(execCmd.Env, fmt.Sprintf("%s=%s", k, v))
-	}
+	// Create command
+	execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	execCmd.Dir = dir
+	execCmd.Env = commandEnv(info, opts.Env)
 
 	// Set stdin if provided
 	if opts.Stdin != nil {
@@ -489,7 +486,6 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 
 	// Capture stdout and stderr
 	var stdout, stderr []byte
-	var err error
 
 	stdout, err = execCmd.Output()
 	if err != nil {
@@ -511,41 +507,75 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 	}, nil
 }
 
-// Attach creates an interactive PTY session (not supported for local provider).
-// Returns an error result as if the command failed, not the provider itself.
-func (p *Provider) Attach(_ context.Context, sessionID string, _ sandbox.AttachOptions) (sandbox.PTY, error) {
+// Attach creates an interactive PTY session running a host process in the workspace directory.
+// The process runs as the server's user; opts.User is ignored.
+func (p *Provider) Attach(_ context.Context, sessionID string, opts sandbox.AttachOptions) (sandbox.PTY, error) {
 	p.processesMu.RLock()
-	_, exists := p.processes[sessionID]
+	info, exists := p.processes[sessionID]
 	p.processesMu.RUnlock()
 
 	if !exists {
 		return nil, sandbox.ErrNotFound
 	}
 
-	// Return a fake PTY that immediately fails with a helpful error message
-	return &unsupportedPTY{
-		errorMessage: "PTY/terminal access is not supported for local sandbox provider.\n" +
-			"Use the Docker provider if you need terminal access.\n" +
-			"For local provider, use your system terminal to work in the workspace directory.\n",
-	}, nil
+	cmd := opts.Cmd
+	if len(cmd) == 0 {
+		cmd = defaultShell()
+	}
+
+	env := map[string]string{"TERM": defaultTerm}
+	for k, v := range opts.Env {
+		env[k] = v
+	}
+
+	execCmd := exec.Command(cmd[0], cmd[1:]...)
+	execCmd.Dir = info.workspacePath
+	execCmd.Env = commandEnv(info, env)
+
+	proc, err := startPTY(execCmd, opts.Rows, opts.Cols)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrAttachFailed, err)
+	}
+
+	return proc, nil
 }
 
-// ExecStream runs a command with bidirectional streaming (not supported for local provider).
-// Returns an error result as if the command failed, not the provider itself.
-func (p *Provider) ExecStream(_ context.Context, sessionID string, _ []string, _ sandbox.ExecStreamOptions) (sandbox.Stream, error) {
+// ExecStream runs a host process in the workspace directory with bidirectional streaming.
+// With opts.TTY the process runs under a PTY; otherwise stdout and stderr are separate.
+// The process runs as the server's user; opts.User is ignored.
+func (p *Provider) ExecStream(_ context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error) {
 	p.processesMu.RLock()
-	_, exists := p.processes[sessionID]
+	info, exists := p.processes[sessionID]
 	p.processesMu.RUnlock()
 
 	if !exists {
 		return nil, sandbox.ErrNotFound
 	}
 
-	// Return a fake stream that immediately fails with a helpful error message
-	return &unsupportedStream{
-		errorMessage: "Streaming command execution is not supported for local sandbox provider.\n" +
-			"Use the Docker provider if you need streaming features (SFTP, port forwarding, etc.).\n",
-	}, nil
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("command is required")
+	}
+
+	dir, err := resolveWorkDir(info.workspacePath, opts.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
+	}
+
+	execCmd := exec.Command(cmd[0], cmd[1:]...)
+	execCmd.Dir = dir
+	execCmd.Env = commandEnv(info, opts.Env)
+
+	var proc *process
+	if opts.TTY {
+		proc, err = startPTY(execCmd, 0, 0)
+	} else {
+		proc, err = startPipes(execCmd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
+	}
+
+	return proc, nil
 }
 
 // HTTPClient returns an HTTP client configured to communicate with the sandbox.
@@ -669,119 +699,3 @@ func hashSecret(secret string) string {
 	// Return salt:hash in hex format
 	return fmt.Sprintf("%s:%s", hex.EncodeToString(salt), hex.EncodeToString(hash))
 }
-
-// unsupportedPTY is a fake PTY that immediately returns an error message.
-type unsupportedPTY struct {
-	errorMessage string
-	closed       bool
-	mu           sync.Mutex
-}
-
-func (p *unsupportedPTY) Read(buf []byte) (int, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if p.closed {
-		return 0, fmt.Errorf("PTY closed")
-	}
-
-	if len(p.errorMessage) == 0 {
-		return 0, fmt.Errorf("PTY closed")
-	}
-
-	// Return the error message once
-	n := copy(buf, []byte(p.errorMessage))
-	p.errorMessage = ""
-	return n, nil
-}
-
-func (p *unsupportedPTY) Write(buf []byte) (int, error) {
-	// Silently discard writes
-	return len(buf), nil
-}
-
-func (p *unsupportedPTY) Resize(_ context.Context, _, _ int) error {
-	// Silently ignore resize requests
-	return nil
-}
-
-func (p *unsupportedPTY) Close() error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.closed = true
-	return nil
-}
-
-func (p *unsupportedPTY) Wait(_ context.Context) (int, error) {
-	// Return immediately with exit code 1 (failure)
-	return 1, nil
-}
-
-// unsupportedStream is a fake stream that immediately returns an error message.
-type unsupportedStream struct {
-	errorMessage string
-	closed       bool
-	mu           sync.Mutex
-}
-
-func (s *unsupportedStream) Read(_ []byte) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.closed {
-		return 0, fmt.Errorf("stream closed")
-	}
-
-	// Return empty - all output goes to stderr
-	return 0, fmt.Errorf("stream closed")
-}
-
-func (s *unsupportedStream) Stderr() io.Reader {
-	// Return the error message via stderr
-	return &errorReader{message: s.errorMessage}
-}
-
-func (s *unsupportedStream) Write(buf []byte) (int, error) {
-	// Silently discard writes
-	return len(buf), nil
-}
-
-func (s *unsupportedStream) Resize(_ context.Context, _, _ int) error {
-	return nil
-}
-
-func (s *unsupportedStream) CloseWrite() error {
-	return nil
-}
-
-func (s *unsupportedStream) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.closed = true
-	return nil
-}
-
-func (s *unsupportedStream) Wait(_ context.Context) (int, error) {
-	// Return immediately with exit code 1 (failure)
-	return 1, nil
-}
-
-// errorReader is a simple reader that returns an error message once.
-type errorReader struct {
-	message string
-	read    bool
-	mu      sync.Mutex
-}
-
-func (r *errorReader) Read(buf []byte) (int, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if r.read {
-		return 0, fmt.Errorf("EOF")
-	}
-
-	n := copy(buf, []byte(r.message))
-	r.read = true
-	return n, nil
-}
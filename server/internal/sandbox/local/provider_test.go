@@ -0,0 +1,268 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+func newTestProvider(t *testing.T) (*Provider, string) {
+	t.Helper()
+
+	p, err := NewProvider(&config.Config{LocalAgentBinary: "sh"})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+
+	workspace := t.TempDir()
+	if _, err := p.Create(context.Background(), "session-1", sandbox.CreateOptions{WorkspacePath: workspace}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return p, workspace
+}
+
+func waitExit(t *testing.T, w interface {
+	Wait(context.Context) (int, error)
+}) int {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code, err := w.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	return code
+}
+
+func TestAttach(t *testing.T) {
+	p, workspace := newTestProvider(t)
+
+	pty, err := p.Attach(context.Background(), "session-1", sandbox.AttachOptions{
+		Cmd:  []string{"sh", "-c", `pwd; echo "term=$TERM foo=$FOO"; stty size; read line; echo "got $line"; exit 3`},
+		Rows: 24,
+		Cols: 80,
+		Env:  map[string]string{"FOO": "bar"},
+	})
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	defer pty.Close()
+
+	if _, err := pty.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	out, _ := io.ReadAll(pty)
+	if code := waitExit(t, pty); code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+
+	// macOS temp dirs live behind a /var -> /private/var symlink.
+	realWorkspace, _ := filepath.EvalSymlinks(workspace)
+	for _, want := range []string{realWorkspace, "term=xterm-256color foo=bar", "24 80", "got hello"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestAttachResize(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	pty, err := p.Attach(context.Background(), "session-1", sandbox.AttachOptions{
+		Cmd:  []string{"sh", "-c", "read line; stty size"},
+		Rows: 24,
+		Cols: 80,
+	})
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	defer pty.Close()
+
+	if err := pty.Resize(context.Background(), 50, 132); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	if _, err := pty.Write([]byte("\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	out, _ := io.ReadAll(pty)
+	if !strings.Contains(string(out), "50 132") {
+		t.Errorf("output %q does not contain resized dimensions", out)
+	}
+	waitExit(t, pty)
+}
+
+func TestAttachClose(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	pty, err := p.Attach(context.Background(), "session-1", sandbox.AttachOptions{
+		Cmd: []string{"sleep", "60"},
+	})
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	if err := pty.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if code := waitExit(t, pty); code == 0 {
+		t.Error("expected non-zero exit code after Close")
+	}
+}
+
+func TestAttachNotFound(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	if _, err := p.Attach(context.Background(), "missing", sandbox.AttachOptions{}); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("Attach error = %v, want ErrNotFound", err)
+	}
+	if _, err := p.ExecStream(context.Background(), "missing", []string{"true"}, sandbox.ExecStreamOptions{}); !errors.Is(err, sandbox.ErrNotFound) {
+		t.Errorf("ExecStream error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestExecStream(t *testing.T) {
+	p, workspace := newTestProvider(t)
+	if err := os.Mkdir(filepath.Join(workspace, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := p.ExecStream(context.Background(), "session-1",
+		[]string{"sh", "-c", `cat; basename "$(pwd)"; echo oops >&2; exit 7`},
+		sandbox.ExecStreamOptions{WorkDir: "sub"})
+	if err != nil {
+		t.Fatalf("ExecStream: %v", err)
+	}
+	defer stream.Close()
+
+	if _, err := stream.Write([]byte("input\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite: %v", err)
+	}
+
+	stderr := stream.Stderr()
+	if stderr == nil {
+		t.Fatal("Stderr() = nil for non-TTY stream")
+	}
+
+	var errOut bytes.Buffer
+	errDone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&errOut, stderr)
+		close(errDone)
+	}()
+
+	out, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	<-errDone
+
+	if got, want := string(out), "input\nsub\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := errOut.String(), "oops\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+	if code := waitExit(t, stream); code != 7 {
+		t.Errorf("exit code = %d, want 7", code)
+	}
+}
+
+func TestExecStreamTTY(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	stream, err := p.ExecStream(context.Background(), "session-1",
+		[]string{"sh", "-c", "test -t 0 && echo tty; echo oops >&2"},
+		sandbox.ExecStreamOptions{TTY: true})
+	if err != nil {
+		t.Fatalf("ExecStream: %v", err)
+	}
+	defer stream.Close()
+
+	if stream.Stderr() != nil {
+		t.Error("Stderr() should be nil for TTY stream")
+	}
+
+	out, _ := io.ReadAll(stream)
+	if !strings.Contains(string(out), "tty") || !strings.Contains(string(out), "oops") {
+		t.Errorf("output %q should contain merged stdout and stderr", out)
+	}
+	if code := waitExit(t, stream); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+}
+
+func TestExecStreamClose(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	stream, err := p.ExecStream(context.Background(), "session-1", []string{"sh", "-c", "sleep 60"}, sandbox.ExecStreamOptions{})
+	if err != nil {
+		t.Fatalf("ExecStream: %v", err)
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if code := waitExit(t, stream); code != 128+9 {
+		t.Errorf("exit code = %d, want %d", code, 128+9)
+	}
+}
+
+func TestWorkDirOutsideWorkspace(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	_, err := p.ExecStream(context.Background(), "session-1", []string{"true"}, sandbox.ExecStreamOptions{WorkDir: "../.."})
+	if !errors.Is(err, sandbox.ErrExecFailed) {
+		t.Errorf("ExecStream error = %v, want ErrExecFailed", err)
+	}
+
+	_, err = p.Exec(context.Background(), "session-1", []string{"true"}, sandbox.ExecOptions{WorkDir: "../"})
+	if !errors.Is(err, sandbox.ErrExecFailed) {
+		t.Errorf("Exec error = %v, want ErrExecFailed", err)
+	}
+}
+
+func TestResolveWorkDir(t *testing.T) {
+	tests := []struct {
+		workDir string
+		want    string
+		wantErr bool
+	}{
+		{workDir: "", want: "/ws"},
+		{workDir: "src", want: "/ws/src"},
+		{workDir: "/src", want: "/ws/src"},
+		{workDir: "src/../lib", want: "/ws/lib"},
+		{workDir: "..", wantErr: true},
+		{workDir: "../other", wantErr: true},
+		{workDir: "/../etc", wantErr: true},
+		{workDir: "..foo", want: "/ws/..foo"},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveWorkDir("/ws", tt.workDir)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("resolveWorkDir(%q) = %q, want error", tt.workDir, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveWorkDir(%q) = %q, %v; want %q", tt.workDir, got, err, tt.want)
+		}
+	}
+}
@@ -340,6 +340,36 @@ func (p *ProviderProxy) HTTPClient(ctx context.Context, sessionID string) (*http
 	return provider.HTTPClient(ctx, sessionID)
 }
 
+// CloneVolume clones a data volume using the provider of the source session.
+// Both sessions must use the same provider.
+// Returns ErrNotSupported if that provider does not implement VolumeCloner.
+func (p *ProviderProxy) CloneVolume(ctx context.Context, sourceSessionID, targetSessionID string) error {
+	providerName, err := p.providerGetter(ctx, sourceSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	targetProviderName, err := p.providerGetter(ctx, targetSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get provider for session: %w", err)
+	}
+	if targetProviderName != providerName {
+		return fmt.Errorf("%w: cannot clone a volume from provider %q to provider %q", ErrNotSupported, providerName, targetProviderName)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	cloner, ok := provider.(VolumeCloner)
+	if !ok {
+		return fmt.Errorf("%w: provider %q cannot clone volumes", ErrNotSupported, providerName)
+	}
+
+	return cloner.CloneVolume(ctx, sourceSessionID, targetSessionID)
+}
+
 // Watch watches all providers and merges events.
 func (p *ProviderProxy) Watch(ctx context.Context) (<-chan StateEvent, error) {
 	merged := make(chan StateEvent, 100)
@@ -29,6 +29,11 @@ type Provider struct {
 	secrets   map[string]string // sessionID -> raw secret
 	image     string            // configured sandbox image
 
+	// volumes simulates per-session data volumes: sessionID -> path -> contents.
+	// Like Docker volumes, they survive Remove unless sandbox.RemoveVolumes() is passed.
+	volumes    map[string]map[string][]byte
+	createOpts map[string]sandbox.CreateOptions // sessionID -> options from the last Create
+
 	// Event subscribers for Watch functionality
 	subscribersMu sync.RWMutex
 	subscribers   []*eventSubscriber
@@ -38,33 +43,38 @@ type Provider struct {
 	HTTPHandler http.Handler
 
 	// Configurable behaviors for testing
-	CreateFunc     func(ctx context.Context, sessionID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error)
-	StartFunc      func(ctx context.Context, sessionID string) error
-	StopFunc       func(ctx context.Context, sessionID string, timeout time.Duration) error
-	RemoveFunc     func(ctx context.Context, sessionID string, opts ...sandbox.RemoveOption) error
-	GetFunc        func(ctx context.Context, sessionID string) (*sandbox.Sandbox, error)
-	GetSecretFunc  func(ctx context.Context, sessionID string) (string, error)
-	ExecFunc       func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error)
-	AttachFunc     func(ctx context.Context, sessionID string, opts sandbox.AttachOptions) (sandbox.PTY, error)
-	ExecStreamFunc func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error)
-	WatchFunc      func(ctx context.Context) (<-chan sandbox.StateEvent, error)
+	CreateFunc      func(ctx context.Context, sessionID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error)
+	StartFunc       func(ctx context.Context, sessionID string) error
+	StopFunc        func(ctx context.Context, sessionID string, timeout time.Duration) error
+	RemoveFunc      func(ctx context.Context, sessionID string, opts ...sandbox.RemoveOption) error
+	GetFunc         func(ctx context.Context, sessionID string) (*sandbox.Sandbox, error)
+	GetSecretFunc   func(ctx context.Context, sessionID string) (string, error)
+	ExecFunc        func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error)
+	AttachFunc      func(ctx context.Context, sessionID string, opts sandbox.AttachOptions) (sandbox.PTY, error)
+	ExecStreamFunc  func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error)
+	WatchFunc       func(ctx context.Context) (<-chan sandbox.StateEvent, error)
+	CloneVolumeFunc func(ctx context.Context, sourceSessionID, targetSessionID string) error
 }
 
 // NewProvider creates a new mock provider with default behavior.
 func NewProvider() *Provider {
 	return &Provider{
-		sandboxes: make(map[string]*sandbox.Sandbox),
-		secrets:   make(map[string]string),
-		image:     DefaultMockImage,
+		sandboxes:  make(map[string]*sandbox.Sandbox),
+		secrets:    make(map[string]string),
+		image:      DefaultMockImage,
+		volumes:    make(map[string]map[string][]byte),
+		createOpts: make(map[string]sandbox.CreateOptions),
 	}
 }
 
 // NewProviderWithImage creates a new mock provider with a specific image.
 func NewProviderWithImage(image string) *Provider {
 	return &Provider{
-		sandboxes: make(map[string]*sandbox.Sandbox),
-		secrets:   make(map[string]string),
-		image:     image,
+		sandboxes:  make(map[string]*sandbox.Sandbox),
+		secrets:    make(map[string]string),
+		image:      image,
+		volumes:    make(map[string]map[string][]byte),
+		createOpts: make(map[string]sandbox.CreateOptions),
 	}
 }
 
@@ -95,6 +105,12 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 	if opts.SharedSecret != "" {
 		p.secrets[sessionID] = opts.SharedSecret
 	}
+	p.createOpts[sessionID] = opts
+
+	// Create the data volume unless it survived a previous Remove or was cloned
+	if _, exists := p.volumes[sessionID]; !exists {
+		p.volumes[sessionID] = make(map[string][]byte)
+	}
 
 	// Always simulate port 3002 assignment (deterministic for testing)
 	ports := []sandbox.AssignedPort{
@@ -205,6 +221,11 @@ func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	// A volume can exist without a sandbox (e.g. cloned but never created)
+	if cfg.RemoveVolumes {
+		delete(p.volumes, sessionID)
+	}
+
 	if _, exists := p.sandboxes[sessionID]; !exists {
 		return nil // Idempotent
 	}
@@ -266,6 +287,31 @@ func (p *Provider) GetSecret(ctx context.Context, sessionID string) (string, err
 	return secret, nil
 }
 
+// CloneVolume copies the source session's data volume to the target session.
+func (p *Provider) CloneVolume(ctx context.Context, sourceSessionID, targetSessionID string) error {
+	if p.CloneVolumeFunc != nil {
+		return p.CloneVolumeFunc(ctx, sourceSessionID, targetSessionID)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	src, exists := p.volumes[sourceSessionID]
+	if !exists {
+		return sandbox.ErrNotFound
+	}
+	if _, exists := p.volumes[targetSessionID]; exists {
+		return sandbox.ErrAlreadyExists
+	}
+
+	dst := make(map[string][]byte, len(src))
+	for path, data := range src {
+		dst[path] = append([]byte(nil), data...)
+	}
+	p.volumes[targetSessionID] = dst
+	return nil
+}
+
 // Exec runs a mock command.
 func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
 	if p.ExecFunc != nil {
@@ -382,6 +428,45 @@ func (p *Provider) GetSandboxes() map[string]*sandbox.Sandbox {
 	return result
 }
 
+// SetVolumeFile writes a file to a session's simulated data volume, creating
+// the volume if needed (for test setup).
+func (p *Provider) SetVolumeFile(sessionID, path string, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.volumes[sessionID]; !exists {
+		p.volumes[sessionID] = make(map[string][]byte)
+	}
+	p.volumes[sessionID][path] = append([]byte(nil), data...)
+}
+
+// GetVolume returns a copy of a session's simulated data volume and whether it
+// exists (for test assertions).
+func (p *Provider) GetVolume(sessionID string) (map[string][]byte, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	vol, exists := p.volumes[sessionID]
+	if !exists {
+		return nil, false
+	}
+	result := make(map[string][]byte, len(vol))
+	for path, data := range vol {
+		result[path] = append([]byte(nil), data...)
+	}
+	return result, true
+}
+
+// GetCreateOptions returns the options passed to the last Create for a session
+// (for test assertions).
+func (p *Provider) GetCreateOptions(sessionID string) (sandbox.CreateOptions, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	opts, exists := p.createOpts[sessionID]
+	return opts, exists
+}
+
 // SetSandboxPort overrides the port mapping for a sandbox to point to a mock server.
 // This is useful for testing to redirect sandbox traffic to a test HTTP server.
 func (p *Provider) SetSandboxPort(sessionID string, host string, port int) {
@@ -103,6 +103,17 @@ type DockerProxyProvider interface {
 	DockerTransport(projectID string) (http.RoundTripper, error)
 }
 
+// VolumeCloner is an optional interface that sandbox providers can implement
+// to copy a session's data volume to another session. This is used to fork
+// sessions: the new session's sandbox starts from the source's filesystem state.
+type VolumeCloner interface {
+	// CloneVolume copies the data volume of sourceSessionID into a new data volume
+	// for targetSessionID. The source sandbox may be running.
+	// Returns ErrNotFound if the source has no data volume and ErrAlreadyExists
+	// if the target already has one. A failed copy leaves no target volume behind.
+	CloneVolume(ctx context.Context, sourceSessionID, targetSessionID string) error
+}
+
 // ProviderStatus represents the current status of a sandbox provider.
 type ProviderStatus struct {
 	Available bool   `json:"available"`
@@ -222,6 +233,22 @@ type CreateOptions struct {
 	// Set as WORKSPACE_COMMIT environment variable.
 	WorkspaceCommit string
 
+	// WorkspaceSubmodules makes the agent initialize the workspace's
+	// submodules recursively. Set as WORKSPACE_SUBMODULES=1.
+	WorkspaceSubmodules bool
+
+	// WorkspaceLFS makes the agent download the workspace's Git LFS objects
+	// through the proxy. Set as WORKSPACE_LFS=1.
+	WorkspaceLFS bool
+
+	// ForkedFrom is the session whose data volume was cloned for this sandbox (optional).
+	// Set as FORKED_FROM_SESSION_ID so the agent adopts that session's filesystem state.
+	ForkedFrom string
+
+	// ForkMessageID is the last chat message kept when the session was forked (optional).
+	// Set as FORK_MESSAGE_ID; the agent drops later messages from the cloned transcript.
+	ForkMessageID string
+
 	// Resources defines resource limits for the sandbox.
 	Resources ResourceConfig
 }
@@ -177,6 +177,23 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 	return dockerProv.Create(ctx, sessionID, opts)
 }
 
+// CloneVolume copies a session's data volume within the project's VM.
+// Sessions can only be forked within a project, so both volumes live in the same VM.
+func (p *Provider) CloneVolume(ctx context.Context, sourceSessionID, targetSessionID string) error {
+	projectID, err := p.sessionProjectResolver(ctx, sourceSessionID)
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve project for session %s: %v", sandbox.ErrNotFound, sourceSessionID, err)
+	}
+
+	// The VM may have been shut down while idle; the volume is still on its disk
+	dockerProv, err := p.getOrCreateDockerProvider(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to get docker provider: %w", err)
+	}
+
+	return dockerProv.CloneVolume(ctx, sourceSessionID, targetSessionID)
+}
+
 // Start starts a sandbox.
 func (p *Provider) Start(ctx context.Context, sessionID string) error {
 	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 
+	"github.com/google/uuid"
+
 	"github.com/obot-platform/discobot/server/internal/events"
 	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/model"
@@ -53,6 +56,8 @@ type NewSessionRequest struct {
 	Model       string
 	Reasoning   string
 	Mode        string
+	// Name is the session name; if empty it is derived from Messages
+	Name string
 	// Messages is the raw UIMessage array - passed through without parsing
 	Messages json.RawMessage
 }
@@ -67,6 +72,27 @@ type CancelCompletionResponse struct {
 // ErrNoActiveCompletion is returned when attempting to cancel with no active completion.
 var ErrNoActiveCompletion = errors.New("no active completion to cancel")
 
+// ForkSessionRequest contains the parameters for forking a chat session.
+type ForkSessionRequest struct {
+	ProjectID       string
+	SourceSessionID string
+	// SessionID is the ID for the new session (optional, generated if empty)
+	SessionID string
+	// MessageID is the last message to keep (optional, keeps all if empty)
+	MessageID string
+	// Name is the new session's name (optional, defaults to the source's name)
+	Name string
+}
+
+// ErrForkMessageNotFound is returned when the fork point is not in the session's history.
+var ErrForkMessageNotFound = errors.New("message not found in session history")
+
+// ErrSessionExists is returned when a session with the requested ID already exists.
+var ErrSessionExists = errors.New("session already exists")
+
+// ErrInvalidSessionID is returned when a requested session ID is not valid.
+var ErrInvalidSessionID = errors.New("invalid session ID")
+
 // NewSession creates a new chat session and enqueues initialization.
 // Uses the client-provided session ID.
 func (c *ChatService) NewSession(ctx context.Context, req NewSessionRequest) (string, error) {
@@ -93,7 +119,10 @@ func (c *ChatService) NewSession(ctx context.Context, req NewSessionRequest) (st
 	}
 
 	// Try to derive session name from first user message text
-	name := deriveSessionName(req.Messages)
+	name := req.Name
+	if name == "" {
+		name = deriveSessionName(req.Messages)
+	}
 
 	// Use SessionService to create the session with client-provided ID
 	sess, err := c.sessionService.CreateSessionWithID(ctx, req.SessionID, req.ProjectID, req.WorkspaceID, name, req.AgentID, req.Model, req.Reasoning, req.Mode)
@@ -115,6 +144,74 @@ func (c *ChatService) NewSession(ctx context.Context, req NewSessionRequest) (st
 	return sess.ID, nil
 }
 
+// ForkSession creates a new session from the state of an existing one and
+// enqueues its initialization. The new session gets a copy of the source's
+// sandbox data (workspace changes and agent state) and its chat history up to
+// and including req.MessageID.
+// The source sandbox is started if needed to read its history.
+func (c *ChatService) ForkSession(ctx context.Context, req ForkSessionRequest) (*Session, error) {
+	source, err := c.GetSession(ctx, req.ProjectID, req.SourceSessionID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Status == model.SessionStatusRemoving || source.Status == model.SessionStatusRemoved {
+		return nil, fmt.Errorf("session is being deleted")
+	}
+	if source.WorkspacePath == nil || *source.WorkspacePath == "" {
+		return nil, fmt.Errorf("session has not been initialized")
+	}
+
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	} else if err := ValidateSessionID(sessionID); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSessionID, err)
+	}
+	if _, err := c.store.GetSessionByID(ctx, sessionID); err == nil {
+		return nil, ErrSessionExists
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	history, err := c.GetMessages(ctx, req.ProjectID, req.SourceSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if req.MessageID != "" {
+		idx := slices.IndexFunc(history, func(m sandboxapi.UIMessage) bool { return m.ID == req.MessageID })
+		if idx < 0 {
+			return nil, ErrForkMessageNotFound
+		}
+		history = history[:idx+1]
+	}
+
+	name := req.Name
+	if name == "" {
+		name = source.Name
+	}
+
+	sess, err := c.sessionService.ForkSession(ctx, source, sessionID, name, history)
+	if err != nil {
+		return nil, err
+	}
+
+	agentID := ""
+	if source.AgentID != nil {
+		agentID = *source.AgentID
+	}
+	if err := c.jobEnqueuer.Enqueue(ctx, jobs.SessionInitPayload{
+		ProjectID:   req.ProjectID,
+		SessionID:   sess.ID,
+		WorkspaceID: source.WorkspaceID,
+		AgentID:     agentID,
+	}); err != nil {
+		// Log but don't fail - session was created, init can be retried
+		log.Printf("Warning: failed to enqueue session init for %s: %v", sess.ID, err)
+	}
+
+	return sess, nil
+}
+
 // GetSession retrieves a session and validates it belongs to the project.
 func (c *ChatService) GetSession(ctx context.Context, projectID, sessionID string) (*model.Session, error) {
 	sess, err := c.store.GetSessionByID(ctx, sessionID)
@@ -326,6 +423,34 @@ func (c *ChatService) SendToSandbox(ctx context.Context, projectID, sessionID st
 	return outerCh, nil
 }
 
+// StartPrompt sends text as a user message to the session's agent and
+// returns its message ID once the agent started working on it. The
+// completion keeps running without a client, and the session status poller
+// marks the session ready when it ends. Cancelling ctx stops waiting for the
+// session to initialize and the agent to respond.
+func (c *ChatService) StartPrompt(ctx context.Context, projectID, sessionID, text, requestModel, mode string) (string, error) {
+	messageID := uuid.New().String()
+	messages, err := buildUserMessage(messageID, text)
+	if err != nil {
+		return "", fmt.Errorf("failed to build message: %w", err)
+	}
+
+	sendCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.SendToSandbox(sendCtx, projectID, sessionID, messages, requestModel, "", mode)
+	if err != nil {
+		return "", err
+	}
+	_, ok := <-stream
+	cancel()
+	for range stream {
+	}
+	if !ok {
+		return "", fmt.Errorf("agent closed the stream without responding")
+	}
+	return messageID, nil
+}
+
 // GetStream returns a channel of SSE events for an in-progress completion.
 // If no completion is in progress, returns an empty closed channel.
 // This is used by the resume endpoint to catch up on events.
@@ -346,12 +471,39 @@ func (c *ChatService) GetStream(ctx context.Context, projectID, sessionID string
 }
 
 // GetMessages returns all messages for a session by querying the sandbox.
-// The sandbox is automatically reconciled if not running.
-// Returns an error if the sandbox cannot be reached after reconciliation.
+// The sandbox is automatically reconciled if not running. A stopped session
+// with a stored transcript is served from the database without booting its
+// sandbox, and the database is the fallback when the sandbox cannot be reached.
 func (c *ChatService) GetMessages(ctx context.Context, projectID, sessionID string) ([]sandboxapi.UIMessage, error) {
-	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+	session, err := c.GetSession(ctx, projectID, sessionID)
+	if err != nil {
 		return nil, err
 	}
+
+	if session.Status == model.SessionStatusStopped {
+		stored, mirrored, err := c.storedMessages(ctx, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stored messages: %w", err)
+		}
+		if mirrored {
+			return stored, nil
+		}
+	}
+
+	messages, err := c.getSandboxMessages(ctx, sessionID)
+	if err != nil {
+		stored, _, storedErr := c.storedMessages(ctx, sessionID)
+		if storedErr != nil || len(stored) == 0 {
+			return nil, err
+		}
+		log.Printf("[Chat] Sandbox unavailable for session %s, serving stored messages: %v", sessionID, err)
+		return stored, nil
+	}
+	return messages, nil
+}
+
+// getSandboxMessages returns the session's messages from its sandbox.
+func (c *ChatService) getSandboxMessages(ctx context.Context, sessionID string) ([]sandboxapi.UIMessage, error) {
 	if c.sandboxService == nil {
 		return nil, fmt.Errorf("sandbox provider not available")
 	}
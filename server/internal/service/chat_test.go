@@ -1,9 +1,19 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
 )
 
 func TestDeriveSessionName(t *testing.T) {
@@ -233,3 +243,195 @@ func TestDeriveSessionName_RealWorldExamples(t *testing.T) {
 		})
 	}
 }
+
+// recordingEnqueuer records enqueued job payloads.
+type recordingEnqueuer struct {
+	mu       sync.Mutex
+	payloads []jobs.JobPayload
+}
+
+func (e *recordingEnqueuer) Enqueue(_ context.Context, payload jobs.JobPayload) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.payloads = append(e.payloads, payload)
+	return nil
+}
+
+// newForkTestEnv creates a ready source session whose sandbox serves history.
+func newForkTestEnv(t *testing.T, history []sandboxapi.UIMessage) (*testEnv, *ChatService, *recordingEnqueuer, *model.Session) {
+	t.Helper()
+
+	env := newTestEnv(t)
+	t.Cleanup(env.cleanup)
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+	source := env.createTestSession(t, project.ID, workspace.ID, agent.ID, commit)
+	source.WorkspacePath = ptrString(workspace.Path)
+	source.WorkspaceCommit = ptrString(commit)
+	if err := env.store.UpdateSession(context.Background(), source); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	env.mockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/chat") && r.Method == "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sandboxapi.GetMessagesResponse{Messages: history})
+			return
+		}
+		http.NotFound(w, r)
+	})
+	if _, err := env.mockSandbox.Create(context.Background(), source.ID, sandbox.CreateOptions{SharedSecret: "secret"}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(context.Background(), source.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+	env.mockSandbox.SetVolumeFile(source.ID, "discobot/workspace/main.go", []byte("package main"))
+
+	enqueuer := &recordingEnqueuer{}
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sandboxSvc.SetSessionInitializer(&testSessionInitializer{})
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, enqueuer)
+	chatSvc := NewChatService(env.store, sessionSvc, enqueuer, env.eventBroker, sandboxSvc, env.gitService)
+
+	return env, chatSvc, enqueuer, source
+}
+
+func testHistory() []sandboxapi.UIMessage {
+	return []sandboxapi.UIMessage{
+		{ID: "user-1", Role: "user", Parts: json.RawMessage(`[{"type":"text","text":"first"}]`)},
+		{ID: "asst-1", Role: "assistant", Parts: json.RawMessage(`[{"type":"text","text":"one"}]`)},
+		{ID: "user-2", Role: "user", Parts: json.RawMessage(`[{"type":"text","text":"second"}]`)},
+		{ID: "asst-2", Role: "assistant", Parts: json.RawMessage(`[{"type":"text","text":"two"}]`)},
+	}
+}
+
+func TestForkSession(t *testing.T) {
+	env, chatSvc, enqueuer, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	forked, err := chatSvc.ForkSession(ctx, ForkSessionRequest{
+		ProjectID:       source.ProjectID,
+		SourceSessionID: source.ID,
+		SessionID:       "forked-session",
+		MessageID:       "asst-1",
+	})
+	if err != nil {
+		t.Fatalf("ForkSession failed: %v", err)
+	}
+
+	if forked.ID != "forked-session" || forked.ForkedFrom != source.ID || forked.ForkMessageID != "asst-1" {
+		t.Errorf("unexpected fork metadata: id=%s forkedFrom=%s forkMessageId=%s", forked.ID, forked.ForkedFrom, forked.ForkMessageID)
+	}
+	if forked.Name != source.Name || forked.Status != model.SessionStatusInitializing {
+		t.Errorf("unexpected name/status: %s/%s", forked.Name, forked.Status)
+	}
+	if forked.WorkspacePath != *source.WorkspacePath || forked.WorkspaceCommit != *source.WorkspaceCommit {
+		t.Errorf("workspace not inherited: %s@%s", forked.WorkspacePath, forked.WorkspaceCommit)
+	}
+
+	vol, ok := env.mockSandbox.GetVolume("forked-session")
+	if !ok || string(vol["discobot/workspace/main.go"]) != "package main" {
+		t.Errorf("data volume not cloned: %v", vol)
+	}
+
+	messages, err := env.store.ListMessagesBySession(ctx, "forked-session")
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 copied messages, got %d", len(messages))
+	}
+	if messages[0].Role != "user" || messages[1].Role != "assistant" || string(messages[1].Parts) != `[{"type":"text","text":"one"}]` {
+		t.Errorf("copied messages out of order or altered: %s %s", messages[0].Role, messages[1].Parts)
+	}
+	if messages[0].MessageID != "user-1" || messages[0].ID == "user-1" {
+		t.Errorf("expected a fresh row ID keeping the sandbox message ID, got %s/%s", messages[0].ID, messages[0].MessageID)
+	}
+
+	if len(enqueuer.payloads) != 1 {
+		t.Fatalf("expected 1 enqueued job, got %d", len(enqueuer.payloads))
+	}
+	payload, ok := enqueuer.payloads[0].(jobs.SessionInitPayload)
+	if !ok || payload.SessionID != "forked-session" || payload.WorkspaceID != source.WorkspaceID {
+		t.Errorf("unexpected init payload: %+v", enqueuer.payloads[0])
+	}
+}
+
+func TestForkSession_InitializePassesForkToSandbox(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	if _, err := chatSvc.ForkSession(ctx, ForkSessionRequest{
+		ProjectID:       source.ProjectID,
+		SourceSessionID: source.ID,
+		SessionID:       "forked-session",
+	}); err != nil {
+		t.Fatalf("ForkSession failed: %v", err)
+	}
+
+	if err := chatSvc.sessionService.Initialize(ctx, "forked-session"); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	opts, ok := env.mockSandbox.GetCreateOptions("forked-session")
+	if !ok {
+		t.Fatal("sandbox was not created for forked session")
+	}
+	if opts.ForkedFrom != source.ID || opts.ForkMessageID != "asst-2" {
+		t.Errorf("fork not passed to sandbox: forkedFrom=%s forkMessageId=%s", opts.ForkedFrom, opts.ForkMessageID)
+	}
+	if opts.WorkspaceCommit != *source.WorkspaceCommit {
+		t.Errorf("expected source commit %s, got %s", *source.WorkspaceCommit, opts.WorkspaceCommit)
+	}
+}
+
+func TestForkSession_Errors(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	_, err := chatSvc.ForkSession(ctx, ForkSessionRequest{
+		ProjectID:       source.ProjectID,
+		SourceSessionID: source.ID,
+		MessageID:       "missing",
+	})
+	if !errors.Is(err, ErrForkMessageNotFound) {
+		t.Errorf("expected ErrForkMessageNotFound, got %v", err)
+	}
+
+	_, err = chatSvc.ForkSession(ctx, ForkSessionRequest{
+		ProjectID:       source.ProjectID,
+		SourceSessionID: source.ID,
+		SessionID:       source.ID,
+	})
+	if !errors.Is(err, ErrSessionExists) {
+		t.Errorf("expected ErrSessionExists, got %v", err)
+	}
+
+	_, err = chatSvc.ForkSession(ctx, ForkSessionRequest{
+		ProjectID:       source.ProjectID,
+		SourceSessionID: source.ID,
+		SessionID:       "not/valid",
+	})
+	if !errors.Is(err, ErrInvalidSessionID) {
+		t.Errorf("expected ErrInvalidSessionID, got %v", err)
+	}
+
+	// A failed clone leaves no session behind
+	env.mockSandbox.CloneVolumeFunc = func(context.Context, string, string) error {
+		return sandbox.ErrNotSupported
+	}
+	_, err = chatSvc.ForkSession(ctx, ForkSessionRequest{
+		ProjectID:       source.ProjectID,
+		SourceSessionID: source.ID,
+		SessionID:       "forked-session",
+	})
+	if !errors.Is(err, sandbox.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if _, err := env.store.GetSessionByID(ctx, "forked-session"); err == nil {
+		t.Error("session should be removed after a failed clone")
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// Checkpoints are snapshots of a session's workspace taken by discobot-agent
+// inside the sandbox at the end of every chat completion, keyed by the ID of
+// the last message of that turn.
+
+const (
+	// agentBinary is the discobot-agent binary in the sandbox image.
+	agentBinary = "/opt/discobot/bin/discobot-agent"
+
+	// checkpointNotFoundExitCode is the exit code discobot-agent uses when
+	// the requested checkpoint does not exist.
+	checkpointNotFoundExitCode = 2
+)
+
+// ErrCheckpointNotFound is returned when no checkpoint was recorded for a message.
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// ErrCompletionRunning is returned when a restore is attempted while the
+// agent is still working on the session.
+var ErrCompletionRunning = errors.New("a chat completion is running")
+
+// Checkpoint is a recorded workspace state.
+type Checkpoint struct {
+	MessageID string    `json:"messageId"`
+	Seq       int       `json:"seq"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CheckpointFile is a file that differs between a checkpoint and the
+// current workspace. Status is relative to the checkpoint: "added" files
+// did not exist at the checkpoint.
+type CheckpointFile struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// CheckpointDiff compares a checkpoint with the current workspace.
+type CheckpointDiff struct {
+	Files []CheckpointFile `json:"files"`
+	Patch string           `json:"patch"`
+}
+
+// CheckpointRestoreResult lists the files a restore changed.
+type CheckpointRestoreResult struct {
+	Files []CheckpointFile `json:"files"`
+}
+
+// RecordCheckpoint snapshots the session's workspace against the last
+// message of the conversation. It is called when a chat completion ends.
+func (s *SandboxService) RecordCheckpoint(ctx context.Context, sessionID string) (*Checkpoint, error) {
+	client, err := s.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := client.GetMessages(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	var checkpoint Checkpoint
+	if err := s.runCheckpointCommand(ctx, sessionID, &checkpoint, "create", messages[len(messages)-1].ID); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// ListCheckpoints returns the session's checkpoints, oldest first.
+func (s *SandboxService) ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	var resp struct {
+		Checkpoints []Checkpoint `json:"checkpoints"`
+	}
+	if err := s.runCheckpointCommand(ctx, sessionID, &resp, "list"); err != nil {
+		return nil, err
+	}
+	return resp.Checkpoints, nil
+}
+
+// DiffCheckpoint compares the checkpoint for messageID with the current
+// workspace. If path is non-empty, only that file is compared.
+func (s *SandboxService) DiffCheckpoint(ctx context.Context, sessionID, messageID, path string) (*CheckpointDiff, error) {
+	args := []string{"diff", messageID}
+	if path != "" {
+		args = append(args, path)
+	}
+	var diff CheckpointDiff
+	if err := s.runCheckpointCommand(ctx, sessionID, &diff, args...); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// RestoreCheckpoint resets the session's workspace to the checkpoint for messageID.
+func (s *SandboxService) RestoreCheckpoint(ctx context.Context, sessionID, messageID string) (*CheckpointRestoreResult, error) {
+	var result CheckpointRestoreResult
+	if err := s.runCheckpointCommand(ctx, sessionID, &result, "restore", messageID); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// runCheckpointCommand runs a discobot-agent checkpoint subcommand as root,
+// which is needed to read and preserve the overlay's whiteouts and owners,
+// and decodes its JSON output into out.
+func (s *SandboxService) runCheckpointCommand(ctx context.Context, sessionID string, out any, args ...string) error {
+	if err := s.ensureSandboxReady(ctx, sessionID); err != nil {
+		return err
+	}
+
+	cmd := append([]string{agentBinary, "checkpoint"}, args...)
+	result, err := s.provider.Exec(ctx, sessionID, cmd, sandbox.ExecOptions{User: "root"})
+	if err != nil {
+		return fmt.Errorf("failed to run checkpoint %s: %w", args[0], err)
+	}
+	switch result.ExitCode {
+	case 0:
+	case checkpointNotFoundExitCode:
+		return ErrCheckpointNotFound
+	default:
+		return fmt.Errorf("checkpoint %s failed (exit code %d): %s", args[0], result.ExitCode, strings.TrimSpace(string(result.Stderr)))
+	}
+
+	if err := json.Unmarshal(result.Stdout, out); err != nil {
+		return fmt.Errorf("failed to parse checkpoint %s output: %w", args[0], err)
+	}
+	return nil
+}
+
+// ============================================================================
+// Checkpoint Methods
+// ============================================================================
+
+// RecordCheckpoint snapshots the session's files against the last message of
+// the conversation. Returns nil if the conversation is empty.
+func (c *ChatService) RecordCheckpoint(ctx context.Context, projectID, sessionID string) (*Checkpoint, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.RecordCheckpoint(ctx, sessionID)
+}
+
+// ListCheckpoints returns the checkpoints recorded for a session.
+// The sandbox is automatically reconciled if not running.
+func (c *ChatService) ListCheckpoints(ctx context.Context, projectID, sessionID string) ([]Checkpoint, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.ListCheckpoints(ctx, sessionID)
+}
+
+// DiffCheckpoint compares the checkpoint recorded for messageID with the
+// session's current files. If path is non-empty, only that file is compared.
+// The sandbox is automatically reconciled if not running.
+func (c *ChatService) DiffCheckpoint(ctx context.Context, projectID, sessionID, messageID, path string) (*CheckpointDiff, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.DiffCheckpoint(ctx, sessionID, messageID, path)
+}
+
+// RestoreCheckpoint resets the session's files to the checkpoint recorded
+// for messageID. The conversation itself is left unchanged.
+// Returns ErrCompletionRunning while the agent is working on the session.
+func (c *ChatService) RestoreCheckpoint(ctx context.Context, projectID, sessionID, messageID string) (*CheckpointRestoreResult, error) {
+	sess, err := c.GetSession(ctx, projectID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Status == model.SessionStatusRunning {
+		return nil, ErrCompletionRunning
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.RestoreCheckpoint(ctx, sessionID, messageID)
+}
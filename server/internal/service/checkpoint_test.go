@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// execCall records a command run through the mock provider.
+type execCall struct {
+	cmd  []string
+	user string
+}
+
+func stubCheckpointExec(env *testEnv, exitCode int, stdout string) *[]execCall {
+	var calls []execCall
+	env.mockSandbox.ExecFunc = func(_ context.Context, _ string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
+		calls = append(calls, execCall{cmd: cmd, user: opts.User})
+		return &sandbox.ExecResult{ExitCode: exitCode, Stdout: []byte(stdout), Stderr: []byte("boom")}, nil
+	}
+	return &calls
+}
+
+func TestRecordCheckpoint_UsesLastMessage(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	calls := stubCheckpointExec(env, 0, `{"messageId":"asst-2","seq":2,"createdAt":"2026-01-02T03:04:05Z"}`)
+
+	checkpoint, err := chatSvc.RecordCheckpoint(context.Background(), source.ProjectID, source.ID)
+	if err != nil {
+		t.Fatalf("RecordCheckpoint: %v", err)
+	}
+	if checkpoint.MessageID != "asst-2" || checkpoint.Seq != 2 {
+		t.Errorf("unexpected checkpoint: %+v", checkpoint)
+	}
+
+	if len(*calls) != 1 {
+		t.Fatalf("expected 1 exec call, got %d", len(*calls))
+	}
+	call := (*calls)[0]
+	want := []string{agentBinary, "checkpoint", "create", "asst-2"}
+	if !slices.Equal(call.cmd, want) {
+		t.Errorf("cmd = %v, want %v", call.cmd, want)
+	}
+	if call.user != "root" {
+		t.Errorf("user = %q, want root", call.user)
+	}
+}
+
+func TestRecordCheckpoint_EmptyConversation(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, nil)
+	calls := stubCheckpointExec(env, 0, `{}`)
+
+	checkpoint, err := chatSvc.RecordCheckpoint(context.Background(), source.ProjectID, source.ID)
+	if err != nil || checkpoint != nil {
+		t.Fatalf("RecordCheckpoint = %v, %v; want nil, nil", checkpoint, err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("expected no exec calls, got %v", *calls)
+	}
+}
+
+func TestCheckpointListAndDiff(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	calls := stubCheckpointExec(env, 0, `{"checkpoints":[{"messageId":"asst-1","seq":1},{"messageId":"asst-2","seq":2}]}`)
+	list, err := chatSvc.ListCheckpoints(ctx, source.ProjectID, source.ID)
+	if err != nil {
+		t.Fatalf("ListCheckpoints: %v", err)
+	}
+	if len(list) != 2 || list[0].MessageID != "asst-1" {
+		t.Errorf("unexpected list: %+v", list)
+	}
+	if got := (*calls)[0].cmd[2:]; !slices.Equal(got, []string{"list"}) {
+		t.Errorf("list args = %v", got)
+	}
+
+	calls = stubCheckpointExec(env, 0, `{"files":[{"path":"main.go","status":"modified"}],"patch":"--- a/main.go\n"}`)
+	diff, err := chatSvc.DiffCheckpoint(ctx, source.ProjectID, source.ID, "asst-1", "main.go")
+	if err != nil {
+		t.Fatalf("DiffCheckpoint: %v", err)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].Status != "modified" || diff.Patch == "" {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+	if got := (*calls)[0].cmd[2:]; !slices.Equal(got, []string{"diff", "asst-1", "main.go"}) {
+		t.Errorf("diff args = %v", got)
+	}
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	stubCheckpointExec(env, checkpointNotFoundExitCode, "")
+	if _, err := chatSvc.DiffCheckpoint(ctx, source.ProjectID, source.ID, "missing", ""); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("expected ErrCheckpointNotFound, got %v", err)
+	}
+
+	stubCheckpointExec(env, 1, "")
+	if _, err := chatSvc.RestoreCheckpoint(ctx, source.ProjectID, source.ID, "asst-1"); err == nil || errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("expected command failure, got %v", err)
+	}
+
+	if _, err := chatSvc.ListCheckpoints(ctx, "other-project", source.ID); err == nil {
+		t.Error("expected error for session in another project")
+	}
+
+	if err := env.store.UpdateSessionStatus(ctx, source.ID, model.SessionStatusRunning, nil); err != nil {
+		t.Fatal(err)
+	}
+	calls := stubCheckpointExec(env, 0, `{"files":[]}`)
+	if _, err := chatSvc.RestoreCheckpoint(ctx, source.ProjectID, source.ID, "asst-1"); !errors.Is(err, ErrCompletionRunning) {
+		t.Errorf("expected ErrCompletionRunning, got %v", err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("restore must not run while a completion is running, got %v", *calls)
+	}
+}
@@ -376,6 +376,7 @@ type CredentialEnvVar struct {
 // GetAllDecrypted returns all credentials for a project as environment variable mappings.
 // This is used to pass credentials to agent containers.
 // Each credential is mapped to its provider's configured environment variable name.
+// Git credentials marked for forwarding are included as git environment variables.
 func (s *CredentialService) GetAllDecrypted(ctx context.Context, projectID string) ([]CredentialEnvVar, error) {
 	creds, err := s.store.ListCredentialsByProject(ctx, projectID)
 	if err != nil {
@@ -434,6 +435,12 @@ func (s *CredentialService) GetAllDecrypted(ctx context.Context, projectID strin
 		}
 	}
 
+	gitEnv, err := s.gitCredentialEnvVars(ctx, projectID)
+	if err != nil {
+		log.Printf("Warning: Failed to get git credentials for project %s: %v", projectID, err)
+	}
+	result = append(result, gitEnv...)
+
 	return result, nil
 }
 
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+// With credential placeholders, provider credentials never enter the
+// sandbox. The agent gets a placeholder in place of each one, and the proxy
+// in the sandbox, which the agent's traffic goes through, swaps it for the
+// real value on requests to the provider's hosts. Whatever runs in the
+// sandbox can read and leak placeholders only.
+
+// credentialHosts are the hosts each provider's credentials are sent to.
+// Credentials of providers not listed here, and git credentials, are given
+// to the sandbox as they are.
+var credentialHosts = map[string][]string{
+	ProviderAnthropic:     {"api.anthropic.com"},
+	ProviderOpenAI:        {"api.openai.com"},
+	ProviderCodex:         {"api.openai.com", "chatgpt.com"},
+	ProviderGitHubCopilot: {"api.github.com", "api.githubcopilot.com", "*.githubcopilot.com"},
+}
+
+// credentialPrefixes are the well-known prefixes of provider credentials,
+// longest first. Placeholders keep them, so that clients checking the
+// format of a key accept its placeholder.
+var credentialPrefixes = []string{
+	"sk-ant-api03-",
+	"sk-ant-oat01-",
+	"github_pat_",
+	"sk-proj-",
+	"sk-ant-",
+	"gho_",
+	"ghp_",
+	"ghu_",
+	"sk-",
+}
+
+// proxySecret is a secret as the proxy's runtime config API takes it.
+type proxySecret struct {
+	Value string   `json:"value"`
+	Hosts []string `json:"hosts"`
+}
+
+// pushedSecrets are the secrets the proxy in a sandbox was last given.
+type pushedSecrets struct {
+	startedAt time.Time // A restarted sandbox has a new proxy without secrets
+	secrets   map[string]proxySecret
+}
+
+// credentialPlaceholder returns the placeholder standing for a credential in
+// a session's sandbox. It is derived from the session and the credential's
+// provider and variable rather than its value, so it survives OAuth refreshes
+// and the agent keeps working with the environment it was given.
+func credentialPlaceholder(key []byte, sessionID string, cred CredentialEnvVar) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sessionID + "\x00" + cred.Provider + "\x00" + cred.EnvVar))
+	prefix := ""
+	for _, p := range credentialPrefixes {
+		if strings.HasPrefix(cred.Value, p) {
+			prefix = p
+			break
+		}
+	}
+	return prefix + "discobot-placeholder-" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// placeholderCredentials replaces the credentials of providers with known
+// hosts by placeholders, and returns the secrets the proxy needs to swap
+// them back, keyed by placeholder.
+func placeholderCredentials(key []byte, sessionID string, creds []CredentialEnvVar) ([]CredentialEnvVar, map[string]proxySecret) {
+	result := make([]CredentialEnvVar, len(creds))
+	secrets := make(map[string]proxySecret)
+	for i, cred := range creds {
+		result[i] = cred
+		hosts, ok := credentialHosts[cred.Provider]
+		if !ok || cred.Value == "" {
+			continue
+		}
+		placeholder := credentialPlaceholder(key, sessionID, cred)
+		secrets[placeholder] = proxySecret{Value: cred.Value, Hosts: hosts}
+		result[i].Value = placeholder
+	}
+	return result, secrets
+}
+
+// sandboxCredentials fetches the credentials to send to a session's sandbox.
+// With credential placeholders enabled, it gives the proxy in the sandbox
+// any secrets it does not have yet and returns placeholders in their place.
+func (s *SandboxService) sandboxCredentials(ctx context.Context, sessionID string) ([]CredentialEnvVar, error) {
+	creds, err := s.credentialFetcher(ctx, sessionID)
+	if err != nil || !s.cfg.CredentialPlaceholders {
+		return creds, err
+	}
+
+	result, secrets := placeholderCredentials(s.cfg.EncryptionKey, sessionID, creds)
+	if err := s.pushProxySecrets(ctx, sessionID, secrets, false); err != nil {
+		// Still hand out placeholders: requests fail to authenticate until a
+		// later push succeeds, rather than exposing the real credentials
+		log.Printf("Warning: failed to give credentials to the proxy of session %s: %v", sessionID, err)
+	}
+	return result, nil
+}
+
+// PushCredentialSecrets gives the proxy in a session's sandbox the
+// credentials its placeholders stand for. It does nothing unless credential
+// placeholders are enabled.
+func (s *SandboxService) PushCredentialSecrets(ctx context.Context, sessionID string) error {
+	if !s.cfg.CredentialPlaceholders || s.credentialFetcher == nil {
+		return nil
+	}
+	creds, err := s.credentialFetcher(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	_, secrets := placeholderCredentials(s.cfg.EncryptionKey, sessionID, creds)
+	// The proxy may still be starting along with the sandbox
+	return s.pushProxySecrets(ctx, sessionID, secrets, true)
+}
+
+// PushProjectCredentialSecrets gives the proxies of a project's active
+// sessions their credentials after they changed, e.g. on an OAuth refresh.
+func (s *SandboxService) PushProjectCredentialSecrets(ctx context.Context, projectID string) {
+	if !s.cfg.CredentialPlaceholders {
+		return
+	}
+	sessions, err := s.store.ListSessionsByStatuses(ctx, []string{model.SessionStatusReady, model.SessionStatusRunning})
+	if err != nil {
+		log.Printf("Warning: failed to list sessions of project %s: %v", projectID, err)
+		return
+	}
+	for _, sess := range sessions {
+		if sess.ProjectID != projectID {
+			continue
+		}
+		if err := s.PushCredentialSecrets(ctx, sess.ID); err != nil {
+			log.Printf("Warning: failed to give credentials to the proxy of session %s: %v", sess.ID, err)
+		}
+	}
+}
+
+// pushProxySecrets sets the secrets of the proxy in a session's sandbox,
+// unless it already has them. Placeholders it was given before and that are
+// gone from secrets are removed.
+func (s *SandboxService) pushProxySecrets(ctx context.Context, sessionID string, secrets map[string]proxySecret, retry bool) error {
+	sb, err := s.provider.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	var startedAt time.Time
+	if sb.StartedAt != nil {
+		startedAt = *sb.StartedAt
+	}
+
+	s.proxySecretsMu.Lock()
+	prev, ok := s.proxySecrets[sessionID]
+	s.proxySecretsMu.Unlock()
+	if ok && !prev.startedAt.Equal(startedAt) {
+		ok = false
+	}
+	if ok && maps.EqualFunc(prev.secrets, secrets, func(a, b proxySecret) bool {
+		return a.Value == b.Value && slices.Equal(a.Hosts, b.Hosts)
+	}) {
+		return nil
+	}
+
+	// PATCH merges; a null secret removes its placeholder
+	patch := make(map[string]*proxySecret, len(secrets))
+	for placeholder, secret := range secrets {
+		patch[placeholder] = &secret
+	}
+	if ok {
+		for placeholder := range prev.secrets {
+			if _, keep := secrets[placeholder]; !keep {
+				patch[placeholder] = nil
+			}
+		}
+	}
+
+	if len(patch) > 0 {
+		push := func() (struct{}, int, error) {
+			return struct{}{}, 0, s.proxyAPI(ctx, sessionID, http.MethodPatch, "/api/config", map[string]any{"secrets": patch}, nil)
+		}
+		if retry {
+			_, err = retryWithBackoff(ctx, push)
+		} else {
+			_, _, err = push()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	s.proxySecretsMu.Lock()
+	s.proxySecrets[sessionID] = pushedSecrets{startedAt: startedAt, secrets: secrets}
+	s.proxySecretsMu.Unlock()
+	return nil
+}
+
+// forgetProxySecrets drops the secrets the proxy of a session's sandbox was
+// given, once the sandbox is stopped or removed and its proxy with them, so
+// decrypted credentials do not stay in server memory. A restarted sandbox
+// gets them pushed again.
+func (s *SandboxService) forgetProxySecrets(sessionID string) {
+	s.proxySecretsMu.Lock()
+	delete(s.proxySecrets, sessionID)
+	s.proxySecretsMu.Unlock()
+}
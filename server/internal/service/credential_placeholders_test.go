@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+func TestPlaceholderCredentials(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	creds := []CredentialEnvVar{
+		{EnvVar: "ANTHROPIC_API_KEY", Value: "sk-ant-api03-secret", Provider: ProviderAnthropic, AuthType: AuthTypeAPIKey},
+		{EnvVar: "OPENAI_API_KEY", Value: "opaque-secret", Provider: ProviderOpenAI, AuthType: AuthTypeAPIKey},
+		{EnvVar: "GIT_TOKEN", Value: "ghp_git", Provider: ProviderGit, AuthType: AuthTypeAPIKey},
+	}
+
+	result, secrets := placeholderCredentials(key, "session-1", creds)
+
+	anthropic := result[0].Value
+	if !strings.HasPrefix(anthropic, "sk-ant-api03-") || strings.Contains(anthropic, "secret") {
+		t.Errorf("Anthropic placeholder %q should keep the prefix only", anthropic)
+	}
+	if got := secrets[anthropic]; got.Value != "sk-ant-api03-secret" || strings.Join(got.Hosts, ",") != "api.anthropic.com" {
+		t.Errorf("Unexpected Anthropic secret: %+v", got)
+	}
+	if openai := result[1].Value; strings.Contains(openai, "opaque") || secrets[openai].Value != "opaque-secret" {
+		t.Errorf("Unexpected OpenAI placeholder %q", openai)
+	}
+	if result[2].Value != "ghp_git" {
+		t.Errorf("Git credential should be passed as is, got %q", result[2].Value)
+	}
+	if len(secrets) != 2 {
+		t.Errorf("Expected 2 secrets, got %d", len(secrets))
+	}
+	if creds[0].Value != "sk-ant-api03-secret" {
+		t.Error("Input credentials were modified")
+	}
+
+	// Refreshed tokens keep their placeholder; other sessions get their own
+	refreshed := []CredentialEnvVar{creds[0]}
+	refreshed[0].Value = "sk-ant-api03-refreshed"
+	if again, _ := placeholderCredentials(key, "session-1", refreshed); again[0].Value != anthropic {
+		t.Errorf("Placeholder changed on refresh: %q != %q", again[0].Value, anthropic)
+	}
+	if other, _ := placeholderCredentials(key, "session-2", creds); other[0].Value == anthropic {
+		t.Error("Sessions share a placeholder")
+	}
+}
+
+func TestSandboxService_CredentialPlaceholders(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	const sessionID = "session-1"
+	creds := []CredentialEnvVar{
+		{EnvVar: "ANTHROPIC_API_KEY", Value: "sk-ant-api03-secret", Provider: ProviderAnthropic, AuthType: AuthTypeAPIKey},
+	}
+	fetcher := func(_ context.Context, _ string) ([]CredentialEnvVar, error) {
+		return creds, nil
+	}
+	cfg := &config.Config{CredentialPlaceholders: true, EncryptionKey: []byte("0123456789abcdef0123456789abcdef")}
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, cfg, fetcher, env.eventBroker, nil)
+
+	if _, err := env.mockSandbox.Create(ctx, sessionID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(ctx, sessionID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	var patches []map[string]*proxySecret
+	env.mockSandbox.ExecStreamFunc = func(_ context.Context, _ string, _ []string, _ sandbox.ExecStreamOptions) (sandbox.Stream, error) {
+		return newProxyTestStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Secrets map[string]*proxySecret `json:"secrets"`
+			}
+			if r.Method != http.MethodPatch || r.URL.Path != "/api/config" || json.NewDecoder(r.Body).Decode(&body) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			patches = append(patches, body.Secrets)
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		})), nil
+	}
+
+	if err := sandboxSvc.PushCredentialSecrets(ctx, sessionID); err != nil {
+		t.Fatalf("PushCredentialSecrets failed: %v", err)
+	}
+	if len(patches) != 1 || len(patches[0]) != 1 {
+		t.Fatalf("Expected one secret pushed, got %v", patches)
+	}
+
+	got, err := sandboxSvc.sandboxCredentials(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("sandboxCredentials failed: %v", err)
+	}
+	placeholder := got[0].Value
+	if secret := patches[0][placeholder]; secret == nil || secret.Value != "sk-ant-api03-secret" {
+		t.Errorf("Pushed %v, want the secret of %q", patches[0], placeholder)
+	}
+	if len(patches) != 1 {
+		t.Errorf("Unchanged secrets were pushed again: %d pushes", len(patches))
+	}
+
+	// A refreshed token is pushed for the same placeholder
+	creds = []CredentialEnvVar{{EnvVar: "ANTHROPIC_API_KEY", Value: "sk-ant-api03-refreshed", Provider: ProviderAnthropic, AuthType: AuthTypeAPIKey}}
+	if got, _ := sandboxSvc.sandboxCredentials(ctx, sessionID); got[0].Value != placeholder {
+		t.Errorf("Placeholder changed to %q", got[0].Value)
+	}
+	if len(patches) != 2 || patches[1][placeholder] == nil || patches[1][placeholder].Value != "sk-ant-api03-refreshed" {
+		t.Fatalf("Expected the refreshed secret pushed, got %v", patches)
+	}
+
+	// A deleted credential removes its placeholder from the proxy
+	creds = nil
+	if _, err := sandboxSvc.sandboxCredentials(ctx, sessionID); err != nil {
+		t.Fatalf("sandboxCredentials failed: %v", err)
+	}
+	if len(patches) != 3 || patches[2][placeholder] != nil {
+		t.Fatalf("Expected the placeholder removed, got %v", patches)
+	}
+	if _, ok := patches[2][placeholder]; !ok {
+		t.Errorf("Expected a null secret for %q, got %v", placeholder, patches[2])
+	}
+
+	// Stopping the sandbox drops the secrets held for its proxy
+	creds = []CredentialEnvVar{{EnvVar: "ANTHROPIC_API_KEY", Value: "sk-ant-api03-secret", Provider: ProviderAnthropic, AuthType: AuthTypeAPIKey}}
+	if err := sandboxSvc.PushCredentialSecrets(ctx, sessionID); err != nil {
+		t.Fatalf("PushCredentialSecrets failed: %v", err)
+	}
+	if err := sandboxSvc.StopForSession(ctx, sessionID); err != nil {
+		t.Fatalf("StopForSession failed: %v", err)
+	}
+	sandboxSvc.proxySecretsMu.Lock()
+	_, held := sandboxSvc.proxySecrets[sessionID]
+	sandboxSvc.proxySecretsMu.Unlock()
+	if held {
+		t.Error("Secrets still held after the sandbox stopped")
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// Session exports are produced by discobot-agent inside the sandbox and
+// streamed from its stdout, so large bundles and archives never have to be
+// held in memory.
+
+// exportEmptyExitCode is the exit code discobot-agent uses when there is
+// nothing to export.
+const exportEmptyExitCode = 3
+
+// Export errors
+var (
+	ErrInvalidExportFormat = errors.New("invalid export format")
+	ErrNothingToExport     = errors.New("nothing to export")
+)
+
+// exportFormat describes a format of discobot-agent export.
+type exportFormat struct {
+	ext         string
+	contentType string
+	needsBase   bool // whether the export is relative to the session's base commit
+}
+
+var exportFormats = map[string]exportFormat{
+	"mbox":   {ext: "mbox", contentType: "application/mbox", needsBase: true},
+	"bundle": {ext: "bundle", contentType: "application/octet-stream", needsBase: true},
+	"diff":   {ext: "diff", contentType: "text/x-diff; charset=utf-8"},
+	"zip":    {ext: "zip", contentType: "application/zip", needsBase: true},
+	"tar":    {ext: "tar", contentType: "application/x-tar", needsBase: true},
+}
+
+// SessionExport is a download of a session's work. Body streams from the
+// sandbox and must be closed; a read fails if the export fails midway.
+type SessionExport struct {
+	Filename    string
+	ContentType string
+	Body        io.ReadCloser
+}
+
+// ExportSession runs discobot-agent export in the session's sandbox and
+// returns its output. The command runs as the sandbox's default user, who
+// owns the workspace repository. Returns ErrNothingToExport if there are no
+// changes in the requested format.
+func (s *SandboxService) ExportSession(ctx context.Context, sessionID, format, base string) (io.ReadCloser, error) {
+	client, err := s.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := client.GetUserInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := []string{agentBinary, "export", format}
+	if base != "" {
+		cmd = append(cmd, base)
+	}
+	stream, err := s.provider.ExecStream(ctx, sessionID, cmd, sandbox.ExecStreamOptions{
+		User: strconv.Itoa(userInfo.UID) + ":" + strconv.Itoa(userInfo.GID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run export: %w", err)
+	}
+
+	e := &exportReader{ctx: ctx, stream: stream, stdout: bufio.NewReaderSize(stream, 64*1024), stderrDone: make(chan struct{})}
+	go func() {
+		defer close(e.stderrDone)
+		if stderr := stream.Stderr(); stderr != nil {
+			_, _ = io.Copy(&e.stderr, io.LimitReader(stderr, 64*1024))
+		}
+	}()
+
+	// Wait for the first output so failures are reported before the caller
+	// starts a response
+	if _, err := e.stdout.Peek(1); err != nil {
+		if err := e.wait(); err != nil {
+			_ = stream.Close()
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// exportReader reads the output of discobot-agent export and turns a
+// failed exit into a read error.
+type exportReader struct {
+	ctx        context.Context
+	stream     sandbox.Stream
+	stdout     *bufio.Reader
+	stderr     bytes.Buffer
+	stderrDone chan struct{}
+	waited     bool
+}
+
+func (e *exportReader) Read(p []byte) (int, error) {
+	n, err := e.stdout.Read(p)
+	if err == io.EOF {
+		if werr := e.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (e *exportReader) Close() error {
+	return e.stream.Close()
+}
+
+// wait returns the result of the command once its output ended.
+func (e *exportReader) wait() error {
+	if e.waited {
+		return nil
+	}
+	e.waited = true
+
+	code, err := e.stream.Wait(e.ctx)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	<-e.stderrDone
+	switch code {
+	case 0:
+		return nil
+	case exportEmptyExitCode:
+		return ErrNothingToExport
+	default:
+		return fmt.Errorf("export failed (exit code %d): %s", code, strings.TrimSpace(e.stderr.String()))
+	}
+}
+
+// ============================================================================
+// Export Methods
+// ============================================================================
+
+// ExportSession returns the session's work in format: "mbox" patches or a
+// git "bundle" of the commits since the session's base commit, a "diff" of
+// the uncommitted changes, or a "zip" or "tar" of the files changed since
+// the base commit. A stopped sandbox is started for the export.
+func (c *ChatService) ExportSession(ctx context.Context, projectID, sessionID, format string) (*SessionExport, error) {
+	f, ok := exportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExportFormat, format)
+	}
+	sess, err := c.GetSession(ctx, projectID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+
+	var base string
+	if f.needsBase {
+		switch {
+		case sess.BaseCommit != nil && *sess.BaseCommit != "":
+			base = *sess.BaseCommit
+		case sess.WorkspaceCommit != nil && *sess.WorkspaceCommit != "":
+			base = *sess.WorkspaceCommit
+		default:
+			return nil, fmt.Errorf("%w: the session has no base commit", ErrNothingToExport)
+		}
+	}
+
+	body, err := c.sandboxService.ExportSession(ctx, sessionID, format, base)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionExport{
+		Filename:    "session-" + sessionID + "." + f.ext,
+		ContentType: f.contentType,
+		Body:        body,
+	}, nil
+}
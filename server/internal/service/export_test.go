@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/mock"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// exportTestStream is a mock stream that exits with a given code.
+type exportTestStream struct {
+	*mock.Stream
+	exitCode int
+}
+
+func (s *exportTestStream) Wait(_ context.Context) (int, error) {
+	return s.exitCode, nil
+}
+
+func TestChatService_ExportSession(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, commit)
+
+	env.mockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(sandboxapi.UserResponse{Username: "discobot", UID: 1000, GID: 1000})
+	})
+	if _, err := env.mockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	var gotCmd []string
+	var gotUser string
+	exitCode := 0
+	output := "From abc Mon Sep 17 00:00:00 2001\nSubject: [PATCH] agent work\n"
+	env.mockSandbox.ExecStreamFunc = func(_ context.Context, _ string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error) {
+		gotCmd, gotUser = cmd, opts.User
+		return &exportTestStream{Stream: &mock.Stream{OutputBuffer: []byte(output), StderrBuffer: []byte("fatal: bad revision")}, exitCode: exitCode}, nil
+	}
+
+	enqueuer := &recordingEnqueuer{}
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, enqueuer)
+	chatSvc := NewChatService(env.store, sessionSvc, enqueuer, env.eventBroker, sandboxSvc, env.gitService)
+
+	export, err := chatSvc.ExportSession(ctx, project.ID, session.ID, "mbox")
+	if err != nil {
+		t.Fatalf("ExportSession failed: %v", err)
+	}
+	body, err := io.ReadAll(export.Body)
+	_ = export.Body.Close()
+	if err != nil || string(body) != output {
+		t.Errorf("Unexpected export body %q: %v", body, err)
+	}
+	if want := []string{agentBinary, "export", "mbox", commit}; strings.Join(gotCmd, " ") != strings.Join(want, " ") {
+		t.Errorf("Ran %v, want %v", gotCmd, want)
+	}
+	if gotUser != "1000:1000" {
+		t.Errorf("Ran as %q, want the workspace owner", gotUser)
+	}
+	if export.Filename != "session-"+session.ID+".mbox" || export.ContentType != "application/mbox" {
+		t.Errorf("Unexpected export metadata %q %q", export.Filename, export.ContentType)
+	}
+
+	// A failure after output started surfaces as a read error
+	exitCode = 1
+	export, err = chatSvc.ExportSession(ctx, project.ID, session.ID, "bundle")
+	if err != nil {
+		t.Fatalf("ExportSession failed: %v", err)
+	}
+	if _, err := io.ReadAll(export.Body); err == nil || !strings.Contains(err.Error(), "bad revision") {
+		t.Errorf("Expected the export failure when reading, got %v", err)
+	}
+
+	output = ""
+	exitCode = exportEmptyExitCode
+	if _, err := chatSvc.ExportSession(ctx, project.ID, session.ID, "diff"); !errors.Is(err, ErrNothingToExport) {
+		t.Errorf("Expected ErrNothingToExport, got %v", err)
+	}
+	if len(gotCmd) != 3 {
+		t.Errorf("Expected no base commit for a diff export, got %v", gotCmd)
+	}
+
+	if _, err := chatSvc.ExportSession(ctx, project.ID, session.ID, "rar"); !errors.Is(err, ErrInvalidExportFormat) {
+		t.Errorf("Expected ErrInvalidExportFormat, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/obot-platform/discobot/server/internal/git"
 	"github.com/obot-platform/discobot/server/internal/store"
@@ -62,9 +63,31 @@ func (s *GitService) Diff(ctx context.Context, workspaceID string, opts git.Diff
 	return s.provider.Diff(ctx, workspaceID, opts)
 }
 
-// Branches returns all branches for a workspace.
+// Branches returns all branches for a workspace, with the sessions that
+// target each branch. Target branches that do not exist yet are included
+// without a commit.
 func (s *GitService) Branches(ctx context.Context, workspaceID string) ([]git.Branch, error) {
-	return s.provider.Branches(ctx, workspaceID)
+	branches, err := s.provider.Branches(ctx, workspaceID)
+	if err != nil || s.store == nil {
+		return branches, err
+	}
+
+	sessions, err := s.store.ListSessionsByWorkspace(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, sess := range sessions {
+		if sess.TargetBranch == nil || *sess.TargetBranch == "" {
+			continue
+		}
+		i := slices.IndexFunc(branches, func(b git.Branch) bool { return !b.IsRemote && b.Name == *sess.TargetBranch })
+		if i < 0 {
+			branches = append(branches, git.Branch{Name: *sess.TargetBranch})
+			i = len(branches) - 1
+		}
+		branches[i].Sessions = append(branches[i].Sessions, sess.ID)
+	}
+	return branches, nil
 }
 
 // FileTree returns the file tree for a workspace at a specific ref.
@@ -113,6 +136,39 @@ func (s *GitService) ApplyPatches(ctx context.Context, workspaceID string, patch
 	return s.provider.ApplyPatches(ctx, workspaceID, patches)
 }
 
+// ApplyPatchesThreeWay applies patches with a three-way merge fallback.
+// Returns a *git.ConflictError if the merge conflicts.
+func (s *GitService) ApplyPatchesThreeWay(ctx context.Context, workspaceID string, patches []byte) (string, error) {
+	return s.provider.ApplyPatchesThreeWay(ctx, workspaceID, patches)
+}
+
+// ApplyPatchesToBranch applies patches to a branch in a worktree without
+// touching the workspace checkout. Returns a *git.ConflictError if the merge
+// conflicts.
+func (s *GitService) ApplyPatchesToBranch(ctx context.Context, workspaceID, branch string, patches []byte) (string, error) {
+	return s.provider.ApplyPatchesToBranch(ctx, workspaceID, branch, patches)
+}
+
+// ResolveBranch returns the commit of a branch of the workspace.
+func (s *GitService) ResolveBranch(ctx context.Context, workspaceID, branch string) (string, error) {
+	return s.provider.ResolveBranch(ctx, workspaceID, branch)
+}
+
+// FetchRef fetches a ref of the workspace's origin remote and returns its commit.
+func (s *GitService) FetchRef(ctx context.Context, workspaceID, ref string) (string, error) {
+	return s.provider.FetchRef(ctx, workspaceID, ref)
+}
+
+// FormatPatch returns the commits in base..head as mbox-format patches.
+func (s *GitService) FormatPatch(ctx context.Context, workspaceID, base, head string) ([]byte, error) {
+	return s.provider.FormatPatch(ctx, workspaceID, base, head)
+}
+
+// Push pushes a commit to a branch of the workspace's origin remote.
+func (s *GitService) Push(ctx context.Context, workspaceID string, opts git.PushOptions) error {
+	return s.provider.Push(ctx, workspaceID, opts)
+}
+
 // Provider returns the underlying git provider.
 // This allows direct access for advanced operations.
 func (s *GitService) Provider() git.Provider {
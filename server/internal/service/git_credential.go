@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Git credential auth types
+const (
+	GitAuthTypeSSHKey     = "ssh_key"
+	GitAuthTypeHTTPSToken = "https_token"
+)
+
+// ProviderGit is the provider of the git credentials forwarded to sandboxes.
+const ProviderGit = "git"
+
+// defaultGitUsername is sent with HTTPS tokens that have no username.
+// GitHub, GitLab and Gitea accept a token with any username.
+const defaultGitUsername = "x-access-token"
+
+// gitSSHKeyEnvVar holds the forwarded SSH key inside the sandbox.
+const gitSSHKeyEnvVar = "DISCOBOT_GIT_SSH_KEY"
+
+// sandboxGitSSHCommand writes the forwarded SSH key to a temporary file for
+// each ssh invocation and removes it again, so the key never lands in the
+// workspace. git appends the ssh arguments, which the inner shell receives
+// as "$@".
+const sandboxGitSSHCommand = `sh -c 'k=$(mktemp) && printf "%s\n" "$` + gitSSHKeyEnvVar + `" > "$k" && ssh -i "$k" -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new "$@"; s=$?; rm -f "$k"; exit $s' ssh`
+
+var (
+	ErrGitCredentialNotFound = errors.New("git credential not found")
+	ErrInvalidGitCredential  = errors.New("invalid git credential")
+)
+
+// GitCredentialData is the encrypted secret of a git credential.
+type GitCredentialData struct {
+	PrivateKey string `json:"private_key,omitempty"`
+	Token      string `json:"token,omitempty"`
+}
+
+// GitCredentialInput creates or updates the git credential of a host.
+type GitCredentialInput struct {
+	Host             string
+	AuthType         string
+	Username         string
+	PrivateKey       string
+	Token            string
+	ForwardToSandbox bool
+}
+
+// GitCredentialInfo represents safe git credential info for API responses
+// (no secrets).
+type GitCredentialInfo struct {
+	ID               string    `json:"id"`
+	Host             string    `json:"host"`
+	AuthType         string    `json:"authType"`
+	Username         string    `json:"username,omitempty"`
+	Fingerprint      string    `json:"fingerprint,omitempty"` // SSH keys only
+	ForwardToSandbox bool      `json:"forwardToSandbox"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// ListGitCredentials returns the git credentials of a project (safe info only).
+func (s *CredentialService) ListGitCredentials(ctx context.Context, projectID string) ([]GitCredentialInfo, error) {
+	creds, err := s.store.ListGitCredentialsByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]GitCredentialInfo, len(creds))
+	for i, c := range creds {
+		result[i] = toGitCredentialInfo(c)
+	}
+	return result, nil
+}
+
+// SetGitCredential creates or updates the git credential of the input's host
+// and auth type. SSH keys must be unencrypted private keys.
+func (s *CredentialService) SetGitCredential(ctx context.Context, projectID string, input GitCredentialInput) (*GitCredentialInfo, error) {
+	host := normalizeGitHost(input.Host)
+	if host == "" {
+		return nil, fmt.Errorf("%w: host is required", ErrInvalidGitCredential)
+	}
+
+	var data GitCredentialData
+	var fingerprint, username string
+	switch input.AuthType {
+	case GitAuthTypeSSHKey:
+		signer, err := ssh.ParsePrivateKey([]byte(input.PrivateKey))
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				return nil, fmt.Errorf("%w: passphrase-protected keys are not supported", ErrInvalidGitCredential)
+			}
+			return nil, fmt.Errorf("%w: privateKey is not a valid SSH private key", ErrInvalidGitCredential)
+		}
+		data.PrivateKey = input.PrivateKey
+		fingerprint = ssh.FingerprintSHA256(signer.PublicKey())
+	case GitAuthTypeHTTPSToken:
+		if input.Token == "" {
+			return nil, fmt.Errorf("%w: token is required", ErrInvalidGitCredential)
+		}
+		data.Token = input.Token
+		username = input.Username
+		if username == "" {
+			username = defaultGitUsername
+		}
+	default:
+		return nil, fmt.Errorf("%w: authType must be %q or %q", ErrInvalidGitCredential, GitAuthTypeSSHKey, GitAuthTypeHTTPSToken)
+	}
+
+	encrypted, err := s.encryptor.EncryptJSON(data)
+	if err != nil {
+		return nil, ErrEncryptionFailed
+	}
+
+	existing, err := s.store.GetGitCredentialByHost(ctx, projectID, host, input.AuthType)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	if existing != nil {
+		existing.Username = username
+		existing.EncryptedData = encrypted
+		existing.Fingerprint = fingerprint
+		existing.ForwardToSandbox = input.ForwardToSandbox
+		if err := s.store.UpdateGitCredential(ctx, existing); err != nil {
+			return nil, err
+		}
+		info := toGitCredentialInfo(existing)
+		return &info, nil
+	}
+
+	cred := &model.GitCredential{
+		ProjectID:        projectID,
+		Host:             host,
+		AuthType:         input.AuthType,
+		Username:         username,
+		EncryptedData:    encrypted,
+		Fingerprint:      fingerprint,
+		ForwardToSandbox: input.ForwardToSandbox,
+	}
+	if err := s.store.CreateGitCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	info := toGitCredentialInfo(cred)
+	return &info, nil
+}
+
+// DeleteGitCredential removes a git credential.
+func (s *CredentialService) DeleteGitCredential(ctx context.Context, projectID, id string) error {
+	if err := s.store.DeleteGitCredential(ctx, projectID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrGitCredentialNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GitCredentials returns the credentials for cloning and fetching remote:
+// the SSH key of its host for SSH remotes, the HTTPS token otherwise. It
+// implements git.CredentialSource.
+func (s *CredentialService) GitCredentials(ctx context.Context, projectID, remote string) (*git.Credentials, error) {
+	host := git.RemoteHost(remote)
+	if host == "" {
+		return nil, nil
+	}
+	authType := GitAuthTypeHTTPSToken
+	if git.IsSSHRemote(remote) {
+		authType = GitAuthTypeSSHKey
+	}
+
+	cred, err := s.store.GetGitCredentialByHost(ctx, projectID, host, authType)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var data GitCredentialData
+	if err := s.encryptor.DecryptJSON(cred.EncryptedData, &data); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	if authType == GitAuthTypeSSHKey {
+		return &git.Credentials{SSHKey: []byte(data.PrivateKey)}, nil
+	}
+	return &git.Credentials{Username: cred.Username, Password: data.Token}, nil
+}
+
+// gitCredentialEnvVars returns the environment that lets git inside a
+// sandbox use the project's forwarded git credentials. HTTPS tokens become
+// per-host http.extraHeader settings. Only one SSH key can be forwarded,
+// since ssh is configured once for all hosts.
+func (s *CredentialService) gitCredentialEnvVars(ctx context.Context, projectID string) ([]CredentialEnvVar, error) {
+	creds, err := s.store.ListGitCredentialsByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []CredentialEnvVar
+	var sshHost string
+	headers := 0
+	for _, c := range creds {
+		if !c.ForwardToSandbox {
+			continue
+		}
+
+		var data GitCredentialData
+		if err := s.encryptor.DecryptJSON(c.EncryptedData, &data); err != nil {
+			// Skip credentials that fail to decrypt
+			continue
+		}
+
+		switch c.AuthType {
+		case GitAuthTypeSSHKey:
+			if sshHost != "" {
+				log.Printf("Warning: Only one SSH key is forwarded to sandboxes, skipping the key for %s (using %s)", c.Host, sshHost)
+				continue
+			}
+			sshHost = c.Host
+			result = append(result,
+				gitEnvVar(gitSSHKeyEnvVar, data.PrivateKey, c.AuthType),
+				gitEnvVar("GIT_SSH_COMMAND", sandboxGitSSHCommand, c.AuthType),
+				gitEnvVar("GIT_SSH_VARIANT", "ssh", c.AuthType),
+			)
+		case GitAuthTypeHTTPSToken:
+			n := strconv.Itoa(headers)
+			result = append(result,
+				gitEnvVar("GIT_CONFIG_KEY_"+n, "http.https://"+c.Host+"/.extraHeader", c.AuthType),
+				gitEnvVar("GIT_CONFIG_VALUE_"+n, git.BasicAuthHeader(c.Username, data.Token), c.AuthType),
+			)
+			headers++
+		}
+	}
+	if headers > 0 {
+		result = append(result, gitEnvVar("GIT_CONFIG_COUNT", strconv.Itoa(headers), GitAuthTypeHTTPSToken))
+	}
+	return result, nil
+}
+
+func gitEnvVar(name, value, authType string) CredentialEnvVar {
+	return CredentialEnvVar{EnvVar: name, Value: value, Provider: ProviderGit, AuthType: authType}
+}
+
+// normalizeGitHost returns the lowercased host name of a host, URL or
+// scp-like remote, or "" if it has none.
+func normalizeGitHost(host string) string {
+	host = strings.TrimSpace(host)
+	if strings.Contains(host, ":") {
+		host = git.RemoteHost(host)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "/"))
+	if strings.ContainsAny(host, "/@ \t") {
+		return ""
+	}
+	return host
+}
+
+func toGitCredentialInfo(c *model.GitCredential) GitCredentialInfo {
+	return GitCredentialInfo{
+		ID:               c.ID,
+		Host:             c.Host,
+		AuthType:         c.AuthType,
+		Username:         c.Username,
+		Fingerprint:      c.Fingerprint,
+		ForwardToSandbox: c.ForwardToSandbox,
+		CreatedAt:        c.CreatedAt,
+		UpdatedAt:        c.UpdatedAt,
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+)
+
+func newTestGitCredentialService(t *testing.T) *CredentialService {
+	t.Helper()
+	credSvc, err := NewCredentialService(setupTestStore(t), &config.Config{
+		EncryptionKey: []byte("test-key-32-bytes-long-123456789"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create credential service: %v", err)
+	}
+	return credSvc
+}
+
+func testSSHKey(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestSetGitCredential(t *testing.T) {
+	credSvc := newTestGitCredentialService(t)
+	ctx := context.Background()
+	projectID := "test-project"
+
+	t.Run("stores an SSH key with its fingerprint", func(t *testing.T) {
+		info, err := credSvc.SetGitCredential(ctx, projectID, GitCredentialInput{
+			Host:       "GitHub.com",
+			AuthType:   GitAuthTypeSSHKey,
+			PrivateKey: testSSHKey(t),
+		})
+		if err != nil {
+			t.Fatalf("SetGitCredential failed: %v", err)
+		}
+		if info.Host != "github.com" {
+			t.Errorf("Expected normalized host github.com, got %s", info.Host)
+		}
+		if !strings.HasPrefix(info.Fingerprint, "SHA256:") {
+			t.Errorf("Expected SHA256 fingerprint, got %q", info.Fingerprint)
+		}
+	})
+
+	t.Run("defaults the token username and updates in place", func(t *testing.T) {
+		first, err := credSvc.SetGitCredential(ctx, projectID, GitCredentialInput{
+			Host:     "https://gitlab.example.com/group/repo.git",
+			AuthType: GitAuthTypeHTTPSToken,
+			Token:    "token-1",
+		})
+		if err != nil {
+			t.Fatalf("SetGitCredential failed: %v", err)
+		}
+		if first.Host != "gitlab.example.com" || first.Username != defaultGitUsername {
+			t.Errorf("Unexpected credential: %+v", first)
+		}
+
+		second, err := credSvc.SetGitCredential(ctx, projectID, GitCredentialInput{
+			Host:     "gitlab.example.com",
+			AuthType: GitAuthTypeHTTPSToken,
+			Username: "oauth2",
+			Token:    "token-2",
+		})
+		if err != nil {
+			t.Fatalf("SetGitCredential failed: %v", err)
+		}
+		if second.ID != first.ID || second.Username != "oauth2" {
+			t.Errorf("Expected update of %s with username oauth2, got %+v", first.ID, second)
+		}
+	})
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		inputs := []GitCredentialInput{
+			{Host: "", AuthType: GitAuthTypeHTTPSToken, Token: "x"},
+			{Host: "github.com", AuthType: GitAuthTypeHTTPSToken},
+			{Host: "github.com", AuthType: GitAuthTypeSSHKey, PrivateKey: "not a key"},
+			{Host: "github.com", AuthType: "password", Token: "x"},
+		}
+		for _, input := range inputs {
+			if _, err := credSvc.SetGitCredential(ctx, projectID, input); !errors.Is(err, ErrInvalidGitCredential) {
+				t.Errorf("Expected ErrInvalidGitCredential for %+v, got %v", input, err)
+			}
+		}
+	})
+
+	creds, err := credSvc.ListGitCredentials(ctx, projectID)
+	if err != nil {
+		t.Fatalf("ListGitCredentials failed: %v", err)
+	}
+	if len(creds) != 2 {
+		t.Fatalf("Expected 2 git credentials, got %d", len(creds))
+	}
+
+	if err := credSvc.DeleteGitCredential(ctx, projectID, creds[0].ID); err != nil {
+		t.Fatalf("DeleteGitCredential failed: %v", err)
+	}
+	if err := credSvc.DeleteGitCredential(ctx, projectID, creds[0].ID); !errors.Is(err, ErrGitCredentialNotFound) {
+		t.Errorf("Expected ErrGitCredentialNotFound, got %v", err)
+	}
+}
+
+func TestGitCredentials_MatchesRemote(t *testing.T) {
+	credSvc := newTestGitCredentialService(t)
+	ctx := context.Background()
+	projectID := "test-project"
+	key := testSSHKey(t)
+
+	if _, err := credSvc.SetGitCredential(ctx, projectID, GitCredentialInput{Host: "github.com", AuthType: GitAuthTypeSSHKey, PrivateKey: key}); err != nil {
+		t.Fatalf("SetGitCredential failed: %v", err)
+	}
+	if _, err := credSvc.SetGitCredential(ctx, projectID, GitCredentialInput{Host: "github.com", AuthType: GitAuthTypeHTTPSToken, Token: "ghp_test"}); err != nil {
+		t.Fatalf("SetGitCredential failed: %v", err)
+	}
+
+	sshCreds, err := credSvc.GitCredentials(ctx, projectID, "git@github.com:org/repo.git")
+	if err != nil || sshCreds == nil {
+		t.Fatalf("Expected SSH credentials, got %v, %v", sshCreds, err)
+	}
+	if string(sshCreds.SSHKey) != key {
+		t.Error("Expected the stored SSH key")
+	}
+
+	httpsCreds, err := credSvc.GitCredentials(ctx, projectID, "https://github.com/org/repo.git")
+	if err != nil || httpsCreds == nil {
+		t.Fatalf("Expected HTTPS credentials, got %v, %v", httpsCreds, err)
+	}
+	if httpsCreds.Username != defaultGitUsername || httpsCreds.Password != "ghp_test" {
+		t.Errorf("Unexpected HTTPS credentials: %+v", httpsCreds)
+	}
+
+	for _, remote := range []string{"https://gitlab.com/org/repo.git", "/home/user/repo"} {
+		creds, err := credSvc.GitCredentials(ctx, projectID, remote)
+		if err != nil || creds != nil {
+			t.Errorf("Expected no credentials for %s, got %v, %v", remote, creds, err)
+		}
+	}
+	if creds, _ := credSvc.GitCredentials(ctx, "other-project", "https://github.com/org/repo.git"); creds != nil {
+		t.Error("Expected credentials to be scoped to their project")
+	}
+}
+
+func TestGetAllDecrypted_ForwardsGitCredentials(t *testing.T) {
+	credSvc := newTestGitCredentialService(t)
+	ctx := context.Background()
+	projectID := "test-project"
+
+	inputs := []GitCredentialInput{
+		{Host: "github.com", AuthType: GitAuthTypeHTTPSToken, Token: "ghp_test", ForwardToSandbox: true},
+		{Host: "gitlab.com", AuthType: GitAuthTypeHTTPSToken, Token: "glpat_private"},
+		{Host: "github.com", AuthType: GitAuthTypeSSHKey, PrivateKey: testSSHKey(t), ForwardToSandbox: true},
+	}
+	for _, input := range inputs {
+		if _, err := credSvc.SetGitCredential(ctx, projectID, input); err != nil {
+			t.Fatalf("SetGitCredential failed: %v", err)
+		}
+	}
+
+	envVars, err := credSvc.GetAllDecrypted(ctx, projectID)
+	if err != nil {
+		t.Fatalf("GetAllDecrypted failed: %v", err)
+	}
+	env := make(map[string]string)
+	for _, e := range envVars {
+		if e.Provider != ProviderGit {
+			t.Errorf("Unexpected provider %s for %s", e.Provider, e.EnvVar)
+		}
+		env[e.EnvVar] = e.Value
+	}
+
+	if env["GIT_CONFIG_COUNT"] != "1" {
+		t.Errorf("Expected one forwarded token, got GIT_CONFIG_COUNT=%q", env["GIT_CONFIG_COUNT"])
+	}
+	if env["GIT_CONFIG_KEY_0"] != "http.https://github.com/.extraHeader" {
+		t.Errorf("Unexpected GIT_CONFIG_KEY_0: %q", env["GIT_CONFIG_KEY_0"])
+	}
+	if !strings.HasPrefix(env["GIT_CONFIG_VALUE_0"], "Authorization: Basic ") {
+		t.Errorf("Unexpected GIT_CONFIG_VALUE_0: %q", env["GIT_CONFIG_VALUE_0"])
+	}
+	if env[gitSSHKeyEnvVar] == "" || !strings.Contains(env["GIT_SSH_COMMAND"], gitSSHKeyEnvVar) {
+		t.Errorf("Expected the SSH key to be forwarded, got %v", env)
+	}
+	for name, value := range env {
+		if strings.Contains(value, "glpat_private") {
+			t.Errorf("Credential not marked for forwarding leaked in %s", name)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// Session imports apply outside work to a session's workspace with
+// discobot-agent import, which reads the patches from stdin.
+
+// importEmptyExitCode is the exit code discobot-agent uses when the input
+// has no changes.
+const importEmptyExitCode = exportEmptyExitCode
+
+// Import errors
+var (
+	ErrInvalidImport     = errors.New("invalid import")
+	ErrNothingToImport   = errors.New("nothing to import")
+	ErrImportSessionBusy = errors.New("cannot import while the session is running or committing")
+)
+
+// ImportSessionRequest imports work into a session. Exactly one of Patch,
+// Ref and SourceSessionID is set.
+type ImportSessionRequest struct {
+	// Patch is an uploaded patch in Format
+	Patch  string `json:"patch,omitempty"`
+	Format string `json:"format,omitempty"` // "mbox" (default, git format-patch output) or "diff"
+	// Ref is a ref of the workspace's origin remote, e.g. a branch or
+	// refs/pull/12/head. Its commits since the session's base are imported.
+	Ref string `json:"ref,omitempty"`
+	// SourceSessionID imports the commits of another session of the project
+	SourceSessionID string `json:"sourceSessionId,omitempty"`
+
+	// KeepConflicts leaves a conflicted import in the workspace, with
+	// conflict markers, instead of undoing it
+	KeepConflicts bool `json:"keepConflicts,omitempty"`
+	// Notify sends the agent a message describing the imported changes
+	Notify bool   `json:"notify,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+}
+
+// ImportedCommit is a commit created in the workspace by an import.
+type ImportedCommit struct {
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+}
+
+// ImportSessionResponse is the outcome of an import.
+type ImportSessionResponse struct {
+	// Applied is false when the import conflicted and was undone
+	Applied bool             `json:"applied"`
+	Commits []ImportedCommit `json:"commits"`
+	Files   []string         `json:"files"`
+	// Conflict describes why the import stopped
+	Conflict *git.ConflictError `json:"conflict,omitempty"`
+	// ConflictsKept is true when the conflicted files were left in the
+	// workspace for the agent or user to resolve
+	ConflictsKept bool   `json:"conflictsKept,omitempty"`
+	MessageID     string `json:"messageId,omitempty"`
+}
+
+// agentImportResult is the JSON output of discobot-agent import.
+type agentImportResult struct {
+	Commits  []ImportedCommit `json:"commits"`
+	Files    []string         `json:"files"`
+	Conflict *struct {
+		Patch string `json:"patch"`
+		Files []struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		} `json:"files"`
+		Kept bool `json:"kept"`
+	} `json:"conflict"`
+}
+
+// ImportSession runs discobot-agent import in the session's sandbox as the
+// workspace owner, with patch as its input. Commits are made with the
+// server's git identity.
+func (s *SandboxService) ImportSession(ctx context.Context, sessionID, format string, keepConflicts bool, patch io.Reader) (*ImportSessionResponse, error) {
+	client, err := s.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := client.GetUserInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	if name, email := s.getGitConfig(ctx); name != "" && email != "" {
+		env["GIT_COMMITTER_NAME"] = name
+		env["GIT_COMMITTER_EMAIL"] = email
+	}
+	cmd := []string{agentBinary, "import", format}
+	if keepConflicts {
+		cmd = append(cmd, "--keep-conflicts")
+	}
+	stream, err := s.provider.ExecStream(ctx, sessionID, cmd, sandbox.ExecStreamOptions{
+		Env:  env,
+		User: strconv.Itoa(userInfo.UID) + ":" + strconv.Itoa(userInfo.GID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run import: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	var wg sync.WaitGroup
+	var stdinErr error
+	var stderr bytes.Buffer
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, stdinErr = io.Copy(stream, patch)
+		_ = stream.CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		if r := stream.Stderr(); r != nil {
+			_, _ = io.Copy(&stderr, io.LimitReader(r, 64*1024))
+		}
+	}()
+	stdout, readErr := io.ReadAll(stream)
+	code, waitErr := stream.Wait(ctx)
+	wg.Wait()
+
+	switch {
+	case readErr != nil:
+		return nil, fmt.Errorf("failed to read import output: %w", readErr)
+	case waitErr != nil:
+		return nil, fmt.Errorf("import failed: %w", waitErr)
+	case stdinErr != nil:
+		return nil, fmt.Errorf("failed to send patch: %w", stdinErr)
+	case code == importEmptyExitCode:
+		return nil, ErrNothingToImport
+	case code != 0:
+		return nil, fmt.Errorf("import failed (exit code %d): %s", code, strings.TrimSpace(stderr.String()))
+	}
+
+	var out agentImportResult
+	if err := json.Unmarshal(stdout, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse import output: %w", err)
+	}
+	result := &ImportSessionResponse{
+		Applied: out.Conflict == nil,
+		Commits: out.Commits,
+		Files:   out.Files,
+	}
+	if out.Conflict != nil {
+		result.Conflict = &git.ConflictError{Patch: out.Conflict.Patch}
+		for _, f := range out.Conflict.Files {
+			result.Conflict.Conflicts = append(result.Conflict.Conflicts, git.Conflict{Path: f.Path, Hunks: git.ParseConflictHunks(f.Content)})
+		}
+		result.ConflictsKept = out.Conflict.Kept
+	}
+	return result, nil
+}
+
+// ============================================================================
+// Import Methods
+// ============================================================================
+
+// ImportSession applies an uploaded patch, the commits of a ref of the
+// workspace's remote, or the commits of another session to the session's
+// workspace. A conflicted import is reported in the response rather than as
+// an error. With Notify, the agent is told what changed.
+func (c *ChatService) ImportSession(ctx context.Context, projectID, sessionID string, req ImportSessionRequest) (*ImportSessionResponse, error) {
+	sources := 0
+	for _, set := range []bool{req.Patch != "", req.Ref != "", req.SourceSessionID != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("%w: exactly one of patch, ref and sourceSessionId is required", ErrInvalidImport)
+	}
+	format := req.Format
+	if format == "" {
+		format = "mbox"
+	}
+	if format != "mbox" && (format != "diff" || req.Patch == "") {
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, req.Format)
+	}
+
+	sess, err := c.GetSession(ctx, projectID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case sess.Status == model.SessionStatusRunning:
+		return nil, ErrImportSessionBusy
+	case sess.CommitStatus == model.CommitStatusPending, sess.CommitStatus == model.CommitStatusCommitting, sess.CommitStatus == model.CommitStatusConflicted:
+		// The agent may be rebasing onto the commit target after a conflict
+		return nil, ErrImportSessionBusy
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+
+	var patch io.Reader
+	var source string
+	switch {
+	case req.Patch != "":
+		patch = strings.NewReader(req.Patch)
+		source = "a patch"
+		if format == "diff" {
+			source = "a diff"
+		}
+
+	case req.Ref != "":
+		patches, err := c.refPatches(ctx, sess, req.Ref)
+		if err != nil {
+			return nil, err
+		}
+		patch = bytes.NewReader(patches)
+		source = fmt.Sprintf("the commits of `%s` from the repository's remote", req.Ref)
+
+	default:
+		if req.SourceSessionID == sessionID {
+			return nil, fmt.Errorf("%w: a session cannot import its own commits", ErrInvalidImport)
+		}
+		export, err := c.ExportSession(ctx, projectID, req.SourceSessionID, "mbox")
+		if errors.Is(err, ErrNothingToExport) {
+			return nil, fmt.Errorf("%w: the source session has no commits", ErrNothingToImport)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to export source session: %w", err)
+		}
+		defer func() { _ = export.Body.Close() }()
+		patch = export.Body
+		source = "the commits of another session"
+		if src, err := c.store.GetSessionByID(ctx, req.SourceSessionID); err == nil && src.Name != "" {
+			source = fmt.Sprintf("the commits of the session %q", src.Name)
+		}
+	}
+
+	result, err := c.sandboxService.ImportSession(ctx, sessionID, format, req.KeepConflicts, patch)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Session %s: imported %s (%d commits, %d files, conflicts: %v)", sessionID, source, len(result.Commits), len(result.Files), result.Conflict != nil)
+
+	if req.Notify && (result.Applied || result.ConflictsKept) {
+		messageID, err := c.StartPrompt(context.WithoutCancel(ctx), projectID, sessionID, importPrompt(source, format, result), req.Model, req.Mode)
+		if err != nil {
+			// The import itself succeeded; the caller can still tell the agent
+			log.Printf("Session %s: failed to notify the agent about the import: %v", sessionID, err)
+		} else {
+			result.MessageID = messageID
+		}
+	}
+	return result, nil
+}
+
+// refPatches fetches ref into the session's workspace on the server, where
+// the project's git credentials are available, and returns its commits since
+// the session's base as patches.
+func (c *ChatService) refPatches(ctx context.Context, sess *model.Session, ref string) ([]byte, error) {
+	if c.gitService == nil {
+		return nil, fmt.Errorf("git provider not available")
+	}
+	var base string
+	switch {
+	case sess.BaseCommit != nil && *sess.BaseCommit != "":
+		base = *sess.BaseCommit
+	case sess.WorkspaceCommit != nil && *sess.WorkspaceCommit != "":
+		base = *sess.WorkspaceCommit
+	default:
+		return nil, fmt.Errorf("%w: the session has no base commit", ErrInvalidImport)
+	}
+
+	commit, err := c.gitService.FetchRef(ctx, sess.WorkspaceID, ref)
+	if err != nil {
+		if errors.Is(err, git.ErrInvalidRef) || errors.Is(err, git.ErrFetchFailed) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		return nil, err
+	}
+	patches, err := c.gitService.FormatPatch(ctx, sess.WorkspaceID, base, commit)
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("%w: %s has no commits since the session's base", ErrNothingToImport, ref)
+	}
+	return patches, nil
+}
+
+// importPrompt tells the agent what an import changed in its workspace.
+func importPrompt(source, format string, result *ImportSessionResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "I imported %s into the workspace.\n", source)
+	if len(result.Commits) > 0 {
+		b.WriteString("\nNew commits:\n")
+		for _, commit := range result.Commits {
+			fmt.Fprintf(&b, "- %.12s %s\n", commit.SHA, commit.Subject)
+		}
+	} else if format == "diff" && result.Applied {
+		b.WriteString("\nThe changes are uncommitted.\n")
+	}
+	if len(result.Files) > 0 {
+		b.WriteString("\nChanged files:\n")
+		for _, path := range result.Files {
+			fmt.Fprintf(&b, "- `%s`\n", path)
+		}
+	}
+	if result.Conflict != nil && result.ConflictsKept {
+		b.WriteString("\nThe import stopped with conflicts")
+		if result.Conflict.Patch != "" {
+			fmt.Fprintf(&b, " while applying %q", result.Conflict.Patch)
+		}
+		b.WriteString(". These files still contain conflict markers:\n")
+		for _, conflict := range result.Conflict.Conflicts {
+			fmt.Fprintf(&b, "- `%s`\n", conflict.Path)
+		}
+		if format == "mbox" {
+			b.WriteString("\nResolve the conflicts, stage the files and run `git am --continue` to apply the remaining patches.\n")
+		} else {
+			b.WriteString("\nResolve the conflicts and remove the markers.\n")
+		}
+	} else {
+		b.WriteString("\nReview the changes before continuing your work.\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/mock"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// importSandbox serves the sandbox user and records chat prompts.
+type importSandbox struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (s *importSandbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/user":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(sandboxapi.UserResponse{Username: "discobot", UID: 1000, GID: 1000})
+	case strings.HasSuffix(r.URL.Path, "/chat") && r.Method == "POST":
+		body, _ := io.ReadAll(r.Body)
+		s.prompts = append(s.prompts, string(body))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasSuffix(r.URL.Path, "/chat") && r.Method == "GET":
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "data: {\"type\":\"start\"}\n\ndata: [DONE]\n\n")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestChatService_ImportSession(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, commit)
+	session.CommitStatus = model.CommitStatusNone
+	if err := env.store.UpdateSession(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	sb := &importSandbox{}
+	env.mockSandbox.HTTPHandler = sb
+	if _, err := env.mockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	var gotCmd []string
+	var stdin *mock.Stream
+	output, exitCode := "", 0
+	env.mockSandbox.ExecStreamFunc = func(_ context.Context, _ string, cmd []string, _ sandbox.ExecStreamOptions) (sandbox.Stream, error) {
+		gotCmd = cmd
+		stdin = &mock.Stream{OutputBuffer: []byte(output)}
+		return &exportTestStream{Stream: stdin, exitCode: exitCode}, nil
+	}
+
+	enqueuer := &recordingEnqueuer{}
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sandboxSvc.SetSessionInitializer(&testSessionInitializer{})
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, enqueuer)
+	chatSvc := NewChatService(env.store, sessionSvc, enqueuer, env.eventBroker, sandboxSvc, env.gitService)
+
+	output = `{"commits":[{"sha":"0123456789abcdef","subject":"Colleague's work"}],"files":["main.go"]}`
+	result, err := chatSvc.ImportSession(ctx, project.ID, session.ID, ImportSessionRequest{Patch: "From 0123\n", Notify: true})
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	if !result.Applied || len(result.Commits) != 1 || result.MessageID == "" {
+		t.Errorf("Unexpected import result %+v", result)
+	}
+	if want := []string{agentBinary, "import", "mbox"}; strings.Join(gotCmd, " ") != strings.Join(want, " ") {
+		t.Errorf("Ran %v, want %v", gotCmd, want)
+	}
+	if string(stdin.InputBuffer) != "From 0123\n" || !stdin.WritesClosed {
+		t.Errorf("Expected the patch on stdin, got %q", stdin.InputBuffer)
+	}
+	if len(sb.prompts) != 1 || !strings.Contains(sb.prompts[0], "Colleague's work") || !strings.Contains(sb.prompts[0], "`main.go`") {
+		t.Errorf("Unexpected notification %v", sb.prompts)
+	}
+
+	// Conflicts are reported with their hunks
+	if err := env.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	output = `{"commits":[],"files":["main.go"],"conflict":{"files":[{"path":"main.go","content":"<<<<<<< ours\na\n=======\nb\n>>>>>>> theirs\n"}],"kept":true}}`
+	result, err = chatSvc.ImportSession(ctx, project.ID, session.ID, ImportSessionRequest{Patch: "diff --git a/main.go b/main.go\n", Format: "diff", KeepConflicts: true})
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	if result.Applied || !result.ConflictsKept || result.Conflict == nil || len(result.Conflict.Conflicts) != 1 {
+		t.Fatalf("Expected a kept conflict, got %+v", result)
+	}
+	if hunks := result.Conflict.Conflicts[0].Hunks; len(hunks) != 1 || hunks[0].Ours != "a\n" || hunks[0].Theirs != "b\n" {
+		t.Errorf("Unexpected conflict hunks %+v", hunks)
+	}
+	if want := []string{agentBinary, "import", "diff", "--keep-conflicts"}; strings.Join(gotCmd, " ") != strings.Join(want, " ") {
+		t.Errorf("Ran %v, want %v", gotCmd, want)
+	}
+
+	output, exitCode = "", importEmptyExitCode
+	if _, err := chatSvc.ImportSession(ctx, project.ID, session.ID, ImportSessionRequest{Patch: "\n"}); !errors.Is(err, ErrNothingToImport) {
+		t.Errorf("Expected ErrNothingToImport, got %v", err)
+	}
+
+	for _, req := range []ImportSessionRequest{
+		{},
+		{Patch: "x", Ref: "main"},
+		{Ref: "main", Format: "diff"},
+		{Patch: "x", Format: "zip"},
+		{SourceSessionID: session.ID},
+	} {
+		if _, err := chatSvc.ImportSession(ctx, project.ID, session.ID, req); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("Expected ErrInvalidImport for %+v, got %v", req, err)
+		}
+	}
+
+	session.CommitStatus = model.CommitStatusConflicted
+	if err := env.store.UpdateSession(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if _, err := chatSvc.ImportSession(ctx, project.ID, session.ID, ImportSessionRequest{Patch: "x"}); !errors.Is(err, ErrImportSessionBusy) {
+		t.Errorf("Expected ErrImportSessionBusy while the commit conflicts, got %v", err)
+	}
+
+	if err := env.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusRunning, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	if _, err := chatSvc.ImportSession(ctx, project.ID, session.ID, ImportSessionRequest{Patch: "x"}); !errors.Is(err, ErrImportSessionBusy) {
+		t.Errorf("Expected ErrImportSessionBusy, got %v", err)
+	}
+}
+
+func TestImportPrompt(t *testing.T) {
+	result := &ImportSessionResponse{
+		Commits:       []ImportedCommit{{SHA: "0123456789abcdef", Subject: "Add parser"}},
+		Files:         []string{"parser.go", "lexer.go"},
+		Conflict:      &git.ConflictError{Patch: "Add lexer", Conflicts: []git.Conflict{{Path: "lexer.go"}}},
+		ConflictsKept: true,
+	}
+	prompt := importPrompt("a patch", "mbox", result)
+	for _, want := range []string{"I imported a patch", "- 0123456789ab Add parser", "- `parser.go`", `while applying "Add lexer"`, "git am --continue"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Job errors
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobNotRetryable   = errors.New("only failed or cancelled jobs can be retried")
+	ErrJobNotCancellable = errors.New("only pending or running jobs can be cancelled")
+)
+
+// jobCancelledReason is stored as the error of jobs cancelled through the API.
+const jobCancelledReason = "cancelled by user"
+
+// JobCanceller stops a job that is executing. It is implemented by the dispatcher.
+type JobCanceller interface {
+	// CancelJob cancels the job's context. Returns false if the job is not
+	// executing on this server.
+	CancelJob(jobID string) bool
+}
+
+// Job represents a background job (for API responses)
+type Job struct {
+	ID           string          `json:"id"`
+	ProjectID    string          `json:"projectId,omitempty"`
+	Type         string          `json:"type"`
+	Status       string          `json:"status"`
+	Payload      json.RawMessage `json:"payload"`
+	Error        string          `json:"error,omitempty"`
+	Attempts     int             `json:"attempts"`
+	MaxAttempts  int             `json:"maxAttempts"`
+	Priority     int             `json:"priority"`
+	ResourceType string          `json:"resourceType,omitempty"`
+	ResourceID   string          `json:"resourceId,omitempty"`
+	WorkerID     string          `json:"workerId,omitempty"`
+	ScheduledAt  time.Time       `json:"scheduledAt"`
+	StartedAt    *time.Time      `json:"startedAt,omitempty"`
+	CompletedAt  *time.Time      `json:"completedAt,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+// JobService handles job administration
+type JobService struct {
+	store       *store.Store
+	eventBroker *events.Broker
+	canceller   JobCanceller
+}
+
+// NewJobService creates a new job service
+func NewJobService(s *store.Store, eventBroker *events.Broker) *JobService {
+	return &JobService{
+		store:       s,
+		eventBroker: eventBroker,
+	}
+}
+
+// SetCanceller sets the component used to stop running jobs.
+// Without one, cancelling a running job only changes its status.
+func (s *JobService) SetCanceller(c JobCanceller) {
+	s.canceller = c
+}
+
+// ListJobs returns jobs matching the filter, newest first.
+func (s *JobService) ListJobs(ctx context.Context, filter store.JobFilter) ([]*Job, error) {
+	dbJobs, err := s.store.ListJobs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	result := make([]*Job, len(dbJobs))
+	for i, job := range dbJobs {
+		result[i] = mapJob(job)
+	}
+	return result, nil
+}
+
+// GetJob returns a job. If projectID is non-empty the job must belong to
+// that project.
+func (s *JobService) GetJob(ctx context.Context, projectID, jobID string) (*Job, error) {
+	job, err := s.getJob(ctx, projectID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return mapJob(job), nil
+}
+
+// RetryJob requeues a failed or cancelled job for one more attempt.
+// Returns jobs.ErrJobAlreadyExists if another job for the same resource is
+// pending or running.
+func (s *JobService) RetryJob(ctx context.Context, projectID, jobID string) (*Job, error) {
+	job, err := s.getJob(ctx, projectID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != string(model.JobStatusFailed) && job.Status != string(model.JobStatusCancelled) {
+		return nil, ErrJobNotRetryable
+	}
+
+	if job.ResourceType != nil && job.ResourceID != nil {
+		active, err := s.store.HasActiveJobForResource(ctx, *job.ResourceType, *job.ResourceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for active jobs: %w", err)
+		}
+		if active {
+			return nil, jobs.ErrJobAlreadyExists
+		}
+	}
+
+	ok, err := s.store.RetryJob(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry job: %w", err)
+	}
+	if !ok {
+		return nil, ErrJobNotRetryable
+	}
+
+	job, err = s.store.GetJobByID(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	s.publishJobUpdated(ctx, job)
+
+	return mapJob(job), nil
+}
+
+// CancelJob cancels a pending or running job. A running job's context is
+// cancelled so the executor stops; it is not retried.
+func (s *JobService) CancelJob(ctx context.Context, projectID, jobID string) (*Job, error) {
+	if _, err := s.getJob(ctx, projectID, jobID); err != nil {
+		return nil, err
+	}
+
+	ok, err := s.store.CancelJob(ctx, jobID, jobCancelledReason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	if !ok {
+		return nil, ErrJobNotCancellable
+	}
+
+	// If the job runs on another server, its dispatcher picks up the
+	// cancellation from the database.
+	if s.canceller != nil {
+		s.canceller.CancelJob(jobID)
+	}
+
+	job, err := s.store.GetJobByID(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	s.publishJobUpdated(ctx, job)
+	s.publishJobCancelled(ctx, job)
+
+	return mapJob(job), nil
+}
+
+func (s *JobService) getJob(ctx context.Context, projectID, jobID string) (*model.Job, error) {
+	job, err := s.store.GetJobByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if projectID != "" && (job.ProjectID == nil || *job.ProjectID != projectID) {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *JobService) publishJobUpdated(ctx context.Context, job *model.Job) {
+	if s.eventBroker == nil {
+		return
+	}
+	if err := s.eventBroker.PublishJobUpdated(ctx, job); err != nil {
+		log.Printf("Failed to publish job update event for job %s: %v", job.ID, err)
+	}
+}
+
+// publishJobCancelled publishes the job_completed event the dispatcher
+// would have sent had the job finished.
+func (s *JobService) publishJobCancelled(ctx context.Context, job *model.Job) {
+	if s.eventBroker == nil || job.ProjectID == nil {
+		return
+	}
+	j := mapJob(job)
+	if err := s.eventBroker.PublishJobCompleted(ctx, *job.ProjectID, j.ID, j.Type, j.ResourceType, j.ResourceID, j.Status, j.Error); err != nil {
+		log.Printf("Failed to publish job completion event for job %s: %v", job.ID, err)
+	}
+}
+
+// mapJob maps a model.Job to a service.Job
+func mapJob(job *model.Job) *Job {
+	j := &Job{
+		ID:          job.ID,
+		Type:        job.Type,
+		Status:      job.Status,
+		Payload:     job.Payload,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		Priority:    job.Priority,
+		ScheduledAt: job.ScheduledAt,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+		CreatedAt:   job.CreatedAt,
+	}
+	if job.ProjectID != nil {
+		j.ProjectID = *job.ProjectID
+	}
+	if job.Error != nil {
+		j.Error = *job.Error
+	}
+	if job.ResourceType != nil {
+		j.ResourceType = *job.ResourceType
+	}
+	if job.ResourceID != nil {
+		j.ResourceID = *job.ResourceID
+	}
+	if job.WorkerID != nil {
+		j.WorkerID = *job.WorkerID
+	}
+	return j
+}
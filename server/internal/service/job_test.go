@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// recordingCanceller records the jobs the service asked to cancel.
+type recordingCanceller struct {
+	cancelled []string
+}
+
+func (c *recordingCanceller) CancelJob(jobID string) bool {
+	c.cancelled = append(c.cancelled, jobID)
+	return true
+}
+
+func createTestJob(t *testing.T, env *testEnv, projectID, jobType, status, resourceID string) *model.Job {
+	t.Helper()
+	resourceType := jobs.ResourceTypeSession
+	job := &model.Job{
+		ProjectID:    &projectID,
+		Type:         jobType,
+		Payload:      []byte(`{"projectId":"` + projectID + `","sessionId":"` + resourceID + `"}`),
+		Status:       status,
+		MaxAttempts:  3,
+		ResourceType: &resourceType,
+		ResourceID:   &resourceID,
+	}
+	if status == string(model.JobStatusFailed) {
+		job.Attempts = job.MaxAttempts
+	}
+	if err := env.store.CreateJob(context.Background(), job); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	return job
+}
+
+func projectEventTypes(t *testing.T, env *testEnv, projectID string) []events.EventType {
+	t.Helper()
+	evts, err := env.store.ListProjectEventsSince(context.Background(), projectID, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	types := make([]events.EventType, len(evts))
+	for i, e := range evts {
+		types[i] = events.EventType(e.Type)
+	}
+	return types
+}
+
+func TestJobService_ListAndGet(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+	project := env.createTestProject(t)
+
+	initJob := createTestJob(t, env, project.ID, string(jobs.JobTypeSessionInit), string(model.JobStatusFailed), "s1")
+	createTestJob(t, env, project.ID, string(jobs.JobTypeSessionCommit), string(model.JobStatusPending), "s2")
+	otherJob := createTestJob(t, env, "other-project", string(jobs.JobTypeSessionInit), string(model.JobStatusFailed), "s3")
+
+	svc := NewJobService(env.store, env.eventBroker)
+
+	all, err := svc.ListJobs(ctx, store.JobFilter{ProjectID: project.ID})
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 project jobs, got %d", len(all))
+	}
+
+	failed, err := svc.ListJobs(ctx, store.JobFilter{Status: string(model.JobStatusFailed)})
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(failed) != 2 {
+		t.Errorf("expected 2 failed jobs across projects, got %d", len(failed))
+	}
+
+	byResource, err := svc.ListJobs(ctx, store.JobFilter{ProjectID: project.ID, ResourceType: jobs.ResourceTypeSession, ResourceID: "s1"})
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(byResource) != 1 || byResource[0].ID != initJob.ID {
+		t.Errorf("unexpected jobs for resource: %+v", byResource)
+	}
+
+	job, err := svc.GetJob(ctx, project.ID, initJob.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.ResourceID != "s1" || string(job.Payload) == "" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	if _, err := svc.GetJob(ctx, project.ID, otherJob.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound for another project's job, got %v", err)
+	}
+	if _, err := svc.GetJob(ctx, "", otherJob.ID); err != nil {
+		t.Errorf("admin GetJob: %v", err)
+	}
+}
+
+func TestJobService_Cancel(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+	project := env.createTestProject(t)
+
+	job := createTestJob(t, env, project.ID, string(jobs.JobTypeSessionInit), string(model.JobStatusRunning), "s1")
+
+	svc := NewJobService(env.store, env.eventBroker)
+	canceller := &recordingCanceller{}
+	svc.SetCanceller(canceller)
+
+	cancelled, err := svc.CancelJob(ctx, project.ID, job.ID)
+	if err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	if cancelled.Status != string(model.JobStatusCancelled) || cancelled.CompletedAt == nil {
+		t.Errorf("unexpected job after cancel: %+v", cancelled)
+	}
+	if len(canceller.cancelled) != 1 || canceller.cancelled[0] != job.ID {
+		t.Errorf("expected running job to be cancelled, got %v", canceller.cancelled)
+	}
+
+	types := projectEventTypes(t, env, project.ID)
+	if len(types) != 2 || types[0] != events.EventTypeJobUpdated || types[1] != events.EventTypeJobCompleted {
+		t.Errorf("unexpected events: %v", types)
+	}
+
+	if _, err := svc.CancelJob(ctx, project.ID, job.ID); !errors.Is(err, ErrJobNotCancellable) {
+		t.Errorf("expected ErrJobNotCancellable, got %v", err)
+	}
+}
+
+func TestJobService_Retry(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+	project := env.createTestProject(t)
+
+	job := createTestJob(t, env, project.ID, string(jobs.JobTypeSessionInit), string(model.JobStatusFailed), "s1")
+
+	svc := NewJobService(env.store, env.eventBroker)
+
+	// Another job for the same session blocks the retry
+	blocker := createTestJob(t, env, project.ID, string(jobs.JobTypeSessionCommit), string(model.JobStatusPending), "s1")
+	if _, err := svc.RetryJob(ctx, project.ID, job.ID); !errors.Is(err, jobs.ErrJobAlreadyExists) {
+		t.Errorf("expected ErrJobAlreadyExists, got %v", err)
+	}
+	if _, err := svc.CancelJob(ctx, project.ID, blocker.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+
+	retried, err := svc.RetryJob(ctx, project.ID, job.ID)
+	if err != nil {
+		t.Fatalf("RetryJob: %v", err)
+	}
+	if retried.Status != string(model.JobStatusPending) || retried.Error != "" {
+		t.Errorf("unexpected job after retry: %+v", retried)
+	}
+	if retried.MaxAttempts != 4 {
+		t.Errorf("expected one more attempt (max 4), got %d", retried.MaxAttempts)
+	}
+
+	if _, err := svc.RetryJob(ctx, project.ID, job.ID); !errors.Is(err, ErrJobNotRetryable) {
+		t.Errorf("expected ErrJobNotRetryable for pending job, got %v", err)
+	}
+}
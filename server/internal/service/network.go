@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// The proxy in each sandbox records the HTTP traffic through it and serves
+// it on its API port, which is not published on the host. The server reaches
+// it through socat in the sandbox, the way SSH port forwarding does.
+
+// proxyAPIPort is the port of the proxy's API inside the sandbox.
+const proxyAPIPort = 17081
+
+// proxyAPITimeout bounds a request to the proxy API.
+const proxyAPITimeout = 30 * time.Second
+
+// NetworkEntry is a request recorded by the sandbox proxy. Sensitive header
+// values, including those the proxy injected, are redacted.
+type NetworkEntry struct {
+	ID        uint64    `json:"id"`
+	StartedAt time.Time `json:"startedAt"`
+
+	Method         string       `json:"method"`
+	URL            string       `json:"url"`
+	Proto          string       `json:"proto"`
+	RequestHeaders http.Header  `json:"requestHeaders"`
+	RequestSize    int64        `json:"requestSize"`
+	RequestBody    *NetworkBody `json:"requestBody,omitempty"`
+
+	Status          int          `json:"status,omitempty"`
+	ResponseHeaders http.Header  `json:"responseHeaders,omitempty"`
+	ResponseSize    int64        `json:"responseSize"`
+	ResponseBody    *NetworkBody `json:"responseBody,omitempty"`
+
+	WaitMs     float64 `json:"waitMs"`     // Until the response headers arrived
+	DurationMs float64 `json:"durationMs"` // Until the response body was read
+
+	Cache   string `json:"cache,omitempty"` // "HIT" for responses served from the proxy cache
+	Blocked bool   `json:"blocked,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Done    bool   `json:"done"`
+}
+
+// proxyNetworkEntry is a NetworkEntry as the proxy API encodes it.
+type proxyNetworkEntry struct {
+	ID        uint64    `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+
+	Method         string       `json:"method"`
+	URL            string       `json:"url"`
+	Proto          string       `json:"proto"`
+	RequestHeaders http.Header  `json:"request_headers"`
+	RequestSize    int64        `json:"request_size"`
+	RequestBody    *NetworkBody `json:"request_body,omitempty"`
+
+	Status          int          `json:"status,omitempty"`
+	ResponseHeaders http.Header  `json:"response_headers,omitempty"`
+	ResponseSize    int64        `json:"response_size"`
+	ResponseBody    *NetworkBody `json:"response_body,omitempty"`
+
+	WaitMs     float64 `json:"wait_ms"`
+	DurationMs float64 `json:"duration_ms"`
+
+	Cache   string `json:"cache,omitempty"`
+	Blocked bool   `json:"blocked,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Done    bool   `json:"done"`
+}
+
+// NetworkBody is the recorded start of a request or response body.
+type NetworkBody struct {
+	Text      string `json:"text"`
+	Encoding  string `json:"encoding,omitempty"` // "base64" for binary bodies
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// NetworkFilter selects recorded requests. Zero values select everything.
+type NetworkFilter struct {
+	Since uint64 // Only requests with a greater ID
+	Host  string // Only requests whose host contains Host
+	Limit int    // At most the Limit most recent requests
+}
+
+func (f NetworkFilter) query() string {
+	q := url.Values{}
+	if f.Since > 0 {
+		q.Set("since", strconv.FormatUint(f.Since, 10))
+	}
+	if f.Host != "" {
+		q.Set("host", f.Host)
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+// ListNetwork returns the requests recorded by the proxy in the session's
+// sandbox, oldest first.
+func (s *SandboxService) ListNetwork(ctx context.Context, sessionID string, f NetworkFilter) ([]NetworkEntry, error) {
+	var resp struct {
+		Entries []proxyNetworkEntry `json:"entries"`
+	}
+	if err := s.proxyAPI(ctx, sessionID, http.MethodGet, "/api/network"+f.query(), nil, &resp); err != nil {
+		return nil, err
+	}
+	entries := make([]NetworkEntry, len(resp.Entries))
+	for i, e := range resp.Entries {
+		entries[i] = NetworkEntry(e)
+	}
+	return entries, nil
+}
+
+// ExportNetworkHAR returns the requests recorded by the proxy in the
+// session's sandbox as a HAR document.
+func (s *SandboxService) ExportNetworkHAR(ctx context.Context, sessionID string, f NetworkFilter) (json.RawMessage, error) {
+	var har json.RawMessage
+	if err := s.proxyAPI(ctx, sessionID, http.MethodGet, "/api/network/har"+f.query(), nil, &har); err != nil {
+		return nil, err
+	}
+	return har, nil
+}
+
+// ClearNetwork discards the requests recorded by the proxy in the session's
+// sandbox.
+func (s *SandboxService) ClearNetwork(ctx context.Context, sessionID string) error {
+	return s.proxyAPI(ctx, sessionID, http.MethodDelete, "/api/network", nil, nil)
+}
+
+// proxyAPI sends a request to the proxy API in the session's sandbox and
+// decodes the JSON response into out, if not nil. Unlike agent API calls,
+// it does not start a stopped sandbox: it returns sandbox.ErrNotRunning.
+func (s *SandboxService) proxyAPI(ctx context.Context, sessionID, method, path string, body, out any) error {
+	sb, err := s.provider.Get(ctx, sessionID)
+	if errors.Is(err, sandbox.ErrNotFound) || (err == nil && sb.Status != sandbox.StatusRunning) {
+		return sandbox.ErrNotRunning
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get sandbox: %w", err)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proxyAPITimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, "http://proxy"+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.proxyAPIClient(sessionID).Do(req)
+	if err != nil {
+		return fmt.Errorf("proxy API request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("proxy API returned status %d: %s", resp.StatusCode, errResp.Error)
+		}
+		return fmt.Errorf("proxy API returned status %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode proxy API response: %w", err)
+	}
+	return nil
+}
+
+// proxyAPIClient returns an HTTP client whose connections are socat
+// processes in the session's sandbox connected to the proxy API.
+func (s *SandboxService) proxyAPIClient(sessionID string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				// The stream outlives the dial, so it ends with the connection
+				cmd := []string{"socat", "-", "TCP:127.0.0.1:" + strconv.Itoa(proxyAPIPort)}
+				stream, err := s.provider.ExecStream(context.WithoutCancel(ctx), sessionID, cmd, sandbox.ExecStreamOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to connect to proxy API: %w", err)
+				}
+				return &streamConn{Stream: stream}, nil
+			},
+			// Each connection is a process in the sandbox; don't keep them around
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+// streamConn adapts a sandbox.Stream to a net.Conn. Deadlines are not
+// supported; requests are bounded by their context instead.
+type streamConn struct {
+	sandbox.Stream
+}
+
+func (c *streamConn) LocalAddr() net.Addr                { return streamAddr{} }
+func (c *streamConn) RemoteAddr() net.Addr               { return streamAddr{} }
+func (c *streamConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+// streamAddr is the address of both ends of a streamConn.
+type streamAddr struct{}
+
+func (streamAddr) Network() string { return "sandbox-exec" }
+func (streamAddr) String() string  { return "sandbox-exec" }
+
+// ============================================================================
+// Network Methods
+// ============================================================================
+
+// ListNetwork returns the HTTP requests recorded in the session's sandbox.
+// Recordings live in the sandbox, so a stopped sandbox is not started; the
+// call fails with sandbox.ErrNotRunning instead.
+func (c *ChatService) ListNetwork(ctx context.Context, projectID, sessionID string, f NetworkFilter) ([]NetworkEntry, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.ListNetwork(ctx, sessionID, f)
+}
+
+// ExportNetworkHAR returns the HTTP requests recorded in the session's
+// sandbox as a HAR document.
+func (c *ChatService) ExportNetworkHAR(ctx context.Context, projectID, sessionID string, f NetworkFilter) (json.RawMessage, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.ExportNetworkHAR(ctx, sessionID, f)
+}
+
+// ClearNetwork discards the HTTP requests recorded in the session's sandbox.
+func (c *ChatService) ClearNetwork(ctx context.Context, projectID, sessionID string) error {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return err
+	}
+	if c.sandboxService == nil {
+		return fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.ClearNetwork(ctx, sessionID)
+}
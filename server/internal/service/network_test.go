@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// proxyTestStream is a stream connected to an HTTP handler, standing in for
+// socat connected to the proxy API.
+type proxyTestStream struct {
+	net.Conn
+}
+
+func newProxyTestStream(handler http.Handler) *proxyTestStream {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		req, err := http.ReadRequest(bufio.NewReader(server))
+		if err != nil {
+			return
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		_ = rec.Result().Write(server)
+	}()
+	return &proxyTestStream{Conn: client}
+}
+
+func (s *proxyTestStream) Stderr() io.Reader                        { return nil }
+func (s *proxyTestStream) Resize(_ context.Context, _, _ int) error { return nil }
+func (s *proxyTestStream) CloseWrite() error                        { return nil }
+func (s *proxyTestStream) Wait(_ context.Context) (int, error)      { return 0, nil }
+
+func TestChatService_Network(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, commit)
+
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
+	chatSvc := NewChatService(env.store, sessionSvc, nil, env.eventBroker, sandboxSvc, env.gitService)
+
+	// Recordings are not started up with the sandbox
+	if _, err := chatSvc.ListNetwork(ctx, project.ID, session.ID, NetworkFilter{}); !errors.Is(err, sandbox.ErrNotRunning) {
+		t.Fatalf("Expected ErrNotRunning without a sandbox, got %v", err)
+	}
+
+	if _, err := env.mockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	var gotCmd []string
+	var gotRequests []string
+	env.mockSandbox.ExecStreamFunc = func(_ context.Context, _ string, cmd []string, _ sandbox.ExecStreamOptions) (sandbox.Stream, error) {
+		gotCmd = cmd
+		return newProxyTestStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotRequests = append(gotRequests, r.Method+" "+r.URL.RequestURI())
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/network":
+				if r.Method == http.MethodDelete {
+					_, _ = w.Write([]byte(`{"status":"ok"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"entries":[{"id":42,"method":"GET","url":"https://api.github.com/user","status":200,"request_headers":{"Authorization":["[REDACTED]"]},"done":true}]}`))
+			case "/api/network/har":
+				_, _ = w.Write([]byte(`{"log":{"version":"1.2","entries":[]}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"not found"}`))
+			}
+		})), nil
+	}
+
+	entries, err := chatSvc.ListNetwork(ctx, project.ID, session.ID, NetworkFilter{Since: 41, Host: "github.com"})
+	if err != nil {
+		t.Fatalf("ListNetwork failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != 42 || entries[0].RequestHeaders.Get("Authorization") != "[REDACTED]" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+	if want := "socat - TCP:127.0.0.1:17081"; strings.Join(gotCmd, " ") != want {
+		t.Errorf("Ran %v, want %q", gotCmd, want)
+	}
+
+	har, err := chatSvc.ExportNetworkHAR(ctx, project.ID, session.ID, NetworkFilter{})
+	if err != nil {
+		t.Fatalf("ExportNetworkHAR failed: %v", err)
+	}
+	var doc struct {
+		Log struct {
+			Version string `json:"version"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(har, &doc); err != nil || doc.Log.Version != "1.2" {
+		t.Errorf("Unexpected HAR %s: %v", har, err)
+	}
+
+	if err := chatSvc.ClearNetwork(ctx, project.ID, session.ID); err != nil {
+		t.Fatalf("ClearNetwork failed: %v", err)
+	}
+
+	want := []string{"GET /api/network?host=github.com&since=41", "GET /api/network/har", "DELETE /api/network"}
+	if strings.Join(gotRequests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Proxy API got %v, want %v", gotRequests, want)
+	}
+
+	if _, err := chatSvc.ListNetwork(ctx, project.ID, "missing", NetworkFilter{}); err == nil {
+		t.Error("Expected an error for an unknown session")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -792,6 +793,249 @@ index 0000000..abc123
 	}
 }
 
+// TestPerformCommit_ConflictRebased tests that conflicting patches put the
+// commit in the conflicted state, ask the agent to rebase, and apply the
+// rebased patches.
+func TestPerformCommit_ConflictRebased(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, initialCommit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, initialCommit)
+
+	// The agent edits README.md while the workspace edits the same line
+	conflicting := agentPatch(t, workspace.Path, "README.md", "# Agent\n")
+	newCommit := env.addCommitToWorkspace(t, workspace.Path, "README.md", "# Workspace\n")
+	rebased := agentPatch(t, workspace.Path, "README.md", "# Workspace\n# Agent\n")
+
+	var mu sync.Mutex
+	var commitsCount int
+	var prompts []string
+	handler := &trackingHandler{
+		onChat: func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			prompts = append(prompts, string(body))
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		},
+		onCommits: func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			commitsCount++
+			patches := conflicting
+			if commitsCount > 1 {
+				patches = rebased
+			}
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sandboxapi.CommitsResponse{Patches: patches, CommitCount: 1})
+		},
+	}
+	sessionSvc := env.startSandbox(t, session.ID, handler)
+
+	if err := sessionSvc.PerformCommit(context.Background(), project.ID, session.ID); err != nil {
+		t.Fatalf("PerformCommit failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(prompts) != 1 {
+		t.Fatalf("Expected 1 conflict prompt, got %d", len(prompts))
+	}
+	if !strings.Contains(prompts[0], "README.md") || !strings.Contains(prompts[0], "git pull -r origin "+newCommit) {
+		t.Errorf("Conflict prompt does not describe the conflict: %s", prompts[0])
+	}
+
+	updatedSession, err := env.store.GetSessionByID(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if updatedSession.CommitStatus != model.CommitStatusCompleted {
+		t.Errorf("Expected commit status %s, got %s (error: %v)", model.CommitStatusCompleted, updatedSession.CommitStatus, updatedSession.CommitError)
+	}
+	if updatedSession.ConflictRetries != 1 {
+		t.Errorf("Expected 1 conflict retry, got %d", updatedSession.ConflictRetries)
+	}
+	if updatedSession.CommitConflicts != nil {
+		t.Errorf("Expected conflicts to be cleared, got %s", *updatedSession.CommitConflicts)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workspace.Path, "README.md"))
+	if err != nil {
+		t.Fatalf("Failed to read README.md: %v", err)
+	}
+	if string(content) != "# Workspace\n# Agent\n" {
+		t.Errorf("Unexpected README.md content: %q", content)
+	}
+}
+
+// TestPerformCommit_ConflictRetriesExhausted tests that the commit fails with
+// the conflicts kept on the session when the agent keeps sending conflicting
+// patches.
+func TestPerformCommit_ConflictRetriesExhausted(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, initialCommit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, initialCommit)
+
+	conflicting := agentPatch(t, workspace.Path, "README.md", "# Agent\n")
+	newCommit := env.addCommitToWorkspace(t, workspace.Path, "README.md", "# Workspace\n")
+
+	var mu sync.Mutex
+	var commitsCount, chatCount int
+	handler := &trackingHandler{
+		onChat: func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			chatCount++
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		},
+		onCommits: func(w http.ResponseWriter, _ *http.Request) {
+			mu.Lock()
+			commitsCount++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sandboxapi.CommitsResponse{Patches: conflicting, CommitCount: 1})
+		},
+	}
+	sessionSvc := env.startSandbox(t, session.ID, handler)
+
+	if err := sessionSvc.PerformCommit(context.Background(), project.ID, session.ID); err != nil {
+		t.Fatalf("PerformCommit failed: %v", err)
+	}
+
+	mu.Lock()
+	if commitsCount != maxConflictRetries+1 || chatCount != maxConflictRetries {
+		t.Errorf("Expected %d commits requests and %d prompts, got %d and %d", maxConflictRetries+1, maxConflictRetries, commitsCount, chatCount)
+	}
+	mu.Unlock()
+
+	updatedSession, err := env.store.GetSessionByID(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if updatedSession.CommitStatus != model.CommitStatusFailed {
+		t.Errorf("Expected commit status %s, got %s", model.CommitStatusFailed, updatedSession.CommitStatus)
+	}
+	if updatedSession.ConflictRetries != maxConflictRetries {
+		t.Errorf("Expected %d conflict retries, got %d", maxConflictRetries, updatedSession.ConflictRetries)
+	}
+
+	conflicts := sessionSvc.mapSession(updatedSession).CommitConflicts
+	if conflicts == nil || len(conflicts.Conflicts) != 1 || conflicts.Conflicts[0].Path != "README.md" {
+		t.Fatalf("Expected README.md conflict on the session, got %+v", conflicts)
+	}
+	if len(conflicts.Conflicts[0].Hunks) != 1 || conflicts.Conflicts[0].Hunks[0].Theirs != "# Agent\n" {
+		t.Errorf("Unexpected conflict hunks: %+v", conflicts.Conflicts[0].Hunks)
+	}
+
+	if head := strings.TrimSpace(runGit(t, workspace.Path, "rev-parse", "HEAD")); head != newCommit {
+		t.Errorf("Expected workspace HEAD to stay at %s, got %s", newCommit, head)
+	}
+}
+
+// TestPerformCommit_TargetBranch tests that a session with a target branch
+// commits to that branch in a worktree, leaving the workspace checkout alone.
+func TestPerformCommit_TargetBranch(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, initialCommit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, initialCommit)
+	session.TargetBranch = ptrString("feature/agent")
+	if err := env.store.UpdateSession(context.Background(), session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	patch := agentPatch(t, workspace.Path, "README.md", "# Agent\n")
+	handler := &trackingHandler{
+		onCommits: func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sandboxapi.CommitsResponse{Patches: patch, CommitCount: 1})
+		},
+	}
+	sessionSvc := env.startSandbox(t, session.ID, handler)
+
+	if err := sessionSvc.PerformCommit(context.Background(), project.ID, session.ID); err != nil {
+		t.Fatalf("PerformCommit failed: %v", err)
+	}
+
+	updatedSession, err := env.store.GetSessionByID(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if updatedSession.CommitStatus != model.CommitStatusCompleted {
+		t.Fatalf("Expected commit status %s, got %s (error: %v)", model.CommitStatusCompleted, updatedSession.CommitStatus, updatedSession.CommitError)
+	}
+
+	branchCommit := strings.TrimSpace(runGit(t, workspace.Path, "rev-parse", "feature/agent"))
+	if updatedSession.AppliedCommit == nil || *updatedSession.AppliedCommit != branchCommit {
+		t.Errorf("Expected appliedCommit %s, got %v", branchCommit, updatedSession.AppliedCommit)
+	}
+	if head := strings.TrimSpace(runGit(t, workspace.Path, "rev-parse", "HEAD")); head != initialCommit {
+		t.Errorf("Expected workspace HEAD to stay at %s, got %s", initialCommit, head)
+	}
+	if content, _ := os.ReadFile(filepath.Join(workspace.Path, "README.md")); string(content) != "# Test\n" {
+		t.Errorf("Expected workspace checkout untouched, README.md is %q", content)
+	}
+
+	branches, err := env.gitService.Branches(context.Background(), workspace.ID)
+	if err != nil {
+		t.Fatalf("Branches failed: %v", err)
+	}
+	found := false
+	for _, b := range branches {
+		if b.Name == "feature/agent" {
+			found = true
+			if b.IsRemote || len(b.Sessions) != 1 || b.Sessions[0] != session.ID {
+				t.Errorf("Unexpected branch %+v", b)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected feature/agent in branches %+v", branches)
+	}
+}
+
+// startSandbox starts a mock sandbox serving handler and returns a session
+// service using it.
+func (e *testEnv) startSandbox(t *testing.T, sessionID string, handler http.Handler) *SessionService {
+	t.Helper()
+	e.mockSandbox.HTTPHandler = handler
+	if _, err := e.mockSandbox.Create(context.Background(), sessionID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := e.mockSandbox.Start(context.Background(), sessionID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	sandboxSvc := NewSandboxService(e.store, e.mockSandbox, &config.Config{}, nil, e.eventBroker, nil)
+	sandboxSvc.SetSessionInitializer(&testSessionInitializer{})
+	return NewSessionService(e.store, e.gitService, e.mockSandbox, sandboxSvc, e.eventBroker, nil)
+}
+
+// agentPatch commits content to filename on top of the workspace HEAD, as the
+// agent would, and returns the commit as an mbox patch. The workspace HEAD is
+// left unchanged.
+func agentPatch(t *testing.T, wsPath, filename, content string) string {
+	t.Helper()
+	runGit(t, wsPath, "checkout", "-q", "--detach")
+	if err := os.WriteFile(filepath.Join(wsPath, filename), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, wsPath, "commit", "-q", "-am", "Agent change")
+	patch := runGit(t, wsPath, "format-patch", "--stdout", "-1")
+	runGit(t, wsPath, "checkout", "-q", "-")
+	return patch
+}
+
 // trackingHandler is a custom handler that allows separate handling of chat and commits.
 type trackingHandler struct {
 	onChat    func(w http.ResponseWriter, r *http.Request)
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/forge"
+	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Pull request errors
+var (
+	ErrPullRequestUnsupported = errors.New("pull requests need a git URL workspace hosted on a configured forge")
+	ErrPullRequestNotReady    = errors.New("session has no commit to open a pull request from")
+)
+
+// pullRequestBranchPrefix namespaces the branches pushed for sessions.
+const pullRequestBranchPrefix = "discobot/"
+
+// maxPullRequestTitle caps the length of a pull request title in runes.
+const maxPullRequestTitle = 100
+
+// NewForgeRegistry creates the registry of forges configured with a token.
+func NewForgeRegistry(cfg *config.Config) *forge.Registry {
+	var forges []forge.Forge
+	if cfg.GitHubToken != "" {
+		forges = append(forges, forge.NewGitHub(cfg.GitHubAPIURL, cfg.GitHubToken))
+	}
+	if cfg.GiteaURL != "" && cfg.GiteaToken != "" {
+		forges = append(forges, forge.NewGitea(cfg.GiteaURL, cfg.GiteaToken))
+	}
+	return forge.NewRegistry(forges...)
+}
+
+// PullRequestService pushes committed sessions to a branch of the
+// workspace's remote and opens a pull request for them.
+type PullRequestService struct {
+	store       *store.Store
+	gitService  *GitService
+	forges      *forge.Registry
+	eventBroker *events.Broker
+}
+
+// NewPullRequestService creates a new pull request service
+func NewPullRequestService(s *store.Store, gitService *GitService, forges *forge.Registry, eventBroker *events.Broker) *PullRequestService {
+	return &PullRequestService{
+		store:       s,
+		gitService:  gitService,
+		forges:      forges,
+		eventBroker: eventBroker,
+	}
+}
+
+// ValidatePullRequest checks that the session's workspace is hosted on a
+// configured forge.
+func (p *PullRequestService) ValidatePullRequest(ctx context.Context, projectID, sessionID string) error {
+	_, err := p.projectSession(ctx, projectID, sessionID)
+	return err
+}
+
+// RequestPullRequest enqueues a job that opens a pull request for the
+// session. The job is keyed like the session's commit job, so when the commit
+// is still pending it runs after it.
+func (p *PullRequestService) RequestPullRequest(ctx context.Context, projectID, sessionID string, jobQueue JobEnqueuer) error {
+	sess, err := p.projectSession(ctx, projectID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	switch sess.CommitStatus {
+	case model.CommitStatusPending, model.CommitStatusCommitting, model.CommitStatusConflicted, model.CommitStatusCompleted:
+	default:
+		return ErrPullRequestNotReady
+	}
+
+	payload := jobs.SessionPullRequestPayload{
+		ProjectID:   projectID,
+		SessionID:   sessionID,
+		WorkspaceID: sess.WorkspaceID,
+		Branch:      commitJobBranch(ctx, p.gitService, sess),
+	}
+	if err := jobQueue.Enqueue(ctx, payload); err != nil {
+		return fmt.Errorf("failed to enqueue pull request job: %w", err)
+	}
+	return nil
+}
+
+// OpenPullRequest force-pushes the session's applied commit to the session's
+// branch and opens a pull request against the workspace's current branch. If
+// a pull request is already open for the branch, it is reused, so this can be
+// called again after a later commit of the same session.
+// This is called by the dispatcher when processing a session_pull_request job.
+func (p *PullRequestService) OpenPullRequest(ctx context.Context, projectID, sessionID string) (retErr error) {
+	sess, err := p.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("session not found: %w", err)
+	}
+
+	// Record the failure on the session. Push and forge errors are returned
+	// for the job queue to retry; retrying cannot fix the session itself.
+	defer func() {
+		if retErr != nil {
+			msg := retErr.Error()
+			if err := p.store.UpdateSessionPullRequest(ctx, sess.ID, "", nil, "", &msg); err != nil {
+				log.Printf("Failed to record pull request error for session %s: %v", sess.ID, err)
+			}
+			if errors.Is(retErr, ErrPullRequestNotReady) || errors.Is(retErr, ErrPullRequestUnsupported) {
+				retErr = nil
+			}
+		}
+	}()
+
+	if sess.CommitStatus != model.CommitStatusCompleted || sess.AppliedCommit == nil || *sess.AppliedCommit == "" {
+		return ErrPullRequestNotReady
+	}
+
+	workspace, fg, repo, err := p.forgeFor(ctx, sess)
+	if err != nil {
+		return err
+	}
+
+	status, err := p.gitService.Status(ctx, sess.WorkspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get workspace status: %w", err)
+	}
+	if status.Branch == "" || status.Branch == "HEAD" {
+		return fmt.Errorf("workspace is not on a branch to open a pull request against")
+	}
+
+	ref := *sess.AppliedCommit
+	if sess.BaseCommit != nil && *sess.BaseCommit != "" {
+		ref = *sess.BaseCommit + ".." + ref
+	}
+	commits, err := p.gitService.Log(ctx, sess.WorkspaceID, git.LogOptions{Ref: ref, Limit: 100})
+	if err != nil {
+		return fmt.Errorf("failed to list session commits: %w", err)
+	}
+	slices.Reverse(commits)
+
+	branch := pullRequestBranch(sess, status.Branch)
+	pushOpts := git.PushOptions{Ref: *sess.AppliedCommit, Branch: branch, Force: true}
+	if forge.IsHTTPRemote(workspace.Path) {
+		pushOpts.Username, pushOpts.Password = fg.PushCredentials()
+	}
+	if err := p.gitService.Push(ctx, sess.WorkspaceID, pushOpts); err != nil {
+		return err
+	}
+
+	title, body := pullRequestText(sess, commits)
+	pr, err := fg.CreatePullRequest(ctx, repo, forge.PullRequestRequest{
+		Title: title,
+		Body:  body,
+		Head:  branch,
+		Base:  status.Branch,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s pull request: %w", fg.Name(), err)
+	}
+
+	if err := p.store.UpdateSessionPullRequest(ctx, sess.ID, branch, &pr.Number, pr.URL, nil); err != nil {
+		return fmt.Errorf("failed to store pull request: %w", err)
+	}
+	log.Printf("Session %s: pull request #%d opened at %s", sess.ID, pr.Number, pr.URL)
+
+	if p.eventBroker != nil {
+		data := events.SessionPullRequestData{SessionID: sess.ID, Branch: branch, Number: pr.Number, URL: pr.URL}
+		if err := p.eventBroker.PublishSessionPullRequest(ctx, projectID, data); err != nil {
+			log.Printf("Failed to publish pull request event: %v", err)
+		}
+	}
+	return nil
+}
+
+// projectSession returns a session of the project whose workspace supports
+// pull requests.
+func (p *PullRequestService) projectSession(ctx context.Context, projectID, sessionID string) (*model.Session, error) {
+	sess, err := p.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session not found: %w", err)
+	}
+	if sess.ProjectID != projectID {
+		return nil, fmt.Errorf("session not found: %w", store.ErrNotFound)
+	}
+	if _, _, _, err := p.forgeFor(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// forgeFor returns the session's workspace and the forge hosting its remote.
+func (p *PullRequestService) forgeFor(ctx context.Context, sess *model.Session) (*model.Workspace, forge.Forge, forge.Repo, error) {
+	workspace, err := p.store.GetWorkspaceByID(ctx, sess.WorkspaceID)
+	if err != nil {
+		return nil, nil, forge.Repo{}, fmt.Errorf("workspace not found: %w", err)
+	}
+	if !git.IsGitURL(workspace.Path) {
+		return nil, nil, forge.Repo{}, ErrPullRequestUnsupported
+	}
+	fg, repo, err := p.forges.ForRemote(workspace.Path)
+	if err != nil {
+		return nil, nil, forge.Repo{}, fmt.Errorf("%w: %v", ErrPullRequestUnsupported, err)
+	}
+	return workspace, fg, repo, nil
+}
+
+// pullRequestBranch returns the branch pushed for a session: its target
+// branch, unless that is the base branch. Otherwise, once a pull request was
+// opened the branch is kept, even if the session was renamed.
+func pullRequestBranch(sess *model.Session, baseBranch string) string {
+	if branch := worktreeBranch(sess, baseBranch); branch != "" {
+		return branch
+	}
+	if sess.PullRequestBranch != nil && *sess.PullRequestBranch != "" {
+		return *sess.PullRequestBranch
+	}
+
+	id := sess.ID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	slug := branchSlug(sessionTitle(sess))
+	if slug == "" {
+		return pullRequestBranchPrefix + "session-" + id
+	}
+	return pullRequestBranchPrefix + slug + "-" + id
+}
+
+// branchSlug lowercases s and joins its alphanumeric words with hyphens,
+// keeping at most 40 characters.
+func branchSlug(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+		if b.Len() >= 40 {
+			break
+		}
+	}
+	slug := b.String()
+	if len(slug) > 40 {
+		slug = slug[:40]
+	}
+	return strings.TrimSuffix(slug, "-")
+}
+
+// sessionTitle returns the name shown for a session.
+func sessionTitle(sess *model.Session) string {
+	if sess.DisplayName != nil && strings.TrimSpace(*sess.DisplayName) != "" {
+		return strings.TrimSpace(*sess.DisplayName)
+	}
+	return strings.TrimSpace(sess.Name)
+}
+
+// pullRequestText derives the pull request title and body from the session
+// name and the subjects of its commits, oldest first.
+func pullRequestText(sess *model.Session, commits []git.Commit) (title, body string) {
+	title = sessionTitle(sess)
+	if title == "" && len(commits) > 0 {
+		title = commits[0].Message
+	}
+	if title == "" {
+		title = "Changes from session " + sess.ID
+	}
+	if runes := []rune(strings.Join(strings.Fields(title), " ")); len(runes) > maxPullRequestTitle {
+		title = string(runes[:maxPullRequestTitle-1]) + "…"
+	} else {
+		title = string(runes)
+	}
+
+	var b strings.Builder
+	for _, c := range commits {
+		fmt.Fprintf(&b, "- %s\n", c.Message)
+	}
+	if len(commits) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Opened from discobot session `%s`.\n", sess.ID)
+	return title, b.String()
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/forge"
+	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestPullRequestBranch(t *testing.T) {
+	tests := []struct {
+		sess *model.Session
+		want string
+	}{
+		{&model.Session{ID: "abcdef1234", Name: "Fix the login bug!"}, "discobot/fix-the-login-bug-abcdef12"},
+		{&model.Session{ID: "abc", Name: "  ", DisplayName: ptrString("Añadir README")}, "discobot/a-adir-readme-abc"},
+		{&model.Session{ID: "abcdef1234", Name: "???"}, "discobot/session-abcdef12"},
+		{&model.Session{ID: "abcdef1234", Name: "Renamed", PullRequestBranch: ptrString("discobot/original-abcdef12")}, "discobot/original-abcdef12"},
+		{&model.Session{ID: "abcdef1234", Name: "Targeted", TargetBranch: ptrString("feature/login")}, "feature/login"},
+		{&model.Session{ID: "abcdef1234", Name: "Targets base", TargetBranch: ptrString("main")}, "discobot/targets-base-abcdef12"},
+	}
+	for _, tt := range tests {
+		if got := pullRequestBranch(tt.sess, "main"); got != tt.want {
+			t.Errorf("pullRequestBranch(%q) = %q, want %q", tt.sess.Name, got, tt.want)
+		}
+	}
+
+	long := pullRequestBranch(&model.Session{ID: "abcdef1234", Name: strings.Repeat("word ", 30)}, "main")
+	if len(long) > len("discobot/")+40+len("-abcdef12") {
+		t.Errorf("Expected slug to be capped, got %q", long)
+	}
+}
+
+func TestPullRequestText(t *testing.T) {
+	commits := []git.Commit{{Message: "Add parser"}, {Message: "Add tests"}}
+
+	title, body := pullRequestText(&model.Session{ID: "s1", Name: "Build the parser"}, commits)
+	if title != "Build the parser" {
+		t.Errorf("title = %q", title)
+	}
+	if !strings.HasPrefix(body, "- Add parser\n- Add tests\n\n") || !strings.Contains(body, "`s1`") {
+		t.Errorf("Unexpected body %q", body)
+	}
+
+	title, _ = pullRequestText(&model.Session{ID: "s1"}, commits)
+	if title != "Add parser" {
+		t.Errorf("Expected first commit subject as fallback title, got %q", title)
+	}
+
+	title, _ = pullRequestText(&model.Session{ID: "s1", Name: strings.Repeat("x", 200)}, nil)
+	if n := len([]rune(title)); n != maxPullRequestTitle {
+		t.Errorf("Expected title truncated to %d runes, got %d", maxPullRequestTitle, n)
+	}
+}
+
+func TestPullRequestService_Request(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	defer env.cleanup()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+	sess := env.createTestSession(t, project.ID, workspace.ID, agent.ID, commit)
+
+	svc := NewPullRequestService(env.store, env.gitService, forge.NewRegistry(forge.NewGitHub("", "tok")), env.eventBroker)
+	enqueuer := &recordingEnqueuer{}
+
+	// Local workspaces have no forge
+	if err := svc.RequestPullRequest(ctx, project.ID, sess.ID, enqueuer); !errors.Is(err, ErrPullRequestUnsupported) {
+		t.Fatalf("Expected ErrPullRequestUnsupported for local workspace, got %v", err)
+	}
+
+	workspace.Path = "git@github.com:o/n.git"
+	if err := env.store.UpdateWorkspace(ctx, workspace); err != nil {
+		t.Fatalf("Failed to update workspace: %v", err)
+	}
+
+	if err := svc.RequestPullRequest(ctx, "other-project", sess.ID, enqueuer); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found for another project, got %v", err)
+	}
+
+	if err := svc.RequestPullRequest(ctx, project.ID, sess.ID, enqueuer); err != nil {
+		t.Fatalf("RequestPullRequest failed: %v", err)
+	}
+	if len(enqueuer.payloads) != 1 {
+		t.Fatalf("Expected 1 job, got %d", len(enqueuer.payloads))
+	}
+	if p, ok := enqueuer.payloads[0].(jobs.SessionPullRequestPayload); !ok || p.WorkspaceID != workspace.ID {
+		t.Errorf("Unexpected payload %+v", enqueuer.payloads[0])
+	}
+
+	// A failed commit cannot be turned into a pull request
+	sess.CommitStatus = model.CommitStatusFailed
+	if err := env.store.UpdateSession(ctx, sess); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if err := svc.RequestPullRequest(ctx, project.ID, sess.ID, enqueuer); !errors.Is(err, ErrPullRequestNotReady) {
+		t.Errorf("Expected ErrPullRequestNotReady, got %v", err)
+	}
+
+	// The job records why it could not open the pull request without retrying
+	if err := svc.OpenPullRequest(ctx, project.ID, sess.ID); err != nil {
+		t.Errorf("Expected no retry for a failed commit, got %v", err)
+	}
+	updated, _ := env.store.GetSessionByID(ctx, sess.ID)
+	if updated.PullRequestError == nil || *updated.PullRequestError != ErrPullRequestNotReady.Error() {
+		t.Errorf("Expected pull request error to be recorded, got %v", updated.PullRequestError)
+	}
+}
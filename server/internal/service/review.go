@@ -0,0 +1,634 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Review errors
+var (
+	ErrReviewThreadNotFound  = errors.New("review thread not found")
+	ErrReviewCommentNotFound = errors.New("review comment not found")
+	ErrInvalidReview         = errors.New("invalid review comment")
+	ErrReviewCommentSent     = errors.New("review comment was already sent to the agent")
+	ErrNoPendingReview       = errors.New("no pending review comments to send")
+	ErrReviewSessionBusy     = errors.New("cannot send a review while the session is running or committing")
+)
+
+// ReviewThread represents a review thread and its comments (for API responses)
+type ReviewThread struct {
+	ID        string          `json:"id"`
+	Path      string          `json:"path"`
+	Side      string          `json:"side"`
+	StartLine int             `json:"startLine"`
+	EndLine   int             `json:"endLine"`
+	Revision  string          `json:"revision"`
+	Outdated  bool            `json:"outdated"`
+	Resolved  bool            `json:"resolved"`
+	CreatedBy string          `json:"createdBy,omitempty"`
+	Comments  []ReviewComment `json:"comments"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// ReviewComment represents a comment of a review thread (for API responses).
+// Pending comments have not been sent to the agent yet.
+type ReviewComment struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId,omitempty"`
+	Body      string     `json:"body"`
+	Pending   bool       `json:"pending"`
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// CreateReviewThreadRequest anchors a new thread to a line range of a file
+// in the session's diff. Lines are 1-based and inclusive.
+type CreateReviewThreadRequest struct {
+	Path      string `json:"path"`
+	Side      string `json:"side,omitempty"` // "new" (default) or "old"
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine,omitempty"` // defaults to startLine
+	Body      string `json:"body"`
+}
+
+// SendReviewRequest sends the pending review comments to the agent.
+type SendReviewRequest struct {
+	// Body is an optional overall comment placed before the line comments
+	Body  string `json:"body,omitempty"`
+	Model string `json:"model,omitempty"`
+	Mode  string `json:"mode,omitempty"`
+}
+
+// SendReviewResponse is returned once the agent started working on a review.
+type SendReviewResponse struct {
+	MessageID string `json:"messageId"`
+	Comments  int    `json:"comments"`
+}
+
+// ReviewService manages review threads on session diffs and sends them to
+// the session's agent.
+type ReviewService struct {
+	store       *store.Store
+	chatService *ChatService
+}
+
+// NewReviewService creates a new review service
+func NewReviewService(s *store.Store, chatService *ChatService) *ReviewService {
+	return &ReviewService{
+		store:       s,
+		chatService: chatService,
+	}
+}
+
+// ListReviewThreads returns the review threads of a session with their
+// comments, ordered by file and line.
+func (r *ReviewService) ListReviewThreads(ctx context.Context, projectID, sessionID string) ([]ReviewThread, error) {
+	if _, err := r.chatService.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+
+	threads, comments, err := r.loadThreads(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ReviewThread, len(threads))
+	for i, t := range threads {
+		result[i] = mapReviewThread(t, comments[t.ID])
+	}
+	return result, nil
+}
+
+// CreateReviewThread starts a thread on lines of the session's current diff.
+// The lines must be shown in the file's diff; their content is kept to
+// detect when a later diff changes them.
+func (r *ReviewService) CreateReviewThread(ctx context.Context, projectID, sessionID, userID string, req CreateReviewThreadRequest) (*ReviewThread, error) {
+	if req.Side == "" {
+		req.Side = model.ReviewSideNew
+	}
+	if req.EndLine == 0 {
+		req.EndLine = req.StartLine
+	}
+	switch {
+	case strings.TrimSpace(req.Path) == "":
+		return nil, fmt.Errorf("%w: path is required", ErrInvalidReview)
+	case req.Side != model.ReviewSideNew && req.Side != model.ReviewSideOld:
+		return nil, fmt.Errorf("%w: side must be %q or %q", ErrInvalidReview, model.ReviewSideNew, model.ReviewSideOld)
+	case req.StartLine < 1 || req.EndLine < req.StartLine:
+		return nil, fmt.Errorf("%w: invalid line range %d-%d", ErrInvalidReview, req.StartLine, req.EndLine)
+	case strings.TrimSpace(req.Body) == "":
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidReview)
+	}
+
+	result, err := r.chatService.GetDiff(ctx, projectID, sessionID, req.Path, "")
+	if err != nil {
+		return nil, err
+	}
+	diff, ok := result.(*sandboxapi.SingleFileDiffResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected diff response %T", result)
+	}
+	anchor, ok := anchoredLines(diff.Patch, req.Side, req.StartLine, req.EndLine)
+	if !ok {
+		return nil, fmt.Errorf("%w: lines %d-%d of %s are not part of the diff", ErrInvalidReview, req.StartLine, req.EndLine, req.Path)
+	}
+
+	thread := &model.ReviewThread{
+		ProjectID:  projectID,
+		SessionID:  sessionID,
+		Path:       req.Path,
+		Side:       req.Side,
+		StartLine:  req.StartLine,
+		EndLine:    req.EndLine,
+		Revision:   patchRevision(diff.Patch),
+		AnchorText: anchor,
+		CreatedBy:  userID,
+	}
+	comment := &model.ReviewComment{
+		SessionID: sessionID,
+		ProjectID: projectID,
+		UserID:    userID,
+		Body:      req.Body,
+	}
+	if err := r.store.CreateReviewThread(ctx, thread, comment); err != nil {
+		return nil, fmt.Errorf("failed to create review thread: %w", err)
+	}
+
+	mapped := mapReviewThread(thread, []*model.ReviewComment{comment})
+	return &mapped, nil
+}
+
+// AddReviewComment adds a pending reply to a thread.
+func (r *ReviewService) AddReviewComment(ctx context.Context, projectID, sessionID, threadID, userID, body string) (*ReviewComment, error) {
+	if _, err := r.getThread(ctx, projectID, sessionID, threadID); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidReview)
+	}
+
+	comment := &model.ReviewComment{
+		ThreadID:  threadID,
+		SessionID: sessionID,
+		ProjectID: projectID,
+		UserID:    userID,
+		Body:      body,
+	}
+	if err := r.store.CreateReviewComment(ctx, comment); err != nil {
+		return nil, fmt.Errorf("failed to create review comment: %w", err)
+	}
+	mapped := mapReviewComment(comment)
+	return &mapped, nil
+}
+
+// SetReviewThreadResolved resolves or reopens a thread. Pending comments of
+// resolved threads are not sent to the agent.
+func (r *ReviewService) SetReviewThreadResolved(ctx context.Context, projectID, sessionID, threadID string, resolved bool) (*ReviewThread, error) {
+	thread, err := r.getThread(ctx, projectID, sessionID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	thread.Resolved = resolved
+	if err := r.store.UpdateReviewThread(ctx, thread); err != nil {
+		return nil, fmt.Errorf("failed to update review thread: %w", err)
+	}
+
+	_, comments, err := r.loadThreads(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	mapped := mapReviewThread(thread, comments[thread.ID])
+	return &mapped, nil
+}
+
+// DeleteReviewThread deletes a thread and its comments.
+func (r *ReviewService) DeleteReviewThread(ctx context.Context, projectID, sessionID, threadID string) error {
+	if _, err := r.getThread(ctx, projectID, sessionID, threadID); err != nil {
+		return err
+	}
+	if err := r.store.DeleteReviewThread(ctx, sessionID, threadID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrReviewThreadNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateReviewComment edits a pending comment. Comments already sent to the
+// agent cannot be changed.
+func (r *ReviewService) UpdateReviewComment(ctx context.Context, projectID, sessionID, threadID, commentID, body string) (*ReviewComment, error) {
+	comment, err := r.getComment(ctx, projectID, sessionID, threadID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.SentAt != nil {
+		return nil, ErrReviewCommentSent
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidReview)
+	}
+
+	comment.Body = body
+	if err := r.store.UpdateReviewComment(ctx, comment); err != nil {
+		return nil, fmt.Errorf("failed to update review comment: %w", err)
+	}
+	mapped := mapReviewComment(comment)
+	return &mapped, nil
+}
+
+// DeleteReviewComment deletes a comment. Deleting the last comment of a
+// thread deletes the thread.
+func (r *ReviewService) DeleteReviewComment(ctx context.Context, projectID, sessionID, threadID, commentID string) error {
+	if _, err := r.getComment(ctx, projectID, sessionID, threadID, commentID); err != nil {
+		return err
+	}
+	if err := r.store.DeleteReviewComment(ctx, threadID, commentID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrReviewCommentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateOutdated marks the session's threads whose anchored lines changed in
+// diff, a response of ChatService.GetDiff. Threads whose lines only moved,
+// because lines were added or removed above them, follow them instead. A
+// full diff also outdates threads on files that are no longer changed; a
+// files-only diff has no lines and is ignored.
+func (r *ReviewService) UpdateOutdated(ctx context.Context, sessionID string, diff any) error {
+	patches := make(map[string]string)
+	complete := false
+	switch d := diff.(type) {
+	case *sandboxapi.DiffResponse:
+		complete = true
+		for _, f := range d.Files {
+			patches[f.Path] = f.Patch
+		}
+	case *sandboxapi.SingleFileDiffResponse:
+		patches[d.Path] = d.Patch
+	default:
+		return nil
+	}
+
+	threads, err := r.store.ListReviewThreadsBySession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	var outdated []string
+	for _, t := range threads {
+		if t.Outdated {
+			continue
+		}
+		patch, ok := patches[t.Path]
+		if !ok {
+			if complete {
+				outdated = append(outdated, t.ID)
+			}
+			continue
+		}
+		if patchRevision(patch) == t.Revision {
+			continue
+		}
+		start, ok := reanchor(patch, t)
+		if !ok {
+			outdated = append(outdated, t.ID)
+			continue
+		}
+		if start != t.StartLine {
+			t.EndLine += start - t.StartLine
+			t.StartLine = start
+			t.Revision = patchRevision(patch)
+			if err := r.store.UpdateReviewThread(ctx, t); err != nil {
+				return fmt.Errorf("failed to move review thread: %w", err)
+			}
+		}
+	}
+	return r.store.MarkReviewThreadsOutdated(ctx, outdated)
+}
+
+// SendReview composes the pending comments of unresolved threads into one
+// prompt and sends it to the session's agent. It returns once the agent
+// started working on it; the comments are then no longer pending.
+func (r *ReviewService) SendReview(ctx context.Context, projectID, sessionID string, req SendReviewRequest) (*SendReviewResponse, error) {
+	sess, err := r.chatService.GetSession(ctx, projectID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case sess.Status == model.SessionStatusRunning:
+		return nil, ErrReviewSessionBusy
+	case sess.CommitStatus == model.CommitStatusPending, sess.CommitStatus == model.CommitStatusCommitting, sess.CommitStatus == model.CommitStatusConflicted:
+		return nil, ErrReviewSessionBusy
+	}
+
+	// Flag threads whose lines changed since they were written, so the agent
+	// knows not to look for the quoted code as is
+	if diff, err := r.chatService.GetDiff(ctx, projectID, sessionID, "", ""); err != nil {
+		log.Printf("Review: failed to get diff of session %s: %v", sessionID, err)
+	} else if err := r.UpdateOutdated(ctx, sessionID, diff); err != nil {
+		log.Printf("Review: failed to update outdated threads of session %s: %v", sessionID, err)
+	}
+
+	threads, comments, err := r.loadThreads(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	prompt, commentIDs := reviewPrompt(threads, comments, req.Body)
+	if len(commentIDs) == 0 {
+		return nil, ErrNoPendingReview
+	}
+
+	// The review is sent even if the client goes away while the session starts
+	messageID, err := r.chatService.StartPrompt(context.WithoutCancel(ctx), projectID, sessionID, prompt, req.Model, req.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send review: %w", err)
+	}
+
+	if err := r.store.MarkReviewCommentsSent(ctx, commentIDs, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to mark review comments sent: %w", err)
+	}
+	log.Printf("Session %s: sent review with %d comments", sessionID, len(commentIDs))
+
+	return &SendReviewResponse{MessageID: messageID, Comments: len(commentIDs)}, nil
+}
+
+// loadThreads returns the threads of a session and their comments by
+// thread ID.
+func (r *ReviewService) loadThreads(ctx context.Context, sessionID string) ([]*model.ReviewThread, map[string][]*model.ReviewComment, error) {
+	threads, err := r.store.ListReviewThreadsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	comments, err := r.store.ListReviewCommentsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byThread := make(map[string][]*model.ReviewComment)
+	for _, c := range comments {
+		byThread[c.ThreadID] = append(byThread[c.ThreadID], c)
+	}
+	return threads, byThread, nil
+}
+
+// getThread returns a thread of a session of the project.
+func (r *ReviewService) getThread(ctx context.Context, projectID, sessionID, threadID string) (*model.ReviewThread, error) {
+	if _, err := r.chatService.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	thread, err := r.store.GetReviewThread(ctx, sessionID, threadID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrReviewThreadNotFound
+		}
+		return nil, err
+	}
+	return thread, nil
+}
+
+// getComment returns a comment of a thread of a session of the project.
+func (r *ReviewService) getComment(ctx context.Context, projectID, sessionID, threadID, commentID string) (*model.ReviewComment, error) {
+	if _, err := r.getThread(ctx, projectID, sessionID, threadID); err != nil {
+		return nil, err
+	}
+	comment, err := r.store.GetReviewComment(ctx, threadID, commentID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrReviewCommentNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
+// reviewPrompt builds the prompt of a review from the pending comments of
+// unresolved threads, and returns the IDs of the comments it includes.
+func reviewPrompt(threads []*model.ReviewThread, comments map[string][]*model.ReviewComment, summary string) (string, []string) {
+	var b strings.Builder
+	var commentIDs []string
+	for _, t := range threads {
+		if t.Resolved {
+			continue
+		}
+		var pending []*model.ReviewComment
+		for _, c := range comments[t.ID] {
+			if c.SentAt == nil {
+				pending = append(pending, c)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		lines := "line " + strconv.Itoa(t.StartLine)
+		if t.EndLine != t.StartLine {
+			lines = fmt.Sprintf("lines %d-%d", t.StartLine, t.EndLine)
+		}
+		if t.Side == model.ReviewSideOld {
+			lines += " of the original file"
+		}
+		fmt.Fprintf(&b, "### `%s` (%s)\n\n", t.Path, lines)
+		if t.Outdated {
+			b.WriteString("These lines have changed since this comment was written.\n\n")
+		}
+		if t.AnchorText != "" {
+			fence := codeFence(t.AnchorText)
+			fmt.Fprintf(&b, "%s\n%s\n%s\n\n", fence, t.AnchorText, fence)
+		}
+		for _, c := range pending {
+			fmt.Fprintf(&b, "- %s\n", strings.ReplaceAll(strings.TrimSpace(c.Body), "\n", "\n  "))
+			commentIDs = append(commentIDs, c.ID)
+		}
+		b.WriteString("\n")
+	}
+	if len(commentIDs) == 0 {
+		return "", nil
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("I reviewed your changes. Please address the following review comments.\n\n")
+	if summary = strings.TrimSpace(summary); summary != "" {
+		prompt.WriteString(summary + "\n\n")
+	}
+	prompt.WriteString(strings.TrimRight(b.String(), "\n") + "\n")
+	return prompt.String(), commentIDs
+}
+
+// codeFence returns a backtick fence longer than any backtick run in s.
+func codeFence(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// patchRevision identifies a version of a file's diff.
+func patchRevision(patch string) string {
+	sum := sha256.Sum256([]byte(patch))
+	return hex.EncodeToString(sum[:6])
+}
+
+// reanchorDistance is how many lines away from its old position a thread's
+// anchor text is looked for.
+const reanchorDistance = 100
+
+// reanchor returns the start line of a thread's anchor text in a file's
+// unified diff: the thread's own if the text is still there, otherwise the
+// nearest one within reanchorDistance lines. It returns false if the text
+// is not found.
+func reanchor(patch string, t *model.ReviewThread) (int, bool) {
+	lines := sideLines(patch, t.Side)
+	length := t.EndLine - t.StartLine
+	for d := 0; d <= reanchorDistance; d++ {
+		for _, start := range []int{t.StartLine - d, t.StartLine + d} {
+			if anchor, ok := joinLines(lines, start, start+length); ok && anchor == t.AnchorText {
+				return start, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// anchoredLines returns the lines start to end of one side of a file's
+// unified diff, joined by newlines. It returns false unless all of them are
+// shown in the diff.
+func anchoredLines(patch, side string, start, end int) (string, bool) {
+	return joinLines(sideLines(patch, side), start, end)
+}
+
+// sideLines maps the line numbers of one side of a unified diff to the
+// lines shown.
+func sideLines(patch, side string) map[int]string {
+	oldLines, newLines := parsePatchLines(patch)
+	if side == model.ReviewSideOld {
+		return oldLines
+	}
+	return newLines
+}
+
+// joinLines joins lines start to end, returning false unless all of them
+// are present.
+func joinLines(lines map[int]string, start, end int) (string, bool) {
+	result := make([]string, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		line, ok := lines[n]
+		if !ok {
+			return "", false
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n"), true
+}
+
+// parsePatchLines maps the line numbers of the old and new file to the lines
+// shown in a unified diff.
+func parsePatchLines(patch string) (oldLines, newLines map[int]string) {
+	oldLines = make(map[int]string)
+	newLines = make(map[int]string)
+
+	var oldNum, newNum, oldLeft, newLeft int
+	for _, line := range strings.Split(patch, "\n") {
+		if oldLeft <= 0 && newLeft <= 0 {
+			// Between hunks: only a hunk header matters
+			if strings.HasPrefix(line, "@@ ") {
+				oldNum, oldLeft, newNum, newLeft = parseHunkHeader(line)
+			}
+			continue
+		}
+		if line == "" {
+			// A blank context line whose leading space was stripped
+			line = " "
+		}
+		switch line[0] {
+		case ' ':
+			oldLines[oldNum] = line[1:]
+			newLines[newNum] = line[1:]
+			oldNum++
+			newNum++
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLines[oldNum] = line[1:]
+			oldNum++
+			oldLeft--
+		case '+':
+			newLines[newNum] = line[1:]
+			newNum++
+			newLeft--
+		}
+	}
+	return oldLines, newLines
+}
+
+// parseHunkHeader parses "@@ -oldStart,oldCount +newStart,newCount @@".
+// Omitted counts default to 1.
+func parseHunkHeader(header string) (oldStart, oldCount, newStart, newCount int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0, 0, 0, 0
+	}
+	parse := func(r string) (int, int) {
+		startStr, countStr, found := strings.Cut(r[1:], ",")
+		start, _ := strconv.Atoi(startStr)
+		count := 1
+		if found {
+			count, _ = strconv.Atoi(countStr)
+		}
+		return start, count
+	}
+	oldStart, oldCount = parse(fields[1])
+	newStart, newCount = parse(fields[2])
+	return oldStart, oldCount, newStart, newCount
+}
+
+func mapReviewThread(t *model.ReviewThread, comments []*model.ReviewComment) ReviewThread {
+	result := ReviewThread{
+		ID:        t.ID,
+		Path:      t.Path,
+		Side:      t.Side,
+		StartLine: t.StartLine,
+		EndLine:   t.EndLine,
+		Revision:  t.Revision,
+		Outdated:  t.Outdated,
+		Resolved:  t.Resolved,
+		CreatedBy: t.CreatedBy,
+		Comments:  make([]ReviewComment, len(comments)),
+		CreatedAt: t.CreatedAt,
+	}
+	for i, c := range comments {
+		result.Comments[i] = mapReviewComment(c)
+	}
+	return result
+}
+
+func mapReviewComment(c *model.ReviewComment) ReviewComment {
+	return ReviewComment{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		Body:      c.Body,
+		Pending:   c.SentAt == nil,
+		SentAt:    c.SentAt,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+const reviewTestPatch = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,5 @@
+ package main
+
+-func main() {}
++func main() {
++	run()
++}
+`
+
+// reviewSandbox serves the session diff and records chat prompts.
+type reviewSandbox struct {
+	mu      sync.Mutex
+	patch   string
+	prompts []string
+}
+
+func (s *reviewSandbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/diff" && r.URL.Query().Get("path") != "":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(sandboxapi.SingleFileDiffResponse{Path: r.URL.Query().Get("path"), Status: "modified", Patch: s.patch})
+	case r.URL.Path == "/diff":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(sandboxapi.DiffResponse{Files: []sandboxapi.FileDiffEntry{{Path: "main.go", Status: "modified", Patch: s.patch}}})
+	case strings.HasSuffix(r.URL.Path, "/chat") && r.Method == "POST":
+		body, _ := io.ReadAll(r.Body)
+		s.prompts = append(s.prompts, string(body))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasSuffix(r.URL.Path, "/chat") && r.Method == "GET":
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, "data: {\"type\":\"start\"}\n\ndata: [DONE]\n\n")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newReviewTestEnv(t *testing.T) (*ReviewService, *reviewSandbox, *model.Session) {
+	t.Helper()
+
+	env := newTestEnv(t)
+	t.Cleanup(env.cleanup)
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, commit)
+	session.CommitStatus = model.CommitStatusNone
+	if err := env.store.UpdateSession(context.Background(), session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	sb := &reviewSandbox{patch: reviewTestPatch}
+	env.mockSandbox.HTTPHandler = sb
+	if _, err := env.mockSandbox.Create(context.Background(), session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(context.Background(), session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	enqueuer := &recordingEnqueuer{}
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sandboxSvc.SetSessionInitializer(&testSessionInitializer{})
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, enqueuer)
+	chatSvc := NewChatService(env.store, sessionSvc, enqueuer, env.eventBroker, sandboxSvc, env.gitService)
+
+	return NewReviewService(env.store, chatSvc), sb, session
+}
+
+func TestAnchoredLines(t *testing.T) {
+	tests := []struct {
+		side       string
+		start, end int
+		want       string
+		ok         bool
+	}{
+		{model.ReviewSideNew, 3, 5, "func main() {\n\trun()\n}", true},
+		{model.ReviewSideNew, 2, 2, "", true},
+		{model.ReviewSideOld, 3, 3, "func main() {}", true},
+		{model.ReviewSideOld, 4, 4, "", false},
+		{model.ReviewSideNew, 5, 6, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := anchoredLines(reviewTestPatch, tt.side, tt.start, tt.end)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("anchoredLines(%s, %d-%d) = %q, %v, want %q, %v", tt.side, tt.start, tt.end, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReviewService_SendReview(t *testing.T) {
+	svc, sb, session := newReviewTestEnv(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateReviewThread(ctx, session.ProjectID, session.ID, "user-1", CreateReviewThreadRequest{
+		Path: "main.go", StartLine: 10, Body: "Outside the diff",
+	}); !errors.Is(err, ErrInvalidReview) {
+		t.Fatalf("Expected ErrInvalidReview for lines outside the diff, got %v", err)
+	}
+
+	thread, err := svc.CreateReviewThread(ctx, session.ProjectID, session.ID, "user-1", CreateReviewThreadRequest{
+		Path: "main.go", StartLine: 3, EndLine: 5, Body: "Check the error returned by run",
+	})
+	if err != nil {
+		t.Fatalf("CreateReviewThread failed: %v", err)
+	}
+	if _, err := svc.AddReviewComment(ctx, session.ProjectID, session.ID, thread.ID, "user-1", "And log it"); err != nil {
+		t.Fatalf("AddReviewComment failed: %v", err)
+	}
+	resolved, err := svc.CreateReviewThread(ctx, session.ProjectID, session.ID, "user-1", CreateReviewThreadRequest{
+		Path: "main.go", Side: model.ReviewSideOld, StartLine: 1, Body: "Never mind",
+	})
+	if err != nil {
+		t.Fatalf("CreateReviewThread failed: %v", err)
+	}
+	if _, err := svc.SetReviewThreadResolved(ctx, session.ProjectID, session.ID, resolved.ID, true); err != nil {
+		t.Fatalf("SetReviewThreadResolved failed: %v", err)
+	}
+
+	result, err := svc.SendReview(ctx, session.ProjectID, session.ID, SendReviewRequest{Body: "Nearly there"})
+	if err != nil {
+		t.Fatalf("SendReview failed: %v", err)
+	}
+	if result.Comments != 2 || result.MessageID == "" {
+		t.Errorf("Unexpected send result %+v", result)
+	}
+
+	if len(sb.prompts) != 1 {
+		t.Fatalf("Expected 1 prompt sent, got %d", len(sb.prompts))
+	}
+	var body struct {
+		Messages []struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(sb.prompts[0]), &body); err != nil || len(body.Messages) != 1 || len(body.Messages[0].Parts) != 1 {
+		t.Fatalf("Unexpected chat request %s: %v", sb.prompts[0], err)
+	}
+	prompt := body.Messages[0].Parts[0].Text
+	for _, want := range []string{"Nearly there", "`main.go` (lines 3-5)", "\trun()", "- Check the error returned by run", "- And log it"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "Never mind") {
+		t.Errorf("Resolved thread was sent:\n%s", prompt)
+	}
+
+	threads, err := svc.ListReviewThreads(ctx, session.ProjectID, session.ID)
+	if err != nil {
+		t.Fatalf("ListReviewThreads failed: %v", err)
+	}
+	for _, th := range threads {
+		if th.ID != thread.ID {
+			continue
+		}
+		for _, c := range th.Comments {
+			if c.Pending || c.SentAt == nil {
+				t.Errorf("Expected comment %s to be sent", c.ID)
+			}
+		}
+		if _, err := svc.UpdateReviewComment(ctx, session.ProjectID, session.ID, th.ID, th.Comments[0].ID, "edited"); !errors.Is(err, ErrReviewCommentSent) {
+			t.Errorf("Expected ErrReviewCommentSent, got %v", err)
+		}
+	}
+
+	// The session is running the review now
+	if _, err := svc.SendReview(ctx, session.ProjectID, session.ID, SendReviewRequest{}); !errors.Is(err, ErrReviewSessionBusy) {
+		t.Errorf("Expected ErrReviewSessionBusy, got %v", err)
+	}
+	if err := svc.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	if _, err := svc.SendReview(ctx, session.ProjectID, session.ID, SendReviewRequest{}); !errors.Is(err, ErrNoPendingReview) {
+		t.Errorf("Expected ErrNoPendingReview, got %v", err)
+	}
+}
+
+func TestReviewService_UpdateOutdated(t *testing.T) {
+	svc, _, session := newReviewTestEnv(t)
+	ctx := context.Background()
+
+	changed, err := svc.CreateReviewThread(ctx, session.ProjectID, session.ID, "", CreateReviewThreadRequest{Path: "main.go", StartLine: 4, Body: "Pass a context"})
+	if err != nil {
+		t.Fatalf("CreateReviewThread failed: %v", err)
+	}
+	kept, err := svc.CreateReviewThread(ctx, session.ProjectID, session.ID, "", CreateReviewThreadRequest{Path: "main.go", StartLine: 1, Body: "Rename the package"})
+	if err != nil {
+		t.Fatalf("CreateReviewThread failed: %v", err)
+	}
+
+	// The agent changed run() in a later turn; the package line is unchanged
+	patch := strings.Replace(reviewTestPatch, "+\trun()", "+\trun(ctx)", 1)
+	diff := &sandboxapi.DiffResponse{Files: []sandboxapi.FileDiffEntry{{Path: "main.go", Patch: patch}}}
+	if err := svc.UpdateOutdated(ctx, session.ID, diff); err != nil {
+		t.Fatalf("UpdateOutdated failed: %v", err)
+	}
+
+	outdated := func() map[string]bool {
+		threads, err := svc.ListReviewThreads(ctx, session.ProjectID, session.ID)
+		if err != nil {
+			t.Fatalf("ListReviewThreads failed: %v", err)
+		}
+		result := make(map[string]bool)
+		for _, th := range threads {
+			result[th.ID] = th.Outdated
+		}
+		return result
+	}
+	if got := outdated(); !got[changed.ID] || got[kept.ID] {
+		t.Errorf("Expected only the changed thread to be outdated, got %v", got)
+	}
+
+	// A full diff without the file means its changes were reverted
+	if err := svc.UpdateOutdated(ctx, session.ID, &sandboxapi.DiffResponse{}); err != nil {
+		t.Fatalf("UpdateOutdated failed: %v", err)
+	}
+	if got := outdated(); !got[kept.ID] {
+		t.Errorf("Expected thread on an unchanged file to be outdated, got %v", got)
+	}
+}
+
+func TestReviewService_UpdateOutdatedMovesThreads(t *testing.T) {
+	svc, _, session := newReviewTestEnv(t)
+	ctx := context.Background()
+
+	thread, err := svc.CreateReviewThread(ctx, session.ProjectID, session.ID, "", CreateReviewThreadRequest{Path: "main.go", StartLine: 3, EndLine: 5, Body: "Handle errors"})
+	if err != nil {
+		t.Fatalf("CreateReviewThread failed: %v", err)
+	}
+
+	// The agent added an import above the commented lines
+	patch := strings.Replace(reviewTestPatch, "@@ -1,3 +1,5 @@\n package main\n\n", "@@ -1,3 +1,7 @@\n package main\n\n+import \"os\"\n+\n", 1)
+	diff := &sandboxapi.DiffResponse{Files: []sandboxapi.FileDiffEntry{{Path: "main.go", Patch: patch}}}
+	if err := svc.UpdateOutdated(ctx, session.ID, diff); err != nil {
+		t.Fatalf("UpdateOutdated failed: %v", err)
+	}
+
+	threads, err := svc.ListReviewThreads(ctx, session.ProjectID, session.ID)
+	if err != nil {
+		t.Fatalf("ListReviewThreads failed: %v", err)
+	}
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+	got := threads[0]
+	if got.ID != thread.ID || got.Outdated || got.StartLine != 5 || got.EndLine != 7 {
+		t.Errorf("Expected thread moved to lines 5-7, got %+v", got)
+	}
+	if got.Revision != patchRevision(patch) {
+		t.Errorf("Revision = %s, want %s", got.Revision, patchRevision(patch))
+	}
+}
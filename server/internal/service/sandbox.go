@@ -46,6 +46,10 @@ type SandboxService struct {
 	// Health probe cache — skip probing if container was healthy recently
 	healthCacheMap map[string]time.Time
 	healthCacheMu  sync.RWMutex
+
+	// Secrets given to each sandbox's proxy, with credential placeholders
+	proxySecrets   map[string]pushedSecrets
+	proxySecretsMu sync.Mutex
 }
 
 const healthCacheTTL = 10 * time.Second
@@ -61,6 +65,7 @@ func NewSandboxService(s *store.Store, p sandbox.Provider, cfg *config.Config, c
 		jobEnqueuer:       jobEnqueuer,
 		lastActivityMap:   make(map[string]time.Time),
 		healthCacheMap:    make(map[string]time.Time),
+		proxySecrets:      make(map[string]pushedSecrets),
 	}
 }
 
@@ -101,7 +106,11 @@ func (s *SandboxService) GetClient(ctx context.Context, sessionID string) (*Sess
 
 	gitName, gitEmail := s.getGitConfig(ctx)
 
-	inner := NewSandboxChatClient(s.provider, s.credentialFetcher, agentType, &SandboxChatClientConfig{
+	var fetcher CredentialFetcher
+	if s.credentialFetcher != nil {
+		fetcher = s.sandboxCredentials
+	}
+	inner := NewSandboxChatClient(s.provider, fetcher, agentType, &SandboxChatClientConfig{
 		GitUserName:  gitName,
 		GitUserEmail: gitEmail,
 	})
@@ -287,8 +296,8 @@ func (s *SandboxService) ReconcileSandbox(ctx context.Context, sessionID string)
 		return fmt.Errorf("failed to wait for job completion: %w", err)
 	}
 
-	if status == "failed" {
-		return fmt.Errorf("session initialization failed: %s", errorMsg)
+	if status != "completed" {
+		return fmt.Errorf("session initialization %s: %s", status, errorMsg)
 	}
 
 	log.Printf("Session %s initialized successfully via job", sessionID)
@@ -338,9 +347,11 @@ func (s *SandboxService) CreateForSession(ctx context.Context, sessionID string)
 			"discobot.workspace.id": session.WorkspaceID,
 			"discobot.project.id":   session.ProjectID,
 		},
-		WorkspacePath:   workspacePath,
-		WorkspaceSource: workspace.Path, // Original workspace path (local or git URL)
-		WorkspaceCommit: workspaceCommit,
+		WorkspacePath:       workspacePath,
+		WorkspaceSource:     workspace.Path, // Original workspace path (local or git URL)
+		WorkspaceCommit:     workspaceCommit,
+		WorkspaceSubmodules: workspace.Submodules,
+		WorkspaceLFS:        workspace.LFS,
 		Resources: sandbox.ResourceConfig{
 			Timeout: s.cfg.SandboxIdleTimeout,
 		},
@@ -395,6 +406,7 @@ func (s *SandboxService) Attach(ctx context.Context, sessionID string, rows, col
 
 // StopForSession stops the sandbox for a session.
 func (s *SandboxService) StopForSession(ctx context.Context, sessionID string) error {
+	s.forgetProxySecrets(sessionID)
 	return s.provider.Stop(ctx, sessionID, 10*time.Second)
 }
 
@@ -442,6 +454,7 @@ func (s *SandboxService) probeSandboxHealth(ctx context.Context, sessionID strin
 // DestroyForSession removes the sandbox when a session is deleted.
 // This is deprecated - use SessionService.PerformDeletion instead which handles volumes.
 func (s *SandboxService) DestroyForSession(ctx context.Context, sessionID string) error {
+	s.forgetProxySecrets(sessionID)
 	err := s.provider.Remove(ctx, sessionID)
 	if errors.Is(err, sandbox.ErrNotFound) {
 		// Already removed, not an error
@@ -486,6 +499,7 @@ func (s *SandboxService) ReconcileSandboxes(ctx context.Context) error {
 		if err != nil {
 			log.Printf("Failed to get session %s, removing orphaned sandbox: %v", sb.SessionID, err)
 			// Preserve volumes for orphaned sandboxes in case of recovery
+			s.forgetProxySecrets(sb.SessionID)
 			if err := s.provider.Remove(ctx, sb.SessionID); err != nil {
 				log.Printf("Failed to remove orphaned sandbox for session %s: %v", sb.SessionID, err)
 			}
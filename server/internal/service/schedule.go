@@ -0,0 +1,644 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/obot-platform/discobot/server/internal/cron"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Schedule errors
+var (
+	ErrScheduleNotFound  = errors.New("schedule not found")
+	ErrScheduleRunActive = errors.New("the previous run of this schedule is still active")
+	ErrInvalidSchedule   = errors.New("invalid schedule")
+)
+
+// scheduleRunHistoryLimit caps the runs returned by ListScheduleRuns.
+const scheduleRunHistoryLimit = 100
+
+// Schedule represents a recurring session schedule (for API responses)
+type Schedule struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Cron           string     `json:"cron"`
+	Timezone       string     `json:"timezone,omitempty"`
+	WorkspaceID    string     `json:"workspaceId"`
+	AgentID        string     `json:"agentId"`
+	Model          string     `json:"model,omitempty"`
+	Mode           string     `json:"mode,omitempty"`
+	PromptTemplate string     `json:"promptTemplate"`
+	AutoCommit     bool       `json:"autoCommit"`
+	Enabled        bool       `json:"enabled"`
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+	LastRunAt      *time.Time `json:"lastRunAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// ScheduleRun represents one run of a schedule (for API responses)
+type ScheduleRun struct {
+	ID           string     `json:"id"`
+	ScheduleID   string     `json:"scheduleId"`
+	SessionID    string     `json:"sessionId,omitempty"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	ScheduledFor time.Time  `json:"scheduledFor"`
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
+	CompletedAt  *time.Time `json:"completedAt,omitempty"`
+}
+
+// ScheduleRequest contains the fields of a schedule. On update, nil fields
+// are left unchanged.
+type ScheduleRequest struct {
+	Name           *string `json:"name"`
+	Cron           *string `json:"cron"`
+	Timezone       *string `json:"timezone"`
+	WorkspaceID    *string `json:"workspaceId"`
+	AgentID        *string `json:"agentId"`
+	Model          *string `json:"model"`
+	Mode           *string `json:"mode"`
+	PromptTemplate *string `json:"promptTemplate"`
+	AutoCommit     *bool   `json:"autoCommit"`
+	Enabled        *bool   `json:"enabled"`
+}
+
+// PromptData is the data available to a schedule's prompt template.
+type PromptData struct {
+	// ScheduleName is the name of the schedule
+	ScheduleName string
+	// Date is the run's scheduled date (YYYY-MM-DD) in the schedule's timezone
+	Date string
+	// Time is the run's scheduled time in the schedule's timezone
+	Time time.Time
+}
+
+// ScheduleService manages schedules and starts their sessions.
+type ScheduleService struct {
+	store       *store.Store
+	chatService *ChatService
+	jobEnqueuer JobEnqueuer
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(s *store.Store, chatService *ChatService, jobEnqueuer JobEnqueuer) *ScheduleService {
+	return &ScheduleService{
+		store:       s,
+		chatService: chatService,
+		jobEnqueuer: jobEnqueuer,
+	}
+}
+
+// ListSchedules returns all schedules for a project
+func (s *ScheduleService) ListSchedules(ctx context.Context, projectID string) ([]*Schedule, error) {
+	dbSchedules, err := s.store.ListSchedulesByProject(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	result := make([]*Schedule, len(dbSchedules))
+	for i, sched := range dbSchedules {
+		result[i] = mapSchedule(sched)
+	}
+	return result, nil
+}
+
+// GetSchedule returns a schedule of the project
+func (s *ScheduleService) GetSchedule(ctx context.Context, projectID, scheduleID string) (*Schedule, error) {
+	sched, err := s.getSchedule(ctx, projectID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return mapSchedule(sched), nil
+}
+
+// CreateSchedule creates a schedule. Name, cron, workspaceId, agentId and
+// promptTemplate are required; schedules are enabled unless enabled is false.
+func (s *ScheduleService) CreateSchedule(ctx context.Context, projectID string, req ScheduleRequest) (*Schedule, error) {
+	sched := &model.Schedule{ProjectID: projectID, Enabled: true}
+	applyScheduleRequest(sched, req)
+	if err := s.validateSchedule(ctx, sched); err != nil {
+		return nil, err
+	}
+	if err := setNextRun(sched, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.CreateSchedule(ctx, sched); err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return mapSchedule(sched), nil
+}
+
+// UpdateSchedule updates the fields set in req and recomputes the next run.
+func (s *ScheduleService) UpdateSchedule(ctx context.Context, projectID, scheduleID string, req ScheduleRequest) (*Schedule, error) {
+	sched, err := s.getSchedule(ctx, projectID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyScheduleRequest(sched, req)
+	if err := s.validateSchedule(ctx, sched); err != nil {
+		return nil, err
+	}
+	if err := setNextRun(sched, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.UpdateSchedule(ctx, sched); err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return mapSchedule(sched), nil
+}
+
+// DeleteSchedule deletes a schedule and its run history. Sessions started by
+// the schedule are kept.
+func (s *ScheduleService) DeleteSchedule(ctx context.Context, projectID, scheduleID string) error {
+	if _, err := s.getSchedule(ctx, projectID, scheduleID); err != nil {
+		return err
+	}
+	if err := s.store.DeleteSchedule(ctx, scheduleID); err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return nil
+}
+
+// ListScheduleRuns returns the schedule's most recent runs, newest first.
+func (s *ScheduleService) ListScheduleRuns(ctx context.Context, projectID, scheduleID string) ([]*ScheduleRun, error) {
+	if _, err := s.getSchedule(ctx, projectID, scheduleID); err != nil {
+		return nil, err
+	}
+
+	runs, err := s.store.ListScheduleRuns(ctx, scheduleID, scheduleRunHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+
+	result := make([]*ScheduleRun, len(runs))
+	for i, run := range runs {
+		result[i] = mapScheduleRun(run)
+	}
+	return result, nil
+}
+
+// TriggerSchedule starts a run of the schedule now, outside its cron
+// schedule. Returns ErrScheduleRunActive if the previous run is still active.
+func (s *ScheduleService) TriggerSchedule(ctx context.Context, projectID, scheduleID string) (*ScheduleRun, error) {
+	sched, err := s.getSchedule(ctx, projectID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.store.HasActiveScheduleRun(ctx, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for active runs: %w", err)
+	}
+	if active {
+		return nil, ErrScheduleRunActive
+	}
+
+	run, err := s.startRun(ctx, sched, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return mapScheduleRun(run), nil
+}
+
+// ProcessSchedules starts the runs of all due schedules and records the
+// outcome of runs whose agent has finished. It is called periodically by
+// the dispatcher leader.
+func (s *ScheduleService) ProcessSchedules(ctx context.Context, now time.Time) error {
+	// Next run times are stored in UTC
+	now = now.UTC()
+	if err := s.startDueSchedules(ctx, now); err != nil {
+		return err
+	}
+	return s.finishScheduleRuns(ctx)
+}
+
+// startDueSchedules starts a run for every schedule whose next run time has
+// passed. Missed runs (e.g. while the server was down) are collapsed into one.
+func (s *ScheduleService) startDueSchedules(ctx context.Context, now time.Time) error {
+	due, err := s.store.ListDueSchedules(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list due schedules: %w", err)
+	}
+
+	for _, sched := range due {
+		scheduledFor := *sched.NextRunAt
+		if err := setNextRun(sched, now); err != nil {
+			log.Printf("Schedule %s has an invalid cron expression, disabling: %v", sched.ID, err)
+			sched.Enabled = false
+			sched.NextRunAt = nil
+			if err := s.store.UpdateSchedule(ctx, sched); err != nil {
+				log.Printf("Failed to disable schedule %s: %v", sched.ID, err)
+			}
+			continue
+		}
+
+		claimed, err := s.store.ClaimScheduleRun(ctx, sched.ID, now, sched.NextRunAt)
+		if err != nil {
+			log.Printf("Failed to claim run of schedule %s: %v", sched.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		active, err := s.store.HasActiveScheduleRun(ctx, sched.ID)
+		if err != nil {
+			log.Printf("Failed to check for active runs of schedule %s: %v", sched.ID, err)
+			continue
+		}
+		if active {
+			log.Printf("Schedule %s: skipping run, previous run still active", sched.ID)
+			s.recordSkippedRun(ctx, sched, scheduledFor)
+			continue
+		}
+
+		if _, err := s.startRun(ctx, sched, scheduledFor); err != nil {
+			log.Printf("Failed to start run of schedule %s: %v", sched.ID, err)
+		}
+	}
+	return nil
+}
+
+// startRun records a pending run and enqueues the job that starts its session.
+func (s *ScheduleService) startRun(ctx context.Context, sched *model.Schedule, scheduledFor time.Time) (*model.ScheduleRun, error) {
+	run := &model.ScheduleRun{
+		ScheduleID:   sched.ID,
+		ProjectID:    sched.ProjectID,
+		Status:       model.ScheduleRunStatusPending,
+		ScheduledFor: scheduledFor,
+	}
+	if err := s.store.CreateScheduleRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to create schedule run: %w", err)
+	}
+
+	if err := s.jobEnqueuer.Enqueue(ctx, jobs.ScheduleRunPayload{
+		ProjectID:  sched.ProjectID,
+		ScheduleID: sched.ID,
+		RunID:      run.ID,
+	}); err != nil {
+		s.failRun(ctx, run, fmt.Sprintf("failed to enqueue run: %v", err))
+		return nil, fmt.Errorf("failed to enqueue schedule run: %w", err)
+	}
+
+	log.Printf("Schedule %s: started run %s", sched.ID, run.ID)
+	return run, nil
+}
+
+func (s *ScheduleService) recordSkippedRun(ctx context.Context, sched *model.Schedule, scheduledFor time.Time) {
+	now := time.Now()
+	run := &model.ScheduleRun{
+		ScheduleID:   sched.ID,
+		ProjectID:    sched.ProjectID,
+		Status:       model.ScheduleRunStatusSkipped,
+		Error:        ptrString(ErrScheduleRunActive.Error()),
+		ScheduledFor: scheduledFor,
+		CompletedAt:  &now,
+	}
+	if err := s.store.CreateScheduleRun(ctx, run); err != nil {
+		log.Printf("Failed to record skipped run of schedule %s: %v", sched.ID, err)
+	}
+}
+
+// RunScheduledSession creates the session for a pending run and sends it the
+// schedule's prompt. It returns once the agent has accepted the prompt; the
+// run is finished by ProcessSchedules when the completion ends.
+// This is called by the dispatcher when processing a schedule_run job.
+func (s *ScheduleService) RunScheduledSession(ctx context.Context, runID string) (retErr error) {
+	run, err := s.store.GetScheduleRunByID(ctx, runID)
+	if err != nil {
+		return fmt.Errorf("schedule run not found: %w", err)
+	}
+	if run.Status != model.ScheduleRunStatusPending {
+		return nil
+	}
+
+	// Record the failure on the run even if the job was cancelled
+	defer func() {
+		if retErr != nil {
+			s.failRun(context.WithoutCancel(ctx), run, retErr.Error())
+		}
+	}()
+
+	if run.SessionID != nil {
+		// A previous attempt created the session but may or may not have sent
+		// the prompt; sending it again could run the agent twice
+		return fmt.Errorf("run was interrupted before the prompt was confirmed")
+	}
+
+	sched, err := s.store.GetScheduleByID(ctx, run.ScheduleID)
+	if err != nil {
+		return fmt.Errorf("schedule not found: %w", err)
+	}
+
+	prompt, err := renderPrompt(sched, run.ScheduledFor)
+	if err != nil {
+		return err
+	}
+	modelID, mode := "", ""
+	if sched.Model != nil {
+		modelID = *sched.Model
+	}
+	if sched.Mode != nil {
+		mode = *sched.Mode
+	}
+
+	sessionID, err := s.chatService.NewSession(ctx, NewSessionRequest{
+		SessionID:   uuid.New().String(),
+		ProjectID:   sched.ProjectID,
+		WorkspaceID: sched.WorkspaceID,
+		AgentID:     sched.AgentID,
+		Model:       modelID,
+		Mode:        mode,
+		Name:        strings.TrimSpace(prompt),
+	})
+	if err != nil {
+		return err
+	}
+	run.SessionID = &sessionID
+	if err := s.store.UpdateScheduleRun(ctx, run); err != nil {
+		return fmt.Errorf("failed to update schedule run: %w", err)
+	}
+
+	// Sending waits for the session to initialize
+	if _, err := s.chatService.StartPrompt(ctx, sched.ProjectID, sessionID, prompt, modelID, mode); err != nil {
+		return fmt.Errorf("failed to send prompt: %w", err)
+	}
+
+	now := time.Now()
+	run.Status = model.ScheduleRunStatusRunning
+	run.StartedAt = &now
+	if err := s.store.UpdateScheduleRun(ctx, run); err != nil {
+		return fmt.Errorf("failed to update schedule run: %w", err)
+	}
+
+	log.Printf("Schedule %s: run %s started session %s", sched.ID, run.ID, sessionID)
+	return nil
+}
+
+// finishScheduleRuns completes running runs whose session is no longer
+// running, and commits the session's changes if the schedule asks for it.
+func (s *ScheduleService) finishScheduleRuns(ctx context.Context) error {
+	runs, err := s.store.ListScheduleRunsByStatus(ctx, model.ScheduleRunStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to list running schedule runs: %w", err)
+	}
+
+	for _, run := range runs {
+		if run.SessionID == nil {
+			s.failRun(ctx, run, "run has no session")
+			continue
+		}
+
+		sess, err := s.store.GetSessionByID(ctx, *run.SessionID)
+		if errors.Is(err, store.ErrNotFound) {
+			s.failRun(ctx, run, "session was deleted")
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to get session of schedule run %s: %v", run.ID, err)
+			continue
+		}
+
+		switch sess.Status {
+		case model.SessionStatusReady, model.SessionStatusStopped:
+		case model.SessionStatusError:
+			msg := "session failed"
+			if sess.ErrorMessage != nil {
+				msg = *sess.ErrorMessage
+			}
+			s.failRun(ctx, run, msg)
+			continue
+		case model.SessionStatusRemoving, model.SessionStatusRemoved:
+			s.failRun(ctx, run, "session was deleted")
+			continue
+		default:
+			// Still working (or restarting)
+			continue
+		}
+
+		if sched, err := s.store.GetScheduleByID(ctx, run.ScheduleID); err == nil && sched.AutoCommit {
+			if err := s.chatService.sessionService.CommitSession(ctx, sess.ProjectID, sess.ID, s.jobEnqueuer); err != nil {
+				s.failRun(ctx, run, fmt.Sprintf("failed to start commit: %v", err))
+				continue
+			}
+		}
+
+		now := time.Now()
+		run.Status = model.ScheduleRunStatusCompleted
+		run.CompletedAt = &now
+		if err := s.store.UpdateScheduleRun(ctx, run); err != nil {
+			log.Printf("Failed to complete schedule run %s: %v", run.ID, err)
+			continue
+		}
+		log.Printf("Schedule %s: run %s completed", run.ScheduleID, run.ID)
+	}
+	return nil
+}
+
+func (s *ScheduleService) failRun(ctx context.Context, run *model.ScheduleRun, errorMsg string) {
+	log.Printf("Schedule %s: run %s failed: %s", run.ScheduleID, run.ID, errorMsg)
+
+	now := time.Now()
+	run.Status = model.ScheduleRunStatusFailed
+	run.Error = &errorMsg
+	run.CompletedAt = &now
+	if err := s.store.UpdateScheduleRun(ctx, run); err != nil {
+		log.Printf("Failed to update schedule run %s: %v", run.ID, err)
+	}
+}
+
+func (s *ScheduleService) getSchedule(ctx context.Context, projectID, scheduleID string) (*model.Schedule, error) {
+	sched, err := s.store.GetScheduleByID(ctx, scheduleID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	if sched.ProjectID != projectID {
+		return nil, ErrScheduleNotFound
+	}
+	return sched, nil
+}
+
+// validateSchedule checks a schedule's fields and that its workspace and
+// agent belong to its project.
+func (s *ScheduleService) validateSchedule(ctx context.Context, sched *model.Schedule) error {
+	if strings.TrimSpace(sched.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSchedule)
+	}
+	if _, err := cron.Parse(sched.Cron); err != nil {
+		return fmt.Errorf("%w: cron: %v", ErrInvalidSchedule, err)
+	}
+	if _, err := time.LoadLocation(sched.Timezone); err != nil {
+		return fmt.Errorf("%w: timezone: %v", ErrInvalidSchedule, err)
+	}
+	if strings.TrimSpace(sched.PromptTemplate) == "" {
+		return fmt.Errorf("%w: promptTemplate is required", ErrInvalidSchedule)
+	}
+	if _, err := template.New("prompt").Parse(sched.PromptTemplate); err != nil {
+		return fmt.Errorf("%w: promptTemplate: %v", ErrInvalidSchedule, err)
+	}
+	if sched.Mode != nil && *sched.Mode != "" && *sched.Mode != "plan" {
+		return fmt.Errorf("%w: mode must be \"plan\" or empty", ErrInvalidSchedule)
+	}
+
+	if sched.WorkspaceID == "" {
+		return fmt.Errorf("%w: workspaceId is required", ErrInvalidSchedule)
+	}
+	workspace, err := s.store.GetWorkspaceByID(ctx, sched.WorkspaceID)
+	if err != nil || workspace.ProjectID != sched.ProjectID {
+		return fmt.Errorf("%w: workspace not found", ErrInvalidSchedule)
+	}
+	if sched.AgentID == "" {
+		return fmt.Errorf("%w: agentId is required", ErrInvalidSchedule)
+	}
+	agent, err := s.store.GetAgentByID(ctx, sched.AgentID)
+	if err != nil || agent.ProjectID != sched.ProjectID {
+		return fmt.Errorf("%w: agent not found", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+func applyScheduleRequest(sched *model.Schedule, req ScheduleRequest) {
+	if req.Name != nil {
+		sched.Name = *req.Name
+	}
+	if req.Cron != nil {
+		sched.Cron = *req.Cron
+	}
+	if req.Timezone != nil {
+		sched.Timezone = *req.Timezone
+	}
+	if req.WorkspaceID != nil {
+		sched.WorkspaceID = *req.WorkspaceID
+	}
+	if req.AgentID != nil {
+		sched.AgentID = *req.AgentID
+	}
+	if req.Model != nil {
+		sched.Model = req.Model
+	}
+	if req.Mode != nil {
+		sched.Mode = req.Mode
+	}
+	if req.PromptTemplate != nil {
+		sched.PromptTemplate = *req.PromptTemplate
+	}
+	if req.AutoCommit != nil {
+		sched.AutoCommit = *req.AutoCommit
+	}
+	if req.Enabled != nil {
+		sched.Enabled = *req.Enabled
+	}
+}
+
+// setNextRun sets the schedule's next run time after now, or clears it if
+// the schedule is disabled.
+func setNextRun(sched *model.Schedule, now time.Time) error {
+	if !sched.Enabled {
+		sched.NextRunAt = nil
+		return nil
+	}
+
+	expr, err := cron.Parse(sched.Cron)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression: %w", err)
+	}
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	next := expr.Next(now.In(loc))
+	if next.IsZero() {
+		sched.NextRunAt = nil
+		return nil
+	}
+	next = next.UTC()
+	sched.NextRunAt = &next
+	return nil
+}
+
+// renderPrompt executes the schedule's prompt template for a run.
+func renderPrompt(sched *model.Schedule, scheduledFor time.Time) (string, error) {
+	tmpl, err := template.New("prompt").Parse(sched.PromptTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	// The timezone was validated when the schedule was saved
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := scheduledFor.In(loc)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, PromptData{
+		ScheduleName: sched.Name,
+		Date:         local.Format("2006-01-02"),
+		Time:         local,
+	}); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// mapSchedule maps a model.Schedule to a service.Schedule
+func mapSchedule(sched *model.Schedule) *Schedule {
+	s := &Schedule{
+		ID:             sched.ID,
+		Name:           sched.Name,
+		Cron:           sched.Cron,
+		Timezone:       sched.Timezone,
+		WorkspaceID:    sched.WorkspaceID,
+		AgentID:        sched.AgentID,
+		PromptTemplate: sched.PromptTemplate,
+		AutoCommit:     sched.AutoCommit,
+		Enabled:        sched.Enabled,
+		NextRunAt:      sched.NextRunAt,
+		LastRunAt:      sched.LastRunAt,
+		CreatedAt:      sched.CreatedAt,
+	}
+	if sched.Model != nil {
+		s.Model = *sched.Model
+	}
+	if sched.Mode != nil {
+		s.Mode = *sched.Mode
+	}
+	return s
+}
+
+// mapScheduleRun maps a model.ScheduleRun to a service.ScheduleRun
+func mapScheduleRun(run *model.ScheduleRun) *ScheduleRun {
+	r := &ScheduleRun{
+		ID:           run.ID,
+		ScheduleID:   run.ScheduleID,
+		Status:       run.Status,
+		ScheduledFor: run.ScheduledFor,
+		StartedAt:    run.StartedAt,
+		CompletedAt:  run.CompletedAt,
+	}
+	if run.SessionID != nil {
+		r.SessionID = *run.SessionID
+	}
+	if run.Error != nil {
+		r.Error = *run.Error
+	}
+	return r
+}
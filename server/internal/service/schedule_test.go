@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+type scheduleTestEnv struct {
+	*testEnv
+	svc         *ScheduleService
+	enqueuer    *recordingEnqueuer
+	projectID   string
+	workspaceID string
+	agentID     string
+	commit      string
+}
+
+func newScheduleTestEnv(t *testing.T) *scheduleTestEnv {
+	t.Helper()
+
+	env := newTestEnv(t)
+	t.Cleanup(env.cleanup)
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, commit := env.createTestWorkspace(t, project.ID)
+
+	enqueuer := &recordingEnqueuer{}
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, enqueuer)
+	chatSvc := NewChatService(env.store, sessionSvc, enqueuer, env.eventBroker, sandboxSvc, env.gitService)
+
+	return &scheduleTestEnv{
+		testEnv:     env,
+		svc:         NewScheduleService(env.store, chatSvc, enqueuer),
+		enqueuer:    enqueuer,
+		projectID:   project.ID,
+		workspaceID: workspace.ID,
+		agentID:     agent.ID,
+		commit:      commit,
+	}
+}
+
+func (e *scheduleTestEnv) request(cron string) ScheduleRequest {
+	return ScheduleRequest{
+		Name:           ptrString("Nightly"),
+		Cron:           ptrString(cron),
+		WorkspaceID:    ptrString(e.workspaceID),
+		AgentID:        ptrString(e.agentID),
+		PromptTemplate: ptrString("Run {{.ScheduleName}} for {{.Date}}"),
+	}
+}
+
+// makeDue moves the schedule's next run into the past.
+func (e *scheduleTestEnv) makeDue(t *testing.T, scheduleID string, at time.Time) {
+	t.Helper()
+	sched, err := e.store.GetScheduleByID(context.Background(), scheduleID)
+	if err != nil {
+		t.Fatalf("Failed to get schedule: %v", err)
+	}
+	sched.NextRunAt = &at
+	if err := e.store.UpdateSchedule(context.Background(), sched); err != nil {
+		t.Fatalf("Failed to update schedule: %v", err)
+	}
+}
+
+func (e *scheduleTestEnv) runPayloads() []jobs.ScheduleRunPayload {
+	var result []jobs.ScheduleRunPayload
+	for _, p := range e.enqueuer.payloads {
+		if rp, ok := p.(jobs.ScheduleRunPayload); ok {
+			result = append(result, rp)
+		}
+	}
+	return result
+}
+
+func TestScheduleService_CreateAndValidate(t *testing.T) {
+	env := newScheduleTestEnv(t)
+	ctx := context.Background()
+
+	sched, err := env.svc.CreateSchedule(ctx, env.projectID, env.request("0 2 * * *"))
+	if err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+	if !sched.Enabled {
+		t.Error("Expected new schedule to be enabled")
+	}
+	if sched.NextRunAt == nil {
+		t.Fatal("Expected next run to be set")
+	}
+	if sched.NextRunAt.UTC().Hour() != 2 || sched.NextRunAt.Minute() != 0 || !sched.NextRunAt.After(time.Now()) {
+		t.Errorf("Unexpected next run %v", sched.NextRunAt)
+	}
+
+	disable := false
+	disabled, err := env.svc.UpdateSchedule(ctx, env.projectID, sched.ID, ScheduleRequest{Enabled: &disable})
+	if err != nil {
+		t.Fatalf("UpdateSchedule failed: %v", err)
+	}
+	if disabled.Enabled || disabled.NextRunAt != nil {
+		t.Errorf("Expected disabled schedule without next run, got enabled=%v next=%v", disabled.Enabled, disabled.NextRunAt)
+	}
+
+	invalid := []func(*ScheduleRequest){
+		func(r *ScheduleRequest) { r.Name = ptrString(" ") },
+		func(r *ScheduleRequest) { r.Cron = ptrString("every day") },
+		func(r *ScheduleRequest) { r.Timezone = ptrString("Mars/Olympus") },
+		func(r *ScheduleRequest) { r.PromptTemplate = ptrString("{{.Date") },
+		func(r *ScheduleRequest) { r.Mode = ptrString("yolo") },
+		func(r *ScheduleRequest) { r.WorkspaceID = ptrString("missing") },
+		func(r *ScheduleRequest) { r.AgentID = nil },
+	}
+	for i, mutate := range invalid {
+		req := env.request("0 2 * * *")
+		mutate(&req)
+		if _, err := env.svc.CreateSchedule(ctx, env.projectID, req); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("case %d: expected ErrInvalidSchedule, got %v", i, err)
+		}
+	}
+
+	if _, err := env.svc.GetSchedule(ctx, "other-project", sched.ID); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("Expected ErrScheduleNotFound for other project, got %v", err)
+	}
+}
+
+func TestScheduleService_ProcessSchedules(t *testing.T) {
+	env := newScheduleTestEnv(t)
+	ctx := context.Background()
+
+	sched, err := env.svc.CreateSchedule(ctx, env.projectID, env.request("*/5 * * * *"))
+	if err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+
+	// Not due yet
+	if err := env.svc.ProcessSchedules(ctx, time.Now()); err != nil {
+		t.Fatalf("ProcessSchedules failed: %v", err)
+	}
+	if len(env.runPayloads()) != 0 {
+		t.Fatal("Expected no run before the schedule is due")
+	}
+
+	due := time.Now().Add(-time.Minute).UTC().Truncate(time.Minute)
+	env.makeDue(t, sched.ID, due)
+	if err := env.svc.ProcessSchedules(ctx, time.Now()); err != nil {
+		t.Fatalf("ProcessSchedules failed: %v", err)
+	}
+
+	payloads := env.runPayloads()
+	if len(payloads) != 1 || payloads[0].ScheduleID != sched.ID {
+		t.Fatalf("Expected one schedule_run job, got %+v", payloads)
+	}
+	run, err := env.store.GetScheduleRunByID(ctx, payloads[0].RunID)
+	if err != nil {
+		t.Fatalf("Failed to get run: %v", err)
+	}
+	if run.Status != model.ScheduleRunStatusPending || !run.ScheduledFor.Equal(due) {
+		t.Errorf("Unexpected run: status=%s scheduledFor=%v", run.Status, run.ScheduledFor)
+	}
+
+	updated, err := env.svc.GetSchedule(ctx, env.projectID, sched.ID)
+	if err != nil {
+		t.Fatalf("GetSchedule failed: %v", err)
+	}
+	if updated.NextRunAt == nil || !updated.NextRunAt.After(time.Now()) || updated.LastRunAt == nil {
+		t.Errorf("Expected next run in the future and last run set, got next=%v last=%v", updated.NextRunAt, updated.LastRunAt)
+	}
+
+	// Due again while the first run is still pending: skipped
+	env.makeDue(t, sched.ID, due.Add(time.Minute))
+	if err := env.svc.ProcessSchedules(ctx, time.Now()); err != nil {
+		t.Fatalf("ProcessSchedules failed: %v", err)
+	}
+	if len(env.runPayloads()) != 1 {
+		t.Errorf("Expected no new job while a run is active, got %d", len(env.runPayloads()))
+	}
+	runs, err := env.svc.ListScheduleRuns(ctx, env.projectID, sched.ID)
+	if err != nil {
+		t.Fatalf("ListScheduleRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Status != model.ScheduleRunStatusSkipped {
+		t.Errorf("Expected newest run to be skipped, got %+v", runs)
+	}
+
+	if _, err := env.svc.TriggerSchedule(ctx, env.projectID, sched.ID); !errors.Is(err, ErrScheduleRunActive) {
+		t.Errorf("Expected ErrScheduleRunActive, got %v", err)
+	}
+}
+
+func TestScheduleService_FinishRun(t *testing.T) {
+	env := newScheduleTestEnv(t)
+	ctx := context.Background()
+
+	req := env.request("0 2 * * *")
+	autoCommit := true
+	req.AutoCommit = &autoCommit
+	sched, err := env.svc.CreateSchedule(ctx, env.projectID, req)
+	if err != nil {
+		t.Fatalf("CreateSchedule failed: %v", err)
+	}
+
+	session := env.createTestSession(t, env.projectID, env.workspaceID, env.agentID, env.commit)
+	session.Status = model.SessionStatusRunning
+	session.CommitStatus = model.CommitStatusNone
+	if err := env.store.UpdateSession(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	startedAt := time.Now()
+	run := &model.ScheduleRun{
+		ScheduleID:   sched.ID,
+		ProjectID:    env.projectID,
+		SessionID:    &session.ID,
+		Status:       model.ScheduleRunStatusRunning,
+		ScheduledFor: startedAt,
+		StartedAt:    &startedAt,
+	}
+	if err := env.store.CreateScheduleRun(ctx, run); err != nil {
+		t.Fatalf("Failed to create run: %v", err)
+	}
+
+	// Agent still working
+	if err := env.svc.ProcessSchedules(ctx, time.Now()); err != nil {
+		t.Fatalf("ProcessSchedules failed: %v", err)
+	}
+	got, _ := env.store.GetScheduleRunByID(ctx, run.ID)
+	if got.Status != model.ScheduleRunStatusRunning {
+		t.Fatalf("Expected run to stay running, got %s", got.Status)
+	}
+
+	// Agent finished
+	session.Status = model.SessionStatusReady
+	if err := env.store.UpdateSession(ctx, session); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if err := env.svc.ProcessSchedules(ctx, time.Now()); err != nil {
+		t.Fatalf("ProcessSchedules failed: %v", err)
+	}
+	got, _ = env.store.GetScheduleRunByID(ctx, run.ID)
+	if got.Status != model.ScheduleRunStatusCompleted || got.CompletedAt == nil {
+		t.Errorf("Expected completed run, got %s", got.Status)
+	}
+
+	var commits int
+	for _, p := range env.enqueuer.payloads {
+		if cp, ok := p.(jobs.SessionCommitPayload); ok && cp.SessionID == session.ID {
+			commits++
+		}
+	}
+	if commits != 1 {
+		t.Errorf("Expected one session_commit job, got %d", commits)
+	}
+	sess, _ := env.store.GetSessionByID(ctx, session.ID)
+	if sess.CommitStatus != model.CommitStatusPending {
+		t.Errorf("Expected commit status pending, got %s", sess.CommitStatus)
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	sched := &model.Schedule{
+		Name:           "Deps",
+		Timezone:       "UTC",
+		PromptTemplate: "{{.ScheduleName}}: update dependencies on {{.Date}} at {{.Time.Format \"15:04\"}}",
+	}
+	got, err := renderPrompt(sched, time.Date(2026, 3, 4, 2, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("renderPrompt failed: %v", err)
+	}
+	want := "Deps: update dependencies on 2026-03-04 at 02:30"
+	if got != want {
+		t.Errorf("renderPrompt = %q, want %q", got, want)
+	}
+}
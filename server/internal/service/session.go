@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/obot-platform/discobot/server/internal/events"
@@ -16,12 +17,21 @@ import (
 	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/model"
 	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
 	"github.com/obot-platform/discobot/server/internal/store"
 )
 
 // SessionIDMaxLength is the maximum allowed length for a session ID.
 const SessionIDMaxLength = 65
 
+// ErrCommitInProgress is returned when changing how a session commits while
+// one of its commits is running.
+var ErrCommitInProgress = errors.New("a commit of this session is in progress")
+
+// maxConflictRetries is how many times a commit asks the agent to rebase
+// conflicting patches before failing.
+const maxConflictRetries = 3
+
 // sessionIDRegex matches valid session IDs (alphanumeric and hyphens only).
 var sessionIDRegex = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
 
@@ -54,6 +64,7 @@ type Session struct {
 	CommitError     string     `json:"commitError,omitempty"`
 	BaseCommit      string     `json:"baseCommit,omitempty"`
 	AppliedCommit   string     `json:"appliedCommit,omitempty"`
+	TargetBranch    string     `json:"targetBranch,omitempty"`
 	ErrorMessage    string     `json:"errorMessage,omitempty"`
 	Files           []FileNode `json:"files"`
 	WorkspaceID     string     `json:"workspaceId,omitempty"`
@@ -63,6 +74,16 @@ type Session struct {
 	Mode            string     `json:"mode,omitempty"`
 	WorkspacePath   string     `json:"workspacePath,omitempty"`
 	WorkspaceCommit string     `json:"workspaceCommit,omitempty"`
+	ForkedFrom      string     `json:"forkedFrom,omitempty"`
+	ForkMessageID   string     `json:"forkMessageId,omitempty"`
+
+	CommitConflicts *git.ConflictError `json:"commitConflicts,omitempty"`
+	ConflictRetries int                `json:"conflictRetries,omitempty"`
+
+	PullRequestBranch string `json:"pullRequestBranch,omitempty"`
+	PullRequestNumber int    `json:"pullRequestNumber,omitempty"`
+	PullRequestURL    string `json:"pullRequestUrl,omitempty"`
+	PullRequestError  string `json:"pullRequestError,omitempty"`
 }
 
 // FileNode represents a file in a session
@@ -199,6 +220,76 @@ func (s *SessionService) CreateSessionWithID(ctx context.Context, sessionID, pro
 	return s.mapSession(sess), nil
 }
 
+// ForkSession creates a new session that starts from the state of source.
+// The source's data volume is cloned for the new session and history is copied
+// into the new session's messages. The caller enqueues initialization, which
+// boots the sandbox from the cloned volume.
+// Returns sandbox.ErrNotSupported if the sandbox provider cannot clone volumes.
+func (s *SessionService) ForkSession(ctx context.Context, source *model.Session, sessionID, name string, history []sandboxapi.UIMessage) (*Session, error) {
+	cloner, ok := s.sandboxProvider.(sandbox.VolumeCloner)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+
+	var forkMessageID *string
+	if len(history) > 0 {
+		forkMessageID = &history[len(history)-1].ID
+	}
+
+	// Reuse the source's workspace path and commit so initialization takes the
+	// reconcile path instead of checking out the workspace's current commit.
+	sess := &model.Session{
+		ID:              sessionID,
+		ProjectID:       source.ProjectID,
+		WorkspaceID:     source.WorkspaceID,
+		AgentID:         source.AgentID,
+		Model:           source.Model,
+		Reasoning:       source.Reasoning,
+		Mode:            source.Mode,
+		Name:            name,
+		Status:          model.SessionStatusInitializing,
+		WorkspacePath:   source.WorkspacePath,
+		WorkspaceCommit: source.WorkspaceCommit,
+		ForkedFrom:      &source.ID,
+		ForkMessageID:   forkMessageID,
+	}
+	if err := s.store.CreateSession(ctx, sess); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// The session row must exist first: providers resolve the project from it.
+	if err := cloner.CloneVolume(ctx, source.ID, sessionID); err != nil {
+		if delErr := s.store.DeleteSession(ctx, sessionID); delErr != nil {
+			log.Printf("Failed to delete session %s after clone failure: %v", sessionID, delErr)
+		}
+		return nil, fmt.Errorf("failed to clone session data: %w", err)
+	}
+
+	// Offset timestamps so the copied history keeps its order.
+	now := time.Now()
+	messages := make([]*model.Message, len(history))
+	for i, msg := range history {
+		parts := msg.Parts
+		if len(parts) == 0 {
+			parts = json.RawMessage("[]")
+		}
+		// Rows get fresh IDs; MessageID matches the cloned sandbox history.
+		messages[i] = &model.Message{
+			SessionID: sessionID,
+			MessageID: msg.ID,
+			Role:      msg.Role,
+			Parts:     parts,
+			CreatedAt: now.Add(time.Duration(i) * time.Millisecond),
+		}
+	}
+	if err := s.store.CreateMessages(ctx, messages); err != nil {
+		// The fork is usable without the copy, which only backs the database view.
+		log.Printf("Failed to copy message history to forked session %s: %v", sessionID, err)
+	}
+
+	return s.mapSession(sess), nil
+}
+
 // UpdateStatus updates the session status and optional error message, and publishes an SSE event.
 func (s *SessionService) UpdateStatus(ctx context.Context, projectID, sessionID, status string, errorMsg *string) (*Session, error) {
 	// Use targeted column update to avoid overwriting concurrent changes to other fields
@@ -263,6 +354,40 @@ func (s *SessionService) UpdateSession(ctx context.Context, sessionID, name stri
 	return s.mapSession(sess), nil
 }
 
+// SetTargetBranch sets the branch the session commits to. An empty branch
+// commits to the workspace checkout again. The branch cannot change while a
+// commit is in progress.
+func (s *SessionService) SetTargetBranch(ctx context.Context, projectID, sessionID, branch string) (*Session, error) {
+	sess, err := s.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session not found: %w", err)
+	}
+	if sess.ProjectID != projectID {
+		return nil, fmt.Errorf("session not found: %w", store.ErrNotFound)
+	}
+	if branch != "" {
+		if err := git.ValidateBranchName(branch); err != nil {
+			return nil, err
+		}
+	}
+
+	switch sess.CommitStatus {
+	case model.CommitStatusPending, model.CommitStatusCommitting, model.CommitStatusConflicted:
+		return nil, ErrCommitInProgress
+	}
+
+	if branch == "" {
+		sess.TargetBranch = nil
+	} else {
+		sess.TargetBranch = &branch
+	}
+	if err := s.store.UpdateSession(ctx, sess); err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return s.mapSession(sess), nil
+}
+
 // DeleteSession initiates async deletion of a session.
 // It sets the session status to "removing", emits an SSE event, and enqueues a deletion job.
 func (s *SessionService) DeleteSession(ctx context.Context, projectID, sessionID string, jobQueue JobEnqueuer) error {
@@ -321,8 +446,14 @@ func (s *SessionService) CommitSession(ctx context.Context, projectID, sessionID
 		s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusPending)
 	}
 
-	// Enqueue commit job (multiple jobs for same workspace are allowed and serialized)
-	if err = jobQueue.Enqueue(ctx, jobs.SessionCommitPayload{ProjectID: projectID, SessionID: sessionID, WorkspaceID: sess.WorkspaceID}); err != nil {
+	// Enqueue commit job (multiple jobs for same workspace or branch are allowed and serialized)
+	payload := jobs.SessionCommitPayload{
+		ProjectID:   projectID,
+		SessionID:   sessionID,
+		WorkspaceID: sess.WorkspaceID,
+		Branch:      commitJobBranch(ctx, s.gitService, sess),
+	}
+	if err = jobQueue.Enqueue(ctx, payload); err != nil {
 		return fmt.Errorf("failed to enqueue commit job: %w", err)
 	}
 
@@ -339,11 +470,11 @@ func (s *SessionService) publishCommitStatusChanged(ctx context.Context, project
 	}
 }
 
-// ReconcileCommitStates checks sessions stuck in pending/committing commit states
+// ReconcileCommitStates checks sessions stuck in pending/committing/conflicted commit states
 // and re-enqueues commit jobs if needed. This should be called on server startup.
 func (s *SessionService) ReconcileCommitStates(ctx context.Context) error {
 	// Query sessions with stuck commit states
-	statuses := []string{model.CommitStatusPending, model.CommitStatusCommitting}
+	statuses := []string{model.CommitStatusPending, model.CommitStatusCommitting, model.CommitStatusConflicted}
 	sessions, err := s.store.ListSessionsByCommitStatuses(ctx, statuses)
 	if err != nil {
 		return fmt.Errorf("failed to list sessions with commit states: %w", err)
@@ -360,7 +491,9 @@ func (s *SessionService) ReconcileCommitStates(ctx context.Context) error {
 	var enqueuedCount int
 	for _, sess := range sessions {
 		// Check if active job already exists
-		hasJob, err := s.store.HasActiveJobForResource(ctx, jobs.ResourceTypeWorkspace, sess.WorkspaceID)
+		branch := commitJobBranch(ctx, s.gitService, sess)
+		resourceType, resourceID := jobs.CommitResourceKey(sess.WorkspaceID, branch)
+		hasJob, err := s.store.HasActiveJobForResource(ctx, resourceType, resourceID)
 		if err != nil {
 			log.Printf("Failed to check job for session %s: %v", sess.ID, err)
 			continue
@@ -377,6 +510,7 @@ func (s *SessionService) ReconcileCommitStates(ctx context.Context) error {
 			ProjectID:   sess.ProjectID,
 			SessionID:   sess.ID,
 			WorkspaceID: sess.WorkspaceID,
+			Branch:      branch,
 		}
 
 		if s.jobEnqueuer != nil {
@@ -399,6 +533,9 @@ func (s *SessionService) ReconcileCommitStates(ctx context.Context) error {
 // This is called by the SessionDeleteExecutor job handler.
 func (s *SessionService) PerformDeletion(ctx context.Context, projectID, sessionID string) error {
 	// Step 1: Destroy sandbox and associated volumes (idempotent - handles not found)
+	if s.sandboxService != nil {
+		s.sandboxService.forgetProxySecrets(sessionID)
+	}
 	if s.sandboxProvider != nil {
 		if err := s.sandboxProvider.Remove(ctx, sessionID, sandbox.RemoveVolumes()); err != nil {
 			if !errors.Is(err, sandbox.ErrNotFound) {
@@ -461,6 +598,11 @@ func (s *SessionService) mapSession(sess *model.Session) *Session {
 		appliedCommit = *sess.AppliedCommit
 	}
 
+	targetBranch := ""
+	if sess.TargetBranch != nil {
+		targetBranch = *sess.TargetBranch
+	}
+
 	workspacePath := ""
 	if sess.WorkspacePath != nil {
 		workspacePath = *sess.WorkspacePath
@@ -486,6 +628,40 @@ func (s *SessionService) mapSession(sess *model.Session) *Session {
 		mode = *sess.Mode
 	}
 
+	forkedFrom := ""
+	if sess.ForkedFrom != nil {
+		forkedFrom = *sess.ForkedFrom
+	}
+
+	forkMessageID := ""
+	if sess.ForkMessageID != nil {
+		forkMessageID = *sess.ForkMessageID
+	}
+
+	var commitConflicts *git.ConflictError
+	if sess.CommitConflicts != nil && *sess.CommitConflicts != "" {
+		commitConflicts = &git.ConflictError{}
+		if err := json.Unmarshal([]byte(*sess.CommitConflicts), commitConflicts); err != nil {
+			log.Printf("Failed to decode commit conflicts of session %s: %v", sess.ID, err)
+			commitConflicts = nil
+		}
+	}
+
+	var pullRequestBranch, pullRequestURL, pullRequestError string
+	var pullRequestNumber int
+	if sess.PullRequestBranch != nil {
+		pullRequestBranch = *sess.PullRequestBranch
+	}
+	if sess.PullRequestNumber != nil {
+		pullRequestNumber = *sess.PullRequestNumber
+	}
+	if sess.PullRequestURL != nil {
+		pullRequestURL = *sess.PullRequestURL
+	}
+	if sess.PullRequestError != nil {
+		pullRequestError = *sess.PullRequestError
+	}
+
 	timestamp := sess.UpdatedAt.Format(time.RFC3339)
 	if sess.UpdatedAt.IsZero() {
 		timestamp = time.Now().Format(time.RFC3339)
@@ -503,6 +679,7 @@ func (s *SessionService) mapSession(sess *model.Session) *Session {
 		CommitError:     commitError,
 		BaseCommit:      baseCommit,
 		AppliedCommit:   appliedCommit,
+		TargetBranch:    targetBranch,
 		ErrorMessage:    errorMessage,
 		Files:           []FileNode{},
 		WorkspaceID:     sess.WorkspaceID,
@@ -512,6 +689,16 @@ func (s *SessionService) mapSession(sess *model.Session) *Session {
 		Mode:            mode,
 		WorkspacePath:   workspacePath,
 		WorkspaceCommit: workspaceCommit,
+		ForkedFrom:      forkedFrom,
+		ForkMessageID:   forkMessageID,
+
+		CommitConflicts: commitConflicts,
+		ConflictRetries: sess.ConflictRetries,
+
+		PullRequestBranch: pullRequestBranch,
+		PullRequestNumber: pullRequestNumber,
+		PullRequestURL:    pullRequestURL,
+		PullRequestError:  pullRequestError,
 	}
 }
 
@@ -722,9 +909,13 @@ func (s *SessionService) initializeSync(
 				"discobot.workspace.id": workspace.ID,
 				"discobot.project.id":   projectID,
 			},
-			WorkspacePath:   workspacePath,
-			WorkspaceSource: workspace.Path, // Original source (git URL or local path) for WORKSPACE_PATH env var
-			WorkspaceCommit: workspaceCommit,
+			WorkspacePath:       workspacePath,
+			WorkspaceSource:     workspace.Path, // Original source (git URL or local path) for WORKSPACE_PATH env var
+			WorkspaceCommit:     workspaceCommit,
+			WorkspaceSubmodules: workspace.Submodules,
+			WorkspaceLFS:        workspace.LFS,
+			ForkedFrom:          session.ForkedFrom,
+			ForkMessageID:       session.ForkMessageID,
 		}
 
 		_, err := s.sandboxProvider.Create(ctx, sessionID, opts)
@@ -742,6 +933,16 @@ func (s *SessionService) initializeSync(
 		}
 	}
 
+	// With credential placeholders, the sandbox proxy holds the real
+	// credentials; the agent cannot authenticate until it has them
+	if s.sandboxService != nil {
+		if err := s.sandboxService.PushCredentialSecrets(ctx, sessionID); err != nil {
+			log.Printf("Failed to give credentials to the proxy of session %s: %v", sessionID, err)
+			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("failed to give credentials to the sandbox proxy: "+err.Error()))
+			return fmt.Errorf("failed to give credentials to the sandbox proxy: %w", err)
+		}
+	}
+
 	// Success! Update status to running
 	s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusReady, nil)
 	log.Printf("Session %s initialized successfully", sessionID)
@@ -808,17 +1009,19 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 		}
 	}()
 
-	// Get current git status and set up session for this commit
-	gitStatus, err := s.gitService.Status(ctx, sess.WorkspaceID)
+	// Get the commit to apply on top of and set up session for this commit
+	baseCommit, _, err := s.commitTarget(ctx, sess)
 	if err != nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to get workspace status: %v", err))
 		return nil
 	}
 
 	sess.CommitStatus = model.CommitStatusPending
-	sess.BaseCommit = ptrString(gitStatus.Commit)
+	sess.BaseCommit = ptrString(baseCommit)
 	sess.AppliedCommit = nil
 	sess.CommitError = nil
+	sess.CommitConflicts = nil
+	sess.ConflictRetries = 0
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session for commit: %w", err)
 	}
@@ -876,22 +1079,23 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 	return nil
 }
 
-// syncBaseCommit checks if the workspace commit has changed and updates baseCommit.
+// syncBaseCommit checks if the workspace (or target branch) commit has changed
+// and updates baseCommit.
 // If patches are already available from the agent, it applies them directly.
 func (s *SessionService) syncBaseCommit(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session) error {
-	gitStatus, err := s.gitService.Status(ctx, sess.WorkspaceID)
+	baseCommit, _, err := s.commitTarget(ctx, sess)
 	if err != nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to get workspace status: %v", err))
 		return nil
 	}
 
 	// No change - nothing to do
-	if gitStatus.Commit == *sess.BaseCommit {
+	if baseCommit == *sess.BaseCommit {
 		return nil
 	}
 
-	log.Printf("Session %s: workspace commit changed from %s to %s, updating baseCommit", sess.ID, *sess.BaseCommit, gitStatus.Commit)
-	sess.BaseCommit = ptrString(gitStatus.Commit)
+	log.Printf("Session %s: workspace commit changed from %s to %s, updating baseCommit", sess.ID, *sess.BaseCommit, baseCommit)
+	sess.BaseCommit = ptrString(baseCommit)
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session baseCommit: %w", err)
 	}
@@ -931,15 +1135,41 @@ func (s *SessionService) tryApplyExistingPatches(ctx context.Context, projectID
 
 // sendCommitPrompt sends the /discobot-commit command to the agent.
 func (s *SessionService) sendCommitPrompt(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session) error {
+	log.Printf("Session %s: sending /discobot-commit %s to agent", sess.ID, *sess.BaseCommit)
+
+	commitMessage := fmt.Sprintf("/discobot-commit %s", *sess.BaseCommit)
+	streamCh := s.sendAgentMessage(ctx, projectID, workspace, sess, sess.ID+"-commit", commitMessage)
+	if streamCh == nil {
+		return nil
+	}
+
+	// Transition to committing now that the agent is actively working
+	sess.CommitStatus = model.CommitStatusCommitting
+	if err := s.store.UpdateSession(ctx, sess); err != nil {
+		return fmt.Errorf("failed to update session status: %w", err)
+	}
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting)
+
+	// Drain the stream until complete
+	for line := range streamCh {
+		if line.Done {
+			break
+		}
+	}
+
+	log.Printf("Session %s: /discobot-commit message completed", sess.ID)
+	return nil
+}
+
+// sendAgentMessage sends a user message to the agent on behalf of the commit
+// flow. Returns nil if it could not be sent, after marking the commit failed.
+func (s *SessionService) sendAgentMessage(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, msgID, text string) <-chan SSELine {
 	if s.sandboxService == nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, "Sandbox service not available")
 		return nil
 	}
 
-	log.Printf("Session %s: sending /discobot-commit %s to agent", sess.ID, *sess.BaseCommit)
-
-	commitMessage := fmt.Sprintf("/discobot-commit %s", *sess.BaseCommit)
-	messages, err := buildCommitMessage(sess.ID+"-commit", commitMessage)
+	messages, err := buildUserMessage(msgID, text)
 	if err != nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to build commit message: %v", err))
 		return nil
@@ -962,23 +1192,7 @@ func (s *SessionService) sendCommitPrompt(ctx context.Context, projectID string,
 		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to send commit message to agent: %v", err))
 		return nil
 	}
-
-	// Transition to committing now that the agent is actively working
-	sess.CommitStatus = model.CommitStatusCommitting
-	if err := s.store.UpdateSession(ctx, sess); err != nil {
-		return fmt.Errorf("failed to update session status: %w", err)
-	}
-	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting)
-
-	// Drain the stream until complete
-	for line := range streamCh {
-		if line.Done {
-			break
-		}
-	}
-
-	log.Printf("Session %s: /discobot-commit message completed", sess.ID)
-	return nil
+	return streamCh
 }
 
 // fetchAndApplyPatches fetches patches from the agent and applies them to the workspace.
@@ -1021,13 +1235,29 @@ func (s *SessionService) applyPatches(ctx context.Context, projectID string, wor
 		s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting)
 	}
 
-	finalCommit, err := s.gitService.ApplyPatches(ctx, sess.WorkspaceID, []byte(patches))
+	_, branch, err := s.commitTarget(ctx, sess)
+	if err != nil {
+		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to get workspace status: %v", err))
+		return nil
+	}
+
+	var finalCommit string
+	if branch != "" {
+		finalCommit, err = s.gitService.ApplyPatchesToBranch(ctx, sess.WorkspaceID, branch, []byte(patches))
+	} else {
+		finalCommit, err = s.gitService.ApplyPatchesThreeWay(ctx, sess.WorkspaceID, []byte(patches))
+	}
+	var conflictErr *git.ConflictError
+	if errors.As(err, &conflictErr) {
+		return s.resolveConflicts(ctx, projectID, workspace, sess, conflictErr)
+	}
 	if err != nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to apply patches to workspace: %v", err))
 		return nil
 	}
 
 	sess.AppliedCommit = ptrString(finalCommit)
+	sess.CommitConflicts = nil
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session applied commit: %w", err)
 	}
@@ -1036,6 +1266,128 @@ func (s *SessionService) applyPatches(ctx context.Context, projectID string, wor
 	return nil
 }
 
+// resolveConflicts asks the agent to rebase its commits onto the workspace's
+// current commit and resolve the conflicts, then fetches and applies the
+// rebased patches. After maxConflictRetries attempts the commit fails, with
+// the last conflicts kept on the session.
+func (s *SessionService) resolveConflicts(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, conflictErr *git.ConflictError) error {
+	if data, err := json.Marshal(conflictErr); err == nil {
+		sess.CommitConflicts = ptrString(string(data))
+	}
+
+	if sess.ConflictRetries >= maxConflictRetries {
+		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Patches still conflict with the workspace after %d rebase attempts: %v", sess.ConflictRetries, conflictErr))
+		return nil
+	}
+
+	baseCommit, _, err := s.commitTarget(ctx, sess)
+	if err != nil {
+		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to get workspace status: %v", err))
+		return nil
+	}
+
+	sess.ConflictRetries++
+	sess.BaseCommit = ptrString(baseCommit)
+	sess.CommitStatus = model.CommitStatusConflicted
+	if err := s.store.UpdateSession(ctx, sess); err != nil {
+		return fmt.Errorf("failed to update session conflicts: %w", err)
+	}
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusConflicted)
+
+	log.Printf("Session %s: %v, asking agent to rebase onto %s (attempt %d of %d)", sess.ID, conflictErr, baseCommit, sess.ConflictRetries, maxConflictRetries)
+
+	msgID := fmt.Sprintf("%s-conflict-%d", sess.ID, sess.ConflictRetries)
+	streamCh := s.sendAgentMessage(ctx, projectID, workspace, sess, msgID, buildConflictPrompt(baseCommit, conflictErr))
+	if streamCh == nil {
+		return nil
+	}
+	for line := range streamCh {
+		if line.Done {
+			break
+		}
+	}
+
+	return s.fetchAndApplyPatches(ctx, projectID, workspace, sess)
+}
+
+// buildConflictPrompt describes the conflicts to the agent and asks it to
+// rebase its commits onto the base commit.
+func buildConflictPrompt(baseCommit string, conflictErr *git.ConflictError) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your commits could not be applied to the workspace: %q conflicts with the changes in %s.\n\n", conflictErr.Patch, baseCommit)
+	b.WriteString("Conflicts:\n")
+	for _, c := range conflictErr.Conflicts {
+		fmt.Fprintf(&b, "\n%s\n", c.Path)
+		for _, h := range c.Hunks {
+			fmt.Fprintf(&b, "  at line %d, workspace:\n%s  yours:\n%s", h.StartLine, indentLines(h.Ours), indentLines(h.Theirs))
+		}
+	}
+	fmt.Fprintf(&b, "\nRun `git pull -r origin %s`, resolve the conflicts keeping the intent of both sides, continue the rebase and make sure all changes are committed.", baseCommit)
+	return b.String()
+}
+
+// indentLines indents each line of s by four spaces.
+func indentLines(s string) string {
+	if s == "" {
+		return "    (empty)\n"
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			b.WriteString("    " + line)
+		}
+	}
+	if !strings.HasSuffix(s, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// commitTarget returns the commit the session's patches are applied on top of
+// and, when they are applied to a branch in a worktree, that branch. A target
+// branch that does not exist yet starts at the workspace HEAD.
+func (s *SessionService) commitTarget(ctx context.Context, sess *model.Session) (baseCommit, branch string, err error) {
+	status, err := s.gitService.Status(ctx, sess.WorkspaceID)
+	if err != nil {
+		return "", "", err
+	}
+
+	branch = worktreeBranch(sess, status.Branch)
+	if branch == "" {
+		return status.Commit, "", nil
+	}
+	commit, err := s.gitService.ResolveBranch(ctx, sess.WorkspaceID, branch)
+	if errors.Is(err, git.ErrNotFound) {
+		return status.Commit, branch, nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return commit, branch, nil
+}
+
+// worktreeBranch returns the session's target branch, or "" if it has none or
+// the branch is checked out in the workspace, so commits go to the checkout.
+func worktreeBranch(sess *model.Session, checkedOut string) string {
+	if sess.TargetBranch == nil || *sess.TargetBranch == "" || *sess.TargetBranch == checkedOut {
+		return ""
+	}
+	return *sess.TargetBranch
+}
+
+// commitJobBranch returns the branch the session's commit jobs are keyed on.
+func commitJobBranch(ctx context.Context, gitService *GitService, sess *model.Session) string {
+	if sess.TargetBranch == nil || *sess.TargetBranch == "" {
+		return ""
+	}
+	if gitService != nil {
+		if status, err := gitService.Status(ctx, sess.WorkspaceID); err == nil {
+			return worktreeBranch(sess, status.Branch)
+		}
+	}
+	return *sess.TargetBranch
+}
+
 // setCommitFailed sets the commit status to failed with an error message.
 func (s *SessionService) setCommitFailed(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, errorMsg string) {
 	log.Printf("Workspace %s commit failed (via session %s): %s", workspace.ID, sess.ID, errorMsg)
@@ -1050,9 +1402,10 @@ func (s *SessionService) setCommitFailed(ctx context.Context, projectID string,
 	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusFailed)
 }
 
-// buildCommitMessage creates a UIMessage array for the /discobot-commit command.
+// buildUserMessage creates a UIMessage array holding one user message with
+// text, such as the /discobot-commit command or a scheduled prompt.
 // Returns json.RawMessage that can be passed to SendMessages.
-func buildCommitMessage(msgID, text string) (json.RawMessage, error) {
+func buildUserMessage(msgID, text string) (json.RawMessage, error) {
 	// Build the text part
 	part := map[string]interface{}{
 		"type": "text",
@@ -16,6 +16,8 @@ import (
 const (
 	pollInterval = 5 * time.Second // Check running sessions every 5 seconds
 	pollTimeout  = 3 * time.Second // Timeout for individual status checks
+
+	checkpointTimeout = 2 * time.Minute // Timeout for checkpointing a finished turn
 )
 
 // errSessionNotRunning is returned by checkSession when it successfully determines
@@ -190,6 +192,17 @@ func (p *SessionStatusPoller) checkSession(ctx context.Context, session *model.S
 	if !status.IsRunning {
 		logger.Info("session marked running but completion not active, updating to ready",
 			"completion_id", status.CompletionID)
+		// The completion ended without the chat stream seeing it (e.g. the
+		// client disconnected), so its transcript and checkpoint were not
+		// recorded yet
+		checkpointCtx, cancelCheckpoint := context.WithTimeout(ctx, checkpointTimeout)
+		if err := p.sandboxSvc.MirrorTranscript(checkpointCtx, session.ID); err != nil {
+			logger.Warn("failed to store transcript", "error", err)
+		}
+		if _, err := p.sandboxSvc.RecordCheckpoint(checkpointCtx, session.ID); err != nil {
+			logger.Warn("failed to record checkpoint", "error", err)
+		}
+		cancelCheckpoint()
 		if err := p.updateSessionStatus(ctx, session, model.SessionStatusReady, ""); err != nil {
 			return err
 		}
@@ -130,6 +130,7 @@ func TestSessionIDMaxLength(t *testing.T) {
 func TestMapSessionFieldCoverage(t *testing.T) {
 	// Create a fully populated model.Session with non-nil values
 	strPtr := func(s string) *string { return &s }
+	prNumber := 7
 
 	modelSession := &model.Session{
 		ID:              "test-id",
@@ -144,12 +145,23 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 		CommitError:     strPtr("commit error"),
 		BaseCommit:      strPtr("base123"),
 		AppliedCommit:   strPtr("applied456"),
+		TargetBranch:    strPtr("feature"),
 		ErrorMessage:    strPtr("error message"),
 		WorkspacePath:   strPtr("/path/to/workspace"),
 		WorkspaceCommit: strPtr("commit789"),
 		Model:           strPtr("claude-opus-4-6"),
 		Reasoning:       strPtr("enabled"),
 		Mode:            strPtr("plan"),
+		ForkedFrom:      strPtr("source-id"),
+		ForkMessageID:   strPtr("msg-1"),
+
+		CommitConflicts: strPtr(`{"patch":"Edit README","conflicts":[{"path":"README.md"}]}`),
+		ConflictRetries: 2,
+
+		PullRequestBranch: strPtr("discobot/test-name-test-id"),
+		PullRequestNumber: &prNumber,
+		PullRequestURL:    strPtr("https://github.com/o/n/pull/7"),
+		PullRequestError:  strPtr("push failed"),
 	}
 
 	// Create a mock SessionService (nil is fine since mapSession doesn't use it)
@@ -173,12 +185,22 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 		"CommitError":     "CommitError",
 		"BaseCommit":      "BaseCommit",
 		"AppliedCommit":   "AppliedCommit",
+		"TargetBranch":    "TargetBranch",
 		"ErrorMessage":    "ErrorMessage",
 		"WorkspacePath":   "WorkspacePath",
 		"WorkspaceCommit": "WorkspaceCommit",
 		"Model":           "Model",
 		"Reasoning":       "Reasoning",
 		"Mode":            "Mode",
+		"ForkedFrom":      "ForkedFrom",
+		"ForkMessageID":   "ForkMessageID",
+		"CommitConflicts": "CommitConflicts",
+		"ConflictRetries": "ConflictRetries",
+
+		"PullRequestBranch": "PullRequestBranch",
+		"PullRequestNumber": "PullRequestNumber",
+		"PullRequestURL":    "PullRequestURL",
+		"PullRequestError":  "PullRequestError",
 		// Excluded fields (not part of API response):
 		// - CreatedAt, UpdatedAt: mapped to Timestamp
 		// - Project, Workspace, Agent, Messages: relationships, not serialized
@@ -249,6 +271,15 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 	if result.DisplayName != "Test Display" {
 		t.Errorf("DisplayName = %q, want %q", result.DisplayName, "Test Display")
 	}
+	if result.CommitConflicts == nil || result.CommitConflicts.Patch != "Edit README" || len(result.CommitConflicts.Conflicts) != 1 {
+		t.Errorf("CommitConflicts = %+v, want the decoded conflict", result.CommitConflicts)
+	}
+	if result.ConflictRetries != 2 {
+		t.Errorf("ConflictRetries = %d, want 2", result.ConflictRetries)
+	}
+	if result.PullRequestNumber != 7 {
+		t.Errorf("PullRequestNumber = %d, want 7", result.PullRequestNumber)
+	}
 	if result.AgentID != "test-agent" {
 		t.Errorf("AgentID = %q, want %q", result.AgentID, "test-agent")
 	}
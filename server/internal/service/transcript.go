@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// MirrorTranscript copies the session's user and assistant messages from the
+// sandbox into the messages table, so the conversation outlives the sandbox.
+// It is called when a chat completion ends.
+func (s *SandboxService) MirrorTranscript(ctx context.Context, sessionID string) error {
+	client, err := s.GetClient(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	messages, err := client.GetMessages(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	rows := make([]*model.Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		parts := msg.Parts
+		if len(parts) == 0 || string(parts) == "null" {
+			parts = json.RawMessage("[]")
+		}
+		rows = append(rows, &model.Message{MessageID: msg.ID, Role: msg.Role, Parts: parts})
+	}
+	return s.store.UpsertSessionMessages(ctx, sessionID, rows)
+}
+
+// MirrorTranscript stores the session's completed messages server-side.
+func (c *ChatService) MirrorTranscript(ctx context.Context, projectID, sessionID string) error {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return err
+	}
+	if c.sandboxService == nil {
+		return fmt.Errorf("sandbox provider not available")
+	}
+	return c.sandboxService.MirrorTranscript(ctx, sessionID)
+}
+
+// storedMessages returns the session's messages from the database as
+// UIMessages. mirrored reports whether any of them came from the sandbox
+// transcript rather than only the initial message stored at creation. The
+// transcript holds that message too, so it is left out once mirrored.
+func (c *ChatService) storedMessages(ctx context.Context, sessionID string) (messages []sandboxapi.UIMessage, mirrored bool, err error) {
+	rows, err := c.store.ListMessagesBySession(ctx, sessionID)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, row := range rows {
+		if row.MessageID != "" {
+			mirrored = true
+			break
+		}
+	}
+
+	messages = make([]sandboxapi.UIMessage, 0, len(rows))
+	for _, row := range rows {
+		id := row.MessageID
+		if id == "" {
+			if mirrored {
+				continue
+			}
+			id = row.ID
+		}
+		messages = append(messages, sandboxapi.UIMessage{
+			ID:        id,
+			Role:      row.Role,
+			Parts:     row.Parts,
+			CreatedAt: row.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return messages, mirrored, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// serveHistory makes the session's sandbox return history from GET /chat.
+func serveHistory(env *testEnv, history []sandboxapi.UIMessage) {
+	env.mockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/chat") && r.Method == "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(sandboxapi.GetMessagesResponse{Messages: history})
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+func TestMirrorTranscript(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	if err := chatSvc.MirrorTranscript(ctx, source.ProjectID, source.ID); err != nil {
+		t.Fatalf("MirrorTranscript: %v", err)
+	}
+
+	// The next turn updates the last message and appends two more; the system
+	// message is not stored
+	history := testHistory()
+	history[3].Parts = json.RawMessage(`[{"type":"text","text":"two, edited"}]`)
+	history = append(history,
+		sandboxapi.UIMessage{ID: "sys-1", Role: "system", Parts: json.RawMessage(`[]`)},
+		sandboxapi.UIMessage{ID: "user-3", Role: "user", Parts: json.RawMessage(`[{"type":"text","text":"third"}]`)},
+		sandboxapi.UIMessage{ID: "asst-3", Role: "assistant"},
+	)
+	serveHistory(env, history)
+	if err := chatSvc.MirrorTranscript(ctx, source.ProjectID, source.ID); err != nil {
+		t.Fatalf("MirrorTranscript: %v", err)
+	}
+
+	rows, err := env.store.ListMessagesBySession(ctx, source.ID)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	var ids []string
+	for _, row := range rows {
+		ids = append(ids, row.MessageID)
+	}
+	if got := strings.Join(ids, ","); got != "user-1,asst-1,user-2,asst-2,user-3,asst-3" {
+		t.Fatalf("stored messages = %s", got)
+	}
+	if string(rows[3].Parts) != `[{"type":"text","text":"two, edited"}]` || string(rows[5].Parts) != `[]` {
+		t.Errorf("unexpected parts: %s, %s", rows[3].Parts, rows[5].Parts)
+	}
+
+	// A sandbox that lost its history does not erase the stored transcript
+	serveHistory(env, nil)
+	if err := chatSvc.MirrorTranscript(ctx, source.ProjectID, source.ID); err != nil {
+		t.Fatalf("MirrorTranscript: %v", err)
+	}
+	if rows, _ := env.store.ListMessagesBySession(ctx, source.ID); len(rows) != 6 {
+		t.Errorf("expected 6 stored messages, got %d", len(rows))
+	}
+}
+
+func TestGetMessages_StoppedSessionUsesStoredTranscript(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	if err := chatSvc.MirrorTranscript(ctx, source.ProjectID, source.ID); err != nil {
+		t.Fatalf("MirrorTranscript: %v", err)
+	}
+	if err := env.mockSandbox.Stop(ctx, source.ID, 0); err != nil {
+		t.Fatalf("Failed to stop sandbox: %v", err)
+	}
+	if err := env.store.UpdateSessionStatus(ctx, source.ID, model.SessionStatusStopped, nil); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+
+	messages, err := chatSvc.GetMessages(ctx, source.ProjectID, source.ID)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 4 || messages[0].ID != "user-1" || messages[3].ID != "asst-2" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+	if sb, _ := env.mockSandbox.Get(ctx, source.ID); sb.Status == sandbox.StatusRunning {
+		t.Error("expected the sandbox to stay stopped")
+	}
+}
+
+func TestGetMessages_FallsBackWhenSandboxFails(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	// Without a stored transcript the sandbox error is returned. Not a 5xx,
+	// which the client would retry with backoff.
+	failing := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "conversation not found", http.StatusNotFound)
+	})
+	env.mockSandbox.HTTPHandler = failing
+	if _, err := chatSvc.GetMessages(ctx, source.ProjectID, source.ID); err == nil {
+		t.Fatal("expected error without a stored transcript")
+	}
+
+	serveHistory(env, testHistory())
+	if err := chatSvc.MirrorTranscript(ctx, source.ProjectID, source.ID); err != nil {
+		t.Fatalf("MirrorTranscript: %v", err)
+	}
+	env.mockSandbox.HTTPHandler = failing
+
+	messages, err := chatSvc.GetMessages(ctx, source.ProjectID, source.ID)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 4 || messages[1].ID != "asst-1" || messages[1].Role != "assistant" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+}
+
+func TestGetMessages_MirroredInitialMessageNotDuplicated(t *testing.T) {
+	env, chatSvc, _, source := newForkTestEnv(t, testHistory())
+	ctx := context.Background()
+
+	// The first prompt is stored at creation, before the sandbox gave it an ID
+	sess, err := chatSvc.sessionService.CreateSession(ctx, source.ProjectID, source.WorkspaceID, "first", *source.AgentID, "first")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := env.mockSandbox.Create(ctx, sess.ID, sandbox.CreateOptions{SharedSecret: "secret"}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(ctx, sess.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+	if err := env.store.UpdateSessionStatus(ctx, sess.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+
+	if err := chatSvc.MirrorTranscript(ctx, source.ProjectID, sess.ID); err != nil {
+		t.Fatalf("MirrorTranscript: %v", err)
+	}
+	if err := env.mockSandbox.Stop(ctx, sess.ID, 0); err != nil {
+		t.Fatalf("Failed to stop sandbox: %v", err)
+	}
+	if err := env.store.UpdateSessionStatus(ctx, sess.ID, model.SessionStatusStopped, nil); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+
+	messages, err := chatSvc.GetMessages(ctx, source.ProjectID, sess.ID)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	var ids []string
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	if got := strings.Join(ids, ","); got != "user-1,asst-1,user-2,asst-2" {
+		t.Errorf("messages = %s", got)
+	}
+}
@@ -0,0 +1,637 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/encryption"
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Webhook errors
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidWebhook          = errors.New("invalid webhook")
+)
+
+// Webhook request headers
+const (
+	WebhookEventHeader     = "X-Discobot-Event"
+	WebhookDeliveryHeader  = "X-Discobot-Delivery"
+	WebhookSignatureHeader = "X-Discobot-Signature-256"
+)
+
+const (
+	// webhookTimeout bounds a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+	// webhookResponseLimit caps the response body kept in the delivery log.
+	webhookResponseLimit = 4096
+	// webhookDeliveryHistoryLimit caps the deliveries returned by ListDeliveries.
+	webhookDeliveryHistoryLimit = 100
+)
+
+// WebhookEventTypes are the event types webhooks can subscribe to.
+var WebhookEventTypes = []events.EventType{
+	events.EventTypeSessionUpdated,
+	events.EventTypeWorkspaceUpdated,
+	events.EventTypeJobCompleted,
+	events.EventTypeJobUpdated,
+	events.EventTypeSessionPullRequest,
+}
+
+// Webhook represents a webhook subscription (for API responses)
+type Webhook struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"eventTypes"` // Empty means all event types
+	Enabled    bool      `json:"enabled"`
+	Secret     string    `json:"secret,omitempty"` // Only returned when the secret is set
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// WebhookDelivery represents one delivery of an event (for API responses)
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	ResponseBody   string          `json:"responseBody,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMs     int64           `json:"durationMs,omitempty"`
+	RedeliveryOf   string          `json:"redeliveryOf,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"` // Only included for a single delivery
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+// WebhookRequest contains the fields of a webhook. On update, nil fields are
+// left unchanged. A secret is generated on create if none is given.
+type WebhookRequest struct {
+	URL        *string   `json:"url"`
+	Secret     *string   `json:"secret"`
+	EventTypes *[]string `json:"eventTypes"`
+	Enabled    *bool     `json:"enabled"`
+}
+
+// WebhookPayload is the JSON body sent to webhooks.
+type WebhookPayload struct {
+	ID        string           `json:"id"` // Event ID
+	Type      events.EventType `json:"type"`
+	ProjectID string           `json:"projectId"`
+	Timestamp time.Time        `json:"timestamp"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+// WebhookService manages webhook subscriptions and delivers events to them.
+type WebhookService struct {
+	store       *store.Store
+	encryptor   *encryption.Encryptor
+	jobEnqueuer JobEnqueuer
+	client      *http.Client
+
+	allowPrivateNetworks bool
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(s *store.Store, cfg *config.Config, jobEnqueuer JobEnqueuer) (*WebhookService, error) {
+	enc, err := encryption.NewEncryptor(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &WebhookService{
+		store:                s,
+		encryptor:            enc,
+		jobEnqueuer:          jobEnqueuer,
+		allowPrivateNetworks: cfg.WebhookAllowPrivateNetworks,
+	}
+	// The receiver is checked when it is connected to, after DNS resolution
+	// and on redirects, so a host cannot be repointed to a blocked address
+	// after the webhook was validated. Deliveries go direct rather than
+	// through an environment proxy, so the check sees the receiver itself.
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: svc.checkWebhookDial}
+	svc.client = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: webhookTimeout},
+	}
+	return svc, nil
+}
+
+// ListWebhooks returns all webhooks for a project
+func (s *WebhookService) ListWebhooks(ctx context.Context, projectID string) ([]*Webhook, error) {
+	dbWebhooks, err := s.store.ListWebhooksByProject(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	result := make([]*Webhook, len(dbWebhooks))
+	for i, w := range dbWebhooks {
+		result[i] = mapWebhook(w)
+	}
+	return result, nil
+}
+
+// GetWebhook returns a webhook of the project
+func (s *WebhookService) GetWebhook(ctx context.Context, projectID, webhookID string) (*Webhook, error) {
+	w, err := s.getWebhook(ctx, projectID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return mapWebhook(w), nil
+}
+
+// CreateWebhook creates an enabled webhook. The response includes the
+// secret, which is not returned again.
+func (s *WebhookService) CreateWebhook(ctx context.Context, projectID string, req WebhookRequest) (*Webhook, error) {
+	if req.Secret == nil || *req.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		req.Secret = &secret
+	}
+
+	w := &model.Webhook{ProjectID: projectID, Enabled: true}
+	if err := s.applyWebhookRequest(ctx, w, req); err != nil {
+		return nil, err
+	}
+	if err := s.store.CreateWebhook(ctx, w); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	result := mapWebhook(w)
+	result.Secret = *req.Secret
+	return result, nil
+}
+
+// UpdateWebhook updates the fields set in req.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, projectID, webhookID string, req WebhookRequest) (*Webhook, error) {
+	w, err := s.getWebhook(ctx, projectID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Secret != nil && *req.Secret == "" {
+		return nil, fmt.Errorf("%w: secret must not be empty", ErrInvalidWebhook)
+	}
+
+	if err := s.applyWebhookRequest(ctx, w, req); err != nil {
+		return nil, err
+	}
+	if err := s.store.UpdateWebhook(ctx, w); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	result := mapWebhook(w)
+	if req.Secret != nil {
+		result.Secret = *req.Secret
+	}
+	return result, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery log.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, projectID, webhookID string) error {
+	if _, err := s.getWebhook(ctx, projectID, webhookID); err != nil {
+		return err
+	}
+	if err := s.store.DeleteWebhook(ctx, webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the webhook's most recent deliveries, newest first.
+func (s *WebhookService) ListDeliveries(ctx context.Context, projectID, webhookID string) ([]*WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, projectID, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.store.ListWebhookDeliveries(ctx, webhookID, webhookDeliveryHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	result := make([]*WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		result[i] = mapWebhookDelivery(d)
+	}
+	return result, nil
+}
+
+// GetDelivery returns a delivery including its request payload.
+func (s *WebhookService) GetDelivery(ctx context.Context, projectID, webhookID, deliveryID string) (*WebhookDelivery, error) {
+	d, err := s.getDelivery(ctx, projectID, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	result := mapWebhookDelivery(d)
+	result.Payload = d.Payload
+	return result, nil
+}
+
+// Redeliver sends a delivery's payload again as a new delivery.
+func (s *WebhookService) Redeliver(ctx context.Context, projectID, webhookID, deliveryID string) (*WebhookDelivery, error) {
+	original, err := s.getDelivery(ctx, projectID, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &model.WebhookDelivery{
+		WebhookID:    original.WebhookID,
+		ProjectID:    original.ProjectID,
+		EventID:      original.EventID,
+		EventType:    original.EventType,
+		Payload:      original.Payload,
+		RedeliveryOf: &original.ID,
+	}
+	if err := s.enqueueDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	return mapWebhookDelivery(d), nil
+}
+
+// HandleEvent queues a delivery of the event to each of the project's
+// enabled webhooks subscribed to its type. It is registered as the event
+// broker's publish hook.
+func (s *WebhookService) HandleEvent(ctx context.Context, projectID string, event *events.Event) {
+	if isWebhookJobEvent(event) {
+		// Delivery jobs publish job events themselves; delivering those
+		// would queue deliveries forever
+		return
+	}
+
+	webhooks, err := s.store.ListEnabledWebhooksByProject(ctx, projectID)
+	if err != nil {
+		log.Printf("Failed to list webhooks for project %s: %v", projectID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		ID:        event.ID,
+		Type:      event.Type,
+		ProjectID: projectID,
+		Timestamp: event.Timestamp,
+		Data:      event.Data,
+	})
+	if err != nil {
+		log.Printf("Failed to marshal webhook payload for event %s: %v", event.ID, err)
+		return
+	}
+
+	for _, w := range webhooks {
+		if !webhookSubscribed(w, event.Type) {
+			continue
+		}
+		d := &model.WebhookDelivery{
+			WebhookID: w.ID,
+			ProjectID: projectID,
+			EventID:   event.ID,
+			EventType: string(event.Type),
+			Payload:   payload,
+		}
+		if err := s.enqueueDelivery(ctx, d); err != nil {
+			log.Printf("Failed to queue delivery of event %s to webhook %s: %v", event.ID, w.ID, err)
+		}
+	}
+}
+
+// Deliver sends a delivery's payload to its webhook and records the result.
+// It returns an error when the attempt failed so the job queue retries it;
+// finalAttempt marks the delivery failed instead of pending.
+// This is called by the dispatcher when processing a webhook_delivery job.
+func (s *WebhookService) Deliver(ctx context.Context, deliveryID string, finalAttempt bool) error {
+	d, err := s.store.GetWebhookDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return fmt.Errorf("webhook delivery not found: %w", err)
+	}
+	if d.Status != model.WebhookDeliveryStatusPending {
+		return nil
+	}
+
+	w, err := s.store.GetWebhookByID(ctx, d.WebhookID)
+	if err != nil {
+		// Webhook deleted since the delivery was queued; nothing to retry
+		s.finishDelivery(ctx, d, model.WebhookDeliveryStatusFailed, "webhook not found")
+		return nil
+	}
+
+	var secret string
+	if err := s.encryptor.DecryptJSON(w.EncryptedSecret, &secret); err != nil {
+		s.finishDelivery(ctx, d, model.WebhookDeliveryStatusFailed, "failed to decrypt webhook secret")
+		return nil
+	}
+
+	d.Attempts++
+	attemptErr := s.send(ctx, w.URL, secret, d)
+
+	if attemptErr == nil {
+		s.finishDelivery(ctx, d, model.WebhookDeliveryStatusSucceeded, "")
+		return nil
+	}
+	if finalAttempt {
+		s.finishDelivery(ctx, d, model.WebhookDeliveryStatusFailed, attemptErr.Error())
+		return attemptErr
+	}
+	errMsg := attemptErr.Error()
+	d.Error = &errMsg
+	if err := s.store.UpdateWebhookDelivery(ctx, d); err != nil {
+		log.Printf("Failed to update webhook delivery %s: %v", d.ID, err)
+	}
+	return attemptErr
+}
+
+// send performs one delivery attempt and records the response on d.
+func (s *WebhookService) send(ctx context.Context, targetURL, secret string, d *model.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Discobot-Webhook")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, d.Payload))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	duration := time.Since(start).Milliseconds()
+	d.DurationMs = &duration
+	if err != nil {
+		d.ResponseStatus = nil
+		d.ResponseBody = nil
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	status := resp.StatusCode
+	bodyStr := string(body)
+	d.ResponseStatus = &status
+	d.ResponseBody = &bodyStr
+
+	if status < 200 || status > 299 {
+		return fmt.Errorf("receiver returned status %d", status)
+	}
+	return nil
+}
+
+func (s *WebhookService) finishDelivery(ctx context.Context, d *model.WebhookDelivery, status, errMsg string) {
+	now := time.Now()
+	d.Status = status
+	d.DeliveredAt = &now
+	d.Error = nil
+	if errMsg != "" {
+		d.Error = &errMsg
+	}
+	if err := s.store.UpdateWebhookDelivery(ctx, d); err != nil {
+		log.Printf("Failed to update webhook delivery %s: %v", d.ID, err)
+	}
+}
+
+// enqueueDelivery records a pending delivery and enqueues the job sending it.
+func (s *WebhookService) enqueueDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	if err := s.store.CreateWebhookDelivery(ctx, d); err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	if err := s.jobEnqueuer.Enqueue(ctx, jobs.WebhookDeliveryPayload{
+		ProjectID:  d.ProjectID,
+		DeliveryID: d.ID,
+	}); err != nil {
+		s.finishDelivery(ctx, d, model.WebhookDeliveryStatusFailed, fmt.Sprintf("failed to enqueue delivery: %v", err))
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *WebhookService) getWebhook(ctx context.Context, projectID, webhookID string) (*model.Webhook, error) {
+	w, err := s.store.GetWebhookByID(ctx, webhookID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if w.ProjectID != projectID {
+		return nil, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+func (s *WebhookService) getDelivery(ctx context.Context, projectID, webhookID, deliveryID string) (*model.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, projectID, webhookID); err != nil {
+		return nil, err
+	}
+	d, err := s.store.GetWebhookDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	if d.WebhookID != webhookID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return d, nil
+}
+
+// applyWebhookRequest validates and applies the fields set in req.
+func (s *WebhookService) applyWebhookRequest(ctx context.Context, w *model.Webhook, req WebhookRequest) error {
+	if req.URL != nil {
+		u, err := url.Parse(*req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+		}
+		if err := s.checkWebhookHost(ctx, u.Hostname()); err != nil {
+			return err
+		}
+		w.URL = *req.URL
+	}
+	if w.URL == "" {
+		return fmt.Errorf("%w: url is required", ErrInvalidWebhook)
+	}
+
+	if req.EventTypes != nil {
+		for _, t := range *req.EventTypes {
+			if !slices.Contains(WebhookEventTypes, events.EventType(t)) {
+				return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, t)
+			}
+		}
+		w.EventTypes = strings.Join(*req.EventTypes, ",")
+	}
+
+	if req.Secret != nil {
+		encrypted, err := s.encryptor.EncryptJSON(*req.Secret)
+		if err != nil {
+			return ErrEncryptionFailed
+		}
+		w.EncryptedSecret = encrypted
+	}
+
+	if req.Enabled != nil {
+		w.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+// checkWebhookHost rejects webhook hosts that are or resolve to a blocked
+// address. Hosts that do not resolve are accepted; deliveries to them fail
+// until they do, and are checked when connecting either way.
+func (s *WebhookService) checkWebhookHost(ctx context.Context, host string) error {
+	if s.allowPrivateNetworks {
+		return nil
+	}
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host); err == nil {
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if blockedWebhookIP(ip) {
+			return fmt.Errorf("%w: url must not point to a loopback, link-local or private address", ErrInvalidWebhook)
+		}
+	}
+	return nil
+}
+
+// checkWebhookDial is the Control function of the delivery client's dialer.
+// It refuses connections to blocked addresses.
+func (s *WebhookService) checkWebhookDial(_, address string, _ syscall.RawConn) error {
+	if s.allowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedWebhookIP(ip) {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// blockedWebhookIP reports whether webhooks may not be delivered to ip:
+// loopback, link-local (such as cloud metadata services), private, shared
+// (carrier-grade NAT) and unspecified addresses are internal to the
+// server's network. IPv4-mapped IPv6 addresses are checked as IPv4.
+func blockedWebhookIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		// 0.0.0.0/8 ("this network") and 100.64.0.0/10 (shared address space)
+		if ip4[0] == 0 || ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+			return true
+		}
+	}
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified()
+}
+
+// SignWebhookPayload returns the signature header value for a payload:
+// "sha256=" followed by the hex HMAC-SHA256 of the body keyed with the secret.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// webhookSubscribed reports whether the webhook receives events of type t.
+func webhookSubscribed(w *model.Webhook, t events.EventType) bool {
+	if w.EventTypes == "" {
+		return slices.Contains(WebhookEventTypes, t)
+	}
+	return slices.Contains(strings.Split(w.EventTypes, ","), string(t))
+}
+
+// isWebhookJobEvent reports whether the event is about a webhook delivery job.
+func isWebhookJobEvent(event *events.Event) bool {
+	if event.Type != events.EventTypeJobUpdated && event.Type != events.EventTypeJobCompleted {
+		return false
+	}
+	var data struct {
+		JobType string `json:"jobType"`
+	}
+	return json.Unmarshal(event.Data, &data) == nil && data.JobType == string(jobs.JobTypeWebhookDelivery)
+}
+
+// mapWebhook maps a model.Webhook to a service.Webhook
+func mapWebhook(w *model.Webhook) *Webhook {
+	eventTypes := []string{}
+	if w.EventTypes != "" {
+		eventTypes = strings.Split(w.EventTypes, ",")
+	}
+	return &Webhook{
+		ID:         w.ID,
+		URL:        w.URL,
+		EventTypes: eventTypes,
+		Enabled:    w.Enabled,
+		CreatedAt:  w.CreatedAt,
+		UpdatedAt:  w.UpdatedAt,
+	}
+}
+
+// mapWebhookDelivery maps a model.WebhookDelivery to a service.WebhookDelivery
+func mapWebhookDelivery(d *model.WebhookDelivery) *WebhookDelivery {
+	result := &WebhookDelivery{
+		ID:          d.ID,
+		WebhookID:   d.WebhookID,
+		EventID:     d.EventID,
+		EventType:   d.EventType,
+		Status:      d.Status,
+		Attempts:    d.Attempts,
+		DeliveredAt: d.DeliveredAt,
+		CreatedAt:   d.CreatedAt,
+	}
+	if d.ResponseStatus != nil {
+		result.ResponseStatus = *d.ResponseStatus
+	}
+	if d.ResponseBody != nil {
+		result.ResponseBody = *d.ResponseBody
+	}
+	if d.Error != nil {
+		result.Error = *d.Error
+	}
+	if d.DurationMs != nil {
+		result.DurationMs = *d.DurationMs
+	}
+	if d.RedeliveryOf != nil {
+		result.RedeliveryOf = *d.RedeliveryOf
+	}
+	return result
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+// webhookReceiver is an httptest server recording webhook requests. It
+// answers with the queued status codes, then 200.
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	rcv := &webhookReceiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, r)
+		rcv.bodies = append(rcv.bodies, body)
+		status := http.StatusOK
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		rcv.mu.Unlock()
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newWebhookTestService(t *testing.T) (*WebhookService, *recordingEnqueuer) {
+	t.Helper()
+	// Test receivers listen on loopback
+	cfg := &config.Config{EncryptionKey: []byte("test-key-32-bytes-long-123456789"), WebhookAllowPrivateNetworks: true}
+	enqueuer := &recordingEnqueuer{}
+	svc, err := NewWebhookService(setupTestStore(t), cfg, enqueuer)
+	if err != nil {
+		t.Fatalf("Failed to create webhook service: %v", err)
+	}
+	return svc, enqueuer
+}
+
+func testEvent(t *testing.T, eventType events.EventType, data any) *events.Event {
+	t.Helper()
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &events.Event{
+		ID:        "evt-" + string(eventType),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      b,
+	}
+}
+
+// deliveryIDs returns the IDs of the webhook deliveries enqueued so far.
+func deliveryIDs(e *recordingEnqueuer) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var ids []string
+	for _, p := range e.payloads {
+		if dp, ok := p.(jobs.WebhookDeliveryPayload); ok {
+			ids = append(ids, dp.DeliveryID)
+		}
+	}
+	return ids
+}
+
+func TestWebhookService_DeliversSignedEvent(t *testing.T) {
+	ctx := context.Background()
+	svc, enqueuer := newWebhookTestService(t)
+	rcv := newWebhookReceiver(t)
+
+	url, secret := rcv.URL, "s3cret"
+	eventTypes := []string{string(events.EventTypeSessionUpdated)}
+	webhook, err := svc.CreateWebhook(ctx, "test-project", WebhookRequest{URL: &url, Secret: &secret, EventTypes: &eventTypes})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	svc.HandleEvent(ctx, "test-project", testEvent(t, events.EventTypeWorkspaceUpdated, events.WorkspaceUpdatedData{WorkspaceID: "ws-1", Status: "ready"}))
+	svc.HandleEvent(ctx, "other-project", testEvent(t, events.EventTypeSessionUpdated, events.SessionUpdatedData{SessionID: "s-0", Status: "error"}))
+	svc.HandleEvent(ctx, "test-project", testEvent(t, events.EventTypeSessionUpdated, events.SessionUpdatedData{SessionID: "s-1", Status: "error"}))
+
+	ids := deliveryIDs(enqueuer)
+	if len(ids) != 1 {
+		t.Fatalf("Expected 1 delivery for the subscribed event, got %d", len(ids))
+	}
+
+	if err := svc.Deliver(ctx, ids[0], false); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if rcv.count() != 1 {
+		t.Fatalf("Expected 1 request, got %d", rcv.count())
+	}
+
+	req, body := rcv.requests[0], rcv.bodies[0]
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if got, want := req.Header.Get(WebhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("Signature = %q, want %q", got, want)
+	}
+	if got := req.Header.Get(WebhookEventHeader); got != "session_updated" {
+		t.Errorf("Event header = %q", got)
+	}
+	if got := req.Header.Get(WebhookDeliveryHeader); got != ids[0] {
+		t.Errorf("Delivery header = %q, want %q", got, ids[0])
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	var data events.SessionUpdatedData
+	if err := json.Unmarshal(payload.Data, &data); err != nil {
+		t.Fatalf("Invalid payload data: %v", err)
+	}
+	if payload.Type != events.EventTypeSessionUpdated || payload.ProjectID != "test-project" || data.SessionID != "s-1" {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+
+	delivery, err := svc.GetDelivery(ctx, "test-project", webhook.ID, ids[0])
+	if err != nil {
+		t.Fatalf("GetDelivery failed: %v", err)
+	}
+	if delivery.Status != model.WebhookDeliveryStatusSucceeded || delivery.ResponseStatus != 200 || delivery.Attempts != 1 {
+		t.Errorf("Unexpected delivery %+v", delivery)
+	}
+}
+
+func TestWebhookService_RetryAndRedeliver(t *testing.T) {
+	ctx := context.Background()
+	svc, enqueuer := newWebhookTestService(t)
+	rcv := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+
+	url := rcv.URL
+	webhook, err := svc.CreateWebhook(ctx, "test-project", WebhookRequest{URL: &url})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	svc.HandleEvent(ctx, "test-project", testEvent(t, events.EventTypeJobCompleted, events.JobCompletedData{JobID: "job-1", JobType: "session_commit", Status: "completed"}))
+	ids := deliveryIDs(enqueuer)
+	if len(ids) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(ids))
+	}
+
+	// First attempt fails and stays pending for the queue to retry
+	if err := svc.Deliver(ctx, ids[0], false); err == nil {
+		t.Fatal("Expected error for 500 response")
+	}
+	delivery, _ := svc.GetDelivery(ctx, "test-project", webhook.ID, ids[0])
+	if delivery.Status != model.WebhookDeliveryStatusPending || delivery.ResponseStatus != 500 {
+		t.Errorf("Expected pending delivery after 500, got %+v", delivery)
+	}
+
+	// Last attempt fails the delivery
+	if err := svc.Deliver(ctx, ids[0], true); err == nil {
+		t.Fatal("Expected error for 502 response")
+	}
+	delivery, _ = svc.GetDelivery(ctx, "test-project", webhook.ID, ids[0])
+	if delivery.Status != model.WebhookDeliveryStatusFailed || delivery.Attempts != 2 || delivery.Error == "" {
+		t.Errorf("Expected failed delivery after 2 attempts, got %+v", delivery)
+	}
+
+	redelivery, err := svc.Redeliver(ctx, "test-project", webhook.ID, ids[0])
+	if err != nil {
+		t.Fatalf("Redeliver failed: %v", err)
+	}
+	if redelivery.ID == ids[0] || redelivery.RedeliveryOf != ids[0] {
+		t.Errorf("Expected new delivery referencing the original, got %+v", redelivery)
+	}
+	if err := svc.Deliver(ctx, redelivery.ID, false); err != nil {
+		t.Fatalf("Redelivery failed: %v", err)
+	}
+	if string(rcv.bodies[2]) != string(rcv.bodies[0]) {
+		t.Error("Expected redelivery to send the original payload")
+	}
+
+	deliveries, err := svc.ListDeliveries(ctx, "test-project", webhook.ID)
+	if err != nil {
+		t.Fatalf("ListDeliveries failed: %v", err)
+	}
+	if len(deliveries) != 2 {
+		t.Errorf("Expected 2 deliveries in the log, got %d", len(deliveries))
+	}
+}
+
+func TestWebhookService_IgnoresOwnJobEvents(t *testing.T) {
+	ctx := context.Background()
+	svc, enqueuer := newWebhookTestService(t)
+
+	url := "https://example.com/hook"
+	if _, err := svc.CreateWebhook(ctx, "test-project", WebhookRequest{URL: &url}); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	svc.HandleEvent(ctx, "test-project", testEvent(t, events.EventTypeJobUpdated, events.JobUpdatedData{JobID: "job-1", JobType: string(jobs.JobTypeWebhookDelivery), Status: "pending"}))
+	if ids := deliveryIDs(enqueuer); len(ids) != 0 {
+		t.Errorf("Expected no delivery for webhook job events, got %d", len(ids))
+	}
+}
+
+func TestWebhookService_CreateAndValidate(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newWebhookTestService(t)
+
+	url := "https://example.com/hook"
+	created, err := svc.CreateWebhook(ctx, "test-project", WebhookRequest{URL: &url})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if len(created.Secret) != 64 || !created.Enabled {
+		t.Errorf("Expected generated secret and enabled webhook, got %+v", created)
+	}
+
+	got, err := svc.GetWebhook(ctx, "test-project", created.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook failed: %v", err)
+	}
+	if got.Secret != "" {
+		t.Error("Expected secret to be omitted after creation")
+	}
+
+	if _, err := svc.GetWebhook(ctx, "other-project", created.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+
+	badURL := "ftp://example.com"
+	badTypes := []string{"session_deleted"}
+	empty := ""
+	for i, req := range []WebhookRequest{
+		{},
+		{URL: &badURL},
+		{URL: &url, EventTypes: &badTypes},
+	} {
+		if _, err := svc.CreateWebhook(ctx, "test-project", req); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("case %d: expected ErrInvalidWebhook, got %v", i, err)
+		}
+	}
+	if _, err := svc.UpdateWebhook(ctx, "test-project", created.ID, WebhookRequest{Secret: &empty}); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("Expected ErrInvalidWebhook for empty secret, got %v", err)
+	}
+}
+
+func TestBlockedWebhookIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"0.0.0.0", true},
+		{"0.255.0.1", true},
+		{"10.1.2.3", true},
+		{"100.64.0.0", true},
+		{"100.127.255.255", true},
+		{"169.254.169.254", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:100.100.100.200", true},
+		{"::ffff:0.0.0.1", true},
+		{"100.63.255.255", false},
+		{"100.128.0.0", false},
+		{"1.2.3.4", false},
+		{"::ffff:8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := blockedWebhookIP(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("blockedWebhookIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestWebhookService_BlocksPrivateAddresses(t *testing.T) {
+	ctx := context.Background()
+	svc, enqueuer := newWebhookTestService(t)
+	rcv := newWebhookReceiver(t)
+
+	// Created while allowed, so delivery hits the dialer check
+	url := rcv.URL
+	webhook, err := svc.CreateWebhook(ctx, "test-project", WebhookRequest{URL: &url})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	svc.allowPrivateNetworks = false
+
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"https://10.0.0.1/hook",
+		"https://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+		"http://0.1.2.3/hook",
+		"http://100.64.0.1/hook",
+		"http://100.127.255.254/hook",
+		"http://[::ffff:10.0.0.1]/hook",
+		"http://[::ffff:169.254.169.254]/hook",
+		"http://[::ffff:100.64.0.1]/hook",
+	} {
+		if _, err := svc.CreateWebhook(ctx, "test-project", WebhookRequest{URL: &u}); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("%s: expected ErrInvalidWebhook, got %v", u, err)
+		}
+		if _, err := svc.UpdateWebhook(ctx, "test-project", webhook.ID, WebhookRequest{URL: &u}); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("%s: expected ErrInvalidWebhook on update, got %v", u, err)
+		}
+	}
+
+	svc.HandleEvent(ctx, "test-project", testEvent(t, events.EventTypeSessionUpdated, events.SessionUpdatedData{SessionID: "s-1", Status: "ready"}))
+	ids := deliveryIDs(enqueuer)
+	if len(ids) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(ids))
+	}
+	err = svc.Deliver(ctx, ids[0], true)
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("Expected the loopback receiver to be refused, got %v", err)
+	}
+	if rcv.count() != 0 {
+		t.Errorf("Expected no request to reach the receiver, got %d", rcv.count())
+	}
+}
@@ -39,10 +39,19 @@ type Workspace struct {
 	Provider     string     `json:"provider,omitempty"`
 	Status       string     `json:"status"`
 	ErrorMessage string     `json:"errorMessage,omitempty"`
+	Submodules   bool       `json:"submodules"`
+	LFS          bool       `json:"lfs"`
 	WorkDir      string     `json:"workDir,omitempty"`
 	Sessions     []*Session `json:"sessions"`
 }
 
+// WorkspaceOptions selects what is checked out besides the repository
+// itself, on the server and in sandboxes.
+type WorkspaceOptions struct {
+	Submodules bool `json:"submodules,omitempty"` // Initialize submodules recursively
+	LFS        bool `json:"lfs,omitempty"`        // Download Git LFS objects
+}
+
 // WorkspaceService handles workspace operations
 type WorkspaceService struct {
 	store       *store.Store
@@ -86,7 +95,7 @@ func (s *WorkspaceService) GetWorkspace(ctx context.Context, workspaceID string)
 // CreateWorkspace creates a new workspace with initializing status.
 // For local paths: if the directory does not exist or is empty, it will be
 // created and initialized as a new git repository automatically.
-func (s *WorkspaceService) CreateWorkspace(ctx context.Context, projectID, path, sourceType, provider string) (*Workspace, error) {
+func (s *WorkspaceService) CreateWorkspace(ctx context.Context, projectID, path, sourceType, provider string, opts WorkspaceOptions) (*Workspace, error) {
 	// Expand ~ to home directory for local paths
 	if sourceType == "local" {
 		expandedPath, err := expandPath(path)
@@ -189,6 +198,8 @@ func (s *WorkspaceService) CreateWorkspace(ctx context.Context, projectID, path,
 		SourceType: sourceType,
 		Provider:   provider,
 		Status:     model.WorkspaceStatusInitializing,
+		Submodules: opts.Submodules,
+		LFS:        opts.LFS,
 	}
 	if err := s.store.CreateWorkspace(ctx, ws); err != nil {
 		return nil, fmt.Errorf("failed to create workspace: %w", err)
@@ -230,6 +241,8 @@ func (s *WorkspaceService) mapWorkspace(ctx context.Context, ws *model.Workspace
 		SourceType:  ws.SourceType,
 		Provider:    ws.Provider,
 		Status:      ws.Status,
+		Submodules:  ws.Submodules,
+		LFS:         ws.LFS,
 		Sessions:    []*Session{},
 	}
 	if ws.ErrorMessage != nil {
@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/obot-platform/discobot/server/internal/git"
 	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/model"
 )
@@ -49,6 +52,60 @@ func TestCommitSession_Success(t *testing.T) {
 	}
 }
 
+// TestCommitSession_TargetBranch tests that commits to a branch other than the
+// checked out one are keyed on that branch.
+func TestCommitSession_TargetBranch(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, initialCommit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, initialCommit)
+	checkedOut := strings.TrimSpace(runGit(t, workspace.Path, "rev-parse", "--abbrev-ref", "HEAD"))
+
+	var enqueued []jobs.SessionCommitPayload
+	mockEnqueuer := &mockJobEnqueuer{
+		enqueueFunc: func(_ context.Context, payload jobs.JobPayload) error {
+			enqueued = append(enqueued, payload.(jobs.SessionCommitPayload))
+			return nil
+		},
+	}
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, nil, env.eventBroker, mockEnqueuer)
+
+	for _, branch := range []string{"feature", checkedOut} {
+		// Start from a session without a commit in progress
+		session.CommitStatus = model.CommitStatusNone
+		if err := env.store.UpdateSession(context.Background(), session); err != nil {
+			t.Fatalf("Failed to update session: %v", err)
+		}
+		if _, err := sessionSvc.SetTargetBranch(context.Background(), project.ID, session.ID, branch); err != nil {
+			t.Fatalf("SetTargetBranch(%s) failed: %v", branch, err)
+		}
+		if err := sessionSvc.CommitSession(context.Background(), project.ID, session.ID, mockEnqueuer); err != nil {
+			t.Fatalf("CommitSession failed: %v", err)
+		}
+	}
+
+	if len(enqueued) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(enqueued))
+	}
+	if resourceType, resourceID := enqueued[0].ResourceKey(); resourceType != jobs.ResourceTypeBranch || resourceID != workspace.ID+":feature" {
+		t.Errorf("Expected branch resource key, got %s %s", resourceType, resourceID)
+	}
+	if resourceType, _ := enqueued[1].ResourceKey(); resourceType != jobs.ResourceTypeWorkspace {
+		t.Errorf("Expected the checked out branch to be keyed on the workspace, got %s", resourceType)
+	}
+
+	// The branch cannot change while a commit is in progress
+	if _, err := sessionSvc.SetTargetBranch(context.Background(), project.ID, session.ID, "other"); !errors.Is(err, ErrCommitInProgress) {
+		t.Errorf("Expected ErrCommitInProgress, got %v", err)
+	}
+	if _, err := sessionSvc.SetTargetBranch(context.Background(), project.ID, session.ID, "bad..name"); !errors.Is(err, git.ErrInvalidBranch) {
+		t.Errorf("Expected ErrInvalidBranch, got %v", err)
+	}
+}
+
 // TestCommitSession_EnqueueFailure tests that CommitSession returns an error when enqueue fails.
 func TestCommitSession_EnqueueFailure(t *testing.T) {
 	env := newTestEnv(t)
@@ -2,6 +2,7 @@
 package store
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"time"
@@ -136,19 +137,41 @@ func (s *Store) DeleteProject(ctx context.Context, id string) error {
 			return err
 		}
 		for _, ws := range workspaces {
-			// Delete messages and terminal history for each session
+			// Delete messages, terminal history and reviews for each session
 			if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", ws.ID).Delete(&model.Message{}).Error; err != nil {
 				return err
 			}
 			if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", ws.ID).Delete(&model.TerminalHistory{}).Error; err != nil {
 				return err
 			}
+			if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", ws.ID).Delete(&model.ReviewComment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", ws.ID).Delete(&model.ReviewThread{}).Error; err != nil {
+				return err
+			}
 			// Delete sessions
 			if err := tx.Where("workspace_id = ?", ws.ID).Delete(&model.Session{}).Error; err != nil {
 				return err
 			}
 		}
 
+		// Delete schedules and their run history
+		if err := tx.Where("project_id = ?", id).Delete(&model.ScheduleRun{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", id).Delete(&model.Schedule{}).Error; err != nil {
+			return err
+		}
+
+		// Delete webhooks and their delivery log
+		if err := tx.Where("project_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", id).Delete(&model.Webhook{}).Error; err != nil {
+			return err
+		}
+
 		// Delete workspaces
 		if err := tx.Where("project_id = ?", id).Delete(&model.Workspace{}).Error; err != nil {
 			return err
@@ -168,6 +191,9 @@ func (s *Store) DeleteProject(ctx context.Context, id string) error {
 		if err := tx.Where("project_id = ?", id).Delete(&model.Credential{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("project_id = ?", id).Delete(&model.GitCredential{}).Error; err != nil {
+			return err
+		}
 
 		// Delete members
 		if err := tx.Where("project_id = ?", id).Delete(&model.ProjectMember{}).Error; err != nil {
@@ -262,19 +288,33 @@ func (s *Store) UpdateWorkspace(ctx context.Context, workspace *model.Workspace)
 
 func (s *Store) DeleteWorkspace(ctx context.Context, id string) error {
 	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Delete messages and terminal history for all sessions in this workspace
+		// Delete messages, terminal history and reviews for all sessions in this workspace
 		if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", id).Delete(&model.Message{}).Error; err != nil {
 			return err
 		}
 		if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", id).Delete(&model.TerminalHistory{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", id).Delete(&model.ReviewComment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", id).Delete(&model.ReviewThread{}).Error; err != nil {
+			return err
+		}
 
 		// Delete sessions
 		if err := tx.Where("workspace_id = ?", id).Delete(&model.Session{}).Error; err != nil {
 			return err
 		}
 
+		// Delete schedules that start sessions in this workspace
+		if err := tx.Where("schedule_id IN (SELECT id FROM schedules WHERE workspace_id = ?)", id).Delete(&model.ScheduleRun{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", id).Delete(&model.Schedule{}).Error; err != nil {
+			return err
+		}
+
 		// Delete the workspace
 		return tx.Delete(&model.Workspace{}, "id = ?", id).Error
 	})
@@ -353,6 +393,21 @@ func (s *Store) UpdateSessionWorkspace(ctx context.Context, id, workspacePath, w
 	return s.writeDB.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// UpdateSessionPullRequest updates the pull request fields of a session.
+// A nil number leaves the branch, number and URL unchanged, so that a failed
+// retry keeps the last opened pull request.
+func (s *Store) UpdateSessionPullRequest(ctx context.Context, id, branch string, number *int, url string, errorMessage *string) error {
+	updates := map[string]interface{}{
+		"pull_request_error": errorMessage,
+	}
+	if number != nil {
+		updates["pull_request_branch"] = branch
+		updates["pull_request_number"] = *number
+		updates["pull_request_url"] = url
+	}
+	return s.writeDB.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
 func (s *Store) DeleteSession(ctx context.Context, id string) error {
 	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Delete messages
@@ -365,6 +420,14 @@ func (s *Store) DeleteSession(ctx context.Context, id string) error {
 			return err
 		}
 
+		// Delete review threads and their comments
+		if err := tx.Where("session_id = ?", id).Delete(&model.ReviewComment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", id).Delete(&model.ReviewThread{}).Error; err != nil {
+			return err
+		}
+
 		// Delete the session
 		return tx.Delete(&model.Session{}, "id = ?", id).Error
 	})
@@ -435,7 +498,7 @@ func (s *Store) SetDefaultAgent(ctx context.Context, projectID, agentID string)
 
 func (s *Store) ListMessagesBySession(ctx context.Context, sessionID string) ([]*model.Message, error) {
 	var messages []*model.Message
-	err := s.readDB.WithContext(ctx).Where("session_id = ?", sessionID).Order("turn ASC").Find(&messages).Error
+	err := s.readDB.WithContext(ctx).Where("session_id = ?", sessionID).Order("created_at ASC").Find(&messages).Error
 	return messages, err
 }
 
@@ -443,6 +506,51 @@ func (s *Store) CreateMessage(ctx context.Context, message *model.Message) error
 	return s.writeDB.WithContext(ctx).Create(message).Error
 }
 
+// CreateMessages inserts several messages in a single transaction.
+func (s *Store) CreateMessages(ctx context.Context, messages []*model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return s.writeDB.WithContext(ctx).Create(messages).Error
+}
+
+// UpsertSessionMessages stores a session's transcript in conversation order.
+// Rows are matched by MessageID: changed ones are updated and new ones
+// appended after the existing ones. Rows missing from messages are kept, so
+// history survives a sandbox that lost its own.
+func (s *Store) UpsertSessionMessages(ctx context.Context, sessionID string, messages []*model.Message) error {
+	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []*model.Message
+		if err := tx.Where("session_id = ? AND message_id <> ''", sessionID).Find(&existing).Error; err != nil {
+			return err
+		}
+		byMessageID := make(map[string]*model.Message, len(existing))
+		for _, m := range existing {
+			byMessageID[m.MessageID] = m
+		}
+
+		// Offset timestamps so appended messages keep their order.
+		now := time.Now()
+		for i, msg := range messages {
+			if row, ok := byMessageID[msg.MessageID]; ok {
+				if row.Role == msg.Role && bytes.Equal(row.Parts, msg.Parts) {
+					continue
+				}
+				if err := tx.Model(row).Updates(map[string]any{"role": msg.Role, "parts": msg.Parts}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			msg.SessionID = sessionID
+			msg.CreatedAt = now.Add(time.Duration(i) * time.Millisecond)
+			if err := tx.Create(msg).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // --- Credentials ---
 
 func (s *Store) GetCredentialByProvider(ctx context.Context, projectID, provider string) (*model.Credential, error) {
@@ -474,6 +582,172 @@ func (s *Store) DeleteCredential(ctx context.Context, projectID, provider string
 	return s.writeDB.WithContext(ctx).Delete(&model.Credential{}, "project_id = ? AND provider = ?", projectID, provider).Error
 }
 
+// --- Git Credentials ---
+
+func (s *Store) GetGitCredential(ctx context.Context, projectID, id string) (*model.GitCredential, error) {
+	var credential model.GitCredential
+	if err := s.readDB.WithContext(ctx).First(&credential, "project_id = ? AND id = ?", projectID, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (s *Store) GetGitCredentialByHost(ctx context.Context, projectID, host, authType string) (*model.GitCredential, error) {
+	var credential model.GitCredential
+	if err := s.readDB.WithContext(ctx).First(&credential, "project_id = ? AND host = ? AND auth_type = ?", projectID, host, authType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (s *Store) ListGitCredentialsByProject(ctx context.Context, projectID string) ([]*model.GitCredential, error) {
+	var credentials []*model.GitCredential
+	err := s.readDB.WithContext(ctx).Where("project_id = ?", projectID).Order("host, auth_type").Find(&credentials).Error
+	return credentials, err
+}
+
+func (s *Store) CreateGitCredential(ctx context.Context, credential *model.GitCredential) error {
+	return s.writeDB.WithContext(ctx).Create(credential).Error
+}
+
+func (s *Store) UpdateGitCredential(ctx context.Context, credential *model.GitCredential) error {
+	return s.writeDB.WithContext(ctx).Save(credential).Error
+}
+
+func (s *Store) DeleteGitCredential(ctx context.Context, projectID, id string) error {
+	result := s.writeDB.WithContext(ctx).Delete(&model.GitCredential{}, "project_id = ? AND id = ?", projectID, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// --- Review Threads ---
+
+func (s *Store) GetReviewThread(ctx context.Context, sessionID, id string) (*model.ReviewThread, error) {
+	var thread model.ReviewThread
+	if err := s.readDB.WithContext(ctx).First(&thread, "id = ? AND session_id = ?", id, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ListReviewThreadsBySession returns a session's review threads ordered by
+// file and line.
+func (s *Store) ListReviewThreadsBySession(ctx context.Context, sessionID string) ([]*model.ReviewThread, error) {
+	var threads []*model.ReviewThread
+	err := s.readDB.WithContext(ctx).Where("session_id = ?", sessionID).
+		Order("path ASC, start_line ASC, created_at ASC").Find(&threads).Error
+	return threads, err
+}
+
+func (s *Store) CreateReviewThread(ctx context.Context, thread *model.ReviewThread, comment *model.ReviewComment) error {
+	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(thread).Error; err != nil {
+			return err
+		}
+		comment.ThreadID = thread.ID
+		return tx.Create(comment).Error
+	})
+}
+
+func (s *Store) UpdateReviewThread(ctx context.Context, thread *model.ReviewThread) error {
+	return s.writeDB.WithContext(ctx).Save(thread).Error
+}
+
+// MarkReviewThreadsOutdated flags threads whose anchored lines changed.
+func (s *Store) MarkReviewThreadsOutdated(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.writeDB.WithContext(ctx).Model(&model.ReviewThread{}).Where("id IN ?", ids).Update("outdated", true).Error
+}
+
+func (s *Store) DeleteReviewThread(ctx context.Context, sessionID, id string) error {
+	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND session_id = ?", id, sessionID).Delete(&model.ReviewThread{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("thread_id = ?", id).Delete(&model.ReviewComment{}).Error
+	})
+}
+
+// --- Review Comments ---
+
+func (s *Store) GetReviewComment(ctx context.Context, threadID, id string) (*model.ReviewComment, error) {
+	var comment model.ReviewComment
+	if err := s.readDB.WithContext(ctx).First(&comment, "id = ? AND thread_id = ?", id, threadID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ListReviewCommentsBySession returns all review comments of a session,
+// oldest first.
+func (s *Store) ListReviewCommentsBySession(ctx context.Context, sessionID string) ([]*model.ReviewComment, error) {
+	var comments []*model.ReviewComment
+	err := s.readDB.WithContext(ctx).Where("session_id = ?", sessionID).Order("created_at ASC").Find(&comments).Error
+	return comments, err
+}
+
+func (s *Store) CreateReviewComment(ctx context.Context, comment *model.ReviewComment) error {
+	return s.writeDB.WithContext(ctx).Create(comment).Error
+}
+
+func (s *Store) UpdateReviewComment(ctx context.Context, comment *model.ReviewComment) error {
+	return s.writeDB.WithContext(ctx).Save(comment).Error
+}
+
+// DeleteReviewComment deletes a comment, and its thread if it was the
+// thread's last comment.
+func (s *Store) DeleteReviewComment(ctx context.Context, threadID, id string) error {
+	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND thread_id = ?", id, threadID).Delete(&model.ReviewComment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		var remaining int64
+		if err := tx.Model(&model.ReviewComment{}).Where("thread_id = ?", threadID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Delete(&model.ReviewThread{}, "id = ?", threadID).Error
+		}
+		return nil
+	})
+}
+
+// MarkReviewCommentsSent records when pending comments were sent to the agent.
+func (s *Store) MarkReviewCommentsSent(ctx context.Context, ids []string, sentAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.writeDB.WithContext(ctx).Model(&model.ReviewComment{}).
+		Where("id IN ? AND sent_at IS NULL", ids).Update("sent_at", sentAt).Error
+}
+
 // --- Terminal History ---
 
 func (s *Store) ListTerminalHistory(ctx context.Context, sessionID string, limit int) ([]*model.TerminalHistory, error) {
@@ -625,26 +899,31 @@ func (s *Store) ClaimJobOfTypes(ctx context.Context, jobTypes []string, workerID
 	return &job, nil
 }
 
-// CompleteJob marks a job as completed.
+// CompleteJob marks a running job as completed.
+// Jobs that are no longer running (e.g. cancelled) are left unchanged.
 func (s *Store) CompleteJob(ctx context.Context, jobID string) error {
 	now := time.Now()
 	return s.writeDB.WithContext(ctx).Model(&model.Job{}).
-		Where("id = ?", jobID).
+		Where("id = ? AND status = ?", jobID, model.JobStatusRunning).
 		Updates(map[string]interface{}{
 			"status":       model.JobStatusCompleted,
 			"completed_at": now,
 		}).Error
 }
 
-// FailJob marks a job as failed with an error message.
+// FailJob marks a running job as failed with an error message.
 // If attempts < max_attempts, requeues as pending for retry with backoff.
 // The baseBackoff is multiplied by the attempt number for exponential backoff.
+// Jobs that are no longer running (e.g. cancelled) are left unchanged.
 func (s *Store) FailJob(ctx context.Context, jobID string, errMsg string, baseBackoff time.Duration) error {
 	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var job model.Job
 		if err := tx.First(&job, "id = ?", jobID).Error; err != nil {
 			return err
 		}
+		if job.Status != string(model.JobStatusRunning) {
+			return nil
+		}
 
 		if job.Attempts < job.MaxAttempts {
 			// Retry: reset to pending with exponential backoff
@@ -703,6 +982,275 @@ func (s *Store) ListPendingJobTypes(ctx context.Context) ([]string, error) {
 	return types, err
 }
 
+// JobFilter narrows ListJobs. Empty fields match any value.
+type JobFilter struct {
+	ProjectID    string
+	Type         string
+	Status       string
+	ResourceType string
+	ResourceID   string
+	Limit        int
+	Offset       int
+}
+
+// ListJobs returns jobs matching the filter, newest first.
+func (s *Store) ListJobs(ctx context.Context, filter JobFilter) ([]*model.Job, error) {
+	query := s.readDB.WithContext(ctx).Order("created_at DESC")
+	if filter.ProjectID != "" {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var jobs []*model.Job
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CancelJob marks a pending or running job as cancelled.
+// Returns false if the job is in any other state.
+func (s *Store) CancelJob(ctx context.Context, jobID, reason string) (bool, error) {
+	now := time.Now()
+	result := s.writeDB.WithContext(ctx).Model(&model.Job{}).
+		Where("id = ? AND status IN ?", jobID, []string{string(model.JobStatusPending), string(model.JobStatusRunning)}).
+		Updates(map[string]interface{}{
+			"status":       model.JobStatusCancelled,
+			"completed_at": now,
+			"error":        reason,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RetryJob requeues a failed or cancelled job to run once more, keeping its
+// attempt count. Returns false if the job is in any other state.
+func (s *Store) RetryJob(ctx context.Context, jobID string) (bool, error) {
+	result := s.writeDB.WithContext(ctx).Model(&model.Job{}).
+		Where("id = ? AND status IN ?", jobID, []string{string(model.JobStatusFailed), string(model.JobStatusCancelled)}).
+		Updates(map[string]interface{}{
+			"status":       model.JobStatusPending,
+			"max_attempts": gorm.Expr("attempts + 1"),
+			"error":        nil,
+			"worker_id":    nil,
+			"started_at":   nil,
+			"completed_at": nil,
+			"scheduled_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FilterCancelledJobs returns the IDs among jobIDs whose jobs have been cancelled.
+func (s *Store) FilterCancelledJobs(ctx context.Context, jobIDs []string) ([]string, error) {
+	if len(jobIDs) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	err := s.readDB.WithContext(ctx).Model(&model.Job{}).
+		Where("id IN ? AND status = ?", jobIDs, model.JobStatusCancelled).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// --- Schedules ---
+
+func (s *Store) GetScheduleByID(ctx context.Context, id string) (*model.Schedule, error) {
+	var schedule model.Schedule
+	if err := s.readDB.WithContext(ctx).First(&schedule, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *Store) ListSchedulesByProject(ctx context.Context, projectID string) ([]*model.Schedule, error) {
+	var schedules []*model.Schedule
+	err := s.readDB.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+func (s *Store) CreateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	return s.writeDB.WithContext(ctx).Create(schedule).Error
+}
+
+func (s *Store) UpdateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	return s.writeDB.WithContext(ctx).Save(schedule).Error
+}
+
+func (s *Store) DeleteSchedule(ctx context.Context, id string) error {
+	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", id).Delete(&model.ScheduleRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Schedule{}, "id = ?", id).Error
+	})
+}
+
+// ListDueSchedules returns enabled schedules whose next run is at or before now.
+func (s *Store) ListDueSchedules(ctx context.Context, now time.Time) ([]*model.Schedule, error) {
+	var schedules []*model.Schedule
+	err := s.readDB.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// ClaimScheduleRun moves a due schedule's next run time forward.
+// Returns false if the schedule is no longer due, e.g. because another
+// server already claimed this run.
+func (s *Store) ClaimScheduleRun(ctx context.Context, id string, now time.Time, nextRunAt *time.Time) (bool, error) {
+	result := s.writeDB.WithContext(ctx).Model(&model.Schedule{}).
+		Where("id = ? AND enabled = ? AND next_run_at <= ?", id, true, now).
+		Updates(map[string]interface{}{
+			"next_run_at": nextRunAt,
+			"last_run_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// --- Schedule Runs ---
+
+func (s *Store) GetScheduleRunByID(ctx context.Context, id string) (*model.ScheduleRun, error) {
+	var run model.ScheduleRun
+	if err := s.readDB.WithContext(ctx).First(&run, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListScheduleRuns returns a schedule's runs, newest first.
+func (s *Store) ListScheduleRuns(ctx context.Context, scheduleID string, limit int) ([]*model.ScheduleRun, error) {
+	query := s.readDB.WithContext(ctx).Where("schedule_id = ?", scheduleID).Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var runs []*model.ScheduleRun
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// ListScheduleRunsByStatus returns all runs with the given status.
+func (s *Store) ListScheduleRunsByStatus(ctx context.Context, status string) ([]*model.ScheduleRun, error) {
+	var runs []*model.ScheduleRun
+	err := s.readDB.WithContext(ctx).Where("status = ?", status).Find(&runs).Error
+	return runs, err
+}
+
+// HasActiveScheduleRun checks if a schedule has a pending or running run.
+func (s *Store) HasActiveScheduleRun(ctx context.Context, scheduleID string) (bool, error) {
+	var count int64
+	err := s.readDB.WithContext(ctx).Model(&model.ScheduleRun{}).
+		Where("schedule_id = ? AND status IN ?", scheduleID,
+			[]string{model.ScheduleRunStatusPending, model.ScheduleRunStatusRunning}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *Store) CreateScheduleRun(ctx context.Context, run *model.ScheduleRun) error {
+	return s.writeDB.WithContext(ctx).Create(run).Error
+}
+
+func (s *Store) UpdateScheduleRun(ctx context.Context, run *model.ScheduleRun) error {
+	return s.writeDB.WithContext(ctx).Save(run).Error
+}
+
+// --- Webhooks ---
+
+func (s *Store) GetWebhookByID(ctx context.Context, id string) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := s.readDB.WithContext(ctx).First(&webhook, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (s *Store) ListWebhooksByProject(ctx context.Context, projectID string) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	err := s.readDB.WithContext(ctx).Where("project_id = ?", projectID).Order("created_at ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListEnabledWebhooksByProject returns the project's enabled webhooks.
+func (s *Store) ListEnabledWebhooksByProject(ctx context.Context, projectID string) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	err := s.readDB.WithContext(ctx).Where("project_id = ? AND enabled = ?", projectID, true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return s.writeDB.WithContext(ctx).Create(webhook).Error
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return s.writeDB.WithContext(ctx).Save(webhook).Error
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, id string) error {
+	return s.writeDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Webhook{}, "id = ?", id).Error
+	})
+}
+
+// --- Webhook Deliveries ---
+
+func (s *Store) GetWebhookDeliveryByID(ctx context.Context, id string) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	if err := s.readDB.WithContext(ctx).First(&delivery, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns a webhook's deliveries, newest first.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*model.WebhookDelivery, error) {
+	query := s.readDB.WithContext(ctx).Where("webhook_id = ?", webhookID).Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var deliveries []*model.WebhookDelivery
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (s *Store) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.writeDB.WithContext(ctx).Create(delivery).Error
+}
+
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.writeDB.WithContext(ctx).Save(delivery).Error
+}
+
 // --- Dispatcher Leader Election ---
 
 // TryAcquireLeadership attempts to become the leader.
@@ -847,6 +1395,85 @@ func (s *Store) GetMaxEventSeq(ctx context.Context) (int64, error) {
 	return maxSeq, err
 }
 
+// ListProjectEventsAfterSeq returns a project's events with seq > afterSeq
+// in ascending order by sequence number.
+func (s *Store) ListProjectEventsAfterSeq(ctx context.Context, projectID string, afterSeq int64) ([]model.ProjectEvent, error) {
+	var events []model.ProjectEvent
+	err := s.readDB.WithContext(ctx).
+		Where("project_id = ? AND seq > ?", projectID, afterSeq).
+		Order("seq ASC").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ProjectEventSeqExists reports whether the project still has the event with
+// the given sequence number.
+func (s *Store) ProjectEventSeqExists(ctx context.Context, projectID string, seq int64) (bool, error) {
+	var count int64
+	err := s.readDB.WithContext(ctx).
+		Model(&model.ProjectEvent{}).
+		Where("project_id = ? AND seq = ?", projectID, seq).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetMaxProjectEventSeq returns the highest sequence number of a project's
+// events. Returns 0 if the project has no events.
+func (s *Store) GetMaxProjectEventSeq(ctx context.Context, projectID string) (int64, error) {
+	var maxSeq int64
+	err := s.readDB.WithContext(ctx).
+		Model(&model.ProjectEvent{}).
+		Where("project_id = ?", projectID).
+		Select("COALESCE(MAX(seq), 0)").
+		Scan(&maxSeq).Error
+	return maxSeq, err
+}
+
+// TrimProjectEvents deletes all but the newest keep events of every project.
+// Returns the number of deleted events. A keep of 0 or less deletes nothing.
+func (s *Store) TrimProjectEvents(ctx context.Context, keep int) (int64, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	var projectIDs []string
+	err := s.readDB.WithContext(ctx).
+		Model(&model.ProjectEvent{}).
+		Group("project_id").
+		Having("COUNT(*) > ?", keep).
+		Pluck("project_id", &projectIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, projectID := range projectIDs {
+		// Oldest sequence number that is kept
+		var minSeq int64
+		err := s.writeDB.WithContext(ctx).
+			Model(&model.ProjectEvent{}).
+			Where("project_id = ?", projectID).
+			Order("seq DESC").
+			Offset(keep-1).
+			Limit(1).
+			Pluck("seq", &minSeq).Error
+		if err != nil {
+			return deleted, err
+		}
+
+		result := s.writeDB.WithContext(ctx).
+			Where("project_id = ? AND seq < ?", projectID, minSeq).
+			Delete(&model.ProjectEvent{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
+
 // DeleteOldProjectEvents deletes events older than the specified duration.
 // This can be called periodically to clean up old events.
 func (s *Store) DeleteOldProjectEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
@@ -857,6 +1484,14 @@ func (s *Store) DeleteOldProjectEvents(ctx context.Context, olderThan time.Durat
 	return result.RowsAffected, result.Error
 }
 
+// --- Notifications ---
+
+// Notify sends a Postgres notification on channel, waking every connection
+// listening on it. Postgres only.
+func (s *Store) Notify(ctx context.Context, channel string) error {
+	return s.writeDB.WithContext(ctx).Exec("SELECT pg_notify(?, '')", channel).Error
+}
+
 // --- User Preferences ---
 
 // GetUserPreference returns a single preference by user ID and key.